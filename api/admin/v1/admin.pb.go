@@ -0,0 +1,1276 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: admin/v1/admin.proto
+
+package adminv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LookupRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The client IP to resolve.
+	Ip string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	// Matched by the host resolver as the Host header of a request.
+	Host          string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupRequest) Reset() {
+	*x = LookupRequest{}
+	mi := &file_admin_v1_admin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupRequest) ProtoMessage() {}
+
+func (x *LookupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupRequest.ProtoReflect.Descriptor instead.
+func (*LookupRequest) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LookupRequest) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *LookupRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type LookupResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Ip    string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	// The resolvers tried, in order.
+	Resolvers []*ResolverStep `protobuf:"bytes,2,rep,name=resolvers,proto3" json:"resolvers,omitempty"`
+	// The name of the resolver that found the node.
+	Resolver string `protobuf:"bytes,3,opt,name=resolver,proto3" json:"resolver,omitempty"`
+	// The node found, unset when none was.
+	Node *Node `protobuf:"bytes,4,opt,name=node,proto3" json:"node,omitempty"`
+	// The error that stopped the lookup.
+	Error         string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupResponse) Reset() {
+	*x = LookupResponse{}
+	mi := &file_admin_v1_admin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupResponse) ProtoMessage() {}
+
+func (x *LookupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupResponse.ProtoReflect.Descriptor instead.
+func (*LookupResponse) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LookupResponse) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *LookupResponse) GetResolvers() []*ResolverStep {
+	if x != nil {
+		return x.Resolvers
+	}
+	return nil
+}
+
+func (x *LookupResponse) GetResolver() string {
+	if x != nil {
+		return x.Resolver
+	}
+	return ""
+}
+
+func (x *LookupResponse) GetNode() *Node {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *LookupResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// ResolverStep is the trace of one resolver of a lookup.
+type ResolverStep struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Name     string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Matched  bool                   `protobuf:"varint,2,opt,name=matched,proto3" json:"matched,omitempty"`
+	Duration *durationpb.Duration   `protobuf:"bytes,3,opt,name=duration,proto3" json:"duration,omitempty"`
+	// The MAC address the resolver found for the IP.
+	MacAddress string `protobuf:"bytes,4,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	// Why the resolver matched or not.
+	Notes         []string `protobuf:"bytes,5,rep,name=notes,proto3" json:"notes,omitempty"`
+	Error         string   `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolverStep) Reset() {
+	*x = ResolverStep{}
+	mi := &file_admin_v1_admin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolverStep) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolverStep) ProtoMessage() {}
+
+func (x *ResolverStep) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolverStep.ProtoReflect.Descriptor instead.
+func (*ResolverStep) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ResolverStep) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ResolverStep) GetMatched() bool {
+	if x != nil {
+		return x.Matched
+	}
+	return false
+}
+
+func (x *ResolverStep) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *ResolverStep) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+func (x *ResolverStep) GetNotes() []string {
+	if x != nil {
+		return x.Notes
+	}
+	return nil
+}
+
+func (x *ResolverStep) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Node identifies a node.
+type Node struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Uuid           string                 `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	ProvisionState string                 `protobuf:"bytes,3,opt,name=provision_state,json=provisionState,proto3" json:"provision_state,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Node) Reset() {
+	*x = Node{}
+	mi := &file_admin_v1_admin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Node) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Node) ProtoMessage() {}
+
+func (x *Node) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Node.ProtoReflect.Descriptor instead.
+func (*Node) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Node) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *Node) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Node) GetProvisionState() string {
+	if x != nil {
+		return x.ProvisionState
+	}
+	return ""
+}
+
+type PreviewRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The UUID or name of the node.
+	Node string `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	// The client IP the documents are rendered for, by default the first
+	// address of the node.
+	ClientIp      string `protobuf:"bytes,2,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PreviewRequest) Reset() {
+	*x = PreviewRequest{}
+	mi := &file_admin_v1_admin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PreviewRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreviewRequest) ProtoMessage() {}
+
+func (x *PreviewRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreviewRequest.ProtoReflect.Descriptor instead.
+func (*PreviewRequest) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PreviewRequest) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+func (x *PreviewRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+type PreviewResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	NodeUuid    string                 `protobuf:"bytes,1,opt,name=node_uuid,json=nodeUuid,proto3" json:"node_uuid,omitempty"`
+	NodeName    string                 `protobuf:"bytes,2,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
+	ClientIp    string                 `protobuf:"bytes,3,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	MetaData    *structpb.Value        `protobuf:"bytes,4,opt,name=meta_data,json=metaData,proto3" json:"meta_data,omitempty"`
+	NetworkData *structpb.Struct       `protobuf:"bytes,5,opt,name=network_data,json=networkData,proto3" json:"network_data,omitempty"`
+	// Unset when the node has no user data.
+	UserData    []byte           `protobuf:"bytes,6,opt,name=user_data,json=userData,proto3,oneof" json:"user_data,omitempty"`
+	VendorData  *structpb.Struct `protobuf:"bytes,7,opt,name=vendor_data,json=vendorData,proto3" json:"vendor_data,omitempty"`
+	VendorData2 *structpb.Struct `protobuf:"bytes,8,opt,name=vendor_data2,json=vendorData2,proto3" json:"vendor_data2,omitempty"`
+	// The content of the paths of the EC2 tree under /latest/.
+	Ec2 map[string]*structpb.Value `protobuf:"bytes,9,rep,name=ec2,proto3" json:"ec2,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The schema violations of each document.
+	Violations map[string]*Violations `protobuf:"bytes,10,rep,name=violations,proto3" json:"violations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The errors of the documents that failed to render, by name.
+	Errors        map[string]string `protobuf:"bytes,11,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PreviewResponse) Reset() {
+	*x = PreviewResponse{}
+	mi := &file_admin_v1_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PreviewResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreviewResponse) ProtoMessage() {}
+
+func (x *PreviewResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreviewResponse.ProtoReflect.Descriptor instead.
+func (*PreviewResponse) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PreviewResponse) GetNodeUuid() string {
+	if x != nil {
+		return x.NodeUuid
+	}
+	return ""
+}
+
+func (x *PreviewResponse) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
+func (x *PreviewResponse) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *PreviewResponse) GetMetaData() *structpb.Value {
+	if x != nil {
+		return x.MetaData
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetNetworkData() *structpb.Struct {
+	if x != nil {
+		return x.NetworkData
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetUserData() []byte {
+	if x != nil {
+		return x.UserData
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetVendorData() *structpb.Struct {
+	if x != nil {
+		return x.VendorData
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetVendorData2() *structpb.Struct {
+	if x != nil {
+		return x.VendorData2
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetEc2() map[string]*structpb.Value {
+	if x != nil {
+		return x.Ec2
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetViolations() map[string]*Violations {
+	if x != nil {
+		return x.Violations
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetErrors() map[string]string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type Violations struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Violations    []string               `protobuf:"bytes,1,rep,name=violations,proto3" json:"violations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Violations) Reset() {
+	*x = Violations{}
+	mi := &file_admin_v1_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Violations) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Violations) ProtoMessage() {}
+
+func (x *Violations) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Violations.ProtoReflect.Descriptor instead.
+func (*Violations) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Violations) GetViolations() []string {
+	if x != nil {
+		return x.Violations
+	}
+	return nil
+}
+
+type GetCacheStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCacheStatsRequest) Reset() {
+	*x = GetCacheStatsRequest{}
+	mi := &file_admin_v1_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCacheStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCacheStatsRequest) ProtoMessage() {}
+
+func (x *GetCacheStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCacheStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetCacheStatsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{7}
+}
+
+type GetCacheStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Caches        []*CacheStats          `protobuf:"bytes,1,rep,name=caches,proto3" json:"caches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCacheStatsResponse) Reset() {
+	*x = GetCacheStatsResponse{}
+	mi := &file_admin_v1_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCacheStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCacheStatsResponse) ProtoMessage() {}
+
+func (x *GetCacheStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCacheStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetCacheStatsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetCacheStatsResponse) GetCaches() []*CacheStats {
+	if x != nil {
+		return x.Caches
+	}
+	return nil
+}
+
+// CacheStats is the usage of a cache, as exposed in the
+// ironic_metadata_cache_* metrics.
+type CacheStats struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Name      string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Entries   int64                  `protobuf:"varint,2,opt,name=entries,proto3" json:"entries,omitempty"`
+	Hits      uint64                 `protobuf:"varint,3,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses    uint64                 `protobuf:"varint,4,opt,name=misses,proto3" json:"misses,omitempty"`
+	Evictions uint64                 `protobuf:"varint,5,opt,name=evictions,proto3" json:"evictions,omitempty"`
+	// An estimate of the memory the entries take, or zero for caches that do
+	// not size their entries.
+	Bytes int64 `protobuf:"varint,6,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	// When the oldest entry was stored, unset when the cache is empty.
+	Oldest        *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=oldest,proto3" json:"oldest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CacheStats) Reset() {
+	*x = CacheStats{}
+	mi := &file_admin_v1_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CacheStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CacheStats) ProtoMessage() {}
+
+func (x *CacheStats) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CacheStats.ProtoReflect.Descriptor instead.
+func (*CacheStats) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CacheStats) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CacheStats) GetEntries() int64 {
+	if x != nil {
+		return x.Entries
+	}
+	return 0
+}
+
+func (x *CacheStats) GetHits() uint64 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+func (x *CacheStats) GetMisses() uint64 {
+	if x != nil {
+		return x.Misses
+	}
+	return 0
+}
+
+func (x *CacheStats) GetEvictions() uint64 {
+	if x != nil {
+		return x.Evictions
+	}
+	return 0
+}
+
+func (x *CacheStats) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *CacheStats) GetOldest() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Oldest
+	}
+	return nil
+}
+
+type RefreshCacheRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshCacheRequest) Reset() {
+	*x = RefreshCacheRequest{}
+	mi := &file_admin_v1_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshCacheRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshCacheRequest) ProtoMessage() {}
+
+func (x *RefreshCacheRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshCacheRequest.ProtoReflect.Descriptor instead.
+func (*RefreshCacheRequest) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{10}
+}
+
+type RefreshCacheResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,1,opt,name=duration,proto3" json:"duration,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshCacheResponse) Reset() {
+	*x = RefreshCacheResponse{}
+	mi := &file_admin_v1_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshCacheResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshCacheResponse) ProtoMessage() {}
+
+func (x *RefreshCacheResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshCacheResponse.ProtoReflect.Descriptor instead.
+func (*RefreshCacheResponse) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RefreshCacheResponse) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+type InvalidateNodeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The UUID or name of the node.
+	Node          string `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvalidateNodeRequest) Reset() {
+	*x = InvalidateNodeRequest{}
+	mi := &file_admin_v1_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvalidateNodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvalidateNodeRequest) ProtoMessage() {}
+
+func (x *InvalidateNodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvalidateNodeRequest.ProtoReflect.Descriptor instead.
+func (*InvalidateNodeRequest) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *InvalidateNodeRequest) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+type InvalidateNodeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeUuid      string                 `protobuf:"bytes,1,opt,name=node_uuid,json=nodeUuid,proto3" json:"node_uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvalidateNodeResponse) Reset() {
+	*x = InvalidateNodeResponse{}
+	mi := &file_admin_v1_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvalidateNodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvalidateNodeResponse) ProtoMessage() {}
+
+func (x *InvalidateNodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvalidateNodeResponse.ProtoReflect.Descriptor instead.
+func (*InvalidateNodeResponse) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *InvalidateNodeResponse) GetNodeUuid() string {
+	if x != nil {
+		return x.NodeUuid
+	}
+	return ""
+}
+
+type WatchEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The types of events to stream, such as user_data, phone_home and
+	// lookup_failure. Empty streams every type.
+	Types         []string `protobuf:"bytes,1,rep,name=types,proto3" json:"types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_admin_v1_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *WatchEventsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+type WatchEventsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *Event                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsResponse) Reset() {
+	*x = WatchEventsResponse{}
+	mi := &file_admin_v1_admin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsResponse) ProtoMessage() {}
+
+func (x *WatchEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsResponse.ProtoReflect.Descriptor instead.
+func (*WatchEventsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *WatchEventsResponse) GetEvent() *Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+// Event is an event of the service, as sent to webhooks and NATS.
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the event, the same in every sink.
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type      string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Empty for lookup failures.
+	NodeUuid string `protobuf:"bytes,4,opt,name=node_uuid,json=nodeUuid,proto3" json:"node_uuid,omitempty"`
+	NodeName string `protobuf:"bytes,5,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
+	Endpoint string `protobuf:"bytes,6,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	ClientIp string `protobuf:"bytes,7,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	// The HTTP status the request was answered with.
+	Status        int32 `protobuf:"varint,8,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_admin_v1_admin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_v1_admin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_admin_v1_admin_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Event) GetNodeUuid() string {
+	if x != nil {
+		return x.NodeUuid
+	}
+	return ""
+}
+
+func (x *Event) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
+func (x *Event) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *Event) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *Event) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+var File_admin_v1_admin_proto protoreflect.FileDescriptor
+
+const file_admin_v1_admin_proto_rawDesc = "" +
+	"\n" +
+	"\x14admin/v1/admin.proto\x12\x18ironic_metadata.admin.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"3\n" +
+	"\rLookupRequest\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\"\xcc\x01\n" +
+	"\x0eLookupResponse\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12D\n" +
+	"\tresolvers\x18\x02 \x03(\v2&.ironic_metadata.admin.v1.ResolverStepR\tresolvers\x12\x1a\n" +
+	"\bresolver\x18\x03 \x01(\tR\bresolver\x122\n" +
+	"\x04node\x18\x04 \x01(\v2\x1e.ironic_metadata.admin.v1.NodeR\x04node\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\xc0\x01\n" +
+	"\fResolverStep\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\amatched\x18\x02 \x01(\bR\amatched\x125\n" +
+	"\bduration\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12\x1f\n" +
+	"\vmac_address\x18\x04 \x01(\tR\n" +
+	"macAddress\x12\x14\n" +
+	"\x05notes\x18\x05 \x03(\tR\x05notes\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\"W\n" +
+	"\x04Node\x12\x12\n" +
+	"\x04uuid\x18\x01 \x01(\tR\x04uuid\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12'\n" +
+	"\x0fprovision_state\x18\x03 \x01(\tR\x0eprovisionState\"A\n" +
+	"\x0ePreviewRequest\x12\x12\n" +
+	"\x04node\x18\x01 \x01(\tR\x04node\x12\x1b\n" +
+	"\tclient_ip\x18\x02 \x01(\tR\bclientIp\"\xdf\x06\n" +
+	"\x0fPreviewResponse\x12\x1b\n" +
+	"\tnode_uuid\x18\x01 \x01(\tR\bnodeUuid\x12\x1b\n" +
+	"\tnode_name\x18\x02 \x01(\tR\bnodeName\x12\x1b\n" +
+	"\tclient_ip\x18\x03 \x01(\tR\bclientIp\x123\n" +
+	"\tmeta_data\x18\x04 \x01(\v2\x16.google.protobuf.ValueR\bmetaData\x12:\n" +
+	"\fnetwork_data\x18\x05 \x01(\v2\x17.google.protobuf.StructR\vnetworkData\x12 \n" +
+	"\tuser_data\x18\x06 \x01(\fH\x00R\buserData\x88\x01\x01\x128\n" +
+	"\vvendor_data\x18\a \x01(\v2\x17.google.protobuf.StructR\n" +
+	"vendorData\x12:\n" +
+	"\fvendor_data2\x18\b \x01(\v2\x17.google.protobuf.StructR\vvendorData2\x12D\n" +
+	"\x03ec2\x18\t \x03(\v22.ironic_metadata.admin.v1.PreviewResponse.Ec2EntryR\x03ec2\x12Y\n" +
+	"\n" +
+	"violations\x18\n" +
+	" \x03(\v29.ironic_metadata.admin.v1.PreviewResponse.ViolationsEntryR\n" +
+	"violations\x12M\n" +
+	"\x06errors\x18\v \x03(\v25.ironic_metadata.admin.v1.PreviewResponse.ErrorsEntryR\x06errors\x1aN\n" +
+	"\bEc2Entry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x05value:\x028\x01\x1ac\n" +
+	"\x0fViolationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12:\n" +
+	"\x05value\x18\x02 \x01(\v2$.ironic_metadata.admin.v1.ViolationsR\x05value:\x028\x01\x1a9\n" +
+	"\vErrorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\f\n" +
+	"\n" +
+	"_user_data\",\n" +
+	"\n" +
+	"Violations\x12\x1e\n" +
+	"\n" +
+	"violations\x18\x01 \x03(\tR\n" +
+	"violations\"\x16\n" +
+	"\x14GetCacheStatsRequest\"U\n" +
+	"\x15GetCacheStatsResponse\x12<\n" +
+	"\x06caches\x18\x01 \x03(\v2$.ironic_metadata.admin.v1.CacheStatsR\x06caches\"\xce\x01\n" +
+	"\n" +
+	"CacheStats\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aentries\x18\x02 \x01(\x03R\aentries\x12\x12\n" +
+	"\x04hits\x18\x03 \x01(\x04R\x04hits\x12\x16\n" +
+	"\x06misses\x18\x04 \x01(\x04R\x06misses\x12\x1c\n" +
+	"\tevictions\x18\x05 \x01(\x04R\tevictions\x12\x14\n" +
+	"\x05bytes\x18\x06 \x01(\x03R\x05bytes\x122\n" +
+	"\x06oldest\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x06oldest\"\x15\n" +
+	"\x13RefreshCacheRequest\"M\n" +
+	"\x14RefreshCacheResponse\x125\n" +
+	"\bduration\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\bduration\"+\n" +
+	"\x15InvalidateNodeRequest\x12\x12\n" +
+	"\x04node\x18\x01 \x01(\tR\x04node\"5\n" +
+	"\x16InvalidateNodeResponse\x12\x1b\n" +
+	"\tnode_uuid\x18\x01 \x01(\tR\bnodeUuid\"*\n" +
+	"\x12WatchEventsRequest\x12\x14\n" +
+	"\x05types\x18\x01 \x03(\tR\x05types\"L\n" +
+	"\x13WatchEventsResponse\x125\n" +
+	"\x05event\x18\x01 \x01(\v2\x1f.ironic_metadata.admin.v1.EventR\x05event\"\xf0\x01\n" +
+	"\x05Event\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x1b\n" +
+	"\tnode_uuid\x18\x04 \x01(\tR\bnodeUuid\x12\x1b\n" +
+	"\tnode_name\x18\x05 \x01(\tR\bnodeName\x12\x1a\n" +
+	"\bendpoint\x18\x06 \x01(\tR\bendpoint\x12\x1b\n" +
+	"\tclient_ip\x18\a \x01(\tR\bclientIp\x12\x16\n" +
+	"\x06status\x18\b \x01(\x05R\x06status2\x8f\x05\n" +
+	"\fAdminService\x12[\n" +
+	"\x06Lookup\x12'.ironic_metadata.admin.v1.LookupRequest\x1a(.ironic_metadata.admin.v1.LookupResponse\x12^\n" +
+	"\aPreview\x12(.ironic_metadata.admin.v1.PreviewRequest\x1a).ironic_metadata.admin.v1.PreviewResponse\x12p\n" +
+	"\rGetCacheStats\x12..ironic_metadata.admin.v1.GetCacheStatsRequest\x1a/.ironic_metadata.admin.v1.GetCacheStatsResponse\x12m\n" +
+	"\fRefreshCache\x12-.ironic_metadata.admin.v1.RefreshCacheRequest\x1a..ironic_metadata.admin.v1.RefreshCacheResponse\x12s\n" +
+	"\x0eInvalidateNode\x12/.ironic_metadata.admin.v1.InvalidateNodeRequest\x1a0.ironic_metadata.admin.v1.InvalidateNodeResponse\x12l\n" +
+	"\vWatchEvents\x12,.ironic_metadata.admin.v1.WatchEventsRequest\x1a-.ironic_metadata.admin.v1.WatchEventsResponse0\x01B=Z;github.com/appkins-org/ironic-metadata/api/admin/v1;adminv1b\x06proto3"
+
+var (
+	file_admin_v1_admin_proto_rawDescOnce sync.Once
+	file_admin_v1_admin_proto_rawDescData []byte
+)
+
+func file_admin_v1_admin_proto_rawDescGZIP() []byte {
+	file_admin_v1_admin_proto_rawDescOnce.Do(func() {
+		file_admin_v1_admin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_admin_v1_admin_proto_rawDesc), len(file_admin_v1_admin_proto_rawDesc)))
+	})
+	return file_admin_v1_admin_proto_rawDescData
+}
+
+var file_admin_v1_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_admin_v1_admin_proto_goTypes = []any{
+	(*LookupRequest)(nil),          // 0: ironic_metadata.admin.v1.LookupRequest
+	(*LookupResponse)(nil),         // 1: ironic_metadata.admin.v1.LookupResponse
+	(*ResolverStep)(nil),           // 2: ironic_metadata.admin.v1.ResolverStep
+	(*Node)(nil),                   // 3: ironic_metadata.admin.v1.Node
+	(*PreviewRequest)(nil),         // 4: ironic_metadata.admin.v1.PreviewRequest
+	(*PreviewResponse)(nil),        // 5: ironic_metadata.admin.v1.PreviewResponse
+	(*Violations)(nil),             // 6: ironic_metadata.admin.v1.Violations
+	(*GetCacheStatsRequest)(nil),   // 7: ironic_metadata.admin.v1.GetCacheStatsRequest
+	(*GetCacheStatsResponse)(nil),  // 8: ironic_metadata.admin.v1.GetCacheStatsResponse
+	(*CacheStats)(nil),             // 9: ironic_metadata.admin.v1.CacheStats
+	(*RefreshCacheRequest)(nil),    // 10: ironic_metadata.admin.v1.RefreshCacheRequest
+	(*RefreshCacheResponse)(nil),   // 11: ironic_metadata.admin.v1.RefreshCacheResponse
+	(*InvalidateNodeRequest)(nil),  // 12: ironic_metadata.admin.v1.InvalidateNodeRequest
+	(*InvalidateNodeResponse)(nil), // 13: ironic_metadata.admin.v1.InvalidateNodeResponse
+	(*WatchEventsRequest)(nil),     // 14: ironic_metadata.admin.v1.WatchEventsRequest
+	(*WatchEventsResponse)(nil),    // 15: ironic_metadata.admin.v1.WatchEventsResponse
+	(*Event)(nil),                  // 16: ironic_metadata.admin.v1.Event
+	nil,                            // 17: ironic_metadata.admin.v1.PreviewResponse.Ec2Entry
+	nil,                            // 18: ironic_metadata.admin.v1.PreviewResponse.ViolationsEntry
+	nil,                            // 19: ironic_metadata.admin.v1.PreviewResponse.ErrorsEntry
+	(*durationpb.Duration)(nil),    // 20: google.protobuf.Duration
+	(*structpb.Value)(nil),         // 21: google.protobuf.Value
+	(*structpb.Struct)(nil),        // 22: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil),  // 23: google.protobuf.Timestamp
+}
+var file_admin_v1_admin_proto_depIdxs = []int32{
+	2,  // 0: ironic_metadata.admin.v1.LookupResponse.resolvers:type_name -> ironic_metadata.admin.v1.ResolverStep
+	3,  // 1: ironic_metadata.admin.v1.LookupResponse.node:type_name -> ironic_metadata.admin.v1.Node
+	20, // 2: ironic_metadata.admin.v1.ResolverStep.duration:type_name -> google.protobuf.Duration
+	21, // 3: ironic_metadata.admin.v1.PreviewResponse.meta_data:type_name -> google.protobuf.Value
+	22, // 4: ironic_metadata.admin.v1.PreviewResponse.network_data:type_name -> google.protobuf.Struct
+	22, // 5: ironic_metadata.admin.v1.PreviewResponse.vendor_data:type_name -> google.protobuf.Struct
+	22, // 6: ironic_metadata.admin.v1.PreviewResponse.vendor_data2:type_name -> google.protobuf.Struct
+	17, // 7: ironic_metadata.admin.v1.PreviewResponse.ec2:type_name -> ironic_metadata.admin.v1.PreviewResponse.Ec2Entry
+	18, // 8: ironic_metadata.admin.v1.PreviewResponse.violations:type_name -> ironic_metadata.admin.v1.PreviewResponse.ViolationsEntry
+	19, // 9: ironic_metadata.admin.v1.PreviewResponse.errors:type_name -> ironic_metadata.admin.v1.PreviewResponse.ErrorsEntry
+	9,  // 10: ironic_metadata.admin.v1.GetCacheStatsResponse.caches:type_name -> ironic_metadata.admin.v1.CacheStats
+	23, // 11: ironic_metadata.admin.v1.CacheStats.oldest:type_name -> google.protobuf.Timestamp
+	20, // 12: ironic_metadata.admin.v1.RefreshCacheResponse.duration:type_name -> google.protobuf.Duration
+	16, // 13: ironic_metadata.admin.v1.WatchEventsResponse.event:type_name -> ironic_metadata.admin.v1.Event
+	23, // 14: ironic_metadata.admin.v1.Event.timestamp:type_name -> google.protobuf.Timestamp
+	21, // 15: ironic_metadata.admin.v1.PreviewResponse.Ec2Entry.value:type_name -> google.protobuf.Value
+	6,  // 16: ironic_metadata.admin.v1.PreviewResponse.ViolationsEntry.value:type_name -> ironic_metadata.admin.v1.Violations
+	0,  // 17: ironic_metadata.admin.v1.AdminService.Lookup:input_type -> ironic_metadata.admin.v1.LookupRequest
+	4,  // 18: ironic_metadata.admin.v1.AdminService.Preview:input_type -> ironic_metadata.admin.v1.PreviewRequest
+	7,  // 19: ironic_metadata.admin.v1.AdminService.GetCacheStats:input_type -> ironic_metadata.admin.v1.GetCacheStatsRequest
+	10, // 20: ironic_metadata.admin.v1.AdminService.RefreshCache:input_type -> ironic_metadata.admin.v1.RefreshCacheRequest
+	12, // 21: ironic_metadata.admin.v1.AdminService.InvalidateNode:input_type -> ironic_metadata.admin.v1.InvalidateNodeRequest
+	14, // 22: ironic_metadata.admin.v1.AdminService.WatchEvents:input_type -> ironic_metadata.admin.v1.WatchEventsRequest
+	1,  // 23: ironic_metadata.admin.v1.AdminService.Lookup:output_type -> ironic_metadata.admin.v1.LookupResponse
+	5,  // 24: ironic_metadata.admin.v1.AdminService.Preview:output_type -> ironic_metadata.admin.v1.PreviewResponse
+	8,  // 25: ironic_metadata.admin.v1.AdminService.GetCacheStats:output_type -> ironic_metadata.admin.v1.GetCacheStatsResponse
+	11, // 26: ironic_metadata.admin.v1.AdminService.RefreshCache:output_type -> ironic_metadata.admin.v1.RefreshCacheResponse
+	13, // 27: ironic_metadata.admin.v1.AdminService.InvalidateNode:output_type -> ironic_metadata.admin.v1.InvalidateNodeResponse
+	15, // 28: ironic_metadata.admin.v1.AdminService.WatchEvents:output_type -> ironic_metadata.admin.v1.WatchEventsResponse
+	23, // [23:29] is the sub-list for method output_type
+	17, // [17:23] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_admin_v1_admin_proto_init() }
+func file_admin_v1_admin_proto_init() {
+	if File_admin_v1_admin_proto != nil {
+		return
+	}
+	file_admin_v1_admin_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_admin_v1_admin_proto_rawDesc), len(file_admin_v1_admin_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_admin_v1_admin_proto_goTypes,
+		DependencyIndexes: file_admin_v1_admin_proto_depIdxs,
+		MessageInfos:      file_admin_v1_admin_proto_msgTypes,
+	}.Build()
+	File_admin_v1_admin_proto = out.File
+	file_admin_v1_admin_proto_goTypes = nil
+	file_admin_v1_admin_proto_depIdxs = nil
+}
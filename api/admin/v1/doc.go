@@ -0,0 +1,4 @@
+// Package adminv1 is the gRPC admin API of the service, generated from
+// admin.proto by running make proto, which needs buf, protoc-gen-go and
+// protoc-gen-go-grpc.
+package adminv1
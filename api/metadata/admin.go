@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// adminMiddleware requires the admin token as a bearer token.
+func (h *Handler) adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.hasAdminToken(r) {
+			h.logger().Warn().
+				Str("path", r.URL.Path).
+				Str("remote_addr", r.RemoteAddr).
+				Msg("Rejected unauthenticated admin request")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasAdminToken reports whether a request carries the admin token as a
+// bearer token.
+func (h *Handler) hasAdminToken(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) == 1
+}
+
+// adminNode fetches the node named by the uuid route variable, which may be
+// a node UUID or name. When the node cannot be fetched an error response is
+// written and ok is false.
+func (h *Handler) adminNode(w http.ResponseWriter, r *http.Request) (*nodes.Node, bool) {
+	return h.fetchNode(w, r, mux.Vars(r)["uuid"])
+}
+
+// fetchNode fetches a node by UUID or name. When the node cannot be fetched
+// an error response is written and ok is false.
+func (h *Handler) fetchNode(
+	w http.ResponseWriter,
+	r *http.Request,
+	nodeID string,
+) (*nodes.Node, bool) {
+	node, err := h.nodeSource().GetNode(r.Context(), nodeID)
+	if err != nil {
+		if gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+			http.Error(w, "Node not found", http.StatusNotFound)
+			return nil, false
+		}
+		h.logger().Error().
+			Err(err).
+			Str("node", nodeID).
+			Msg("Failed to get node from Ironic")
+		http.Error(w, "Failed to get node", http.StatusBadGateway)
+		return nil, false
+	}
+	return node, true
+}
@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	h := &Handler{AdminToken: "secret"}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "missing", want: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "wrong scheme", authorization: "Basic secret", want: http.StatusUnauthorized},
+		{name: "valid", authorization: "Bearer secret", want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/nodes/test-uuid/seed.iso", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+
+			h.adminMiddleware(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAdminRoutesDisabled(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/nodes/test-uuid/seed.iso", nil)
+	req.Header.Set("Authorization", "Bearer ")
+
+	createTestHandler().Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected admin routes to be disabled without a token, got %d", rr.Code)
+	}
+}
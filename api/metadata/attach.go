@@ -0,0 +1,308 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/configdrive"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/objectstorage/v1/containers"
+	"github.com/gophercloud/gophercloud/v2/openstack/objectstorage/v1/objects"
+)
+
+// Configdrive attachment targets.
+const (
+	// ConfigDriveTargetInstanceInfo stores the encoded configdrive in the
+	// node's instance_info.
+	ConfigDriveTargetInstanceInfo = "instance_info"
+
+	// ConfigDriveTargetSwift uploads the encoded configdrive to object
+	// storage and stores a temp URL in the node's instance_info.
+	ConfigDriveTargetSwift = "swift"
+)
+
+// Defaults for configdrives uploaded to object storage, matching Ironic's
+// configdrive_swift_container and configdrive_swift_temp_url_duration.
+const (
+	defaultConfigDriveContainer = "ironic_configdrive_container"
+	defaultConfigDriveURLTTL    = time.Hour
+)
+
+// AttachConfigDriveOpts configures where AttachConfigDrive stores a
+// configdrive.
+type AttachConfigDriveOpts struct {
+	// Target is one of ConfigDriveTargetInstanceInfo, the default, or
+	// ConfigDriveTargetSwift.
+	Target string
+
+	// Container is the object storage container for the swift target.
+	Container string
+
+	// TempURLTTL is the lifetime of the temp URL for the swift target. It
+	// has to outlast the deployment.
+	TempURLTTL time.Duration
+}
+
+// AttachConfigDriveResult describes an attached configdrive.
+type AttachConfigDriveResult struct {
+	NodeUUID string `json:"node_uuid"`
+	Target   string `json:"target"`
+	Size     int    `json:"size"`
+	Object   string `json:"object,omitempty"`
+}
+
+// BuildConfigDrive renders a node's metadata into a configdrive image, so
+// the documents served over the network can also be attached to the node.
+func (h *Handler) BuildConfigDrive(node *nodes.Node) ([]byte, error) {
+	cd := &configdrive.ConfigDrive{Content: make(map[string][]byte)}
+
+	metaData, err := h.metaDataDocument(node, h.buildMetaData(node))
+	if err != nil {
+		return nil, err
+	}
+	if cd.MetaData, err = json.Marshal(metaData); err != nil {
+		return nil, fmt.Errorf("failed to marshal meta_data.json: %w", err)
+	}
+	if cd.NetworkData, err = json.Marshal(h.buildNetworkData(node)); err != nil {
+		return nil, fmt.Errorf("failed to marshal network_data.json: %w", err)
+	}
+	vendorData, err := h.buildVendorData(node)
+	if err != nil {
+		return nil, err
+	}
+	if cd.VendorData, err = json.Marshal(vendorData); err != nil {
+		return nil, fmt.Errorf("failed to marshal vendor_data.json: %w", err)
+	}
+	vendorData2, err := h.buildVendorData2(context.Background(), node)
+	if err != nil {
+		return nil, err
+	}
+	if cd.VendorData2, err = json.Marshal(vendorData2); err != nil {
+		return nil, fmt.Errorf("failed to marshal vendor_data2.json: %w", err)
+	}
+
+	userData, err := h.renderUserData(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render user_data: %w", err)
+	}
+	if len(userData) > 0 {
+		cd.UserData = userData
+	}
+
+	for _, file := range h.injectedFiles(node) {
+		contents := file.contents
+		if contents == nil {
+			if contents, err = h.readContent(node, file.id); err != nil {
+				return nil, fmt.Errorf("failed to read injected file %s: %w", file.path, err)
+			}
+		}
+		cd.Content[file.id] = contents
+	}
+
+	return configdrive.Build(cd)
+}
+
+// AttachConfigDrive builds a node's configdrive and stores it in the node's
+// instance_info, either inline or as a temp URL to an object storage copy.
+func (h *Handler) AttachConfigDrive(
+	ctx context.Context,
+	node *nodes.Node,
+	opts AttachConfigDriveOpts,
+) (*AttachConfigDriveResult, error) {
+	if opts.Target == "" {
+		opts.Target = ConfigDriveTargetInstanceInfo
+	}
+	if opts.Target != ConfigDriveTargetInstanceInfo && opts.Target != ConfigDriveTargetSwift {
+		return nil, fmt.Errorf("unknown configdrive target %q", opts.Target)
+	}
+	if err := h.checkLeader(); err != nil {
+		return nil, err
+	}
+
+	image, err := h.BuildConfigDrive(node)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := configdrive.Encode(image)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AttachConfigDriveResult{
+		NodeUUID: node.UUID,
+		Target:   opts.Target,
+		Size:     len(encoded),
+	}
+
+	value := encoded
+	if opts.Target == ConfigDriveTargetSwift {
+		if value, result.Object, err = h.uploadConfigDrive(ctx, node, encoded, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	err = h.updateNode(ctx, node, nodes.UpdateOperation{
+		Op:    nodes.AddOp,
+		Path:  "/instance_info/configdrive",
+		Value: value,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.InvalidateConfigDrive(node.UUID)
+
+	h.logger().Info().
+		Str("node_uuid", node.UUID).
+		Str("target", result.Target).
+		Int("size", result.Size).
+		Msg("Attached configdrive to node")
+
+	return result, nil
+}
+
+// swiftClient returns the object storage client of the Ironic clients.
+func (h *Handler) swiftClient() (*gophercloud.ServiceClient, error) {
+	if h.Clients == nil {
+		return nil, errors.New("no object storage client configured")
+	}
+	return h.Clients.SwiftClient()
+}
+
+// uploadConfigDrive stores an encoded configdrive in object storage and
+// returns a temp URL Ironic can download it from, along with the object's
+// swift://<container>/<object> reference.
+func (h *Handler) uploadConfigDrive(
+	ctx context.Context,
+	node *nodes.Node,
+	encoded string,
+	opts AttachConfigDriveOpts,
+) (string, string, error) {
+	swift, err := h.swiftClient()
+	if err != nil {
+		return "", "", err
+	}
+
+	container := opts.Container
+	if container == "" {
+		container = defaultConfigDriveContainer
+	}
+	ttl := opts.TempURLTTL
+	if ttl <= 0 {
+		ttl = defaultConfigDriveURLTTL
+	}
+	object := "configdrive-" + node.UUID
+
+	if err := containers.Create(ctx, swift, container, nil).Err; err != nil {
+		return "", "", fmt.Errorf("failed to create container %s: %w", container, err)
+	}
+	err = objects.Create(ctx, swift, container, object, objects.CreateOpts{
+		Content:     strings.NewReader(encoded),
+		ContentType: "text/plain",
+	}).Err
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload configdrive to %s/%s: %w", container, object, err)
+	}
+
+	tempURL, err := objects.CreateTempURL(ctx, swift, container, object, objects.CreateTempURLOpts{
+		Method:     http.MethodGet,
+		TTL:        int(ttl.Seconds()),
+		TempURLKey: h.SwiftTempURLKey,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp URL for %s/%s: %w", container, object, err)
+	}
+	return tempURL, "swift://" + container + "/" + object, nil
+}
+
+// handleConfigDriveImage handles GET requests to
+// /admin/nodes/{uuid}/configdrive, returning the configdrive image that
+// would be attached to a node.
+func (h *Handler) handleConfigDriveImage(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+
+	image, err := h.BuildConfigDrive(node)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to build configdrive")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-iso9660-image")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", node.UUID+"-configdrive.iso"))
+	if _, err := w.Write(image); err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to write configdrive response")
+	}
+}
+
+// handleConfigDriveAttach handles POST requests to
+// /admin/nodes/{uuid}/configdrive, building a node's configdrive and storing
+// it in instance_info. The target, container and ttl query parameters map
+// to AttachConfigDriveOpts.
+func (h *Handler) handleConfigDriveAttach(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := AttachConfigDriveOpts{
+		Target:    query.Get("target"),
+		Container: query.Get("container"),
+	}
+	if ttl := query.Get("ttl"); ttl != "" {
+		var err error
+		if opts.TempURLTTL, err = time.ParseDuration(ttl); err != nil {
+			http.Error(w, "Invalid ttl", http.StatusBadRequest)
+			return
+		}
+	}
+	if opts.Target != "" && opts.Target != ConfigDriveTargetInstanceInfo &&
+		opts.Target != ConfigDriveTargetSwift {
+		http.Error(w, "Unknown target", http.StatusBadRequest)
+		return
+	}
+	if opts.Target == ConfigDriveTargetSwift {
+		if _, err := h.swiftClient(); err != nil {
+			http.Error(w, "Object storage unavailable", http.StatusConflict)
+			return
+		}
+	}
+
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.AttachConfigDrive(r.Context(), node, opts)
+	if errors.Is(err, ErrNotLeader) {
+		writeNotLeader(w)
+		return
+	}
+	if errors.Is(err, ErrNoNodeWriter) {
+		writeNoNodeWriter(w)
+		return
+	}
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Str("target", opts.Target).
+			Msg("Failed to attach configdrive")
+		http.Error(w, "Failed to attach configdrive", http.StatusBadGateway)
+		return
+	}
+
+	h.writeJSONResponse(w, result)
+}
@@ -0,0 +1,197 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/configdrive"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestBuildConfigDrive(t *testing.T) {
+	h := createTestHandler()
+	node := &nodes.Node{
+		UUID: "test-uuid",
+		Name: "test-node",
+		InstanceInfo: map[string]any{
+			"user_data": "#cloud-config\n",
+			"files": []any{
+				map[string]any{"path": "/etc/motd", "contents": "aGVsbG8K"},
+			},
+		},
+	}
+
+	image, err := h.BuildConfigDrive(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cd, err := configdrive.Parse(image)
+	if err != nil {
+		t.Fatalf("failed to parse configdrive: %v", err)
+	}
+	data, err := newConfigDriveData(cd)
+	if err != nil {
+		t.Fatalf("failed to decode configdrive: %v", err)
+	}
+
+	if have, want := data.MetaData.UUID, "test-uuid"; have != want {
+		t.Errorf("uuid: have %q, want %q", have, want)
+	}
+	if have, want := data.UserData, "#cloud-config\n"; have != want {
+		t.Errorf("user_data: have %q, want %q", have, want)
+	}
+	if len(data.MetaData.Files) != 1 || data.MetaData.Files[0].ContentPath != "/content/0000" {
+		t.Fatalf("unexpected files: %+v", data.MetaData.Files)
+	}
+	if have, want := string(cd.Content["0000"]), "hello\n"; have != want {
+		t.Errorf("content 0000: have %q, want %q", have, want)
+	}
+}
+
+func TestBuildConfigDrive_redactKeys(t *testing.T) {
+	h := NewHandler(WithRedactKeys("*password*", "ipmi_*"))
+	node := &nodes.Node{
+		UUID: "test-uuid",
+		Properties: map[string]any{
+			"cpu_arch":      "x86_64",
+			"ipmi_address":  "10.1.0.5",
+			"root_password": "hunter2",
+		},
+		InstanceInfo: map[string]any{
+			"meta_data": map[string]any{"bmc_password": "hunter2"},
+		},
+	}
+
+	image, err := h.BuildConfigDrive(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cd, err := configdrive.Parse(image)
+	if err != nil {
+		t.Fatalf("failed to parse configdrive: %v", err)
+	}
+	data, err := newConfigDriveData(cd)
+	if err != nil {
+		t.Fatalf("failed to decode configdrive: %v", err)
+	}
+
+	if have := data.MetaData.Meta["cpu_arch"]; have != "x86_64" {
+		t.Errorf("cpu_arch: have %q, want x86_64", have)
+	}
+	for _, secret := range []string{"hunter2", "10.1.0.5"} {
+		if strings.Contains(string(cd.MetaData), secret) {
+			t.Errorf("have %q in meta_data.json, want it redacted: %s", secret, cd.MetaData)
+		}
+	}
+}
+
+func TestAttachConfigDrive(t *testing.T) {
+	var patch []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/nodes/test-uuid" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"uuid": "test-uuid"}`))
+	}))
+	defer srv.Close()
+
+	h := createTestHandler()
+	h.Clients.SetIronicClient(&gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       srv.URL + "/",
+	})
+	node := &nodes.Node{UUID: "test-uuid", Name: "test-node"}
+
+	if _, err := h.AttachConfigDrive(t.Context(), node, AttachConfigDriveOpts{
+		Target: "floppy",
+	}); err == nil {
+		t.Error("expected error for unknown target")
+	}
+	if _, err := h.AttachConfigDrive(t.Context(), node, AttachConfigDriveOpts{
+		Target: ConfigDriveTargetSwift,
+	}); err == nil {
+		t.Error("expected error without an object storage client")
+	}
+
+	result, err := h.AttachConfigDrive(t.Context(), node, AttachConfigDriveOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Target != ConfigDriveTargetInstanceInfo {
+		t.Errorf("target: have %q, want %q", result.Target, ConfigDriveTargetInstanceInfo)
+	}
+
+	if len(patch) != 1 || patch[0]["path"] != "/instance_info/configdrive" {
+		t.Fatalf("unexpected patch: %v", patch)
+	}
+	value, ok := patch[0]["value"].(string)
+	if !ok || len(value) != result.Size {
+		t.Fatalf("unexpected configdrive value of %d bytes", len(value))
+	}
+	if strings.HasPrefix(value, "http") {
+		t.Error("expected an inline configdrive")
+	}
+	if _, err := configdrive.Parse([]byte(value)); err != nil {
+		t.Errorf("attached configdrive does not parse: %v", err)
+	}
+}
+
+func TestHandler_configDriveAttach_nodeWriter(t *testing.T) {
+	node := nodes.Node{UUID: "node-1", Name: "web01", ProvisionState: "active"}
+	tests := []struct {
+		name       string
+		source     func(*mock.NodeSource) client.NodeSource
+		wantStatus int
+	}{
+		{
+			name:       "writable source",
+			source:     func(s *mock.NodeSource) client.NodeSource { return s },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "read-only source",
+			source: func(s *mock.NodeSource) client.NodeSource {
+				return client.NewCachedSource(s, time.Minute)
+			},
+			wantStatus: http.StatusNotImplemented,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := mock.NewNodeSource(node)
+			h := NewHandler(WithNodeSource(tt.source(source)), WithAdminToken("secret"))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/nodes/node-1/configdrive", nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			stored, err := source.GetNode(t.Context(), "node-1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, attached := stored.InstanceInfo["configdrive"]
+			if attached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("have instance_info %v, want the configdrive attached: %v",
+					stored.InstanceInfo, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
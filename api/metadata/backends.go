@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+)
+
+// Backend is a provisioning domain served by its own Handler, backed by its
+// own Ironic, for the clients of its networks.
+type Backend struct {
+	// Name identifies the backend in logs.
+	Name string
+
+	// Prefixes are the client networks the backend serves.
+	Prefixes []netip.Prefix
+
+	// Handler serves the requests of the clients of Prefixes.
+	Handler *Handler
+}
+
+// backendRoute is a client network and the routes of the backend serving
+// it.
+type backendRoute struct {
+	prefix netip.Prefix
+	name   string
+	routes http.Handler
+}
+
+// BackendRouter routes each request to the backend serving the network of
+// its client IP, so one metadata address serves several isolated
+// provisioning domains without the nodes of one being resolved for the
+// clients of another. The most specific network matching the client wins.
+type BackendRouter struct {
+	fallback *Handler
+	routes   http.Handler
+	backends []backendRoute
+}
+
+// NewBackendRouter returns a BackendRouter serving clients outside the
+// networks of every backend with fallback. A network can only be served by
+// a single backend.
+func NewBackendRouter(fallback *Handler, backends ...Backend) (*BackendRouter, error) {
+	if fallback == nil {
+		return nil, fmt.Errorf("a fallback handler is required")
+	}
+	router := &BackendRouter{fallback: fallback, routes: fallback.Routes()}
+	owners := make(map[netip.Prefix]string)
+	for _, backend := range backends {
+		if backend.Handler == nil {
+			return nil, fmt.Errorf("backend %q has no handler", backend.Name)
+		}
+		routes := backend.Handler.Routes()
+		for _, prefix := range backend.Prefixes {
+			prefix = prefix.Masked()
+			if owner, ok := owners[prefix]; ok {
+				return nil, fmt.Errorf("network %s is served by both backend %q and %q",
+					prefix, owner, backend.Name)
+			}
+			owners[prefix] = backend.Name
+			router.backends = append(router.backends,
+				backendRoute{prefix: prefix, name: backend.Name, routes: routes})
+		}
+	}
+	return router, nil
+}
+
+// ServeHTTP serves a request with the backend of its client IP. Forwarding
+// headers only select the backend when the request comes from one of the
+// TrustedProxies of the fallback handler, so a client cannot reach the
+// backend of another network by forging them.
+func (b *BackendRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routes, name := b.route(b.fallback.getClientIP(r))
+	if name != "" {
+		b.fallback.logger().Debug().
+			Str("backend", name).
+			Str("request_path", r.URL.Path).
+			Msg("Routing request to backend")
+	}
+	routes.ServeHTTP(w, r)
+}
+
+// route returns the routes serving a client IP and the name of their
+// backend, or the routes of the fallback handler and an empty name.
+func (b *BackendRouter) route(clientIP string) (http.Handler, string) {
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return b.routes, ""
+	}
+	addr = addr.Unmap()
+
+	best := -1
+	for i, backend := range b.backends {
+		if backend.prefix.Contains(addr) &&
+			(best < 0 || backend.prefix.Bits() > b.backends[best].prefix.Bits()) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return b.routes, ""
+	}
+	return b.backends[best].routes, b.backends[best].name
+}
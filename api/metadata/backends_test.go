@@ -0,0 +1,202 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// backendHandler returns a handler serving a node at each client IP, behind
+// a trusted proxy at 192.168.0.1.
+func backendHandler(nodesByIP map[string]nodes.Node) *Handler {
+	var all []nodes.Node
+	resolver := &mock.Resolver{Nodes: make(map[string]*nodes.Node)}
+	for ip, node := range nodesByIP {
+		all = append(all, node)
+		resolver.Nodes[ip] = &node
+	}
+	return NewHandler(
+		WithNodeSource(mock.NewNodeSource(all...)),
+		WithResolvers(resolver),
+		WithTrustedProxies(netip.MustParsePrefix("192.168.0.1/32")),
+	)
+}
+
+func TestBackendRouter(t *testing.T) {
+	fallback := backendHandler(map[string]nodes.Node{
+		"192.168.0.5": {UUID: "default-1", Name: "default"},
+		"10.1.0.5":    {UUID: "default-2", Name: "leaked"},
+	})
+	rackA := backendHandler(map[string]nodes.Node{"10.1.0.5": {UUID: "rack-a-1", Name: "a"}})
+	rackB := backendHandler(map[string]nodes.Node{"10.1.2.5": {UUID: "rack-b-1", Name: "b"}})
+
+	router, err := NewBackendRouter(fallback,
+		Backend{
+			Name:     "rack-a",
+			Prefixes: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+			Handler:  rackA,
+		},
+		Backend{
+			Name:     "rack-b",
+			Prefixes: []netip.Prefix{netip.MustParsePrefix("10.1.2.0/24")},
+			Handler:  rackB,
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+		wantStatus int
+	}{
+		{name: "backend", remoteAddr: "10.1.0.5:4321", want: "rack-a-1"},
+		{name: "most specific network", remoteAddr: "10.1.2.5:4321", want: "rack-b-1"},
+		{name: "fallback", remoteAddr: "192.168.0.5:4321", want: "default-1"},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "192.168.0.1:4321",
+			forwarded:  "10.1.0.5",
+			want:       "rack-a-1",
+		},
+		{
+			name:       "forged forwarding header",
+			remoteAddr: "192.168.0.5:4321",
+			forwarded:  "10.1.0.5",
+			want:       "default-1",
+		},
+		{
+			name:       "node of another backend",
+			remoteAddr: "10.1.3.5:4321",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if tt.wantStatus != 0 {
+				if rr.Code != tt.wantStatus {
+					t.Errorf("have status %d, want %d", rr.Code, tt.wantStatus)
+				}
+				return
+			}
+			var have struct {
+				UUID string `json:"uuid"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v: %s", err, rr.Body)
+			}
+			if have.UUID != tt.want {
+				t.Errorf("have node %q, want %q", have.UUID, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBackendRouter_errors(t *testing.T) {
+	prefix := netip.MustParsePrefix("10.1.0.0/16")
+
+	tests := []struct {
+		name     string
+		fallback *Handler
+		backends []Backend
+	}{
+		{name: "no fallback"},
+		{
+			name:     "no handler",
+			fallback: NewHandler(),
+			backends: []Backend{{Name: "rack-a", Prefixes: []netip.Prefix{prefix}}},
+		},
+		{
+			name:     "network served twice",
+			fallback: NewHandler(),
+			backends: []Backend{
+				{Name: "rack-a", Prefixes: []netip.Prefix{prefix}, Handler: NewHandler()},
+				{
+					Name:     "rack-b",
+					Prefixes: []netip.Prefix{netip.MustParsePrefix("10.1.2.3/16")},
+					Handler:  NewHandler(),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBackendRouter(tt.fallback, tt.backends...); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestHandler_Clone(t *testing.T) {
+	h := &Handler{}
+	// Set every exported field, so fields added without being cloned fail
+	// the test.
+	v := reflect.ValueOf(h).Elem()
+	for i := range v.NumField() {
+		field := v.Field(i)
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.String:
+			field.SetString("set")
+		case reflect.Int64:
+			field.SetInt(1)
+		case reflect.Struct:
+			// Limits, whose fields are all integers.
+			for j := range field.NumField() {
+				field.Field(j).SetInt(1)
+			}
+		case reflect.Map:
+			field.Set(reflect.MakeMap(field.Type()))
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+		case reflect.Pointer:
+			field.Set(reflect.New(field.Type().Elem()))
+		case reflect.Interface:
+			field.Set(reflect.ValueOf(mock.NewNodeSource()))
+		default:
+			t.Fatalf("unhandled kind %s of field %s", field.Kind(), v.Type().Field(i).Name)
+		}
+	}
+
+	clone := h.Clone(WithRegion("rack-a"))
+	cv := reflect.ValueOf(clone).Elem()
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Name
+		if !v.Type().Field(i).IsExported() || name == "Region" {
+			continue
+		}
+		if !reflect.DeepEqual(cv.Field(i).Interface(), v.Field(i).Interface()) {
+			t.Errorf("field %s not cloned", name)
+		}
+	}
+	if clone.Region != "rack-a" {
+		t.Errorf("have region %q, want the option applied", clone.Region)
+	}
+	if clone.Clients != h.Clients || clone.Nodes != h.Nodes {
+		t.Error("expected the clients and node source to be shared")
+	}
+}
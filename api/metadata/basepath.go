@@ -0,0 +1,57 @@
+package metadata
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// cleanBasePath normalizes a base path to a leading slash and no trailing
+// slash, returning the empty string for the root.
+func cleanBasePath(base string) string {
+	base = strings.TrimSpace(base)
+	if base == "" {
+		return ""
+	}
+	base = path.Clean("/" + base)
+	if base == "/" {
+		return ""
+	}
+	return base
+}
+
+// stripBasePath serves next under base, which is removed from request paths
+// before routing. Requests outside base are served unchanged, so the service
+// keeps answering at the root behind proxies that rewrite base away.
+func stripBasePath(base string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := trimBasePath(r.URL.Path, base)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		// RawPath is only used while it encodes Path.
+		r2.URL.RawPath, _ = trimBasePath(r.URL.RawPath, base)
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// trimBasePath removes base from the start of a path, reporting whether
+// the path is base or below it.
+func trimBasePath(p, base string) (string, bool) {
+	rest, ok := strings.CutPrefix(p, base)
+	if !ok || (rest != "" && rest[0] != '/') {
+		return p, false
+	}
+	if rest == "" {
+		return "/", true
+	}
+	return rest, true
+}
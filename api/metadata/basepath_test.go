@@ -0,0 +1,68 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCleanBasePath(t *testing.T) {
+	tests := []struct {
+		have string
+		want string
+	}{
+		{have: "", want: ""},
+		{have: "/", want: ""},
+		{have: "metadata", want: "/metadata"},
+		{have: "/metadata/", want: "/metadata"},
+		{have: "/provisioning//metadata", want: "/provisioning/metadata"},
+	}
+	for _, tt := range tests {
+		if have := cleanBasePath(tt.have); have != tt.want {
+			t.Errorf("cleanBasePath(%q): have %q, want %q", tt.have, have, tt.want)
+		}
+	}
+}
+
+func TestStripBasePath(t *testing.T) {
+	var have string
+	record := func(_ http.ResponseWriter, r *http.Request) {
+		have = r.URL.Path
+	}
+	handler := stripBasePath("/metadata", http.HandlerFunc(record))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/metadata/openstack/latest", want: "/openstack/latest"},
+		{path: "/metadata", want: "/"},
+		{path: "/metadata/", want: "/"},
+		// Paths rewritten by a proxy are served unchanged.
+		{path: "/openstack/latest", want: "/openstack/latest"},
+		{path: "/metadata-other/latest", want: "/metadata-other/latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			if have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutes_basePath(t *testing.T) {
+	h := createTestHandler()
+	h.BasePath = "/metadata/"
+	routes := h.Routes()
+
+	for _, path := range []string{"/metadata/openstack", "/openstack"} {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: have status %d, want %d", path, w.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package metadata
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/boot"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// agentProvisionStates are the provision states in which a node boots the
+// deploy ramdisk of its driver_info.
+var agentProvisionStates = []nodes.ProvisionState{
+	nodes.Deploying, nodes.DeployWait,
+	nodes.Cleaning, nodes.CleanWait,
+	nodes.Inspecting, nodes.InspectWait,
+	nodes.Servicing, nodes.ServiceWait,
+}
+
+// rescueProvisionStates are the provision states in which a node boots the
+// rescue ramdisk of its driver_info.
+var rescueProvisionStates = []nodes.ProvisionState{
+	nodes.Rescuing, nodes.RescueWait, nodes.Rescue,
+}
+
+// bootConfig returns what a node boots in its provision state: the deploy
+// or rescue ramdisk of its driver_info while the agent runs on it, the
+// kernel and ramdisk or boot ISO of its instance_info once active, such as
+// under the ramdisk deploy interface, and its local disk otherwise.
+func bootConfig(node *nodes.Node) boot.Config {
+	config := boot.Config{Node: node.Name}
+	if config.Node == "" {
+		config.Node = node.UUID
+	}
+
+	state := nodes.ProvisionState(node.ProvisionState)
+	params := stringField(node.DriverInfo, "kernel_append_params")
+	switch {
+	case slices.Contains(agentProvisionStates, state):
+		config.Kernel = stringField(node.DriverInfo, "deploy_kernel")
+		config.Ramdisk = stringField(node.DriverInfo, "deploy_ramdisk")
+		if config.Kernel == "" {
+			config.ISO = stringField(node.DriverInfo, "deploy_iso")
+		}
+	case slices.Contains(rescueProvisionStates, state):
+		config.Kernel = stringField(node.DriverInfo, "rescue_kernel")
+		config.Ramdisk = stringField(node.DriverInfo, "rescue_ramdisk")
+	case state == nodes.Active:
+		config.Kernel = stringField(node.InstanceInfo, "kernel")
+		config.Ramdisk = stringField(node.InstanceInfo, "ramdisk")
+		if config.Kernel == "" {
+			config.ISO = stringField(node.InstanceInfo, "boot_iso")
+		}
+		if value := stringField(node.InstanceInfo, "kernel_append_params"); value != "" {
+			params = value
+		}
+	}
+	if !config.LocalBoot() {
+		config.Params = params
+	}
+	return config
+}
+
+// stringField returns a string field of node data, empty when it is not a
+// string.
+func stringField(fields map[string]any, key string) string {
+	value, _ := fields[key].(string)
+	return value
+}
+
+// bootNode resolves the node named by id, a MAC address of one of its ports
+// or an IP address it holds. When no node can be resolved, or it is of an
+// unserved project, an error response is written and ok is false. Unlike
+// metadata, boot scripts are served to nodes in every provision state.
+func (h *Handler) bootNode(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+	endpoint string,
+) (*nodes.Node, bool) {
+	ctx := r.Context()
+
+	var (
+		node *nodes.Node
+		err  error
+	)
+	if mac, macErr := net.ParseMAC(id); macErr == nil {
+		node, err = h.getNodeByMACAddress(ctx, mac.String())
+	} else if ip := net.ParseIP(id); ip != nil {
+		node, err = h.getNodeByIP(withRequestHost(ctx, r.Host), ip.String())
+	} else {
+		http.Error(w, "Expected a MAC or IP address", http.StatusBadRequest)
+		return nil, false
+	}
+	if errors.Is(err, ErrIPConflict) {
+		http.Error(w, "Several nodes hold the IP", http.StatusConflict)
+		return nil, false
+	}
+	if err != nil || node == nil {
+		h.logger().Warn().
+			Err(err).
+			Str("id", id).
+			Str("endpoint", endpoint).
+			Msg("Failed to find node to boot")
+		http.Error(w, "Node not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !h.projectServed(node) {
+		h.Metrics.observeProjectRefusal(endpoint)
+		h.logger().Warn().
+			Str("node_uuid", node.UUID).
+			Str("project_id", h.projectID(node)).
+			Str("endpoint", endpoint).
+			Msg("Refusing boot script of node of an unserved project")
+		http.Error(w, "Node not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	setLastModified(w, node)
+	setPolicySubject(ctx, node, endpoint)
+	return node, true
+}
+
+// handleIPXE handles requests to /ipxe/{id}, serving the iPXE script of
+// the node with the MAC or IP address id. Nodes chainload it with
+// chain http://169.254.169.254/ipxe/${net0/mac}.
+func (h *Handler) handleIPXE(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.bootNode(w, r, mux.Vars(r)["id"], "ipxe")
+	if !ok {
+		return
+	}
+
+	config := bootConfig(node)
+	script, err := boot.IPXE(config)
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Str("provision_state", node.ProvisionState).
+			Msg("Failed to render iPXE script")
+		http.Error(w, "Boot script not available", http.StatusUnprocessableEntity)
+		return
+	}
+
+	h.logger().Info().
+		Str("node_uuid", node.UUID).
+		Str("provision_state", node.ProvisionState).
+		Bool("local_boot", config.LocalBoot()).
+		Msg("Serving iPXE script")
+	h.writeTextResponse(w, script)
+}
+
+// grubID converts the suffix of a grub.cfg file name GRUB requests into a
+// MAC or IP address: 01-52-54-00-12-34-56 names the MAC address of the
+// interface GRUB booted from, and 0A000005 the IPv4 address 10.0.0.5.
+func grubID(suffix string) string {
+	if mac, ok := strings.CutPrefix(suffix, "01-"); ok {
+		return mac
+	}
+	if b, err := hex.DecodeString(suffix); err == nil && len(b) == net.IPv4len {
+		return net.IP(b).String()
+	}
+	return suffix
+}
+
+// handleGRUB handles requests to /grub/grub.cfg-{id}, serving the GRUB
+// configuration of the node with the MAC or IP address id, named as GRUB
+// names the files it looks up in its prefix when booted over the network.
+func (h *Handler) handleGRUB(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.bootNode(w, r, grubID(mux.Vars(r)["id"]), "grub")
+	if !ok {
+		return
+	}
+
+	config := bootConfig(node)
+	cfg, err := boot.GRUB(config)
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Str("provision_state", node.ProvisionState).
+			Msg("Failed to render GRUB configuration")
+		http.Error(w, "Boot script not available", http.StatusUnprocessableEntity)
+		return
+	}
+
+	h.logger().Info().
+		Str("node_uuid", node.UUID).
+		Str("provision_state", node.ProvisionState).
+		Bool("local_boot", config.LocalBoot()).
+		Msg("Serving GRUB configuration")
+	h.writeTextResponse(w, cfg)
+}
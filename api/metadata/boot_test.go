@@ -0,0 +1,231 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestBootConfig(t *testing.T) {
+	driverInfo := map[string]any{
+		"deploy_kernel":        "http://10.0.0.1/ipa.kernel",
+		"deploy_ramdisk":       "http://10.0.0.1/ipa.initramfs",
+		"rescue_kernel":        "http://10.0.0.1/rescue.kernel",
+		"rescue_ramdisk":       "http://10.0.0.1/rescue.initramfs",
+		"kernel_append_params": "console=ttyS0",
+	}
+
+	tests := []struct {
+		name         string
+		state        nodes.ProvisionState
+		instanceInfo map[string]any
+		wantKernel   string
+		wantISO      string
+		wantParams   string
+	}{
+		{
+			name:       "deploying",
+			state:      nodes.DeployWait,
+			wantKernel: "http://10.0.0.1/ipa.kernel",
+			wantParams: "console=ttyS0",
+		},
+		{
+			name:       "cleaning",
+			state:      nodes.CleanWait,
+			wantKernel: "http://10.0.0.1/ipa.kernel",
+			wantParams: "console=ttyS0",
+		},
+		{
+			name:       "rescue",
+			state:      nodes.Rescue,
+			wantKernel: "http://10.0.0.1/rescue.kernel",
+			wantParams: "console=ttyS0",
+		},
+		{
+			name:  "active ramdisk",
+			state: nodes.Active,
+			instanceInfo: map[string]any{
+				"kernel":               "http://10.0.0.1/vmlinuz",
+				"ramdisk":              "http://10.0.0.1/initrd",
+				"kernel_append_params": "quiet",
+			},
+			wantKernel: "http://10.0.0.1/vmlinuz",
+			wantParams: "quiet",
+		},
+		{
+			name:         "active iso",
+			state:        nodes.Active,
+			instanceInfo: map[string]any{"boot_iso": "http://10.0.0.1/boot.iso"},
+			wantISO:      "http://10.0.0.1/boot.iso",
+			wantParams:   "console=ttyS0",
+		},
+		{name: "active local", state: nodes.Active},
+		{name: "available", state: nodes.Available},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have := bootConfig(&nodes.Node{
+				UUID:           "node-1",
+				ProvisionState: string(tt.state),
+				DriverInfo:     driverInfo,
+				InstanceInfo:   tt.instanceInfo,
+			})
+			if have.Node != "node-1" {
+				t.Errorf("have node %q, want node-1", have.Node)
+			}
+			if have.Kernel != tt.wantKernel || have.ISO != tt.wantISO ||
+				have.Params != tt.wantParams {
+				t.Errorf("have kernel %q, iso %q, params %q, want %q, %q, %q",
+					have.Kernel, have.ISO, have.Params, tt.wantKernel, tt.wantISO, tt.wantParams)
+			}
+		})
+	}
+}
+
+// testBootSource returns a node source with a node booting the deploy
+// ramdisk, and another whose deploy kernel is a Glance image.
+func testBootSource() *mock.NodeSource {
+	node := nodes.Node{
+		UUID:           "node-1",
+		Name:           "web01",
+		ProvisionState: string(nodes.DeployWait),
+		DriverInfo: map[string]any{
+			"deploy_kernel":  "http://10.0.0.1/ipa.kernel",
+			"deploy_ramdisk": "http://10.0.0.1/ipa.initramfs",
+		},
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	}
+	invalid := nodes.Node{
+		UUID:           "node-2",
+		ProvisionState: string(nodes.DeployWait),
+		DriverInfo: map[string]any{
+			"deploy_kernel":  "8a81b3a9-0b1f-4e4c-9a4f-0d3c7b5e6f70",
+			"deploy_ramdisk": "http://10.0.0.1/ipa.initramfs",
+		},
+	}
+
+	source := mock.NewNodeSource(node, invalid)
+	for _, port := range []ports.Port{
+		{UUID: "port-1", NodeUUID: "node-1", Address: "52:54:00:00:00:01"},
+		{UUID: "port-2", NodeUUID: "node-2", Address: "52:54:00:00:00:02"},
+	} {
+		source.AddPort(port)
+	}
+	return source
+}
+
+func TestHandler_handleIPXE(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "mac",
+			path:       "/ipxe/52:54:00:00:00:01",
+			wantStatus: http.StatusOK,
+			wantBody:   "kernel --name kernel http://10.0.0.1/ipa.kernel initrd=ramdisk",
+		},
+		{
+			name:       "hyphenated mac",
+			path:       "/ipxe/52-54-00-00-00-01",
+			wantStatus: http.StatusOK,
+			wantBody:   "# web01\n",
+		},
+		{
+			name:       "ip",
+			path:       "/ipxe/10.0.0.5",
+			wantStatus: http.StatusOK,
+			wantBody:   "initrd --name ramdisk http://10.0.0.1/ipa.initramfs",
+		},
+		{name: "unknown mac", path: "/ipxe/52:54:00:00:00:09", wantStatus: http.StatusNotFound},
+		{name: "unknown ip", path: "/ipxe/10.0.0.9", wantStatus: http.StatusNotFound},
+		{name: "invalid id", path: "/ipxe/web01", wantStatus: http.StatusBadRequest},
+		{
+			name:       "unrenderable",
+			path:       "/ipxe/52:54:00:00:00:02",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.Nodes = testBootSource()
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.1:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if !strings.HasPrefix(rr.Body.String(), "#!ipxe\n") ||
+				!strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("have script %q, want it to contain %q", rr.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandler_handleGRUB(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "mac",
+			path:       "/grub/grub.cfg-01-52-54-00-00-00-01",
+			wantStatus: http.StatusOK,
+			wantBody:   "\tlinux (http,10.0.0.1)/ipa.kernel\n",
+		},
+		{
+			name:       "hex ip",
+			path:       "/grub/grub.cfg-0A000005",
+			wantStatus: http.StatusOK,
+			wantBody:   "\tinitrd (http,10.0.0.1)/ipa.initramfs\n",
+		},
+		{
+			name:       "unknown mac",
+			path:       "/grub/grub.cfg-01-52-54-00-00-00-09",
+			wantStatus: http.StatusNotFound,
+		},
+		{name: "partial hex ip", path: "/grub/grub.cfg-0A00", wantStatus: http.StatusBadRequest},
+		{
+			name:       "unrenderable",
+			path:       "/grub/grub.cfg-01-52-54-00-00-00-02",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.Nodes = testBootSource()
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.1:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("have configuration %q, want it to contain %q", rr.Body, tt.wantBody)
+			}
+		})
+	}
+}
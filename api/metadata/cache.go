@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Cache classes, grouping routes by how their responses may be cached.
+const (
+	// CacheUserData covers user data, which may carry secrets, and the
+	// documents embedding it.
+	CacheUserData = "user_data"
+
+	// CachePassword covers password endpoints.
+	CachePassword = "password"
+
+	// CacheVendorData covers vendor data, which may carry resolved secrets.
+	CacheVendorData = "vendor_data"
+
+	// CacheMetaData covers instance metadata.
+	CacheMetaData = "meta_data"
+
+	// CacheAdmin covers the admin API.
+	CacheAdmin = "admin"
+
+	// CacheDefault covers all other routes.
+	CacheDefault = "default"
+)
+
+// CacheClasses lists the cache classes.
+var CacheClasses = []string{
+	CacheUserData,
+	CachePassword,
+	CacheVendorData,
+	CacheMetaData,
+	CacheAdmin,
+	CacheDefault,
+}
+
+// defaultCacheControl holds the Cache-Control header of each cache class.
+// Every document depends on the requesting node while sharing its URL with
+// all other nodes, so nothing may be stored by shared caches.
+var defaultCacheControl = map[string]string{
+	CacheUserData:   "no-store",
+	CachePassword:   "no-store",
+	CacheVendorData: "no-store",
+	CacheMetaData:   "private, max-age=60",
+	CacheAdmin:      "no-store",
+	CacheDefault:    "private, no-cache",
+}
+
+// userDataFiles are the names under which user data is served.
+var userDataFiles = map[string]bool{
+	"user_data":             true,
+	"user_data" + jwsSuffix: true,
+	"user-data":             true,
+	"config.ign":            true,
+}
+
+// metaDataFiles are the names under which instance metadata is served.
+var metaDataFiles = map[string]bool{
+	"meta_data.json":             true,
+	"meta_data.json" + jwsSuffix: true,
+	"meta-data":                  true,
+}
+
+// cacheControlMiddleware sets the Cache-Control header of the request's
+// cache class, which handlers may override.
+func (h *Handler) cacheControlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value := h.cacheControl(cacheClass(r)); value != "" {
+			w.Header().Set("Cache-Control", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cacheControl returns the Cache-Control header of a cache class.
+func (h *Handler) cacheControl(class string) string {
+	if value, ok := h.CacheControl[class]; ok {
+		return value
+	}
+	return defaultCacheControl[class]
+}
+
+// cacheClass returns the cache class of a request.
+func cacheClass(r *http.Request) string {
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	name := path.Base(p)
+
+	switch {
+	case strings.HasPrefix(p, "/admin/"):
+		return CacheAdmin
+	case p == gcePrefix || strings.HasPrefix(p, gcePrefix+"/"):
+		// GCE documents can embed user data in instance attributes and
+		// recursive listings.
+		if strings.Contains(p, "/attributes") || r.URL.Query().Get("recursive") == "true" {
+			return CacheUserData
+		}
+		return CacheMetaData
+	case userDataFiles[name]:
+		return CacheUserData
+	case name == "password":
+		return CachePassword
+	case name == "vendor_data.json" || name == "vendor_data2.json":
+		return CacheVendorData
+	case metaDataFiles[name] || strings.HasPrefix(p, "/latest/meta-data") ||
+		strings.HasPrefix(p, "/latest/dynamic"):
+		return CacheMetaData
+	}
+	return CacheDefault
+}
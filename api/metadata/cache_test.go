@@ -0,0 +1,65 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheClass(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/openstack/latest/user_data", want: CacheUserData},
+		{path: "/openstack/2018-08-27/user_data.jws", want: CacheUserData},
+		{path: "/latest/user-data", want: CacheUserData},
+		{path: "/nocloud/user-data", want: CacheUserData},
+		{path: "/openstack/latest/password", want: CachePassword},
+		{path: "/openstack/latest/vendor_data2.json", want: CacheVendorData},
+		{path: "/openstack/latest/meta_data.json", want: CacheMetaData},
+		{path: "/latest/meta-data/hostname", want: CacheMetaData},
+		{path: "/latest/meta-data/", want: CacheMetaData},
+		{path: gcePrefix + "/instance/hostname", want: CacheMetaData},
+		{path: gcePrefix + "/instance/attributes/user-data", want: CacheUserData},
+		{path: gcePrefix + "/?recursive=true", want: CacheUserData},
+		{path: "/admin/cache", want: CacheAdmin},
+		{path: "/openstack/latest/network_data.json", want: CacheDefault},
+		{path: "/healthz", want: CacheDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if have := cacheClass(r); have != tt.want {
+				t.Errorf("have %s, want %s", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheControlMiddleware(t *testing.T) {
+	h := createTestHandler()
+	h.CacheControl = map[string]string{CacheMetaData: "private, max-age=5", CacheDefault: ""}
+	document := func(w http.ResponseWriter, _ *http.Request) {
+		h.writeTextResponse(w, "ok")
+	}
+	handler := h.cacheControlMiddleware(http.HandlerFunc(document))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/openstack/latest/user_data", want: "no-store"},
+		{path: "/openstack/latest/meta_data.json", want: "private, max-age=5"},
+		{path: "/healthz", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if have := w.Header().Get("Cache-Control"); have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
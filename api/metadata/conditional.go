@@ -0,0 +1,150 @@
+package metadata
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// conditionalMiddleware sets an ETag derived from the body of successful
+// GET responses, and answers conditional requests whose validators still
+// match with 304 Not Modified. Responses are buffered to compute the ETag,
+// except those whose handler sets the ETag itself, which then has to call
+// notModified before writing.
+func (h *Handler) conditionalMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &conditionalWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		cw.decide()
+		if cw.passthrough {
+			return
+		}
+
+		if cw.status == http.StatusOK {
+			w.Header().Set("ETag", etag(cw.body.Bytes()))
+			if notModified(w, r) {
+				return
+			}
+		}
+		w.WriteHeader(cw.status)
+		if _, err := w.Write(cw.body.Bytes()); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("path", r.URL.Path).
+				Msg("Failed to write response")
+		}
+	})
+}
+
+// conditionalWriter buffers a response, unless the handler set an ETag
+// before writing it, in which case the response is passed through.
+type conditionalWriter struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	decided     bool
+	passthrough bool
+}
+
+// decide settles whether the response is passed through, once the
+// handler starts writing it.
+func (cw *conditionalWriter) decide() {
+	if !cw.decided {
+		cw.decided = true
+		cw.passthrough = cw.Header().Get("ETag") != ""
+	}
+}
+
+func (cw *conditionalWriter) WriteHeader(status int) {
+	cw.decide()
+	if cw.passthrough {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+}
+
+func (cw *conditionalWriter) Write(p []byte) (int, error) {
+	cw.decide()
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(p)
+	}
+	return cw.body.Write(p)
+}
+
+// etag returns the strong ETag of a response body.
+func etag(body []byte) string {
+	digest := sha256.Sum256(body)
+	return formatETag(digest[:])
+}
+
+// formatETag formats a SHA-256 digest of a response body as an ETag.
+func formatETag(digest []byte) string {
+	return `"` + hex.EncodeToString(digest[:16]) + `"`
+}
+
+// setLastModified sets the Last-Modified header of a node's documents to
+// the time the node was last updated in Ironic.
+func setLastModified(w http.ResponseWriter, node *nodes.Node) {
+	if !node.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", node.UpdatedAt.UTC().Format(http.TimeFormat))
+	} else if !node.CreatedAt.IsZero() {
+		w.Header().Set("Last-Modified", node.CreatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since in its absence,
+// against the ETag and Last-Modified headers already set on w. It writes
+// 304 Not Modified and returns true when the client's copy is current.
+func notModified(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, w.Header().Get("ETag")) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil {
+			return false
+		}
+		lastModified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+		if err != nil || lastModified.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+
+	header := w.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using
+// the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConditionalMiddleware(t *testing.T) {
+	updated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := createTestHandler()
+	document := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "Node not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", updated.Format(http.TimeFormat))
+		h.writeTextResponse(w, "hostname\nweb01")
+	}
+	handler := h.conditionalMiddleware(http.HandlerFunc(document))
+	want := etag([]byte("hostname\nweb01"))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     map[string]string
+		wantStatus int
+		wantETag   bool
+	}{
+		{name: "unconditional", wantStatus: http.StatusOK, wantETag: true},
+		{
+			name:       "etag match",
+			header:     map[string]string{"If-None-Match": `"other", ` + want},
+			wantStatus: http.StatusNotModified,
+			wantETag:   true,
+		},
+		{
+			name:       "weak etag match",
+			header:     map[string]string{"If-None-Match": "W/" + want},
+			wantStatus: http.StatusNotModified,
+			wantETag:   true,
+		},
+		{
+			name:       "etag mismatch",
+			header:     map[string]string{"If-None-Match": `"other"`},
+			wantStatus: http.StatusOK,
+			wantETag:   true,
+		},
+		{
+			// If-None-Match takes precedence over If-Modified-Since.
+			name: "etag mismatch not modified",
+			header: map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": updated.Format(http.TimeFormat),
+			},
+			wantStatus: http.StatusOK,
+			wantETag:   true,
+		},
+		{
+			name:       "not modified since",
+			header:     map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)},
+			wantStatus: http.StatusNotModified,
+			wantETag:   true,
+		},
+		{
+			name: "modified since",
+			header: map[string]string{
+				"If-Modified-Since": updated.Add(-time.Hour).Format(http.TimeFormat),
+			},
+			wantStatus: http.StatusOK,
+			wantETag:   true,
+		},
+		{
+			name:       "error",
+			path:       "/missing",
+			header:     map[string]string{"If-None-Match": "*"},
+			wantStatus: http.StatusNotFound,
+		},
+		{name: "post", method: "POST", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, path := tt.method, tt.path
+			if method == "" {
+				method = "GET"
+			}
+			if path == "" {
+				path = "/openstack/latest/meta_data.json"
+			}
+			req := httptest.NewRequest(method, path, nil)
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status: have %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if have := rr.Header().Get("ETag"); (have == want) != tt.wantETag {
+				t.Errorf("ETag: have %q, want set %v", have, tt.wantETag)
+			}
+			if rr.Code == http.StatusNotModified && rr.Body.Len() > 0 {
+				t.Errorf("expected empty 304 body, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestConditionalMiddleware_passthrough(t *testing.T) {
+	h := createTestHandler()
+	streamed := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"streamed"`)
+		if notModified(w, r) {
+			return
+		}
+		_, _ = w.Write([]byte("#cloud-config\n"))
+	}
+	handler := h.conditionalMiddleware(http.HandlerFunc(streamed))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/openstack/latest/user_data", nil))
+	if have := rr.Header().Get("ETag"); have != `"streamed"` {
+		t.Errorf("ETag: have %q, want the handler's", have)
+	}
+
+	req := httptest.NewRequest("GET", "/openstack/latest/user_data", nil)
+	req.Header.Set("If-None-Match", `"streamed"`)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("status: have %d, want %d", rr.Code, http.StatusNotModified)
+	}
+}
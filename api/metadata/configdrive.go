@@ -0,0 +1,215 @@
+package metadata
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/configdrive"
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// configDriveFetchTimeout bounds the download of a remote configdrive.
+const configDriveFetchTimeout = 30 * time.Second
+
+// configDriveCache holds the parsed configdrive of each node, along with a
+// hash of the instance_info it was parsed from, evicting the least recently
+// used beyond CacheLimits.
+type configDriveCache struct {
+	mu       sync.Mutex
+	entries  lru.Cache[string, configDriveCacheEntry]
+	counters metrics.CacheCounters
+}
+
+// configDriveCacheEntry is a parsed configdrive and the time it was stored.
+type configDriveCacheEntry struct {
+	hash   string
+	data   *configDriveData
+	stored time.Time
+}
+
+// get returns the configdrive of a node if it was parsed from the same
+// instance_info.
+func (c *configDriveCache) get(nodeUUID, hash string) (*configDriveData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries.Get(nodeUUID)
+	hit := ok && entry.hash == hash
+	c.counters.Lookup(hit)
+	if !hit {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// put stores the configdrive of a node, of an estimated size in bytes, at
+// now, replacing any earlier version and evicting beyond limits.
+func (c *configDriveCache) put(
+	nodeUUID, hash string, data *configDriveData, size int64, limits lru.Limits, now time.Time,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counters.Evict(c.entries.SetLimits(limits))
+	c.counters.Evict(c.entries.Put(
+		nodeUUID, configDriveCacheEntry{hash: hash, data: data, stored: now}, size))
+}
+
+// delete drops the configdrive of a node.
+func (c *configDriveCache) delete(nodeUUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.Delete(nodeUUID)
+}
+
+// stats returns the usage of the cache.
+func (c *configDriveCache) stats() metrics.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.counters.Stats(c.entries.Len(), func(yield func(time.Time) bool) {
+		for _, entry := range c.entries.All() {
+			if !yield(entry.stored) {
+				return
+			}
+		}
+	})
+	stats.Bytes = c.entries.Bytes()
+	return stats
+}
+
+// extractFromConfigDrive returns the data of a node's configdrive. Parsed
+// configdrives are cached until the node's instance_info changes, since a
+// single request may need them several times. The result is shared and must
+// not be modified.
+func (h *Handler) extractFromConfigDrive(node *nodes.Node) (*configDriveData, error) {
+	if _, ok := node.InstanceInfo["configdrive"]; !ok {
+		return h.parseConfigDrive(node)
+	}
+
+	hash, err := instanceInfoHash(node)
+	if err != nil {
+		return h.parseConfigDrive(node)
+	}
+	if data, ok := h.configDriveCache.get(node.UUID, hash); ok {
+		return data, nil
+	}
+
+	// Failures are not cached, as they may be transient download errors.
+	data, err := h.parseConfigDrive(node)
+	if err != nil {
+		return nil, err
+	}
+	h.configDriveCache.put(node.UUID, hash, data, jsonSize(data), h.CacheLimits, h.now())
+	return data, nil
+}
+
+// jsonSize estimates the memory a value takes by the size of its JSON
+// encoding, or returns zero when it cannot be encoded.
+func jsonSize(v any) int64 {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return int64(len(b))
+}
+
+// InvalidateConfigDrive drops the cached configdrive of a node, for callers
+// that learn about node changes before the next request does.
+func (h *Handler) InvalidateConfigDrive(nodeUUID string) {
+	h.configDriveCache.delete(nodeUUID)
+}
+
+// InvalidateNode drops the cached configdrive, inspection inventory and
+// allocation of a node, so the next request for it fetches them again.
+func (h *Handler) InvalidateNode(node *nodes.Node) {
+	h.configDriveCache.delete(node.UUID)
+	h.inventoryCache.delete(node.UUID)
+	if node.AllocationUUID != "" {
+		h.allocationCache.delete(node.AllocationUUID)
+	}
+}
+
+// instanceInfoHash returns a digest of a node's instance_info. Map keys are
+// marshalled in sorted order, so equal contents produce equal digests.
+func instanceInfoHash(node *nodes.Node) (string, error) {
+	b, err := json.Marshal(node.InstanceInfo)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fetchConfigDrive downloads a configdrive referenced by URL or Swift object.
+func (h *Handler) fetchConfigDrive(node *nodes.Node, ref string) (*configDriveData, error) {
+	if h.ConfigDrives == nil {
+		return nil, fmt.Errorf("remote configdrive fetching is not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), configDriveFetchTimeout)
+	defer cancel()
+
+	cd, err := h.ConfigDrives.Fetch(ctx, ref)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to fetch remote configdrive")
+		return nil, fmt.Errorf("failed to fetch configdrive: %w", err)
+	}
+
+	data, err := newConfigDriveData(cd)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to decode remote configdrive")
+		return nil, err
+	}
+	return data, nil
+}
+
+// newConfigDriveData decodes the files of a configdrive image.
+func newConfigDriveData(cd *configdrive.ConfigDrive) (*configDriveData, error) {
+	data := &configDriveData{MetaData: &metadata.MetaData{}}
+	if err := json.Unmarshal(cd.MetaData, data.MetaData); err != nil {
+		return nil, fmt.Errorf("failed to parse configdrive meta_data.json: %w", err)
+	}
+	data.PublicKeys = data.MetaData.PublicKeys
+
+	if cd.NetworkData != nil {
+		data.NetworkData = &metadata.NetworkData{}
+		if err := json.Unmarshal(cd.NetworkData, data.NetworkData); err != nil {
+			return nil, fmt.Errorf("failed to parse configdrive network_data.json: %w", err)
+		}
+	}
+
+	if cd.VendorData != nil {
+		if err := json.Unmarshal(cd.VendorData, &data.VendorData); err != nil {
+			return nil, fmt.Errorf("failed to parse configdrive vendor_data.json: %w", err)
+		}
+	}
+
+	if cd.UserData != nil {
+		data.UserData = string(cd.UserData)
+	}
+
+	if len(cd.Content) > 0 {
+		data.Content = make(map[string]string, len(cd.Content))
+		for id, body := range cd.Content {
+			data.Content[id] = base64.StdEncoding.EncodeToString(body)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestExtractFromConfigDriveCache(t *testing.T) {
+	h := createTestHandler()
+	node := &nodes.Node{
+		UUID: "test-uuid",
+		InstanceInfo: map[string]any{
+			"configdrive": map[string]any{
+				"meta_data": map[string]any{"uuid": "test-uuid", "hostname": "first"},
+			},
+		},
+	}
+
+	first, err := h.extractFromConfigDrive(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := h.extractFromConfigDrive(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Error("expected unchanged instance_info to reuse the parsed configdrive")
+	}
+
+	node.InstanceInfo["configdrive"] = map[string]any{
+		"meta_data": map[string]any{"uuid": "test-uuid", "hostname": "second"},
+	}
+	changed, err := h.extractFromConfigDrive(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := changed.MetaData.Hostname; have != "second" {
+		t.Errorf("expected changed instance_info to be parsed again, got hostname %q", have)
+	}
+
+	h.InvalidateConfigDrive(node.UUID)
+	invalidated, err := h.extractFromConfigDrive(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if invalidated == changed {
+		t.Error("expected invalidated configdrive to be parsed again")
+	}
+}
+
+func TestExtractFromConfigDriveCache_limits(t *testing.T) {
+	h := createTestHandler()
+	h.CacheLimits = lru.Limits{MaxEntries: 2}
+	configDriveNode := func(uuid string) *nodes.Node {
+		return &nodes.Node{
+			UUID: uuid,
+			InstanceInfo: map[string]any{
+				"configdrive": map[string]any{"meta_data": map[string]any{"uuid": uuid}},
+			},
+		}
+	}
+
+	for _, uuid := range []string{"node-1", "node-2", "node-1", "node-3"} {
+		if _, err := h.extractFromConfigDrive(configDriveNode(uuid)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	stats := h.configDriveCache.stats()
+	if stats.Entries != 2 || stats.Evictions != 1 || stats.Bytes == 0 {
+		t.Errorf("have stats %+v, want 2 entries of some bytes and 1 eviction", stats)
+	}
+	// node-1 was used after node-2, which was evicted.
+	if _, ok := h.configDriveCache.entries.Get("node-2"); ok {
+		t.Error("expected the least recently used configdrive to be evicted")
+	}
+	if _, ok := h.configDriveCache.entries.Get("node-1"); !ok {
+		t.Error("expected the recently used configdrive to be kept")
+	}
+}
@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Policies for a client IP that several nodes hold in their node data, such
+// as one whose stale instance_info still lists the IP of another.
+const (
+	// ConflictRefuse fails the request with 409 Conflict.
+	ConflictRefuse = "refuse"
+
+	// ConflictDHCP matches no node by IP, leaving the resolvers after the
+	// ip resolver, such as those of DHCP leases, to find the node by its MAC
+	// address.
+	ConflictDHCP = "dhcp"
+
+	// ConflictFirst serves the first node listed.
+	ConflictFirst = "first"
+)
+
+// IPConflictPolicies lists the policies for IP conflicts.
+var IPConflictPolicies = []string{ConflictRefuse, ConflictDHCP, ConflictFirst}
+
+// ErrIPConflict is the error of resolving a client IP several nodes hold
+// under ConflictRefuse.
+var ErrIPConflict = errors.New("several nodes hold the client IP")
+
+// ParseIPConflictPolicy parses a policy for IP conflicts, where empty means
+// ConflictRefuse.
+func ParseIPConflictPolicy(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	if policy == "" {
+		return ConflictRefuse, nil
+	}
+	if !slices.Contains(IPConflictPolicies, policy) {
+		return "", fmt.Errorf("unknown IP conflict policy %q, want one of %s",
+			policy, strings.Join(IPConflictPolicies, ", "))
+	}
+	return policy, nil
+}
+
+// ipMatch is a node holding a client IP, and where it does.
+type ipMatch struct {
+	node  nodes.Node
+	where string
+}
+
+// sameIP reports whether two strings are the same IP address, whatever
+// their notation.
+func sameIP(a, b string) bool {
+	addrA, err := netip.ParseAddr(a)
+	if err != nil {
+		return false
+	}
+	addrB, err := netip.ParseAddr(b)
+	if err != nil {
+		return false
+	}
+	return addrA.Unmap() == addrB.Unmap()
+}
+
+// urlHasIP reports whether the host of a URL, such as the ipa-api-url of a
+// node, is the IP address ip.
+func urlHasIP(rawURL, ip string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return sameIP(u.Hostname(), ip)
+}
+
+// nameHasIP reports whether a node name holds the IP address ip whole, such
+// as rack1-10.0.0.1, but not rack1-10.0.0.12.
+func nameHasIP(name, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	ipChars := "0123456789."
+	if addr.Is6() && !addr.Is4In6() {
+		ipChars = "0123456789abcdefABCDEF:."
+	}
+	for _, field := range strings.FieldsFunc(name, func(r rune) bool {
+		return !strings.ContainsRune(ipChars, r)
+	}) {
+		if sameIP(strings.Trim(field, "."), ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveIPConflict picks the node of a client IP held by several nodes
+// according to IPConflictPolicy, logging and counting the conflict.
+func (h *Handler) resolveIPConflict(
+	ctx context.Context,
+	clientIP string,
+	matches []ipMatch,
+) (*nodes.Node, error) {
+	policy := h.IPConflictPolicy
+	if policy == "" {
+		policy = ConflictRefuse
+	}
+	uuids := make([]string, 0, len(matches))
+	sources := make([]string, 0, len(matches))
+	for _, match := range matches {
+		uuids = append(uuids, match.node.UUID)
+		sources = append(sources, match.where)
+	}
+	h.logger().Warn().
+		Str("client_ip", clientIP).
+		Strs("node_uuids", uuids).
+		Strs("sources", sources).
+		Str("policy", policy).
+		Msg("Several nodes hold the client IP")
+	traceNote(ctx, "nodes %s all hold the IP, resolved by the %s policy",
+		strings.Join(uuids, ", "), policy)
+	h.Metrics.observeIPConflict(policy)
+
+	switch policy {
+	case ConflictFirst:
+		return &matches[0].node, nil
+	case ConflictDHCP:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: nodes %s", ErrIPConflict, strings.Join(uuids, ", "))
+	}
+}
@@ -0,0 +1,192 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestParseIPConflictPolicy(t *testing.T) {
+	tests := []struct {
+		have    string
+		want    string
+		wantErr bool
+	}{
+		{have: "", want: ConflictRefuse},
+		{have: "refuse", want: ConflictRefuse},
+		{have: " DHCP ", want: ConflictDHCP},
+		{have: "first", want: ConflictFirst},
+		{have: "last", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.have, func(t *testing.T) {
+			have, err := ParseIPConflictPolicy(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have policy %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_ipConflict(t *testing.T) {
+	fixedIP := map[string]any{
+		"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+	}
+	current := nodes.Node{
+		UUID: "node-1", Name: "web01", ProvisionState: "active", InstanceInfo: fixedIP,
+	}
+	stale := nodes.Node{
+		UUID: "node-2", Name: "web02", ProvisionState: "active", InstanceInfo: fixedIP,
+	}
+	other := nodes.Node{UUID: "node-3", Name: "web03", ProvisionState: "active"}
+	named := nodes.Node{UUID: "node-4", Name: "rack1-10.0.0.5", ProvisionState: "active"}
+	similar := nodes.Node{
+		UUID: "node-5", Name: "rack1-10.0.0.55", ProvisionState: "active",
+		DriverInfo: map[string]any{
+			"deploy_ramdisk_options": map[string]any{"ipa-api-url": "http://10.0.0.50:9999"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		policy     string
+		nodes      []nodes.Node
+		want       string
+		wantStatus int
+		wantMetric string
+	}{
+		{
+			name:       "refuse by default",
+			nodes:      []nodes.Node{current, stale, other},
+			wantStatus: http.StatusConflict,
+			wantMetric: `ironic_metadata_ip_conflicts_total{policy="refuse"} 1`,
+		},
+		{
+			name:       "dhcp",
+			policy:     ConflictDHCP,
+			nodes:      []nodes.Node{current, stale},
+			wantStatus: http.StatusNotFound,
+			wantMetric: `ironic_metadata_ip_conflicts_total{policy="dhcp"} 1`,
+		},
+		{
+			name:       "first",
+			policy:     ConflictFirst,
+			nodes:      []nodes.Node{current, stale},
+			want:       "node-1",
+			wantMetric: `ironic_metadata_ip_conflicts_total{policy="first"} 1`,
+		},
+		{
+			name:   "node names holding longer IPs",
+			nodes:  []nodes.Node{named, similar},
+			want:   "node-4",
+			policy: ConflictRefuse,
+		},
+		{
+			name:   "single node",
+			nodes:  []nodes.Node{current, other},
+			want:   "node-1",
+			policy: ConflictRefuse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := metrics.NewRegistry()
+			h := NewHandler(
+				WithNodeSource(mock.NewNodeSource(tt.nodes...)),
+				WithIPConflictPolicy(tt.policy),
+			)
+			h.EnableMetrics(registry)
+
+			req := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json", nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			var b strings.Builder
+			if _, err := registry.WriteTo(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantMetric != "" && !strings.Contains(b.String(), tt.wantMetric+"\n") {
+				t.Errorf("expected %q in\n%s", tt.wantMetric, b.String())
+			}
+			if tt.wantStatus != 0 {
+				if rr.Code != tt.wantStatus {
+					t.Errorf("have status %d, want %d", rr.Code, tt.wantStatus)
+				}
+				return
+			}
+			var have struct {
+				UUID string `json:"uuid"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v: %s", err, rr.Body)
+			}
+			if have.UUID != tt.want {
+				t.Errorf("have node %q, want %q", have.UUID, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameHasIP(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want bool
+	}{
+		{name: "rack1-10.0.0.1", ip: "10.0.0.1", want: true},
+		{name: "rack1-10.0.0.12", ip: "10.0.0.1"},
+		{name: "rack1-110.0.0.1", ip: "10.0.0.1"},
+		{name: "10.0.0.1.example.com", ip: "10.0.0.1", want: true},
+		{name: "web10.0.0.1", ip: "10.0.0.1", want: true},
+		{name: "node-fd00::1", ip: "fd00::1", want: true},
+		{name: "node-fd00::10", ip: "fd00::1"},
+		{name: "web01", ip: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if have := nameHasIP(tt.name, tt.ip); have != tt.want {
+				t.Errorf("have %t, want %t", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLHasIP(t *testing.T) {
+	tests := []struct {
+		url  string
+		ip   string
+		want bool
+	}{
+		{url: "http://10.0.0.1:9999", ip: "10.0.0.1", want: true},
+		{url: "http://10.0.0.12:9999", ip: "10.0.0.1"},
+		{url: "http://[fd00::1]:9999", ip: "fd00::1", want: true},
+		{url: "http://ipa.example.com/10.0.0.1", ip: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if have := urlHasIP(tt.url, tt.ip); have != tt.want {
+				t.Errorf("have %t, want %t", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// injectedFile is a Nova-style personality file. contents is nil when the
+// body has to be read from the content directory. owner and mode are hints
+// for writing the file, empty when not given.
+type injectedFile struct {
+	path     string
+	id       string
+	contents []byte
+	owner    string
+	mode     string
+}
+
+// injectedFiles collects the files injected into a node, either from the
+// configdrive or from instance_info["files"]. Entries in instance_info carry
+// their base64 encoded contents inline, or reference a file in the content
+// directory through content_path, and may name an owner and an octal mode.
+func (h *Handler) injectedFiles(node *nodes.Node) []injectedFile {
+	if configDrive, err := h.extractFromConfigDrive(node); err == nil &&
+		configDrive.MetaData != nil && len(configDrive.MetaData.Files) > 0 {
+		files := make([]injectedFile, 0, len(configDrive.MetaData.Files))
+		for _, file := range configDrive.MetaData.Files {
+			id := path.Base(file.ContentPath)
+			injected := injectedFile{path: file.Path, id: id, owner: file.Owner, mode: file.Mode}
+			if encoded, ok := configDrive.Content[id]; ok {
+				contents, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					h.logger().Warn().
+						Err(err).
+						Str("node_uuid", node.UUID).
+						Str("content_id", id).
+						Msg("Skipping configdrive content that is not base64 encoded")
+					continue
+				}
+				injected.contents = contents
+			}
+			files = append(files, injected)
+		}
+		return files
+	}
+
+	entries, ok := node.InstanceInfo["files"].([]any)
+	if !ok {
+		return nil
+	}
+
+	files := make([]injectedFile, 0, len(entries))
+	for i, entry := range entries {
+		file, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		filePath, _ := file["path"].(string)
+		if filePath == "" {
+			continue
+		}
+
+		injected := injectedFile{path: filePath}
+		injected.owner, _ = file["owner"].(string)
+		if value, ok := file["mode"]; ok {
+			mode, err := parseFileMode(value)
+			if err != nil {
+				h.logger().Warn().
+					Err(err).
+					Str("node_uuid", node.UUID).
+					Str("path", filePath).
+					Msg("Ignoring invalid mode of injected file")
+			}
+			injected.mode = mode
+		}
+
+		if contentPath, ok := file["content_path"].(string); ok && contentPath != "" {
+			injected.id = path.Base(contentPath)
+			files = append(files, injected)
+			continue
+		}
+
+		encoded, _ := file["contents"].(string)
+		contents, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			h.logger().Warn().
+				Err(err).
+				Str("node_uuid", node.UUID).
+				Str("path", filePath).
+				Msg("Skipping injected file that is not base64 encoded")
+			continue
+		}
+		injected.id = fmt.Sprintf("%04d", i)
+		injected.contents = contents
+		files = append(files, injected)
+	}
+	return files
+}
+
+// parseFileMode parses the mode of an injected file, given as an octal
+// string such as "0644" or as a number whose digits are octal, as in 644.
+// It returns the mode as four octal digits.
+func parseFileMode(value any) (string, error) {
+	var digits string
+	switch value := value.(type) {
+	case string:
+		digits = strings.TrimPrefix(strings.TrimSpace(value), "0o")
+	case float64:
+		if value != float64(int64(value)) {
+			return "", fmt.Errorf("mode %v is not an integer", value)
+		}
+		digits = strconv.FormatInt(int64(value), 10)
+	default:
+		return "", fmt.Errorf("mode %v is neither a string nor a number", value)
+	}
+
+	mode, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil || mode > 0o7777 {
+		return "", fmt.Errorf("mode %v is not an octal file mode", value)
+	}
+	return fmt.Sprintf("%04o", mode), nil
+}
+
+// handleOpenStackContent handles requests to /openstack/content/{id},
+// serving the body of an injected file.
+func (h *Handler) handleOpenStackContent(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "content")
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	for _, file := range h.injectedFiles(node) {
+		if file.id != id {
+			continue
+		}
+
+		contents := file.contents
+		if contents == nil {
+			var err error
+			contents, err = h.readContent(node, id)
+			if err != nil {
+				h.logger().Error().
+					Err(err).
+					Str("node_uuid", node.UUID).
+					Str("content_id", id).
+					Msg("Failed to read injected file content")
+				http.Error(w, "Content not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if _, err := w.Write(contents); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("node_uuid", node.UUID).
+				Str("content_id", id).
+				Msg("Failed to write content response")
+		}
+		return
+	}
+
+	http.Error(w, "Content not found", http.StatusNotFound)
+}
+
+// readContent reads an injected file body from the content directory,
+// preferring a node specific file over a shared one.
+func (h *Handler) readContent(node *nodes.Node, id string) ([]byte, error) {
+	if h.ContentDir == "" {
+		return nil, errors.New("no content directory configured")
+	}
+
+	for _, name := range []string{
+		filepath.Join(h.ContentDir, node.UUID, id),
+		filepath.Join(h.ContentDir, id),
+	} {
+		contents, err := os.ReadFile(name)
+		if err == nil {
+			return contents, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+	return nil, fmt.Errorf("content %s not found in %s", id, h.ContentDir)
+}
@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestInjectedFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		instanceInfo map[string]any
+		want         []injectedFile
+	}{
+		{name: "none", instanceInfo: map[string]any{}, want: nil},
+		{
+			name: "instance info",
+			instanceInfo: map[string]any{
+				"files": []any{
+					map[string]any{"path": "/etc/motd", "contents": "aGVsbG8K"},
+					map[string]any{"path": "/etc/issue", "content_path": "/content/banner"},
+					map[string]any{"path": "/etc/broken", "contents": "not base64!"},
+					map[string]any{"contents": "aGVsbG8K"},
+					map[string]any{
+						"path": "/etc/app.conf", "contents": "aGVsbG8K",
+						"owner": "app:app", "mode": "640",
+					},
+					map[string]any{
+						"path": "/usr/local/bin/run", "content_path": "/content/run",
+						"mode": float64(755),
+					},
+					map[string]any{
+						"path": "/etc/bad-mode", "contents": "aGVsbG8K", "mode": "rw-r--r--",
+					},
+				},
+			},
+			want: []injectedFile{
+				{path: "/etc/motd", id: "0000", contents: []byte("hello\n")},
+				{path: "/etc/issue", id: "banner"},
+				{
+					path: "/etc/app.conf", id: "0004", contents: []byte("hello\n"),
+					owner: "app:app", mode: "0640",
+				},
+				{path: "/usr/local/bin/run", id: "run", mode: "0755"},
+				{path: "/etc/bad-mode", id: "0006", contents: []byte("hello\n")},
+			},
+		},
+		{
+			name: "configdrive",
+			instanceInfo: map[string]any{
+				"configdrive": map[string]any{
+					"meta_data": map[string]any{
+						"uuid": "test-uuid",
+						"files": []any{
+							map[string]any{
+								"path": "/etc/motd", "content_path": "/content/0000",
+								"mode": "0644",
+							},
+						},
+					},
+					"content": map[string]any{"0000": "aGVsbG8K"},
+				},
+				"files": []any{
+					map[string]any{"path": "/etc/ignored", "contents": "aGVsbG8K"},
+				},
+			},
+			want: []injectedFile{
+				{path: "/etc/motd", id: "0000", contents: []byte("hello\n"), mode: "0644"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			have := h.injectedFiles(&nodes.Node{UUID: "test-uuid", InstanceInfo: tt.instanceInfo})
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %+v, want %+v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		have    any
+		want    string
+		wantErr bool
+	}{
+		{have: "0644", want: "0644"},
+		{have: "600", want: "0600"},
+		{have: "0o4755", want: "4755"},
+		{have: float64(644), want: "0644"},
+		{have: "0888", wantErr: true},
+		{have: "17777", wantErr: true},
+		{have: float64(64.5), wantErr: true},
+		{have: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		have, err := parseFileMode(tt.have)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFileMode(%v): unexpected error: %v", tt.have, err)
+		}
+		if have != tt.want {
+			t.Errorf("parseFileMode(%v): have %q, want %q", tt.have, have, tt.want)
+		}
+	}
+}
+
+func TestReadContent(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "test-uuid"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "banner"), []byte("shared"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test-uuid", "banner"), []byte("node"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{ContentDir: dir}
+
+	have, err := h.readContent(&nodes.Node{UUID: "test-uuid"}, "banner")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(have) != "node" {
+		t.Errorf("have %q, want %q", have, "node")
+	}
+
+	have, err = h.readContent(&nodes.Node{UUID: "other-uuid"}, "banner")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(have) != "shared" {
+		t.Errorf("have %q, want %q", have, "shared")
+	}
+
+	if _, err := h.readContent(&nodes.Node{UUID: "test-uuid"}, "missing"); err == nil {
+		t.Error("expected error for missing content")
+	}
+}
@@ -2,140 +2,73 @@ package metadata
 
 import (
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
-	"time"
 
-	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
 )
 
-func TestParseDHCPLeaseFile(t *testing.T) {
+func TestLookupNodeByMAC(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	leaseContent := `1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
+1750802648 9c:6b:00:70:59:8a 10.1.105.194 * *`
+	if err := os.WriteFile(leaseFile, []byte(leaseContent), 0o600); err != nil {
+		t.Fatalf("Failed to write lease file: %v", err)
+	}
+
 	tests := []struct {
-		name          string
-		leaseContent  string
-		targetIP      string
-		expectedMAC   string
-		expectedError bool
+		name     string
+		clientIP string
+		err      error
+		wantNode string
+		wantErr  bool
 	}{
+		{name: "leased and registered", clientIP: "10.1.105.195", wantNode: "node-1"},
+		{name: "no port for leased MAC", clientIP: "10.1.105.194"},
+		{name: "no lease", clientIP: "10.1.105.196"},
 		{
-			name: "valid lease file with target IP",
-			leaseContent: `1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
-1750802648 9c:6b:00:70:59:8a 10.1.105.194 * *`,
-			targetIP:    "10.1.105.195",
-			expectedMAC: "9c:6b:00:70:59:8b",
-		},
-		{
-			name: "valid lease file with different target IP",
-			leaseContent: `1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
-1750802648 9c:6b:00:70:59:8a 10.1.105.194 * *`,
-			targetIP:    "10.1.105.194",
-			expectedMAC: "9c:6b:00:70:59:8a",
-		},
-		{
-			name: "IP not found in lease file",
-			leaseContent: `1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
-1750802648 9c:6b:00:70:59:8a 10.1.105.194 * *`,
-			targetIP:      "10.1.105.196",
-			expectedError: true,
-		},
-		{
-			name:          "empty lease file",
-			leaseContent:  "",
-			targetIP:      "10.1.105.195",
-			expectedError: true,
-		},
-		{
-			name: "malformed lease entries",
-			leaseContent: `invalid line
-1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
-another invalid line`,
-			targetIP:    "10.1.105.195",
-			expectedMAC: "9c:6b:00:70:59:8b",
+			name:     "ironic unavailable",
+			clientIP: "10.1.105.195",
+			err:      errors.New("unavailable"),
+			wantErr:  true,
 		},
 	}
 
+	dhcpLeases := leases.NewFile(leaseFile)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create temporary lease file
-			tmpFile, err := os.CreateTemp("", "dhcp_lease_*.txt")
-			if err != nil {
-				t.Fatalf("Failed to create temp file: %v", err)
-			}
-			defer func() {
-				_ = os.Remove(tmpFile.Name())
-			}()
-
-			// Write test content
-			if _, err := tmpFile.WriteString(tt.leaseContent); err != nil {
-				t.Fatalf("Failed to write to temp file: %v", err)
-			}
-			_ = tmpFile.Close()
-
-			// Test the parsing function
-			mac, err := parseDHCPLeaseFile(tmpFile.Name(), tt.targetIP)
-
-			if tt.expectedError {
+			source := mock.NewNodeSource(nodes.Node{UUID: "node-1"})
+			source.AddPort(ports.Port{
+				UUID:     "port-1",
+				Address:  "9C:6B:00:70:59:8B",
+				NodeUUID: "node-1",
+			})
+			source.SetError(tt.err)
+			handler := &Handler{Nodes: source}
+
+			node, err := handler.lookupNodeByMAC(context.Background(), dhcpLeases, tt.clientIP)
+			if tt.wantErr {
 				if err == nil {
-					t.Errorf("Expected error but got none")
+					t.Error("Expected error but got none")
 				}
 				return
 			}
-
 			if err != nil {
-				t.Errorf("Unexpected error: %v", err)
-				return
+				t.Fatalf("Unexpected error: %v", err)
 			}
 
-			if mac != tt.expectedMAC {
-				t.Errorf("Expected MAC %s, got %s", tt.expectedMAC, mac)
+			var have string
+			if node != nil {
+				have = node.UUID
+			}
+			if have != tt.wantNode {
+				t.Errorf("Expected node %q, got %q", tt.wantNode, have)
 			}
 		})
 	}
 }
-
-func TestLookupNodeByMAC(t *testing.T) {
-	// Create a mock handler (this would need a proper mock client for full testing)
-	handler := &Handler{
-		Clients: &client.Clients{},
-	}
-
-	// Create temporary lease file
-	tmpFile, err := os.CreateTemp("", "dhcp_lease_*.txt")
-	if err != nil {
-		t.Fatalf("Failed to create temp file: %v", err)
-	}
-	defer func() {
-		_ = os.Remove(tmpFile.Name())
-	}()
-
-	leaseContent := `1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
-1750802648 9c:6b:00:70:59:8a 10.1.105.194 * *`
-
-	if _, err := tmpFile.WriteString(leaseContent); err != nil {
-		t.Fatalf("Failed to write to temp file: %v", err)
-	}
-	_ = tmpFile.Close()
-
-	// Note: This test would require mocking the Ironic client to fully test
-	// For now, we just test that the DHCP parsing part works
-	mac, err := parseDHCPLeaseFile(tmpFile.Name(), "10.1.105.195")
-	if err != nil {
-		t.Errorf("Unexpected error parsing DHCP lease: %v", err)
-		return
-	}
-
-	expectedMAC := "9c:6b:00:70:59:8b"
-	if mac != expectedMAC {
-		t.Errorf("Expected MAC %s, got %s", expectedMAC, mac)
-	}
-
-	// Test with context timeout (would need proper mocking for full test)
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-
-	// This would fail because we don't have a real Ironic client
-	_, err = handler.lookupNodeByMAC(ctx, "10.1.105.195")
-	if err == nil {
-		t.Error("Expected error due to missing Ironic client, but got none")
-	}
-}
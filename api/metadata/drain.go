@@ -0,0 +1,192 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDrainTimeout bounds how long a drain waits for in-flight requests
+// by default.
+const defaultDrainTimeout = 30 * time.Second
+
+// drainPollInterval is how often a drain checks for in-flight requests.
+const drainPollInterval = 10 * time.Millisecond
+
+// maxDrainRequestSize bounds the body of drain requests.
+const maxDrainRequestSize = 1 << 10
+
+// drainState tracks in-flight metadata requests and whether the replica is
+// draining.
+type drainState struct {
+	draining atomic.Bool
+	inFlight atomic.Int64
+}
+
+// DrainRequest starts draining the replica.
+type DrainRequest struct {
+	// Timeout bounds how long in-flight requests are waited for, such as
+	// 30s, defaulting to 30 seconds.
+	Timeout string `json:"timeout,omitempty"`
+
+	// StopSync makes the replica give up leadership, so another replica
+	// takes over write-back features and background syncs. It is refused
+	// without leader election.
+	StopSync bool `json:"stop_sync,omitempty"`
+}
+
+// DrainStatus describes the drain state of a replica.
+type DrainStatus struct {
+	Draining    bool  `json:"draining"`
+	InFlight    int64 `json:"in_flight"`
+	Drained     bool  `json:"drained"`
+	SyncStopped bool  `json:"sync_stopped"`
+}
+
+// drainStatus returns the drain state of the replica.
+func (h *Handler) drainStatus() DrainStatus {
+	inFlight := h.drain.inFlight.Load()
+	draining := h.drain.draining.Load()
+	return DrainStatus{
+		Draining:    draining,
+		InFlight:    inFlight,
+		Drained:     draining && inFlight == 0,
+		SyncStopped: h.Leader != nil && h.Leader.Paused(),
+	}
+}
+
+// inFlightMiddleware counts the metadata requests being served, which a
+// drain waits for. Admin and health requests are not counted.
+func (h *Handler) inFlightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil &&
+				(strings.HasPrefix(tpl, "/admin/") || tpl == "/healthz" || tpl == "/readyz") {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		h.drain.inFlight.Add(1)
+		defer h.drain.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// healthRoutes registers the liveness and readiness probes.
+func (h *Handler) healthRoutes(r *mux.Router) {
+	r.HandleFunc("/healthz", h.handleHealthz).Methods("GET")
+	r.HandleFunc("/readyz", h.handleReadyz).Methods("GET")
+}
+
+// handleHealthz handles GET requests to /healthz, which succeed while the
+// process serves requests.
+func (h *Handler) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	h.writeTextResponse(w, "ok")
+}
+
+// handleReadyz handles GET requests to /readyz, which fail while the replica
+// is draining so that load balancers take it out of rotation, while its
+// caches are warmed at startup, and while Ironic is down after waiting for it
+// timed out.
+func (h *Handler) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	if h.drain.draining.Load() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	if h.warming.Load() {
+		http.Error(w, "warming", http.StatusServiceUnavailable)
+		return
+	}
+	if h.Clients != nil && h.Clients.Down() {
+		http.Error(w, "ironic unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	h.writeTextResponse(w, "ok")
+}
+
+// handleDrainStatus handles GET requests to /admin/drain.
+func (h *Handler) handleDrainStatus(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSONResponse(w, h.drainStatus())
+}
+
+// handleDrain handles POST requests to /admin/drain. It fails readiness,
+// optionally gives up leadership, and waits for in-flight requests before
+// responding with the drain state.
+func (h *Handler) handleDrain(w http.ResponseWriter, r *http.Request) {
+	var req DrainRequest
+	body := http.MaxBytesReader(w, r.Body, maxDrainRequestSize)
+	if err := json.NewDecoder(body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	timeout := defaultDrainTimeout
+	if req.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(req.Timeout)
+		if err != nil || timeout < 0 {
+			http.Error(w, "Invalid timeout", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Without leader election, every replica writes to Ironic and runs the
+	// background syncs, and there is no leadership to hand over.
+	if req.StopSync && h.Leader == nil {
+		http.Error(w, "stop_sync requires leader election", http.StatusConflict)
+		return
+	}
+
+	h.drain.draining.Store(true)
+	if req.StopSync {
+		h.Leader.Pause()
+	}
+	h.logger().Info().
+		Bool("stop_sync", req.StopSync).
+		Dur("timeout", timeout).
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Draining")
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for h.drain.inFlight.Load() > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	status := h.drainStatus()
+	event := h.logger().Info()
+	if !status.Drained {
+		event = h.logger().Warn()
+	}
+	event.
+		Int64("in_flight", status.InFlight).
+		Bool("drained", status.Drained).
+		Msg("Finished waiting for in-flight requests")
+
+	h.writeJSONResponse(w, status)
+}
+
+// handleUndrain handles DELETE requests to /admin/drain, returning the
+// replica to rotation and resuming leader election.
+func (h *Handler) handleUndrain(w http.ResponseWriter, r *http.Request) {
+	h.drain.draining.Store(false)
+	if h.Leader != nil {
+		h.Leader.Resume()
+	}
+	h.logger().Info().
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Stopped draining")
+
+	h.writeJSONResponse(w, h.drainStatus())
+}
@@ -0,0 +1,160 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/leader"
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/fakeironic"
+)
+
+func TestHandleDrain(t *testing.T) {
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	changed := make(chan bool, 4)
+	elector, err := leader.New(leader.Config{
+		Lock:     staticLock(true),
+		Identity: "replica-0",
+		OnChange: func(leader bool) { changed <- leader },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go elector.Run(ctx)
+	<-changed
+	h.Leader = elector
+	routes := h.Routes()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		routes.ServeHTTP(rr, req)
+		return rr
+	}
+	drain := func(method, body string) DrainStatus {
+		t.Helper()
+		rr := serve(method, "/admin/drain", body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s /admin/drain: expected status 200, got %d", method, rr.Code)
+		}
+		var status DrainStatus
+		if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return status
+	}
+
+	if rr := serve("GET", "/readyz", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected ready before draining, got %d", rr.Code)
+	}
+
+	// A request still in flight outlasts the timeout.
+	h.drain.inFlight.Add(1)
+	have := drain("POST", `{"timeout": "20ms", "stop_sync": true}`)
+	want := DrainStatus{Draining: true, InFlight: 1, SyncStopped: true}
+	if have != want {
+		t.Errorf("have %+v, want %+v", have, want)
+	}
+	if rr := serve("GET", "/readyz", ""); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready while draining, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/healthz", ""); rr.Code != http.StatusOK {
+		t.Errorf("expected healthy while draining, got %d", rr.Code)
+	}
+	if <-changed {
+		t.Error("expected to give up leadership")
+	}
+
+	h.drain.inFlight.Add(-1)
+	have = drain("POST", "")
+	want = DrainStatus{Draining: true, Drained: true, SyncStopped: true}
+	if have != want {
+		t.Errorf("have %+v, want %+v", have, want)
+	}
+
+	if have := drain("DELETE", ""); have != (DrainStatus{}) {
+		t.Errorf("expected drain to stop, have %+v", have)
+	}
+	if rr := serve("GET", "/readyz", ""); rr.Code != http.StatusOK {
+		t.Errorf("expected ready after draining, got %d", rr.Code)
+	}
+	if !<-changed {
+		t.Error("expected to become leader again")
+	}
+
+	if rr := serve("POST", "/admin/drain", `{"timeout": "soon"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid timeout, got %d", rr.Code)
+	}
+}
+
+func TestHandleDrain_withoutLeaderElection(t *testing.T) {
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	routes := h.Routes()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/admin/drain", strings.NewReader(`{"stop_sync": true}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	routes.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("have status %d, want %d", rr.Code, http.StatusConflict)
+	}
+	if have := h.drainStatus(); have != (DrainStatus{}) {
+		t.Errorf("have %+v, want the replica left in rotation", have)
+	}
+}
+
+func TestInFlightMiddleware(t *testing.T) {
+	h := createTestHandler()
+	var during int64
+	next := h.inFlightMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		during = h.drain.inFlight.Load()
+	}))
+	next.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/openstack", nil))
+
+	if during != 1 {
+		t.Errorf("have %d requests in flight while serving, want 1", during)
+	}
+	if have := h.drain.inFlight.Load(); have != 0 {
+		t.Errorf("have %d requests in flight after serving, want 0", have)
+	}
+}
+
+func TestHandleReadyz_ironicDown(t *testing.T) {
+	srv := fakeironic.New(t)
+	srv.SetFailure(http.StatusServiceUnavailable)
+	clients, err := client.NewClients(client.Options{
+		Ironic:       srv.ServiceClient(),
+		WaitTimeout:  30 * time.Millisecond,
+		PollInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	routes := NewHandler(WithClients(clients)).Routes()
+	readyz := func() int {
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+		return rr.Code
+	}
+
+	// Not having waited for Ironic yet is no reason to fail readiness.
+	if have := readyz(); have != http.StatusOK {
+		t.Fatalf("expected ready before waiting for Ironic, got %d", have)
+	}
+	if _, err := clients.IronicClient(t.Context()); err == nil {
+		t.Fatal("expected error while Ironic is down")
+	}
+	if have := readyz(); have != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready while Ironic is down, got %d", have)
+	}
+}
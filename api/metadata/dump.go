@@ -0,0 +1,139 @@
+package metadata
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// FindNode returns the node identified by ident: an IP address, resolved
+// like the address of a request, the MAC address of one of its ports, or
+// its UUID or name.
+func (h *Handler) FindNode(ctx context.Context, ident string) (*nodes.Node, error) {
+	if addr, err := netip.ParseAddr(ident); err == nil {
+		return h.getNodeByIP(ctx, addr.String())
+	}
+	if mac, err := net.ParseMAC(ident); err == nil && len(mac) == 6 {
+		node, err := h.getNodeByMACAddress(ctx, mac.String())
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nil, fmt.Errorf("no node found for MAC address %s", mac)
+		}
+		return node, nil
+	}
+	return h.nodeSource().GetNode(ctx, ident)
+}
+
+// DumpFiles renders every document of a node for clientIP, by default the
+// first address of the node, as files laid out like a configdrive:
+// openstack/latest and openstack/content, with the EC2 tree under
+// ec2/latest. JSON documents are indented so dumps diff well. Documents
+// that fail to render are left out and reported in the error returned with
+// the files of the others.
+func (h *Handler) DumpFiles(
+	ctx context.Context,
+	node *nodes.Node,
+	clientIP string,
+) (map[string][]byte, error) {
+	if clientIP == "" {
+		clientIP = h.defaultClientIP(node)
+	}
+	preview := h.preview(ctx, node, clientIP)
+
+	files := make(map[string][]byte)
+	var errs []error
+	add := func(name string, v any) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to marshal %s: %w", name, err))
+			return
+		}
+		files[name] = append(data, '\n')
+	}
+
+	if preview.MetaData != nil {
+		add("openstack/latest/meta_data.json", preview.MetaData)
+	}
+	add("openstack/latest/network_data.json", preview.NetworkData)
+	if preview.VendorData != nil {
+		add("openstack/latest/vendor_data.json", preview.VendorData)
+	}
+	if preview.VendorData2 != nil {
+		add("openstack/latest/vendor_data2.json", preview.VendorData2)
+	}
+	if preview.UserData != nil {
+		userData := []byte(*preview.UserData)
+		if preview.UserDataEncoding == "base64" {
+			userData, _ = base64.StdEncoding.DecodeString(*preview.UserData)
+		}
+		files["openstack/latest/user_data"] = userData
+		files["ec2/latest/user-data"] = userData
+	}
+
+	for _, file := range h.injectedFiles(node) {
+		contents := file.contents
+		if contents == nil {
+			var err error
+			if contents, err = h.readContent(node, file.id); err != nil {
+				errs = append(errs, fmt.Errorf("failed to read injected file %s: %w",
+					file.path, err))
+				continue
+			}
+		}
+		files["openstack/content/"+file.id] = contents
+	}
+
+	metaData := make(map[string]any)
+	for path, value := range preview.EC2 {
+		if name, ok := strings.CutPrefix(path, "meta-data/"); ok {
+			setPath(metaData, strings.Split(name, "/"), value)
+		} else {
+			add("ec2/latest/"+path, value)
+		}
+	}
+	add("ec2/latest/meta-data.json", metaData)
+
+	documents := make([]string, 0, len(preview.Errors))
+	for document := range preview.Errors {
+		documents = append(documents, document)
+	}
+	sort.Strings(documents)
+	for _, document := range documents {
+		errs = append(errs, fmt.Errorf("failed to render %s: %s", document,
+			preview.Errors[document]))
+	}
+	return files, errors.Join(errs...)
+}
+
+// defaultClientIP returns the address documents of a node are rendered for
+// when none is given, the first address of the node.
+func (h *Handler) defaultClientIP(node *nodes.Node) string {
+	if ips := h.nodeIPs(node); len(ips) > 0 {
+		return ips[0].ip
+	}
+	return ""
+}
+
+// setPath sets the value at a path of nested maps, creating the maps
+// along it.
+func setPath(tree map[string]any, path []string, value any) {
+	for _, name := range path[:len(path)-1] {
+		child, ok := tree[name].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			tree[name] = child
+		}
+		tree = child
+	}
+	tree[path[len(path)-1]] = value
+}
@@ -0,0 +1,108 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestHandler_FindNode(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{
+		UUID: "node-1",
+		Name: "web01",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	})
+	source.AddPort(ports.Port{UUID: "port-1", NodeUUID: "node-1", Address: "52:54:00:12:34:56"})
+	h := createTestHandler()
+	h.Nodes = source
+	h.Resolvers = []client.Resolver{ipResolver{h: h}}
+
+	tests := []struct {
+		name    string
+		have    string
+		wantErr bool
+	}{
+		{name: "uuid", have: "node-1"},
+		{name: "name", have: "web01"},
+		{name: "ip", have: "10.0.0.5"},
+		{name: "mac", have: "52:54:00:12:34:56"},
+		{name: "mac with dashes", have: "52-54-00-12-34-56"},
+		{name: "unknown ip", have: "10.0.0.9", wantErr: true},
+		{name: "unknown mac", have: "52:54:00:ff:ff:ff", wantErr: true},
+		{name: "unknown name", have: "db01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := h.FindNode(context.Background(), tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if node.UUID != "node-1" {
+				t.Errorf("have node %s, want node-1", node.UUID)
+			}
+		})
+	}
+}
+
+func TestHandler_DumpFiles(t *testing.T) {
+	const key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5QQ== alice@example.com"
+	node := &nodes.Node{
+		UUID: "node-1",
+		Name: "web01",
+		InstanceInfo: map[string]any{
+			"fixed_ips":     []any{map[string]any{"ip_address": "10.0.0.5"}},
+			"user_data":     "#cloud-config\nhostname: web01\n",
+			publicKeysField: map[string]any{"alice": key},
+		},
+	}
+	h := createTestHandler()
+
+	files, err := h.DumpFiles(context.Background(), node, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{
+		"openstack/latest/meta_data.json",
+		"openstack/latest/network_data.json",
+		"openstack/latest/vendor_data.json",
+		"openstack/latest/vendor_data2.json",
+		"ec2/latest/dynamic/instance-identity/document",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected %s", name)
+		}
+	}
+	for _, name := range []string{"openstack/latest/user_data", "ec2/latest/user-data"} {
+		if have := string(files[name]); have != "#cloud-config\nhostname: web01\n" {
+			t.Errorf("have %s %q, want the user data", name, have)
+		}
+	}
+
+	var metaData struct {
+		InstanceID string `json:"instance-id"`
+		LocalIPv4  string `json:"local-ipv4"`
+		PublicKeys map[string]struct {
+			OpenSSHKey string `json:"openssh-key"`
+		} `json:"public-keys"`
+	}
+	if err := json.Unmarshal(files["ec2/latest/meta-data.json"], &metaData); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metaData.InstanceID != "node-1" || metaData.LocalIPv4 != "10.0.0.5" ||
+		metaData.PublicKeys["0"].OpenSSHKey != key {
+		t.Errorf("have EC2 meta-data %+v, want the node's", metaData)
+	}
+}
@@ -0,0 +1,183 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/fakeironic"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+// TestEndToEnd serves metadata requests through the Ironic clients against a
+// fake Ironic API.
+func TestEndToEnd(t *testing.T) {
+	ironic := fakeironic.New(t)
+	ironic.SetPageSize(1)
+	ironic.AddNode(nodes.Node{
+		UUID: "1be26c0b-03f2-4d2e-ae87-c02d7f33c123",
+		Name: "web01",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	})
+	ironic.AddNode(nodes.Node{UUID: "5f0e3b2a-8d7c-4c1e-9a6b-2e4f1d3c5b7a", Name: "web02"})
+	ironic.AddPort(ports.Port{
+		UUID:     "port-web02",
+		Address:  "52:54:00:00:00:02",
+		NodeUUID: "5f0e3b2a-8d7c-4c1e-9a6b-2e4f1d3c5b7a",
+	})
+
+	leaseFile := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	lease := "1750802648 52:54:00:00:00:02 10.0.0.6 web02 *\n"
+	if err := os.WriteFile(leaseFile, []byte(lease), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	h.Clients.SetIronicClient(ironic.ServiceClient())
+	h.Resolvers = []client.Resolver{
+		ipResolver{h: h},
+		leaseResolver{h: h, leases: leases.NewFile(leaseFile)},
+	}
+	routes := h.Routes()
+
+	tests := []struct {
+		name       string
+		clientIP   string
+		wantStatus int
+		wantName   string
+	}{
+		{name: "by IP", clientIP: "10.0.0.5", wantStatus: http.StatusOK, wantName: "web01"},
+		{name: "by lease", clientIP: "10.0.0.6", wantStatus: http.StatusOK, wantName: "web02"},
+		{name: "unknown client", clientIP: "10.0.0.7", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/openstack/latest/meta_data.json", nil)
+			req.RemoteAddr = tt.clientIP + ":40000"
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status: have %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var have struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have.Name != tt.wantName {
+				t.Errorf("have node %q, want %q", have.Name, tt.wantName)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/nodes/web01/user_data",
+		strings.NewReader("#!/bin/sh\necho hi\n"))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: have %d, want 200: %s", rr.Code, rr.Body)
+	}
+	node, _ := ironic.Node("web01")
+	if have := node.InstanceInfo["user_data"]; have != "#!/bin/sh\necho hi\n" {
+		t.Errorf("have user_data %v, want the uploaded script", have)
+	}
+}
+
+// TestEndToEnd_staticSource serves metadata requests from a static
+// directory, without Ironic.
+func TestEndToEnd_staticSource(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"web01/openstack/latest/meta_data.json": `{"uuid": "node-1", "name": "web01"}`,
+		"web01/openstack/latest/network_data.json": `{"links": [{"id": "eth0", ` +
+			`"type": "phy", "ethernet_mac_address": "52:54:00:00:00:01"}], "networks": [` +
+			`{"id": "net0", "type": "ipv4", "link": "eth0", "ip_address": "10.0.0.5", ` +
+			`"netmask": "255.255.255.0"}]}`,
+		"web01/openstack/latest/user_data":     "#cloud-config\nhostname: web01\n",
+		"db01/openstack/latest/meta_data.json": `{"uuid": "node-2", "name": "db01"}`,
+		"mappings.csv":                         "ip,mac_address,node_name\n,52:54:00:00:00:02,db01\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	leaseFile := filepath.Join(root, "dnsmasq.leases")
+	lease := "4102444800 52:54:00:00:00:02 10.0.0.6 db01 *\n"
+	if err := os.WriteFile(leaseFile, []byte(lease), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h := NewHandler(
+		WithNodeSource(client.NewStaticSource(root)),
+		WithDHCPLeases(leases.NewFile(leaseFile)),
+	)
+	routes := h.Routes()
+
+	tests := []struct {
+		name     string
+		clientIP string
+		path     string
+		want     string
+	}{
+		{
+			name:     "meta data by network data",
+			clientIP: "10.0.0.5",
+			path:     "/openstack/latest/meta_data.json",
+			want:     `"uuid":"node-1"`,
+		},
+		{
+			name:     "network data",
+			clientIP: "10.0.0.5",
+			path:     "/openstack/latest/network_data.json",
+			want:     `"ip_address":"10.0.0.5"`,
+		},
+		{
+			name:     "user data",
+			clientIP: "10.0.0.5",
+			path:     "/openstack/latest/user_data",
+			want:     "hostname: web01",
+		},
+		{
+			name:     "meta data by mapped MAC address",
+			clientIP: "10.0.0.6",
+			path:     "/openstack/latest/meta_data.json",
+			want:     `"uuid":"node-2"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.clientIP + ":40000"
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status: have %d, want 200: %s", rr.Code, rr.Body)
+			}
+			if !strings.Contains(rr.Body.String(), tt.want) {
+				t.Errorf("expected %q in %s", tt.want, rr.Body)
+			}
+		})
+	}
+}
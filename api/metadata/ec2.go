@@ -0,0 +1,210 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/identity"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// handleEC2PublicKeys handles requests to /latest/meta-data/public-keys/,
+// listing the node's keys as "<index>=<name>" entries.
+func (h *Handler) handleEC2PublicKeys(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "ec2_public_keys")
+	if !ok {
+		return
+	}
+
+	names := sortedKeyNames(h.buildMetaData(node).PublicKeys)
+	if len(names) == 0 {
+		http.Error(w, "Public keys not found", http.StatusNotFound)
+		return
+	}
+
+	entries := make([]string, 0, len(names))
+	for i, name := range names {
+		entries = append(entries, fmt.Sprintf("%d=%s", i, name))
+	}
+	h.writeTextResponse(w, strings.Join(entries, "\n"))
+}
+
+// handleEC2PublicKey handles requests to /latest/meta-data/public-keys/{index}/,
+// listing the formats the key is available in.
+func (h *Handler) handleEC2PublicKey(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.ec2PublicKey(w, r); !ok {
+		return
+	}
+	h.writeTextResponse(w, "openssh-key")
+}
+
+// handleEC2OpenSSHKey handles requests to
+// /latest/meta-data/public-keys/{index}/openssh-key.
+func (h *Handler) handleEC2OpenSSHKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.ec2PublicKey(w, r)
+	if !ok {
+		return
+	}
+	h.writeTextResponse(w, key+"\n")
+}
+
+// ec2PublicKey returns the key material at the index in the request path.
+// When the key does not exist an error response is written and ok is false.
+func (h *Handler) ec2PublicKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil {
+		http.Error(w, "Invalid public key index", http.StatusBadRequest)
+		return "", false
+	}
+
+	node, _, ok := h.nodeForRequest(w, r, "ec2_public_keys")
+	if !ok {
+		return "", false
+	}
+
+	keys := sortedPublicKeys(h.buildMetaData(node).PublicKeys)
+	if index < 0 || index >= len(keys) {
+		h.logger().Debug().
+			Str("node_uuid", node.UUID).
+			Int("index", index).
+			Int("key_count", len(keys)).
+			Msg("Public key index out of range")
+		http.Error(w, "Public key not found", http.StatusNotFound)
+		return "", false
+	}
+	return keys[index], true
+}
+
+// handleEC2Dynamic handles requests to /latest/dynamic/.
+func (h *Handler) handleEC2Dynamic(w http.ResponseWriter, r *http.Request) {
+	h.writeTextResponse(w, "instance-identity/")
+}
+
+// handleEC2InstanceIdentity handles requests to
+// /latest/dynamic/instance-identity/.
+func (h *Handler) handleEC2InstanceIdentity(w http.ResponseWriter, r *http.Request) {
+	entries := []string{"document"}
+	if h.Identity != nil {
+		entries = append(entries, "signature")
+		if h.Identity.HasCertificate() {
+			entries = append(entries, "pkcs7")
+		}
+	}
+	h.writeTextResponse(w, strings.Join(entries, "\n"))
+}
+
+// handleEC2IdentityDocument handles requests to
+// /latest/dynamic/instance-identity/document.
+func (h *Handler) handleEC2IdentityDocument(w http.ResponseWriter, r *http.Request) {
+	document, ok := h.identityDocument(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(document); err != nil {
+		h.logger().Error().Err(err).Msg("Failed to write identity document response")
+	}
+}
+
+// handleEC2IdentitySignature handles requests to
+// /latest/dynamic/instance-identity/signature.
+func (h *Handler) handleEC2IdentitySignature(w http.ResponseWriter, r *http.Request) {
+	if h.Identity == nil {
+		http.Error(w, "Instance identity signing not configured", http.StatusNotFound)
+		return
+	}
+
+	document, ok := h.identityDocument(w, r)
+	if !ok {
+		return
+	}
+
+	signature, err := h.Identity.Sign(document)
+	if err != nil {
+		h.logger().Error().Err(err).Msg("Failed to sign identity document")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.writeTextResponse(w, base64.StdEncoding.EncodeToString(signature))
+}
+
+// handleEC2IdentityPKCS7 handles requests to
+// /latest/dynamic/instance-identity/pkcs7.
+func (h *Handler) handleEC2IdentityPKCS7(w http.ResponseWriter, r *http.Request) {
+	if h.Identity == nil || !h.Identity.HasCertificate() {
+		http.Error(w, "Instance identity certificate not configured", http.StatusNotFound)
+		return
+	}
+
+	document, ok := h.identityDocument(w, r)
+	if !ok {
+		return
+	}
+
+	der, err := h.Identity.PKCS7(document)
+	if err != nil {
+		h.logger().Error().Err(err).Msg("Failed to build PKCS7 identity signature")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// EC2 serves the PKCS7 structure as bare base64 wrapped at 64 columns.
+	encoded := base64.StdEncoding.EncodeToString(der)
+	var lines []string
+	for len(encoded) > 64 {
+		lines = append(lines, encoded[:64])
+		encoded = encoded[64:]
+	}
+	lines = append(lines, encoded)
+	h.writeTextResponse(w, strings.Join(lines, "\n"))
+}
+
+// identityDocument renders the identity document of the requesting node.
+func (h *Handler) identityDocument(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	node, clientIP, ok := h.nodeForRequest(w, r, "ec2_instance_identity")
+	if !ok {
+		return nil, false
+	}
+
+	b, err := json.MarshalIndent(h.buildIdentityDocument(node, clientIP), "", "  ")
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to marshal identity document")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return b, true
+}
+
+// buildIdentityDocument maps node data onto an EC2 instance identity
+// document.
+func (h *Handler) buildIdentityDocument(node *nodes.Node, clientIP string) *identity.Document {
+	pendingTime := node.ProvisionUpdatedAt
+	if pendingTime.IsZero() {
+		pendingTime = node.CreatedAt
+	}
+
+	architecture, _ := node.Properties["cpu_arch"].(string)
+	availabilityZone, _ := node.InstanceInfo["availability_zone"].(string)
+	imageID, _ := node.InstanceInfo["image_source"].(string)
+
+	return &identity.Document{
+		AccountID:        h.projectID(node),
+		Architecture:     architecture,
+		AvailabilityZone: availabilityZone,
+		ImageID:          imageID,
+		InstanceID:       node.UUID,
+		InstanceType:     node.ResourceClass,
+		PendingTime:      pendingTime.UTC(),
+		PrivateIP:        clientIP,
+		Region:           h.Region,
+		Version:          identity.DocumentVersion,
+	}
+}
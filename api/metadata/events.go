@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"net/http"
+
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+)
+
+// endpointEvents maps the endpoints that send events once a node is
+// answered to the type of their events.
+var endpointEvents = map[string]string{
+	"user_data":         events.UserData,
+	"nocloud_user_data": events.UserData,
+	"ignition":          events.UserData,
+	"phone_home":        events.PhoneHome,
+}
+
+// eventMiddleware sends an event to the EventSinks once a node has been
+// answered on an endpoint of endpointEvents, with the status it was
+// answered with, or once no node was found for a client. It runs outside
+// the policy and conditional requests, so events report what clients were
+// sent.
+func (h *Handler) eventMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(h.EventSinks) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		subject, r := withPolicySubject(r)
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		var event events.Event
+		switch eventType, ok := endpointEvents[subject.endpoint]; {
+		case subject.node != nil && ok:
+			event = events.New(eventType)
+			event.NodeUUID = subject.node.UUID
+			event.NodeName = subject.node.Name
+		case subject.node == nil && subject.endpoint != "":
+			event = events.New(events.LookupFailure)
+		default:
+			return
+		}
+		event.Endpoint = subject.endpoint
+		event.ClientIP, _ = getClientIPFromContext(r)
+		event.Status = rw.statusCode
+		h.sendEvent(event)
+	})
+}
+
+// sendEvent queues an event for the EventSinks that want it.
+func (h *Handler) sendEvent(event events.Event) {
+	for _, sink := range h.EventSinks {
+		if !sink.Wants(event.Type) {
+			continue
+		}
+		queued := sink.Notify(event)
+		h.Metrics.observeEvent(sink.Name(), event.Type, queued)
+		if !queued {
+			h.logger().Warn().
+				Str("client_ip", event.ClientIP).
+				Str("node_uuid", event.NodeUUID).
+				Str("event", event.Type).
+				Str("sink", sink.Name()).
+				Msg("Event queue is full, dropping event")
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/appkins-org/ironic-metadata/pkg/webhook"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_events(t *testing.T) {
+	received := make(chan events.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event events.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		received <- event
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		remoteAddr string
+		userData   string
+		wantEvent  string
+		wantStatus int
+	}{
+		{
+			name:       "user data",
+			method:     http.MethodGet,
+			path:       "/openstack/latest/user_data",
+			userData:   "#cloud-config\n",
+			wantEvent:  events.UserData,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "no user data",
+			method:     http.MethodGet,
+			path:       "/openstack/latest/user_data",
+			wantEvent:  events.UserData,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "nocloud user data",
+			method:     http.MethodGet,
+			path:       "/nocloud/user-data",
+			userData:   "#cloud-config\n",
+			wantEvent:  events.UserData,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "phone home",
+			method:     http.MethodPost,
+			path:       "/phone_home",
+			wantEvent:  events.PhoneHome,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "lookup failure",
+			method:     http.MethodGet,
+			path:       "/openstack/latest/meta_data.json",
+			remoteAddr: "10.0.0.9:4321",
+			wantEvent:  events.LookupFailure,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "meta data",
+			method: http.MethodGet,
+			path:   "/openstack/latest/meta_data.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, err := webhook.New(webhook.Config{URLs: []string{srv.URL}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go notifier.Run(ctx)
+
+			node := nodes.Node{
+				UUID:           "node-1",
+				Name:           "web01",
+				ProvisionState: "active",
+				InstanceInfo: map[string]any{
+					"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+				},
+			}
+			if tt.userData != "" {
+				node.InstanceInfo["user_data"] = tt.userData
+			}
+			registry := metrics.NewRegistry()
+			h := NewHandler(WithNodeSource(mock.NewNodeSource(node)), WithEventSinks(notifier))
+			h.EnableMetrics(registry)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if tt.wantEvent == "" {
+				select {
+				case event := <-received:
+					t.Fatalf("have event %+v, want none", event)
+				case <-time.After(50 * time.Millisecond):
+				}
+				return
+			}
+
+			var event events.Event
+			select {
+			case event = <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected an event to be delivered")
+			}
+			if event.Type != tt.wantEvent || event.Status != tt.wantStatus {
+				t.Errorf("have event %s with status %d, want %s with status %d",
+					event.Type, event.Status, tt.wantEvent, tt.wantStatus)
+			}
+			wantNode, wantClient := "node-1", "10.0.0.5"
+			if tt.wantEvent == events.LookupFailure {
+				wantNode, wantClient = "", "10.0.0.9"
+			}
+			if event.NodeUUID != wantNode || event.ClientIP != wantClient || event.ID == "" {
+				t.Errorf("have event %+v, want node %q and client %q",
+					event, wantNode, wantClient)
+			}
+
+			var b strings.Builder
+			if _, err := registry.WriteTo(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := `ironic_metadata_events_total{result="queued",sink="webhook",type="` +
+				tt.wantEvent + `"} 1`
+			if !strings.Contains(b.String(), want+"\n") {
+				t.Errorf("expected %q in\n%s", want, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Scopes of what stops being served once ActiveExpiry has passed.
+const (
+	// ExpiryUserData stops serving user data, while meta_data.json, network
+	// data and the other documents are still served.
+	ExpiryUserData = "user_data"
+
+	// ExpiryAll stops serving every document of the node.
+	ExpiryAll = "all"
+)
+
+// ExpiryScopes lists the scopes of ActiveExpiry.
+var ExpiryScopes = []string{ExpiryUserData, ExpiryAll}
+
+// ParseExpiryScope parses a scope of ActiveExpiry, where empty means
+// ExpiryUserData.
+func ParseExpiryScope(scope string) (string, error) {
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	if scope == "" {
+		return ExpiryUserData, nil
+	}
+	if !slices.Contains(ExpiryScopes, scope) {
+		return "", fmt.Errorf("unknown expiry scope %q, want one of %s",
+			scope, strings.Join(ExpiryScopes, ", "))
+	}
+	return scope, nil
+}
+
+// activeExpired reports whether ActiveExpiry has passed since a node last
+// became active. Nodes in other provision states never expire.
+func (h *Handler) activeExpired(node *nodes.Node) bool {
+	if h.ActiveExpiry <= 0 || node.ProvisionState != string(nodes.Active) ||
+		node.ProvisionUpdatedAt.IsZero() {
+		return false
+	}
+	return h.now().Sub(node.ProvisionUpdatedAt) >= h.ActiveExpiry
+}
+
+// userDataExpired reports whether the user data of a node is no longer
+// served, logging it when so.
+func (h *Handler) userDataExpired(node *nodes.Node) bool {
+	if !h.activeExpired(node) {
+		return false
+	}
+	h.logger().Info().
+		Str("node_uuid", node.UUID).
+		Time("active_since", node.ProvisionUpdatedAt).
+		Msg("Not serving user data of node active for longer than the expiry")
+	return true
+}
@@ -0,0 +1,170 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestParseExpiryScope(t *testing.T) {
+	tests := []struct {
+		have    string
+		want    string
+		wantErr bool
+	}{
+		{have: "", want: ExpiryUserData},
+		{have: " All ", want: ExpiryAll},
+		{have: "user_data", want: ExpiryUserData},
+		{have: "vendor_data", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.have, func(t *testing.T) {
+			have, err := ParseExpiryScope(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have scope %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_activeExpiry(t *testing.T) {
+	activeSince := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		state string
+		age   time.Duration
+		scope string
+		want  map[string]int
+	}{
+		{
+			name:  "not expired",
+			state: "active",
+			age:   time.Hour,
+			want: map[string]int{
+				"/openstack/latest/user_data":      http.StatusOK,
+				"/openstack/latest/meta_data.json": http.StatusOK,
+			},
+		},
+		{
+			name:  "user data expired",
+			state: "active",
+			age:   24 * time.Hour,
+			want: map[string]int{
+				"/openstack/latest/user_data":      http.StatusNotFound,
+				"/latest/user-data":                http.StatusNotFound,
+				"/openstack/latest/meta_data.json": http.StatusOK,
+			},
+		},
+		{
+			name:  "all expired",
+			state: "active",
+			age:   24 * time.Hour,
+			scope: ExpiryAll,
+			want: map[string]int{
+				"/openstack/latest/user_data":      http.StatusGone,
+				"/openstack/latest/meta_data.json": http.StatusGone,
+			},
+		},
+		{
+			name:  "deploying",
+			state: "wait call-back",
+			age:   24 * time.Hour,
+			scope: ExpiryAll,
+			want: map[string]int{
+				"/openstack/latest/user_data": http.StatusOK,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := nodes.Node{
+				UUID:               "node-1",
+				Name:               "web01",
+				ProvisionState:     tt.state,
+				ProvisionUpdatedAt: activeSince,
+				InstanceInfo:       map[string]any{"user_data": "#cloud-config\n"},
+			}
+			resolver := &mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": &node}}
+			routes := NewHandler(
+				WithNodeSource(mock.NewNodeSource(node)),
+				WithResolvers(resolver),
+				WithActiveExpiry(12*time.Hour, tt.scope),
+				WithClock(func() time.Time { return activeSince.Add(tt.age) }),
+			).Routes()
+
+			for path, want := range tt.want {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.RemoteAddr = "10.0.0.5:4321"
+				rr := httptest.NewRecorder()
+				routes.ServeHTTP(rr, req)
+
+				if rr.Code != want {
+					t.Errorf("%s: have status %d, want %d: %s", path, rr.Code, want, rr.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_activeExpiry_storedIgnition(t *testing.T) {
+	activeSince := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := `{"ignition":{"version":"3.4.0"},` +
+		`"storage":{"files":[{"path":"/etc/token","contents":{"source":"data:,s3cret"}}]}}`
+
+	tests := []struct {
+		name       string
+		age        time.Duration
+		wantSecret bool
+	}{
+		{name: "not expired", age: 30 * time.Minute, wantSecret: true},
+		{name: "expired", age: 48 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := nodes.Node{
+				UUID:               "node-1",
+				Name:               "web01",
+				ProvisionState:     "active",
+				ProvisionUpdatedAt: activeSince,
+				InstanceInfo:       map[string]any{"ignition": stored},
+			}
+			resolver := &mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": &node}}
+			routes := NewHandler(
+				WithNodeSource(mock.NewNodeSource(node)),
+				WithResolvers(resolver),
+				WithActiveExpiry(time.Hour, ExpiryUserData),
+				WithClock(func() time.Time { return activeSince.Add(tt.age) }),
+			).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/ignition/3.4.0/config.ign", nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			if have := strings.Contains(rr.Body.String(), "s3cret"); have != tt.wantSecret {
+				t.Errorf("have stored config served %t, want %t: %s", have, tt.wantSecret, rr.Body)
+			}
+		})
+	}
+}
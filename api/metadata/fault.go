@@ -0,0 +1,184 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Faults that can be injected into metadata responses.
+const (
+	// FaultDelay holds the response for the delay of the Faults.
+	FaultDelay = "delay"
+
+	// FaultUnavailable answers 503 Service Unavailable instead of the
+	// response.
+	FaultUnavailable = "unavailable"
+
+	// FaultTruncate announces the full length of the response but sends
+	// only half its body before closing the connection.
+	FaultTruncate = "truncate"
+
+	// FaultMalformed sends half the body of the response as if it were all
+	// of it, so JSON documents no longer parse.
+	FaultMalformed = "malformed"
+)
+
+// FaultKinds lists the faults that can be injected.
+var FaultKinds = []string{FaultDelay, FaultUnavailable, FaultTruncate, FaultMalformed}
+
+// DefaultFaultDelay is how long FaultDelay holds a response by default.
+const DefaultFaultDelay = 5 * time.Second
+
+// Faults configures the faults injected into metadata responses, for
+// operators to check that the retries and fallbacks of cloud-init in their
+// images cope with a misbehaving metadata service. Admin, health and
+// OpenAPI routes are never faulted.
+type Faults struct {
+	// Rates maps each fault of FaultKinds to the fraction of requests it
+	// hits, between 0 and 1. Faults hit requests independently: a request
+	// hit by several is delayed, then served the first of unavailable,
+	// truncate and malformed.
+	Rates map[string]float64
+
+	// Delay is how long FaultDelay holds a response, DefaultFaultDelay when
+	// zero.
+	Delay time.Duration
+}
+
+// ParseFaults parses a list of faults and the fraction of requests each
+// hits, such as "delay=0.2,unavailable=0.1", where empty means no fault
+// injection and a nil result.
+func ParseFaults(spec string, delay time.Duration) (*Faults, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	faults := &Faults{Rates: make(map[string]float64), Delay: delay}
+	for _, item := range strings.Split(spec, ",") {
+		kind, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if !slices.Contains(FaultKinds, kind) {
+			return nil, fmt.Errorf("unknown fault %q, want one of %s",
+				kind, strings.Join(FaultKinds, ", "))
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid rate of fault %s: %q, want a fraction from 0 to 1",
+				kind, value)
+		}
+		faults.Rates[kind] = rate
+	}
+	return faults, nil
+}
+
+// hits reports whether a fault hits a request.
+func (f *Faults) hits(kind string) bool {
+	rate := f.Rates[kind]
+	return rate > 0 && rand.Float64() < rate
+}
+
+// delay returns how long FaultDelay holds a response.
+func (f *Faults) delay() time.Duration {
+	if f.Delay > 0 {
+		return f.Delay
+	}
+	return DefaultFaultDelay
+}
+
+// faultMiddleware injects the faults of the handler into the responses of
+// metadata routes.
+func (h *Handler) faultMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Faults == nil || !faultable(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if h.Faults.hits(FaultDelay) {
+			h.injectFault(r, FaultDelay)
+			select {
+			case <-time.After(h.Faults.delay()):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		fault := ""
+		for _, kind := range []string{FaultUnavailable, FaultTruncate, FaultMalformed} {
+			if h.Faults.hits(kind) {
+				fault = kind
+				break
+			}
+		}
+		if fault == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.injectFault(r, fault)
+		if fault == FaultUnavailable {
+			http.Error(w, "Fault injected", http.StatusServiceUnavailable)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		body := bw.body.Bytes()
+		length := len(body)
+		if fault == FaultMalformed {
+			length /= 2
+		}
+		// The ETag was derived from the whole body.
+		w.Header().Del("ETag")
+		w.Header().Set("Content-Length", strconv.Itoa(length))
+		w.WriteHeader(bw.status)
+		// Stopping short of the announced length makes the server close the
+		// connection, as a crash mid-response would.
+		_, _ = w.Write(body[:len(body)/2])
+	})
+}
+
+// bufferedWriter buffers the body of a response, holding back its status.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	bw.status = status
+}
+
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	return bw.body.Write(p)
+}
+
+// injectFault records a fault injected into the response to r.
+func (h *Handler) injectFault(r *http.Request, kind string) {
+	h.Metrics.observeFault(kind)
+	h.logger().Debug().
+		Str("fault", kind).
+		Str("path", r.URL.Path).
+		Msg("Injecting fault")
+}
+
+// faultable reports whether faults may be injected into the response to r,
+// which serves metadata rather than the admin API, health probes or the
+// OpenAPI document.
+func faultable(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return true
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return true
+	}
+	return !strings.HasPrefix(tpl, "/admin/") && tpl != "/healthz" && tpl != "/readyz" &&
+		tpl != "/openapi.json"
+}
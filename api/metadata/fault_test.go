@@ -0,0 +1,166 @@
+package metadata
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestParseFaults(t *testing.T) {
+	tests := []struct {
+		have    string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{have: ""},
+		{have: "delay=0.2", want: map[string]float64{FaultDelay: 0.2}},
+		{
+			have: " Unavailable = 1 ,malformed=0",
+			want: map[string]float64{FaultUnavailable: 1, FaultMalformed: 0},
+		},
+		{have: "truncate=1.5", wantErr: true},
+		{have: "truncate", wantErr: true},
+		{have: "reset=0.1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.have, func(t *testing.T) {
+			have, err := ParseFaults(tt.have, time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want == nil {
+				if have != nil {
+					t.Errorf("have faults %+v, want none", have)
+				}
+				return
+			}
+			if !maps.Equal(have.Rates, tt.want) || have.Delay != time.Second {
+				t.Errorf("have faults %+v, want rates %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_faults(t *testing.T) {
+	node := nodes.Node{
+		UUID:           "node-1",
+		Name:           "web01",
+		ProvisionState: "active",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		rates      map[string]float64
+		wantStatus int
+		// wantValid is whether the body is a whole JSON document, and
+		// wantLength whether Content-Length matches a body that is not.
+		wantValid  bool
+		wantLength bool
+		wantMetric string
+	}{
+		{
+			name:       "no fault",
+			path:       "/openstack/latest/meta_data.json",
+			rates:      map[string]float64{FaultUnavailable: 0},
+			wantStatus: http.StatusOK,
+			wantValid:  true,
+		},
+		{
+			name:       "delay",
+			path:       "/openstack/latest/meta_data.json",
+			rates:      map[string]float64{FaultDelay: 1},
+			wantStatus: http.StatusOK,
+			wantValid:  true,
+			wantMetric: `ironic_metadata_faults_injected_total{fault="delay"} 1`,
+		},
+		{
+			name:       "unavailable",
+			path:       "/openstack/latest/meta_data.json",
+			rates:      map[string]float64{FaultUnavailable: 1, FaultTruncate: 1},
+			wantStatus: http.StatusServiceUnavailable,
+			wantMetric: `ironic_metadata_faults_injected_total{fault="unavailable"} 1`,
+		},
+		{
+			name:       "truncate",
+			path:       "/openstack/latest/meta_data.json",
+			rates:      map[string]float64{FaultTruncate: 1},
+			wantStatus: http.StatusOK,
+			wantMetric: `ironic_metadata_faults_injected_total{fault="truncate"} 1`,
+		},
+		{
+			name:       "malformed",
+			path:       "/openstack/latest/meta_data.json",
+			rates:      map[string]float64{FaultMalformed: 1},
+			wantStatus: http.StatusOK,
+			wantLength: true,
+			wantMetric: `ironic_metadata_faults_injected_total{fault="malformed"} 1`,
+		},
+		{
+			name:       "health probe",
+			path:       "/healthz",
+			rates:      map[string]float64{FaultUnavailable: 1},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := metrics.NewRegistry()
+			h := NewHandler(
+				WithNodeSource(mock.NewNodeSource(node)),
+				WithFaults(&Faults{Rates: tt.rates, Delay: time.Millisecond}),
+			)
+			h.EnableMetrics(registry)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			var b strings.Builder
+			if _, err := registry.WriteTo(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantMetric != "" && !strings.Contains(b.String(), tt.wantMetric+"\n") {
+				t.Errorf("expected %q in\n%s", tt.wantMetric, b.String())
+			}
+			if rr.Code != http.StatusOK || tt.path == "/healthz" {
+				return
+			}
+
+			if valid := json.Valid(rr.Body.Bytes()); valid != tt.wantValid {
+				t.Errorf("have valid JSON %v, want %v: %s", valid, tt.wantValid, rr.Body)
+			}
+			length := rr.Header().Get("Content-Length")
+			if tt.wantValid {
+				return
+			}
+			if matches := length == strconv.Itoa(rr.Body.Len()); matches != tt.wantLength {
+				t.Errorf("have Content-Length %s for %d bytes", length, rr.Body.Len())
+			}
+		})
+	}
+}
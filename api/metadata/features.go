@@ -0,0 +1,89 @@
+package metadata
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Route families that can be disabled.
+const (
+	// RoutesOpenStack covers the /openstack routes.
+	RoutesOpenStack = "openstack"
+
+	// RoutesDatedVersions covers the dated OpenStack metadata versions,
+	// leaving only latest.
+	RoutesDatedVersions = "dated_versions"
+
+	// RoutesVendorData covers vendor_data.json and vendor_data2.json.
+	RoutesVendorData = "vendor_data"
+
+	// RoutesEC2 covers the EC2-compatible routes.
+	RoutesEC2 = "ec2"
+
+	// RoutesGCE covers the GCE-compatible routes.
+	RoutesGCE = "gce"
+
+	// RoutesNoCloud covers the NoCloud routes.
+	RoutesNoCloud = "nocloud"
+
+	// RoutesNetwork covers the rendered network configuration routes.
+	RoutesNetwork = "network"
+
+	// RoutesIgnition covers the Ignition routes.
+	RoutesIgnition = "ignition"
+
+	// RoutesPhoneHome covers the /phone_home routes.
+	RoutesPhoneHome = "phone_home"
+
+	// RoutesBoot covers the iPXE and GRUB network boot script routes.
+	RoutesBoot = "boot"
+
+	// RoutesAdmin covers the admin API.
+	RoutesAdmin = "admin"
+
+	// RoutesHealth covers the /healthz and /readyz probes.
+	RoutesHealth = "health"
+
+	// RoutesOpenAPI covers the /openapi.json API description.
+	RoutesOpenAPI = "openapi"
+)
+
+// RouteFamilies lists the route families that can be disabled.
+var RouteFamilies = []string{
+	RoutesOpenStack,
+	RoutesDatedVersions,
+	RoutesVendorData,
+	RoutesEC2,
+	RoutesGCE,
+	RoutesNoCloud,
+	RoutesNetwork,
+	RoutesIgnition,
+	RoutesPhoneHome,
+	RoutesBoot,
+	RoutesAdmin,
+	RoutesHealth,
+	RoutesOpenAPI,
+}
+
+// ParseRouteFamilies parses a comma separated list of route families.
+func ParseRouteFamilies(spec string) (map[string]bool, error) {
+	families := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(RouteFamilies, name) {
+			return nil, fmt.Errorf(
+				"unknown route family %q, want one of %s", name, strings.Join(RouteFamilies, ", "))
+		}
+		families[name] = true
+	}
+	return families, nil
+}
+
+// routesEnabled reports whether a route family is served.
+func (h *Handler) routesEnabled(family string) bool {
+	return !h.DisabledRoutes[family]
+}
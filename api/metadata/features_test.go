@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRouteFamilies(t *testing.T) {
+	have, err := ParseRouteFamilies(" ec2, Vendor_Data,,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(have) != 2 || !have[RoutesEC2] || !have[RoutesVendorData] {
+		t.Errorf("have %v, want ec2 and vendor_data", have)
+	}
+
+	if _, err := ParseRouteFamilies("ec2,azure"); err == nil {
+		t.Error("expected error for unknown route family")
+	}
+}
+
+func TestRoutes_disabled(t *testing.T) {
+	h := createTestHandler()
+	h.DisabledRoutes = map[string]bool{
+		RoutesEC2:           true,
+		RoutesVendorData:    true,
+		RoutesDatedVersions: true,
+	}
+	routes := h.Routes()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{path: "/openstack", wantStatus: http.StatusOK, wantBody: "latest"},
+		{
+			path:       "/openstack/latest",
+			wantStatus: http.StatusOK,
+			wantBody:   "meta_data.json\nnetwork_data.json\nuser_data\npassword",
+		},
+		{path: "/openstack/2018-08-27/meta_data.json", wantStatus: http.StatusNotFound},
+		{path: "/openstack/latest/vendor_data2.json", wantStatus: http.StatusNotFound},
+		{path: "/latest/meta-data", wantStatus: http.StatusNotFound},
+		{path: "/", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status: have %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body: have %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
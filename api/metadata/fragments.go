@@ -0,0 +1,97 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/userdata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// combineUserData layers the user data fragments and profile user data of a
+// node before its own user data in a multipart MIME document. User data is
+// returned unchanged when no fragments apply, and Ignition configs, which
+// cannot be combined, are never layered.
+func (h *Handler) combineUserData(node *nodes.Node, userData []byte) ([]byte, error) {
+	if h.UserDataFragmentsDir == "" && len(h.Profiles) == 0 {
+		return userData, nil
+	}
+	if format := userdata.Detect(userData); format == userdata.FormatIgnition ||
+		format == userdata.FormatButane {
+		return userData, nil
+	}
+
+	parts, err := h.userDataFragments(node)
+	if err != nil {
+		return nil, err
+	}
+	parts = append(parts, h.profileUserData(node)...)
+	if len(parts) == 0 {
+		return userData, nil
+	}
+	if len(userData) > 0 {
+		parts = append(parts, userdata.Part{Filename: "user-data", Content: userData})
+	}
+
+	h.logger().Debug().
+		Str("node_uuid", node.UUID).
+		Int("parts", len(parts)).
+		Msg("Combining user data fragments")
+
+	return userdata.Combine(parts)
+}
+
+// userDataFragments reads the fragments applying to a node: the files in
+// <UserDataFragmentsDir>/global, then those in
+// <UserDataFragmentsDir>/resource-class/<resource class>, each in name order.
+func (h *Handler) userDataFragments(node *nodes.Node) ([]userdata.Part, error) {
+	if h.UserDataFragmentsDir == "" {
+		return nil, nil
+	}
+
+	var parts []userdata.Part
+	for _, dir := range layerDirs(node) {
+		entries, err := os.ReadDir(filepath.Join(h.UserDataFragmentsDir, dir))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list user data fragments: %w", err)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+		for _, entry := range entries {
+			// Skip hidden files, which include the bookkeeping entries of
+			// Kubernetes ConfigMap volumes.
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			name := filepath.Join(h.UserDataFragmentsDir, dir, entry.Name())
+			content, err := os.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read user data fragment: %w", err)
+			}
+			parts = append(parts, userdata.Part{
+				Filename: strings.ReplaceAll(filepath.Join(dir, entry.Name()), "/", "-"),
+				Content:  content,
+			})
+		}
+	}
+	return parts, nil
+}
+
+// layerDirs returns the operator config directories applying to a node, in
+// order of precedence: global, then resource-class/<resource class>.
+// Resource classes that are not plain directory names are ignored.
+func layerDirs(node *nodes.Node) []string {
+	dirs := []string{"global"}
+	if class := node.ResourceClass; class != "" && filepath.Base(class) == class &&
+		!strings.HasPrefix(class, ".") {
+		dirs = append(dirs, filepath.Join("resource-class", class))
+	}
+	return dirs
+}
@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/userdata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestRenderUserData_fragments(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"global/10-bootcmd.yaml":         "#cloud-config\nbootcmd: [echo global]\n",
+		"global/.hidden":                 "ignored",
+		"resource-class/gpu/50-drivers":  "#!/bin/sh\necho gpu\n",
+		"resource-class/disk/50-storage": "#!/bin/sh\necho disk\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		have        *nodes.Node
+		wantParts   []string
+		wantMissing []string
+	}{
+		{
+			name: "global and resource class",
+			have: &nodes.Node{
+				UUID:          "test-uuid",
+				ResourceClass: "gpu",
+				InstanceInfo:  map[string]any{"user_data": "#cloud-config\nhostname: web01\n"},
+			},
+			wantParts:   []string{"echo global", "echo gpu", "hostname: web01", "global-10-bootcmd.yaml"},
+			wantMissing: []string{"echo disk", "ignored"},
+		},
+		{
+			name:        "no node user data",
+			have:        &nodes.Node{UUID: "test-uuid"},
+			wantParts:   []string{"echo global"},
+			wantMissing: []string{"echo gpu", "filename=\"user-data\""},
+		},
+		{
+			name: "ignition is not combined",
+			have: &nodes.Node{
+				UUID:         "test-uuid",
+				InstanceInfo: map[string]any{"user_data": `{"ignition": {"version": "3.4.0"}}`},
+			},
+			wantMissing: []string{"echo global"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.UserDataFragmentsDir = dir
+
+			have, err := h.renderUserData(tt.have)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tt.wantParts) > 0 {
+				if format, err := userdata.Validate(have); err != nil || format != userdata.FormatMIME {
+					t.Errorf("expected a valid MIME document, got %q, %v", format, err)
+				}
+			}
+			for _, want := range tt.wantParts {
+				if !bytes.Contains(have, []byte(want)) {
+					t.Errorf("expected user data to contain %q", want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if bytes.Contains(have, []byte(missing)) {
+					t.Errorf("expected user data not to contain %q", missing)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// gcePrefix is the path prefix of the GCE-compatible metadata routes.
+const gcePrefix = "/computeMetadata/v1"
+
+// gceFlavor is the value of the Metadata-Flavor header required on GCE
+// metadata requests and set on their responses.
+const gceFlavor = "Google"
+
+// handleGCE handles requests below /computeMetadata/v1/, emulating the GCE
+// metadata server on top of node data.
+func (h *Handler) handleGCE(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Metadata-Flavor", gceFlavor)
+
+	// GCE rejects requests without the flavor header so that metadata cannot
+	// be fetched through open redirects or unwitting proxies.
+	if r.Header.Get("Metadata-Flavor") != gceFlavor &&
+		!strings.EqualFold(r.Header.Get("X-Google-Metadata-Request"), "true") {
+		http.Error(w, "Missing required header \"Metadata-Flavor\": \"Google\"", http.StatusForbidden)
+		return
+	}
+
+	node, clientIP, ok := h.nodeForRequest(w, r, "gce")
+	if !ok {
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, gcePrefix)
+	value, found := gceLookup(h.buildGCEMetaData(node, clientIP), path)
+	if !found {
+		h.logger().Debug().
+			Str("node_uuid", node.UUID).
+			Str("path", path).
+			Msg("GCE metadata path not found")
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	recursive, _ := strconv.ParseBool(query.Get("recursive"))
+	alt := query.Get("alt")
+
+	switch {
+	case alt == "json" || (recursive && alt != "text"):
+		h.writeJSONResponse(w, gceJSON(value, false))
+	case !gceIsDirectory(value):
+		h.writeTextResponse(w, fmt.Sprint(value))
+	case recursive:
+		h.writeTextResponse(w, strings.Join(gceText(value, ""), "\n"))
+	default:
+		h.writeTextResponse(w, strings.Join(gceChildren(value), "\n"))
+	}
+}
+
+// buildGCEMetaData maps node data onto the GCE metadata tree. Directories
+// are maps or slices of maps, keyed by their GCE path segment.
+func (h *Handler) buildGCEMetaData(node *nodes.Node, clientIP string) map[string]any {
+	metaData := h.buildMetaData(node)
+	projectID := metaData.ProjectID
+	if projectID == "" {
+		projectID = "default"
+	}
+	zone, _ := node.InstanceInfo["availability_zone"].(string)
+	if zone == "" {
+		zone = "default"
+	}
+
+	attributes := make(map[string]any)
+	for key, value := range metaData.Meta {
+		attributes[key] = value
+	}
+	for key, value := range metaData.Tags {
+		attributes[key] = value
+	}
+	if keys := metaData.PublicKeys; len(keys) > 0 {
+		// GCE stores keys as "<user>:<key>" lines; use the key name as user.
+		lines := make([]string, 0, len(keys))
+		for _, name := range sortedKeyNames(keys) {
+			lines = append(lines, name+":"+keys[name])
+		}
+		attributes["ssh-keys"] = strings.Join(lines, "\n")
+	}
+	if userData, err := h.renderUserData(node); err == nil && len(userData) > 0 {
+		attributes["user-data"] = string(userData)
+	}
+
+	iface := map[string]any{
+		"ip":      clientIP,
+		"network": fmt.Sprintf("projects/%s/networks/default", projectID),
+	}
+	for _, link := range h.buildNetworkData(node).Links {
+		if link.EthernetMacAddress != "" {
+			iface["mac"] = link.EthernetMacAddress
+			break
+		}
+	}
+
+	instance := map[string]any{
+		"attributes":         attributes,
+		"hostname":           metaData.Hostname,
+		"id":                 node.UUID,
+		"machine-type":       fmt.Sprintf("projects/%s/machineTypes/%s", projectID, node.ResourceClass),
+		"name":               metaData.Name,
+		"network-interfaces": []any{iface},
+		"zone":               fmt.Sprintf("projects/%s/zones/%s", projectID, zone),
+	}
+	if image, ok := node.InstanceInfo["image_source"].(string); ok {
+		instance["image"] = image
+	}
+	if instance["name"] == "" {
+		instance["name"] = node.UUID
+	}
+
+	return map[string]any{
+		"instance": instance,
+		"project": map[string]any{
+			"attributes": map[string]any{},
+			"project-id": projectID,
+		},
+	}
+}
+
+// gceLookup resolves a slash-separated path in the metadata tree.
+func gceLookup(tree map[string]any, path string) (any, bool) {
+	var value any = tree
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			child, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// gceIsDirectory reports whether a metadata value is listed as a directory.
+func gceIsDirectory(value any) bool {
+	switch value.(type) {
+	case map[string]any, []any:
+		return true
+	}
+	return false
+}
+
+// gceChildren lists the entries of a directory, with subdirectories
+// suffixed by a slash.
+func gceChildren(value any) []string {
+	var children []string
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if gceIsDirectory(child) {
+				key += "/"
+			}
+			children = append(children, key)
+		}
+		sort.Strings(children)
+	case []any:
+		for i := range v {
+			children = append(children, strconv.Itoa(i)+"/")
+		}
+	}
+	return children
+}
+
+// gceText flattens a directory into "path value" lines, as returned for
+// recursive requests with alt=text.
+func gceText(value any, prefix string) []string {
+	switch v := value.(type) {
+	case map[string]any:
+		var lines []string
+		for _, key := range gceChildren(v) {
+			lines = append(lines, gceText(v[strings.TrimSuffix(key, "/")], prefix+key)...)
+		}
+		return lines
+	case []any:
+		var lines []string
+		for i, child := range v {
+			lines = append(lines, gceText(child, prefix+strconv.Itoa(i)+"/")...)
+		}
+		return lines
+	}
+	return []string{fmt.Sprintf("%s %v", prefix, value)}
+}
+
+// gceJSON converts a metadata value to its JSON representation. GCE
+// camel-cases directory keys in JSON output, except for user-defined
+// attributes which are returned verbatim.
+func gceJSON(value any, verbatim bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, child := range v {
+			name := key
+			if !verbatim {
+				name = gceCamelCase(key)
+			}
+			out[name] = gceJSON(child, !verbatim && key == "attributes")
+		}
+		return out
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, child := range v {
+			out = append(out, gceJSON(child, false))
+		}
+		return out
+	}
+	return value
+}
+
+// gceCamelCase converts a dash-separated path segment to camelCase.
+func gceCamelCase(key string) string {
+	parts := strings.Split(key, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,95 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func testGCETree() map[string]any {
+	return map[string]any{
+		"instance": map[string]any{
+			"attributes": map[string]any{
+				"ssh-keys":  "default:ssh-ed25519 AAAA",
+				"user-data": "#cloud-config",
+			},
+			"hostname":           "node-01",
+			"machine-type":       "projects/demo/machineTypes/baremetal",
+			"network-interfaces": []any{map[string]any{"ip": "10.0.0.5"}},
+		},
+	}
+}
+
+func TestGCELookup(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		want  any
+		found bool
+	}{
+		{name: "leaf", path: "/instance/hostname", want: "node-01", found: true},
+		{
+			name:  "list index",
+			path:  "/instance/network-interfaces/0/ip",
+			want:  "10.0.0.5",
+			found: true,
+		},
+		{name: "missing", path: "/instance/zone"},
+		{name: "index out of range", path: "/instance/network-interfaces/1/"},
+		{name: "below leaf", path: "/instance/hostname/extra"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, found := gceLookup(testGCETree(), tt.path)
+			if found != tt.found {
+				t.Fatalf("found: have %v, want %v", found, tt.found)
+			}
+			if tt.found && have != tt.want {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestGCEChildren(t *testing.T) {
+	instance, _ := gceLookup(testGCETree(), "/instance/")
+	want := []string{"attributes/", "hostname", "machine-type", "network-interfaces/"}
+	if have := gceChildren(instance); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestGCEJSON(t *testing.T) {
+	have := gceJSON(testGCETree(), false)
+	want := map[string]any{
+		"instance": map[string]any{
+			"attributes": map[string]any{
+				"ssh-keys":  "default:ssh-ed25519 AAAA",
+				"user-data": "#cloud-config",
+			},
+			"hostname":          "node-01",
+			"machineType":       "projects/demo/machineTypes/baremetal",
+			"networkInterfaces": []any{map[string]any{"ip": "10.0.0.5"}},
+		},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestGCEFlavorRequired(t *testing.T) {
+	h := &Handler{GCE: true}
+	req := httptest.NewRequest(http.MethodGet, gcePrefix+"/instance/hostname", nil)
+	rec := httptest.NewRecorder()
+
+	h.Routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status: have %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if have := rec.Header().Get("Metadata-Flavor"); have != gceFlavor {
+		t.Errorf("Metadata-Flavor: have %q, want %q", have, gceFlavor)
+	}
+}
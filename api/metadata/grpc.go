@@ -0,0 +1,335 @@
+package metadata
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	adminv1 "github.com/appkins-org/ironic-metadata/api/admin/v1"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// adminServer implements the AdminService of the gRPC admin API.
+type adminServer struct {
+	adminv1.UnimplementedAdminServiceServer
+
+	h      *Handler
+	stream *events.Stream
+}
+
+// NewGRPCServer returns a gRPC server offering the admin operations of h
+// through the AdminService of package adminv1, to callers carrying
+// h.AdminToken as a bearer token. WatchEvents streams the events of stream,
+// which h sends events to, and fails when stream is nil.
+func NewGRPCServer(
+	h *Handler,
+	stream *events.Stream,
+	opts ...grpc.ServerOption,
+) (*grpc.Server, error) {
+	if h.AdminToken == "" {
+		return nil, errors.New("the gRPC admin API requires an admin token")
+	}
+	s := &adminServer{h: h, stream: stream}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.authorizeUnary),
+		grpc.ChainStreamInterceptor(s.authorizeStream))
+	server := grpc.NewServer(opts...)
+	adminv1.RegisterAdminServiceServer(server, s)
+	return server, nil
+}
+
+func (s *adminServer) authorizeUnary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if err := s.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *adminServer) authorizeStream(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := s.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// authorize requires the admin token as a bearer token in the
+// authorization metadata of a call.
+func (s *adminServer) authorize(ctx context.Context, method string) error {
+	md, _ := grpcmetadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.h.AdminToken)) == 1 {
+			return nil
+		}
+	}
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	s.h.logger().Warn().
+		Str("method", method).
+		Str("remote_addr", remoteAddr).
+		Msg("Rejected unauthenticated admin call")
+	return status.Error(codes.Unauthenticated, "admin token required")
+}
+
+// node fetches a node by UUID or name, failing with a status error.
+func (s *adminServer) node(ctx context.Context, nodeID string) (*nodes.Node, error) {
+	if nodeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "node is required")
+	}
+	node, err := s.h.nodeSource().GetNode(ctx, nodeID)
+	if err != nil {
+		if gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+			return nil, status.Error(codes.NotFound, "node not found")
+		}
+		s.h.logger().Error().
+			Err(err).
+			Str("node", nodeID).
+			Msg("Failed to get node from Ironic")
+		return nil, status.Error(codes.Unavailable, "failed to get node")
+	}
+	return node, nil
+}
+
+// Lookup implements adminv1.AdminServiceServer.
+func (s *adminServer) Lookup(
+	ctx context.Context,
+	req *adminv1.LookupRequest,
+) (*adminv1.LookupResponse, error) {
+	if net.ParseIP(req.GetIp()) == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ip")
+	}
+
+	trace := s.h.lookup(ctx, req.GetIp(), req.GetHost())
+	resp := &adminv1.LookupResponse{
+		Ip:       trace.IP,
+		Resolver: trace.Resolver,
+		Error:    trace.Error,
+	}
+	for _, step := range trace.Resolvers {
+		pb := &adminv1.ResolverStep{
+			Name:       step.Name,
+			Matched:    step.Matched,
+			MacAddress: step.MACAddress,
+			Notes:      step.Notes,
+			Error:      step.Error,
+		}
+		if d, err := time.ParseDuration(step.Duration); err == nil {
+			pb.Duration = durationpb.New(d)
+		}
+		resp.Resolvers = append(resp.Resolvers, pb)
+	}
+	if trace.Node != nil {
+		resp.Node = &adminv1.Node{
+			Uuid:           trace.Node.UUID,
+			Name:           trace.Node.Name,
+			ProvisionState: trace.Node.ProvisionState,
+		}
+	}
+	return resp, nil
+}
+
+// Preview implements adminv1.AdminServiceServer.
+func (s *adminServer) Preview(
+	ctx context.Context,
+	req *adminv1.PreviewRequest,
+) (*adminv1.PreviewResponse, error) {
+	clientIP := req.GetClientIp()
+	if clientIP != "" {
+		if _, err := netip.ParseAddr(clientIP); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid client_ip")
+		}
+	}
+	node, err := s.node(ctx, req.GetNode())
+	if err != nil {
+		return nil, err
+	}
+	if clientIP == "" {
+		clientIP = s.h.defaultClientIP(node)
+	}
+
+	preview := s.h.preview(ctx, node, clientIP)
+	resp := &adminv1.PreviewResponse{
+		NodeUuid: preview.NodeUUID,
+		NodeName: preview.NodeName,
+		ClientIp: preview.ClientIP,
+		Ec2:      make(map[string]*structpb.Value, len(preview.EC2)),
+		Errors:   preview.Errors,
+	}
+	if resp.MetaData, err = jsonValue(preview.MetaData); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode meta_data: %v", err)
+	}
+	documents := map[string]struct {
+		data any
+		pb   **structpb.Struct
+	}{
+		"network_data": {preview.NetworkData, &resp.NetworkData},
+		"vendor_data":  {preview.VendorData, &resp.VendorData},
+		"vendor_data2": {preview.VendorData2, &resp.VendorData2},
+	}
+	for name, document := range documents {
+		value, err := jsonValue(document.data)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode %s: %v", name, err)
+		}
+		*document.pb = value.GetStructValue()
+	}
+	for path, content := range preview.EC2 {
+		if resp.Ec2[path], err = jsonValue(content); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode %s: %v", path, err)
+		}
+	}
+	if preview.UserData != nil {
+		resp.UserData = []byte(*preview.UserData)
+		if preview.UserDataEncoding == "base64" {
+			if resp.UserData, err = base64.StdEncoding.DecodeString(*preview.UserData); err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to decode user_data: %v", err)
+			}
+		}
+	}
+	for document, violations := range preview.Violations {
+		if resp.Violations == nil {
+			resp.Violations = make(map[string]*adminv1.Violations)
+		}
+		resp.Violations[document] = &adminv1.Violations{Violations: violations}
+	}
+	return resp, nil
+}
+
+// jsonValue converts a value to a protobuf value through its JSON encoding,
+// returning nil for nil values.
+func jsonValue(v any) (*structpb.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return nil, nil
+	}
+	value := &structpb.Value{}
+	if err := protojson.Unmarshal(b, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetCacheStats implements adminv1.AdminServiceServer.
+func (s *adminServer) GetCacheStats(
+	context.Context,
+	*adminv1.GetCacheStatsRequest,
+) (*adminv1.GetCacheStatsResponse, error) {
+	resp := &adminv1.GetCacheStatsResponse{}
+	for _, cache := range s.h.caches() {
+		stats := cache.stats()
+		pb := &adminv1.CacheStats{
+			Name:      cache.name,
+			Entries:   int64(stats.Entries),
+			Hits:      stats.Hits,
+			Misses:    stats.Misses,
+			Evictions: stats.Evictions,
+			Bytes:     stats.Bytes,
+		}
+		if !stats.Oldest.IsZero() {
+			pb.Oldest = timestamppb.New(stats.Oldest)
+		}
+		resp.Caches = append(resp.Caches, pb)
+	}
+	return resp, nil
+}
+
+// RefreshCache implements adminv1.AdminServiceServer.
+func (s *adminServer) RefreshCache(
+	ctx context.Context,
+	_ *adminv1.RefreshCacheRequest,
+) (*adminv1.RefreshCacheResponse, error) {
+	start := time.Now()
+	if err := s.h.Warm(ctx); err != nil {
+		s.h.logger().Error().
+			Err(err).
+			Msg("Failed to refresh caches")
+		return nil, status.Errorf(codes.Unavailable, "failed to refresh caches: %v", err)
+	}
+	return &adminv1.RefreshCacheResponse{Duration: durationpb.New(time.Since(start))}, nil
+}
+
+// InvalidateNode implements adminv1.AdminServiceServer.
+func (s *adminServer) InvalidateNode(
+	ctx context.Context,
+	req *adminv1.InvalidateNodeRequest,
+) (*adminv1.InvalidateNodeResponse, error) {
+	node, err := s.node(ctx, req.GetNode())
+	if err != nil {
+		return nil, err
+	}
+	s.h.InvalidateNode(node)
+	return &adminv1.InvalidateNodeResponse{NodeUuid: node.UUID}, nil
+}
+
+// WatchEvents implements adminv1.AdminServiceServer.
+func (s *adminServer) WatchEvents(
+	req *adminv1.WatchEventsRequest,
+	ss grpc.ServerStreamingServer[adminv1.WatchEventsResponse],
+) error {
+	if s.stream == nil {
+		return status.Error(codes.FailedPrecondition, "the event stream is not enabled")
+	}
+	types, err := events.ParseTypes(strings.Join(req.GetTypes(), ","))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub := s.stream.Subscribe(types, 0)
+	defer sub.Close()
+	for {
+		select {
+		case <-ss.Context().Done():
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "the service is shutting down")
+			}
+			err := ss.Send(&adminv1.WatchEventsResponse{Event: &adminv1.Event{
+				Id:        event.ID,
+				Type:      event.Type,
+				Timestamp: timestamppb.New(event.Timestamp),
+				NodeUuid:  event.NodeUUID,
+				NodeName:  event.NodeName,
+				Endpoint:  event.Endpoint,
+				ClientIp:  event.ClientIP,
+				Status:    int32(event.Status),
+			}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package metadata
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	adminv1 "github.com/appkins-org/ironic-metadata/api/admin/v1"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startGRPCServer serves the gRPC admin API of h in memory, returning a
+// client.
+func startGRPCServer(
+	t *testing.T,
+	h *Handler,
+	stream *events.Stream,
+) adminv1.AdminServiceClient {
+	t.Helper()
+	server, err := NewGRPCServer(h, stream)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return adminv1.NewAdminServiceClient(conn)
+}
+
+// grpcTestHandler returns a handler serving node-1 at 10.0.0.5, with the
+// admin token secret.
+func grpcTestHandler() *Handler {
+	return NewHandler(
+		WithNodeSource(mock.NewNodeSource(nodes.Node{
+			UUID:           "node-1",
+			Name:           "web01",
+			ProvisionState: "active",
+			InstanceInfo: map[string]any{
+				"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+				"user_data": "#cloud-config\n",
+			},
+		})),
+		WithAdminToken("secret"),
+	)
+}
+
+// withToken returns a context carrying token as a bearer token.
+func withToken(ctx context.Context, token string) context.Context {
+	return grpcmetadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestNewGRPCServer_adminToken(t *testing.T) {
+	if _, err := NewGRPCServer(createTestHandler(), nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestGRPCServer_authorize(t *testing.T) {
+	client := startGRPCServer(t, grpcTestHandler(), nil)
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode codes.Code
+	}{
+		{name: "admin token", ctx: withToken(t.Context(), "secret")},
+		{
+			name:     "wrong token",
+			ctx:      withToken(t.Context(), "guess"),
+			wantCode: codes.Unauthenticated,
+		},
+		{name: "no token", ctx: t.Context(), wantCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.GetCacheStats(tt.ctx, &adminv1.GetCacheStatsRequest{})
+			if have := status.Code(err); have != tt.wantCode {
+				t.Errorf("have code %s, want %s", have, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestGRPCServer_Lookup(t *testing.T) {
+	client := startGRPCServer(t, grpcTestHandler(), nil)
+	ctx := withToken(t.Context(), "secret")
+	tests := []struct {
+		name         string
+		ip           string
+		wantNode     string
+		wantResolver string
+		wantCode     codes.Code
+	}{
+		{name: "found", ip: "10.0.0.5", wantNode: "node-1", wantResolver: resolverIP},
+		{name: "not found", ip: "10.0.0.6"},
+		{name: "invalid", ip: "10.0.0", wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Lookup(ctx, &adminv1.LookupRequest{Ip: tt.ip})
+			if have := status.Code(err); have != tt.wantCode {
+				t.Fatalf("have code %s, want %s: %v", have, tt.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if have := resp.GetNode().GetUuid(); have != tt.wantNode {
+				t.Errorf("have node %q, want %q", have, tt.wantNode)
+			}
+			if resp.GetResolver() != tt.wantResolver {
+				t.Errorf("have resolver %q, want %q", resp.GetResolver(), tt.wantResolver)
+			}
+			if len(resp.GetResolvers()) == 0 || resp.GetResolvers()[0].GetDuration() == nil {
+				t.Errorf("have resolvers %v, want their trace", resp.GetResolvers())
+			}
+		})
+	}
+}
+
+func TestGRPCServer_Preview(t *testing.T) {
+	client := startGRPCServer(t, grpcTestHandler(), nil)
+	ctx := withToken(t.Context(), "secret")
+
+	resp, err := client.Preview(ctx, &adminv1.PreviewRequest{Node: "web01"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetNodeUuid() != "node-1" || resp.GetClientIp() != "10.0.0.5" {
+		t.Errorf("have node %q at %q, want node-1 at 10.0.0.5", resp.GetNodeUuid(),
+			resp.GetClientIp())
+	}
+	metaData := resp.GetMetaData().GetStructValue().GetFields()
+	if have := metaData["uuid"].GetStringValue(); have != "node-1" {
+		t.Errorf("have meta_data uuid %q, want node-1", have)
+	}
+	if have := string(resp.GetUserData()); have != "#cloud-config\n" {
+		t.Errorf("have user data %q, want #cloud-config", have)
+	}
+	if have := resp.GetEc2()["meta-data/instance-id"].GetStringValue(); have != "node-1" {
+		t.Errorf("have instance-id %q, want node-1", have)
+	}
+
+	_, err = client.Preview(ctx, &adminv1.PreviewRequest{Node: "db01"})
+	if have := status.Code(err); have != codes.NotFound {
+		t.Errorf("have code %s, want %s", have, codes.NotFound)
+	}
+	_, err = client.Preview(ctx, &adminv1.PreviewRequest{Node: "web01", ClientIp: "web01"})
+	if have := status.Code(err); have != codes.InvalidArgument {
+		t.Errorf("have code %s, want %s", have, codes.InvalidArgument)
+	}
+}
+
+func TestGRPCServer_caches(t *testing.T) {
+	h := grpcTestHandler()
+	client := startGRPCServer(t, h, nil)
+	ctx := withToken(t.Context(), "secret")
+
+	h.configDriveCache.put("node-1", "hash", &configDriveData{}, 1, h.CacheLimits, h.now())
+	stats, err := client.GetCacheStats(ctx, &adminv1.GetCacheStatsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries := func(stats *adminv1.GetCacheStatsResponse) int64 {
+		for _, cache := range stats.GetCaches() {
+			if cache.GetName() == "configdrive" {
+				return cache.GetEntries()
+			}
+		}
+		t.Fatalf("have caches %v, want configdrive", stats.GetCaches())
+		return 0
+	}
+	if have := entries(stats); have != 1 {
+		t.Errorf("have %d configdrive entries, want 1", have)
+	}
+
+	resp, err := client.InvalidateNode(ctx, &adminv1.InvalidateNodeRequest{Node: "web01"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetNodeUuid() != "node-1" {
+		t.Errorf("have node %q, want node-1", resp.GetNodeUuid())
+	}
+	if stats, err = client.GetCacheStats(ctx, &adminv1.GetCacheStatsRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := entries(stats); have != 0 {
+		t.Errorf("have %d configdrive entries, want 0", have)
+	}
+
+	if _, err := client.RefreshCache(ctx, &adminv1.RefreshCacheRequest{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGRPCServer_WatchEvents(t *testing.T) {
+	stream := events.NewStream()
+	h := grpcTestHandler()
+	h.EventSinks = []events.Sink{stream}
+	client := startGRPCServer(t, h, stream)
+	ctx := withToken(t.Context(), "secret")
+
+	// Streams fail on their first receive.
+	watchError := func(client adminv1.AdminServiceClient, types ...string) error {
+		watch, err := client.WatchEvents(ctx, &adminv1.WatchEventsRequest{Types: types})
+		if err != nil {
+			return err
+		}
+		_, err = watch.Recv()
+		return err
+	}
+	err := watchError(startGRPCServer(t, grpcTestHandler(), nil))
+	if have := status.Code(err); have != codes.FailedPrecondition {
+		t.Errorf("have code %s without a stream, want %s", have, codes.FailedPrecondition)
+	}
+
+	watch, err := client.WatchEvents(ctx,
+		&adminv1.WatchEventsRequest{Types: []string{events.UserData}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Wait for the subscription before sending events.
+	for !stream.Wants(events.UserData) {
+		time.Sleep(time.Millisecond)
+	}
+
+	routes := h.Routes()
+	for _, path := range []string{
+		"/openstack/latest/meta_data.json",
+		"/openstack/latest/user_data",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.5:4321"
+		routes.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	resp, err := watch.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	event := resp.GetEvent()
+	if event.GetType() != events.UserData || event.GetNodeUuid() != "node-1" ||
+		event.GetClientIp() != "10.0.0.5" || event.GetStatus() != http.StatusOK {
+		t.Errorf("have event %v, want user_data of node-1", event)
+	}
+
+	stream.Close()
+	if _, err := watch.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("have error %v after the stream closed, want %s", err, codes.Unavailable)
+	}
+
+	if have := status.Code(watchError(client, "boot")); have != codes.InvalidArgument {
+		t.Errorf("have code %s for an unknown type, want %s", have, codes.InvalidArgument)
+	}
+}
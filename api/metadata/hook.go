@@ -0,0 +1,214 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+)
+
+// Policies on mutation hook failures.
+const (
+	// HookFailureFail answers 503 Service Unavailable when the hook fails,
+	// so nodes retry rather than boot without the site's changes.
+	HookFailureFail = "fail"
+
+	// HookFailureServe logs hook failures and serves the document
+	// unchanged.
+	HookFailureServe = "serve"
+)
+
+// HookFailurePolicies lists the policies on mutation hook failures.
+var HookFailurePolicies = []string{HookFailureFail, HookFailureServe}
+
+// ParseHookFailurePolicy parses a policy on mutation hook failures, where
+// empty means HookFailureFail.
+func ParseHookFailurePolicy(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	if policy == "" {
+		return HookFailureFail, nil
+	}
+	if !slices.Contains(HookFailurePolicies, policy) {
+		return "", fmt.Errorf("unknown hook failure policy %q, want one of %s",
+			policy, strings.Join(HookFailurePolicies, ", "))
+	}
+	return policy, nil
+}
+
+// ParseHookEndpoints parses a comma separated list of the endpoints handed
+// to the mutation hook, such as meta_data.json,user_data. An empty list
+// hands it every endpoint.
+func ParseHookEndpoints(spec string) map[string]bool {
+	endpoints := make(map[string]bool)
+	for _, endpoint := range strings.Split(spec, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints[endpoint] = true
+		}
+	}
+	return endpoints
+}
+
+// Results of mutation hook calls, as counted in the metrics.
+const (
+	HookUnchanged = "unchanged"
+	HookMutated   = "mutated"
+	HookError     = "error"
+)
+
+// mutator returns the MutationHook followed by the Plugins, or nil when
+// neither is set.
+func (h *Handler) mutator() hook.Mutator {
+	switch {
+	case h.MutationHook != nil && h.Plugins != nil:
+		return hook.Chain{h.MutationHook, h.Plugins}
+	case h.MutationHook != nil:
+		return h.MutationHook
+	case h.Plugins != nil:
+		return h.Plugins
+	}
+	return nil
+}
+
+// hookMiddleware hands each successful response served for a node to the
+// MutationHook and Plugins before it is sent, serving the document they
+// return.
+// Responses are buffered when either is set. It runs within
+// policyMiddleware, so policies decide on the mutated documents.
+func (h *Handler) hookMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutator := h.mutator()
+		if mutator == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		subject, r := withPolicySubject(r)
+		bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		body := bw.body.Bytes()
+		if subject.node != nil && bw.status == http.StatusOK && h.hooked(subject.endpoint) {
+			var ok bool
+			if body, ok = h.applyHook(w, r, mutator, subject, body); !ok {
+				return
+			}
+		}
+		w.WriteHeader(bw.status)
+		if _, err := w.Write(body); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("path", r.URL.Path).
+				Msg("Failed to write response")
+		}
+	})
+}
+
+// hooked reports whether responses of an endpoint are handed to the
+// MutationHook and Plugins.
+func (h *Handler) hooked(endpoint string) bool {
+	return len(h.MutationHookEndpoints) == 0 || h.MutationHookEndpoints[endpoint]
+}
+
+// applyHook returns the body of a response as mutator has it served. When
+// it fails and MutationHookFailure is HookFailureFail, an error response is
+// written and false is returned.
+func (h *Handler) applyHook(
+	w http.ResponseWriter,
+	r *http.Request,
+	mutator hook.Mutator,
+	subject *policySubject,
+	body []byte,
+) ([]byte, bool) {
+	node := subject.node
+	clientIP, _ := getClientIPFromContext(r)
+	input := &hook.Input{
+		ClientIP: clientIP,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Endpoint: subject.endpoint,
+		Node: &hook.Node{
+			UUID:           node.UUID,
+			Name:           node.Name,
+			ProvisionState: node.ProvisionState,
+			ProjectID:      h.projectID(node),
+			ResourceClass:  node.ResourceClass,
+			Traits:         node.Traits,
+			Properties:     node.Properties,
+			Extra:          node.Extra,
+		},
+		ContentType: w.Header().Get("Content-Type"),
+		Document:    string(body),
+	}
+	// Numbers are kept as written, so hooks echoing a document change
+	// nothing.
+	isJSON := false
+	var document any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if json.Valid(body) && decoder.Decode(&document) == nil {
+		input.Document = document
+		isJSON = true
+	}
+
+	logger := h.logger().With().
+		Str("client_ip", clientIP).
+		Str("node_uuid", node.UUID).
+		Str("endpoint", subject.endpoint).
+		Logger()
+	mutated, err := mutator.Mutate(r.Context(), input)
+	if err == nil && mutated != nil {
+		mutated, err = hookDocument(mutated, isJSON)
+	}
+	if err != nil {
+		h.Metrics.observeHookCall(HookError)
+		if h.MutationHookFailure == HookFailureServe {
+			logger.Warn().
+				Err(err).
+				Msg("Mutation hook failed, serving the document unchanged")
+			return body, true
+		}
+		logger.Error().
+			Err(err).
+			Msg("Mutation hook failed")
+		http.Error(w, "Mutation hook unavailable", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if mutated == nil || bytes.Equal(mutated, body) {
+		h.Metrics.observeHookCall(HookUnchanged)
+		return body, true
+	}
+	logger.Debug().
+		Int("size", len(mutated)).
+		Msg("Mutation hook changed metadata")
+	h.Metrics.observeHookCall(HookMutated)
+
+	// The validators and signature of the handler were derived from the
+	// document as rendered.
+	w.Header().Del("ETag")
+	w.Header().Del("Content-Length")
+	if err := h.resign(w, mutated); err != nil {
+		logger.Error().
+			Err(err).
+			Msg("Failed to sign response")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return mutated, true
+}
+
+// hookDocument returns the body of a document returned by the hook: JSON
+// documents are served as returned, while other documents must be returned
+// as a JSON string, which is served unquoted.
+func hookDocument(document json.RawMessage, isJSON bool) ([]byte, error) {
+	if isJSON {
+		return append(bytes.TrimSpace(document), '\n'), nil
+	}
+	var s string
+	if err := json.Unmarshal(document, &s); err != nil {
+		return nil, fmt.Errorf("hook returned a document that is not a string: %w", err)
+	}
+	return []byte(s), nil
+}
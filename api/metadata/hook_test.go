@@ -0,0 +1,275 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/appkins-org/ironic-metadata/pkg/plugins"
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/wasmplugin"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_hook(t *testing.T) {
+	// The hook adds a role to the meta of meta_data.json, returns an object
+	// for user_data, fails on network_data.json and leaves the rest as is.
+	var inputs []hook.Input
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input hook.Input
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		inputs = append(inputs, input)
+		switch input.Endpoint {
+		case "meta_data.json":
+			document, _ := input.Document.(map[string]any)
+			document["meta"] = map[string]any{"role": "web"}
+			_ = json.NewEncoder(w).Encode(map[string]any{"document": document})
+		case "user_data":
+			_, _ = w.Write([]byte(`{"document": {"user_data": "#!/bin/sh"}}`))
+		case "network_data.json":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	mutationHook, err := hook.New(hook.Config{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		endpoints  string
+		failure    string
+		wantStatus int
+		wantBody   string
+		wantInput  bool
+		wantMetric string
+	}{
+		{
+			name:       "mutated",
+			path:       "/openstack/latest/meta_data.json",
+			wantStatus: http.StatusOK,
+			wantBody:   `"role":"web"`,
+			wantInput:  true,
+			wantMetric: `ironic_metadata_hook_calls_total{result="mutated"} 1`,
+		},
+		{
+			name:       "unchanged",
+			path:       "/latest/meta-data",
+			wantStatus: http.StatusOK,
+			wantBody:   "instance-id",
+			wantInput:  true,
+			wantMetric: `ironic_metadata_hook_calls_total{result="unchanged"} 1`,
+		},
+		{
+			name:       "not a string",
+			path:       "/openstack/latest/user_data",
+			wantStatus: http.StatusServiceUnavailable,
+			wantInput:  true,
+			wantMetric: `ironic_metadata_hook_calls_total{result="error"} 1`,
+		},
+		{
+			name:       "failed",
+			path:       "/openstack/latest/network_data.json",
+			wantStatus: http.StatusServiceUnavailable,
+			wantInput:  true,
+			wantMetric: `ironic_metadata_hook_calls_total{result="error"} 1`,
+		},
+		{
+			name:       "failed and served",
+			path:       "/openstack/latest/network_data.json",
+			failure:    HookFailureServe,
+			wantStatus: http.StatusOK,
+			wantBody:   `"links"`,
+			wantInput:  true,
+			wantMetric: `ironic_metadata_hook_calls_total{result="error"} 1`,
+		},
+		{
+			name:       "endpoint not hooked",
+			path:       "/openstack/latest/network_data.json",
+			endpoints:  "meta_data.json",
+			wantStatus: http.StatusOK,
+			wantBody:   `"links"`,
+		},
+		{
+			name:       "no node",
+			path:       "/openstack/latest",
+			wantStatus: http.StatusOK,
+			wantBody:   "meta_data.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs = nil
+			node := nodes.Node{
+				UUID:           "node-1",
+				Name:           "web01",
+				ProvisionState: "active",
+				InstanceInfo: map[string]any{
+					"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+					"user_data": "#cloud-config\n",
+				},
+			}
+			failure, err := ParseHookFailurePolicy(tt.failure)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			registry := metrics.NewRegistry()
+			h := NewHandler(
+				WithNodeSource(mock.NewNodeSource(node)),
+				WithMutationHook(mutationHook, ParseHookEndpoints(tt.endpoints), failure),
+			)
+			h.EnableMetrics(registry)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("expected %q in %s", tt.wantBody, rr.Body)
+			}
+			if have := len(inputs) > 0; have != tt.wantInput {
+				t.Fatalf("have hook called %v, want %v", have, tt.wantInput)
+			}
+			if tt.wantInput && (inputs[0].ClientIP != "10.0.0.5" ||
+				inputs[0].Node.UUID != "node-1") {
+				t.Errorf("have input %+v, want the client and node", inputs[0])
+			}
+
+			var b strings.Builder
+			if _, err := registry.WriteTo(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantMetric != "" && !strings.Contains(b.String(), tt.wantMetric+"\n") {
+				t.Errorf("expected %q in\n%s", tt.wantMetric, b.String())
+			}
+		})
+	}
+}
+
+func TestParseHookFailurePolicy(t *testing.T) {
+	tests := []struct {
+		have    string
+		want    string
+		wantErr bool
+	}{
+		{have: "", want: HookFailureFail},
+		{have: "Serve", want: HookFailureServe},
+		{have: "retry", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.have, func(t *testing.T) {
+			have, err := ParseHookFailurePolicy(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_plugins(t *testing.T) {
+	// The hook adds a role to the meta of meta_data.json, which plugins
+	// are handed after it.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input hook.Input
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		document, _ := input.Document.(map[string]any)
+		document["meta"] = map[string]any{"role": "web"}
+		_ = json.NewEncoder(w).Encode(map[string]any{"document": document})
+	}))
+	t.Cleanup(srv.Close)
+	mutationHook, err := hook.New(hook.Config{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		hook     *hook.Hook
+		module   []byte
+		wantBody string
+	}{
+		{
+			name:     "plugin",
+			module:   wasmplugin.Mutator(`{"document":{"uuid":"replaced"}}`),
+			wantBody: `{"uuid":"replaced"}`,
+		},
+		{
+			name:     "after the hook",
+			hook:     mutationHook,
+			module:   wasmplugin.Module("", wasmplugin.Mutate(wasmplugin.Echo)),
+			wantBody: `"role":"web"`,
+		},
+		{
+			name:     "replacing the hook document",
+			hook:     mutationHook,
+			module:   wasmplugin.Mutator(`{"document":{"uuid":"replaced"}}`),
+			wantBody: `{"uuid":"replaced"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			wasmplugin.Write(t, dir, "plugin", tt.module, time.Now())
+			wasmPlugins, err := plugins.New(t.Context(), plugins.Config{Dir: dir})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = wasmPlugins.Close(context.Background())
+			})
+
+			node := nodes.Node{
+				UUID: "node-1",
+				InstanceInfo: map[string]any{
+					"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+				},
+			}
+			opts := []Option{WithNodeSource(mock.NewNodeSource(node)), WithPlugins(wasmPlugins)}
+			if tt.hook != nil {
+				opts = append(opts, WithMutationHook(tt.hook, nil, HookFailureFail))
+			}
+			h := NewHandler(opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json", nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			if !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("expected %q in %s", tt.wantBody, rr.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// requestHostKey is the context key of the Host header a node is resolved
+// for.
+type requestHostKey struct{}
+
+// withRequestHost returns a context in which the host resolver matches host
+// to node names.
+func withRequestHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, requestHostKey{}, host)
+}
+
+// virtualHostName returns the node name a Host header selects under one of
+// VirtualHostSuffixes, such as web01 for web01.metadata.example.com:80 under
+// metadata.example.com, in lower case. It returns empty when the host is not
+// under any of them.
+func (h *Handler) virtualHostName(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, suffix := range h.VirtualHostSuffixes {
+		suffix = strings.ToLower(strings.Trim(suffix, "."))
+		if name, ok := strings.CutSuffix(host, "."+suffix); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// hostResolver finds the node named by the Host header of the request, under
+// one of VirtualHostSuffixes.
+type hostResolver struct {
+	h *Handler
+}
+
+func (r hostResolver) Name() string {
+	return resolverHost
+}
+
+func (r hostResolver) Resolve(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	host, _ := ctx.Value(requestHostKey{}).(string)
+	name := r.h.virtualHostName(host)
+	if name == "" {
+		traceNote(ctx, "host %q is not under a virtual host suffix", host)
+		return nil, nil
+	}
+
+	allNodes, err := r.h.nodeSource().ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range allNodes {
+		if strings.EqualFold(node.Name, name) || strings.EqualFold(node.UUID, name) {
+			traceNote(ctx, "host %s names node %s", host, node.UUID)
+			return &node, nil
+		}
+	}
+	r.h.logger().Debug().
+		Str("client_ip", clientIP).
+		Str("host", host).
+		Msg("No node named by the host of the request")
+	return nil, nil
+}
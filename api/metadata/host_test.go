@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_virtualHostName(t *testing.T) {
+	h := NewHandler(WithVirtualHostSuffixes("metadata.example.com", ".prov.example.com."))
+
+	tests := []struct {
+		have string
+		want string
+	}{
+		{have: "web01.metadata.example.com", want: "web01"},
+		{have: "WEB01.Metadata.Example.com:8080", want: "web01"},
+		{have: "web01.prov.example.com.", want: "web01"},
+		{have: "metadata.example.com"},
+		{have: "web01.other.example.com"},
+		{have: "169.254.169.254"},
+		{have: "[fd00::1]:80"},
+		{have: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.have, func(t *testing.T) {
+			if have := h.virtualHostName(tt.have); have != tt.want {
+				t.Errorf("have name %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_hostResolver(t *testing.T) {
+	web01 := nodes.Node{UUID: "node-1", Name: "web01", ProvisionState: "active"}
+	web02 := nodes.Node{UUID: "node-2", Name: "web02", ProvisionState: "active"}
+	routes := NewHandler(
+		WithNodeSource(mock.NewNodeSource(web01, web02)),
+		WithVirtualHostSuffixes("metadata.example.com"),
+	).Routes()
+
+	tests := []struct {
+		name       string
+		host       string
+		want       string
+		wantStatus int
+	}{
+		{name: "name", host: "web02.metadata.example.com", want: "node-2"},
+		{name: "uuid", host: "node-1.metadata.example.com", want: "node-1"},
+		{
+			name:       "unknown node",
+			host:       "web03.metadata.example.com",
+			wantStatus: http.StatusNotFound,
+		},
+		{name: "other host", host: "169.254.169.254", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json", nil)
+			req.Host = tt.host
+			req.RemoteAddr = "10.0.0.99:4321"
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if tt.wantStatus != 0 {
+				if rr.Code != tt.wantStatus {
+					t.Errorf("have status %d, want %d", rr.Code, tt.wantStatus)
+				}
+				return
+			}
+			var have struct {
+				UUID string `json:"uuid"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v: %s", err, rr.Body)
+			}
+			if have.UUID != tt.want {
+				t.Errorf("have node %q, want %q", have.UUID, tt.want)
+			}
+		})
+	}
+}
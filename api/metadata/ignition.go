@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/ignition"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// handleIgnitionConfig handles requests to /ignition/{version}/config.ign.
+func (h *Handler) handleIgnitionConfig(w http.ResponseWriter, r *http.Request) {
+	requested, err := ignition.ParseVersion(mux.Vars(r)["version"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := ignition.Negotiate(requested, r.Header.Get("Accept"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	node, _, ok := h.nodeForRequest(w, r, "ignition")
+	if !ok {
+		return
+	}
+
+	b, err := h.buildIgnitionConfig(node, version)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Str("ignition_version", version.String()).
+			Msg("Failed to build ignition config")
+		if errors.Is(err, ignition.ErrIncompatible) {
+			http.Error(w, err.Error(), http.StatusNotAcceptable)
+			return
+		}
+		http.Error(w, "Ignition config not available", http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", ignition.MediaType)
+	if _, err := w.Write(b); err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to write ignition response")
+	}
+}
+
+// buildIgnitionConfig returns the Ignition config of a node. A config stored
+// in instance_info["ignition"] or as user data is served verbatim, after
+// transpiling it when it is written as Butane; otherwise the user data is
+// translated into a config declaring version. Once user data has expired,
+// neither is served and the config only sets the hostname and SSH keys.
+func (h *Handler) buildIgnitionConfig(node *nodes.Node, version ignition.Version) ([]byte, error) {
+	stored, err := h.storedIgnitionConfig(node)
+	if err != nil {
+		return nil, err
+	}
+
+	userData, err := h.renderUserData(node)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		if _, ok := ignition.StoredVersion(userData); ok || ignition.IsButane(userData) {
+			stored = userData
+		}
+	}
+
+	if stored != nil && ignition.IsButane(stored) {
+		stored, _, err = ignition.TranslateButane(stored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transpile butane config: %w", err)
+		}
+	}
+
+	if stored != nil {
+		storedVersion, ok := ignition.StoredVersion(stored)
+		if !ok {
+			return nil, errors.New("stored ignition config has no valid version")
+		}
+		if err := ignition.CheckCompatible(storedVersion, version); err != nil {
+			return nil, err
+		}
+		return stored, nil
+	}
+
+	metaData := h.buildMetaData(node)
+	cfg, err := ignition.Translate(version, ignition.Params{
+		Hostname: metaData.Hostname,
+		SSHKeys:  sortedPublicKeys(metaData.PublicKeys),
+		UserData: userData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cfg)
+}
+
+// storedIgnitionConfig returns the config stored in instance_info, if any.
+// Like user data, it is not served after ActiveExpiry.
+func (h *Handler) storedIgnitionConfig(node *nodes.Node) ([]byte, error) {
+	if _, ok := node.InstanceInfo["ignition"]; ok && h.userDataExpired(node) {
+		return nil, nil
+	}
+	switch cfg := node.InstanceInfo["ignition"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(cfg), nil
+	default:
+		return json.Marshal(cfg)
+	}
+}
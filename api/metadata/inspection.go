@@ -0,0 +1,259 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// inventoryTimeout bounds fetching the inspection inventory of a node.
+const inventoryTimeout = 10 * time.Second
+
+// inventoryCache holds the inspection inventory of each node, along with
+// usage counters. Inventories are kept until the node is inspected again, or
+// evicted as the least recently used beyond CacheLimits.
+type inventoryCache struct {
+	mu       sync.Mutex
+	entries  lru.Cache[string, inventoryCacheEntry]
+	counters metrics.CacheCounters
+}
+
+// inventoryCacheEntry is an inventory, or nil for a node without one, and
+// when the inspection it came from finished.
+type inventoryCacheEntry struct {
+	finished time.Time
+	data     *nodes.InventoryData
+	stored   time.Time
+}
+
+// get returns the inventory of a node if it was fetched after the same
+// inspection.
+func (c *inventoryCache) get(nodeUUID string, finished time.Time) (*nodes.InventoryData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries.Get(nodeUUID)
+	hit := ok && entry.finished.Equal(finished)
+	c.counters.Lookup(hit)
+	if !hit {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// put stores the inventory of a node at now, replacing any earlier version
+// and evicting beyond limits.
+func (c *inventoryCache) put(
+	nodeUUID string, finished time.Time, data *nodes.InventoryData, limits lru.Limits,
+	now time.Time,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counters.Evict(c.entries.SetLimits(limits))
+	c.counters.Evict(c.entries.Put(nodeUUID,
+		inventoryCacheEntry{finished: finished, data: data, stored: now}, jsonSize(data)))
+}
+
+// delete drops the inventory of a node.
+func (c *inventoryCache) delete(nodeUUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.Delete(nodeUUID)
+}
+
+// stats returns the usage of the cache.
+func (c *inventoryCache) stats() metrics.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.counters.Stats(c.entries.Len(), func(yield func(time.Time) bool) {
+		for _, entry := range c.entries.All() {
+			if !yield(entry.stored) {
+				return
+			}
+		}
+	})
+	stats.Bytes = c.entries.Bytes()
+	return stats
+}
+
+// inspectedNetworkData builds the network data of a node from the inventory
+// inspection recorded. It returns false when InspectionNetworkData is off or
+// the node has no inspected interfaces.
+func (h *Handler) inspectedNetworkData(node *nodes.Node) (*metadata.NetworkData, bool) {
+	if !h.InspectionNetworkData || node.InspectionFinishedAt == nil {
+		return nil, false
+	}
+	data := h.nodeInventory(node)
+	if data == nil {
+		return nil, false
+	}
+	networkData := networkDataFromInventory(data)
+	return networkData, len(networkData.Links) > 0
+}
+
+// nodeInventory returns the inspection inventory of a node, or nil when it
+// has none or it cannot be fetched. The result is shared and must not be
+// modified.
+func (h *Handler) nodeInventory(node *nodes.Node) *nodes.InventoryData {
+	finished := *node.InspectionFinishedAt
+	if data, ok := h.inventoryCache.get(node.UUID, finished); ok {
+		return data
+	}
+	source, ok := h.nodeSource().(client.InventorySource)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), inventoryTimeout)
+	defer cancel()
+
+	data, err := source.GetInventory(ctx, node.UUID)
+	if err != nil {
+		if !gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+			h.logger().Warn().
+				Err(err).
+				Str("node_uuid", node.UUID).
+				Msg("Failed to get inspection inventory")
+			return nil
+		}
+		// Remember that the node has no inventory.
+		data = nil
+	}
+	h.inventoryCache.put(node.UUID, finished, data, h.CacheLimits, h.now())
+	return data
+}
+
+// networkDataFromInventory builds network data with a link for each
+// inspected interface, named after it and carrying its MAC address and
+// speed, along with the switch port LLDP reported for it. DHCP is configured
+// on the interface the node booted from, or the first one.
+func networkDataFromInventory(data *nodes.InventoryData) *metadata.NetworkData {
+	networkData := &metadata.NetworkData{
+		Links:    []metadata.Link{},
+		Networks: []metadata.Network{},
+		Services: []metadata.Service{},
+	}
+	// Plugin data in another format leaves the links without LLDP hints.
+	plugin, _ := data.PluginData.AsStandardData()
+	pxeMAC := normalizePXEInterface(data.Inventory.Boot.PXEInterface)
+
+	var bootLink string
+	for _, iface := range data.Inventory.Interfaces {
+		mac := strings.ToLower(iface.MACAddress)
+		if iface.Name == "" || mac == "" {
+			continue
+		}
+		link := metadata.Link{
+			ID:                 iface.Name,
+			Type:               "phy",
+			EthernetMacAddress: mac,
+			SpeedMbps:          iface.SpeedMbps,
+		}
+		if lldp := plugin.ParsedLLDP[iface.Name]; lldp != nil {
+			link.SwitchID, _ = lldp["switch_chassis_id"].(string)
+			link.SwitchInfo, _ = lldp["switch_system_name"].(string)
+			link.SwitchPortID, _ = lldp["switch_port_id"].(string)
+			if mtu, ok := lldp["switch_port_mtu"].(float64); ok {
+				link.MTU = int(mtu)
+			}
+		}
+		networkData.Links = append(networkData.Links, link)
+		if mac == pxeMAC {
+			bootLink = link.ID
+		}
+	}
+	if len(networkData.Links) == 0 {
+		return networkData
+	}
+	if bootLink == "" {
+		bootLink = networkData.Links[0].ID
+	}
+
+	networkData.Networks = append(networkData.Networks, metadata.Network{
+		ID:        "network0",
+		Type:      "ipv4_dhcp",
+		Link:      bootLink,
+		NetworkID: "network0",
+	})
+	return networkData
+}
+
+// normalizePXEInterface returns the MAC address of the PXE interface of an
+// inventory, which may be given in the 01-aa-bb-cc-dd-ee-ff form of PXE
+// configuration file names.
+func normalizePXEInterface(pxe string) string {
+	pxe = strings.ToLower(pxe)
+	if rest, ok := strings.CutPrefix(pxe, "01-"); ok && strings.Count(rest, "-") == 5 {
+		pxe = strings.ReplaceAll(rest, "-", ":")
+	}
+	return pxe
+}
+
+// addHardware adds the hardware summary of a node to a vendor data document
+// under hardware, when HardwareVendorData is on and the node was inspected.
+func (h *Handler) addHardware(node *nodes.Node, data map[string]any) {
+	if !h.HardwareVendorData || node.InspectionFinishedAt == nil {
+		return
+	}
+	inventory := h.nodeInventory(node)
+	if inventory == nil {
+		return
+	}
+	data["hardware"] = hardwareFromInventory(*node.InspectionFinishedAt, inventory)
+}
+
+// hardwareFromInventory summarizes the hardware of an inspection inventory.
+func hardwareFromInventory(inspectedAt time.Time, data *nodes.InventoryData) *metadata.Hardware {
+	inv := data.Inventory
+	hardware := &metadata.Hardware{
+		InspectedAt: inspectedAt,
+		System: metadata.HardwareSystem{
+			Manufacturer: inv.SystemVendor.Manufacturer,
+			ProductName:  inv.SystemVendor.ProductName,
+			SerialNumber: inv.SystemVendor.SerialNumber,
+		},
+		CPU: metadata.HardwareCPU{
+			Architecture: inv.CPU.Architecture,
+			ModelName:    inv.CPU.ModelName,
+			Count:        inv.CPU.Count,
+			Frequency:    inv.CPU.Frequency,
+		},
+		MemoryMB: inv.Memory.PhysicalMb,
+		Disks:    []metadata.HardwareDisk{},
+		NICs:     []metadata.HardwareNIC{},
+	}
+	for _, disk := range inv.Disks {
+		hardware.Disks = append(hardware.Disks, metadata.HardwareDisk{
+			Name:       disk.Name,
+			Model:      disk.Model,
+			Size:       disk.Size,
+			Rotational: disk.Rotational,
+			Serial:     disk.Serial,
+			WWN:        disk.Wwn,
+		})
+	}
+	for _, iface := range inv.Interfaces {
+		if iface.MACAddress == "" {
+			continue
+		}
+		hardware.NICs = append(hardware.NICs, metadata.HardwareNIC{
+			Name:       iface.Name,
+			MACAddress: strings.ToLower(iface.MACAddress),
+			SpeedMbps:  iface.SpeedMbps,
+			Vendor:     iface.Vendor,
+			Product:    iface.Product,
+		})
+	}
+	return hardware
+}
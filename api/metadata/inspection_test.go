@@ -0,0 +1,199 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/inventory"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_inspectionNetworkData(t *testing.T) {
+	inspected := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	source := mock.NewNodeSource(
+		nodes.Node{UUID: "node-1", Name: "10.0.0.5", InspectionFinishedAt: &inspected},
+		nodes.Node{UUID: "node-2", Name: "10.0.0.6"},
+		nodes.Node{UUID: "node-3", Name: "10.0.0.7", InspectionFinishedAt: &inspected},
+	)
+	source.AddInventory("node-1", &nodes.InventoryData{
+		Inventory: inventory.InventoryType{
+			Boot: inventory.BootInfoType{PXEInterface: "01-52-54-00-00-00-02"},
+			Interfaces: []inventory.InterfaceType{
+				{Name: "eno1", MACAddress: "52:54:00:00:00:01", SpeedMbps: 10000},
+				{Name: "eno2", MACAddress: "52:54:00:00:00:02", SpeedMbps: 25000},
+				{Name: "lo"},
+			},
+		},
+		PluginData: nodes.PluginData{RawMessage: json.RawMessage(`{"parsed_lldp": {"eno2": {
+			"switch_chassis_id": "00:1b:21:00:00:0a",
+			"switch_system_name": "leaf-a",
+			"switch_port_id": "Ethernet1/5",
+			"switch_port_mtu": 9000
+		}}}`)},
+	})
+
+	h := createTestHandler()
+	h.Nodes = source
+	h.InspectionNetworkData = true
+
+	tests := []struct {
+		name         string
+		clientIP     string
+		wantLinks    []metadata.Link
+		wantBootLink string
+	}{
+		{
+			name:     "inspected",
+			clientIP: "10.0.0.5",
+			wantLinks: []metadata.Link{
+				{
+					ID:                 "eno1",
+					Type:               "phy",
+					EthernetMacAddress: "52:54:00:00:00:01",
+					SpeedMbps:          10000,
+				},
+				{
+					ID:                 "eno2",
+					Type:               "phy",
+					EthernetMacAddress: "52:54:00:00:00:02",
+					MTU:                9000,
+					SpeedMbps:          25000,
+					SwitchID:           "00:1b:21:00:00:0a",
+					SwitchInfo:         "leaf-a",
+					SwitchPortID:       "Ethernet1/5",
+				},
+			},
+			wantBootLink: "eno2",
+		},
+		{
+			name:         "never inspected",
+			clientIP:     "10.0.0.6",
+			wantLinks:    []metadata.Link{{ID: "eth0", Type: "physical", MTU: 1500}},
+			wantBootLink: "eth0",
+		},
+		{
+			name:         "inventory missing",
+			clientIP:     "10.0.0.7",
+			wantLinks:    []metadata.Link{{ID: "eth0", Type: "physical", MTU: 1500}},
+			wantBootLink: "eth0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/openstack/latest/network_data.json", nil)
+			req.RemoteAddr = tt.clientIP + ":40000"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			var have metadata.NetworkData
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have.Links, tt.wantLinks) {
+				t.Errorf("have links %+v, want %+v", have.Links, tt.wantLinks)
+			}
+			if len(have.Networks) != 1 || have.Networks[0].Link != tt.wantBootLink {
+				t.Errorf("have networks %+v, want one on %q", have.Networks, tt.wantBootLink)
+			}
+		})
+	}
+
+	// Inventories, and their absence, are cached until the next inspection.
+	calls := source.Calls(mock.MethodGetInventory)
+	for _, clientIP := range []string{"10.0.0.5", "10.0.0.7"} {
+		req := httptest.NewRequest("GET", "/openstack/latest/network_data.json", nil)
+		req.RemoteAddr = clientIP + ":40000"
+		h.Routes().ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if have := source.Calls(mock.MethodGetInventory); have != calls {
+		t.Errorf("have %d inventory fetches, want %d", have, calls)
+	}
+}
+
+func TestHandler_hardwareVendorData(t *testing.T) {
+	inspected := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	source := mock.NewNodeSource(
+		nodes.Node{UUID: "node-1", Name: "10.0.0.5", InspectionFinishedAt: &inspected},
+		nodes.Node{UUID: "node-2", Name: "10.0.0.6"},
+	)
+	source.AddInventory("node-1", &nodes.InventoryData{
+		Inventory: inventory.InventoryType{
+			CPU:    inventory.CPUType{Architecture: "x86_64", Count: 64, ModelName: "EPYC 7543"},
+			Memory: inventory.MemoryType{PhysicalMb: 262144},
+			Disks: []inventory.RootDiskType{
+				{Name: "/dev/nvme0n1", Model: "PM9A3", Size: 1920383410176},
+			},
+			Interfaces: []inventory.InterfaceType{
+				{Name: "eno1", MACAddress: "52:54:00:00:00:01", SpeedMbps: 25000},
+			},
+			SystemVendor: inventory.SystemVendorType{
+				Manufacturer: "Dell Inc.",
+				ProductName:  "R6525",
+			},
+		},
+	})
+
+	h := createTestHandler()
+	h.Nodes = source
+	h.HardwareVendorData = true
+
+	tests := []struct {
+		name         string
+		path         string
+		clientIP     string
+		wantHardware bool
+	}{
+		{name: "vendor_data", path: "vendor_data.json", clientIP: "10.0.0.5", wantHardware: true},
+		{name: "vendor_data2", path: "vendor_data2.json", clientIP: "10.0.0.5", wantHardware: true},
+		{name: "never inspected", path: "vendor_data.json", clientIP: "10.0.0.6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/openstack/latest/"+tt.path, nil)
+			req.RemoteAddr = tt.clientIP + ":40000"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			var have struct {
+				Hardware *metadata.Hardware `json:"hardware"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantHardware {
+				if have.Hardware != nil {
+					t.Errorf("have hardware %+v, want none", have.Hardware)
+				}
+				return
+			}
+			if have.Hardware == nil {
+				t.Fatal("expected hardware")
+			}
+			if have.Hardware.CPU.Count != 64 || have.Hardware.MemoryMB != 262144 ||
+				have.Hardware.System.ProductName != "R6525" ||
+				!have.Hardware.InspectedAt.Equal(inspected) {
+				t.Errorf("have hardware %+v", have.Hardware)
+			}
+			if len(have.Hardware.Disks) != 1 || have.Hardware.Disks[0].Size != 1920383410176 {
+				t.Errorf("have disks %+v, want /dev/nvme0n1", have.Hardware.Disks)
+			}
+			if len(have.Hardware.NICs) != 1 || have.Hardware.NICs[0].SpeedMbps != 25000 {
+				t.Errorf("have nics %+v, want eno1", have.Hardware.NICs)
+			}
+		})
+	}
+}
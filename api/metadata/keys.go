@@ -0,0 +1,343 @@
+package metadata
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/allocations"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// publicKeysField is the field of instance_info, node.extra and allocation
+// extra holding the SSH keys of a node.
+const publicKeysField = "public_keys"
+
+// adminCertField is the field of instance_info and node.extra holding the
+// PEM certificate cloudbase-init maps to the administrator for WinRM.
+const adminCertField = "admin_cert"
+
+// adminCertChunkSize is the length of the admin_cert<n> keys of meta the
+// admin certificate is split into, the longest value Nova allows in
+// instance metadata.
+const adminCertChunkSize = 255
+
+// Allocations are cached for allocationCacheTTL, as their extra can change
+// without the node changing; fetching them is bounded by allocationTimeout.
+const (
+	allocationCacheTTL = time.Minute
+	allocationTimeout  = 10 * time.Second
+)
+
+// allocationCache holds the allocation of each node, along with usage
+// counters, evicting the least recently used beyond CacheLimits.
+type allocationCache struct {
+	mu       sync.Mutex
+	entries  lru.Cache[string, allocationCacheEntry]
+	counters metrics.CacheCounters
+}
+
+// allocationCacheEntry is an allocation, or nil for one that could not be
+// fetched, and the time it was stored.
+type allocationCacheEntry struct {
+	data   *allocations.Allocation
+	stored time.Time
+}
+
+// get returns an allocation stored less than allocationCacheTTL before now.
+func (c *allocationCache) get(uuid string, now time.Time) (*allocations.Allocation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries.Get(uuid)
+	hit := ok && now.Sub(entry.stored) < allocationCacheTTL
+	c.counters.Lookup(hit)
+	if !hit {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// put stores an allocation at now, replacing any earlier version and
+// evicting beyond limits.
+func (c *allocationCache) put(
+	uuid string, data *allocations.Allocation, limits lru.Limits, now time.Time,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counters.Evict(c.entries.SetLimits(limits))
+	c.counters.Evict(c.entries.Put(
+		uuid, allocationCacheEntry{data: data, stored: now}, jsonSize(data)))
+}
+
+// delete drops an allocation.
+func (c *allocationCache) delete(uuid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.Delete(uuid)
+}
+
+// stats returns the usage of the cache.
+func (c *allocationCache) stats() metrics.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.counters.Stats(c.entries.Len(), func(yield func(time.Time) bool) {
+		for _, entry := range c.entries.All() {
+			if !yield(entry.stored) {
+				return
+			}
+		}
+	})
+	stats.Bytes = c.entries.Bytes()
+	return stats
+}
+
+// nodeAllocation returns the allocation of a node, or nil when it has none
+// or it cannot be fetched. The result is shared and must not be modified.
+func (h *Handler) nodeAllocation(node *nodes.Node) *allocations.Allocation {
+	if node.AllocationUUID == "" {
+		return nil
+	}
+	if data, ok := h.allocationCache.get(node.AllocationUUID, h.now()); ok {
+		return data
+	}
+	source, ok := h.nodeSource().(client.AllocationSource)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), allocationTimeout)
+	defer cancel()
+
+	data, err := source.GetAllocation(ctx, node.AllocationUUID)
+	if err != nil && !gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Str("allocation_uuid", node.AllocationUUID).
+			Msg("Failed to get allocation")
+	}
+	// Failures are remembered too, so a broken allocation is not fetched on
+	// every request.
+	h.allocationCache.put(node.AllocationUUID, data, h.CacheLimits, h.now())
+	return data
+}
+
+// keySet collects the SSH keys of a node as both the public_keys map and the
+// keys list of meta_data.json, dropping keys already added under another
+// name.
+type keySet struct {
+	publicKeys map[string]string
+	keys       []metadata.Key
+	seen       map[string]bool
+}
+
+// newKeySet returns an empty keySet.
+func newKeySet() *keySet {
+	return &keySet{publicKeys: make(map[string]string), seen: make(map[string]bool)}
+}
+
+// add adds an SSH key. Keys without a name are named after their comment,
+// or else their algorithm, and names already taken get a numeric suffix.
+func (s *keySet) add(name, line string) error {
+	key, err := metadata.ParsePublicKey(line)
+	if err != nil {
+		return err
+	}
+	if s.seen[key.Blob] {
+		return nil
+	}
+	s.seen[key.Blob] = true
+
+	if name == "" {
+		name = strings.Fields(key.Comment + " " + key.Algorithm)[0]
+	}
+	unique := name
+	for i := 2; ; i++ {
+		if _, taken := s.publicKeys[unique]; !taken {
+			break
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+
+	s.publicKeys[unique] = key.Line
+	s.keys = append(s.keys, metadata.Key{Type: "ssh", Name: unique, Data: key.Line})
+	return nil
+}
+
+// addValue adds the keys of a public_keys value, which may be a map of names
+// to keys, a list of keys or of {name, data} objects, or a string holding
+// any of those as JSON or keys one per line.
+func (s *keySet) addValue(value any, report func(name string, err error)) {
+	addLines := func(name, lines string) {
+		for line := range strings.Lines(lines) {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := s.add(name, line); err != nil {
+				report(name, err)
+			}
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		trimmed := strings.TrimSpace(value)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			var decoded any
+			if err := json.Unmarshal([]byte(trimmed), &decoded); err == nil {
+				s.addValue(decoded, report)
+				return
+			}
+		}
+		addLines("", value)
+	case map[string]any:
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if line, ok := value[name].(string); ok {
+				addLines(name, line)
+			}
+		}
+	case []any:
+		for _, item := range value {
+			switch item := item.(type) {
+			case string:
+				addLines("", item)
+			case map[string]any:
+				name, _ := item["name"].(string)
+				data, _ := item["data"].(string)
+				if data == "" {
+					data, _ = item["key"].(string)
+				}
+				addLines(name, data)
+			}
+		}
+	}
+}
+
+// addKeys fills the public_keys and keys of meta_data.json with the SSH keys
+// of a node, taken from its configdrive, instance_info, the meta_data Metal3
+// writes to instance_info, node.extra and allocation extra in that order.
+func (h *Handler) addKeys(node *nodes.Node, metaData, configDrive *metadata.MetaData) {
+	keys := newKeySet()
+	source := ""
+	report := func(name string, err error) {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Str("source", source).
+			Str("key_name", name).
+			Msg("Skipping invalid SSH key")
+	}
+
+	if configDrive != nil {
+		source = "configdrive"
+		for _, key := range configDrive.Keys {
+			if key.Type != "" && key.Type != "ssh" {
+				// Keys of other types, such as x509, are served as given.
+				keys.keys = append(keys.keys, key)
+				continue
+			}
+			if err := keys.add(key.Name, key.Data); err != nil {
+				report(key.Name, err)
+			}
+		}
+		keys.addValue(stringMapValue(configDrive.PublicKeys), report)
+	}
+
+	source = "instance_info"
+	keys.addValue(node.InstanceInfo[publicKeysField], report)
+	if document, ok := h.metal3MetaData(node); ok {
+		source = "instance_info meta_data"
+		keys.addValue(document[publicKeysField], report)
+	}
+	source = "extra"
+	keys.addValue(node.Extra[publicKeysField], report)
+	if allocation := h.nodeAllocation(node); allocation != nil {
+		source = "allocation"
+		if value, ok := allocation.Extra[publicKeysField]; ok {
+			keys.addValue(value, report)
+		}
+	}
+
+	metaData.PublicKeys = keys.publicKeys
+	metaData.Keys = keys.keys
+	if metaData.Keys == nil {
+		metaData.Keys = []metadata.Key{}
+	}
+}
+
+// stringMapValue converts a map of strings to the map[string]any of decoded
+// JSON.
+func stringMapValue(values map[string]string) map[string]any {
+	converted := make(map[string]any, len(values))
+	for key, value := range values {
+		converted[key] = value
+	}
+	return converted
+}
+
+// addAdminCert serves the admin certificate of a node, from instance_info
+// or else node.extra, where cloudbase-init looks for it: split into the
+// admin_cert0, admin_cert1, ... keys of meta, and as an x509 key.
+func (h *Handler) addAdminCert(node *nodes.Node, metaData *metadata.MetaData) {
+	if h.redactedKey(adminCertField) {
+		return
+	}
+	cert, _ := node.InstanceInfo[adminCertField].(string)
+	if cert == "" {
+		cert, _ = node.Extra[adminCertField].(string)
+	}
+	if cert = strings.TrimSpace(cert); cert == "" {
+		return
+	}
+	if err := validateCertificate(cert); err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Skipping invalid admin certificate")
+		return
+	}
+
+	for i := 0; i*adminCertChunkSize < len(cert); i++ {
+		chunk := cert[i*adminCertChunkSize : min((i+1)*adminCertChunkSize, len(cert))]
+		metaData.Meta[fmt.Sprintf("admin_cert%d", i)] = chunk
+	}
+	metaData.Keys = append(metaData.Keys, metadata.Key{
+		Type: "x509",
+		Name: adminCertField,
+		Data: cert,
+	})
+}
+
+// validateCertificate checks that a PEM document holds an X.509 certificate.
+func validateCertificate(cert string) error {
+	block, _ := pem.Decode([]byte(cert))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return errors.New("expected a PEM certificate")
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package metadata
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/allocations"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_addKeys(t *testing.T) {
+	const (
+		alice = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5QQ== alice@example.com"
+		bob   = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQAB"
+		carol = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTI= carol"
+		dave  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5RA== dave"
+	)
+
+	tests := []struct {
+		name        string
+		node        nodes.Node
+		configDrive *metadata.MetaData
+		allocation  *allocations.Allocation
+		want        []metadata.Key
+	}{
+		{
+			name: "none",
+			node: nodes.Node{UUID: "node-1"},
+			want: []metadata.Key{},
+		},
+		{
+			name: "instance info map",
+			node: nodes.Node{UUID: "node-1", InstanceInfo: map[string]any{
+				"public_keys": map[string]any{"default": alice, "ops": bob + "\n" + carol},
+			}},
+			want: []metadata.Key{
+				{Type: "ssh", Name: "default", Data: alice},
+				{Type: "ssh", Name: "ops", Data: bob},
+				{Type: "ssh", Name: "ops-2", Data: carol},
+			},
+		},
+		{
+			name: "every source",
+			node: nodes.Node{
+				UUID:           "node-1",
+				AllocationUUID: "alloc-1",
+				InstanceInfo: map[string]any{
+					"public_keys": []any{alice, map[string]any{"name": "bob", "data": bob}},
+				},
+				Extra: map[string]any{"public_keys": "# operators\n" + alice + "\n" + carol},
+			},
+			configDrive: &metadata.MetaData{
+				Keys: []metadata.Key{
+					{Type: "ssh", Name: "default", Data: bob},
+					{Type: "x509", Name: "ca", Data: "-----BEGIN CERTIFICATE-----"},
+				},
+			},
+			allocation: &allocations.Allocation{
+				UUID:  "alloc-1",
+				Extra: map[string]string{"public_keys": `{"dave": "` + dave + `"}`},
+			},
+			want: []metadata.Key{
+				{Type: "ssh", Name: "default", Data: bob},
+				{Type: "x509", Name: "ca", Data: "-----BEGIN CERTIFICATE-----"},
+				{Type: "ssh", Name: "alice@example.com", Data: alice},
+				{Type: "ssh", Name: "carol", Data: carol},
+				{Type: "ssh", Name: "dave", Data: dave},
+			},
+		},
+		{
+			name: "invalid keys",
+			node: nodes.Node{UUID: "node-1", InstanceInfo: map[string]any{
+				"public_keys": []any{"ssh-rsa AAAA...", "not a key", bob},
+			}},
+			want: []metadata.Key{{Type: "ssh", Name: "rsa", Data: bob}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := mock.NewNodeSource(tt.node)
+			if tt.allocation != nil {
+				source.AddAllocation(*tt.allocation)
+			}
+			h := createTestHandler()
+			h.Nodes = source
+
+			have := &metadata.MetaData{}
+			h.addKeys(&tt.node, have, tt.configDrive)
+			if !reflect.DeepEqual(have.Keys, tt.want) {
+				t.Errorf("have keys %+v, want %+v", have.Keys, tt.want)
+			}
+			for _, key := range tt.want {
+				if key.Type == "ssh" && have.PublicKeys[key.Name] != key.Data {
+					t.Errorf("have public key %q = %q, want %q",
+						key.Name, have.PublicKeys[key.Name], key.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_nodeAllocation(t *testing.T) {
+	source := mock.NewNodeSource()
+	source.AddAllocation(allocations.Allocation{UUID: "alloc-1", Name: "web01"})
+	h := createTestHandler()
+	h.Nodes = source
+
+	node := &nodes.Node{UUID: "node-1", AllocationUUID: "alloc-1"}
+	for range 2 {
+		if have := h.nodeAllocation(node); have == nil || have.Name != "web01" {
+			t.Fatalf("have allocation %+v, want web01", have)
+		}
+	}
+	if calls := source.Calls(mock.MethodGetAllocation); calls != 1 {
+		t.Errorf("have %d calls, want the allocation cached", calls)
+	}
+
+	missing := &nodes.Node{UUID: "node-2", AllocationUUID: "alloc-2"}
+	if have := h.nodeAllocation(missing); have != nil {
+		t.Errorf("have allocation %+v, want none", have)
+	}
+	if have := h.nodeAllocation(&nodes.Node{UUID: "node-3"}); have != nil {
+		t.Errorf("have allocation %+v for a node without one", have)
+	}
+}
+
+func TestHandler_addAdminCert(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Administrator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: der,
+	})))
+
+	tests := []struct {
+		name     string
+		node     nodes.Node
+		redacted []string
+		want     bool
+	}{
+		{name: "none", node: nodes.Node{UUID: "node-1"}},
+		{
+			name: "instance info",
+			node: nodes.Node{UUID: "node-1", InstanceInfo: map[string]any{"admin_cert": cert}},
+			want: true,
+		},
+		{
+			name: "extra",
+			node: nodes.Node{UUID: "node-1", Extra: map[string]any{"admin_cert": cert + "\n"}},
+			want: true,
+		},
+		{
+			name: "invalid",
+			node: nodes.Node{UUID: "node-1", InstanceInfo: map[string]any{
+				"admin_cert": "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----",
+			}},
+		},
+		{
+			name:     "redacted",
+			node:     nodes.Node{UUID: "node-1", InstanceInfo: map[string]any{"admin_cert": cert}},
+			redacted: []string{"admin_cert"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.RedactKeys = tt.redacted
+
+			have := &metadata.MetaData{Meta: map[string]string{}}
+			h.addAdminCert(&tt.node, have)
+			if !tt.want {
+				if len(have.Meta) != 0 || len(have.Keys) != 0 {
+					t.Errorf("unexpected admin certificate: %+v", have)
+				}
+				return
+			}
+
+			var chunks strings.Builder
+			for i := 0; ; i++ {
+				chunk, ok := have.Meta["admin_cert"+strconv.Itoa(i)]
+				if !ok {
+					break
+				}
+				if len(chunk) > adminCertChunkSize {
+					t.Errorf("have chunk %d of %d characters, want at most %d",
+						i, len(chunk), adminCertChunkSize)
+				}
+				chunks.WriteString(chunk)
+			}
+			if chunks.String() != cert {
+				t.Errorf("have chunks %q, want %q", chunks.String(), cert)
+			}
+			want := []metadata.Key{{Type: "x509", Name: "admin_cert", Data: cert}}
+			if !reflect.DeepEqual(have.Keys, want) {
+				t.Errorf("have keys %+v, want %+v", have.Keys, want)
+			}
+		})
+	}
+}
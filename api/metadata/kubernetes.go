@@ -0,0 +1,37 @@
+package metadata
+
+import (
+	"context"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// kubernetesTimeout bounds the Kubernetes API requests of a user data
+// lookup.
+const kubernetesTimeout = 10 * time.Second
+
+// kubernetesUserData looks up a node's user data in the Secrets and
+// ConfigMaps named after its name or UUID. ok is false when Kubernetes is
+// not configured or holds no user data for the node.
+func (h *Handler) kubernetesUserData(node *nodes.Node) (string, bool) {
+	if h.KubernetesUserData == nil {
+		return "", false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubernetesTimeout)
+	defer cancel()
+
+	userData, err := h.KubernetesUserData.UserData(ctx, node.Name, node.UUID)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to get user data from Kubernetes")
+		return "", false
+	}
+	if userData == nil {
+		return "", false
+	}
+	return string(userData), true
+}
@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/kube"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestGetUserData_kubernetes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/metal3/configmaps/web01-userdata" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"userData": "#cloud-config\nhostname: web01\n"}}`))
+	}))
+	defer srv.Close()
+
+	client, err := kube.New(kube.Config{Host: srv.URL, Namespace: "metal3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := createTestHandler()
+	h.KubernetesUserData = kube.NewUserDataSource(client, "{node}-userdata", "", 0)
+
+	tests := []struct {
+		name string
+		have *nodes.Node
+		want string
+	}{
+		{
+			name: "kubernetes",
+			have: &nodes.Node{UUID: "test-uuid", Name: "web01"},
+			want: "#cloud-config\nhostname: web01\n",
+		},
+		{
+			name: "instance_info takes precedence",
+			have: &nodes.Node{
+				UUID:         "test-uuid",
+				Name:         "web01",
+				InstanceInfo: map[string]any{"user_data": "#!/bin/sh\n"},
+			},
+			want: "#!/bin/sh\n",
+		},
+		{
+			name: "no user data",
+			have: &nodes.Node{UUID: "test-uuid", Name: "web02"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if have := h.getUserData(tt.have); have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// kvField is the extra field holding the key-value store of a node, whose
+// entries are merged into the meta of its metadata. Unlike instance_info,
+// extra survives undeploying the node.
+const kvField = "kv"
+
+// Limits of the key-value store of a node, those Nova applies to the
+// metadata of servers by default.
+const (
+	MaxKVEntries   = 128
+	MaxKVKeySize   = 255
+	MaxKVValueSize = 255
+)
+
+// maxKVRequestSize bounds the body of key-value store requests.
+const maxKVRequestSize = 64 << 10
+
+// nodeKV returns the key-value store of a node, skipping entries that are
+// not strings.
+func nodeKV(node *nodes.Node) map[string]string {
+	stored, _ := node.Extra[kvField].(map[string]any)
+	entries := make(map[string]string, len(stored))
+	for key, value := range stored {
+		if s, ok := value.(string); ok {
+			entries[key] = s
+		}
+	}
+	return entries
+}
+
+// validateKVEntry checks a key-value store entry against the limits of the
+// store.
+func validateKVEntry(key, value string) error {
+	switch {
+	case key == "":
+		return errors.New("keys cannot be empty")
+	case len(key) > MaxKVKeySize:
+		return fmt.Errorf("key %q is longer than %d bytes", key, MaxKVKeySize)
+	case !utf8.ValidString(key):
+		return fmt.Errorf("key %q is not valid UTF-8", key)
+	case len(value) > MaxKVValueSize:
+		return fmt.Errorf("value of %q is longer than %d bytes", key, MaxKVValueSize)
+	case !utf8.ValidString(value):
+		return fmt.Errorf("value of %q is not valid UTF-8", key)
+	}
+	return nil
+}
+
+// ErrKVInvalid is returned when an update of a key-value store holds an
+// invalid entry or would exceed MaxKVEntries.
+var ErrKVInvalid = errors.New("invalid key-value store")
+
+// PatchKV updates the key-value store of a node with patch, setting the
+// entries with a value and deleting those with a nil value, and returns
+// the updated store. Entries not in patch are kept.
+func (h *Handler) PatchKV(
+	ctx context.Context,
+	node *nodes.Node,
+	patch map[string]*string,
+) (map[string]string, error) {
+	return h.updateKV(ctx, node, patch, false)
+}
+
+// ReplaceKV replaces the key-value store of a node with entries, removing
+// it when entries is empty.
+func (h *Handler) ReplaceKV(
+	ctx context.Context,
+	node *nodes.Node,
+	entries map[string]string,
+) (map[string]string, error) {
+	patch := make(map[string]*string, len(entries))
+	for key, value := range entries {
+		patch[key] = &value
+	}
+	return h.updateKV(ctx, node, patch, true)
+}
+
+// updateKV applies patch to the key-value store of a node, or to an empty
+// store when replace is set, and stores the result unless it is unchanged.
+func (h *Handler) updateKV(
+	ctx context.Context,
+	node *nodes.Node,
+	patch map[string]*string,
+	replace bool,
+) (map[string]string, error) {
+	for key, value := range patch {
+		if value == nil {
+			continue
+		}
+		if err := validateKVEntry(key, *value); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKVInvalid, err)
+		}
+	}
+
+	// The node may be cached from before its store was last updated.
+	current, err := h.currentNode(ctx, node.UUID)
+	if err != nil {
+		return nil, err
+	}
+	stored := nodeKV(current)
+	entries := make(map[string]string)
+	if !replace {
+		entries = maps.Clone(stored)
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(entries, key)
+		} else {
+			entries[key] = *value
+		}
+	}
+	if len(entries) > MaxKVEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrKVInvalid, MaxKVEntries)
+	}
+	_, exists := current.Extra[kvField]
+	switch {
+	case len(entries) == 0 && !exists:
+		return entries, nil
+	case len(entries) > 0 && maps.Equal(entries, stored):
+		return entries, nil
+	}
+
+	operation := nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/extra/" + kvField}
+	if len(entries) > 0 {
+		operation = nodes.UpdateOperation{
+			Op:    nodes.AddOp,
+			Path:  "/extra/" + kvField,
+			Value: entries,
+		}
+	}
+
+	if err := h.updateNode(ctx, node, operation); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// addKV merges the key-value store of a node into the meta of its
+// metadata, overriding node properties of the same name. Redacted keys are
+// left out.
+func (h *Handler) addKV(node *nodes.Node, metaData *metadata.MetaData) {
+	for key, value := range nodeKV(node) {
+		if !h.redactedKey(key) {
+			metaData.Meta[key] = value
+		}
+	}
+}
+
+// handleKV handles GET requests to /admin/nodes/{uuid}/kv, returning the
+// key-value store of the node.
+func (h *Handler) handleKV(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	h.writeJSONResponse(w, nodeKV(node))
+}
+
+// handleUpdateKV handles PUT and PATCH requests to /admin/nodes/{uuid}/kv.
+// PUT replaces the key-value store of the node with the JSON object of the
+// request, while PATCH merges it in, deleting the keys set to null.
+func (h *Handler) handleUpdateKV(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]*string
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxKVRequestSize))
+	if err := decoder.Decode(&patch); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Expected a JSON object of strings", http.StatusBadRequest)
+		return
+	}
+	replace := r.Method == http.MethodPut
+	if replace {
+		for key, value := range patch {
+			if value == nil {
+				delete(patch, key)
+			}
+		}
+	}
+
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	entries, err := h.updateKV(r.Context(), node, patch, replace)
+	if !h.kvUpdated(w, node, err) {
+		return
+	}
+	h.writeJSONResponse(w, entries)
+}
+
+// handleKVEntry handles GET requests to /admin/nodes/{uuid}/kv/{key},
+// returning the value of the entry as text.
+func (h *Handler) handleKVEntry(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	value, ok := nodeKV(node)[mux.Vars(r)["key"]]
+	if !ok {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	h.writeTextResponse(w, value)
+}
+
+// handleSetKVEntry handles PUT requests to /admin/nodes/{uuid}/kv/{key},
+// setting the entry to the request body.
+func (h *Handler) handleSetKVEntry(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxKVRequestSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	value := string(body)
+	_, err = h.PatchKV(r.Context(), node, map[string]*string{mux.Vars(r)["key"]: &value})
+	if !h.kvUpdated(w, node, err) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteKV handles DELETE requests to /admin/nodes/{uuid}/kv and
+// /admin/nodes/{uuid}/kv/{key}, removing the key-value store of the node or
+// one of its entries.
+func (h *Handler) handleDeleteKV(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	if key, ok := mux.Vars(r)["key"]; ok {
+		_, err = h.PatchKV(r.Context(), node, map[string]*string{key: nil})
+	} else {
+		_, err = h.ReplaceKV(r.Context(), node, nil)
+	}
+	if !h.kvUpdated(w, node, err) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// kvUpdated reports whether an update of the key-value store of a node
+// succeeded, writing an error response when it did not.
+func (h *Handler) kvUpdated(w http.ResponseWriter, node *nodes.Node, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, ErrKVInvalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if errors.Is(err, ErrNotLeader) {
+		writeNotLeader(w)
+		return false
+	}
+	if errors.Is(err, ErrNoNodeWriter) {
+		writeNoNodeWriter(w)
+		return false
+	}
+	h.logger().Error().
+		Err(err).
+		Str("node_uuid", node.UUID).
+		Msg("Failed to update key-value store")
+	http.Error(w, "Failed to update key-value store", http.StatusBadGateway)
+	return false
+}
@@ -0,0 +1,271 @@
+package metadata
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_kv(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantOp     string
+		wantValue  map[string]any
+	}{
+		{
+			name:       "get store",
+			method:     http.MethodGet,
+			path:       "/admin/nodes/node-1/kv",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"role":"web"}`,
+		},
+		{
+			name:       "get entry",
+			method:     http.MethodGet,
+			path:       "/admin/nodes/node-1/kv/role",
+			wantStatus: http.StatusOK,
+			wantBody:   "web",
+		},
+		{
+			name:       "get unknown entry",
+			method:     http.MethodGet,
+			path:       "/admin/nodes/node-1/kv/rack",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "replace store",
+			method:     http.MethodPut,
+			path:       "/admin/nodes/node-1/kv",
+			body:       `{"rack":"r12"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"rack":"r12"}`,
+			wantOp:     "add",
+			wantValue:  map[string]any{"rack": "r12"},
+		},
+		{
+			name:       "merge into store",
+			method:     http.MethodPatch,
+			path:       "/admin/nodes/node-1/kv",
+			body:       `{"rack":"r12"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"rack":"r12","role":"web"}`,
+			wantOp:     "add",
+			wantValue:  map[string]any{"rack": "r12", "role": "web"},
+		},
+		{
+			name:       "merge deleting every entry",
+			method:     http.MethodPatch,
+			path:       "/admin/nodes/node-1/kv",
+			body:       `{"role":null}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{}`,
+			wantOp:     "remove",
+		},
+		{
+			name:       "merge unchanged",
+			method:     http.MethodPatch,
+			path:       "/admin/nodes/node-1/kv",
+			body:       `{"role":"web"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"role":"web"}`,
+		},
+		{
+			name:       "set entry",
+			method:     http.MethodPut,
+			path:       "/admin/nodes/node-1/kv/rack",
+			body:       "r12",
+			wantStatus: http.StatusNoContent,
+			wantOp:     "add",
+			wantValue:  map[string]any{"rack": "r12", "role": "web"},
+		},
+		{
+			name:       "delete entry",
+			method:     http.MethodDelete,
+			path:       "/admin/nodes/node-1/kv/role",
+			wantStatus: http.StatusNoContent,
+			wantOp:     "remove",
+		},
+		{
+			name:       "delete unknown entry",
+			method:     http.MethodDelete,
+			path:       "/admin/nodes/node-1/kv/rack",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "clear store",
+			method:     http.MethodDelete,
+			path:       "/admin/nodes/node-1/kv",
+			wantStatus: http.StatusNoContent,
+			wantOp:     "remove",
+		},
+		{
+			name:       "value too long",
+			method:     http.MethodPut,
+			path:       "/admin/nodes/node-1/kv/rack",
+			body:       strings.Repeat("r", MaxKVValueSize+1),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not strings",
+			method:     http.MethodPatch,
+			path:       "/admin/nodes/node-1/kv",
+			body:       `{"rack":12}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := nodes.Node{
+				UUID:  "node-1",
+				Extra: map[string]any{kvField: map[string]any{"role": "web"}},
+			}
+
+			var patch []map[string]any
+			ironic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+				r *http.Request) {
+				if r.URL.Path != "/nodes/node-1" {
+					http.NotFound(w, r)
+					return
+				}
+				if r.Method == http.MethodPatch {
+					if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"uuid":  node.UUID,
+					"extra": node.Extra,
+				})
+			}))
+			defer ironic.Close()
+
+			h := createTestHandler()
+			h.AdminToken = "secret"
+			h.Nodes = mock.NewNodeSource(node)
+			h.Clients.SetIronicClient(&gophercloud.ServiceClient{
+				ProviderClient: &gophercloud.ProviderClient{},
+				Endpoint:       ironic.URL + "/",
+			})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantBody != "" {
+				if have := strings.TrimSpace(rr.Body.String()); have != tt.wantBody {
+					t.Errorf("have body %s, want %s", have, tt.wantBody)
+				}
+			}
+			if tt.wantOp == "" {
+				if patch != nil {
+					t.Errorf("unexpected patch: %v", patch)
+				}
+				return
+			}
+			if len(patch) != 1 || patch[0]["op"] != tt.wantOp ||
+				patch[0]["path"] != "/extra/"+kvField {
+				t.Fatalf("unexpected patch: %v", patch)
+			}
+			if tt.wantValue != nil {
+				value, _ := patch[0]["value"].(map[string]any)
+				if !maps.Equal(value, tt.wantValue) {
+					t.Errorf("have value %v, want %v", value, tt.wantValue)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_kv_nodeSource(t *testing.T) {
+	node := nodes.Node{
+		UUID:  "node-1",
+		Name:  "web01",
+		Extra: map[string]any{kvField: map[string]any{"role": "web"}},
+	}
+
+	tests := []struct {
+		name       string
+		source     func(*mock.NodeSource) client.NodeSource
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name:       "writable source",
+			source:     func(s *mock.NodeSource) client.NodeSource { return s },
+			wantStatus: http.StatusNoContent,
+			want:       map[string]string{"rack": "r12", "role": "web"},
+		},
+		{
+			name: "read-only source",
+			source: func(s *mock.NodeSource) client.NodeSource {
+				return client.NewCachedSource(s, time.Minute)
+			},
+			wantStatus: http.StatusNotImplemented,
+			want:       map[string]string{"role": "web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := mock.NewNodeSource(node)
+			h := NewHandler(WithNodeSource(tt.source(source)), WithAdminToken("secret"))
+
+			req := httptest.NewRequest(http.MethodPut, "/admin/nodes/node-1/kv/rack",
+				strings.NewReader("r12"))
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			stored, err := source.GetNode(t.Context(), "node-1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have := nodeKV(stored); !maps.Equal(have, tt.want) {
+				t.Errorf("have store %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_buildMetaData_kv(t *testing.T) {
+	h := createTestHandler()
+	h.RedactKeys = []string{"secret_*"}
+	node := &nodes.Node{
+		UUID:       "node-1",
+		Properties: map[string]any{"role": "db", "cpu_arch": "x86_64"},
+		Extra: map[string]any{kvField: map[string]any{
+			"role":         "web",
+			"secret_token": "hunter2",
+			"replicas":     3,
+		}},
+	}
+
+	have := h.buildMetaData(node).Meta
+	want := map[string]string{"role": "web", "cpu_arch": "x86_64"}
+	if !maps.Equal(have, want) {
+		t.Errorf("have meta %v, want %v", have, want)
+	}
+}
@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNotLeader is returned by writes to Ironic on a replica that is not
+// leader.
+var ErrNotLeader = errors.New("replica is not leader")
+
+// notLeaderRetryAfter is the Retry-After, in seconds, of the write requests
+// refused by a replica that is not leader.
+const notLeaderRetryAfter = "5"
+
+// LeaderStatus describes the leader election state of a replica.
+type LeaderStatus struct {
+	Enabled  bool   `json:"enabled"`
+	Leader   bool   `json:"leader"`
+	Identity string `json:"identity,omitempty"`
+}
+
+// isLeader reports whether the replica performs write-back features and
+// background syncs, which is always the case without leader election.
+func (h *Handler) isLeader() bool {
+	return h.Leader == nil || h.Leader.IsLeader()
+}
+
+// checkLeader returns ErrNotLeader unless the replica performs write-back
+// features.
+func (h *Handler) checkLeader() error {
+	if !h.isLeader() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// writeNotLeader answers a request whose write to Ironic was refused by a
+// replica that is not leader with 503 Service Unavailable, so the client
+// retries, reaching the leader.
+func writeNotLeader(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", notLeaderRetryAfter)
+	http.Error(w, "Not the leader replica, retry later", http.StatusServiceUnavailable)
+}
+
+// handleLeader handles GET requests to /admin/leader.
+func (h *Handler) handleLeader(w http.ResponseWriter, _ *http.Request) {
+	status := LeaderStatus{Enabled: h.Leader != nil, Leader: h.isLeader()}
+	if h.Leader != nil {
+		status.Identity = h.Leader.Identity()
+	}
+	h.writeJSONResponse(w, status)
+}
@@ -0,0 +1,201 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leader"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// staticLock is always held or never held.
+type staticLock bool
+
+func (l staticLock) TryAcquire(_ context.Context) (bool, error) { return bool(l), nil }
+func (l staticLock) Release(_ context.Context) error            { return nil }
+
+func TestHandleLeader(t *testing.T) {
+	tests := []struct {
+		name string
+		lock leader.Lock
+		want LeaderStatus
+	}{
+		{name: "disabled", want: LeaderStatus{Leader: true}},
+		{
+			name: "leader",
+			lock: staticLock(true),
+			want: LeaderStatus{Enabled: true, Leader: true, Identity: "replica-0"},
+		},
+		{
+			name: "follower",
+			lock: staticLock(false),
+			want: LeaderStatus{Enabled: true, Identity: "replica-0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.AdminToken = "secret"
+			if tt.lock != nil {
+				changed := make(chan bool, 1)
+				elector, err := leader.New(leader.Config{
+					Lock:     tt.lock,
+					Identity: "replica-0",
+					OnChange: func(leader bool) { changed <- leader },
+				})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				ctx, cancel := context.WithCancel(context.Background())
+				t.Cleanup(cancel)
+				go elector.Run(ctx)
+				if tt.want.Leader {
+					<-changed
+				}
+				h.Leader = elector
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/admin/leader", nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rr.Code)
+			}
+			var have LeaderStatus
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %+v, want %+v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_writeBackFollower(t *testing.T) {
+	// node-1 has everything to clear, node-2 no password yet.
+	stored := map[string]nodes.Node{
+		"node-1": {
+			UUID:           "node-1",
+			ProvisionState: "active",
+			InstanceInfo: map[string]any{
+				"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+				"password":  "b2xk",
+			},
+			Extra: map[string]any{
+				hostKeysField: map[string]any{"rsa": map[string]any{"public": "ssh-rsa AAAA"}},
+				kvField:       map[string]any{"role": "web"},
+			},
+		},
+		"node-2": {
+			UUID:           "node-2",
+			ProvisionState: "active",
+			InstanceInfo: map[string]any{
+				"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.6"}},
+			},
+		},
+	}
+
+	// Every request writing to Ironic is refused, and Ironic never patched.
+	var patches atomic.Int32
+	ironic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			patches.Add(1)
+		}
+		node, ok := stored[strings.TrimPrefix(r.URL.Path, "/nodes/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"uuid":          node.UUID,
+			"instance_info": node.InstanceInfo,
+			"extra":         node.Extra,
+		})
+	}))
+	defer ironic.Close()
+
+	elector, err := leader.New(leader.Config{Lock: staticLock(false), Identity: "replica-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	h.HostKeyEscrow = true
+	h.Leader = elector
+	h.Nodes = mock.NewNodeSource(stored["node-1"], stored["node-2"])
+	h.Clients.SetIronicClient(&gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       ironic.URL + "/",
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		remoteAddr string
+		body       string
+	}{
+		{name: "password", method: http.MethodPost, path: "/openstack/latest/password",
+			remoteAddr: "10.0.0.6:4321", body: "c2VjcmV0"},
+		{name: "phone home", method: http.MethodPost, path: "/phone_home",
+			body: url.Values{"pub_key_ed25519": {testHostKey}}.Encode()},
+		{name: "clear password", method: http.MethodDelete,
+			path: "/admin/nodes/node-1/password"},
+		{name: "clear host keys", method: http.MethodDelete,
+			path: "/admin/nodes/node-1/ssh_host_keys"},
+		{name: "kv", method: http.MethodPatch, path: "/admin/nodes/node-1/kv",
+			body: `{"rack": "r12"}`},
+		{name: "user data", method: http.MethodPut, path: "/admin/nodes/node-1/user_data",
+			body: "#cloud-config\nhostname: node-01\n"},
+		{name: "configdrive", method: http.MethodPost,
+			path: "/admin/nodes/node-1/configdrive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.RemoteAddr = "10.0.0.5:4321"
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			req.Header.Set("Authorization", "Bearer secret")
+			if tt.path == "/phone_home" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusServiceUnavailable {
+				t.Fatalf("have status %d, want %d: %s", rr.Code,
+					http.StatusServiceUnavailable, rr.Body)
+			}
+			if rr.Header().Get("Retry-After") == "" {
+				t.Error("expected a Retry-After header")
+			}
+		})
+	}
+	if have := patches.Load(); have != 0 {
+		t.Errorf("have %d patches of Ironic, want none", have)
+	}
+
+	// Reads are served as usual.
+	req := httptest.NewRequest(http.MethodGet, "/openstack/latest/password", nil)
+	req.RemoteAddr = "10.0.0.5:4321"
+	rr := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "b2xk" {
+		t.Errorf("have status %d and body %q, want the password", rr.Code, rr.Body)
+	}
+}
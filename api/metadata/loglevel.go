@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maxLogLevelRequestSize bounds the body of log level changes.
+const maxLogLevelRequestSize = 1 << 10
+
+// SetLogLevelRequest changes the log level.
+type SetLogLevelRequest struct {
+	// Level is a zerolog level such as debug or info.
+	Level string `json:"level"`
+
+	// Duration is how long the level lasts, such as 30m, defaulting to the
+	// configured revert duration. A level lasting 0s becomes the base level.
+	Duration string `json:"duration,omitempty"`
+}
+
+// handleLogLevel handles GET requests to /admin/loglevel.
+func (h *Handler) handleLogLevel(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSONResponse(w, h.LogLevel.Status())
+}
+
+// handleSetLogLevel handles PUT requests to /admin/loglevel, changing the
+// log level until it reverts to the base level.
+func (h *Handler) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req SetLogLevelRequest
+	body := http.MaxBytesReader(w, r.Body, maxLogLevelRequestSize)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	level, err := zerolog.ParseLevel(req.Level)
+	if err != nil || req.Level == "" {
+		http.Error(w, "Invalid log level", http.StatusBadRequest)
+		return
+	}
+	duration := h.LogLevel.RevertAfter()
+	if req.Duration != "" {
+		duration, err = time.ParseDuration(req.Duration)
+		if err != nil || duration < 0 {
+			http.Error(w, "Invalid duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.LogLevel.Set(level, duration)
+	status := h.LogLevel.Status()
+	event := h.logger().Info().
+		Str("log_level", status.Level).
+		Str("base_log_level", status.Base).
+		Str("remote_addr", r.RemoteAddr)
+	if status.RevertAt != nil {
+		event = event.Time("revert_at", *status.RevertAt)
+	}
+	event.Msg("Changed log level")
+
+	h.writeJSONResponse(w, status)
+}
@@ -0,0 +1,104 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/logging"
+	"github.com/rs/zerolog"
+)
+
+func TestHandleSetLogLevel(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+
+	tests := []struct {
+		name         string
+		body         string
+		want         int
+		wantLevel    string
+		wantBase     string
+		wantRevertAt bool
+	}{
+		{
+			name:         "default duration",
+			body:         `{"level": "debug"}`,
+			want:         http.StatusOK,
+			wantLevel:    "debug",
+			wantBase:     "info",
+			wantRevertAt: true,
+		},
+		{
+			name:         "duration",
+			body:         `{"level": "trace", "duration": "5m"}`,
+			want:         http.StatusOK,
+			wantLevel:    "trace",
+			wantBase:     "info",
+			wantRevertAt: true,
+		},
+		{
+			name:      "permanent",
+			body:      `{"level": "warn", "duration": "0s"}`,
+			want:      http.StatusOK,
+			wantLevel: "warn",
+			wantBase:  "warn",
+		},
+		{name: "invalid level", body: `{"level": "loud"}`, want: http.StatusBadRequest},
+		{name: "missing level", body: `{}`, want: http.StatusBadRequest},
+		{
+			name: "invalid duration",
+			body: `{"level": "debug", "duration": "soon"}`,
+			want: http.StatusBadRequest,
+		},
+		{name: "invalid body", body: `debug`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.AdminToken = "secret"
+			h.LogLevel = logging.NewLevel(zerolog.InfoLevel, time.Hour)
+			defer h.LogLevel.Reset()
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/admin/loglevel", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("expected status %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var have logging.LevelStatus
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have.Level != tt.wantLevel || have.Base != tt.wantBase ||
+				(have.RevertAt != nil) != tt.wantRevertAt {
+				t.Errorf("have %+v, want level %s, base %s", have, tt.wantLevel, tt.wantBase)
+			}
+			if zerolog.GlobalLevel().String() != tt.wantLevel {
+				t.Errorf("have global level %s, want %s", zerolog.GlobalLevel(), tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLogLevelRoutesDisabled(t *testing.T) {
+	h := createTestHandler()
+	h.AdminToken = "secret"
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/loglevel", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	h.Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected log level routes to be disabled, got %d", rr.Code)
+	}
+}
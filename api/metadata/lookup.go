@@ -0,0 +1,129 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// LookupTrace is the response of /admin/lookup: how the resolver chain went
+// about finding the node of an IP.
+type LookupTrace struct {
+	IP        string          `json:"ip"`
+	Resolvers []*ResolverStep `json:"resolvers"`
+	// Resolver is the name of the resolver that found the node.
+	Resolver string      `json:"resolver,omitempty"`
+	Node     *LookupNode `json:"node,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// ResolverStep is the trace of one resolver of a lookup.
+type ResolverStep struct {
+	Name       string   `json:"name"`
+	Matched    bool     `json:"matched"`
+	Duration   string   `json:"duration"`
+	MACAddress string   `json:"mac_address,omitempty"`
+	Notes      []string `json:"notes,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// LookupNode identifies the node a lookup found.
+type LookupNode struct {
+	UUID           string `json:"uuid"`
+	Name           string `json:"name,omitempty"`
+	ProvisionState string `json:"provision_state,omitempty"`
+}
+
+type lookupTraceKey struct{}
+
+// withLookupTrace returns a context in which resolvers record their steps
+// in trace.
+func withLookupTrace(ctx context.Context, trace *LookupTrace) context.Context {
+	return context.WithValue(ctx, lookupTraceKey{}, trace)
+}
+
+// traceStep starts the step of a resolver in the trace of ctx, returning nil
+// when ctx is not tracing a lookup.
+func traceStep(ctx context.Context, name string) *ResolverStep {
+	trace, _ := ctx.Value(lookupTraceKey{}).(*LookupTrace)
+	if trace == nil {
+		return nil
+	}
+	step := &ResolverStep{Name: name}
+	trace.Resolvers = append(trace.Resolvers, step)
+	return step
+}
+
+// currentStep returns the step of the resolver running under ctx, or nil.
+func currentStep(ctx context.Context) *ResolverStep {
+	trace, _ := ctx.Value(lookupTraceKey{}).(*LookupTrace)
+	if trace == nil || len(trace.Resolvers) == 0 {
+		return nil
+	}
+	return trace.Resolvers[len(trace.Resolvers)-1]
+}
+
+// traceNote adds a note to the step of the resolver running under ctx.
+func traceNote(ctx context.Context, format string, args ...any) {
+	if step := currentStep(ctx); step != nil {
+		step.Notes = append(step.Notes, fmt.Sprintf(format, args...))
+	}
+}
+
+// traceMAC records the MAC address the resolver running under ctx found.
+func traceMAC(ctx context.Context, mac string) {
+	if step := currentStep(ctx); step != nil {
+		step.MACAddress = mac
+	}
+}
+
+// finish records the outcome of a resolver step.
+func (s *ResolverStep) finish(start time.Time, matched bool, err error) {
+	if s == nil {
+		return
+	}
+	s.Matched = matched
+	s.Duration = time.Since(start).String()
+	if err != nil {
+		s.Error = err.Error()
+	}
+}
+
+// handleLookup runs the resolver chain for the ip query parameter, with the
+// host parameter as the Host header of the request, and returns its trace,
+// without serving any metadata.
+func (h *Handler) handleLookup(w http.ResponseWriter, r *http.Request) {
+	ip := r.URL.Query().Get("ip")
+	if net.ParseIP(ip) == nil {
+		http.Error(w, "Invalid ip", http.StatusBadRequest)
+		return
+	}
+
+	h.writeJSONResponse(w, h.lookup(r.Context(), ip, r.URL.Query().Get("host")))
+}
+
+// lookup runs the resolver chain for ip, with host as the Host header of
+// the request, and returns its trace.
+func (h *Handler) lookup(ctx context.Context, ip, host string) *LookupTrace {
+	trace := &LookupTrace{IP: ip, Resolvers: []*ResolverStep{}}
+	ctx = withRequestHost(withLookupTrace(ctx, trace), host)
+	node, err := h.getNodeByIP(ctx, ip)
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	if node != nil {
+		trace.Node = &LookupNode{
+			UUID:           node.UUID,
+			Name:           node.Name,
+			ProvisionState: node.ProvisionState,
+		}
+		for _, step := range trace.Resolvers {
+			if step.Matched {
+				trace.Resolver = step.Name
+			}
+		}
+	}
+	return trace
+}
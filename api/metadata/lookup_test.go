@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestHandler_lookup(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	data := "4102444800 52:54:00:00:00:02 10.0.0.6 web02 *\n"
+	if err := os.WriteFile(leaseFile, []byte(data), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source := mock.NewNodeSource(
+		nodes.Node{UUID: "node-1", Name: "10.0.0.5"},
+		nodes.Node{UUID: "node-2", Name: "web02", ProvisionState: "active"},
+	)
+	source.AddPort(ports.Port{UUID: "port-2", NodeUUID: "node-2", Address: "52:54:00:00:00:02"})
+
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	h.Nodes = source
+	h.DHCPLeases = leases.NewFile(leaseFile)
+
+	tests := []struct {
+		name         string
+		ip           string
+		wantStatus   int
+		wantNode     string
+		wantResolver string
+		wantMAC      string
+		wantError    bool
+	}{
+		{name: "by IP", ip: "10.0.0.5", wantStatus: http.StatusOK, wantNode: "node-1",
+			wantResolver: resolverIP},
+		{name: "by lease", ip: "10.0.0.6", wantStatus: http.StatusOK, wantNode: "node-2",
+			wantResolver: resolverDHCPLease, wantMAC: "52:54:00:00:00:02"},
+		{name: "not found", ip: "10.0.0.7", wantStatus: http.StatusOK, wantError: true},
+		{name: "invalid ip", ip: "web02", wantStatus: http.StatusBadRequest},
+		{name: "missing ip", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/lookup?ip="+tt.ip, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var have LookupTrace
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have.IP != tt.ip {
+				t.Errorf("have ip %q, want %q", have.IP, tt.ip)
+			}
+			if (have.Error != "") != tt.wantError {
+				t.Errorf("have error %q, want error %v", have.Error, tt.wantError)
+			}
+			if len(have.Resolvers) == 0 {
+				t.Fatal("expected resolver steps")
+			}
+			if tt.wantNode == "" {
+				if have.Node != nil {
+					t.Errorf("have node %q, want none", have.Node.UUID)
+				}
+				return
+			}
+			if have.Node == nil || have.Node.UUID != tt.wantNode {
+				t.Fatalf("have node %+v, want %q", have.Node, tt.wantNode)
+			}
+			if have.Resolver != tt.wantResolver {
+				t.Errorf("have resolver %q, want %q", have.Resolver, tt.wantResolver)
+			}
+			last := have.Resolvers[len(have.Resolvers)-1]
+			if last.Name != tt.wantResolver || !last.Matched || len(last.Notes) == 0 {
+				t.Errorf("have last step %+v, want matched %q with notes", last, tt.wantResolver)
+			}
+			if last.MACAddress != tt.wantMAC {
+				t.Errorf("have mac %q, want %q", last.MACAddress, tt.wantMAC)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package metadata
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Formats of /admin/mappings. MappingsJSON and MappingsYAML also pick the
+// format of every JSON response.
+const (
+	MappingsJSON = "json"
+	MappingsYAML = "yaml"
+	MappingsCSV  = "csv"
+)
+
+// mappingsCSVHeader is the header row of the CSV export of /admin/mappings.
+var mappingsCSVHeader = []string{
+	"ip", "mac_address", "node_uuid", "node_name", "source", "detail", "updated_at",
+	"age_seconds",
+}
+
+// Mappings is the response of /admin/mappings: every IP address the service
+// can tie to a MAC address or a node.
+type Mappings struct {
+	Mappings []Mapping `json:"mappings"`
+
+	// Errors lists the sources that could not be read, whose mappings are
+	// missing.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Mapping ties an IP address to a MAC address, a node or both, as a resolver
+// would.
+type Mapping struct {
+	IP         string `json:"ip"`
+	MACAddress string `json:"mac_address,omitempty"`
+	NodeUUID   string `json:"node_uuid,omitempty"`
+	NodeName   string `json:"node_name,omitempty"`
+
+	// Source is the resolver the mapping comes from: ip for the addresses
+	// in node data, dhcp_lease and dhcp_ack for leases.
+	Source string `json:"source"`
+
+	// Detail is where the source holds the mapping, such as the fixed_ips
+	// of instance_info or the path of the lease file.
+	Detail string `json:"detail,omitempty"`
+
+	// UpdatedAt is when the node was last updated, or created, or the lease
+	// granted, and AgeSeconds how long ago. Both are omitted when unknown.
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	AgeSeconds *int64     `json:"age_seconds,omitempty"`
+}
+
+// csvRow returns the mapping as a row of the CSV export.
+func (m Mapping) csvRow() []string {
+	row := []string{m.IP, m.MACAddress, m.NodeUUID, m.NodeName, m.Source, m.Detail, "", ""}
+	if m.UpdatedAt != nil {
+		row[6] = m.UpdatedAt.Format(time.RFC3339)
+		row[7] = strconv.FormatInt(*m.AgeSeconds, 10)
+	}
+	return row
+}
+
+// nodeIP is an IP address a node holds in its node data, with the MAC
+// address of its link when known.
+type nodeIP struct {
+	ip    string
+	mac   string
+	where string
+}
+
+// nodeIPs returns the IP addresses a node holds in its configdrive, its
+// network_data field and the fixed_ips of its instance_info, which direct IP
+// matching finds it by.
+func (h *Handler) nodeIPs(node *nodes.Node) []nodeIP {
+	var ips []nodeIP
+	if configDrive, err := h.extractFromConfigDrive(node); err == nil &&
+		configDrive.NetworkData != nil {
+		ips = append(ips, networkDataIPs(configDrive.NetworkData, "configdrive network data")...)
+	}
+	if networkData, ok := h.ironicNetworkData(node); ok {
+		ips = append(ips, networkDataIPs(networkData, "node network data")...)
+	}
+	fixedIPs, _ := node.InstanceInfo["fixed_ips"].([]any)
+	for _, fixedIP := range fixedIPs {
+		ipMap, _ := fixedIP.(map[string]any)
+		if ip, ok := ipMap["ip_address"].(string); ok && ip != "" {
+			ips = append(ips, nodeIP{ip: ip, where: "instance_info fixed_ips"})
+		}
+	}
+	return ips
+}
+
+// networkDataIPs returns the addresses of the networks of network data, with
+// the MAC addresses of their links.
+func networkDataIPs(networkData *metadata.NetworkData, where string) []nodeIP {
+	macs := make(map[string]string, len(networkData.Links))
+	for _, link := range networkData.Links {
+		macs[link.ID] = link.EthernetMacAddress
+	}
+	var ips []nodeIP
+	for _, network := range networkData.Networks {
+		if network.Address != "" {
+			ips = append(ips, nodeIP{
+				ip:    network.Address,
+				mac:   strings.ToLower(macs[network.Link]),
+				where: where,
+			})
+		}
+	}
+	return ips
+}
+
+// handleMappings handles requests to /admin/mappings, exporting the mapping
+// table as JSON, or as CSV with format=csv, for audits and for seeding
+// static mappings when Ironic is lost.
+func (h *Handler) handleMappings(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = MappingsJSON
+	}
+	if format != MappingsJSON && format != MappingsYAML && format != MappingsCSV {
+		http.Error(w, "Invalid format, want json, yaml or csv", http.StatusBadRequest)
+		return
+	}
+
+	mappings, err := h.MappingTable(r.Context())
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Msg("Failed to list the nodes and ports of the mapping table")
+		http.Error(w, "Failed to list nodes", http.StatusBadGateway)
+		return
+	}
+
+	// YAML is converted from the JSON by yamlMiddleware.
+	if format != MappingsCSV {
+		h.writeJSONResponse(w, mappings)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="mappings.csv"`)
+	out := csv.NewWriter(w)
+	_ = out.Write(mappingsCSVHeader)
+	for _, m := range mappings.Mappings {
+		_ = out.Write(m.csvRow())
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		h.logger().Error().
+			Err(err).
+			Msg("Failed to write the mapping table")
+	}
+}
+
+// MappingTable builds the mapping table from the nodes, ports and DHCP
+// leases the resolvers read. Lease sources that cannot be read are reported
+// in Errors rather than failing the table.
+func (h *Handler) MappingTable(ctx context.Context) (*Mappings, error) {
+	allNodes, err := h.nodeSource().ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allPorts, err := h.nodeSource().ListPorts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := h.now()
+	result := &Mappings{Mappings: []Mapping{}}
+	byUUID := make(map[string]*nodes.Node, len(allNodes))
+	for i := range allNodes {
+		node := &allNodes[i]
+		byUUID[node.UUID] = node
+		updated := node.UpdatedAt
+		if updated.IsZero() {
+			updated = node.CreatedAt
+		}
+		for _, ip := range h.nodeIPs(node) {
+			m := Mapping{
+				IP:         ip.ip,
+				MACAddress: ip.mac,
+				NodeUUID:   node.UUID,
+				NodeName:   node.Name,
+				Source:     resolverIP,
+				Detail:     ip.where,
+			}
+			m.setAge(updated, now)
+			result.Mappings = append(result.Mappings, m)
+		}
+	}
+
+	nodeByMAC := make(map[string]*nodes.Node, len(allPorts))
+	for _, port := range allPorts {
+		if node, ok := byUUID[port.NodeUUID]; ok {
+			nodeByMAC[strings.ToLower(port.Address)] = node
+		}
+	}
+	addLeases := func(source, detail string, all []leases.Lease) {
+		for _, lease := range all {
+			m := Mapping{
+				IP:         lease.IP.String(),
+				MACAddress: strings.ToLower(lease.MAC),
+				Source:     source,
+				Detail:     detail,
+			}
+			if node, ok := nodeByMAC[m.MACAddress]; ok {
+				m.NodeUUID, m.NodeName = node.UUID, node.Name
+			}
+			m.setAge(lease.Starts, now)
+			result.Mappings = append(result.Mappings, m)
+		}
+	}
+	if h.DHCPACKs != nil {
+		addLeases(resolverDHCPACK, "captured DHCP ACKs", h.DHCPACKs.Leases())
+	}
+	dhcpLeases := h.dhcpLeases()
+	if all, err := dhcpLeases.Leases(); err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("dhcp_lease_file", dhcpLeases.Path()).
+			Msg("Failed to read DHCP leases for the mapping table")
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		addLeases(resolverDHCPLease, dhcpLeases.Path(), all)
+	}
+
+	slices.SortStableFunc(result.Mappings, func(a, b Mapping) int {
+		addrA, _ := netip.ParseAddr(a.IP)
+		addrB, _ := netip.ParseAddr(b.IP)
+		return addrA.Compare(addrB)
+	})
+	return result, nil
+}
+
+// setAge records that the mapping was last updated at updated, unless it is
+// unknown.
+func (m *Mapping) setAge(updated, now time.Time) {
+	if updated.IsZero() {
+		return
+	}
+	age := int64(now.Sub(updated).Seconds())
+	m.UpdatedAt, m.AgeSeconds = &updated, &age
+}
@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestHandler_mappings(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	data := "4102444800 52:54:00:00:00:02 10.0.0.6 web02 *\n" +
+		"4102444800 52:54:00:00:00:09 10.0.0.9 unknown *\n"
+	if err := os.WriteFile(leaseFile, []byte(data), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := mock.NewNodeSource(
+		nodes.Node{
+			UUID:      "node-1",
+			Name:      "web01",
+			UpdatedAt: now.Add(-time.Hour),
+			InstanceInfo: map[string]any{
+				"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+			},
+		},
+		nodes.Node{UUID: "node-2", Name: "web02"},
+	)
+	source.AddPort(ports.Port{UUID: "port-2", NodeUUID: "node-2", Address: "52:54:00:00:00:02"})
+
+	acks := leases.NewLearned()
+	acks.Add(leases.Lease{
+		IP:     netip.MustParseAddr("10.0.0.7"),
+		MAC:    "52:54:00:00:00:02",
+		Starts: now.Add(-time.Minute),
+		Active: true,
+	})
+
+	h := NewHandler(
+		WithNodeSource(source),
+		WithDHCPLeases(leases.NewFile(leaseFile)),
+		WithDHCPACKs(acks),
+		WithClock(func() time.Time { return now }),
+		WithAdminToken("secret"),
+	)
+
+	// want holds ip, mac_address, node_uuid, source and age_seconds.
+	want := [][]string{
+		{"10.0.0.5", "", "node-1", resolverIP, "3600"},
+		{"10.0.0.6", "52:54:00:00:00:02", "node-2", resolverDHCPLease, ""},
+		{"10.0.0.7", "52:54:00:00:00:02", "node-2", resolverDHCPACK, "60"},
+		{"10.0.0.9", "52:54:00:00:00:09", "", resolverDHCPLease, ""},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "json", wantStatus: http.StatusOK},
+		{name: "csv", query: "?format=csv", wantStatus: http.StatusOK},
+		{name: "unknown format", query: "?format=xml", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/mappings"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var have [][]string
+			if tt.name == "csv" {
+				rows, err := csv.NewReader(rr.Body).ReadAll()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !slices.Equal(rows[0], mappingsCSVHeader) {
+					t.Errorf("have header %v, want %v", rows[0], mappingsCSVHeader)
+				}
+				for _, row := range rows[1:] {
+					have = append(have, []string{row[0], row[1], row[2], row[4], row[7]})
+				}
+			} else {
+				var mappings Mappings
+				if err := json.Unmarshal(rr.Body.Bytes(), &mappings); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				for _, m := range mappings.Mappings {
+					row := []string{m.IP, m.MACAddress, m.NodeUUID, m.Source, ""}
+					if m.AgeSeconds != nil {
+						row[4] = strconv.FormatInt(*m.AgeSeconds, 10)
+					}
+					have = append(have, row)
+				}
+			}
+			if !slices.EqualFunc(have, want, slices.Equal) {
+				t.Errorf("have mappings %v, want %v", have, want)
+			}
+		})
+	}
+}
@@ -1,22 +1,41 @@
 package metadata
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/netip"
-	"os"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/appkins-org/ironic-metadata/pkg/blob"
 	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/configdrive"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+	"github.com/appkins-org/ironic-metadata/pkg/hostkeys"
+	"github.com/appkins-org/ironic-metadata/pkg/jws"
+	"github.com/appkins-org/ironic-metadata/pkg/kube"
+	"github.com/appkins-org/ironic-metadata/pkg/leader"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/logging"
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
 	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/identity"
+	"github.com/appkins-org/ironic-metadata/pkg/opa"
+	"github.com/appkins-org/ironic-metadata/pkg/plugins"
+	"github.com/appkins-org/ironic-metadata/pkg/profiles"
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/appkins-org/ironic-metadata/pkg/vault"
+	"github.com/appkins-org/ironic-metadata/pkg/vendordata"
 	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
-	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
 	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v2"
 )
@@ -29,39 +48,518 @@ const (
 	ClientIPKey ContextKey = "client_ip"
 )
 
-// Handler is the struct that implements the http.Handler interface.
+// Handler is the struct that implements the http.Handler interface. It is
+// built with NewHandler, or as a struct literal.
 type Handler struct {
 	Clients *client.Clients
+
+	// Nodes reads nodes and ports. Clients is used when it is nil.
+	Nodes client.NodeSource
+
+	// NodeWriter updates nodes for the features writing back to Ironic.
+	// Clients is used when it is nil, or else Nodes when it can update
+	// nodes. Writes fail with ErrNoNodeWriter when none can.
+	NodeWriter client.NodeWriter
+
+	// Resolvers find the node of a client IP, tried in order until one
+	// does. By default the IP is resolved by the Plugins, if any, then
+	// matched against node data and, when
+	// ReverseDNS is set, its hostnames against node names, then looked up in
+	// the captured DHCP ACKs, if any, and the DHCP lease file. The relay
+	// agent information of its lease is matched first when
+	// RelayAgentMatching is set, after the Host header of the request when
+	// VirtualHostSuffixes is.
+	Resolvers []client.Resolver
+
+	// DHCPLeases is the lease database of the DHCP server, in which the
+	// dhcp_lease resolver looks up the MAC address of a client IP. The
+	// dnsmasq lease file /shared/dnsmasq/dnsmasq.leases is read on every
+	// lookup when it is nil.
+	DHCPLeases *leases.File
+
+	// ReverseDNS, when set, enables the ptr resolver, tried after matching
+	// the IP against node data, which matches the hostnames of the client
+	// IP in reverse DNS to node names.
+	ReverseDNS *ReverseDNS
+
+	// DHCPACKs holds the leases learned from DHCP ACKs captured on the
+	// network. When set, the dhcp_ack resolver looks up the MAC address of a
+	// client IP in it before the dhcp_lease resolver.
+	DHCPACKs *leases.Learned
+
+	// RelayAgentMatching enables the relay_agent resolver, tried first,
+	// which matches the relay agent information of DHCP leases to the
+	// local_link_connection of Ironic ports. It disambiguates nodes whose
+	// IP addresses come from per-rack pools behind DHCP relays.
+	RelayAgentMatching bool
+
+	// VirtualHostSuffixes, when set, enables the host resolver, tried
+	// first, which selects the node named by the Host header of a request
+	// under one of the suffixes, such as web01 for
+	// web01.metadata.example.com under metadata.example.com. Any client can
+	// send any Host header, so it suits networks whose clients are trusted
+	// to ask for their own node.
+	VirtualHostSuffixes []string
+
+	// Identity signs EC2 instance identity documents. Signature endpoints
+	// are unavailable when it is nil.
+	Identity *identity.Signer
+
+	// Region is reported in EC2 instance identity documents.
+	Region string
+
+	// GCE enables the GCE-compatible routes below /computeMetadata/v1/.
+	GCE bool
+
+	// ContentDir holds injected file contents referenced by content_path,
+	// looked up as <ContentDir>/<node UUID>/<id> and then <ContentDir>/<id>.
+	ContentDir string
+
+	// AdminToken is the bearer token required on /admin routes. The admin
+	// API is disabled when it is empty.
+	AdminToken string
+
+	// TrustedProxies are the networks of the reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers name the client of a request.
+	// The headers of other clients are ignored, so instances cannot pass
+	// for other nodes.
+	TrustedProxies []netip.Prefix
+
+	// NodeHeaderTrust selects the credentials, such as TrustAdminToken,
+	// that make a request trusted to select its node with the X-Node-UUID
+	// or X-Node-Name header instead of being resolved by client IP.
+	// Requests carrying the headers without a trusted credential are
+	// rejected.
+	NodeHeaderTrust map[string]bool
+
+	// DebugOverrides lets any request select its node with the node query
+	// parameter, such as ?node=<uuid>, so rendering can be exercised for
+	// any node from a workstation. It exposes the metadata of every node
+	// to every client and is meant for development only.
+	DebugOverrides bool
+
+	// InspectionNetworkData builds the network data of nodes without a
+	// configdrive from the interfaces and LLDP data inspection found,
+	// instead of a single synthetic eth0.
+	InspectionNetworkData bool
+
+	// HardwareVendorData adds a hardware section to vendor_data.json and
+	// vendor_data2.json, summarizing the CPU, memory, disks and NICs
+	// inspection found, so instances can check their hardware class.
+	HardwareVendorData bool
+
+	// HostKeyEscrow stores the SSH host keys nodes post to /phone_home in
+	// their extra, and serves them back in vendor_data.json, so re-imaged
+	// nodes keep their host keys.
+	HostKeyEscrow bool
+
+	// HostKeySealer seals the private host keys nodes post alongside their
+	// public keys. Only public keys are escrowed when it is nil.
+	HostKeySealer *hostkeys.Sealer
+
+	// DefaultProjectID is the project_id of nodes with neither a lessee nor
+	// an owner, such as every node of a standalone Ironic.
+	DefaultProjectID string
+
+	// ServedProvisionStates holds the provision states in which the metadata
+	// of nodes is served. DefaultServedProvisionStates, those of nodes being
+	// deployed or running an instance, are served when it is nil, so a node
+	// being cleaned cannot read the user data of its previous tenant.
+	ServedProvisionStates map[string]bool
+
+	// GoneProvisionStates holds the provision states, such as deleting or
+	// cleaning, in which requests are answered with 410 Gone instead of 404
+	// Not Found, telling clients the instance is gone for good.
+	GoneProvisionStates map[string]bool
+
+	// ServedProjects holds the projects whose nodes are served, matched
+	// against the project a node is deployed for: its lessee, or else its
+	// owner, or else DefaultProjectID. Every project is served when it is
+	// nil, and the nodes of other projects are refused like unknown nodes,
+	// so an instance dedicated to a tenant cannot leak the user data of
+	// another even when a client is matched to the wrong node.
+	ServedProjects map[string]bool
+
+	// RejectMaintenance answers requests of nodes in maintenance with 503
+	// Service Unavailable. Their metadata is served by default, as nodes are
+	// put in maintenance with their instance running.
+	RejectMaintenance bool
+
+	// ActiveExpiry stops serving the user data of nodes, or every document
+	// of them when ActiveExpiryScope is ExpiryAll, once they have been
+	// active for that long, so secrets in user data are not exposed for the
+	// lifetime of the instance. Nothing expires when it is zero.
+	ActiveExpiry time.Duration
+
+	// ActiveExpiryScope is what stops being served once ActiveExpiry has
+	// passed, ExpiryUserData, the default, or ExpiryAll.
+	ActiveExpiryScope string
+
+	// IPConflictPolicy decides the node of a client IP that several nodes
+	// hold in their node data, ConflictRefuse, the default, ConflictDHCP or
+	// ConflictFirst.
+	IPConflictPolicy string
+
+	// ResponseValidation is the mode of checking meta_data.json and
+	// network_data.json against their schemas before they are served, one
+	// of ValidationOff, the default, ValidationLog and ValidationFail.
+	ResponseValidation string
+
+	// CacheLimits bounds each of the configdrive, allocation and inspection
+	// inventory caches, which evict their least recently used entries beyond
+	// it. The zero value leaves them unbounded.
+	CacheLimits lru.Limits
+
+	// Faults are injected into metadata responses when set, to test how
+	// clients cope. Nil injects none.
+	Faults *Faults
+
+	// Policy is asked for a decision on every successful response served
+	// for a node, which it can deny or strip fields from. Nil serves them
+	// as rendered.
+	Policy *opa.Client
+
+	// MutationHook is handed every successful response served for a node
+	// before the Policy decides on it, and may return it changed. Nil
+	// serves them as rendered.
+	MutationHook *hook.Hook
+
+	// MutationHookEndpoints holds the endpoints whose responses are handed
+	// to the MutationHook, such as meta_data.json. Empty hands it all.
+	MutationHookEndpoints map[string]bool
+
+	// MutationHookFailure is the policy on MutationHook failures,
+	// HookFailureFail or HookFailureServe.
+	MutationHookFailure string
+
+	// Plugins, when set, are WASM plugins handed the responses handed to
+	// the MutationHook after it, with its endpoints and failure policy, and
+	// enable the plugin resolver, which asks them for the node of a client
+	// IP before it is matched against node data.
+	Plugins *plugins.Plugins
+
+	// EventSinks, such as webhooks, are sent an event when a node fetches
+	// its user data or phones home, or no node is found for a client.
+	EventSinks []events.Sink
+
+	// configDriveCache holds parsed configdrives by node.
+	configDriveCache configDriveCache
+
+	// inventoryCache holds inspection inventories by node.
+	inventoryCache inventoryCache
+
+	// allocationCache holds the allocations of nodes by UUID.
+	allocationCache allocationCache
+
+	// drain tracks in-flight requests and whether the replica is draining.
+	drain drainState
+
+	// warming is set while the node inventory and leases are fetched at
+	// startup.
+	warming atomic.Bool
+
+	// clock returns the current time, time.Now when it is nil.
+	clock func() time.Time
+
+	// ConfigDrives downloads configdrives referenced by URL or Swift
+	// object. Such configdrives are ignored when it is nil.
+	ConfigDrives *configdrive.Fetcher
+
+	// SwiftTempURLKey signs temp URLs for configdrives uploaded to object
+	// storage. The account's key is used when it is empty.
+	SwiftTempURLKey string
+
+	// RedactKeys holds glob patterns of keys never served, matched against
+	// the properties of a node copied into meta, the tags of its extra and
+	// the meta_data keys of its instance_info, ignoring case.
+	RedactKeys []string
+
+	// TagsKey names the node.extra map holding instance tags. It defaults
+	// to "tags" when empty.
+	TagsKey string
+
+	// Vault resolves {{ vault "<path>#<field>" }} references in user data
+	// and vendor data. Documents with references fail to render when it is
+	// nil.
+	Vault *vault.Client
+
+	// KubernetesUserData looks up user data in Kubernetes Secrets and
+	// ConfigMaps for nodes without user data in Ironic.
+	KubernetesUserData *kube.UserDataSource
+
+	// UserDataFragmentsDir holds user data fragments layered before each
+	// node's own user data, in global/ and resource-class/<class>/.
+	UserDataFragmentsDir string
+
+	// RemoteUserData downloads user data that instance_info references by
+	// URL, swift:// or s3:// reference. Such user data is ignored when it
+	// is nil.
+	RemoteUserData *remote.Fetcher
+
+	// MaxUserDataSize bounds the decoded size of user data, and the size of
+	// downloaded user data. It defaults to blob.DefaultLimit when zero.
+	MaxUserDataSize int64
+
+	// VendorData fetches dynamic vendor data, served alongside the static
+	// vendor data in vendor_data2.json. Only static vendor data is served
+	// when it is nil.
+	VendorData *vendordata.Client
+
+	// VendorDataDir holds operator vendor_data.json and vendor_data2.json
+	// documents merged into the built-in vendor data, in global/ and
+	// resource-class/<class>/.
+	VendorDataDir string
+
+	// TemplateDir holds operator templates of meta_data.json,
+	// vendor_data.json and vendor_data2.json keys, in global/ and
+	// resource-class/<class>/, rendered with the TemplateContext of each
+	// node and merged into the documents.
+	TemplateDir string
+
+	// Profiles are the operator metadata profiles, whose meta, user data,
+	// vendor data and network defaults apply to the nodes they match.
+	Profiles []profiles.Profile
+
+	// JWS signs meta_data.json and user_data responses, so instances can
+	// verify them. Responses are unsigned when it is nil.
+	JWS *jws.Signer
+
+	// CacheControl overrides the Cache-Control header of cache classes,
+	// keyed by class name such as user_data or meta_data. An empty value
+	// omits the header.
+	CacheControl map[string]string
+
+	// DisabledRoutes holds the route families that are not served, such as
+	// ec2 or vendor_data.
+	DisabledRoutes map[string]bool
+
+	// BasePath mounts the routes under a path prefix such as /metadata, for
+	// sharing an ingress with other services. Requests without the prefix
+	// are still served, for proxies that rewrite it away.
+	BasePath string
+
+	// Leader elects the replica performing write-back features and
+	// background syncs. Every replica performs them when it is nil.
+	Leader *leader.Elector
+
+	// Logger receives the log output of the handler, such as a logger
+	// writing to a slog handler through logging.NewSlogWriter. The global
+	// zerolog logger is used when it is nil.
+	Logger *zerolog.Logger
+
+	// LogLevel changes the global log level through the admin API, which
+	// does not serve the log level when it is nil.
+	LogLevel *logging.Level
+
+	// Metrics instruments node resolution, caches and Ironic API calls.
+	// Nothing is recorded when it is nil.
+	Metrics *Metrics
+
+	// AccessLog receives a line per served request, separately from the
+	// application log. No access log is written when it is nil.
+	AccessLog *logging.AccessLog
+}
+
+// logger returns the logger of the handler.
+func (h *Handler) logger() *zerolog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return &log.Logger
 }
 
 // Routes sets up the HTTP routes for the metadata service.
 func (h *Handler) Routes() http.Handler {
+	handler := h.methodsHandler(h.router())
+	if base := cleanBasePath(h.BasePath); base != "" {
+		return stripBasePath(base, handler)
+	}
+	return handler
+}
+
+// router returns the router of the routes of the metadata service.
+func (h *Handler) router() *mux.Router {
 	r := mux.NewRouter()
 
-	// OpenStack metadata service routes
+	// Register the route families that are not disabled
+	if h.routesEnabled(RoutesOpenStack) {
+		h.openStackRoutes(r)
+	}
+	if h.routesEnabled(RoutesEC2) {
+		h.ec2Routes(r)
+	}
+	if h.GCE && h.routesEnabled(RoutesGCE) {
+		h.gceRoutes(r)
+	}
+	if h.routesEnabled(RoutesNoCloud) {
+		h.noCloudRoutes(r)
+	}
+	if h.routesEnabled(RoutesNetwork) {
+		h.networkRoutes(r)
+	}
+	if h.routesEnabled(RoutesIgnition) {
+		h.ignitionRoutes(r)
+	}
+	if h.routesEnabled(RoutesPhoneHome) {
+		h.phoneHomeRoutes(r)
+	}
+	if h.routesEnabled(RoutesBoot) {
+		h.bootRoutes(r)
+	}
+	if h.routesEnabled(RoutesHealth) {
+		h.healthRoutes(r)
+	}
+	if h.routesEnabled(RoutesOpenAPI) {
+		h.openAPIRoutes(r)
+	}
+	// Admin routes are only served when an admin token is configured
+	if h.AdminToken != "" && h.routesEnabled(RoutesAdmin) {
+		h.adminRoutes(r)
+	}
+
+	// Add middleware for HEAD requests, logging, fault injection, in-flight
+	// tracking, client IP detection, events, conditional requests,
+	// YAML negotiation, policy decisions and the mutation hook
+	r.Use(h.headMiddleware)
+	r.Use(h.loggingMiddleware)
+	r.Use(h.faultMiddleware)
+	r.Use(h.inFlightMiddleware)
+	r.Use(h.clientIPMiddleware)
+	r.Use(h.eventMiddleware)
+	r.Use(h.cacheControlMiddleware)
+	r.Use(h.conditionalMiddleware)
+	r.Use(h.yamlMiddleware)
+	r.Use(h.policyMiddleware)
+	r.Use(h.hookMiddleware)
+
+	return r
+}
+
+// openStackRoutes registers the OpenStack metadata service routes.
+func (h *Handler) openStackRoutes(r *mux.Router) {
 	r.HandleFunc("/openstack", h.handleOpenStackRoot).Methods("GET")
 	r.HandleFunc("/openstack/", h.handleOpenStackRoot).Methods("GET")
-	r.HandleFunc("/openstack/latest", h.handleLatestRoot).Methods("GET")
-	r.HandleFunc("/openstack/latest/", h.handleLatestRoot).Methods("GET")
-	r.HandleFunc("/openstack/latest/meta_data.json", h.handleMetaData).Methods("GET")
-	r.HandleFunc("/openstack/latest/network_data.json", h.handleNetworkData).Methods("GET")
-	r.HandleFunc("/openstack/latest/user_data", h.handleUserData).Methods("GET")
-	r.HandleFunc("/openstack/latest/vendor_data.json", h.handleVendorData).Methods("GET")
-	r.HandleFunc("/openstack/latest/vendor_data2.json", h.handleVendorData2).Methods("GET")
-
-	// EC2-compatible routes for compatibility
+	versionPrefix := "/openstack/" + h.openStackVersionPattern()
+	r.HandleFunc("/openstack/content/{id:[A-Za-z0-9_-]+}", h.handleOpenStackContent).Methods("GET")
+	r.HandleFunc(versionPrefix, h.handleLatestRoot).Methods("GET")
+	r.HandleFunc(versionPrefix+"/", h.handleLatestRoot).Methods("GET")
+	for _, file := range h.openStackFiles() {
+		handler := h.handleOpenStackVersion(file)
+		if file.signed && h.JWS != nil {
+			r.HandleFunc(versionPrefix+"/"+file.name+jwsSuffix, h.handleSignedEnvelope(handler)).
+				Methods("GET")
+			handler = h.signResponse(handler)
+		}
+		r.HandleFunc(versionPrefix+"/"+file.name, handler).Methods("GET")
+		if file.post != nil {
+			post := file
+			post.handler = file.post
+			r.HandleFunc(versionPrefix+"/"+file.name, h.handleOpenStackVersion(post)).
+				Methods("POST")
+		}
+	}
+}
+
+// ec2Routes registers the EC2-compatible routes.
+func (h *Handler) ec2Routes(r *mux.Router) {
 	r.HandleFunc("/", h.handleEC2Root).Methods("GET")
 	r.HandleFunc("/latest", h.handleEC2Latest).Methods("GET")
 	r.HandleFunc("/latest/", h.handleEC2Latest).Methods("GET")
 	r.HandleFunc("/latest/meta-data", h.handleEC2MetaData).Methods("GET")
 	r.HandleFunc("/latest/meta-data/", h.handleEC2MetaData).Methods("GET")
-	r.HandleFunc("/latest/user-data", h.handleUserData).Methods("GET")
+	r.HandleFunc("/latest/user-data", h.signResponse(h.handleUserData)).Methods("GET")
+	r.HandleFunc("/latest/meta-data/public-keys", h.handleEC2PublicKeys).Methods("GET")
+	r.HandleFunc("/latest/meta-data/public-keys/", h.handleEC2PublicKeys).Methods("GET")
+	r.HandleFunc("/latest/meta-data/public-keys/{index:[0-9]+}", h.handleEC2PublicKey).Methods("GET")
+	r.HandleFunc("/latest/meta-data/public-keys/{index:[0-9]+}/", h.handleEC2PublicKey).Methods("GET")
+	r.HandleFunc("/latest/meta-data/public-keys/{index:[0-9]+}/openssh-key", h.handleEC2OpenSSHKey).
+		Methods("GET")
+	r.HandleFunc("/latest/meta-data/tags", h.handleEC2Tags).Methods("GET")
+	r.HandleFunc("/latest/meta-data/tags/", h.handleEC2Tags).Methods("GET")
+	r.HandleFunc("/latest/meta-data/tags/instance", h.handleEC2InstanceTags).Methods("GET")
+	r.HandleFunc("/latest/meta-data/tags/instance/", h.handleEC2InstanceTags).Methods("GET")
+	r.HandleFunc("/latest/meta-data/tags/instance/{key}", h.handleEC2InstanceTag).Methods("GET")
+	r.HandleFunc("/latest/dynamic", h.handleEC2Dynamic).Methods("GET")
+	r.HandleFunc("/latest/dynamic/", h.handleEC2Dynamic).Methods("GET")
+	r.HandleFunc("/latest/dynamic/instance-identity", h.handleEC2InstanceIdentity).Methods("GET")
+	r.HandleFunc("/latest/dynamic/instance-identity/", h.handleEC2InstanceIdentity).Methods("GET")
+	r.HandleFunc("/latest/dynamic/instance-identity/document", h.handleEC2IdentityDocument).
+		Methods("GET")
+	r.HandleFunc("/latest/dynamic/instance-identity/signature", h.handleEC2IdentitySignature).
+		Methods("GET")
+	r.HandleFunc("/latest/dynamic/instance-identity/pkcs7", h.handleEC2IdentityPKCS7).Methods("GET")
+}
 
-	// Add middleware for logging and client IP detection
-	r.Use(h.loggingMiddleware)
-	r.Use(h.clientIPMiddleware)
+// gceRoutes registers the GCE-compatible routes, for images built for
+// Google Compute Engine.
+func (h *Handler) gceRoutes(r *mux.Router) {
+	r.PathPrefix(gcePrefix + "/").HandlerFunc(h.handleGCE).Methods("GET")
+}
 
-	return r
+// noCloudRoutes registers the NoCloud datasource routes, optionally pinned
+// to a network-config version.
+func (h *Handler) noCloudRoutes(r *mux.Router) {
+	for _, prefix := range []string{"/nocloud", "/nocloud/{version:v[12]}"} {
+		r.HandleFunc(prefix+"/meta-data", h.handleNoCloudMetaData).Methods("GET")
+		r.HandleFunc(prefix+"/user-data", h.handleNoCloudUserData).Methods("GET")
+		r.HandleFunc(prefix+"/network-config", h.handleNoCloudNetworkConfig).Methods("GET")
+	}
+}
+
+// networkRoutes registers the rendered network configuration routes.
+func (h *Handler) networkRoutes(r *mux.Router) {
+	r.HandleFunc("/network/netplan.yaml", h.handleNetplan).Methods("GET")
+	r.HandleFunc("/network/interfaces", h.handleENI).Methods("GET")
+	r.HandleFunc("/network/config", h.handleNetworkConfig).Methods("GET")
+}
+
+// ignitionRoutes registers the Ignition routes for Fedora CoreOS and RHCOS.
+func (h *Handler) ignitionRoutes(r *mux.Router) {
+	r.HandleFunc("/ignition/{version}/config.ign", h.handleIgnitionConfig).Methods("GET")
+}
+
+// phoneHomeRoutes registers the routes the phone_home module of cloud-init
+// posts to.
+func (h *Handler) phoneHomeRoutes(r *mux.Router) {
+	r.HandleFunc("/phone_home", h.handlePhoneHome).Methods("POST")
+	r.HandleFunc("/phone_home/{instance_id}", h.handlePhoneHome).Methods("POST")
+}
+
+// bootRoutes registers the network boot script routes.
+func (h *Handler) bootRoutes(r *mux.Router) {
+	r.HandleFunc("/ipxe/{id}", h.handleIPXE).Methods("GET")
+	r.HandleFunc("/grub/grub.cfg-{id}", h.handleGRUB).Methods("GET")
+}
+
+// adminRoutes registers the admin API routes.
+func (h *Handler) adminRoutes(r *mux.Router) {
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(h.adminMiddleware)
+	admin.HandleFunc("/nodes/{uuid}/seed.iso", h.handleNoCloudSeedISO).Methods("GET")
+	admin.HandleFunc("/nodes/{uuid}/configdrive", h.handleConfigDriveImage).Methods("GET")
+	admin.HandleFunc("/nodes/{uuid}/configdrive", h.handleConfigDriveAttach).Methods("POST")
+	admin.HandleFunc("/nodes/{uuid}/preview", h.handlePreview).Methods("GET")
+	admin.HandleFunc("/nodes/{uuid}/user_data", h.handleSetUserData).Methods("PUT")
+	admin.HandleFunc("/nodes/{uuid}/password", h.handleClearPassword).Methods("DELETE")
+	admin.HandleFunc("/nodes/{uuid}/ssh_host_keys", h.handleHostKeys).Methods("GET")
+	admin.HandleFunc("/nodes/{uuid}/ssh_host_keys", h.handleClearHostKeys).Methods("DELETE")
+	admin.HandleFunc("/nodes/{uuid}/kv", h.handleKV).Methods("GET")
+	admin.HandleFunc("/nodes/{uuid}/kv", h.handleUpdateKV).Methods("PUT", "PATCH")
+	admin.HandleFunc("/nodes/{uuid}/kv", h.handleDeleteKV).Methods("DELETE")
+	admin.HandleFunc("/nodes/{uuid}/kv/{key}", h.handleKVEntry).Methods("GET")
+	admin.HandleFunc("/nodes/{uuid}/kv/{key}", h.handleSetKVEntry).Methods("PUT")
+	admin.HandleFunc("/nodes/{uuid}/kv/{key}", h.handleDeleteKV).Methods("DELETE")
+	admin.HandleFunc("/lookup", h.handleLookup).Methods("GET")
+	admin.HandleFunc("/mappings", h.handleMappings).Methods("GET")
+	admin.HandleFunc("/selftest", h.handleSelfTest).Methods("GET")
+	admin.HandleFunc("/leader", h.handleLeader).Methods("GET")
+	admin.HandleFunc("/drain", h.handleDrainStatus).Methods("GET")
+	admin.HandleFunc("/drain", h.handleDrain).Methods("POST")
+	admin.HandleFunc("/drain", h.handleUndrain).Methods("DELETE")
+	if h.LogLevel != nil {
+		admin.HandleFunc("/loglevel", h.handleLogLevel).Methods("GET")
+		admin.HandleFunc("/loglevel", h.handleSetLogLevel).Methods("PUT")
+	}
 }
 
 // loggingMiddleware logs incoming requests.
@@ -75,11 +573,11 @@ func (h *Handler) loggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(wrapped, r)
 
 		// Log with comprehensive information
-		logEvent := log.Info()
+		logEvent := h.logger().Info()
 		if wrapped.statusCode >= 400 {
-			logEvent = log.Error()
-		} else if wrapped.statusCode >= 300 {
-			logEvent = log.Warn()
+			logEvent = h.logger().Error()
+		} else if wrapped.statusCode >= 300 && wrapped.statusCode != http.StatusNotModified {
+			logEvent = h.logger().Warn()
 		}
 
 		logEvent.
@@ -94,6 +592,30 @@ func (h *Handler) loggingMiddleware(next http.Handler) http.Handler {
 			Int64("response_size", wrapped.responseSize).
 			Dur("duration", time.Since(start)).
 			Msg("HTTP request")
+
+		if h.AccessLog != nil {
+			uri := r.RequestURI
+			if uri == "" {
+				uri = r.URL.RequestURI()
+			}
+			err := h.AccessLog.Log(logging.AccessEntry{
+				Time:      start,
+				ClientIP:  h.getClientIP(r),
+				Method:    r.Method,
+				URI:       uri,
+				Proto:     r.Proto,
+				Status:    wrapped.statusCode,
+				Size:      wrapped.responseSize,
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+				Duration:  time.Since(start),
+			})
+			if err != nil {
+				h.logger().Warn().
+					Err(err).
+					Msg("Failed to write access log")
+			}
+		}
 	})
 }
 
@@ -118,20 +640,41 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 // clientIPMiddleware extracts the client IP and stores it in the request context.
 func (h *Handler) clientIPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		clientIP := getClientIP(r)
+		clientIP := h.getClientIP(r)
 		ctx := context.WithValue(r.Context(), ClientIPKey, clientIP)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// getClientIP extracts the real client IP from the request.
-func getClientIP(r *http.Request) string {
+// getClientIP extracts the real client IP from the request: its remote
+// address or, for requests of TrustedProxies, the address they forwarded
+// in X-Forwarded-For or X-Real-IP.
+func (h *Handler) getClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("remote_addr", r.RemoteAddr).
+			Msg("Failed to split host:port from remote address, using as-is")
+		host = r.RemoteAddr
+	}
+
+	// Anyone can send the forwarding headers, so only those of trusted
+	// proxies are believed
+	if !h.trustedProxy(host) {
+		if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("X-Real-IP") != "" {
+			h.logger().Debug().
+				Str("remote_addr", r.RemoteAddr).
+				Msg("Ignoring forwarding headers of untrusted client")
+		}
+		return host
+	}
+
 	// Check X-Forwarded-For header first
 	xff := r.Header.Get("X-Forwarded-For")
 	if xff != "" {
-		ips := strings.Split(xff, ",")
-		clientIP := strings.TrimSpace(ips[0])
-		log.Debug().
+		clientIP := h.forwardedFor(xff)
+		h.logger().Debug().
 			Str("x_forwarded_for", xff).
 			Str("extracted_ip", clientIP).
 			Msg("Using IP from X-Forwarded-For header")
@@ -141,23 +684,13 @@ func getClientIP(r *http.Request) string {
 	// Check X-Real-IP header
 	xri := r.Header.Get("X-Real-IP")
 	if xri != "" {
-		log.Debug().
+		h.logger().Debug().
 			Str("x_real_ip", xri).
 			Msg("Using IP from X-Real-IP header")
 		return xri
 	}
 
-	// Fall back to remote address
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		log.Warn().
-			Err(err).
-			Str("remote_addr", r.RemoteAddr).
-			Msg("Failed to split host:port from remote address, using as-is")
-		return r.RemoteAddr
-	}
-
-	log.Debug().
+	h.logger().Debug().
 		Str("remote_addr", r.RemoteAddr).
 		Str("extracted_host", host).
 		Msg("Using IP from remote address")
@@ -173,197 +706,247 @@ func getClientIPFromContext(r *http.Request) (string, error) {
 	return clientIP, nil
 }
 
-// handleOpenStackRoot handles requests to /openstack.
-func (h *Handler) handleOpenStackRoot(w http.ResponseWriter, r *http.Request) {
-	versions := []string{"latest"}
-	h.writeJSONResponse(w, versions)
-}
-
-// handleLatestRoot handles requests to /openstack/latest.
-func (h *Handler) handleLatestRoot(w http.ResponseWriter, r *http.Request) {
-	endpoints := []string{
-		"meta_data.json",
-		"network_data.json",
-		"user_data",
-		"vendor_data.json",
-		"vendor_data2.json",
-	}
-	h.writeJSONResponse(w, endpoints)
-}
-
-// handleMetaData handles requests to /openstack/latest/meta_data.json.
-func (h *Handler) handleMetaData(w http.ResponseWriter, r *http.Request) {
+// nodeForRequest resolves the node issuing the request. When no node can be
+// resolved, or its metadata is not served in its provision state, an error
+// response is written and ok is false. A client IP several nodes hold is
+// refused with 409 Conflict under ConflictRefuse.
+func (h *Handler) nodeForRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	endpoint string,
+) (node *nodes.Node, clientIP string, ok bool) {
 	clientIP, err := getClientIPFromContext(r)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Str("request_path", r.URL.Path).
 			Str("method", r.Method).
 			Msg("Failed to get client IP from context")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return nil, "", false
 	}
 
-	log.Debug().
+	h.logger().Debug().
 		Str("client_ip", clientIP).
-		Str("endpoint", "meta_data.json").
-		Msg("Processing metadata request")
+		Str("endpoint", endpoint).
+		Msg("Processing node request")
+
+	switch {
+	case r.Header.Get(HeaderNodeUUID) != "" || r.Header.Get(HeaderNodeName) != "":
+		node, ok = h.nodeFromHeaders(w, r, endpoint)
+	case h.DebugOverrides && r.URL.Query().Get("node") != "":
+		node, ok = h.nodeFromQuery(w, r, endpoint)
+	default:
+		node, err = h.getNodeByIP(withRequestHost(r.Context(), r.Host), clientIP)
+		if err != nil {
+			// Recorded without a node, the endpoint marks a lookup failure.
+			setPolicySubject(r.Context(), nil, endpoint)
+		}
+		if errors.Is(err, ErrIPConflict) {
+			http.Error(w, "Several nodes hold the client IP", http.StatusConflict)
+			return nil, clientIP, false
+		}
+		if err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("client_ip", clientIP).
+				Str("endpoint", endpoint).
+				Msg("Failed to find node for client IP")
+			http.Error(w, "Node not found", http.StatusNotFound)
+			return nil, clientIP, false
+		}
 
-	node, err := h.getNodeByIP(r.Context(), clientIP)
-	if err != nil {
-		log.Error().
-			Err(err).
+		h.logger().Info().
 			Str("client_ip", clientIP).
-			Str("endpoint", "meta_data.json").
-			Msg("Failed to find node for client IP")
-		http.Error(w, "Node not found", http.StatusNotFound)
-		return
+			Str("node_uuid", node.UUID).
+			Str("node_name", node.Name).
+			Str("endpoint", endpoint).
+			Msg("Successfully matched client IP to node")
+		ok = true
+	}
+	if !ok || !h.serveNode(w, node, endpoint) {
+		return nil, clientIP, false
 	}
 
-	log.Info().
-		Str("client_ip", clientIP).
-		Str("node_uuid", node.UUID).
-		Str("node_name", node.Name).
-		Str("endpoint", "meta_data.json").
-		Msg("Successfully matched client IP to node")
+	setLastModified(w, node)
+	setPolicySubject(r.Context(), node, endpoint)
+	return node, clientIP, true
+}
 
-	metaData := h.buildMetaData(node)
-	h.writeJSONResponse(w, metaData)
+// handleOpenStackRoot handles requests to /openstack, listing the served
+// metadata versions one per line as cloud-init expects.
+func (h *Handler) handleOpenStackRoot(w http.ResponseWriter, r *http.Request) {
+	h.writeTextResponse(w, strings.Join(h.servedOpenStackVersions(), "\n"))
 }
 
-// handleNetworkData handles requests to /openstack/latest/network_data.json.
-func (h *Handler) handleNetworkData(w http.ResponseWriter, r *http.Request) {
-	clientIP, err := getClientIPFromContext(r)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("request_path", r.URL.Path).
-			Str("method", r.Method).
-			Msg("Failed to get client IP from context")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+// handleLatestRoot handles requests to /openstack/{version}, listing the
+// files available in that version.
+func (h *Handler) handleLatestRoot(w http.ResponseWriter, r *http.Request) {
+	version := openStackVersion(r)
+
+	var endpoints []string
+	for _, file := range h.openStackFiles() {
+		if openStackVersionAtLeast(version, file.since) {
+			endpoints = append(endpoints, file.name)
+		}
+	}
+	h.writeTextResponse(w, strings.Join(endpoints, "\n"))
+}
+
+// handleMetaData handles requests to /openstack/{version}/meta_data.json.
+func (h *Handler) handleMetaData(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "meta_data.json")
+	if !ok {
 		return
 	}
 
-	log.Debug().
-		Str("client_ip", clientIP).
-		Str("endpoint", "network_data.json").
-		Msg("Processing network data request")
+	metaData := h.buildMetaData(node)
 
-	node, err := h.getNodeByIP(r.Context(), clientIP)
+	// project_id was added to meta_data.json in Liberty.
+	if !openStackVersionAtLeast(openStackVersion(r), versionLiberty) {
+		metaData.ProjectID = ""
+	}
+
+	document, err := h.metaDataDocument(node, metaData)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
-			Str("client_ip", clientIP).
-			Str("endpoint", "network_data.json").
-			Msg("Failed to find node for client IP")
-		http.Error(w, "Node not found", http.StatusNotFound)
+			Str("node_uuid", node.UUID).
+			Msg("Failed to build meta data")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.writeValidatedJSON(w, node, "meta_data.json", document, metadata.ValidateMetaData)
+}
 
-	log.Info().
-		Str("client_ip", clientIP).
-		Str("node_uuid", node.UUID).
-		Str("node_name", node.Name).
-		Str("endpoint", "network_data.json").
-		Msg("Successfully matched client IP to node")
+// handleNetworkData handles requests to /openstack/{version}/network_data.json.
+func (h *Handler) handleNetworkData(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "network_data.json")
+	if !ok {
+		return
+	}
 
 	networkData := h.buildNetworkData(node)
-	h.writeJSONResponse(w, networkData)
+	h.writeValidatedJSON(w, node, "network_data.json", networkData, metadata.ValidateNetworkData)
 }
 
-// handleUserData handles requests to /openstack/latest/user_data.
+// handleUserData handles requests to /openstack/{version}/user_data.
 func (h *Handler) handleUserData(w http.ResponseWriter, r *http.Request) {
-	clientIP, err := getClientIPFromContext(r)
+	node, clientIP, ok := h.nodeForRequest(w, r, "user_data")
+	if !ok {
+		return
+	}
+
+	body, err := h.openUserData(r.Context(), node)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
-			Str("request_path", r.URL.Path).
-			Str("method", r.Method).
-			Msg("Failed to get client IP from context")
+			Str("client_ip", clientIP).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to render user data")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if body.size == 0 {
+		h.logger().Warn().
+			Str("client_ip", clientIP).
+			Str("node_uuid", node.UUID).
+			Str("node_name", node.Name).
+			Msg("No user data found for node")
+		http.Error(w, "User data not found", http.StatusNotFound)
+		return
+	}
 
-	log.Debug().
-		Str("client_ip", clientIP).
-		Str("endpoint", "user_data").
-		Msg("Processing user data request")
+	h.writeUserData(w, r, node, body)
+}
 
-	node, err := h.getNodeByIP(r.Context(), clientIP)
+// handleVendorData handles requests to /openstack/{version}/vendor_data.json.
+// The node is only looked up when operator vendor data, templates or
+// profiles, or hardware summaries, are configured.
+func (h *Handler) handleVendorData(w http.ResponseWriter, r *http.Request) {
+	if h.VendorDataDir == "" && h.TemplateDir == "" && !h.HardwareVendorData &&
+		!h.HostKeyEscrow && len(h.Profiles) == 0 {
+		h.writeVendorData(w, r, vendorData())
+		return
+	}
+
+	node, _, ok := h.nodeForRequest(w, r, "vendor_data.json")
+	if !ok {
+		return
+	}
+
+	data, err := h.buildVendorData(node)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
-			Str("client_ip", clientIP).
-			Str("endpoint", "user_data").
-			Msg("Failed to find node for client IP")
-		http.Error(w, "Node not found", http.StatusNotFound)
+			Str("node_uuid", node.UUID).
+			Msg("Failed to build vendor data")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.writeVendorData(w, r, data)
+}
 
-	log.Info().
-		Str("client_ip", clientIP).
-		Str("node_uuid", node.UUID).
-		Str("node_name", node.Name).
-		Str("endpoint", "user_data").
-		Msg("Successfully matched client IP to node")
-
-	userDataRes := h.getUserData(node)
-	var b []byte
+// handleVendorData2 handles requests to /openstack/{version}/vendor_data2.json.
+// The node is only looked up when operator or dynamic vendor data, operator
+// templates or profiles, or hardware summaries are configured.
+func (h *Handler) handleVendorData2(w http.ResponseWriter, r *http.Request) {
+	if h.VendorDataDir == "" && h.TemplateDir == "" && h.VendorData == nil &&
+		!h.HardwareVendorData && len(h.Profiles) == 0 {
+		h.writeVendorData(w, r, vendorData2())
+		return
+	}
 
-	if userData, ok := userDataRes.(string); ok {
-		if userData == "" {
-			log.Warn().
-				Str("client_ip", clientIP).
-				Str("node_uuid", node.UUID).
-				Str("node_name", node.Name).
-				Msg("No user data found for node")
-			http.Error(w, "User data not found", http.StatusNotFound)
-			return
-		}
-		b = []byte(userData)
-	} else {
-		b, err = yaml.Marshal(userDataRes)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("client_ip", clientIP).
-				Str("node_uuid", node.UUID).
-				Msg("Failed to marshal user data")
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+	node, _, ok := h.nodeForRequest(w, r, "vendor_data2.json")
+	if !ok {
+		return
 	}
 
-	w.Header().Set("Content-Type", "text/plain")
-	if _, err := w.Write(b); err != nil {
-		log.Error().
+	data, err := h.buildVendorData2(r.Context(), node)
+	if err != nil {
+		h.logger().Error().
 			Err(err).
-			Str("client_ip", clientIP).
 			Str("node_uuid", node.UUID).
-			Msg("Failed to write user data response")
+			Msg("Failed to build vendor data")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	h.writeVendorData(w, r, data)
 }
 
-// handleVendorData handles requests to /openstack/latest/vendor_data.json.
-func (h *Handler) handleVendorData(w http.ResponseWriter, r *http.Request) {
-	vendorData := map[string]any{
+// writeVendorData resolves the secrets referenced by a vendor data document
+// and writes it.
+func (h *Handler) writeVendorData(w http.ResponseWriter, r *http.Request, data map[string]any) {
+	resolved, err := h.resolveSecretValues(r.Context(), data)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("path", r.URL.Path).
+			Msg("Failed to resolve vendor data secrets")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSONResponse(w, resolved)
+}
+
+// vendorData returns the vendor_data.json document.
+func vendorData() map[string]any {
+	return map[string]any{
 		"ironic": map[string]any{
 			"version": "1.0",
 		},
 	}
-	h.writeJSONResponse(w, vendorData)
 }
 
-// handleVendorData2 handles requests to /openstack/latest/vendor_data2.json.
-func (h *Handler) handleVendorData2(w http.ResponseWriter, r *http.Request) {
-	vendorData := map[string]any{
+// vendorData2 returns the vendor_data2.json document.
+func vendorData2() map[string]any {
+	return map[string]any{
 		"static": map[string]any{
 			"ironic-metadata": map[string]any{
 				"version": "1.0",
 			},
 		},
 	}
-	h.writeJSONResponse(w, vendorData)
 }
 
 // handleEC2Root handles EC2-compatible root requests.
@@ -383,40 +966,11 @@ func (h *Handler) handleEC2Latest(w http.ResponseWriter, r *http.Request) {
 
 // handleEC2MetaData handles EC2-compatible meta-data requests.
 func (h *Handler) handleEC2MetaData(w http.ResponseWriter, r *http.Request) {
-	clientIP, err := getClientIPFromContext(r)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("request_path", r.URL.Path).
-			Str("method", r.Method).
-			Msg("Failed to get client IP from context")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	log.Debug().
-		Str("client_ip", clientIP).
-		Str("endpoint", "ec2_meta_data").
-		Msg("Processing EC2-compatible metadata request")
-
-	node, err := h.getNodeByIP(r.Context(), clientIP)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("client_ip", clientIP).
-			Str("endpoint", "ec2_meta_data").
-			Msg("Failed to find node for client IP")
-		http.Error(w, "Node not found", http.StatusNotFound)
+	node, clientIP, ok := h.nodeForRequest(w, r, "ec2_meta_data")
+	if !ok {
 		return
 	}
 
-	log.Info().
-		Str("client_ip", clientIP).
-		Str("node_uuid", node.UUID).
-		Str("node_name", node.Name).
-		Str("endpoint", "ec2_meta_data").
-		Msg("Successfully matched client IP to node")
-
 	// EC2-style metadata
 	ec2Data := []string{
 		fmt.Sprintf("instance-id\n%s", node.UUID),
@@ -427,11 +981,11 @@ func (h *Handler) handleEC2MetaData(w http.ResponseWriter, r *http.Request) {
 	h.writeTextResponse(w, strings.Join(ec2Data, "\n"))
 }
 
-// extractFromConfigDrive attempts to extract data from a node's configdrive.
-func (h *Handler) extractFromConfigDrive(node *nodes.Node) (*configDriveData, error) {
+// parseConfigDrive attempts to extract data from a node's configdrive.
+func (h *Handler) parseConfigDrive(node *nodes.Node) (*configDriveData, error) {
 	configDriveInfo, exists := node.InstanceInfo["configdrive"]
 	if !exists {
-		log.Debug().
+		h.logger().Debug().
 			Str("node_uuid", node.UUID).
 			Str("node_name", node.Name).
 			Msg("No configdrive found in instance_info")
@@ -440,23 +994,28 @@ func (h *Handler) extractFromConfigDrive(node *nodes.Node) (*configDriveData, er
 
 	// Try to parse as configdrive URL or path first
 	if configDriveStr, ok := configDriveInfo.(string); ok {
-		log.Debug().
+		h.logger().Debug().
 			Str("configdrive", configDriveStr).
 			Str("node_uuid", node.UUID).
 			Msg("Found configdrive string")
 
-		// If it's an ISO file path or URL, we would need to download and parse it
-		// For now, we'll assume it's a JSON string or try to parse it as such
+		// Ironic stores configdrives in object storage when
+		// configdrive_use_object_store is set, leaving only a URL behind.
+		if configdrive.IsRemote(configDriveStr) {
+			return h.fetchConfigDrive(node, configDriveStr)
+		}
+
+		// Otherwise, we'll assume it's a JSON string or try to parse it as such
 		if strings.HasPrefix(configDriveStr, "{") {
 			// Try to parse as JSON
 			var configData configDriveData
 			if err := json.Unmarshal([]byte(configDriveStr), &configData); err == nil {
-				log.Debug().
+				h.logger().Debug().
 					Str("node_uuid", node.UUID).
 					Msg("Successfully parsed configdrive as JSON string")
 				return nil, fmt.Errorf("configdrive is a JSON string, not a file path or URL")
 			} else {
-				log.Error().
+				h.logger().Error().
 					Err(err).
 					Str("node_uuid", node.UUID).
 					Str("configdrive_content", configDriveStr).
@@ -464,18 +1023,21 @@ func (h *Handler) extractFromConfigDrive(node *nodes.Node) (*configDriveData, er
 			}
 		}
 
-		// For ISO files, we would use utils.ConfigDrive to parse
-		// This is a placeholder for ISO parsing functionality
-		log.Warn().
-			Str("node_uuid", node.UUID).
-			Str("configdrive", configDriveStr).
-			Msg("ISO configdrive parsing not yet implemented")
-		return nil, fmt.Errorf("ISO configdrive parsing not yet implemented")
+		// Otherwise it is an ISO image, usually base64 encoded gzip
+		cd, err := configdrive.Parse([]byte(configDriveStr))
+		if err != nil {
+			h.logger().Warn().
+				Err(err).
+				Str("node_uuid", node.UUID).
+				Msg("Failed to parse configdrive image")
+			return nil, fmt.Errorf("failed to parse configdrive image: %w", err)
+		}
+		return newConfigDriveData(cd)
 	}
 
 	dataBytes, err := json.Marshal(configDriveInfo)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Str("node_uuid", node.UUID).
 			Msg("Failed to marshal configdrive info")
@@ -484,7 +1046,7 @@ func (h *Handler) extractFromConfigDrive(node *nodes.Node) (*configDriveData, er
 	resData := configDriveData{}
 	err = json.Unmarshal(dataBytes, &resData)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Str("node_uuid", node.UUID).
 			Msg("Failed to unmarshal configdrive data")
@@ -500,6 +1062,7 @@ type configDriveData struct {
 	NetworkData *metadata.NetworkData `json:"network_data,omitempty"`
 	VendorData  map[string]any        `json:"vendor_data,omitempty"`
 	PublicKeys  map[string]string     `json:"public_keys,omitempty"`
+	Content     map[string]string     `json:"content,omitempty"`
 }
 
 // buildMetaData constructs the metadata response for a node.
@@ -512,13 +1075,24 @@ func (h *Handler) buildMetaData(node *nodes.Node) *metadata.MetaData {
 		PublicKeys:   make(map[string]string),
 		Meta:         make(map[string]string),
 		Keys:         []metadata.Key{},
-		ProjectID:    getProjectID(node),
+		ProjectID:    h.projectID(node),
 		CreationTime: &node.CreatedAt,
+		Tags:         h.nodeTags(node),
+	}
+	h.addProfileMeta(node, metaData)
+
+	for _, file := range h.injectedFiles(node) {
+		metaData.Files = append(metaData.Files, metadata.File{
+			Path:        file.path,
+			ContentPath: "/content/" + file.id,
+			Owner:       file.owner,
+			Mode:        file.mode,
+		})
 	}
 
 	// Try to extract from configdrive first
 	if configDriveData, err := h.extractFromConfigDrive(node); err == nil {
-		log.Debug().Str("node_uuid", node.UUID).Msg("Using configdrive metadata")
+		h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using configdrive metadata")
 
 		// Use configdrive metadata if available
 		if configDriveData.MetaData != nil {
@@ -528,40 +1102,45 @@ func (h *Handler) buildMetaData(node *nodes.Node) *metadata.MetaData {
 			}
 		}
 
-		// Use configdrive public keys if available
-		if len(configDriveData.PublicKeys) > 0 {
-			metaData.PublicKeys = configDriveData.PublicKeys
-		}
+		// Keys of the configdrive come first, followed by any added since.
+		h.addKeys(node, metaData, configDriveData.MetaData)
+		h.addAdminCert(node, metaData)
+		h.addKV(node, metaData)
 
 		return metaData
 	}
 
 	// Fallback to dynamic config from instance info
-	log.Debug().Str("node_uuid", node.UUID).Msg("Using dynamic metadata")
-
-	// Extract public keys from instance info
-	if instanceInfo, ok := node.InstanceInfo["public_keys"]; ok {
-		if keys, ok := instanceInfo.(map[string]any); ok {
-			for name, key := range keys {
-				if keyStr, ok := key.(string); ok {
-					metaData.PublicKeys[name] = keyStr
-				}
-			}
+	h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using dynamic metadata")
+
+	h.addKeys(node, metaData, nil)
+	h.addAdminCert(node, metaData)
+	if metal3MetaData, ok := h.metal3MetaData(node); ok {
+		if hostname := metal3Hostname(metal3MetaData); hostname != "" {
+			metaData.Hostname = hostname
 		}
 	}
 
 	// Extract metadata from node properties
 	for key, value := range node.Properties {
-		if strValue, ok := value.(string); ok {
+		if strValue, ok := value.(string); ok && !h.redactedKey(key) {
 			metaData.Meta[key] = strValue
 		}
 	}
+	h.addKV(node, metaData)
 
 	return metaData
 }
 
-// buildNetworkData constructs the network data response for a node.
+// buildNetworkData constructs the network data response for a node, with
+// the network defaults of its profiles applied.
 func (h *Handler) buildNetworkData(node *nodes.Node) *metadata.NetworkData {
+	return h.applyNetworkDefaults(node, h.nodeNetworkData(node))
+}
+
+// nodeNetworkData returns the network data of a node, from its configdrive,
+// its node data or its inspection, or else a basic guess.
+func (h *Handler) nodeNetworkData(node *nodes.Node) *metadata.NetworkData {
 	networkData := &metadata.NetworkData{
 		Links:    []metadata.Link{},
 		Networks: []metadata.Network{},
@@ -571,19 +1150,29 @@ func (h *Handler) buildNetworkData(node *nodes.Node) *metadata.NetworkData {
 	// Try to extract from configdrive first
 	if configDriveData, err := h.extractFromConfigDrive(node); err == nil &&
 		configDriveData.NetworkData != nil {
-		log.Debug().Str("node_uuid", node.UUID).Msg("Using configdrive network data")
+		h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using configdrive network data")
 		return configDriveData.NetworkData
 	}
 
+	// Ironic reports the network data of the node on its own from API
+	// version 1.66, which needs no guessing from instance_info
+	if ironicNetworkData, ok := h.ironicNetworkData(node); ok {
+		h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using node network data")
+		return ironicNetworkData
+	}
+
 	// Fallback to dynamic config from instance info
-	log.Debug().Str("node_uuid", node.UUID).Msg("Using dynamic network data")
+	h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using dynamic network data")
 
-	// Extract network configuration from instance info
-	if instanceInfo, ok := node.InstanceInfo["network_data"]; ok {
-		if netData, ok := instanceInfo.(map[string]any); ok {
-			// Parse the network data - simplified version
-			_ = netData // TODO: Implement proper network data parsing
-		}
+	// Metal3 writes network data to instance_info instead of a configdrive
+	if metal3NetworkData, ok := h.metal3NetworkData(node); ok {
+		h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using instance_info network data")
+		return metal3NetworkData
+	}
+
+	if inspected, ok := h.inspectedNetworkData(node); ok {
+		h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using inspection network data")
+		return inspected
 	}
 
 	// For now, create a basic network configuration as fallback
@@ -604,106 +1193,194 @@ func (h *Handler) buildNetworkData(node *nodes.Node) *metadata.NetworkData {
 
 // getUserData extracts user data from the node.
 func (h *Handler) getUserData(node *nodes.Node) any {
+	raw, structured := h.rawUserData(node)
+	if structured != nil {
+		return structured
+	}
+	return h.decodeUserData(node, raw)
+}
+
+// rawUserData returns the user data of a node as stored, before gzip and
+// base64 encoding is stripped. Configdrives may instead hold structured
+// user data, which is returned as structured. Nodes active for longer than
+// ActiveExpiry have none.
+func (h *Handler) rawUserData(node *nodes.Node) (raw []byte, structured any) {
+	if h.userDataExpired(node) {
+		return nil, nil
+	}
+
 	// Try to extract from configdrive first
 	if configDriveData, err := h.extractFromConfigDrive(node); err == nil &&
-		configDriveData.UserData != "" {
-		log.Debug().Str("node_uuid", node.UUID).Msg("Using configdrive user data")
-		return configDriveData.UserData
+		configDriveData.UserData != nil && configDriveData.UserData != "" {
+		h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using configdrive user data")
+		if userData, ok := configDriveData.UserData.(string); ok {
+			return []byte(userData), nil
+		}
+		return nil, configDriveData.UserData
 	}
 
 	// Fallback to instance info
-	log.Debug().Str("node_uuid", node.UUID).Msg("Using dynamic user data")
+	h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using dynamic user data")
 	if instanceInfo, ok := node.InstanceInfo["user_data"]; ok {
-		if userData, ok := instanceInfo.(string); ok {
-			return userData
+		switch userData := instanceInfo.(type) {
+		case string:
+			if remote.IsRemote(userData) {
+				return h.fetchUserData(node, userData), nil
+			}
+			return []byte(userData), nil
+		case map[string]any:
+			return nil, userData
 		}
 	}
 
-	return ""
+	if userData, ok := h.kubernetesUserData(node); ok {
+		h.logger().Debug().Str("node_uuid", node.UUID).Msg("Using Kubernetes user data")
+		return []byte(userData), nil
+	}
+
+	return nil, nil
 }
 
-// getNodeByIP finds a node by its IP address.
-func (h *Handler) getNodeByIP(ctx context.Context, clientIP string) (*nodes.Node, error) {
-	// Get the Ironic client
-	ironicClient, err := h.Clients.GetIronicClient()
+// userDataLimit returns the bound on the decoded size of user data.
+func (h *Handler) userDataLimit() int64 {
+	if h.MaxUserDataSize > 0 {
+		return h.MaxUserDataSize
+	}
+	return blob.DefaultLimit
+}
+
+// decodeUserData strips gzip and base64 encoding from user data. User data
+// that fails to decode, or expands beyond the size limit, is dropped.
+func (h *Handler) decodeUserData(node *nodes.Node, userData []byte) string {
+	decoded, err := blob.Decode(userData, h.userDataLimit())
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
-			Str("client_ip", clientIP).
-			Msg("Failed to get ironic client")
-		return nil, fmt.Errorf("failed to get ironic client: %w", err)
+			Str("node_uuid", node.UUID).
+			Msg("Failed to decode user data")
+		return ""
 	}
+	return string(decoded)
+}
 
-	// Log the endpoint being used for debugging
-	log.Debug().
-		Str("client_ip", clientIP).
-		Str("ironic_endpoint", ironicClient.Endpoint).
-		Msg("Attempting to list nodes from Ironic")
+// renderUserData returns the serialized user data of a node. Structured
+// user data is rendered as YAML; an empty result means no user data is set.
+func (h *Handler) renderUserData(node *nodes.Node) ([]byte, error) {
+	return h.serializeUserData(context.Background(), node, h.getUserData(node))
+}
 
-	allPages, err := nodes.ListDetail(ironicClient, nodes.ListOpts{}).AllPages(ctx)
+// serializeUserData renders decoded or structured user data, layering
+// fragments and resolving secrets.
+func (h *Handler) serializeUserData(
+	ctx context.Context,
+	node *nodes.Node,
+	userDataRes any,
+) ([]byte, error) {
+	var b []byte
+	if userData, ok := userDataRes.(string); ok {
+		b = []byte(userData)
+	} else {
+		var err error
+		if b, err = yaml.Marshal(userDataRes); err != nil {
+			return nil, err
+		}
+		b = append([]byte("#cloud-config\n"), b...)
+	}
+
+	b, err := h.combineUserData(node, b)
 	if err != nil {
-		log.Error().
-			Err(err).
-			Str("client_ip", clientIP).
-			Str("ironic_endpoint", ironicClient.Endpoint).
-			Msg("Failed to list nodes from Ironic API")
-		return nil, fmt.Errorf("failed to list nodes: %w", err)
+		return nil, err
+	}
+	return h.resolveSecrets(ctx, b)
+}
+
+// getNodeByIP finds a node by its IP address, trying each resolver in turn.
+func (h *Handler) getNodeByIP(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	for _, resolver := range h.resolvers() {
+		start := time.Now()
+		step := traceStep(ctx, resolver.Name())
+		node, err := resolver.Resolve(ctx, clientIP)
+		h.Metrics.observeResolver(resolver.Name(), start, node != nil)
+		step.finish(start, node != nil, err)
+		if err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("client_ip", clientIP).
+				Str("resolver", resolver.Name()).
+				Msg("Failed to resolve node for client IP")
+			return nil, fmt.Errorf("%s resolver: %w", resolver.Name(), err)
+		}
+		if node != nil {
+			h.logger().Debug().
+				Str("client_ip", clientIP).
+				Str("node_uuid", node.UUID).
+				Str("resolver", resolver.Name()).
+				Msg("Resolved node for client IP")
+			return node, nil
+		}
 	}
 
-	allNodes, err := nodes.ExtractNodes(allPages)
+	return nil, fmt.Errorf("no node found for IP %s", clientIP)
+}
+
+// matchNodeByIP finds the node holding an IP address in its configdrive or
+// instance_info. It returns nil when no node does, and leaves an IP several
+// nodes hold to resolveIPConflict.
+func (h *Handler) matchNodeByIP(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	h.logger().Debug().
+		Str("client_ip", clientIP).
+		Msg("Attempting to list nodes from Ironic")
+
+	allNodes, err := h.nodeSource().ListNodes(ctx)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Str("client_ip", clientIP).
-			Msg("Failed to extract nodes from API response")
-		return nil, fmt.Errorf("failed to extract nodes: %w", err)
+			Msg("Failed to list nodes from Ironic API")
+		return nil, err
 	}
 
-	log.Debug().
+	h.logger().Debug().
 		Str("client_ip", clientIP).
 		Int("total_nodes", len(allNodes)).
 		Msg("Successfully retrieved nodes from Ironic")
 
-	// Look for node with matching IP
+	// Look for every node with matching IP, since stale node data can
+	// leave the IP of one node in another
+	var matches []ipMatch
 	for _, node := range allNodes {
 		// Check if the node has this IP in its port information
-		if h.nodeHasIP(&node, clientIP) {
-			log.Info().
-				Str("client_ip", clientIP).
-				Str("node_uuid", node.UUID).
-				Str("node_name", node.Name).
-				Msg("Found matching node for client IP")
-			return &node, nil
+		if where, ok := h.nodeHasIP(&node, clientIP); ok {
+			traceNote(ctx, "node %s has the IP in its %s", node.UUID, where)
+			matches = append(matches, ipMatch{node: node, where: where})
 		}
 	}
+	if len(matches) > 1 {
+		return h.resolveIPConflict(ctx, clientIP, matches)
+	}
+	if len(matches) == 1 {
+		h.logger().Info().
+			Str("client_ip", clientIP).
+			Str("node_uuid", matches[0].node.UUID).
+			Str("node_name", matches[0].node.Name).
+			Msg("Found matching node for client IP")
+		return &matches[0].node, nil
+	}
 
+	traceNote(ctx, "none of %d nodes has the IP", len(allNodes))
 	// Fallback to MAC-to-node lookup using DHCP leases
-	log.Warn().
+	h.logger().Warn().
 		Str("client_ip", clientIP).
 		Int("nodes_checked", len(allNodes)).
 		Msg("No node found matching client IP, attempting MAC-to-node lookup")
 
-	node, err := h.lookupNodeByMAC(ctx, clientIP)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("client_ip", clientIP).
-			Msg("Failed to perform MAC-to-node lookup")
-		return nil, fmt.Errorf("no node found for IP %s", clientIP)
-	}
-
-	log.Info().
-		Str("client_ip", clientIP).
-		Str("node_uuid", node.UUID).
-		Str("node_name", node.Name).
-		Msg("Successfully found node via MAC-to-node lookup")
-
-	return node, nil
+	return nil, nil
 }
 
-// nodeHasIP checks if a node has the specified IP address.
-func (h *Handler) nodeHasIP(node *nodes.Node, targetIP string) bool {
-	log.Debug().
+// nodeHasIP checks if a node has the specified IP address, returning where
+// the address was found.
+func (h *Handler) nodeHasIP(node *nodes.Node, targetIP string) (string, bool) {
+	h.logger().Debug().
 		Str("node_uuid", node.UUID).
 		Str("node_name", node.Name).
 		Str("target_ip", targetIP).
@@ -714,26 +1391,40 @@ func (h *Handler) nodeHasIP(node *nodes.Node, targetIP string) bool {
 			// Check if the target IP is in the network data
 			for _, net := range configDrive.NetworkData.Networks {
 				if net.Address == targetIP {
-					log.Debug().
+					h.logger().Debug().
 						Str("node_uuid", node.UUID).
 						Str("target_ip", targetIP).
 						Str("network_id", net.ID).
 						Msg("Found target IP in configdrive network data")
-					return true
+					return "configdrive network data", true
 				}
 			}
 		}
 	} else {
-		log.Debug().
+		h.logger().Debug().
 			Err(err).
 			Str("node_uuid", node.UUID).
 			Msg("Could not extract configdrive for IP matching")
 	}
 
+	// Check the network data Ironic reports for the node
+	if networkData, ok := h.ironicNetworkData(node); ok {
+		for _, net := range networkData.Networks {
+			if net.Address == targetIP {
+				h.logger().Debug().
+					Str("node_uuid", node.UUID).
+					Str("target_ip", targetIP).
+					Str("network_id", net.ID).
+					Msg("Found target IP in node network data")
+				return "node network data", true
+			}
+		}
+	}
+
 	// Check instance_info for IP addresses
 	if instanceInfo, exists := node.InstanceInfo["fixed_ips"]; exists {
 		if fixedIPs, ok := instanceInfo.([]any); ok {
-			log.Debug().
+			h.logger().Debug().
 				Str("node_uuid", node.UUID).
 				Int("fixed_ips_count", len(fixedIPs)).
 				Msg("Checking fixed_ips in instance_info")
@@ -742,12 +1433,12 @@ func (h *Handler) nodeHasIP(node *nodes.Node, targetIP string) bool {
 				if ipMap, ok := ip.(map[string]any); ok {
 					if ipAddr, exists := ipMap["ip_address"]; exists {
 						if ipStr, ok := ipAddr.(string); ok && ipStr == targetIP {
-							log.Debug().
+							h.logger().Debug().
 								Str("node_uuid", node.UUID).
 								Str("target_ip", targetIP).
 								Int("fixed_ip_index", i).
 								Msg("Found target IP in fixed_ips")
-							return true
+							return "instance_info fixed_ips", true
 						}
 					}
 				}
@@ -759,134 +1450,89 @@ func (h *Handler) nodeHasIP(node *nodes.Node, targetIP string) bool {
 	if driverInfo, exists := node.DriverInfo["deploy_ramdisk_options"]; exists {
 		if options, ok := driverInfo.(map[string]any); ok {
 			if ip, exists := options["ipa-api-url"]; exists {
-				if ipStr, ok := ip.(string); ok && strings.Contains(ipStr, targetIP) {
-					log.Debug().
+				if ipStr, ok := ip.(string); ok && urlHasIP(ipStr, targetIP) {
+					h.logger().Debug().
 						Str("node_uuid", node.UUID).
 						Str("target_ip", targetIP).
 						Str("ipa_api_url", ipStr).
 						Msg("Found target IP in IPA API URL")
-					return true
+					return "driver_info IPA API URL", true
 				}
 			}
 		}
 	}
 
 	// For testing purposes, if node name contains the IP
-	if strings.Contains(node.Name, targetIP) {
-		log.Debug().
+	if nameHasIP(node.Name, targetIP) {
+		h.logger().Debug().
 			Str("node_uuid", node.UUID).
 			Str("node_name", node.Name).
 			Str("target_ip", targetIP).
 			Msg("Found target IP in node name (testing mode)")
-		return true
+		return "node name", true
 	}
 
-	log.Debug().
+	h.logger().Debug().
 		Str("node_uuid", node.UUID).
 		Str("target_ip", targetIP).
 		Msg("Target IP not found in node")
-	return false
+	return "", false
 }
 
 // lookupNodeByMAC performs MAC-to-node lookup by first finding the MAC address
-// from DHCP lease file, then finding the node by that MAC address.
-func (h *Handler) lookupNodeByMAC(ctx context.Context, clientIP string) (*nodes.Node, error) {
+// from DHCP lease file, then finding the node by that MAC address. It returns
+// nil when the IP has no lease or the MAC address no port.
+func (h *Handler) lookupNodeByMAC(
+	ctx context.Context,
+	dhcpLeases *leases.File,
+	clientIP string,
+) (*nodes.Node, error) {
 	// Try to get MAC address from DHCP lease file
-	dhcpLeaseFile := "/shared/dnsmasq/dnsmasq.leases"
-	macAddress, err := parseDHCPLeaseFile(dhcpLeaseFile, clientIP)
-	if err != nil {
-		log.Debug().
+	lease, ok, err := dhcpLeases.Lookup(clientIP)
+	if err != nil || !ok {
+		h.logger().Debug().
 			Err(err).
 			Str("client_ip", clientIP).
-			Str("dhcp_lease_file", dhcpLeaseFile).
+			Str("dhcp_lease_file", dhcpLeases.Path()).
 			Msg("Failed to find MAC address from DHCP lease file")
-		return nil, fmt.Errorf("failed to find MAC address for IP %s: %w", clientIP, err)
-	}
-
-	// Now find the node by MAC address
-	return h.getNodeByMACAddress(ctx, macAddress)
-}
-
-// parseDHCPLeaseFile parses the DHCP lease file to extract MAC address for the given IP.
-func parseDHCPLeaseFile(filePath, targetIP string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open DHCP lease file %s: %w", filePath, err)
-	}
-	defer func() {
-		_ = file.Close()
-	}()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-
-		// Parse dnsmasq lease format: timestamp mac ip hostname client_id
-		// Example: 1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
-		fields := strings.Fields(line)
-		if len(fields) >= 3 {
-			mac := fields[1]
-			ip := fields[2]
-
-			if ip == targetIP {
-				log.Debug().
-					Str("target_ip", targetIP).
-					Str("mac_address", mac).
-					Str("lease_file", filePath).
-					Msg("Found MAC address for IP in DHCP lease file")
-				return mac, nil
-			}
+		if err != nil {
+			traceNote(ctx, "failed to read lease file %s: %v", dhcpLeases.Path(), err)
+		} else {
+			traceNote(ctx, "no lease for the IP in %s", dhcpLeases.Path())
 		}
+		return nil, nil
 	}
 
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading DHCP lease file: %w", err)
-	}
+	h.logger().Debug().
+		Str("client_ip", clientIP).
+		Str("mac_address", lease.MAC).
+		Str("dhcp_lease_file", dhcpLeases.Path()).
+		Msg("Found MAC address for IP in DHCP lease file")
+	macAddress := lease.MAC
+	traceMAC(ctx, macAddress)
+	traceNote(ctx, "lease in %s", dhcpLeases.Path())
 
-	return "", fmt.Errorf("IP address %s not found in DHCP lease file", targetIP)
+	// Now find the node by MAC address
+	return h.getNodeByMACAddress(ctx, macAddress)
 }
 
-// getNodeByMACAddress finds a node by its MAC address using the Ironic ports API.
+// getNodeByMACAddress finds a node by its MAC address using the Ironic ports
+// API. It returns nil when no port has the address.
 func (h *Handler) getNodeByMACAddress(ctx context.Context, macAddress string) (*nodes.Node, error) {
-	// Get the Ironic client
-	ironicClient, err := h.Clients.GetIronicClient()
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("mac_address", macAddress).
-			Msg("Failed to get ironic client")
-		return nil, fmt.Errorf("failed to get ironic client: %w", err)
-	}
-
-	log.Debug().
+	h.logger().Debug().
 		Str("mac_address", macAddress).
-		Str("ironic_endpoint", ironicClient.Endpoint).
 		Msg("Attempting to find port by MAC address")
 
-	// List all ports and find the one with matching MAC address
-	allPages, err := ports.List(ironicClient, ports.ListOpts{}).AllPages(ctx)
+	allPorts, err := h.nodeSource().ListPortsByMAC(ctx, macAddress)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Str("mac_address", macAddress).
-			Str("ironic_endpoint", ironicClient.Endpoint).
 			Msg("Failed to list ports from Ironic API")
-		return nil, fmt.Errorf("failed to list ports: %w", err)
+		return nil, err
 	}
 
-	allPorts, err := ports.ExtractPorts(allPages)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("mac_address", macAddress).
-			Msg("Failed to extract ports from API response")
-		return nil, fmt.Errorf("failed to extract ports: %w", err)
-	}
-
-	log.Debug().
+	h.logger().Debug().
 		Str("mac_address", macAddress).
 		Int("total_ports", len(allPorts)).
 		Msg("Successfully retrieved ports from Ironic")
@@ -896,35 +1542,38 @@ func (h *Handler) getNodeByMACAddress(ctx context.Context, macAddress string) (*
 	for _, port := range allPorts {
 		if strings.EqualFold(port.Address, macAddress) {
 			nodeID = port.NodeUUID
-			log.Debug().
+			h.logger().Debug().
 				Str("mac_address", macAddress).
 				Str("node_uuid", nodeID).
 				Str("port_uuid", port.UUID).
 				Msg("Found port with matching MAC address")
+			traceNote(ctx, "port %s has MAC address %s and belongs to node %s",
+				port.UUID, macAddress, nodeID)
 			break
 		}
 	}
 
 	if nodeID == "" {
-		log.Warn().
+		h.logger().Warn().
 			Str("mac_address", macAddress).
 			Int("ports_checked", len(allPorts)).
 			Msg("No port found with matching MAC address")
-		return nil, fmt.Errorf("no port found for MAC address %s", macAddress)
+		traceNote(ctx, "no port has MAC address %s", macAddress)
+		return nil, nil
 	}
 
 	// Get the node details
-	node, err := nodes.Get(ctx, ironicClient, nodeID).Extract()
+	node, err := h.nodeSource().GetNode(ctx, nodeID)
 	if err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Str("mac_address", macAddress).
 			Str("node_uuid", nodeID).
 			Msg("Failed to get node details")
-		return nil, fmt.Errorf("failed to get node details: %w", err)
+		return nil, err
 	}
 
-	log.Info().
+	h.logger().Info().
 		Str("mac_address", macAddress).
 		Str("node_uuid", node.UUID).
 		Str("node_name", node.Name).
@@ -941,15 +1590,33 @@ func getNodeHostname(node *nodes.Node) string {
 	return node.UUID
 }
 
-func getProjectID(node *nodes.Node) string {
-	return node.Owner
+// sortedKeyNames returns the names of a public_keys map in sorted order, so
+// rendered documents are stable across requests.
+func sortedKeyNames(publicKeys map[string]string) []string {
+	names := make([]string, 0, len(publicKeys))
+	for name := range publicKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedPublicKeys returns the key material of a public_keys map ordered by
+// key name.
+func sortedPublicKeys(publicKeys map[string]string) []string {
+	names := sortedKeyNames(publicKeys)
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, publicKeys[name])
+	}
+	return keys
 }
 
 // writeJSONResponse writes a JSON response.
 func (h *Handler) writeJSONResponse(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Interface("data_type", fmt.Sprintf("%T", data)).
 			Msg("Failed to encode JSON response")
@@ -957,11 +1624,22 @@ func (h *Handler) writeJSONResponse(w http.ResponseWriter, data any) {
 	}
 }
 
+// writeYAMLResponse writes a pre-rendered YAML response.
+func (h *Handler) writeYAMLResponse(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(data); err != nil {
+		h.logger().Error().
+			Err(err).
+			Int("data_length", len(data)).
+			Msg("Failed to write YAML response")
+	}
+}
+
 // writeTextResponse writes a plain text response.
 func (h *Handler) writeTextResponse(w http.ResponseWriter, data string) {
 	w.Header().Set("Content-Type", "text/plain")
 	if _, err := w.Write([]byte(data)); err != nil {
-		log.Error().
+		h.logger().Error().
 			Err(err).
 			Int("data_length", len(data)).
 			Msg("Failed to write text response")
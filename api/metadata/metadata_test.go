@@ -1,14 +1,19 @@
 package metadata
 
 import (
-	"encoding/json"
+	"bytes"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/logging"
 	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/rs/zerolog"
 )
 
 // createTestHandler creates a handler for testing.
@@ -18,6 +23,43 @@ func createTestHandler() *Handler {
 	}
 }
 
+func TestHandler_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(logging.NewSlogWriter(slog.NewTextHandler(&buf, nil)))
+	handler := createTestHandler()
+	handler.Logger = &logger
+
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/openstack", nil))
+
+	if have := buf.String(); !strings.Contains(have, `msg="HTTP request"`) ||
+		!strings.Contains(have, "path=/openstack") {
+		t.Errorf("expected request to be logged to the injected logger, got %q", have)
+	}
+}
+
+func TestHandler_AccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog, err := logging.NewAccessLog(&buf, logging.AccessFormatCombined)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := createTestHandler()
+	handler.AccessLog = accessLog
+
+	req := httptest.NewRequest("GET", "/openstack?x=1", nil)
+	req.RemoteAddr = "10.1.105.195:40000"
+	req.Header.Set("User-Agent", "Cloud-Init/23.4")
+	handler.Routes().ServeHTTP(httptest.NewRecorder(), req)
+
+	have := buf.String()
+	if !strings.HasPrefix(have, "10.1.105.195 - - [") ||
+		!strings.Contains(have, `] "GET /openstack?x=1 HTTP/1.1" 200 `) ||
+		!strings.HasSuffix(have, ` "-" "Cloud-Init/23.4"`+"\n") {
+		t.Errorf("unexpected access log line %q", have)
+	}
+}
+
 func TestHandler_Routes(t *testing.T) {
 	handler := createTestHandler()
 	router := handler.Routes()
@@ -43,18 +85,17 @@ func TestHandler_handleOpenStackRoot(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	var versions []string
-	err = json.Unmarshal(rr.Body.Bytes(), &versions)
-	if err != nil {
-		t.Errorf("failed to unmarshal response: %v", err)
+	versions := strings.Split(rr.Body.String(), "\n")
+	if len(versions) != len(openStackVersions) {
+		t.Errorf("expected %d versions, got %d", len(openStackVersions), len(versions))
 	}
 
-	if len(versions) == 0 {
-		t.Error("expected at least one version in response")
+	if versions[0] != versionFolsom {
+		t.Errorf("expected first version to be %s, got %s", versionFolsom, versions[0])
 	}
 
-	if versions[0] != "latest" {
-		t.Errorf("expected first version to be 'latest', got %s", versions[0])
+	if last := versions[len(versions)-1]; last != "latest" {
+		t.Errorf("expected last version to be 'latest', got %s", last)
 	}
 }
 
@@ -74,16 +115,13 @@ func TestHandler_handleLatestRoot(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	var endpoints []string
-	err = json.Unmarshal(rr.Body.Bytes(), &endpoints)
-	if err != nil {
-		t.Errorf("failed to unmarshal response: %v", err)
-	}
+	endpoints := strings.Split(rr.Body.String(), "\n")
 
 	expectedEndpoints := []string{
 		"meta_data.json",
 		"network_data.json",
 		"user_data",
+		"password",
 		"vendor_data.json",
 		"vendor_data2.json",
 	}
@@ -93,6 +131,70 @@ func TestHandler_handleLatestRoot(t *testing.T) {
 	}
 }
 
+func TestHandler_openStackVersions(t *testing.T) {
+	router := createTestHandler().Routes()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "folsom listing",
+			path:       "/openstack/2012-08-10/",
+			wantStatus: http.StatusOK,
+			wantBody:   "meta_data.json\nuser_data",
+		},
+		{
+			name:       "liberty listing",
+			path:       "/openstack/2015-10-15",
+			wantStatus: http.StatusOK,
+			wantBody: "meta_data.json\nnetwork_data.json\nuser_data\npassword\n" +
+				"vendor_data.json",
+		},
+		{
+			name:       "network data before liberty",
+			path:       "/openstack/2013-10-17/network_data.json",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "password before grizzly",
+			path:       "/openstack/2012-08-10/password",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "vendor data 2 before newton",
+			path:       "/openstack/2016-06-30/vendor_data2.json",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "vendor data in havana",
+			path:       "/openstack/2013-10-17/vendor_data.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown version",
+			path:       "/openstack/2011-01-01/meta_data.json",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
+
 func TestBuildMetaData(t *testing.T) {
 	handler := createTestHandler()
 
@@ -104,7 +206,7 @@ func TestBuildMetaData(t *testing.T) {
 		Owner:     "test-project",
 		InstanceInfo: map[string]any{
 			"public_keys": map[string]any{
-				"default": "ssh-rsa AAAAB3NzaC1yc2EAAAADA...",
+				"default": "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQ== test@example",
 			},
 		},
 		Properties: map[string]any{
@@ -195,6 +297,10 @@ func TestGetNodeHostname(t *testing.T) {
 }
 
 func TestGetClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("127.0.0.1, 10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	tests := []struct {
 		name       string
 		headers    map[string]string
@@ -209,6 +315,14 @@ func TestGetClientIP(t *testing.T) {
 			remoteAddr: "127.0.0.1:12345",
 			expected:   "192.168.1.1",
 		},
+		{
+			name: "forged X-Forwarded-For entry",
+			headers: map[string]string{
+				"X-Forwarded-For": "192.168.1.9, 192.168.1.1",
+			},
+			remoteAddr: "127.0.0.1:12345",
+			expected:   "192.168.1.1",
+		},
 		{
 			name: "X-Real-IP header",
 			headers: map[string]string{
@@ -217,6 +331,22 @@ func TestGetClientIP(t *testing.T) {
 			remoteAddr: "127.0.0.1:12345",
 			expected:   "192.168.1.2",
 		},
+		{
+			name: "X-Forwarded-For header of untrusted client",
+			headers: map[string]string{
+				"X-Forwarded-For": "192.168.1.9",
+			},
+			remoteAddr: "192.168.1.3:12345",
+			expected:   "192.168.1.3",
+		},
+		{
+			name: "X-Real-IP header of untrusted client",
+			headers: map[string]string{
+				"X-Real-IP": "192.168.1.9",
+			},
+			remoteAddr: "192.168.1.3:12345",
+			expected:   "192.168.1.3",
+		},
 		{
 			name:       "Remote address only",
 			headers:    map[string]string{},
@@ -234,10 +364,44 @@ func TestGetClientIP(t *testing.T) {
 				req.Header.Set(key, value)
 			}
 
-			result := getClientIP(req)
+			result := (&Handler{TrustedProxies: proxies}).getClientIP(req)
 			if result != tt.expected {
 				t.Errorf("expected %s, got %s", tt.expected, result)
 			}
 		})
 	}
 }
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{spec: ""},
+		{spec: "10.0.0.1/8, 192.168.1.10", want: []string{"10.0.0.0/8", "192.168.1.10/32"}},
+		{spec: "fd00::1", want: []string{"fd00::1/128"}},
+		{spec: "proxy.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			proxies, err := ParseTrustedProxies(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var have []string
+			for _, prefix := range proxies {
+				have = append(have, prefix.String())
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
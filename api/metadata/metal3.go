@@ -0,0 +1,141 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Fields of instance_info Metal3 writes the documents of a configdrive to,
+// next to user_data, instead of building a configdrive image.
+const (
+	metal3MetaDataField    = "meta_data"
+	metal3NetworkDataField = "network_data"
+)
+
+// metal3HostnameKeys are the keys of Metal3 meta_data holding the hostname,
+// in order of preference.
+var metal3HostnameKeys = []string{"hostname", "local-hostname", "local_hostname"}
+
+// metal3Document decodes an instance_info document, which Metal3 writes as
+// a JSON object or a string holding one.
+func metal3Document(value any) (map[string]any, error) {
+	if encoded, ok := value.(string); ok {
+		var document map[string]any
+		if err := json.Unmarshal([]byte(encoded), &document); err != nil {
+			return nil, err
+		}
+		value = document
+	}
+	document, ok := value.(map[string]any)
+	if !ok || document == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	return document, nil
+}
+
+// metal3MetaData returns the meta_data a node's instance_info holds, or
+// false when it holds none or it is not a JSON object.
+func (h *Handler) metal3MetaData(node *nodes.Node) (map[string]any, bool) {
+	value, ok := node.InstanceInfo[metal3MetaDataField]
+	if !ok {
+		return nil, false
+	}
+	document, err := metal3Document(value)
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Ignoring invalid meta_data in instance_info")
+		return nil, false
+	}
+	return document, true
+}
+
+// metal3NetworkData returns the network_data a node's instance_info holds,
+// or false when it holds none or it cannot be decoded. Schema violations are
+// logged, but the document is used as written.
+func (h *Handler) metal3NetworkData(node *nodes.Node) (*metadata.NetworkData, bool) {
+	value, ok := node.InstanceInfo[metal3NetworkDataField]
+	if !ok {
+		return nil, false
+	}
+
+	networkData := &metadata.NetworkData{}
+	document, err := metal3Document(value)
+	var body []byte
+	if err == nil {
+		body, err = json.Marshal(document)
+	}
+	if err == nil {
+		err = json.Unmarshal(body, networkData)
+	}
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Ignoring invalid network_data in instance_info")
+		return nil, false
+	}
+
+	for _, violation := range metadata.ValidateNetworkData(body) {
+		h.logger().Warn().
+			Str("node_uuid", node.UUID).
+			Str("path", violation.Path).
+			Str("violation", violation.Message).
+			Msg("network_data in instance_info violates its schema")
+	}
+	if networkData.Services == nil {
+		networkData.Services = []metadata.Service{}
+	}
+	return networkData, true
+}
+
+// metal3Hostname returns the hostname Metal3 meta_data sets, if any.
+func metal3Hostname(document map[string]any) string {
+	for _, key := range metal3HostnameKeys {
+		if hostname, _ := document[key].(string); hostname != "" {
+			return hostname
+		}
+	}
+	return ""
+}
+
+// metaDataDocument returns the meta_data.json of a node. The keys of any
+// meta_data in its instance_info are served verbatim, overriding those the
+// service built, so templates reading Metal3 keys such as metal3-name keep
+// working. Its SSH keys are served merged with the others instead, and its
+// keys matching RedactKeys are left out. The keys rendered by the operator's
+// templates are merged last.
+func (h *Handler) metaDataDocument(
+	node *nodes.Node,
+	metaData *metadata.MetaData,
+) (any, error) {
+	document, ok := h.metal3MetaData(node)
+	if !ok && h.TemplateDir == "" {
+		return metaData, nil
+	}
+
+	body, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal meta_data.json: %w", err)
+	}
+	merged := make(map[string]any)
+	if err := json.Unmarshal(body, &merged); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal meta_data.json: %w", err)
+	}
+	for key, value := range document {
+		// Keys are merged with those of the other sources by addKeys.
+		if key == publicKeysField || key == "keys" || h.redactedKey(key) {
+			continue
+		}
+		merged[key] = value
+	}
+	if err := h.renderTemplates(node, "meta_data.json", merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_metal3InstanceInfo(t *testing.T) {
+	node := &nodes.Node{
+		UUID: "node-1",
+		Name: "worker-0",
+		InstanceInfo: map[string]any{
+			"meta_data": map[string]any{
+				"uuid":             "bmh-uid",
+				"metal3-name":      "worker-0",
+				"metal3-namespace": "metal3",
+				"local-hostname":   "worker-0.cluster.local",
+				"public_keys":      map[string]any{"ops": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 ops"},
+			},
+			"network_data": `{"links": [{"id": "enp1s0", "type": "phy",
+				"ethernet_mac_address": "52:54:00:00:00:01"}],
+				"networks": [{"id": "net0", "type": "ipv4_dhcp", "link": "enp1s0",
+				"network_id": "net0"}]}`,
+			"user_data": map[string]any{"runcmd": []any{"true"}},
+		},
+	}
+	h := createTestHandler()
+
+	metaData := h.buildMetaData(node)
+	if metaData.Hostname != "worker-0.cluster.local" {
+		t.Errorf("have hostname %q, want the Metal3 local-hostname", metaData.Hostname)
+	}
+	if metaData.PublicKeys["ops"] == "" {
+		t.Errorf("have public keys %v, want the Metal3 key", metaData.PublicKeys)
+	}
+
+	served, err := h.metaDataDocument(node, metaData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := json.Marshal(served)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var document map[string]any
+	if err := json.Unmarshal(body, &document); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for key, want := range map[string]string{
+		"uuid":             "bmh-uid",
+		"metal3-name":      "worker-0",
+		"metal3-namespace": "metal3",
+		"hostname":         "worker-0.cluster.local",
+		"name":             "worker-0",
+	} {
+		if have := document[key]; have != want {
+			t.Errorf("have %s %v, want %q", key, have, want)
+		}
+	}
+
+	networkData := h.buildNetworkData(node)
+	if len(networkData.Links) != 1 || networkData.Links[0].ID != "enp1s0" {
+		t.Errorf("have links %+v, want enp1s0", networkData.Links)
+	}
+	if len(networkData.Networks) != 1 || networkData.Networks[0].Type != "ipv4_dhcp" {
+		t.Errorf("have networks %+v, want ipv4_dhcp", networkData.Networks)
+	}
+
+	userData, err := h.renderUserData(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "#cloud-config\nruncmd:\n- \"true\"\n"; string(userData) != want {
+		t.Errorf("have user data %q, want %q", userData, want)
+	}
+}
+
+func TestHandler_metal3InvalidDocuments(t *testing.T) {
+	node := &nodes.Node{
+		UUID: "node-1",
+		InstanceInfo: map[string]any{
+			"meta_data":    "not json",
+			"network_data": []any{"eth0"},
+		},
+	}
+	h := createTestHandler()
+
+	document, err := h.metaDataDocument(node, h.buildMetaData(node))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := document.(map[string]any); ok {
+		t.Error("expected invalid meta_data to be ignored")
+	}
+	if networkData := h.buildNetworkData(node); networkData.Links[0].ID != "eth0" ||
+		networkData.Links[0].Type != "physical" {
+		t.Errorf("have links %+v, want the fallback eth0", networkData.Links)
+	}
+}
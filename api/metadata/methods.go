@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// routeMethods are the methods routes are registered with, in the order
+// the Allow header lists them.
+var routeMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+type headRequestKey struct{}
+
+// methodsHandler serves the routes of router to HEAD and OPTIONS requests
+// too, which provisioning agents probe with before a GET. HEAD requests are
+// served like GET, with the same headers and no body. OPTIONS requests are
+// answered 204 No Content with the methods of the route in Allow. Paths
+// matching no route are retried with their trailing slash toggled and in
+// lower case, for clients that request /OpenStack/latest/ instead of
+// /openstack/latest.
+func (h *Handler) methodsHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = normalizePath(router, r)
+
+		switch r.Method {
+		case http.MethodOptions:
+			allowed := allowedMethods(router, r)
+			if len(allowed) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodHead:
+			// Routes are matched as GET and served as HEAD again by
+			// headMiddleware once matched.
+			get := r.Clone(context.WithValue(r.Context(), headRequestKey{}, true))
+			get.Method = http.MethodGet
+			hw := &headWriter{ResponseWriter: w, status: http.StatusOK}
+			router.ServeHTTP(hw, get)
+			hw.finish()
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+// headMiddleware restores the HEAD method of requests methodsHandler
+// matched as GET, so the middleware and handlers that follow see it.
+func (h *Handler) headMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if head, _ := r.Context().Value(headRequestKey{}).(bool); head {
+			r = r.WithContext(r.Context())
+			r.Method = http.MethodHead
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headWriter discards the body of the response to a HEAD request, holding
+// back its status until the length of the body is known.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+func (hw *headWriter) WriteHeader(status int) {
+	hw.status = status
+}
+
+func (hw *headWriter) Write(p []byte) (int, error) {
+	hw.length += len(p)
+	return len(p), nil
+}
+
+// finish writes the header of the response, with the Content-Length of the
+// body a GET would have had when the handler did not set it.
+func (hw *headWriter) finish() {
+	if hw.Header().Get("Content-Length") == "" && hw.status != http.StatusNotModified &&
+		hw.status != http.StatusNoContent {
+		hw.Header().Set("Content-Length", strconv.Itoa(hw.length))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
+
+// allowedMethods returns the methods a route serves the path of r with,
+// or none when no route matches it.
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		req := r.Clone(r.Context())
+		req.Method = method
+		var match mux.RouteMatch
+		if !router.Match(req, &match) {
+			continue
+		}
+		allowed = append(allowed, method)
+		if method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	if len(allowed) > 0 {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	return allowed
+}
+
+// normalizePath returns r, or a copy of r for the first of its path with
+// its trailing slash toggled, in lower case, or both, matching a route
+// when its path matches none.
+func normalizePath(router *mux.Router, r *http.Request) *http.Request {
+	if pathMatches(router, r) {
+		return r
+	}
+	lower := strings.ToLower(r.URL.Path)
+	for _, p := range []string{toggleSlash(r.URL.Path), lower, toggleSlash(lower)} {
+		if p == "" || p == r.URL.Path {
+			continue
+		}
+		req := new(http.Request)
+		*req = *r
+		req.URL = new(url.URL)
+		*req.URL = *r.URL
+		req.URL.Path = p
+		req.URL.RawPath = ""
+		if pathMatches(router, req) {
+			return req
+		}
+	}
+	return r
+}
+
+// pathMatches reports whether a route of router matches the path of r,
+// whatever its method.
+func pathMatches(router *mux.Router, r *http.Request) bool {
+	req := r
+	if r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		req = r.Clone(r.Context())
+		req.Method = http.MethodGet
+	}
+	var match mux.RouteMatch
+	return router.Match(req, &match) || errors.Is(match.MatchErr, mux.ErrMethodMismatch)
+}
+
+// toggleSlash returns a path with its trailing slash removed, or added when
+// it has none.
+func toggleSlash(p string) string {
+	if p == "/" {
+		return ""
+	}
+	if trimmed, ok := strings.CutSuffix(p, "/"); ok {
+		return trimmed
+	}
+	return p + "/"
+}
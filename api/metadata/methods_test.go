@@ -0,0 +1,132 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_methods(t *testing.T) {
+	node := nodes.Node{
+		UUID:           "node-1",
+		Name:           "web01",
+		ProvisionState: "active",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		basePath   string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+		wantBody   bool
+	}{
+		{
+			name:       "GET",
+			method:     http.MethodGet,
+			path:       "/openstack/latest/meta_data.json",
+			wantStatus: http.StatusOK,
+			wantBody:   true,
+		},
+		{
+			name:       "HEAD",
+			method:     http.MethodHead,
+			path:       "/openstack/latest/meta_data.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "HEAD under base path",
+			basePath:   "/metadata",
+			method:     http.MethodHead,
+			path:       "/metadata/openstack/latest/meta_data.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "HEAD not found",
+			method:     http.MethodHead,
+			path:       "/openstack/latest/nonexistent",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "OPTIONS",
+			method:     http.MethodOptions,
+			path:       "/openstack/latest/meta_data.json",
+			wantStatus: http.StatusNoContent,
+			wantAllow:  "GET, HEAD, OPTIONS",
+		},
+		{
+			name:       "OPTIONS not found",
+			method:     http.MethodOptions,
+			path:       "/openstack/latest/nonexistent",
+			wantStatus: http.StatusNotFound,
+			wantBody:   true,
+		},
+		{
+			name:       "trailing slash",
+			method:     http.MethodGet,
+			path:       "/openstack/latest/meta_data.json/",
+			wantStatus: http.StatusOK,
+			wantBody:   true,
+		},
+		{
+			name:       "case",
+			method:     http.MethodGet,
+			path:       "/OpenStack/Latest/meta_data.json",
+			wantStatus: http.StatusOK,
+			wantBody:   true,
+		},
+		{
+			name:       "case and trailing slash",
+			method:     http.MethodHead,
+			path:       "/OpenStack/Latest/meta_data.json/",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(
+				WithNodeSource(mock.NewNodeSource(node)),
+				WithBasePath(tt.basePath),
+			)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if have := rr.Header().Get("Allow"); have != tt.wantAllow {
+				t.Errorf("have Allow %q, want %q", have, tt.wantAllow)
+			}
+			if have := rr.Body.Len() > 0; have != tt.wantBody {
+				t.Errorf("have body %q, want body %v", rr.Body, tt.wantBody)
+			}
+			if tt.method != http.MethodHead || tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			get := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json", nil)
+			get.RemoteAddr = "10.0.0.5:4321"
+			want := httptest.NewRecorder()
+			h.Routes().ServeHTTP(want, get)
+			if have := rr.Header().Get("Content-Length"); have != strconv.Itoa(want.Body.Len()) {
+				t.Errorf("have Content-Length %q, want %d", have, want.Body.Len())
+			}
+			for _, header := range []string{"Content-Type", "ETag"} {
+				if have := rr.Header().Get(header); have != want.Header().Get(header) {
+					t.Errorf("have %s %q, want %q", header, have, want.Header().Get(header))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,455 @@
+package metadata
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/audit"
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+)
+
+// Resolvers finding the node of a client IP, in the order they are tried.
+const (
+	resolverHost       = "host"
+	resolverRelayAgent = "relay_agent"
+	resolverPlugin     = "plugin"
+	resolverIP         = "ip"
+	resolverPTR        = "ptr"
+	resolverDHCPACK    = "dhcp_ack"
+	resolverDHCPLease  = "dhcp_lease"
+)
+
+// Metrics instruments node resolution, caches and Ironic API calls.
+type Metrics struct {
+	registry *metrics.Registry
+
+	resolverAttempts  *metrics.CounterVec
+	resolverSuccesses *metrics.CounterVec
+	resolverDuration  *metrics.HistogramVec
+
+	ironicRequests *metrics.CounterVec
+	ironicErrors   *metrics.CounterVec
+	ironicDuration *metrics.HistogramVec
+
+	ironicAvailability *metrics.CounterVec
+
+	refreshDuration *metrics.HistogramVec
+	refreshErrors   *metrics.CounterVec
+
+	responseViolations *metrics.CounterVec
+
+	ipConflicts *metrics.CounterVec
+
+	faults *metrics.CounterVec
+
+	projectRefusals *metrics.CounterVec
+
+	policyDecisions *metrics.CounterVec
+
+	hookCalls *metrics.CounterVec
+
+	events *metrics.CounterVec
+}
+
+// refreshBuckets are histogram buckets in seconds suited to listing the
+// inventory of thousands of nodes.
+var refreshBuckets = []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// NewMetrics returns metrics registered in registry.
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		registry: registry,
+		resolverAttempts: registry.NewCounterVec(
+			"ironic_metadata_resolver_attempts_total",
+			"Node lookups attempted by each resolver.",
+			"resolver"),
+		resolverSuccesses: registry.NewCounterVec(
+			"ironic_metadata_resolver_successes_total",
+			"Node lookups in which each resolver found the node.",
+			"resolver"),
+		resolverDuration: registry.NewHistogramVec(
+			"ironic_metadata_resolver_duration_seconds",
+			"Duration of the node lookups of each resolver.",
+			nil, "resolver"),
+		ironicRequests: registry.NewCounterVec(
+			"ironic_metadata_ironic_requests_total",
+			"Ironic API requests by operation and status code, or error when no "+
+				"response was received.",
+			"operation", "code"),
+		ironicErrors: registry.NewCounterVec(
+			"ironic_metadata_ironic_request_errors_total",
+			"Ironic API requests that failed without a response or with a server error.",
+			"operation"),
+		ironicDuration: registry.NewHistogramVec(
+			"ironic_metadata_ironic_request_duration_seconds",
+			"Duration of Ironic API requests by operation. Each page of a "+
+				"paginated listing is a request.",
+			nil, "operation"),
+		ironicAvailability: registry.NewCounterVec(
+			"ironic_metadata_ironic_availability_transitions_total",
+			"Times Ironic was found down, after waiting for it timed out, or up, "+
+				"after waiting for it or being down, by state.",
+			"state"),
+		refreshDuration: registry.NewHistogramVec(
+			"ironic_metadata_inventory_refresh_duration_seconds",
+			"Duration of the fetches of the cached node and port inventories.",
+			refreshBuckets, "inventory"),
+		refreshErrors: registry.NewCounterVec(
+			"ironic_metadata_inventory_refresh_errors_total",
+			"Fetches of the cached node and port inventories that failed.",
+			"inventory"),
+		responseViolations: registry.NewCounterVec(
+			"ironic_metadata_response_violations_total",
+			"Schema violations found in rendered documents by RESPONSE_VALIDATION.",
+			"document"),
+		ipConflicts: registry.NewCounterVec(
+			"ironic_metadata_ip_conflicts_total",
+			"Client IPs found held by several nodes, by the IP_CONFLICT_POLICY "+
+				"resolving them.",
+			"policy"),
+		faults: registry.NewCounterVec(
+			"ironic_metadata_faults_injected_total",
+			"Faults injected into metadata responses by FAULT_INJECTION.",
+			"fault"),
+		projectRefusals: registry.NewCounterVec(
+			"ironic_metadata_project_refusals_total",
+			"Requests refused as their node is deployed for a project outside "+
+				"SERVED_PROJECTS.",
+			"endpoint"),
+		policyDecisions: registry.NewCounterVec(
+			"ironic_metadata_policy_decisions_total",
+			"Decisions of the OPA policy on metadata responses.",
+			"decision"),
+		hookCalls: registry.NewCounterVec(
+			"ironic_metadata_hook_calls_total",
+			"Calls of the mutation hook on metadata responses.",
+			"result"),
+		events: registry.NewCounterVec(
+			"ironic_metadata_events_total",
+			"Events queued for each event sink, or dropped as its queue was full.",
+			"sink", "type", "result"),
+	}
+}
+
+// namedCache is a cache of the handler, with the function returning its
+// usage.
+type namedCache struct {
+	name  string
+	stats func() metrics.CacheStats
+}
+
+// caches returns the caches of the handler, as configured so far.
+func (h *Handler) caches() []namedCache {
+	caches := []namedCache{
+		{"configdrive", h.configDriveCache.stats},
+		{"allocation", h.allocationCache.stats},
+	}
+	if h.InspectionNetworkData || h.HardwareVendorData {
+		caches = append(caches, namedCache{"inspection_inventory", h.inventoryCache.stats})
+	}
+	if h.ConfigDrives != nil {
+		caches = append(caches, namedCache{"configdrive_download", h.ConfigDrives.CacheStats})
+	}
+	if h.RemoteUserData != nil {
+		caches = append(caches, namedCache{"user_data_download", h.RemoteUserData.CacheStats})
+	}
+	if h.Vault != nil {
+		caches = append(caches, namedCache{"vault", h.Vault.CacheStats})
+	}
+	if h.KubernetesUserData != nil {
+		caches = append(caches,
+			namedCache{"kubernetes_user_data", h.KubernetesUserData.CacheStats})
+	}
+	if cached, ok := h.Nodes.(*client.CachedSource); ok {
+		caches = append(caches, namedCache{"nodes", cached.CacheStats})
+	}
+	return caches
+}
+
+// EnableMetrics instruments the handler with metrics registered in
+// registry, including the caches configured so far. It is called once,
+// after the handler is configured.
+func (h *Handler) EnableMetrics(registry *metrics.Registry) {
+	h.Metrics = NewMetrics(registry)
+	for _, cache := range h.caches() {
+		h.Metrics.RegisterCache(cache.name, cache.stats)
+	}
+	if h.Clients != nil {
+		h.Metrics.RegisterIronic(h.Clients)
+	}
+	if cached, ok := h.Nodes.(*client.CachedSource); ok {
+		cached.OnRefresh(h.Metrics.observeRefresh)
+	}
+	if h.DHCPLeases != nil {
+		h.Metrics.RegisterLeases(h.DHCPLeases)
+	}
+	if h.DHCPACKs != nil {
+		h.Metrics.RegisterLearned(h.DHCPACKs)
+	}
+	for _, sink := range h.EventSinks {
+		if exporter, ok := sink.(*audit.Exporter); ok {
+			h.Metrics.RegisterAudit(exporter)
+		}
+	}
+}
+
+// RegisterIronic exposes whether the Ironic of clients is up, and counts its
+// availability transitions.
+func (m *Metrics) RegisterIronic(clients *client.Clients) {
+	m.registry.NewGaugeFunc("ironic_metadata_ironic_up",
+		"Whether Ironic is known to be up: 1 once it was seen up or when not "+
+			"waiting for it, 0 while waiting for it or after waiting timed out.",
+		nil, func() float64 {
+			if clients.Available() {
+				return 1
+			}
+			return 0
+		})
+	clients.OnAvailability(m.observeAvailability)
+}
+
+// RegisterLeases exposes the parse errors, duplicates and size of a DHCP
+// lease database.
+func (m *Metrics) RegisterLeases(f *leases.File) {
+	for _, format := range leases.Formats {
+		m.registry.NewCounterFunc("ironic_metadata_dhcp_lease_parse_errors_total",
+			"Malformed entries skipped while parsing the DHCP lease database, by format.",
+			metrics.Labels{"format": format},
+			func() float64 { return float64(f.ParseErrors(format)) })
+	}
+	m.registry.NewCounterFunc("ironic_metadata_dhcp_lease_duplicates_total",
+		"Lease entries superseded by a newer lease of the same IP address.",
+		nil, func() float64 { return float64(f.Duplicates()) })
+	m.registry.NewGaugeFunc("ironic_metadata_dhcp_leases",
+		"IP addresses with a lease in the DHCP lease database as last parsed.",
+		nil, func() float64 { return float64(f.Stats().Leases) })
+}
+
+// RegisterLearned exposes the ACKs captured into, and the size of, a table of
+// learned leases.
+func (m *Metrics) RegisterLearned(l *leases.Learned) {
+	m.registry.NewCounterFunc("ironic_metadata_dhcp_acks_total",
+		"DHCP ACKs captured on the network.",
+		nil, func() float64 { return float64(l.Added()) })
+	m.registry.NewGaugeFunc("ironic_metadata_dhcp_learned_leases",
+		"IP addresses with a lease learned from captured DHCP ACKs, including "+
+			"expired leases not yet dropped.",
+		nil, func() float64 { return float64(l.Len()) })
+}
+
+// RegisterAudit exposes the exports of the audit trail to object storage
+// and the objects deleted for being older than the retention.
+func (m *Metrics) RegisterAudit(e *audit.Exporter) {
+	for _, kind := range audit.Kinds {
+		for _, result := range []string{audit.ResultSuccess, audit.ResultFailure} {
+			m.registry.NewCounterFunc("ironic_metadata_audit_exports_total",
+				"Exports of the audit trail to object storage, by kind and result.",
+				metrics.Labels{"kind": kind, "result": result},
+				func() float64 { return float64(e.Exports(kind, result)) })
+		}
+	}
+	m.registry.NewCounterFunc("ironic_metadata_audit_pruned_total",
+		"Audit objects deleted for being older than AUDIT_EXPORT_RETENTION.",
+		nil, func() float64 { return float64(e.Pruned()) })
+}
+
+// RegisterCache exposes the size, age, hit rate and evictions of a cache
+// called name.
+func (m *Metrics) RegisterCache(name string, stats func() metrics.CacheStats) {
+	labels := metrics.Labels{"cache": name}
+	m.registry.NewGaugeFunc("ironic_metadata_cache_entries",
+		"Entries held by each cache.",
+		labels, func() float64 { return float64(stats().Entries) })
+	m.registry.NewGaugeFunc("ironic_metadata_cache_oldest_entry_age_seconds",
+		"Age of the oldest entry of each cache, zero when it is empty.",
+		labels, func() float64 {
+			oldest := stats().Oldest
+			if oldest.IsZero() {
+				return 0
+			}
+			return metrics.Since(oldest)
+		})
+	m.registry.NewCounterFunc("ironic_metadata_cache_hits_total",
+		"Lookups answered by each cache.",
+		labels, func() float64 { return float64(stats().Hits) })
+	m.registry.NewCounterFunc("ironic_metadata_cache_misses_total",
+		"Lookups each cache could not answer.",
+		labels, func() float64 { return float64(stats().Misses) })
+	m.registry.NewCounterFunc("ironic_metadata_cache_evictions_total",
+		"Entries each cache dropped to stay within CACHE_MAX_ENTRIES and "+
+			"CACHE_MAX_BYTES.",
+		labels, func() float64 { return float64(stats().Evictions) })
+	m.registry.NewGaugeFunc("ironic_metadata_cache_bytes",
+		"Estimated memory taken by the entries of each cache, zero for caches "+
+			"not sizing their entries.",
+		labels, func() float64 { return float64(stats().Bytes) })
+}
+
+// observeResolver records a lookup by resolver started at start, which
+// found the node when found is true.
+func (m *Metrics) observeResolver(resolver string, start time.Time, found bool) {
+	if m == nil {
+		return
+	}
+	m.resolverAttempts.With(resolver).Inc()
+	if found {
+		m.resolverSuccesses.With(resolver).Inc()
+	}
+	m.resolverDuration.With(resolver).Observe(metrics.Since(start))
+}
+
+// observeRefresh records a fetch of a cached inventory that took took and
+// failed with err, if not nil.
+func (m *Metrics) observeRefresh(inventory string, took time.Duration, err error) {
+	m.refreshDuration.With(inventory).Observe(took.Seconds())
+	if err != nil {
+		m.refreshErrors.With(inventory).Inc()
+	}
+}
+
+// observeAvailability records Ironic being found up or down.
+func (m *Metrics) observeAvailability(up bool) {
+	state := "down"
+	if up {
+		state = "up"
+	}
+	m.ironicAvailability.With(state).Inc()
+}
+
+// observeViolations records the schema violations found in a rendered
+// document.
+func (m *Metrics) observeViolations(document string, violations int) {
+	if m == nil || violations == 0 {
+		return
+	}
+	m.responseViolations.With(document).Add(float64(violations))
+}
+
+// observeIPConflict records a client IP held by several nodes, resolved by
+// policy.
+func (m *Metrics) observeIPConflict(policy string) {
+	if m == nil {
+		return
+	}
+	m.ipConflicts.With(policy).Inc()
+}
+
+// observeFault records a fault injected into a metadata response.
+func (m *Metrics) observeFault(kind string) {
+	if m == nil {
+		return
+	}
+	m.faults.With(kind).Inc()
+}
+
+// observeProjectRefusal records a request to an endpoint refused as its node
+// is deployed for an unserved project.
+func (m *Metrics) observeProjectRefusal(endpoint string) {
+	if m == nil {
+		return
+	}
+	m.projectRefusals.With(endpoint).Inc()
+}
+
+// observePolicyDecision records a decision of the policy on a response.
+func (m *Metrics) observePolicyDecision(decision string) {
+	if m == nil {
+		return
+	}
+	m.policyDecisions.With(decision).Inc()
+}
+
+// observeHookCall records a call of the mutation hook on a response.
+func (m *Metrics) observeHookCall(result string) {
+	if m == nil {
+		return
+	}
+	m.hookCalls.With(result).Inc()
+}
+
+// observeEvent records an event queued for an event sink or dropped.
+func (m *Metrics) observeEvent(sink, eventType string, queued bool) {
+	if m == nil {
+		return
+	}
+	result := "queued"
+	if !queued {
+		result = "dropped"
+	}
+	m.events.With(sink, eventType, result).Inc()
+}
+
+// InstrumentIronic returns a transport recording the requests to the Ironic
+// API at endpoint made through next, or http.DefaultTransport when next is
+// nil. Other requests, such as those to object storage sharing the provider
+// client, pass through unrecorded.
+func (m *Metrics) InstrumentIronic(endpoint string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		path, ok := ironicPath(endpoint, r)
+		if !ok {
+			return next.RoundTrip(r)
+		}
+		operation := r.Method + " " + ironicOperation(path)
+
+		start := time.Now()
+		resp, err := next.RoundTrip(r)
+		m.ironicDuration.With(operation).Observe(metrics.Since(start))
+
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		m.ironicRequests.With(operation, code).Inc()
+		if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+			m.ironicErrors.With(operation).Inc()
+		}
+		return resp, err
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// ironicPath returns the path of a request below the Ironic endpoint.
+func ironicPath(endpoint string, r *http.Request) (string, bool) {
+	u := *r.URL
+	u.RawQuery = ""
+	u.Fragment = ""
+	s := u.String()
+	if s+"/" == endpoint {
+		return "", true
+	}
+	if !strings.HasPrefix(s, endpoint) {
+		return "", false
+	}
+	return strings.TrimPrefix(s, endpoint), true
+}
+
+// ironicOperation names the operation of a path below the Ironic endpoint
+// after its resource, replacing identifiers so that operations on different
+// nodes share a name, as in /nodes/{id}/states/provision.
+func ironicOperation(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		switch {
+		case i == 1 && segment != "detail",
+			i == 3 && segments[2] != "states":
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,176 @@
+package metadata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/fakeironic"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestIronicOperation(t *testing.T) {
+	tests := []struct {
+		have string
+		want string
+	}{
+		{have: "", want: "/"},
+		{have: "nodes", want: "/nodes"},
+		{have: "nodes/detail", want: "/nodes/detail"},
+		{have: "nodes/1be26c0b-03f2-4d2e-ae87-c02d7f33c123", want: "/nodes/{id}"},
+		{have: "nodes/web01/states/provision", want: "/nodes/{id}/states/provision"},
+		{have: "nodes/web01/vifs/vif-1", want: "/nodes/{id}/vifs/{id}"},
+		{have: "ports/", want: "/ports"},
+	}
+	for _, tt := range tests {
+		if have := ironicOperation(tt.have); have != tt.want {
+			t.Errorf("ironicOperation(%q): have %q, want %q", tt.have, have, tt.want)
+		}
+	}
+}
+
+func TestHandler_Metrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/nodes/detail":
+			_, _ = w.Write([]byte(`{"nodes": [{"uuid": "uuid-1", "name": "node-10.0.0.5"}]}`))
+		default:
+			http.Error(w, `{"error_message": "unavailable"}`, http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	leaseFile := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	if err := os.WriteFile(leaseFile, []byte("garbage\n"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	registry := metrics.NewRegistry()
+	h := createTestHandler()
+	h.DHCPLeases = leases.NewFile(leaseFile)
+	h.EnableMetrics(registry)
+	endpoint := srv.URL + "/v1/"
+	h.Clients.SetIronicClient(&gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{
+			HTTPClient: http.Client{Transport: h.Metrics.InstrumentIronic(endpoint, nil)},
+		},
+		Endpoint: endpoint,
+	})
+
+	if _, err := h.getNodeByIP(t.Context(), "10.0.0.5"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := h.getNodeByIP(t.Context(), "10.0.0.6"); err == nil {
+		t.Fatal("expected error for unknown IP")
+	}
+
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have := b.String()
+	for _, want := range []string{
+		`ironic_metadata_resolver_attempts_total{resolver="ip"} 2`,
+		`ironic_metadata_resolver_successes_total{resolver="ip"} 1`,
+		`ironic_metadata_resolver_attempts_total{resolver="dhcp_lease"} 1`,
+		`ironic_metadata_resolver_duration_seconds_count{resolver="ip"} 2`,
+		`ironic_metadata_ironic_requests_total{code="200",operation="GET /nodes/detail"} 2`,
+		`ironic_metadata_ironic_request_duration_seconds_count{operation="GET /nodes/detail"} 2`,
+		`ironic_metadata_cache_entries{cache="configdrive"} 0`,
+		`ironic_metadata_cache_evictions_total{cache="configdrive"} 0`,
+		`ironic_metadata_dhcp_lease_parse_errors_total{format="dnsmasq"} 1`,
+		`ironic_metadata_dhcp_lease_parse_errors_total{format="isc"} 0`,
+		`ironic_metadata_dhcp_leases 0`,
+	} {
+		if !strings.Contains(have, want+"\n") {
+			t.Errorf("expected %q in\n%s", want, have)
+		}
+	}
+	if strings.Contains(have, `resolver_successes_total{resolver="dhcp_lease"}`) {
+		t.Error("expected no dhcp_lease successes")
+	}
+}
+
+func TestHandler_Metrics_refresh(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{UUID: "node-1"})
+	registry := metrics.NewRegistry()
+	h := createTestHandler()
+	h.Nodes = client.NewCachedSource(source, time.Minute)
+	h.EnableMetrics(registry)
+
+	if err := h.Warm(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	source.SetError(errors.New("unavailable"))
+	if err := h.Warm(t.Context()); err == nil {
+		t.Fatal("expected error while the source fails")
+	}
+
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have := b.String()
+	for _, want := range []string{
+		`ironic_metadata_inventory_refresh_duration_seconds_count{inventory="nodes"} 2`,
+		`ironic_metadata_inventory_refresh_duration_seconds_count{inventory="ports"} 1`,
+		`ironic_metadata_inventory_refresh_errors_total{inventory="nodes"} 1`,
+		`ironic_metadata_cache_entries{cache="nodes"} 1`,
+	} {
+		if !strings.Contains(have, want+"\n") {
+			t.Errorf("expected %q in\n%s", want, have)
+		}
+	}
+}
+
+func TestHandler_Metrics_ironicAvailability(t *testing.T) {
+	srv := fakeironic.New(t)
+	srv.SetFailure(http.StatusServiceUnavailable)
+	clients, err := client.NewClients(client.Options{
+		Ironic:       srv.ServiceClient(),
+		WaitTimeout:  20 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	registry := metrics.NewRegistry()
+	h := &Handler{Clients: clients}
+	h.EnableMetrics(registry)
+
+	if _, err := clients.IronicClient(t.Context()); err == nil {
+		t.Fatal("expected error while Ironic is down")
+	}
+	srv.SetFailure(0)
+	srv.SetDrivers("ipmi")
+	time.Sleep(10 * time.Millisecond)
+	if _, err := clients.IronicClient(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have := b.String()
+	for _, want := range []string{
+		`ironic_metadata_ironic_up 1`,
+		`ironic_metadata_ironic_availability_transitions_total{state="down"} 1`,
+		`ironic_metadata_ironic_availability_transitions_total{state="up"} 1`,
+	} {
+		if !strings.Contains(have, want+"\n") {
+			t.Errorf("expected %q in\n%s", want, have)
+		}
+	}
+}
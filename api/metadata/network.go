@@ -0,0 +1,125 @@
+package metadata
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/netconfig"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// NetworkData returns the network data served to a node, so other tools can
+// render it outside of an HTTP request.
+func (h *Handler) NetworkData(node *nodes.Node) *metadata.NetworkData {
+	return h.buildNetworkData(node)
+}
+
+// ironicNetworkData returns the network_data field of a node, which Ironic
+// reports from API version 1.66, or false when it is empty or invalid.
+// Unlike the network_data of instance_info, a document violating its schema
+// is not used, so the network data is built from the other sources instead.
+func (h *Handler) ironicNetworkData(node *nodes.Node) (*metadata.NetworkData, bool) {
+	if len(node.NetworkData) == 0 {
+		return nil, false
+	}
+
+	// Documents leaving out a list, such as services, have none.
+	document := make(map[string]any, len(node.NetworkData)+3)
+	for _, key := range []string{"links", "networks", "services"} {
+		document[key] = []any{}
+	}
+	maps.Copy(document, node.NetworkData)
+
+	networkData := &metadata.NetworkData{}
+	body, err := json.Marshal(document)
+	if err == nil {
+		err = json.Unmarshal(body, networkData)
+	}
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Ignoring invalid network_data of node")
+		return nil, false
+	}
+
+	violations := metadata.ValidateNetworkData(body)
+	for _, violation := range violations {
+		h.logger().Warn().
+			Str("node_uuid", node.UUID).
+			Str("path", violation.Path).
+			Str("violation", violation.Message).
+			Msg("network_data of node violates its schema")
+	}
+	if len(violations) > 0 {
+		return nil, false
+	}
+	return networkData, true
+}
+
+// handleNetplan handles requests to /network/netplan.yaml.
+func (h *Handler) handleNetplan(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "netplan")
+	if !ok {
+		return
+	}
+
+	b, err := netconfig.MarshalNetplan(h.buildNetworkData(node))
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to render netplan configuration")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.writeYAMLResponse(w, b)
+}
+
+// handleENI handles requests to /network/interfaces.
+func (h *Handler) handleENI(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "eni")
+	if !ok {
+		return
+	}
+
+	h.writeTextResponse(w, netconfig.ENI(h.buildNetworkData(node)))
+}
+
+// handleNetworkConfig handles requests to /network/config. The format is
+// taken from the format query parameter, falling back to the node's
+// instance_info["network_config_format"] so images that only understand
+// one format can be pinned to it, and to netplan otherwise.
+func (h *Handler) handleNetworkConfig(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "network_config")
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format, _ = node.InstanceInfo["network_config_format"].(string)
+	}
+	if format == "" {
+		format = netconfig.FormatNetplan
+	}
+
+	b, err := netconfig.Render(format, h.buildNetworkData(node))
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Str("format", format).
+			Msg("Failed to render network configuration")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if format == netconfig.FormatENI {
+		h.writeTextResponse(w, string(b))
+		return
+	}
+	h.writeYAMLResponse(w, b)
+}
@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_ironicNetworkData(t *testing.T) {
+	metal3NetworkData := `{"links": [{"id": "metal3", "type": "phy"}], "networks": []}`
+
+	tests := []struct {
+		name        string
+		networkData map[string]any
+		want        string
+	}{
+		{
+			name: "node network data",
+			networkData: map[string]any{
+				"links": []any{map[string]any{
+					"id": "eno1", "type": "phy", "ethernet_mac_address": "52:54:00:00:00:01",
+				}},
+				"networks": []any{map[string]any{
+					"id": "net0", "type": "ipv4", "link": "eno1", "network_id": "net0",
+					"ip_address": "10.0.0.5", "netmask": "255.255.255.0",
+				}},
+			},
+			want: "eno1",
+		},
+		{name: "none", want: "metal3"},
+		{
+			name:        "invalid",
+			networkData: map[string]any{"links": "bond0"},
+			want:        "metal3",
+		},
+		{
+			name:        "schema violation",
+			networkData: map[string]any{"links": []any{map[string]any{"type": "phy"}}},
+			want:        "metal3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &nodes.Node{
+				UUID:         "node-1",
+				NetworkData:  tt.networkData,
+				InstanceInfo: map[string]any{"network_data": metal3NetworkData},
+			}
+			networkData := createTestHandler().buildNetworkData(node)
+			if len(networkData.Links) != 1 || networkData.Links[0].ID != tt.want {
+				t.Errorf("have links %+v, want %s", networkData.Links, tt.want)
+			}
+			if networkData.Services == nil {
+				t.Error("expected services to be an empty list")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/iso"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/netconfig"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultNetworkConfigVersion is the network-config version served when the
+// request does not ask for a specific one.
+const defaultNetworkConfigVersion = 2
+
+// noCloudLabel is the volume label cloud-init looks for on NoCloud seeds.
+const noCloudLabel = "cidata"
+
+// noCloudMetaData is the meta-data document of the NoCloud datasource.
+type noCloudMetaData struct {
+	InstanceID    string   `yaml:"instance-id"`
+	LocalHostname string   `yaml:"local-hostname"`
+	PublicKeys    []string `yaml:"public-keys,omitempty"`
+}
+
+// handleNoCloudMetaData handles requests to /nocloud/meta-data.
+func (h *Handler) handleNoCloudMetaData(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "nocloud_meta_data")
+	if !ok {
+		return
+	}
+
+	b, err := h.renderNoCloudMetaData(node)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to marshal NoCloud meta-data")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.writeYAMLResponse(w, b)
+}
+
+// renderNoCloudMetaData renders the NoCloud meta-data document of a node.
+func (h *Handler) renderNoCloudMetaData(node *nodes.Node) ([]byte, error) {
+	metaData := h.buildMetaData(node)
+	return yaml.Marshal(noCloudMetaData{
+		InstanceID:    metaData.UUID,
+		LocalHostname: metaData.Hostname,
+		PublicKeys:    sortedPublicKeys(metaData.PublicKeys),
+	})
+}
+
+// handleNoCloudUserData handles requests to /nocloud/user-data. Unlike the
+// OpenStack endpoint, a node without user data gets an empty document, since
+// the NoCloud datasource treats a missing user-data file as a seed failure.
+func (h *Handler) handleNoCloudUserData(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "nocloud_user_data")
+	if !ok {
+		return
+	}
+
+	body, err := h.openUserData(r.Context(), node)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to marshal user data")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.writeUserData(w, r, node, body)
+}
+
+// handleNoCloudNetworkConfig handles requests to /nocloud/network-config.
+func (h *Handler) handleNoCloudNetworkConfig(w http.ResponseWriter, r *http.Request) {
+	version, err := networkConfigVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	node, _, ok := h.nodeForRequest(w, r, "nocloud_network_config")
+	if !ok {
+		return
+	}
+
+	b, err := h.renderNoCloudNetworkConfig(node, version)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Int("version", version).
+			Msg("Failed to render network-config")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.writeYAMLResponse(w, b)
+}
+
+// renderNoCloudNetworkConfig renders the network-config document of a node
+// in the given version.
+func (h *Handler) renderNoCloudNetworkConfig(node *nodes.Node, version int) ([]byte, error) {
+	networkData := h.buildNetworkData(node)
+	if version == 1 {
+		return netconfig.MarshalV1(networkData)
+	}
+	return netconfig.MarshalV2(networkData)
+}
+
+// handleNoCloudSeedISO handles requests to /admin/nodes/{uuid}/seed.iso,
+// rendering a node's NoCloud documents into a seed ISO labelled cidata that
+// can be attached as virtual media.
+func (h *Handler) handleNoCloudSeedISO(w http.ResponseWriter, r *http.Request) {
+	version, err := networkConfigVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+
+	image, err := h.renderNoCloudSeed(node, version)
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to render NoCloud seed ISO")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-iso9660-image")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", node.UUID+"-seed.iso"))
+	if _, err := w.Write(image); err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to write seed ISO response")
+	}
+}
+
+// renderNoCloudSeed renders a NoCloud seed ISO for a node.
+func (h *Handler) renderNoCloudSeed(node *nodes.Node, version int) ([]byte, error) {
+	metaData, err := h.renderNoCloudMetaData(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render meta-data: %w", err)
+	}
+	userData, err := h.renderUserData(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render user-data: %w", err)
+	}
+	networkConfig, err := h.renderNoCloudNetworkConfig(node, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render network-config: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := iso.Write(&buf, noCloudLabel, map[string][]byte{
+		"meta-data":      metaData,
+		"user-data":      userData,
+		"network-config": networkConfig,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write seed ISO: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// networkConfigVersion determines the requested network-config version from
+// the route prefix or the version query parameter.
+func networkConfigVersion(r *http.Request) (int, error) {
+	version := strings.TrimPrefix(mux.Vars(r)["version"], "v")
+	if version == "" {
+		version = r.URL.Query().Get("version")
+	}
+
+	switch version {
+	case "":
+		return defaultNetworkConfigVersion, nil
+	case "1":
+		return 1, nil
+	case "2":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("unsupported network-config version %q", version)
+}
@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/blob"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+func TestNetworkConfigVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		vars    map[string]string
+		want    int
+		wantErr bool
+	}{
+		{name: "default", target: "/nocloud/network-config", want: 2},
+		{name: "query v1", target: "/nocloud/network-config?version=1", want: 1},
+		{name: "query v2", target: "/nocloud/network-config?version=2", want: 2},
+		{
+			name:   "route v1",
+			target: "/nocloud/v1/network-config",
+			vars:   map[string]string{"version": "v1"},
+			want:   1,
+		},
+		{name: "invalid", target: "/nocloud/network-config?version=3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.vars != nil {
+				req = mux.SetURLVars(req, tt.vars)
+			}
+
+			have, err := networkConfigVersion(req)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error for %s, got version %d", tt.target, have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("expected version %d, got %d", tt.want, have)
+			}
+		})
+	}
+}
+
+func TestRenderNoCloudSeed(t *testing.T) {
+	h := createTestHandler()
+	node := &nodes.Node{
+		UUID: "test-uuid",
+		Name: "test-node",
+		InstanceInfo: map[string]any{
+			"user_data": "#cloud-config\n",
+		},
+	}
+
+	image, err := h.renderNoCloudSeed(node, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !blob.IsISO(image) {
+		t.Fatal("expected an ISO image")
+	}
+	for _, want := range []string{"instance-id: test-uuid", "#cloud-config", "version: 2"} {
+		if !bytes.Contains(image, []byte(want)) {
+			t.Errorf("expected seed to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPISpec describes the metadata, EC2-compatible, GCE-compatible and
+// admin API surface as an OpenAPI 3 document. Tests check it against the
+// routes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIRoutes registers the route serving the OpenAPI document.
+func (h *Handler) openAPIRoutes(r *mux.Router) {
+	r.HandleFunc("/openapi.json", h.handleOpenAPI).Methods("GET")
+}
+
+// handleOpenAPI handles GET requests to /openapi.json. With a base path,
+// the document names it as the server URL.
+func (h *Handler) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	base := cleanBasePath(h.BasePath)
+	if base == "" {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(openAPISpec); err != nil {
+			h.logger().Error().
+				Err(err).
+				Msg("Failed to write OpenAPI document")
+		}
+		return
+	}
+
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		h.logger().Error().
+			Err(err).
+			Msg("Failed to parse OpenAPI document")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	spec["servers"] = []map[string]string{{"url": base}}
+	h.writeJSONResponse(w, spec)
+}
@@ -0,0 +1,187 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/logging"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+// openAPIDocument is the part of an OpenAPI document the tests check.
+type openAPIDocument struct {
+	OpenAPI string                                 `json:"openapi"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIOperation struct {
+	OperationID string         `json:"operationId"`
+	Responses   map[string]any `json:"responses"`
+}
+
+// routeVariable matches a route variable with its pattern, such as
+// {index:[0-9]+}.
+var routeVariable = regexp.MustCompile(`\{([^:}]+):[^}]*\}`)
+
+func TestOpenAPISpec_routes(t *testing.T) {
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	h.GCE = true
+	h.LogLevel = logging.NewLevel(zerolog.GlobalLevel(), 0)
+	router := h.router()
+
+	var routes []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		// Subrouters have no methods.
+		methods, _ := route.GetMethods()
+		path := routeVariable.ReplaceAllString(tpl, "{$1}")
+		if path == gcePrefix+"/" {
+			path += "{path}"
+		}
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+	var operations []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	for _, route := range routes {
+		if !slices.Contains(operations, route) {
+			t.Errorf("route %s is not described", route)
+		}
+	}
+	for _, operation := range operations {
+		if !slices.Contains(routes, operation) {
+			t.Errorf("operation %s is not routed", operation)
+		}
+	}
+}
+
+func TestOpenAPISpec_valid(t *testing.T) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("have OpenAPI version %q, want 3.0.3", doc.OpenAPI)
+	}
+
+	ids := make(map[string]string)
+	for path, item := range doc.Paths {
+		for method, operation := range item {
+			name := strings.ToUpper(method) + " " + path
+			if operation.OperationID == "" {
+				t.Errorf("%s has no operationId", name)
+			} else if other, ok := ids[operation.OperationID]; ok {
+				t.Errorf("%s and %s share operationId %s", name, other, operation.OperationID)
+			}
+			ids[operation.OperationID] = name
+			if len(operation.Responses) == 0 {
+				t.Errorf("%s has no responses", name)
+			}
+		}
+	}
+
+	// Every reference resolves within the document.
+	var raw map[string]any
+	if err := json.Unmarshal(openAPISpec, &raw); err != nil {
+		t.Fatal(err)
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok && !resolveRef(raw, ref) {
+				t.Errorf("unresolved reference %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(raw)
+}
+
+// resolveRef reports whether a local reference such as
+// #/components/schemas/MetaData exists in doc.
+func resolveRef(doc map[string]any, ref string) bool {
+	var v any = doc
+	for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		if v, ok = m[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	tests := []struct {
+		name        string
+		basePath    string
+		wantServers string
+	}{
+		{name: "root", wantServers: "null"},
+		{name: "base path", basePath: "/metadata/", wantServers: `[{"url":"/metadata"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.BasePath = tt.basePath
+
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rr.Code)
+			}
+			if have := rr.Header().Get("Content-Type"); have != "application/json" {
+				t.Errorf("have content type %q, want application/json", have)
+			}
+			var have map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			servers, err := json.Marshal(have["servers"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(servers) != tt.wantServers {
+				t.Errorf("have servers %s, want %s", servers, tt.wantServers)
+			}
+		})
+	}
+}
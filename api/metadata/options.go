@@ -0,0 +1,493 @@
+package metadata
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/configdrive"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+	"github.com/appkins-org/ironic-metadata/pkg/hostkeys"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/identity"
+	"github.com/appkins-org/ironic-metadata/pkg/opa"
+	"github.com/appkins-org/ironic-metadata/pkg/plugins"
+	"github.com/appkins-org/ironic-metadata/pkg/profiles"
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/rs/zerolog"
+)
+
+// Option configures a Handler built by NewHandler.
+type Option func(*Handler)
+
+// NewHandler returns a Handler configured by opts, applied in order. Fields
+// no option sets keep their zero value, which disables the feature they
+// configure, and can still be set on the returned Handler before Routes is
+// called.
+func NewHandler(opts ...Option) *Handler {
+	h := &Handler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Clone returns a Handler with the configuration of h, every exported
+// field and its clock, but caches and state of its own, with opts applied.
+// It configures the handler of another Ironic, such as that of a Backend,
+// like h.
+func (h *Handler) Clone(opts ...Option) *Handler {
+	clone := &Handler{
+		Clients:               h.Clients,
+		Nodes:                 h.Nodes,
+		NodeWriter:            h.NodeWriter,
+		Resolvers:             h.Resolvers,
+		DHCPLeases:            h.DHCPLeases,
+		ReverseDNS:            h.ReverseDNS,
+		DHCPACKs:              h.DHCPACKs,
+		RelayAgentMatching:    h.RelayAgentMatching,
+		VirtualHostSuffixes:   h.VirtualHostSuffixes,
+		Identity:              h.Identity,
+		Region:                h.Region,
+		GCE:                   h.GCE,
+		ContentDir:            h.ContentDir,
+		AdminToken:            h.AdminToken,
+		TrustedProxies:        h.TrustedProxies,
+		NodeHeaderTrust:       h.NodeHeaderTrust,
+		DebugOverrides:        h.DebugOverrides,
+		InspectionNetworkData: h.InspectionNetworkData,
+		HardwareVendorData:    h.HardwareVendorData,
+		HostKeyEscrow:         h.HostKeyEscrow,
+		HostKeySealer:         h.HostKeySealer,
+		DefaultProjectID:      h.DefaultProjectID,
+		ServedProvisionStates: h.ServedProvisionStates,
+		GoneProvisionStates:   h.GoneProvisionStates,
+		ServedProjects:        h.ServedProjects,
+		RejectMaintenance:     h.RejectMaintenance,
+		ActiveExpiry:          h.ActiveExpiry,
+		ActiveExpiryScope:     h.ActiveExpiryScope,
+		IPConflictPolicy:      h.IPConflictPolicy,
+		ResponseValidation:    h.ResponseValidation,
+		CacheLimits:           h.CacheLimits,
+		Faults:                h.Faults,
+		Policy:                h.Policy,
+		MutationHook:          h.MutationHook,
+		MutationHookEndpoints: h.MutationHookEndpoints,
+		MutationHookFailure:   h.MutationHookFailure,
+		Plugins:               h.Plugins,
+		EventSinks:            h.EventSinks,
+		clock:                 h.clock,
+		ConfigDrives:          h.ConfigDrives,
+		SwiftTempURLKey:       h.SwiftTempURLKey,
+		RedactKeys:            h.RedactKeys,
+		TagsKey:               h.TagsKey,
+		Vault:                 h.Vault,
+		KubernetesUserData:    h.KubernetesUserData,
+		UserDataFragmentsDir:  h.UserDataFragmentsDir,
+		RemoteUserData:        h.RemoteUserData,
+		MaxUserDataSize:       h.MaxUserDataSize,
+		VendorData:            h.VendorData,
+		VendorDataDir:         h.VendorDataDir,
+		TemplateDir:           h.TemplateDir,
+		Profiles:              h.Profiles,
+		JWS:                   h.JWS,
+		CacheControl:          h.CacheControl,
+		DisabledRoutes:        h.DisabledRoutes,
+		BasePath:              h.BasePath,
+		Leader:                h.Leader,
+		Logger:                h.Logger,
+		LogLevel:              h.LogLevel,
+		Metrics:               h.Metrics,
+		AccessLog:             h.AccessLog,
+	}
+	for _, opt := range opts {
+		opt(clone)
+	}
+	return clone
+}
+
+// WithClients sets the Ironic and Swift clients of the handler.
+func WithClients(clients *client.Clients) Option {
+	return func(h *Handler) {
+		h.Clients = clients
+	}
+}
+
+// WithNodeSource sets the source of nodes and ports, such as a
+// client.CachedSource or a mock.NodeSource, used instead of the clients.
+func WithNodeSource(source client.NodeSource) Option {
+	return func(h *Handler) {
+		h.Nodes = source
+	}
+}
+
+// WithNodeWriter sets the writer updating nodes, used instead of the
+// clients.
+func WithNodeWriter(writer client.NodeWriter) Option {
+	return func(h *Handler) {
+		h.NodeWriter = writer
+	}
+}
+
+// WithResolvers sets the resolvers finding the node of a client IP, tried in
+// order, replacing the default chain.
+func WithResolvers(resolvers ...client.Resolver) Option {
+	return func(h *Handler) {
+		h.Resolvers = resolvers
+	}
+}
+
+// WithDHCPLeases sets the lease database of the DHCP server.
+func WithDHCPLeases(file *leases.File) Option {
+	return func(h *Handler) {
+		h.DHCPLeases = file
+	}
+}
+
+// WithDHCPACKs sets the leases learned from captured DHCP ACKs.
+func WithDHCPACKs(learned *leases.Learned) Option {
+	return func(h *Handler) {
+		h.DHCPACKs = learned
+	}
+}
+
+// WithReverseDNS enables matching the hostnames of client IPs to node names.
+func WithReverseDNS(reverseDNS *ReverseDNS) Option {
+	return func(h *Handler) {
+		h.ReverseDNS = reverseDNS
+	}
+}
+
+// WithVirtualHostSuffixes enables selecting the node of a request by its
+// Host header under one of suffixes.
+func WithVirtualHostSuffixes(suffixes ...string) Option {
+	return func(h *Handler) {
+		h.VirtualHostSuffixes = suffixes
+	}
+}
+
+// WithConfigDrives sets the fetcher, and with it the cache, of configdrives
+// referenced by URL or Swift object.
+func WithConfigDrives(fetcher *configdrive.Fetcher) Option {
+	return func(h *Handler) {
+		h.ConfigDrives = fetcher
+	}
+}
+
+// WithRemoteUserData sets the fetcher, and with it the cache, of user data
+// referenced by URL, swift:// or s3:// reference.
+func WithRemoteUserData(fetcher *remote.Fetcher) Option {
+	return func(h *Handler) {
+		h.RemoteUserData = fetcher
+	}
+}
+
+// WithIdentity sets the signer of EC2 instance identity documents.
+func WithIdentity(signer *identity.Signer) Option {
+	return func(h *Handler) {
+		h.Identity = signer
+	}
+}
+
+// WithRegion sets the region reported in EC2 instance identity documents.
+func WithRegion(region string) Option {
+	return func(h *Handler) {
+		h.Region = region
+	}
+}
+
+// WithLogger sets the logger receiving the log output of the handler.
+func WithLogger(logger *zerolog.Logger) Option {
+	return func(h *Handler) {
+		h.Logger = logger
+	}
+}
+
+// WithMetrics sets the metrics instrumenting the handler.
+func WithMetrics(metrics *Metrics) Option {
+	return func(h *Handler) {
+		h.Metrics = metrics
+	}
+}
+
+// WithClock sets the clock the handler reads the current time from, such as
+// the time entries are stored in and expire from its caches. It defaults to
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		h.clock = now
+	}
+}
+
+// WithContentDir sets the directory holding injected file contents.
+func WithContentDir(dir string) Option {
+	return func(h *Handler) {
+		h.ContentDir = dir
+	}
+}
+
+// WithRedactKeys sets the glob patterns of keys never served.
+func WithRedactKeys(patterns ...string) Option {
+	return func(h *Handler) {
+		h.RedactKeys = patterns
+	}
+}
+
+// WithTagsKey sets the node.extra map holding instance tags.
+func WithTagsKey(key string) Option {
+	return func(h *Handler) {
+		h.TagsKey = key
+	}
+}
+
+// WithSwiftTempURLKey sets the key signing temp URLs of configdrives in
+// object storage.
+func WithSwiftTempURLKey(key string) Option {
+	return func(h *Handler) {
+		h.SwiftTempURLKey = key
+	}
+}
+
+// WithUserDataFragmentsDir sets the directory holding user data fragments.
+func WithUserDataFragmentsDir(dir string) Option {
+	return func(h *Handler) {
+		h.UserDataFragmentsDir = dir
+	}
+}
+
+// WithTemplateDir sets the directory holding operator templates.
+func WithTemplateDir(dir string) Option {
+	return func(h *Handler) {
+		h.TemplateDir = dir
+	}
+}
+
+// WithProfiles sets the operator metadata profiles.
+func WithProfiles(p []profiles.Profile) Option {
+	return func(h *Handler) {
+		h.Profiles = p
+	}
+}
+
+// WithVendorDataDir sets the directory holding operator vendor data.
+func WithVendorDataDir(dir string) Option {
+	return func(h *Handler) {
+		h.VendorDataDir = dir
+	}
+}
+
+// WithCacheControl overrides the Cache-Control header of cache classes.
+func WithCacheControl(overrides map[string]string) Option {
+	return func(h *Handler) {
+		h.CacheControl = overrides
+	}
+}
+
+// WithAdminToken sets the bearer token enabling the admin API.
+func WithAdminToken(token string) Option {
+	return func(h *Handler) {
+		h.AdminToken = token
+	}
+}
+
+// WithTrustedProxies sets the networks of the reverse proxies whose
+// forwarding headers name the client of a request.
+func WithTrustedProxies(proxies ...netip.Prefix) Option {
+	return func(h *Handler) {
+		h.TrustedProxies = proxies
+	}
+}
+
+// WithBasePath mounts the routes under a path prefix.
+func WithBasePath(basePath string) Option {
+	return func(h *Handler) {
+		h.BasePath = basePath
+	}
+}
+
+// WithDisabledRoutes disables route families, such as RoutesEC2.
+func WithDisabledRoutes(families ...string) Option {
+	return func(h *Handler) {
+		if h.DisabledRoutes == nil {
+			h.DisabledRoutes = make(map[string]bool)
+		}
+		for _, family := range families {
+			h.DisabledRoutes[family] = true
+		}
+	}
+}
+
+// WithGCE enables the GCE-compatible routes.
+func WithGCE(enabled bool) Option {
+	return func(h *Handler) {
+		h.GCE = enabled
+	}
+}
+
+// WithRelayAgentMatching enables the relay_agent resolver.
+func WithRelayAgentMatching(enabled bool) Option {
+	return func(h *Handler) {
+		h.RelayAgentMatching = enabled
+	}
+}
+
+// WithInspectionNetworkData enables building network data from inspection.
+func WithInspectionNetworkData(enabled bool) Option {
+	return func(h *Handler) {
+		h.InspectionNetworkData = enabled
+	}
+}
+
+// WithHardwareVendorData enables the hardware section of vendor data.
+func WithHardwareVendorData(enabled bool) Option {
+	return func(h *Handler) {
+		h.HardwareVendorData = enabled
+	}
+}
+
+// WithHostKeyEscrow enables the escrow of the SSH host keys of nodes, with
+// their private keys sealed by sealer when it is not nil.
+func WithHostKeyEscrow(sealer *hostkeys.Sealer) Option {
+	return func(h *Handler) {
+		h.HostKeyEscrow = true
+		h.HostKeySealer = sealer
+	}
+}
+
+// WithDebugOverrides lets any request select its node with the node query
+// parameter. It is meant for development only.
+func WithDebugOverrides(enabled bool) Option {
+	return func(h *Handler) {
+		h.DebugOverrides = enabled
+	}
+}
+
+// WithDefaultProjectID sets the project_id of nodes with neither a lessee
+// nor an owner.
+func WithDefaultProjectID(projectID string) Option {
+	return func(h *Handler) {
+		h.DefaultProjectID = projectID
+	}
+}
+
+// WithServedProvisionStates sets the provision states in which the metadata
+// of nodes is served.
+func WithServedProvisionStates(states ...string) Option {
+	return func(h *Handler) {
+		h.ServedProvisionStates = make(map[string]bool)
+		for _, state := range states {
+			h.ServedProvisionStates[state] = true
+		}
+	}
+}
+
+// WithGoneProvisionStates sets the provision states answered with 410 Gone.
+func WithGoneProvisionStates(states ...string) Option {
+	return func(h *Handler) {
+		h.GoneProvisionStates = make(map[string]bool)
+		for _, state := range states {
+			h.GoneProvisionStates[state] = true
+		}
+	}
+}
+
+// WithServedProjects sets the projects whose nodes are served.
+func WithServedProjects(projects ...string) Option {
+	return func(h *Handler) {
+		h.ServedProjects = make(map[string]bool)
+		for _, project := range projects {
+			h.ServedProjects[project] = true
+		}
+	}
+}
+
+// WithRejectMaintenance answers requests of nodes in maintenance with 503
+// Service Unavailable.
+func WithRejectMaintenance(enabled bool) Option {
+	return func(h *Handler) {
+		h.RejectMaintenance = enabled
+	}
+}
+
+// WithActiveExpiry stops serving what scope covers, ExpiryUserData or
+// ExpiryAll, once nodes have been active for expiry.
+func WithActiveExpiry(expiry time.Duration, scope string) Option {
+	return func(h *Handler) {
+		h.ActiveExpiry = expiry
+		h.ActiveExpiryScope = scope
+	}
+}
+
+// WithIPConflictPolicy sets the policy for a client IP several nodes hold,
+// one of ConflictRefuse, ConflictDHCP and ConflictFirst.
+func WithIPConflictPolicy(policy string) Option {
+	return func(h *Handler) {
+		h.IPConflictPolicy = policy
+	}
+}
+
+// WithResponseValidation sets the mode of checking documents against their
+// schemas, one of ValidationOff, ValidationLog and ValidationFail.
+func WithResponseValidation(mode string) Option {
+	return func(h *Handler) {
+		h.ResponseValidation = mode
+	}
+}
+
+// WithCacheLimits bounds each of the per-node caches of the handler.
+func WithCacheLimits(limits lru.Limits) Option {
+	return func(h *Handler) {
+		h.CacheLimits = limits
+	}
+}
+
+// WithFaults injects faults into metadata responses.
+func WithFaults(faults *Faults) Option {
+	return func(h *Handler) {
+		h.Faults = faults
+	}
+}
+
+// WithPolicy asks policy for a decision on the responses served for nodes.
+func WithPolicy(policy *opa.Client) Option {
+	return func(h *Handler) {
+		h.Policy = policy
+	}
+}
+
+// WithMutationHook hands the responses of endpoints served for nodes to
+// mutation, or those of every endpoint when endpoints is empty, applying
+// failure on its failures.
+func WithMutationHook(mutation *hook.Hook, endpoints map[string]bool, failure string) Option {
+	return func(h *Handler) {
+		h.MutationHook = mutation
+		h.MutationHookEndpoints = endpoints
+		h.MutationHookFailure = failure
+	}
+}
+
+// WithPlugins hands responses to the mutate functions of WASM plugins and
+// resolves client IPs with their resolve functions.
+func WithPlugins(wasmPlugins *plugins.Plugins) Option {
+	return func(h *Handler) {
+		h.Plugins = wasmPlugins
+	}
+}
+
+// WithEventSinks sends the events of the service, such as nodes fetching
+// their user data, to sinks.
+func WithEventSinks(sinks ...events.Sink) Option {
+	return func(h *Handler) {
+		h.EventSinks = sinks
+	}
+}
+
+// now returns the current time of the clock of the handler.
+func (h *Handler) now() time.Time {
+	if h.clock != nil {
+		return h.clock()
+	}
+	return time.Now()
+}
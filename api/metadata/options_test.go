@@ -0,0 +1,80 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/allocations"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestNewHandler(t *testing.T) {
+	node := nodes.Node{UUID: "1be26c0b-03f2-4d2e-ae87-c02d7f33c123", Name: "web01"}
+	source := mock.NewNodeSource(node)
+	resolver := &mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": &node}}
+
+	h := NewHandler(
+		WithNodeSource(source),
+		WithResolvers(resolver),
+		WithGCE(true),
+		WithDisabledRoutes(RoutesEC2),
+		WithDefaultProjectID("default"),
+	)
+	if !h.GCE || !h.DisabledRoutes[RoutesEC2] || h.DefaultProjectID != "default" {
+		t.Fatalf("options not applied: %+v", h)
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/openstack/latest/meta_data.json", want: http.StatusOK},
+		{path: "/computeMetadata/v1/instance/hostname", want: http.StatusOK},
+		{path: "/latest/meta-data/instance-id", want: http.StatusNotFound},
+	}
+
+	routes := h.Routes()
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			req.Header.Set("Metadata-Flavor", "Google")
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("have status %d, want %d: %s", rr.Code, tt.want, rr.Body)
+			}
+		})
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	source := mock.NewNodeSource()
+	source.AddAllocation(allocations.Allocation{UUID: "alloc-1", Name: "web01"})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHandler(
+		WithClients(&client.Clients{}),
+		WithNodeSource(source),
+		WithClock(func() time.Time { return now }),
+	)
+
+	node := &nodes.Node{UUID: "node-1", AllocationUUID: "alloc-1"}
+	h.nodeAllocation(node)
+	now = now.Add(allocationCacheTTL - time.Second)
+	h.nodeAllocation(node)
+	if calls := source.Calls(mock.MethodGetAllocation); calls != 1 {
+		t.Errorf("have %d calls before the TTL passed, want 1", calls)
+	}
+
+	now = now.Add(time.Second)
+	h.nodeAllocation(node)
+	if calls := source.Calls(mock.MethodGetAllocation); calls != 2 {
+		t.Errorf("have %d calls after the TTL passed, want 2", calls)
+	}
+}
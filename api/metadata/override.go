@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Headers selecting the node of a trusted request.
+const (
+	HeaderNodeUUID = "X-Node-UUID"
+	HeaderNodeName = "X-Node-Name"
+)
+
+// Credentials trusted to select the node of a request with the node headers.
+const (
+	// TrustAdminToken trusts requests carrying the admin token as a bearer
+	// token.
+	TrustAdminToken = "admin_token"
+
+	// TrustClientCert trusts requests made with a TLS client certificate
+	// verified against the client CA of the listener.
+	TrustClientCert = "client_cert"
+)
+
+// NodeHeaderTrusts lists the credentials that can be trusted to select the
+// node of a request.
+var NodeHeaderTrusts = []string{TrustAdminToken, TrustClientCert}
+
+// ParseNodeHeaderTrust parses a comma separated list of trusted credentials.
+func ParseNodeHeaderTrust(spec string) (map[string]bool, error) {
+	trusts := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(NodeHeaderTrusts, name) {
+			return nil, fmt.Errorf("unknown node header trust %q, want one of %s",
+				name, strings.Join(NodeHeaderTrusts, ", "))
+		}
+		trusts[name] = true
+	}
+	return trusts, nil
+}
+
+// trustsNodeHeaders reports whether a request carries a credential trusted to
+// select its node.
+func (h *Handler) trustsNodeHeaders(r *http.Request) bool {
+	if h.NodeHeaderTrust[TrustAdminToken] && h.AdminToken != "" && h.hasAdminToken(r) {
+		return true
+	}
+	return h.NodeHeaderTrust[TrustClientCert] && r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
+
+// nodeFromHeaders fetches the node selected by the node headers of a
+// request, rejecting requests not trusted to select it. When both headers
+// are set, the node must have both the UUID and the name. When the node
+// cannot be fetched an error response is written and ok is false.
+func (h *Handler) nodeFromHeaders(
+	w http.ResponseWriter,
+	r *http.Request,
+	endpoint string,
+) (node *nodes.Node, ok bool) {
+	nodeUUID, nodeName := r.Header.Get(HeaderNodeUUID), r.Header.Get(HeaderNodeName)
+	if !h.trustsNodeHeaders(r) {
+		h.logger().Warn().
+			Str("path", r.URL.Path).
+			Str("remote_addr", r.RemoteAddr).
+			Str("node_uuid", nodeUUID).
+			Str("node_name", nodeName).
+			Msg("Rejected node headers of untrusted request")
+		http.Error(w, "Node headers not allowed", http.StatusForbidden)
+		return nil, false
+	}
+
+	nodeID := nodeUUID
+	if nodeID == "" {
+		nodeID = nodeName
+	}
+	node, ok = h.fetchNode(w, r, nodeID)
+	if !ok {
+		return nil, false
+	}
+	if nodeUUID != "" && nodeName != "" && node.Name != nodeName {
+		http.Error(w, "Node name does not match node UUID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	h.logger().Info().
+		Str("remote_addr", r.RemoteAddr).
+		Str("node_uuid", node.UUID).
+		Str("node_name", node.Name).
+		Str("endpoint", endpoint).
+		Msg("Selected node from request headers")
+	return node, true
+}
+
+// nodeFromQuery fetches the node selected by the node query parameter of a
+// request, honored when DebugOverrides is set. When the node cannot be
+// fetched an error response is written and ok is false.
+func (h *Handler) nodeFromQuery(
+	w http.ResponseWriter,
+	r *http.Request,
+	endpoint string,
+) (node *nodes.Node, ok bool) {
+	node, ok = h.fetchNode(w, r, r.URL.Query().Get("node"))
+	if !ok {
+		return nil, false
+	}
+
+	h.logger().Warn().
+		Str("remote_addr", r.RemoteAddr).
+		Str("node_uuid", node.UUID).
+		Str("node_name", node.Name).
+		Str("endpoint", endpoint).
+		Msg("Selected node from debug query parameter")
+	return node, true
+}
@@ -0,0 +1,201 @@
+package metadata
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_nodeHeaders(t *testing.T) {
+	const adminToken = "secret"
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+
+	tests := []struct {
+		name       string
+		trust      string
+		headers    map[string]string
+		tls        *tls.ConnectionState
+		wantStatus int
+		wantNode   string
+	}{
+		{
+			name:       "resolved by IP without headers",
+			trust:      TrustAdminToken,
+			wantStatus: http.StatusOK,
+			wantNode:   "node-1",
+		},
+		{
+			name:  "uuid with admin token",
+			trust: TrustAdminToken,
+			headers: map[string]string{
+				HeaderNodeUUID:  "node-2",
+				"Authorization": "Bearer " + adminToken,
+			},
+			wantStatus: http.StatusOK,
+			wantNode:   "node-2",
+		},
+		{
+			name:       "name with client certificate",
+			trust:      TrustClientCert,
+			headers:    map[string]string{HeaderNodeName: "web02"},
+			tls:        verified,
+			wantStatus: http.StatusOK,
+			wantNode:   "node-2",
+		},
+		{
+			name:       "client certificate not trusted",
+			trust:      TrustAdminToken,
+			headers:    map[string]string{HeaderNodeName: "web02"},
+			tls:        verified,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:  "wrong admin token",
+			trust: TrustAdminToken + "," + TrustClientCert,
+			headers: map[string]string{
+				HeaderNodeUUID:  "node-2",
+				"Authorization": "Bearer wrong",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "not trusted at all",
+			headers:    map[string]string{HeaderNodeUUID: "node-2"},
+			tls:        verified,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:  "mismatched name",
+			trust: TrustClientCert,
+			headers: map[string]string{
+				HeaderNodeUUID: "node-2",
+				HeaderNodeName: "web01",
+			},
+			tls:        verified,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown node",
+			trust:      TrustClientCert,
+			headers:    map[string]string{HeaderNodeUUID: "node-3"},
+			tls:        verified,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trust, err := ParseNodeHeaderTrust(tt.trust)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			h := createTestHandler()
+			h.AdminToken = adminToken
+			h.NodeHeaderTrust = trust
+			h.Nodes = mock.NewNodeSource(
+				nodes.Node{UUID: "node-1", Name: "10.0.0.5"},
+				nodes.Node{UUID: "node-2", Name: "web02"},
+			)
+
+			req := httptest.NewRequest("GET", "/openstack/latest/meta_data.json", nil)
+			req.RemoteAddr = "10.0.0.5:40000"
+			req.TLS = tt.tls
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantNode == "" {
+				return
+			}
+			var have struct {
+				UUID string `json:"uuid"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have.UUID != tt.wantNode {
+				t.Errorf("have node %q, want %q", have.UUID, tt.wantNode)
+			}
+		})
+	}
+
+	if _, err := ParseNodeHeaderTrust("everyone"); err == nil {
+		t.Error("expected error for an unknown trust")
+	}
+}
+
+func TestHandler_debugOverrides(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		query      string
+		wantStatus int
+		wantNode   string
+	}{
+		{name: "disabled", query: "?node=node-2", wantStatus: http.StatusOK, wantNode: "node-1"},
+		{
+			name:       "by uuid",
+			enabled:    true,
+			query:      "?node=node-2",
+			wantStatus: http.StatusOK,
+			wantNode:   "node-2",
+		},
+		{
+			name:       "by name",
+			enabled:    true,
+			query:      "?node=web02",
+			wantStatus: http.StatusOK,
+			wantNode:   "node-2",
+		},
+		{name: "without node", enabled: true, wantStatus: http.StatusOK, wantNode: "node-1"},
+		{
+			name:       "unknown node",
+			enabled:    true,
+			query:      "?node=node-3",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.DebugOverrides = tt.enabled
+			h.Nodes = mock.NewNodeSource(
+				nodes.Node{UUID: "node-1", Name: "10.0.0.5"},
+				nodes.Node{UUID: "node-2", Name: "web02"},
+			)
+
+			req := httptest.NewRequest("GET", "/openstack/latest/meta_data.json"+tt.query, nil)
+			req.RemoteAddr = "10.0.0.5:40000"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantNode == "" {
+				return
+			}
+			var have struct {
+				UUID string `json:"uuid"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have.UUID != tt.wantNode {
+				t.Errorf("have node %q, want %q", have.UUID, tt.wantNode)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// passwordField is the instance_info field holding the password posted by
+// a node.
+const passwordField = "password"
+
+// MaxPasswordSize is the size of the largest password a node may post,
+// that of the four chunks of 255 characters Nova stores it in.
+const MaxPasswordSize = 4 * 255
+
+// ErrPasswordSet is returned when posting a password to a node holding one.
+var ErrPasswordSet = errors.New("password already set")
+
+// nodePassword returns the password posted by a node, empty when none is.
+func nodePassword(node *nodes.Node) string {
+	password, _ := node.InstanceInfo[passwordField].(string)
+	return password
+}
+
+// SetPassword stores the password posted by a node in its instance_info.
+// As with Nova, a password is only stored once: posting another fails with
+// ErrPasswordSet until it is cleared. Ironic clears it when the node is
+// undeployed, together with the rest of its instance_info.
+func (h *Handler) SetPassword(ctx context.Context, node *nodes.Node, password string) error {
+	// The node may be cached from before an earlier password was posted.
+	current, err := h.currentNode(ctx, node.UUID)
+	if err != nil {
+		return err
+	}
+	if nodePassword(current) != "" {
+		return ErrPasswordSet
+	}
+	return h.updateNode(ctx, node, nodes.UpdateOperation{
+		Op:    nodes.AddOp,
+		Path:  "/instance_info/" + passwordField,
+		Value: password,
+	})
+}
+
+// ClearPassword removes the password posted by a node, so it can post a
+// new one.
+func (h *Handler) ClearPassword(ctx context.Context, node *nodes.Node) error {
+	current, err := h.currentNode(ctx, node.UUID)
+	if err != nil {
+		return err
+	}
+	if nodePassword(current) == "" {
+		return nil
+	}
+	return h.updateNode(ctx, node, nodes.UpdateOperation{
+		Op:   nodes.RemoveOp,
+		Path: "/instance_info/" + passwordField,
+	})
+}
+
+// handlePassword handles GET requests to /openstack/{version}/password,
+// returning the password the node posted, or nothing when it posted none.
+func (h *Handler) handlePassword(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "password")
+	if !ok {
+		return
+	}
+	h.writeTextResponse(w, nodePassword(node))
+}
+
+// handlePostPassword handles POST requests to /openstack/{version}/password,
+// through which cloudbase-init stores the administrator password it
+// generated, encrypted with the SSH key of the node.
+func (h *Handler) handlePostPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxPasswordSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request is too large", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	node, clientIP, ok := h.nodeForRequest(w, r, "password")
+	if !ok {
+		return
+	}
+
+	logger := h.logger().With().
+		Str("client_ip", clientIP).
+		Str("node_uuid", node.UUID).
+		Logger()
+	if err := h.SetPassword(r.Context(), node, string(body)); err != nil {
+		if errors.Is(err, ErrPasswordSet) {
+			logger.Warn().
+				Msg("Refused to replace the password of node")
+			http.Error(w, "Password already set", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, ErrNotLeader) {
+			writeNotLeader(w)
+			return
+		}
+		if errors.Is(err, ErrNoNodeWriter) {
+			writeNoNodeWriter(w)
+			return
+		}
+		logger.Error().
+			Err(err).
+			Msg("Failed to set password")
+		http.Error(w, "Failed to set password", http.StatusBadGateway)
+		return
+	}
+	logger.Info().
+		Msg("Set node password")
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleClearPassword handles DELETE requests to
+// /admin/nodes/{uuid}/password, clearing the password the node posted.
+func (h *Handler) handleClearPassword(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	if err := h.ClearPassword(r.Context(), node); err != nil {
+		if errors.Is(err, ErrNotLeader) {
+			writeNotLeader(w)
+			return
+		}
+		if errors.Is(err, ErrNoNodeWriter) {
+			writeNoNodeWriter(w)
+			return
+		}
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to clear password")
+		http.Error(w, "Failed to clear password", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,220 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_password(t *testing.T) {
+	node := nodes.Node{
+		UUID:           "node-1",
+		Name:           "win01",
+		ProvisionState: "active",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		stored     string
+		wantStatus int
+		wantBody   string
+		wantPatch  string
+	}{
+		{
+			name:       "post",
+			method:     http.MethodPost,
+			path:       "/openstack/latest/password",
+			body:       "c2VjcmV0",
+			wantStatus: http.StatusOK,
+			wantPatch:  "add",
+		},
+		{
+			name:       "post when set",
+			method:     http.MethodPost,
+			path:       "/openstack/latest/password",
+			body:       "c2VjcmV0",
+			stored:     "b2xk",
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "post too large",
+			method:     http.MethodPost,
+			path:       "/openstack/2013-04-04/password",
+			body:       strings.Repeat("a", MaxPasswordSize+1),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "post before grizzly",
+			method:     http.MethodPost,
+			path:       "/openstack/2012-08-10/password",
+			body:       "c2VjcmV0",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			path:       "/openstack/latest/password",
+			stored:     "b2xk",
+			wantStatus: http.StatusOK,
+			wantBody:   "b2xk",
+		},
+		{
+			name:       "get unset",
+			method:     http.MethodGet,
+			path:       "/openstack/latest/password",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "clear",
+			method:     http.MethodDelete,
+			path:       "/admin/nodes/node-1/password",
+			stored:     "b2xk",
+			wantStatus: http.StatusNoContent,
+			wantPatch:  "remove",
+		},
+		{
+			name:       "clear unset",
+			method:     http.MethodDelete,
+			path:       "/admin/nodes/node-1/password",
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := node
+			stored.InstanceInfo = map[string]any{"password": tt.stored}
+			for key, value := range node.InstanceInfo {
+				stored.InstanceInfo[key] = value
+			}
+
+			var patch []map[string]any
+			ironic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+				r *http.Request) {
+				if r.URL.Path != "/nodes/node-1" {
+					http.NotFound(w, r)
+					return
+				}
+				if r.Method == http.MethodPatch {
+					if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"uuid":          stored.UUID,
+					"instance_info": stored.InstanceInfo,
+				})
+			}))
+			defer ironic.Close()
+
+			h := createTestHandler()
+			h.AdminToken = "secret"
+			h.Nodes = mock.NewNodeSource(stored)
+			h.Clients.SetIronicClient(&gophercloud.ServiceClient{
+				ProviderClient: &gophercloud.ProviderClient{},
+				Endpoint:       ironic.URL + "/",
+			})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.RemoteAddr = "10.0.0.5:4321"
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.method == http.MethodGet && rr.Body.String() != tt.wantBody {
+				t.Errorf("have body %q, want %q", rr.Body, tt.wantBody)
+			}
+			if tt.wantPatch == "" {
+				if patch != nil {
+					t.Errorf("unexpected patch: %v", patch)
+				}
+				return
+			}
+			if len(patch) != 1 || patch[0]["op"] != tt.wantPatch ||
+				patch[0]["path"] != "/instance_info/password" {
+				t.Fatalf("unexpected patch: %v", patch)
+			}
+			if tt.wantPatch == "add" && patch[0]["value"] != tt.body {
+				t.Errorf("have password %v, want %q", patch[0]["value"], tt.body)
+			}
+		})
+	}
+}
+
+func TestHandler_password_nodeSource(t *testing.T) {
+	node := nodes.Node{
+		UUID:           "node-1",
+		Name:           "win01",
+		ProvisionState: "active",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		source     func(*mock.NodeSource) client.NodeSource
+		wantStatus []int
+	}{
+		{
+			name:       "writable source",
+			source:     func(s *mock.NodeSource) client.NodeSource { return s },
+			wantStatus: []int{http.StatusOK, http.StatusConflict},
+		},
+		{
+			name: "read-only source",
+			source: func(s *mock.NodeSource) client.NodeSource {
+				return client.NewCachedSource(s, time.Minute)
+			},
+			wantStatus: []int{http.StatusNotImplemented},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := mock.NewNodeSource(node)
+			h := NewHandler(WithNodeSource(tt.source(source)))
+
+			for _, want := range tt.wantStatus {
+				req := httptest.NewRequest(http.MethodPost, "/openstack/latest/password",
+					strings.NewReader("c2VjcmV0"))
+				req.RemoteAddr = "10.0.0.5:4321"
+				rr := httptest.NewRecorder()
+				h.Routes().ServeHTTP(rr, req)
+
+				if rr.Code != want {
+					t.Fatalf("have status %d, want %d: %s", rr.Code, want, rr.Body)
+				}
+			}
+			stored, err := source.GetNode(t.Context(), "node-1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := ""
+			if tt.wantStatus[0] == http.StatusOK {
+				want = "c2VjcmV0"
+			}
+			if have := nodePassword(stored); have != want {
+				t.Errorf("have password %q, want %q", have, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/hostkeys"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// hostKeysField is the extra field holding the escrowed SSH host keys of a
+// node. Unlike instance_info, extra survives undeploying the node.
+const hostKeysField = "ssh_host_keys"
+
+// maxPhoneHomeSize bounds the body of phone home requests.
+const maxPhoneHomeSize = 64 << 10
+
+// ErrHostKeyChanged is returned when a node posts a host key of a type
+// escrowed with another public key.
+var ErrHostKeyChanged = errors.New("host key differs from the escrowed one")
+
+// nodeHostKeys returns the escrowed host keys of a node, with private keys
+// still sealed.
+func nodeHostKeys(node *nodes.Node) map[string]hostkeys.Key {
+	escrowed, _ := node.Extra[hostKeysField].(map[string]any)
+	keys := make(map[string]hostkeys.Key, len(escrowed))
+	for keyType, value := range escrowed {
+		fields, _ := value.(map[string]any)
+		public, _ := fields["public"].(string)
+		if public == "" {
+			continue
+		}
+		private, _ := fields["private"].(string)
+		keys[keyType] = hostkeys.Key{Public: public, Private: private}
+	}
+	return keys
+}
+
+// postedHostKeys returns the host keys of a phone home request: the public
+// keys cloud-init posts as pub_key_<type>, and the private keys posted as
+// priv_key_<type>. Private keys are dropped when no sealer is configured.
+func (h *Handler) postedHostKeys(
+	node *nodes.Node,
+	form url.Values,
+) (map[string]hostkeys.Key, error) {
+	keys := make(map[string]hostkeys.Key)
+	for _, keyType := range hostkeys.Types {
+		line := form.Get("pub_key_" + keyType)
+		if line == "" {
+			continue
+		}
+		public, err := hostkeys.ParsePublic(keyType, line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s host key: %w", keyType, err)
+		}
+		key := hostkeys.Key{Public: public}
+
+		if private := form.Get("priv_key_" + keyType); private != "" {
+			if err := hostkeys.ValidatePrivate(private); err != nil {
+				return nil, fmt.Errorf("invalid private %s host key: %w", keyType, err)
+			}
+			if h.HostKeySealer == nil {
+				h.logger().Warn().
+					Str("node_uuid", node.UUID).
+					Str("key_type", keyType).
+					Msg("Dropping private host key posted without an escrow key configured")
+			} else {
+				key.Private = private
+			}
+		}
+		keys[keyType] = key
+	}
+	return keys, nil
+}
+
+// EscrowHostKeys stores the host keys posted by a node in its extra, with
+// their private keys sealed, reporting whether they changed. Keys of types
+// not posted are kept, and the private key of a type is kept when the same
+// public key is posted without it. Escrowed keys are never replaced: posting
+// another public key of an escrowed type fails with ErrHostKeyChanged until
+// the keys are cleared, so whoever holds the IP of the node cannot swap
+// them for their own.
+func (h *Handler) EscrowHostKeys(
+	ctx context.Context,
+	node *nodes.Node,
+	posted map[string]hostkeys.Key,
+) (bool, error) {
+	if len(posted) == 0 {
+		return false, nil
+	}
+
+	// The node may be cached from before its keys were last escrowed.
+	current, err := h.currentNode(ctx, node.UUID)
+	if err != nil {
+		return false, err
+	}
+	escrowed := nodeHostKeys(current)
+	for keyType, key := range posted {
+		if prev, ok := escrowed[keyType]; ok && prev.Public != key.Public {
+			return false, fmt.Errorf("%w: %s", ErrHostKeyChanged, keyType)
+		}
+	}
+	keys := maps.Clone(escrowed)
+	for keyType, key := range posted {
+		sealed, err := h.sealHostKey(node, key, escrowed[keyType])
+		if err != nil {
+			return false, err
+		}
+		key.Private = sealed
+		keys[keyType] = key
+	}
+	if maps.Equal(keys, escrowed) {
+		return false, nil
+	}
+
+	value := make(map[string]any, len(keys))
+	for keyType, key := range keys {
+		fields := map[string]any{"public": key.Public}
+		if key.Private != "" {
+			fields["private"] = key.Private
+		}
+		value[keyType] = fields
+	}
+	return true, h.updateNode(ctx, node, nodes.UpdateOperation{
+		Op:    nodes.AddOp,
+		Path:  "/extra/" + hostKeysField,
+		Value: value,
+	})
+}
+
+// sealHostKey returns the sealed private key of a posted host key: that of
+// the escrowed key when the posted key is the same or has no private key,
+// or else the posted private key sealed anew.
+func (h *Handler) sealHostKey(node *nodes.Node, posted, escrowed hostkeys.Key) (string, error) {
+	if posted.Public != escrowed.Public {
+		escrowed = hostkeys.Key{}
+	}
+	if posted.Private == "" || h.HostKeySealer == nil {
+		return escrowed.Private, nil
+	}
+	if escrowed.Private != "" {
+		private, err := h.HostKeySealer.Open(node.UUID, escrowed.Private)
+		if err == nil && string(private) == posted.Private {
+			return escrowed.Private, nil
+		}
+	}
+	return h.HostKeySealer.Seal(node.UUID, []byte(posted.Private))
+}
+
+// ClearHostKeys removes the escrowed host keys of a node, so it keeps the
+// host keys it generates when next deployed.
+func (h *Handler) ClearHostKeys(ctx context.Context, node *nodes.Node) error {
+	current, err := h.currentNode(ctx, node.UUID)
+	if err != nil {
+		return err
+	}
+	if _, ok := current.Extra[hostKeysField]; !ok {
+		return nil
+	}
+	return h.updateNode(ctx, node, nodes.UpdateOperation{
+		Op:   nodes.RemoveOp,
+		Path: "/extra/" + hostKeysField,
+	})
+}
+
+// addHostKeys adds the escrowed host keys of a node to its vendor data: the
+// public keys under ssh_host_keys, and when private keys are escrowed, a
+// cloud-config installing them under cloud-init, unless the operator's
+// vendor data sets one. Private keys that fail to open are left out.
+func (h *Handler) addHostKeys(node *nodes.Node, data map[string]any) {
+	if !h.HostKeyEscrow {
+		return
+	}
+	keys := nodeHostKeys(node)
+	if len(keys) == 0 {
+		return
+	}
+
+	public := make(map[string]any, len(keys))
+	for keyType, key := range keys {
+		public[keyType] = key.Public
+		if key.Private == "" {
+			continue
+		}
+		if h.HostKeySealer == nil {
+			key.Private = ""
+		} else if private, err := h.HostKeySealer.Open(node.UUID, key.Private); err != nil {
+			h.logger().Warn().
+				Err(err).
+				Str("node_uuid", node.UUID).
+				Str("key_type", keyType).
+				Msg("Leaving out escrowed private host key")
+			key.Private = ""
+		} else {
+			key.Private = string(private)
+		}
+		keys[keyType] = key
+	}
+	data[hostKeysField] = public
+
+	if _, ok := data["cloud-init"]; ok {
+		return
+	}
+	cloudConfig, err := hostkeys.CloudConfig(keys)
+	if err != nil {
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Leaving out escrowed private host keys")
+		return
+	}
+	if cloudConfig != nil {
+		data["cloud-init"] = string(cloudConfig)
+	}
+}
+
+// handlePhoneHome handles POST requests to /phone_home and
+// /phone_home/{instance_id}, posted by the phone_home module of cloud-init
+// once the node booted. When host key escrow is enabled, the host keys it
+// posts are stored in the extra of the node.
+func (h *Handler) handlePhoneHome(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhoneHomeSize)
+	if err := r.ParseForm(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request is too large", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	node, clientIP, ok := h.nodeForRequest(w, r, "phone_home")
+	if !ok {
+		return
+	}
+
+	instanceID := mux.Vars(r)["instance_id"]
+	if instanceID == "" {
+		instanceID = r.PostForm.Get("instance_id")
+	}
+	if instanceID != "" && !strings.EqualFold(instanceID, node.UUID) {
+		h.logger().Warn().
+			Str("client_ip", clientIP).
+			Str("node_uuid", node.UUID).
+			Str("instance_id", instanceID).
+			Msg("Refusing phone home of another instance")
+		http.Error(w, "Instance ID does not match the node", http.StatusConflict)
+		return
+	}
+
+	logger := h.logger().With().
+		Str("client_ip", clientIP).
+		Str("node_uuid", node.UUID).
+		Str("hostname", r.PostForm.Get("hostname")).
+		Logger()
+	if h.HostKeyEscrow {
+		posted, err := h.postedHostKeys(node, r.PostForm)
+		if err != nil {
+			logger.Warn().
+				Err(err).
+				Msg("Refusing invalid host keys")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		changed, err := h.EscrowHostKeys(r.Context(), node, posted)
+		if errors.Is(err, ErrHostKeyChanged) {
+			logger.Warn().
+				Err(err).
+				Msg("Refused to replace escrowed host keys")
+			http.Error(w, "Host keys differ from the escrowed ones", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, ErrNotLeader) {
+			writeNotLeader(w)
+			return
+		}
+		if errors.Is(err, ErrNoNodeWriter) {
+			writeNoNodeWriter(w)
+			return
+		}
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Msg("Failed to escrow host keys")
+			http.Error(w, "Failed to escrow host keys", http.StatusBadGateway)
+			return
+		}
+		if changed {
+			logger.Info().
+				Int("host_keys", len(posted)).
+				Msg("Escrowed host keys")
+		}
+	}
+
+	logger.Info().
+		Msg("Node phoned home")
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleHostKeys handles GET requests to /admin/nodes/{uuid}/ssh_host_keys,
+// returning the escrowed public host keys of the node by type.
+func (h *Handler) handleHostKeys(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	public := make(map[string]string)
+	for keyType, key := range nodeHostKeys(node) {
+		public[keyType] = key.Public
+	}
+	h.writeJSONResponse(w, public)
+}
+
+// handleClearHostKeys handles DELETE requests to
+// /admin/nodes/{uuid}/ssh_host_keys, removing the escrowed host keys of the
+// node.
+func (h *Handler) handleClearHostKeys(w http.ResponseWriter, r *http.Request) {
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	if err := h.ClearHostKeys(r.Context(), node); err != nil {
+		if errors.Is(err, ErrNotLeader) {
+			writeNotLeader(w)
+			return
+		}
+		if errors.Is(err, ErrNoNodeWriter) {
+			writeNoNodeWriter(w)
+			return
+		}
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to clear host keys")
+		http.Error(w, "Failed to clear host keys", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
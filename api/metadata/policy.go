@@ -0,0 +1,177 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/appkins-org/ironic-metadata/pkg/opa"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Policy decisions, as counted in the metrics.
+const (
+	PolicyAllow = "allow"
+	PolicyStrip = "strip"
+	PolicyDeny  = "deny"
+	PolicyError = "error"
+)
+
+// policySubject is the node a response is served for and its endpoint,
+// recorded by nodeForRequest for policyMiddleware.
+type policySubject struct {
+	node     *nodes.Node
+	endpoint string
+}
+
+type policySubjectKey struct{}
+
+// withPolicySubject returns the subject of a request and the request
+// carrying it in its context, reusing the subject of an outer middleware.
+func withPolicySubject(r *http.Request) (*policySubject, *http.Request) {
+	if subject, ok := r.Context().Value(policySubjectKey{}).(*policySubject); ok {
+		return subject, r
+	}
+	subject := &policySubject{}
+	return subject, r.WithContext(context.WithValue(r.Context(), policySubjectKey{}, subject))
+}
+
+// setPolicySubject records the node and endpoint of a request in its
+// context, when policyMiddleware, hookMiddleware or eventMiddleware is
+// handling it.
+func setPolicySubject(ctx context.Context, node *nodes.Node, endpoint string) {
+	if subject, ok := ctx.Value(policySubjectKey{}).(*policySubject); ok {
+		subject.node = node
+		subject.endpoint = endpoint
+	}
+}
+
+// policyMiddleware asks the Policy for a decision on each successful
+// response served for a node, before it is sent: the response is denied
+// with 403 Forbidden, served with the fields the decision strips removed,
+// or served as is. Responses are buffered when a Policy is set. Failing to
+// get a decision answers 503 Service Unavailable, so an unreachable OPA
+// never leaks what its policy would have denied.
+func (h *Handler) policyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Policy == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		subject, r := withPolicySubject(r)
+		bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		body := bw.body.Bytes()
+		if subject.node != nil && bw.status == http.StatusOK {
+			var ok bool
+			if body, ok = h.applyPolicy(w, r, subject, body); !ok {
+				return
+			}
+		}
+		w.WriteHeader(bw.status)
+		if _, err := w.Write(body); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("path", r.URL.Path).
+				Msg("Failed to write response")
+		}
+	})
+}
+
+// applyPolicy returns the body of a response as the decision of the Policy
+// has it served. When it is not served an error response is written and
+// false is returned.
+func (h *Handler) applyPolicy(
+	w http.ResponseWriter,
+	r *http.Request,
+	subject *policySubject,
+	body []byte,
+) ([]byte, bool) {
+	node := subject.node
+	clientIP, _ := getClientIPFromContext(r)
+	input := &opa.Input{
+		ClientIP: clientIP,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Endpoint: subject.endpoint,
+		Node: &opa.Node{
+			UUID:           node.UUID,
+			Name:           node.Name,
+			ProvisionState: node.ProvisionState,
+			Maintenance:    node.Maintenance,
+			ProjectID:      h.projectID(node),
+			Owner:          node.Owner,
+			Lessee:         node.Lessee,
+			ResourceClass:  node.ResourceClass,
+			Traits:         node.Traits,
+			Extra:          node.Extra,
+		},
+		ContentType: w.Header().Get("Content-Type"),
+		Document:    string(body),
+	}
+	// Numbers are kept as written, so stripping a document changes nothing
+	// but the stripped fields.
+	var document any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if json.Valid(body) && decoder.Decode(&document) == nil {
+		input.Document = document
+	}
+
+	logger := h.logger().With().
+		Str("client_ip", clientIP).
+		Str("node_uuid", node.UUID).
+		Str("endpoint", subject.endpoint).
+		Logger()
+	decision, err := h.Policy.Decide(r.Context(), input)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msg("Failed to get policy decision")
+		h.Metrics.observePolicyDecision(PolicyError)
+		http.Error(w, "Policy decision unavailable", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if !decision.Allow {
+		logger.Warn().
+			Str("reason", decision.Reason).
+			Msg("Policy denied metadata")
+		h.Metrics.observePolicyDecision(PolicyDeny)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return nil, false
+	}
+	if document == nil || !opa.Strip(document, decision.Strip) {
+		h.Metrics.observePolicyDecision(PolicyAllow)
+		return body, true
+	}
+
+	stripped, err := json.Marshal(document)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msg("Failed to encode stripped document")
+		h.Metrics.observePolicyDecision(PolicyError)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	stripped = append(stripped, '\n')
+	logger.Debug().
+		Strs("strip", decision.Strip).
+		Msg("Policy stripped metadata")
+	h.Metrics.observePolicyDecision(PolicyStrip)
+
+	// The validators and signature of the handler were derived from the
+	// whole document.
+	w.Header().Del("ETag")
+	w.Header().Del("Content-Length")
+	if err := h.resign(w, stripped); err != nil {
+		logger.Error().
+			Err(err).
+			Msg("Failed to sign response")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return stripped, true
+}
@@ -0,0 +1,142 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/appkins-org/ironic-metadata/pkg/opa"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_policy(t *testing.T) {
+	// The policy strips the hostname of meta_data.json, denies the user
+	// data of nodes of tenant-b and fails on network_data.json.
+	var inputs []opa.Input
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input opa.Input `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		inputs = append(inputs, body.Input)
+		switch {
+		case body.Input.Endpoint == "meta_data.json":
+			_, _ = w.Write([]byte(`{"result": {"allow": true, "strip": ["hostname"]}}`))
+		case body.Input.Endpoint == "user_data" && body.Input.Node.ProjectID == "tenant-b":
+			_, _ = w.Write([]byte(`{"result": {"allow": false, "reason": "tenant-b"}}`))
+		case body.Input.Endpoint == "network_data.json":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte(`{"result": true}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	policy, err := opa.New(opa.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		lessee     string
+		wantStatus int
+		wantBody   string
+		wantInput  bool
+		wantMetric string
+	}{
+		{
+			name:       "strip",
+			path:       "/openstack/latest/meta_data.json",
+			wantStatus: http.StatusOK,
+			wantBody:   `"uuid":"node-1"`,
+			wantInput:  true,
+			wantMetric: `ironic_metadata_policy_decisions_total{decision="strip"} 1`,
+		},
+		{
+			name:       "allow",
+			path:       "/openstack/latest/user_data",
+			lessee:     "tenant-a",
+			wantStatus: http.StatusOK,
+			wantBody:   "#cloud-config",
+			wantInput:  true,
+			wantMetric: `ironic_metadata_policy_decisions_total{decision="allow"} 1`,
+		},
+		{
+			name:       "deny",
+			path:       "/openstack/latest/user_data",
+			lessee:     "tenant-b",
+			wantStatus: http.StatusForbidden,
+			wantInput:  true,
+			wantMetric: `ironic_metadata_policy_decisions_total{decision="deny"} 1`,
+		},
+		{
+			name:       "error",
+			path:       "/openstack/latest/network_data.json",
+			wantStatus: http.StatusServiceUnavailable,
+			wantInput:  true,
+			wantMetric: `ironic_metadata_policy_decisions_total{decision="error"} 1`,
+		},
+		{
+			name:       "no node",
+			path:       "/openstack/latest",
+			wantStatus: http.StatusOK,
+			wantBody:   "meta_data.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs = nil
+			node := nodes.Node{
+				UUID:           "node-1",
+				Name:           "web01",
+				ProvisionState: "active",
+				Lessee:         tt.lessee,
+				InstanceInfo: map[string]any{
+					"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+					"user_data": "#cloud-config\n",
+				},
+			}
+			registry := metrics.NewRegistry()
+			h := NewHandler(WithNodeSource(mock.NewNodeSource(node)), WithPolicy(policy))
+			h.EnableMetrics(registry)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("expected %q in %s", tt.wantBody, rr.Body)
+			}
+			if strings.Contains(rr.Body.String(), `"hostname"`) {
+				t.Errorf("have hostname in %s, want it stripped", rr.Body)
+			}
+			if have := len(inputs) > 0; have != tt.wantInput {
+				t.Fatalf("have policy queried %v, want %v", have, tt.wantInput)
+			}
+			if tt.wantInput && (inputs[0].ClientIP != "10.0.0.5" ||
+				inputs[0].Node.UUID != "node-1") {
+				t.Errorf("have input %+v, want the client and node", inputs[0])
+			}
+
+			var b strings.Builder
+			if _, err := registry.WriteTo(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantMetric != "" && !strings.Contains(b.String(), tt.wantMetric+"\n") {
+				t.Errorf("expected %q in\n%s", tt.wantMetric, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package metadata
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Preview is the response of /admin/nodes/{uuid}/preview: the documents a
+// node would currently receive, rendered but not served.
+type Preview struct {
+	NodeUUID string `json:"node_uuid"`
+	NodeName string `json:"node_name,omitempty"`
+
+	// ClientIP is the address the documents were rendered for, which the
+	// EC2 local-ipv4 and identity document report.
+	ClientIP string `json:"client_ip,omitempty"`
+
+	MetaData    any                   `json:"meta_data,omitempty"`
+	NetworkData *metadata.NetworkData `json:"network_data,omitempty"`
+
+	// UserData is null when the node has none. User data that is not
+	// UTF-8 is base64 encoded, with UserDataEncoding set to base64.
+	UserData         *string `json:"user_data"`
+	UserDataEncoding string  `json:"user_data_encoding,omitempty"`
+
+	VendorData  map[string]any `json:"vendor_data,omitempty"`
+	VendorData2 map[string]any `json:"vendor_data2,omitempty"`
+
+	// EC2 maps the paths of the EC2 tree under /latest/ to their content.
+	EC2 map[string]any `json:"ec2"`
+
+	// Violations lists the schema violations of each document, whatever
+	// RESPONSE_VALIDATION is set to.
+	Violations map[string][]string `json:"violations,omitempty"`
+
+	// Errors holds the documents that failed to render, by name.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// handlePreview handles GET requests to /admin/nodes/{uuid}/preview,
+// returning every document the node would currently receive so operators
+// can review them before powering it on. The ip query parameter sets the
+// client IP the documents are rendered for, by default the first address of
+// the node.
+func (h *Handler) handlePreview(w http.ResponseWriter, r *http.Request) {
+	clientIP := r.URL.Query().Get("ip")
+	if clientIP != "" {
+		if _, err := netip.ParseAddr(clientIP); err != nil {
+			http.Error(w, "Invalid ip", http.StatusBadRequest)
+			return
+		}
+	}
+
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+	if clientIP == "" {
+		clientIP = h.defaultClientIP(node)
+	}
+
+	h.writeJSONResponse(w, h.preview(r.Context(), node, clientIP))
+}
+
+// preview renders the documents of a node for clientIP.
+func (h *Handler) preview(ctx context.Context, node *nodes.Node, clientIP string) *Preview {
+	preview := &Preview{
+		NodeUUID: node.UUID,
+		NodeName: node.Name,
+		ClientIP: clientIP,
+		EC2:      make(map[string]any),
+	}
+	fail := func(document string, err error) {
+		if preview.Errors == nil {
+			preview.Errors = make(map[string]string)
+		}
+		preview.Errors[document] = err.Error()
+	}
+	validate := func(document string, data any, check func([]byte) []metadata.Violation) {
+		body, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		for _, violation := range check(body) {
+			if preview.Violations == nil {
+				preview.Violations = make(map[string][]string)
+			}
+			preview.Violations[document] = append(preview.Violations[document],
+				violation.Error())
+		}
+	}
+
+	metaData := h.buildMetaData(node)
+	if document, err := h.metaDataDocument(node, metaData); err != nil {
+		fail("meta_data", err)
+	} else {
+		preview.MetaData = document
+		validate("meta_data", document, metadata.ValidateMetaData)
+	}
+
+	preview.NetworkData = h.buildNetworkData(node)
+	validate("network_data", preview.NetworkData, metadata.ValidateNetworkData)
+
+	if userData, err := h.readUserData(ctx, node); err != nil {
+		fail("user_data", err)
+	} else if len(userData) > 0 {
+		s := string(userData)
+		if !utf8.Valid(userData) {
+			s = base64.StdEncoding.EncodeToString(userData)
+			preview.UserDataEncoding = "base64"
+		}
+		preview.UserData = &s
+	}
+
+	var err error
+	if preview.VendorData, err = h.buildVendorData(node); err != nil {
+		fail("vendor_data", err)
+	}
+	if preview.VendorData2, err = h.buildVendorData2(ctx, node); err != nil {
+		fail("vendor_data2", err)
+	}
+
+	preview.EC2["meta-data/instance-id"] = node.UUID
+	preview.EC2["meta-data/hostname"] = getNodeHostname(node)
+	if clientIP != "" {
+		preview.EC2["meta-data/local-ipv4"] = clientIP
+	}
+	for i, key := range sortedPublicKeys(metaData.PublicKeys) {
+		preview.EC2["meta-data/public-keys/"+strconv.Itoa(i)+"/openssh-key"] = key
+	}
+	for key, value := range h.nodeTags(node) {
+		preview.EC2["meta-data/tags/instance/"+key] = value
+	}
+	preview.EC2["dynamic/instance-identity/document"] = h.buildIdentityDocument(node, clientIP)
+	return preview
+}
+
+// readUserData renders the user data of a node in memory.
+func (h *Handler) readUserData(ctx context.Context, node *nodes.Node) ([]byte, error) {
+	body, err := h.openUserData(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	if body.size == 0 {
+		return nil, nil
+	}
+	return io.ReadAll(body)
+}
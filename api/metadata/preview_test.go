@@ -0,0 +1,139 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_preview(t *testing.T) {
+	const key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5QQ== alice@example.com"
+	source := mock.NewNodeSource(
+		nodes.Node{
+			UUID: "node-1",
+			Name: "web01",
+			InstanceInfo: map[string]any{
+				"fixed_ips":     []any{map[string]any{"ip_address": "10.0.0.5"}},
+				"user_data":     "#cloud-config\nhostname: web01\n",
+				publicKeysField: map[string]any{"alice": key},
+			},
+			Extra: map[string]any{"tags": map[string]any{"role": "web"}},
+		},
+		nodes.Node{
+			UUID:         "node-2",
+			Name:         "db01",
+			InstanceInfo: map[string]any{"user_data": "\xff\xfe"},
+		},
+		nodes.Node{UUID: "node-3", Name: "bare"},
+	)
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	h.Nodes = source
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantIP       string
+		wantUserData string
+		wantEncoding string
+	}{
+		{
+			name:         "node",
+			path:         "/admin/nodes/web01/preview",
+			wantStatus:   http.StatusOK,
+			wantIP:       "10.0.0.5",
+			wantUserData: "#cloud-config\nhostname: web01\n",
+		},
+		{
+			name:         "client ip",
+			path:         "/admin/nodes/node-1/preview?ip=192.0.2.10",
+			wantStatus:   http.StatusOK,
+			wantIP:       "192.0.2.10",
+			wantUserData: "#cloud-config\nhostname: web01\n",
+		},
+		{
+			name:         "binary user data",
+			path:         "/admin/nodes/node-2/preview",
+			wantStatus:   http.StatusOK,
+			wantUserData: base64.StdEncoding.EncodeToString([]byte("\xff\xfe")),
+			wantEncoding: "base64",
+		},
+		{name: "no user data", path: "/admin/nodes/node-3/preview", wantStatus: http.StatusOK},
+		{name: "invalid ip", path: "/admin/nodes/node-1/preview?ip=web01",
+			wantStatus: http.StatusBadRequest},
+		{name: "unknown node", path: "/admin/nodes/node-9/preview",
+			wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var have Preview
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have.ClientIP != tt.wantIP {
+				t.Errorf("have client IP %q, want %q", have.ClientIP, tt.wantIP)
+			}
+			if tt.wantUserData == "" {
+				if have.UserData != nil {
+					t.Errorf("have user data %q, want none", *have.UserData)
+				}
+			} else if have.UserData == nil || *have.UserData != tt.wantUserData {
+				t.Errorf("have user data %v, want %q", have.UserData, tt.wantUserData)
+			}
+			if have.UserDataEncoding != tt.wantEncoding {
+				t.Errorf("have encoding %q, want %q", have.UserDataEncoding, tt.wantEncoding)
+			}
+			if have.MetaData == nil || have.NetworkData == nil {
+				t.Error("expected meta_data and network_data")
+			}
+			if have.EC2["meta-data/instance-id"] != have.NodeUUID {
+				t.Errorf("have EC2 instance-id %v, want %s",
+					have.EC2["meta-data/instance-id"], have.NodeUUID)
+			}
+			if len(have.Errors) > 0 {
+				t.Errorf("have errors %v, want none", have.Errors)
+			}
+		})
+	}
+
+	// The EC2 tree holds the keys and tags of the node.
+	req := httptest.NewRequest(http.MethodGet, "/admin/nodes/node-1/preview", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rr, req)
+	var have Preview
+	if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for path, want := range map[string]string{
+		"meta-data/hostname":                  "web01",
+		"meta-data/local-ipv4":                "10.0.0.5",
+		"meta-data/public-keys/0/openssh-key": key,
+		"meta-data/tags/instance/role":        "web",
+	} {
+		if have.EC2[path] != want {
+			t.Errorf("have EC2 %s %v, want %q", path, have.EC2[path], want)
+		}
+	}
+	if _, ok := have.EC2["dynamic/instance-identity/document"].(map[string]any); !ok {
+		t.Error("expected an identity document")
+	}
+}
@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"slices"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/profiles"
+	"github.com/appkins-org/ironic-metadata/pkg/userdata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// nodeProfiles returns the operator profiles matching a node, in the order
+// they are defined.
+func (h *Handler) nodeProfiles(node *nodes.Node) []*profiles.Profile {
+	if len(h.Profiles) == 0 {
+		return nil
+	}
+	return profiles.Select(h.Profiles, node)
+}
+
+// addProfileMeta sets the meta defaults of the profiles of a node, later
+// profiles overriding earlier ones. Redacted keys are left out.
+func (h *Handler) addProfileMeta(node *nodes.Node, metaData *metadata.MetaData) {
+	for _, profile := range h.nodeProfiles(node) {
+		for key, value := range profile.Meta {
+			if !h.redactedKey(key) {
+				metaData.Meta[key] = value
+			}
+		}
+	}
+}
+
+// profileUserData returns the user data of the profiles of a node as parts
+// named after the profiles.
+func (h *Handler) profileUserData(node *nodes.Node) []userdata.Part {
+	var parts []userdata.Part
+	for _, profile := range h.nodeProfiles(node) {
+		if profile.UserData == "" {
+			continue
+		}
+		parts = append(parts, userdata.Part{
+			Filename: "profile-" + profile.Name,
+			Content:  []byte(profile.UserData),
+		})
+	}
+	return parts
+}
+
+// addProfileVendorData merges the vendor data of the profiles of a node
+// into the vendor data document name.
+func (h *Handler) addProfileVendorData(node *nodes.Node, name string, data map[string]any) {
+	for _, profile := range h.nodeProfiles(node) {
+		src := profile.VendorData
+		if name == "vendor_data2.json" {
+			src = profile.VendorData2
+		}
+		// Merging shares the objects of src with data, which later layers
+		// merge into, so the profile is copied first.
+		if src != nil {
+			mergeVendorData(data, cloneJSON(src).(map[string]any))
+		}
+	}
+}
+
+// cloneJSON returns a deep copy of a decoded JSON value.
+func cloneJSON(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(val))
+		for key, item := range val {
+			m[key] = cloneJSON(item)
+		}
+		return m
+	case []any:
+		s := make([]any, len(val))
+		for i, item := range val {
+			s[i] = cloneJSON(item)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// applyNetworkDefaults returns the network data of a node with the network
+// defaults of its profiles applied: their MTU set on links without one, and
+// their DNS nameservers added as dns services when there are none. The
+// network data is copied rather than modified, as it may be cached.
+func (h *Handler) applyNetworkDefaults(
+	node *nodes.Node,
+	networkData *metadata.NetworkData,
+) *metadata.NetworkData {
+	var mtu int
+	var nameservers []string
+	for _, profile := range h.nodeProfiles(node) {
+		if profile.Network.MTU != 0 {
+			mtu = profile.Network.MTU
+		}
+		if len(profile.Network.DNSNameservers) > 0 {
+			nameservers = profile.Network.DNSNameservers
+		}
+	}
+	if mtu == 0 && len(nameservers) == 0 {
+		return networkData
+	}
+
+	withDefaults := *networkData
+	withDefaults.Links = slices.Clone(networkData.Links)
+	for i := range withDefaults.Links {
+		if withDefaults.Links[i].MTU == 0 {
+			withDefaults.Links[i].MTU = mtu
+		}
+	}
+	hasDNS := slices.ContainsFunc(networkData.Services, func(s metadata.Service) bool {
+		return s.Type == "dns"
+	})
+	if !hasDNS && len(nameservers) > 0 {
+		withDefaults.Services = slices.Clone(networkData.Services)
+		for _, nameserver := range nameservers {
+			withDefaults.Services = append(withDefaults.Services, metadata.Service{
+				Type:    "dns",
+				Address: nameserver,
+			})
+		}
+	}
+	return &withDefaults
+}
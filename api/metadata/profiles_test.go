@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"bytes"
+	"maps"
+	"reflect"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/profiles"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func testProfiles(t *testing.T) []profiles.Profile {
+	t.Helper()
+	p, err := profiles.Parse([]byte(`
+profiles:
+  - name: gpu
+    match:
+      resource_class: baremetal-gpu
+    meta:
+      role: gpu
+      rack: unknown
+    user_data: |
+      #!/bin/sh
+      echo gpu
+    vendor_data:
+      gpu:
+        driver: "550"
+    network:
+      mtu: 9000
+      dns_nameservers: [10.0.0.2, 10.0.0.3]
+  - name: raid
+    match:
+      deploy_template: CUSTOM_RAID1
+    meta:
+      storage: raid1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestHandler_profiles(t *testing.T) {
+	h := createTestHandler()
+	h.Profiles = testProfiles(t)
+	node := &nodes.Node{
+		UUID:          "node-1",
+		ResourceClass: "baremetal-gpu",
+		Properties:    map[string]any{"rack": "r12"},
+		InstanceInfo: map[string]any{
+			"traits":    []any{"CUSTOM_RAID1"},
+			"user_data": "#cloud-config\nhostname: web01\n",
+		},
+	}
+
+	meta := h.buildMetaData(node).Meta
+	wantMeta := map[string]string{"role": "gpu", "rack": "r12", "storage": "raid1"}
+	if !maps.Equal(meta, wantMeta) {
+		t.Errorf("have meta %v, want %v", meta, wantMeta)
+	}
+
+	userData, err := h.renderUserData(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"profile-gpu", "echo gpu", "hostname: web01"} {
+		if !bytes.Contains(userData, []byte(want)) {
+			t.Errorf("expected user data to contain %q", want)
+		}
+	}
+
+	vendorData, err := h.buildVendorData(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gpu, _ := vendorData["gpu"].(map[string]any)
+	if gpu["driver"] != "550" {
+		t.Errorf("have vendor data %v, want the gpu driver", vendorData)
+	}
+	gpu["driver"] = "changed"
+	if h.Profiles[0].VendorData["gpu"].(map[string]any)["driver"] != "550" {
+		t.Error("vendor data of the profile was modified")
+	}
+
+	networkData := h.buildNetworkData(node)
+	if networkData.Links[0].MTU != 1500 {
+		t.Errorf("have mtu %d, want the fallback link kept at 1500", networkData.Links[0].MTU)
+	}
+	wantServices := []metadata.Service{
+		{Type: "dns", Address: "10.0.0.2"},
+		{Type: "dns", Address: "10.0.0.3"},
+	}
+	if !reflect.DeepEqual(networkData.Services, wantServices) {
+		t.Errorf("have services %v, want %v", networkData.Services, wantServices)
+	}
+
+	other := &nodes.Node{UUID: "node-2", ResourceClass: "baremetal-cpu"}
+	if meta := h.buildMetaData(other).Meta; len(meta) != 0 {
+		t.Errorf("have meta %v for a node matching no profile", meta)
+	}
+}
+
+func TestHandler_applyNetworkDefaults(t *testing.T) {
+	h := createTestHandler()
+	h.Profiles = testProfiles(t)
+	node := &nodes.Node{UUID: "node-1", ResourceClass: "baremetal-gpu"}
+
+	have := &metadata.NetworkData{
+		Links: []metadata.Link{
+			{ID: "eth0", Type: "phy"},
+			{ID: "eth1", Type: "phy", MTU: 1500},
+		},
+		Services: []metadata.Service{{Type: "dns", Address: "192.0.2.53"}},
+	}
+	got := h.applyNetworkDefaults(node, have)
+	if got.Links[0].MTU != 9000 || got.Links[1].MTU != 1500 {
+		t.Errorf("have links %v, want the mtu set on eth0 only", got.Links)
+	}
+	if len(got.Services) != 1 {
+		t.Errorf("have services %v, want the existing nameserver kept", got.Services)
+	}
+	if have.Links[0].MTU != 0 {
+		t.Error("network data was modified")
+	}
+}
@@ -0,0 +1,80 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// maxProjectIDLength is the longest owner or lessee Ironic stores.
+const maxProjectIDLength = 255
+
+// ValidateProjectID checks a project ID, such as the owner or lessee of a
+// node, which must be a non-empty string of printable characters without
+// whitespace that fits Ironic's owner field.
+func ValidateProjectID(id string) error {
+	if id == "" {
+		return errors.New("project ID is empty")
+	}
+	if len(id) > maxProjectIDLength {
+		return fmt.Errorf("project ID is longer than %d characters", maxProjectIDLength)
+	}
+	if i := strings.IndexFunc(id, func(r rune) bool {
+		return unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}); i >= 0 {
+		return fmt.Errorf("project ID %q contains whitespace or control characters", id)
+	}
+	return nil
+}
+
+// projectID returns the project a node is deployed for: its lessee, the
+// project leasing it, or else its owner, or else DefaultProjectID. Values
+// that are not valid project IDs are skipped.
+func (h *Handler) projectID(node *nodes.Node) string {
+	for _, candidate := range []struct {
+		field, id string
+	}{
+		{"lessee", node.Lessee},
+		{"owner", node.Owner},
+	} {
+		if candidate.id == "" {
+			continue
+		}
+		if err := ValidateProjectID(candidate.id); err != nil {
+			h.logger().Debug().
+				Err(err).
+				Str("node_uuid", node.UUID).
+				Str("field", candidate.field).
+				Msg("Skipping invalid project ID")
+			continue
+		}
+		return candidate.id
+	}
+	return h.DefaultProjectID
+}
+
+// ParseProjects parses a comma separated list of project IDs.
+func ParseProjects(spec string) (map[string]bool, error) {
+	projects := make(map[string]bool)
+	for _, id := range strings.Split(spec, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if err := ValidateProjectID(id); err != nil {
+			return nil, err
+		}
+		projects[id] = true
+	}
+	return projects, nil
+}
+
+// projectServed reports whether the metadata of a node is served given the
+// project it is deployed for. Every project is served when ServedProjects
+// is nil.
+func (h *Handler) projectServed(node *nodes.Node) bool {
+	return h.ServedProjects == nil || h.ServedProjects[h.projectID(node)]
+}
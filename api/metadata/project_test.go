@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_projectID(t *testing.T) {
+	tests := []struct {
+		name      string
+		node      nodes.Node
+		defaultID string
+		want      string
+	}{
+		{name: "lessee", node: nodes.Node{Lessee: "tenant-a", Owner: "infra"}, want: "tenant-a"},
+		{name: "owner", node: nodes.Node{Owner: "infra"}, want: "infra"},
+		{name: "default", defaultID: "standalone", want: "standalone"},
+		{name: "none", want: ""},
+		{
+			name:      "invalid lessee",
+			node:      nodes.Node{Lessee: "tenant a", Owner: "infra"},
+			defaultID: "standalone",
+			want:      "infra",
+		},
+		{
+			name:      "invalid owner",
+			node:      nodes.Node{Owner: "infra\n"},
+			defaultID: "standalone",
+			want:      "standalone",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.DefaultProjectID = tt.defaultID
+			if have := h.projectID(&tt.node); have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProjectID(t *testing.T) {
+	tests := []struct {
+		have    string
+		wantErr bool
+	}{
+		{have: "8b7c3a1e2f8d4f0b9d1c0e5a6b7c8d9e"},
+		{have: "tenant-a"},
+		{have: "", wantErr: true},
+		{have: "tenant a", wantErr: true},
+		{have: "tenant\x00", wantErr: true},
+		{have: strings.Repeat("a", 256), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateProjectID(tt.have); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateProjectID(%q): have error %v, want error %v", tt.have, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseProjects(t *testing.T) {
+	tests := []struct {
+		have    string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{have: "", want: map[string]bool{}},
+		{have: "tenant-a", want: map[string]bool{"tenant-a": true}},
+		{
+			have: " tenant-a, tenant-b ,",
+			want: map[string]bool{"tenant-a": true, "tenant-b": true},
+		},
+		{have: "tenant a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.have, func(t *testing.T) {
+			have, err := ParseProjects(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
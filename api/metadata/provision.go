@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// ProvisionStates lists the provision states of Ironic nodes.
+var ProvisionStates = []string{
+	string(nodes.Enroll), string(nodes.Verifying), string(nodes.Manageable),
+	string(nodes.Available), string(nodes.Active), string(nodes.DeployWait),
+	string(nodes.Deploying), string(nodes.DeployFail), string(nodes.DeployDone),
+	string(nodes.DeployHold), string(nodes.Deleting), string(nodes.Deleted),
+	string(nodes.Cleaning), string(nodes.CleanWait), string(nodes.CleanFail),
+	string(nodes.CleanHold), string(nodes.Error), string(nodes.Rebuild),
+	string(nodes.Inspecting), string(nodes.InspectFail), string(nodes.InspectWait),
+	string(nodes.Adopting), string(nodes.AdoptFail), string(nodes.Rescue),
+	string(nodes.RescueFail), string(nodes.Rescuing), string(nodes.UnrescueFail),
+	string(nodes.RescueWait), string(nodes.Unrescuing), string(nodes.Servicing),
+	string(nodes.ServiceWait), string(nodes.ServiceFail), string(nodes.ServiceHold),
+}
+
+// DefaultServedProvisionStates are the provision states of the nodes served
+// when ServedProvisionStates is nil: those of nodes being deployed, running
+// an instance, or being rescued or serviced while running one.
+var DefaultServedProvisionStates = []string{
+	string(nodes.Deploying), string(nodes.DeployWait), string(nodes.DeployHold),
+	string(nodes.Active), string(nodes.Rebuild),
+	string(nodes.Rescuing), string(nodes.RescueWait), string(nodes.Rescue),
+	string(nodes.Unrescuing),
+	string(nodes.Servicing), string(nodes.ServiceWait), string(nodes.ServiceHold),
+}
+
+// ParseProvisionStates parses a comma separated list of provision states.
+func ParseProvisionStates(spec string) (map[string]bool, error) {
+	states := make(map[string]bool)
+	for _, state := range strings.Split(spec, ",") {
+		state = strings.ToLower(strings.TrimSpace(state))
+		if state == "" {
+			continue
+		}
+		if !slices.Contains(ProvisionStates, state) {
+			return nil, fmt.Errorf("unknown provision state %q, want one of %s",
+				state, strings.Join(ProvisionStates, ", "))
+		}
+		states[state] = true
+	}
+	return states, nil
+}
+
+// provisionStateServed reports whether nodes in a provision state are
+// served. Nodes without one, such as those of sources not reporting it, are.
+func (h *Handler) provisionStateServed(state string) bool {
+	if state == "" {
+		return true
+	}
+	if h.ServedProvisionStates == nil {
+		return slices.Contains(DefaultServedProvisionStates, state)
+	}
+	return h.ServedProvisionStates[state]
+}
+
+// serveNode checks whether the metadata of a node is served given its
+// provision state, maintenance flag and project. When it is not an error
+// response is written and false is returned: 410 Gone for the provision
+// states of GoneProvisionStates and for nodes expired with ExpiryAll, 503
+// Service Unavailable for nodes in maintenance when RejectMaintenance is
+// set, and 404 Not Found otherwise, including for nodes of projects outside
+// ServedProjects.
+func (h *Handler) serveNode(w http.ResponseWriter, node *nodes.Node, endpoint string) bool {
+	reject := func(status int, reason string) bool {
+		h.logger().Warn().
+			Str("node_uuid", node.UUID).
+			Str("provision_state", node.ProvisionState).
+			Bool("maintenance", node.Maintenance).
+			Str("project_id", h.projectID(node)).
+			Str("endpoint", endpoint).
+			Msg(reason)
+		http.Error(w, http.StatusText(status), status)
+		return false
+	}
+
+	if h.GoneProvisionStates[node.ProvisionState] {
+		return reject(http.StatusGone, "Refusing metadata of node in a gone provision state")
+	}
+	if !h.provisionStateServed(node.ProvisionState) {
+		return reject(http.StatusNotFound,
+			"Refusing metadata of node in an unserved provision state")
+	}
+	if h.ActiveExpiryScope == ExpiryAll && h.activeExpired(node) {
+		return reject(http.StatusGone, "Refusing metadata of node active for longer than the expiry")
+	}
+	if h.RejectMaintenance && node.Maintenance {
+		return reject(http.StatusServiceUnavailable, "Refusing metadata of node in maintenance")
+	}
+	if !h.projectServed(node) {
+		h.Metrics.observeProjectRefusal(endpoint)
+		return reject(http.StatusNotFound, "Refusing metadata of node of an unserved project")
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestParseProvisionStates(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", have: ""},
+		{
+			name: "states",
+			have: "deleting, Clean Wait,cleaning",
+			want: []string{"deleting", "clean wait", "cleaning"},
+		},
+		{name: "unknown", have: "active,cleaned", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := ParseProvisionStates(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(have) != len(tt.want) {
+				t.Errorf("have states %v, want %v", have, tt.want)
+			}
+			for _, state := range tt.want {
+				if !have[state] {
+					t.Errorf("have states %v, want %q", have, state)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_serveNode(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       string
+		maintenance bool
+		lessee      string
+		opts        []Option
+		want        int
+	}{
+		{name: "active", state: "active", want: http.StatusOK},
+		{name: "deploying", state: "wait call-back", want: http.StatusOK},
+		{name: "no provision state", want: http.StatusOK},
+		{name: "cleaning", state: "cleaning", want: http.StatusNotFound},
+		{name: "available", state: "available", want: http.StatusNotFound},
+		{
+			name:  "gone",
+			state: "cleaning",
+			opts:  []Option{WithGoneProvisionStates("cleaning", "deleting")},
+			want:  http.StatusGone,
+		},
+		{
+			name:  "configured states",
+			state: "available",
+			opts:  []Option{WithServedProvisionStates("active", "available")},
+			want:  http.StatusOK,
+		},
+		{
+			name:  "configured states without active",
+			state: "active",
+			opts:  []Option{WithServedProvisionStates("deploying")},
+			want:  http.StatusNotFound,
+		},
+		{name: "maintenance", state: "active", maintenance: true, want: http.StatusOK},
+		{
+			name:        "rejected maintenance",
+			state:       "active",
+			maintenance: true,
+			opts:        []Option{WithRejectMaintenance(true)},
+			want:        http.StatusServiceUnavailable,
+		},
+		{
+			name:   "served project",
+			state:  "active",
+			lessee: "tenant-a",
+			opts:   []Option{WithServedProjects("tenant-a")},
+			want:   http.StatusOK,
+		},
+		{
+			name:   "unserved project",
+			state:  "active",
+			lessee: "tenant-b",
+			opts:   []Option{WithServedProjects("tenant-a")},
+			want:   http.StatusNotFound,
+		},
+		{
+			name:  "no project",
+			state: "active",
+			opts:  []Option{WithServedProjects("tenant-a")},
+			want:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := nodes.Node{
+				UUID:           "node-1",
+				Name:           "web01",
+				ProvisionState: tt.state,
+				Maintenance:    tt.maintenance,
+				Lessee:         tt.lessee,
+			}
+			resolver := &mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": &node}}
+			opts := append([]Option{
+				WithNodeSource(mock.NewNodeSource(node)),
+				WithResolvers(resolver),
+			}, tt.opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json", nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			NewHandler(opts...).Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("have status %d, want %d: %s", rr.Code, tt.want, rr.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies parses a comma separated list of the addresses or
+// CIDR networks of trusted reverse proxies, such as
+// 10.0.0.0/24,192.168.1.10.
+func ParseTrustedProxies(spec string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: want an address or CIDR network",
+				entry)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// trustedProxy reports whether ip is the address of a trusted proxy.
+func (h *Handler) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range h.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the client address of an X-Forwarded-For header
+// appended to by trusted proxies: the last address not of a trusted proxy,
+// as earlier addresses are set by the client and can be forged, or the
+// first address when every proxy is trusted.
+func (h *Handler) forwardedFor(xff string) string {
+	ips := strings.Split(xff, ",")
+	for i := len(ips) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(ips[i])
+		if !h.trustedProxy(ip) {
+			return ip
+		}
+	}
+	return strings.TrimSpace(ips[0])
+}
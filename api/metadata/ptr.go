@@ -0,0 +1,117 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// DefaultReverseDNSTimeout bounds the reverse DNS lookups of the ptr
+// resolver unless ReverseDNS.Timeout is set.
+const DefaultReverseDNSTimeout = time.Second
+
+// ReverseDNS configures the ptr resolver, which looks up the hostnames of a
+// client IP in reverse DNS and matches them to node names. It suits
+// provisioning networks whose DHCP server registers hostnames with dynamic
+// DNS.
+type ReverseDNS struct {
+	// Suffixes are stripped from hostnames before matching, such as
+	// "prov.example.com" to match web01.prov.example.com to the node
+	// web01. Hostnames are also matched whole.
+	Suffixes []string
+
+	// Timeout bounds each lookup, DefaultReverseDNSTimeout when zero.
+	Timeout time.Duration
+
+	// LookupAddr returns the hostnames of an IP address,
+	// net.DefaultResolver.LookupAddr when nil.
+	LookupAddr func(ctx context.Context, addr string) ([]string, error)
+}
+
+// names returns the node names the hostnames of addr may match, in lower
+// case.
+func (r *ReverseDNS) names(ctx context.Context, addr string) ([]string, error) {
+	lookupAddr := r.LookupAddr
+	if lookupAddr == nil {
+		lookupAddr = net.DefaultResolver.LookupAddr
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultReverseDNSTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	hostnames, err := lookupAddr(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, hostname := range hostnames {
+		hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+		if hostname == "" {
+			continue
+		}
+		names = append(names, hostname)
+		for _, suffix := range r.Suffixes {
+			suffix = strings.ToLower(strings.Trim(suffix, "."))
+			if short, ok := strings.CutSuffix(hostname, "."+suffix); ok && short != "" {
+				names = append(names, short)
+			}
+		}
+	}
+	return names, nil
+}
+
+// ptrResolver finds the node named after a hostname of the client IP in
+// reverse DNS.
+type ptrResolver struct {
+	h *Handler
+}
+
+func (r ptrResolver) Name() string {
+	return resolverPTR
+}
+
+func (r ptrResolver) Resolve(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	names, err := r.h.ReverseDNS.names(ctx, clientIP)
+	if err != nil {
+		// Failing DNS leaves the lease resolvers to find the node.
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			r.h.logger().Warn().
+				Err(err).
+				Str("client_ip", clientIP).
+				Msg("Failed to look up client IP in reverse DNS")
+		}
+		traceNote(ctx, "reverse DNS lookup failed: %v", err)
+		return nil, nil
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	traceNote(ctx, "reverse DNS names the IP %s", strings.Join(names, ", "))
+
+	allNodes, err := r.h.nodeSource().ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Prefer the whole hostname, then the names with suffixes stripped.
+	for _, name := range names {
+		for _, node := range allNodes {
+			if node.Name != "" && strings.EqualFold(node.Name, name) {
+				traceNote(ctx, "node %s is named %s", node.UUID, name)
+				return &node, nil
+			}
+		}
+	}
+	r.h.logger().Debug().
+		Str("client_ip", clientIP).
+		Strs("hostnames", names).
+		Msg("No node named after the hostnames of client IP")
+	return nil, nil
+}
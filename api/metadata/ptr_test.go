@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestPTRResolver(t *testing.T) {
+	hostnames := map[string][]string{
+		"10.0.0.5": {"web01.prov.example.com."},
+		"10.0.0.6": {"WEB02.example.com."},
+		"10.0.0.7": {"db01.prov.example.com."},
+		"10.0.0.8": {"web01.other.example.org."},
+		"10.0.0.9": {"node-full.prov.example.com."},
+	}
+	lookupAddr := func(_ context.Context, addr string) ([]string, error) {
+		switch addr {
+		case "10.0.0.10":
+			return nil, errors.New("server misbehaving")
+		case "10.0.0.11":
+			return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+		}
+		return hostnames[addr], nil
+	}
+
+	h := createTestHandler()
+	h.Nodes = mock.NewNodeSource(
+		nodes.Node{UUID: "node-1", Name: "web01"},
+		nodes.Node{UUID: "node-2", Name: "web02"},
+		nodes.Node{UUID: "node-3", Name: "node-full.prov.example.com"},
+		nodes.Node{UUID: "node-4", Name: "node-full"},
+	)
+	h.ReverseDNS = &ReverseDNS{
+		Suffixes:   []string{".prov.example.com", "example.com."},
+		LookupAddr: lookupAddr,
+	}
+	resolver := h.resolvers()[1]
+	if resolver.Name() != resolverPTR {
+		t.Fatalf("have second resolver %q, want %q", resolver.Name(), resolverPTR)
+	}
+
+	tests := []struct {
+		clientIP string
+		wantNode string
+	}{
+		{clientIP: "10.0.0.5", wantNode: "node-1"},
+		{clientIP: "10.0.0.6", wantNode: "node-2"},
+		{clientIP: "10.0.0.7"},
+		{clientIP: "10.0.0.8"},
+		{clientIP: "10.0.0.9", wantNode: "node-3"},
+		{clientIP: "10.0.0.10"},
+		{clientIP: "10.0.0.11"},
+		{clientIP: "10.0.0.12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.clientIP, func(t *testing.T) {
+			node, err := resolver.Resolve(t.Context(), tt.clientIP)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var have string
+			if node != nil {
+				have = node.UUID
+			}
+			if have != tt.wantNode {
+				t.Errorf("have node %q, want %q", have, tt.wantNode)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// ParseRedactKeys parses a comma separated list of glob patterns of keys
+// never served, such as *password*,ipmi_*.
+func ParseRedactKeys(spec string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(spec, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// redactedKey reports whether a key of the properties, extra or meta of a
+// node matches one of RedactKeys, ignoring case, and is never served.
+func (h *Handler) redactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range h.RedactKeys {
+		if ok, _ := path.Match(strings.ToLower(pattern), key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedNode returns a copy of a node for operator templates, without its
+// driver_info and driver_internal_info, which hold BMC credentials and agent
+// tokens, and without the keys of its properties, extra and instance_info
+// matching RedactKeys.
+func (h *Handler) redactedNode(node *nodes.Node) *nodes.Node {
+	redacted := *node
+	redacted.DriverInfo = nil
+	redacted.DriverInternalInfo = nil
+	redacted.Properties = h.redactMap(node.Properties)
+	redacted.Extra = h.redactMap(node.Extra)
+	redacted.InstanceInfo = h.redactMap(node.InstanceInfo)
+	return &redacted
+}
+
+// redactMap returns a copy of m without the keys matching RedactKeys.
+func (h *Handler) redactMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(m))
+	for key, value := range m {
+		if !h.redactedKey(key) {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
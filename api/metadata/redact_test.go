@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestParseRedactKeys(t *testing.T) {
+	tests := []struct {
+		have    string
+		want    []string
+		wantErr bool
+	}{
+		{have: ""},
+		{have: "*password*, IPMI_*,", want: []string{"*password*", "ipmi_*"}},
+		{have: "[a-", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.have, func(t *testing.T) {
+			have, err := ParseRedactKeys(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_redactKeys(t *testing.T) {
+	node := nodes.Node{
+		UUID:           "node-1",
+		Name:           "web01",
+		ProvisionState: "active",
+		Properties: map[string]any{
+			"cpu_arch":       "x86_64",
+			"IPMI_Address":   "10.1.0.5",
+			"root_password":  "hunter2",
+			"capabilities":   "boot_mode:uefi",
+			"vendor_comment": "rack 4",
+		},
+		Extra: map[string]any{
+			"tags": map[string]any{"role": "web", "api_token": "s3cret"},
+		},
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+			"meta_data": map[string]any{
+				"metal3-name":     "web01",
+				"bmc_password":    "hunter2",
+				"metal3-password": "hunter2",
+			},
+		},
+	}
+	h := NewHandler(
+		WithNodeSource(mock.NewNodeSource(node)),
+		WithRedactKeys("*password*", "ipmi_*", "*token*"),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json", nil)
+	req.RemoteAddr = "10.0.0.5:4321"
+	rr := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("have status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+
+	var document struct {
+		Meta       map[string]string `json:"meta"`
+		Tags       map[string]string `json:"tags"`
+		Metal3Name string            `json:"metal3-name"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &document); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if document.Meta["cpu_arch"] != "x86_64" || document.Tags["role"] != "web" ||
+		document.Metal3Name != "web01" {
+		t.Errorf("have %s, want the keys not redacted", rr.Body)
+	}
+	for _, secret := range []string{"hunter2", "10.1.0.5", "s3cret"} {
+		if strings.Contains(rr.Body.String(), secret) {
+			t.Errorf("have %q in %s, want it redacted", secret, rr.Body)
+		}
+	}
+}
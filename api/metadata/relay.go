@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+// relayAgentResolver finds the node cabled to the switch port a DHCP relay
+// agent reported in the lease of the client IP. The circuit ID is matched to
+// the port_id, and the remote ID to the switch_id or switch_info, of the
+// local_link_connection of Ironic ports.
+type relayAgentResolver struct {
+	h      *Handler
+	leases *leases.File
+}
+
+func (r relayAgentResolver) Name() string {
+	return resolverRelayAgent
+}
+
+func (r relayAgentResolver) Resolve(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	lease, ok, err := r.leases.Lookup(clientIP)
+	if err != nil || !ok || (lease.CircuitID == "" && lease.RemoteID == "") {
+		traceNote(ctx, "no lease with relay agent information for the IP")
+		return nil, nil
+	}
+	traceMAC(ctx, lease.MAC)
+	traceNote(ctx, "lease has circuit ID %q and remote ID %q", lease.CircuitID, lease.RemoteID)
+
+	allPorts, err := r.h.nodeSource().ListPorts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matched []ports.Port
+	for _, port := range allPorts {
+		if matchesRelayAgent(port.LocalLinkConnection, lease) {
+			matched = append(matched, port)
+			traceNote(ctx, "port %s of node %s matches the relay agent information",
+				port.UUID, port.NodeUUID)
+		}
+	}
+
+	nodeUUID, ok := relayAgentNode(matched, lease.MAC)
+	if !ok {
+		r.h.logger().Warn().
+			Str("client_ip", clientIP).
+			Str("circuit_id", lease.CircuitID).
+			Str("remote_id", lease.RemoteID).
+			Int("ports_matched", len(matched)).
+			Msg("Relay agent information matches ports of several nodes")
+		traceNote(ctx, "the MAC address does not single out one of the matched nodes")
+		return nil, nil
+	}
+	if nodeUUID == "" {
+		return nil, nil
+	}
+
+	node, err := r.h.nodeSource().GetNode(ctx, nodeUUID)
+	if gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+		// The node was deleted since the ports were listed.
+		return nil, nil
+	}
+	return node, err
+}
+
+// matchesRelayAgent reports whether a port's local_link_connection matches
+// the relay agent information of lease. A circuit ID must equal the port_id.
+// A remote ID must equal the switch_id, compared as MAC addresses, or the
+// switch_info, unless the port records neither and the circuit ID matched.
+func matchesRelayAgent(llc map[string]any, lease leases.Lease) bool {
+	field := func(key string) string {
+		value, _ := llc[key].(string)
+		return value
+	}
+
+	if lease.CircuitID != "" && !strings.EqualFold(field("port_id"), lease.CircuitID) {
+		return false
+	}
+	if lease.RemoteID == "" {
+		return true
+	}
+	switchID, switchInfo := field("switch_id"), field("switch_info")
+	if switchID == "" && switchInfo == "" {
+		return lease.CircuitID != ""
+	}
+	return sameMAC(switchID, lease.RemoteID) ||
+		strings.EqualFold(switchID, lease.RemoteID) ||
+		strings.EqualFold(switchInfo, lease.RemoteID)
+}
+
+// relayAgentNode returns the UUID of the node the matched ports belong to,
+// or an empty UUID when there are none. When they belong to several nodes,
+// the node with a port of the leased MAC address is chosen, and false is
+// returned when that does not single out one.
+func relayAgentNode(matched []ports.Port, mac string) (string, bool) {
+	nodeUUIDs := make(map[string]bool)
+	for _, port := range matched {
+		nodeUUIDs[port.NodeUUID] = true
+	}
+	if len(nodeUUIDs) <= 1 {
+		for nodeUUID := range nodeUUIDs {
+			return nodeUUID, true
+		}
+		return "", true
+	}
+
+	clear(nodeUUIDs)
+	for _, port := range matched {
+		if strings.EqualFold(port.Address, mac) {
+			nodeUUIDs[port.NodeUUID] = true
+		}
+	}
+	if len(nodeUUIDs) != 1 {
+		return "", false
+	}
+	for nodeUUID := range nodeUUIDs {
+		return nodeUUID, true
+	}
+	return "", false
+}
+
+// sameMAC reports whether a and b are the same MAC address.
+func sameMAC(a, b string) bool {
+	macA, err := net.ParseMAC(a)
+	if err != nil {
+		return false
+	}
+	macB, err := net.ParseMAC(b)
+	if err != nil {
+		return false
+	}
+	return macA.String() == macB.String()
+}
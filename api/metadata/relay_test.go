@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestRelayAgentResolver(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "dhcpd.leases")
+	lease := func(ip, mac, options string) string {
+		return "lease " + ip + " {\n  starts 4 2025/06/01 12:00:00;\n" +
+			"  hardware ethernet " + mac + ";\n" + options + "}\n"
+	}
+	data := lease("10.0.0.5", "52:54:00:00:00:05",
+		"  option agent.circuit-id \"Ethernet1/5\";\n  option agent.remote-id 0:1b:21:0:0:b;\n") +
+		lease("10.0.0.6", "52:54:00:00:0a:01", "  option agent.circuit-id \"Ethernet1/5\";\n") +
+		lease("10.0.0.7", "52:54:00:00:00:07", "  option agent.circuit-id \"Ethernet1/5\";\n") +
+		lease("10.0.0.8", "52:54:00:00:0a:01", "") +
+		lease("10.0.0.9", "52:54:00:00:00:09",
+			"  option agent.circuit-id \"ethernet1/6\";\n  option agent.remote-id \"leaf-a\";\n")
+	if err := os.WriteFile(leaseFile, []byte(data), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source := mock.NewNodeSource(
+		nodes.Node{UUID: "node-a1"}, nodes.Node{UUID: "node-a2"}, nodes.Node{UUID: "node-b1"})
+	for _, port := range []ports.Port{
+		{NodeUUID: "node-a1", Address: "52:54:00:00:0a:01", LocalLinkConnection: map[string]any{
+			"switch_id": "00:1b:21:00:00:0a", "switch_info": "leaf-a", "port_id": "Ethernet1/5",
+		}},
+		{NodeUUID: "node-a2", Address: "52:54:00:00:0a:02", LocalLinkConnection: map[string]any{
+			"switch_id": "00:1b:21:00:00:0a", "switch_info": "leaf-a", "port_id": "Ethernet1/6",
+		}},
+		{NodeUUID: "node-b1", Address: "52:54:00:00:0b:01", LocalLinkConnection: map[string]any{
+			"switch_id": "00:1b:21:00:00:0b", "switch_info": "leaf-b", "port_id": "Ethernet1/5",
+		}},
+	} {
+		source.AddPort(port)
+	}
+
+	h := createTestHandler()
+	h.Nodes = source
+	h.DHCPLeases = leases.NewFile(leaseFile)
+	h.RelayAgentMatching = true
+	resolver := h.resolvers()[0]
+	if resolver.Name() != resolverRelayAgent {
+		t.Fatalf("have first resolver %q, want %q", resolver.Name(), resolverRelayAgent)
+	}
+
+	tests := []struct {
+		name     string
+		clientIP string
+		wantNode string
+	}{
+		{name: "circuit and remote ID as switch MAC", clientIP: "10.0.0.5", wantNode: "node-b1"},
+		{name: "circuit ID on several racks and MAC", clientIP: "10.0.0.6", wantNode: "node-a1"},
+		{name: "circuit ID on several racks", clientIP: "10.0.0.7"},
+		{name: "no relay agent information", clientIP: "10.0.0.8"},
+		{name: "remote ID as switch info", clientIP: "10.0.0.9", wantNode: "node-a2"},
+		{name: "no lease", clientIP: "10.0.0.10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := resolver.Resolve(t.Context(), tt.clientIP)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var have string
+			if node != nil {
+				have = node.UUID
+			}
+			if have != tt.wantNode {
+				t.Errorf("have node %q, want %q", have, tt.wantNode)
+			}
+		})
+	}
+}
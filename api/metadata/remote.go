@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"context"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// remoteUserDataTimeout bounds the download of remote user data.
+const remoteUserDataTimeout = 30 * time.Second
+
+// fetchUserData downloads user data referenced by URL, swift:// or s3://
+// reference, verifying it against instance_info["user_data_checksum"] when
+// set. User data is returned as stored, still encoded. User data that cannot
+// be fetched or fails verification is dropped.
+func (h *Handler) fetchUserData(node *nodes.Node, ref string) []byte {
+	if h.RemoteUserData == nil {
+		h.logger().Error().
+			Str("node_uuid", node.UUID).
+			Msg("Node references remote user data but remote fetching is not configured")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteUserDataTimeout)
+	defer cancel()
+
+	data, err := h.RemoteUserData.Fetch(ctx, ref, h.userDataLimit())
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to fetch remote user data")
+		return nil
+	}
+
+	if checksum, ok := node.InstanceInfo["user_data_checksum"].(string); ok && checksum != "" {
+		if err := remote.Verify(data, checksum); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("node_uuid", node.UUID).
+				Msg("Remote user data failed checksum verification")
+			return nil
+		}
+	}
+
+	return data
+}
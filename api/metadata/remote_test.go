@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestGetUserData_remote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user-data" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("#cloud-config\n"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		instanceInfo map[string]any
+		want         string
+	}{
+		{
+			name:         "remote",
+			instanceInfo: map[string]any{"user_data": srv.URL + "/user-data"},
+			want:         "#cloud-config\n",
+		},
+		{
+			name: "checksum",
+			instanceInfo: map[string]any{
+				"user_data":          srv.URL + "/user-data",
+				"user_data_checksum": "md5:bcc49e477481b24e5973326acb51702a",
+			},
+			want: "#cloud-config\n",
+		},
+		{
+			name: "checksum mismatch",
+			instanceInfo: map[string]any{
+				"user_data":          srv.URL + "/user-data",
+				"user_data_checksum": "md5:00000000000000000000000000000000",
+			},
+		},
+		{
+			name:         "missing",
+			instanceInfo: map[string]any{"user_data": srv.URL + "/missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.RemoteUserData = remote.NewFetcher(remote.NewDownloader(nil, "", nil), time.Minute)
+			node := &nodes.Node{UUID: "test-uuid", InstanceInfo: tt.instanceInfo}
+
+			if have := h.getUserData(node); have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
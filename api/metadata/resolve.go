@@ -0,0 +1,142 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// DefaultDHCPLeaseFile is the lease database read by the dhcp_lease resolver
+// by default, the dnsmasq lease file of Metal3's Ironic image.
+const DefaultDHCPLeaseFile = "/shared/dnsmasq/dnsmasq.leases"
+
+// nodeSource returns the source of nodes and ports, defaulting to the
+// Ironic clients.
+func (h *Handler) nodeSource() client.NodeSource {
+	if h.Nodes != nil {
+		return h.Nodes
+	}
+	return h.Clients
+}
+
+// resolvers returns the resolvers finding the node of a client IP.
+func (h *Handler) resolvers() []client.Resolver {
+	if len(h.Resolvers) > 0 {
+		return h.Resolvers
+	}
+	dhcpLeases := h.dhcpLeases()
+	var resolvers []client.Resolver
+	if h.Plugins != nil {
+		resolvers = append(resolvers, pluginResolver{h: h})
+	}
+	resolvers = append(resolvers, ipResolver{h: h})
+	if h.ReverseDNS != nil {
+		resolvers = append(resolvers, ptrResolver{h: h})
+	}
+	if h.DHCPACKs != nil {
+		resolvers = append(resolvers, ackResolver{h: h})
+	}
+	resolvers = append(resolvers, leaseResolver{h: h, leases: dhcpLeases})
+	if h.RelayAgentMatching {
+		resolvers = append([]client.Resolver{relayAgentResolver{h: h, leases: dhcpLeases}},
+			resolvers...)
+	}
+	if len(h.VirtualHostSuffixes) > 0 {
+		resolvers = append([]client.Resolver{hostResolver{h: h}}, resolvers...)
+	}
+	return resolvers
+}
+
+// dhcpLeases returns the lease database of the DHCP server.
+func (h *Handler) dhcpLeases() *leases.File {
+	if h.DHCPLeases != nil {
+		return h.DHCPLeases
+	}
+	return leases.NewFile(DefaultDHCPLeaseFile)
+}
+
+// ipResolver finds the node holding the client IP in its configdrive network
+// data or instance_info.
+type ipResolver struct {
+	h *Handler
+}
+
+func (r ipResolver) Name() string {
+	return resolverIP
+}
+
+func (r ipResolver) Resolve(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	return r.h.matchNodeByIP(ctx, clientIP)
+}
+
+// pluginResolver finds the node named by the resolve function of a WASM
+// plugin.
+type pluginResolver struct {
+	h *Handler
+}
+
+func (r pluginResolver) Name() string {
+	return resolverPlugin
+}
+
+func (r pluginResolver) Resolve(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	nodeID, plugin, err := r.h.Plugins.Resolve(ctx, clientIP)
+	if err != nil {
+		// A failing plugin leaves the other resolvers to find the node.
+		r.h.logger().Warn().
+			Err(err).
+			Str("client_ip", clientIP).
+			Str("plugin", plugin).
+			Msg("Plugin failed to resolve client IP")
+		traceNote(ctx, "plugin %s failed: %v", plugin, err)
+		return nil, nil
+	}
+	if nodeID == "" {
+		return nil, nil
+	}
+	traceNote(ctx, "plugin %s names node %s", plugin, nodeID)
+
+	node, err := r.h.nodeSource().GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s named by plugin %s: %w", nodeID, plugin, err)
+	}
+	return node, nil
+}
+
+// leaseResolver finds the MAC address leased the client IP in the lease
+// database of the DHCP server, and the node with a port of that address.
+type leaseResolver struct {
+	h      *Handler
+	leases *leases.File
+}
+
+func (r leaseResolver) Name() string {
+	return resolverDHCPLease
+}
+
+func (r leaseResolver) Resolve(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	return r.h.lookupNodeByMAC(ctx, r.leases, clientIP)
+}
+
+// ackResolver finds the MAC address leased the client IP in the DHCP ACKs
+// captured on the network, and the node with a port of that address.
+type ackResolver struct {
+	h *Handler
+}
+
+func (r ackResolver) Name() string {
+	return resolverDHCPACK
+}
+
+func (r ackResolver) Resolve(ctx context.Context, clientIP string) (*nodes.Node, error) {
+	lease, ok, err := r.h.DHCPACKs.Lookup(clientIP)
+	if err != nil || !ok {
+		traceNote(ctx, "no DHCP ACK captured for the IP")
+		return nil, nil
+	}
+	traceMAC(ctx, lease.MAC)
+	return r.h.getNodeByMACAddress(ctx, lease.MAC)
+}
@@ -0,0 +1,214 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/plugins"
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/wasmplugin"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestGetNodeByIP(t *testing.T) {
+	node1 := &nodes.Node{UUID: "node-1"}
+	node2 := &nodes.Node{UUID: "node-2"}
+
+	tests := []struct {
+		name      string
+		resolvers []client.Resolver
+		wantNode  string
+		wantErr   bool
+	}{
+		{
+			name: "first resolver wins",
+			resolvers: []client.Resolver{
+				&mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": node1}},
+				&mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": node2}},
+			},
+			wantNode: "node-1",
+		},
+		{
+			name: "falls back to next resolver",
+			resolvers: []client.Resolver{
+				&mock.Resolver{},
+				&mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": node2}},
+			},
+			wantNode: "node-2",
+		},
+		{
+			name: "error stops resolution",
+			resolvers: []client.Resolver{
+				&mock.Resolver{Err: errors.New("unavailable")},
+				&mock.Resolver{Nodes: map[string]*nodes.Node{"10.0.0.5": node2}},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "no node",
+			resolvers: []client.Resolver{&mock.Resolver{}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.Resolvers = tt.resolvers
+
+			node, err := h.getNodeByIP(t.Context(), "10.0.0.5")
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if node.UUID != tt.wantNode {
+				t.Errorf("have node %q, want %q", node.UUID, tt.wantNode)
+			}
+		})
+	}
+}
+
+func TestHandler_NodeSource(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{
+		UUID: "1be26c0b-03f2-4d2e-ae87-c02d7f33c123",
+		Name: "web01",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	})
+	h := createTestHandler()
+	h.Nodes = source
+
+	req := httptest.NewRequest("GET", "/openstack/latest/meta_data.json", nil)
+	req.RemoteAddr = "10.0.0.5:40000"
+	rr := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var have struct {
+		UUID string `json:"uuid"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have.UUID != "1be26c0b-03f2-4d2e-ae87-c02d7f33c123" || have.Name != "web01" {
+		t.Errorf("have %+v, want node web01", have)
+	}
+	if calls := source.Calls(mock.MethodListNodes); calls != 1 {
+		t.Errorf("have %d ListNodes calls, want 1", calls)
+	}
+
+	req = httptest.NewRequest("GET", "/openstack/latest/meta_data.json", nil)
+	req.RemoteAddr = "10.0.0.6:40000"
+	rr = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown client, got %d", rr.Code)
+	}
+}
+
+func TestHandler_DHCPACKs(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{UUID: "node-1"})
+	source.AddPort(ports.Port{UUID: "port-1", Address: "52:54:00:00:00:01", NodeUUID: "node-1"})
+	h := createTestHandler()
+	h.Nodes = source
+	h.DHCPLeases = leases.NewFile(filepath.Join(t.TempDir(), "missing.leases"))
+	h.DHCPACKs = leases.NewLearned()
+	h.DHCPACKs.Add(leases.Lease{
+		IP:     netip.MustParseAddr("10.0.0.5"),
+		MAC:    "52:54:00:00:00:01",
+		Active: true,
+	})
+
+	var names []string
+	for _, resolver := range h.resolvers() {
+		names = append(names, resolver.Name())
+	}
+	wantNames := []string{resolverIP, resolverDHCPACK, resolverDHCPLease}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("have resolvers %q, want %q", names, wantNames)
+	}
+
+	node, err := h.getNodeByIP(t.Context(), "10.0.0.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.UUID != "node-1" {
+		t.Errorf("have node %q, want node-1", node.UUID)
+	}
+	if _, err := h.getNodeByIP(t.Context(), "10.0.0.6"); err == nil {
+		t.Error("expected error for an IP without a captured ACK")
+	}
+}
+
+func TestPluginResolver(t *testing.T) {
+	tests := []struct {
+		name     string
+		module   []byte
+		wantNode string
+		wantErr  bool
+	}{
+		{name: "named node", module: wasmplugin.Resolver(`{"node":"web01"}`), wantNode: "node-1"},
+		{name: "no node", module: wasmplugin.Resolver("")},
+		{name: "failed", module: wasmplugin.Resolver("not json")},
+		{name: "unknown node", module: wasmplugin.Resolver(`{"node":"db01"}`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			wasmplugin.Write(t, dir, "rack", tt.module, time.Now())
+			wasmPlugins, err := plugins.New(t.Context(), plugins.Config{Dir: dir})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = wasmPlugins.Close(context.Background())
+			})
+
+			h := createTestHandler()
+			h.Nodes = mock.NewNodeSource(nodes.Node{UUID: "node-1", Name: "web01"})
+			h.Plugins = wasmPlugins
+			resolver := h.resolvers()[0]
+			if resolver.Name() != resolverPlugin {
+				t.Fatalf("have first resolver %q, want %q", resolver.Name(), resolverPlugin)
+			}
+
+			node, err := resolver.Resolve(t.Context(), "10.0.0.5")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var have string
+			if node != nil {
+				have = node.UUID
+			}
+			if have != tt.wantNode {
+				t.Errorf("have node %q, want %q", have, tt.wantNode)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// secretResolveTimeout bounds the secret lookups of a single document.
+const secretResolveTimeout = 10 * time.Second
+
+// vaultRefPattern matches {{ vault "<path>#<field>" }} references. Other
+// template syntax is left alone, since user data is often a Jinja template
+// rendered by cloud-init.
+var vaultRefPattern = regexp.MustCompile(`\{\{\s*vault\s+"([^"]+)"\s*\}\}`)
+
+// resolveSecrets replaces Vault references in data with the secrets they
+// name. Secrets are inserted verbatim, without escaping.
+func (h *Handler) resolveSecrets(ctx context.Context, data []byte) ([]byte, error) {
+	if !vaultRefPattern.Match(data) {
+		return data, nil
+	}
+	if h.Vault == nil {
+		return nil, errors.New("document references vault secrets but vault is not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretResolveTimeout)
+	defer cancel()
+
+	var resolveErr error
+	resolved := vaultRefPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		if resolveErr != nil {
+			return nil
+		}
+		ref := vaultRefPattern.FindSubmatch(match)[1]
+		secret, err := h.Vault.Read(ctx, string(ref))
+		if err != nil {
+			resolveErr = fmt.Errorf("failed to resolve vault reference: %w", err)
+			return nil
+		}
+		return []byte(secret)
+	})
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	return resolved, nil
+}
+
+// resolveSecretValues resolves Vault references in the string values of a
+// decoded JSON document.
+func (h *Handler) resolveSecretValues(ctx context.Context, v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		resolved, err := h.resolveSecrets(ctx, []byte(v))
+		if err != nil {
+			return nil, err
+		}
+		return string(resolved), nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			resolved, err := h.resolveSecretValues(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			resolved, err := h.resolveSecretValues(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return v, nil
+}
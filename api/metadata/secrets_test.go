@@ -0,0 +1,109 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/vault"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestRenderUserData_vaultReferences(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/nodes/web01" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"root_password": "hunter2"}, "metadata": {}}}`))
+	}))
+	defer srv.Close()
+
+	client, err := vault.New(vault.Config{Address: srv.URL, Token: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		vault   *vault.Client
+		have    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "resolved",
+			vault: client,
+			have:  "#cloud-config\npassword: {{ vault \"secret/data/nodes/web01#root_password\" }}\n",
+			want:  "#cloud-config\npassword: hunter2\n",
+		},
+		{
+			name:  "jinja untouched",
+			vault: client,
+			have:  "## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n",
+			want:  "## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n",
+		},
+		{
+			name: "no references without vault",
+			have: "#!/bin/sh\n",
+			want: "#!/bin/sh\n",
+		},
+		{
+			name:    "references without vault",
+			have:    "password: {{ vault \"secret/data/nodes/web01#root_password\" }}\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			vault:   client,
+			have:    "password: {{ vault \"secret/data/nodes/web02#root_password\" }}\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.Vault = tt.vault
+			node := &nodes.Node{
+				UUID:         "test-uuid",
+				InstanceInfo: map[string]any{"user_data": tt.have},
+			}
+
+			have, err := h.renderUserData(node)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(have) != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSecretValues(t *testing.T) {
+	h := createTestHandler()
+	have, err := h.resolveSecretValues(t.Context(), map[string]any{
+		"list":   []any{"a", 1},
+		"nested": map[string]any{"b": true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	doc, ok := have.(map[string]any)
+	if !ok || len(doc) != 2 {
+		t.Fatalf("unexpected document: %v", have)
+	}
+
+	_, err = h.resolveSecretValues(t.Context(), map[string]any{
+		"nested": []any{`{{ vault "secret/data/x#y" }}`},
+	})
+	if err == nil {
+		t.Error("expected error for nested reference without vault")
+	}
+}
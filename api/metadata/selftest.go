@@ -0,0 +1,282 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Stages of /admin/selftest, in the order they run.
+const (
+	stageLeases  = "leases"
+	stageNode    = "node"
+	stageResolve = "resolve"
+	stageRender  = "render"
+)
+
+// Outcomes of a stage of /admin/selftest.
+const (
+	stageOK      = "ok"
+	stageFailed  = "failed"
+	stageSkipped = "skipped"
+)
+
+// SelfTest is the response of /admin/selftest: how each stage of serving a
+// node went.
+type SelfTest struct {
+	// OK is false when a stage failed.
+	OK     bool         `json:"ok"`
+	Node   *LookupNode  `json:"node,omitempty"`
+	IP     string       `json:"ip,omitempty"`
+	Stages []*TestStage `json:"stages"`
+}
+
+// TestStage is the outcome of a stage of /admin/selftest.
+type TestStage struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Detail          string  `json:"detail,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// runStage runs fn as the stage called name of test, timing it, and reports
+// whether it passed. fn returns the detail of the stage, and errSkipStage to
+// skip it.
+func (t *SelfTest) runStage(name string, fn func() (string, error)) bool {
+	start := time.Now()
+	detail, err := fn()
+	stage := &TestStage{
+		Name:            name,
+		Status:          stageOK,
+		DurationSeconds: time.Since(start).Seconds(),
+		Detail:          detail,
+	}
+	switch {
+	case errors.Is(err, errSkipStage):
+		stage.Status = stageSkipped
+	case err != nil:
+		stage.Status, stage.Error = stageFailed, err.Error()
+		t.OK = false
+	}
+	t.Stages = append(t.Stages, stage)
+	return err == nil
+}
+
+// skip records a stage that could not run, as an earlier one failed.
+func (t *SelfTest) skip(names ...string) {
+	for _, name := range names {
+		t.Stages = append(t.Stages, &TestStage{
+			Name:   name,
+			Status: stageSkipped,
+			Detail: "an earlier stage failed",
+		})
+	}
+}
+
+// errSkipStage skips a stage of /admin/selftest that does not apply.
+var errSkipStage = errors.New("stage skipped")
+
+// handleSelfTest handles requests to /admin/selftest, which serves a node end
+// to end without answering it: it parses the DHCP leases, fetches the node
+// from the live Ironic, resolves it from one of its IP addresses and renders
+// its documents, timing each stage. The node is the one of the node query
+// parameter, or the first served node with a known IP address; the ip
+// parameter picks the address it is resolved from. The response is 200 OK
+// when every stage passed and 503 Service Unavailable otherwise, for
+// monitoring systems to use as a deep health check.
+func (h *Handler) handleSelfTest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	test := &SelfTest{OK: true, Stages: []*TestStage{}}
+
+	var active []leases.Lease
+	test.runStage(stageLeases, func() (string, error) {
+		dhcpLeases := h.dhcpLeases()
+		all, err := dhcpLeases.Leases()
+		if errors.Is(err, fs.ErrNotExist) && h.DHCPLeases == nil {
+			return "no lease file at the default path", errSkipStage
+		}
+		if err != nil {
+			return "", err
+		}
+		active = all
+		if h.DHCPACKs != nil {
+			active = append(active, h.DHCPACKs.Leases()...)
+		}
+		return fmt.Sprintf("%d active leases in %s", len(all), dhcpLeases.Path()), nil
+	})
+
+	var node *nodes.Node
+	ip := r.URL.Query().Get("ip")
+	if !test.runStage(stageNode, func() (string, error) {
+		var err error
+		node, ip, err = h.selfTestNode(ctx, r.URL.Query().Get("node"), ip, active)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("node in provision state %q", node.ProvisionState), nil
+	}) {
+		test.skip(stageResolve, stageRender)
+		h.writeSelfTest(w, test)
+		return
+	}
+	test.Node = &LookupNode{
+		UUID:           node.UUID,
+		Name:           node.Name,
+		ProvisionState: node.ProvisionState,
+	}
+	test.IP = ip
+
+	test.runStage(stageResolve, func() (string, error) {
+		if ip == "" {
+			return "", errors.New("no IP address known for the node")
+		}
+		trace := &LookupTrace{IP: ip}
+		resolved, err := h.getNodeByIP(withLookupTrace(ctx, trace), ip)
+		if err != nil {
+			return "", err
+		}
+		if resolved.UUID != node.UUID {
+			return "", fmt.Errorf("resolved node %s instead", resolved.UUID)
+		}
+		for _, step := range trace.Resolvers {
+			if step.Matched {
+				return "resolved by the " + step.Name + " resolver", nil
+			}
+		}
+		return "", nil
+	})
+
+	test.runStage(stageRender, func() (string, error) {
+		return h.selfTestRender(ctx, node)
+	})
+	h.writeSelfTest(w, test)
+}
+
+// writeSelfTest writes the outcome of a self-test.
+func (h *Handler) writeSelfTest(w http.ResponseWriter, test *SelfTest) {
+	if !test.OK {
+		h.logger().Warn().
+			Interface("stages", test.Stages).
+			Msg("Self-test failed")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(test)
+		return
+	}
+	h.writeJSONResponse(w, test)
+}
+
+// selfTestNode returns the node a self-test serves and the IP address it is
+// resolved from: the node with a UUID or name, or else the first served node
+// with a known IP address. The address is ip, or else one of the node data
+// or active leases of the node, or empty when none is known.
+func (h *Handler) selfTestNode(
+	ctx context.Context, id, ip string, active []leases.Lease,
+) (*nodes.Node, string, error) {
+	leasedIPs := make(map[string]string, len(active))
+	for _, lease := range active {
+		leasedIPs[strings.ToLower(lease.MAC)] = lease.IP.String()
+	}
+	allPorts, err := h.nodeSource().ListPorts(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	nodeIP := func(node *nodes.Node) string {
+		if ip != "" {
+			return ip
+		}
+		if ips := h.nodeIPs(node); len(ips) > 0 {
+			return ips[0].ip
+		}
+		for _, port := range allPorts {
+			if port.NodeUUID != node.UUID {
+				continue
+			}
+			if leased, ok := leasedIPs[strings.ToLower(port.Address)]; ok {
+				return leased
+			}
+		}
+		return ""
+	}
+
+	if id != "" {
+		node, err := h.nodeSource().GetNode(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		return node, nodeIP(node), nil
+	}
+	allNodes, err := h.nodeSource().ListNodes(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range allNodes {
+		node := &allNodes[i]
+		if !h.provisionStateServed(node.ProvisionState) || !h.projectServed(node) {
+			continue
+		}
+		if nodeIP := nodeIP(node); nodeIP != "" {
+			return node, nodeIP, nil
+		}
+	}
+	return nil, "", fmt.Errorf("none of %d nodes is served with a known IP address",
+		len(allNodes))
+}
+
+// selfTestRender renders the meta_data.json, network_data.json and user data
+// of a node, failing on schema violations when ResponseValidation fails
+// requests on them.
+func (h *Handler) selfTestRender(ctx context.Context, node *nodes.Node) (string, error) {
+	metaData, err := h.metaDataDocument(node, h.buildMetaData(node))
+	if err != nil {
+		return "", err
+	}
+	documents := []struct {
+		name     string
+		document any
+		validate func([]byte) []metadata.Violation
+	}{
+		{"meta_data.json", metaData, metadata.ValidateMetaData},
+		{"network_data.json", h.buildNetworkData(node), metadata.ValidateNetworkData},
+	}
+	var violations []string
+	for _, document := range documents {
+		b, err := json.Marshal(document.document)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", document.name, err)
+		}
+		for _, violation := range document.validate(b) {
+			violations = append(violations,
+				fmt.Sprintf("%s: %s: %s", document.name, violation.Path, violation.Message))
+		}
+	}
+
+	body, err := h.openUserData(ctx, node)
+	if err != nil {
+		return "", fmt.Errorf("user_data: %w", err)
+	}
+	size, err := io.Copy(io.Discard, body)
+	if err != nil {
+		return "", fmt.Errorf("user_data: %w", err)
+	}
+
+	detail := fmt.Sprintf("%d bytes of user data", size)
+	if len(violations) > 0 {
+		detail += "; schema violations: " + strings.Join(violations, "; ")
+		if h.ResponseValidation == ValidationFail {
+			return detail, errors.New("rendered documents violate their schemas")
+		}
+	}
+	return detail, nil
+}
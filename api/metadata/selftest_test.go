@@ -0,0 +1,130 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestHandler_selfTest(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	data := "4102444800 52:54:00:00:00:02 10.0.0.6 web02 *\n"
+	if err := os.WriteFile(leaseFile, []byte(data), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source := mock.NewNodeSource(
+		nodes.Node{
+			UUID:           "node-1",
+			Name:           "web01",
+			ProvisionState: "active",
+			InstanceInfo: map[string]any{
+				"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+			},
+		},
+		nodes.Node{UUID: "node-2", Name: "web02", ProvisionState: "active"},
+		nodes.Node{UUID: "node-3", Name: "db01", ProvisionState: "active"},
+	)
+	source.AddPort(ports.Port{UUID: "port-2", NodeUUID: "node-2", Address: "52:54:00:00:00:02"})
+
+	h := NewHandler(
+		WithNodeSource(source),
+		WithDHCPLeases(leases.NewFile(leaseFile)),
+		WithAdminToken("secret"),
+	)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantNode   string
+		wantIP     string
+		// wantStages holds the status of each stage, in order.
+		wantStages []string
+	}{
+		{
+			name:       "first served node",
+			wantStatus: http.StatusOK,
+			wantNode:   "node-1",
+			wantIP:     "10.0.0.5",
+			wantStages: []string{stageOK, stageOK, stageOK, stageOK},
+		},
+		{
+			name:       "node leasing its address",
+			query:      "?node=web02",
+			wantStatus: http.StatusOK,
+			wantNode:   "node-2",
+			wantIP:     "10.0.0.6",
+			wantStages: []string{stageOK, stageOK, stageOK, stageOK},
+		},
+		{
+			name:       "address of another node",
+			query:      "?node=web02&ip=10.0.0.5",
+			wantStatus: http.StatusServiceUnavailable,
+			wantNode:   "node-2",
+			wantIP:     "10.0.0.5",
+			wantStages: []string{stageOK, stageOK, stageFailed, stageOK},
+		},
+		{
+			name:       "node without an address",
+			query:      "?node=db01",
+			wantStatus: http.StatusServiceUnavailable,
+			wantNode:   "node-3",
+			wantStages: []string{stageOK, stageOK, stageFailed, stageOK},
+		},
+		{
+			name:       "unknown node",
+			query:      "?node=missing",
+			wantStatus: http.StatusServiceUnavailable,
+			wantStages: []string{stageOK, stageFailed, stageSkipped, stageSkipped},
+		},
+	}
+
+	stageNames := []string{stageLeases, stageNode, stageResolve, stageRender}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/selftest"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			var have SelfTest
+			if err := json.Unmarshal(rr.Body.Bytes(), &have); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have.OK != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("have ok %v with status %d", have.OK, rr.Code)
+			}
+			haveNode := ""
+			if have.Node != nil {
+				haveNode = have.Node.UUID
+			}
+			if haveNode != tt.wantNode || have.IP != tt.wantIP {
+				t.Errorf("have node %q at %q, want %q at %q", haveNode, have.IP, tt.wantNode,
+					tt.wantIP)
+			}
+			var haveStages []string
+			for i, stage := range have.Stages {
+				haveStages = append(haveStages, stage.Status)
+				if stage.Name != stageNames[i] {
+					t.Errorf("have stage %q at %d, want %q", stage.Name, i, stageNames[i])
+				}
+			}
+			if !slices.Equal(haveStages, tt.wantStages) {
+				t.Errorf("have stages %v, want %v: %s", haveStages, tt.wantStages, rr.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// SignatureHeader carries the detached JWS of a signed response body.
+const SignatureHeader = "X-Metadata-Signature"
+
+// jwsSuffix is appended to the names of signed files to serve them as JWS
+// envelopes.
+const jwsSuffix = ".jws"
+
+// bufferedResponse is a response writer holding the response in memory so
+// it can be signed before it is sent.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// signResponse sets the detached JWS of successful response bodies of next
+// in SignatureHeader. next is returned unchanged when signing is disabled.
+// Signed responses are buffered, so they are no longer streamed.
+func (h *Handler) signResponse(next http.HandlerFunc) http.HandlerFunc {
+	if h.JWS == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		buf := newBufferedResponse()
+		next(buf, r)
+
+		if buf.status == http.StatusOK {
+			signature, err := h.JWS.SignDetached(buf.body.Bytes())
+			if err != nil {
+				h.logger().Error().
+					Err(err).
+					Str("path", r.URL.Path).
+					Msg("Failed to sign response")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			buf.header.Set(SignatureHeader, signature)
+		}
+
+		for key, values := range buf.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(buf.status)
+		if _, err := w.Write(buf.body.Bytes()); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("path", r.URL.Path).
+				Msg("Failed to write signed response")
+		}
+	}
+}
+
+// resign replaces the detached signature of a response whose body was
+// rewritten after it was signed, if it was.
+func (h *Handler) resign(w http.ResponseWriter, body []byte) error {
+	if h.JWS == nil || w.Header().Get(SignatureHeader) == "" {
+		return nil
+	}
+	signature, err := h.JWS.SignDetached(body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set(SignatureHeader, signature)
+	return nil
+}
+
+// handleSignedEnvelope serves the successful responses of next as a JWS
+// with the response body as payload, for clients that cannot read response
+// headers. Other responses are passed through.
+func (h *Handler) handleSignedEnvelope(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		buf := newBufferedResponse()
+		next(buf, r)
+
+		if buf.status != http.StatusOK {
+			for key, values := range buf.header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(buf.status)
+			_, _ = w.Write(buf.body.Bytes())
+			return
+		}
+
+		token, err := h.JWS.Sign(buf.body.Bytes())
+		if err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("path", r.URL.Path).
+				Msg("Failed to sign response")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jose")
+		if _, err := w.Write([]byte(token)); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("path", r.URL.Path).
+				Msg("Failed to write signed response")
+		}
+	}
+}
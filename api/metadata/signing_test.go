@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/jws"
+)
+
+func TestSignResponse(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jws.NewSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "Node not found", http.StatusNotFound)
+			return
+		}
+		h := createTestHandler()
+		h.writeJSONResponse(w, map[string]string{"uuid": "test-uuid"})
+	}
+
+	h := createTestHandler()
+	h.JWS = signer
+
+	t.Run("detached", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.signResponse(document)(rr, httptest.NewRequest("GET", "/meta_data.json", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d", rr.Code)
+		}
+		signature := rr.Header().Get(SignatureHeader)
+		if _, err := jws.Verify(signature, rr.Body.Bytes(), key.Public()); err != nil {
+			t.Errorf("failed to verify signature %q: %v", signature, err)
+		}
+		if have := rr.Header().Get("Content-Type"); have != "application/json" {
+			t.Errorf("Content-Type: have %q, want application/json", have)
+		}
+	})
+
+	t.Run("envelope", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.handleSignedEnvelope(document)(rr, httptest.NewRequest("GET", "/meta_data.json.jws", nil))
+
+		payload, err := jws.Verify(rr.Body.String(), nil, key.Public())
+		if err != nil {
+			t.Fatalf("failed to verify envelope: %v", err)
+		}
+		if have, want := string(payload), "{\"uuid\":\"test-uuid\"}\n"; have != want {
+			t.Errorf("payload: have %q, want %q", have, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		for _, handler := range []http.HandlerFunc{
+			h.signResponse(document),
+			h.handleSignedEnvelope(document),
+		} {
+			rr := httptest.NewRecorder()
+			handler(rr, httptest.NewRequest("GET", "/missing", nil))
+
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("status: have %d, want %d", rr.Code, http.StatusNotFound)
+			}
+			if rr.Header().Get(SignatureHeader) != "" {
+				t.Error("expected error response to be unsigned")
+			}
+		}
+	})
+}
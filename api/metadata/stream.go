@@ -0,0 +1,224 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/blob"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// userDataBody is the rendered user data of a node, ready to be written.
+type userDataBody struct {
+	io.Reader
+
+	// size is the length of the user data, zero when none is set.
+	size int64
+
+	// etag is the ETag of the user data.
+	etag string
+
+	// contentType is the Content-Type of the user data.
+	contentType string
+}
+
+// openUserData returns the rendered user data of a node. User data served
+// as stored is streamed from its decoded source, so large payloads are
+// never held in memory decoded. User data that has to be rendered, because
+// it is structured, layered with fragments or may reference secrets, is
+// rendered in memory.
+func (h *Handler) openUserData(ctx context.Context, node *nodes.Node) (*userDataBody, error) {
+	raw, structured := h.rawUserData(node)
+	if structured != nil {
+		return h.bufferUserData(ctx, node, structured)
+	}
+	if len(raw) == 0 {
+		return h.bufferUserData(ctx, node, "")
+	}
+
+	fragments, err := h.hasUserDataFragments(node)
+	if err != nil {
+		return nil, err
+	}
+	if fragments {
+		return h.bufferUserData(ctx, node, h.decodeUserData(node, raw))
+	}
+
+	// Decode once to size and hash the user data and look for templates,
+	// without keeping the decoded bytes.
+	scan, err := scanUserData(raw, h.userDataLimit())
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to decode user data")
+		return h.bufferUserData(ctx, node, "")
+	}
+	if scan.templated {
+		return h.bufferUserData(ctx, node, h.decodeUserData(node, raw))
+	}
+
+	r, err := blob.NewReader(raw, h.userDataLimit())
+	if err != nil {
+		return nil, err
+	}
+	return &userDataBody{
+		Reader:      r,
+		size:        scan.size,
+		etag:        scan.etag,
+		contentType: userDataContentType(scan.head),
+	}, nil
+}
+
+// bufferUserData renders user data in memory.
+func (h *Handler) bufferUserData(
+	ctx context.Context,
+	node *nodes.Node,
+	userData any,
+) (*userDataBody, error) {
+	b, err := h.serializeUserData(ctx, node, userData)
+	if err != nil {
+		return nil, err
+	}
+	return &userDataBody{
+		Reader:      bytes.NewReader(b),
+		size:        int64(len(b)),
+		etag:        etag(b),
+		contentType: userDataContentType(b),
+	}, nil
+}
+
+// hasUserDataFragments reports whether user data fragments apply to a node.
+func (h *Handler) hasUserDataFragments(node *nodes.Node) (bool, error) {
+	if h.UserDataFragmentsDir == "" {
+		return false, nil
+	}
+	parts, err := h.userDataFragments(node)
+	if err != nil {
+		return false, err
+	}
+	return len(parts) > 0, nil
+}
+
+// userDataScan describes decoded user data.
+type userDataScan struct {
+	size int64
+	etag string
+
+	// templated is set when the user data holds template delimiters, and
+	// so may reference secrets.
+	templated bool
+
+	// head is the start of the user data, to sniff its content type from.
+	head []byte
+}
+
+// scanUserData decodes user data without keeping it, to describe it.
+func scanUserData(raw []byte, limit int64) (*userDataScan, error) {
+	r, err := blob.NewReader(raw, limit)
+	if err != nil {
+		return nil, err
+	}
+	var scanner templateScanner
+	head := &prefixWriter{limit: sniffLen}
+	digest := sha256.New()
+	size, err := io.Copy(io.MultiWriter(&scanner, head, digest), r)
+	if err != nil {
+		return nil, err
+	}
+	return &userDataScan{
+		size:      size,
+		etag:      formatETag(digest.Sum(nil)),
+		templated: scanner.found,
+		head:      head.b,
+	}, nil
+}
+
+// sniffLen is the length of the start of user data its content type is
+// sniffed from, all http.DetectContentType considers.
+const sniffLen = 512
+
+// prefixWriter is a writer keeping the first limit bytes written.
+type prefixWriter struct {
+	b     []byte
+	limit int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if n := w.limit - len(w.b); n > 0 {
+		w.b = append(w.b, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// userDataContentType returns the Content-Type of user data starting with
+// head, so clients such as cloudbase-init and the proxies in between get
+// its bytes as stored: text/plain for text, with the charset of UTF-16
+// text starting with a byte order mark, as PowerShell scripts saved on
+// Windows do, and the sniffed type of binary user data, such as
+// application/x-gzip.
+func userDataContentType(head []byte) string {
+	contentType := http.DetectContentType(head)
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "text/") {
+		return contentType
+	}
+	if charset := params["charset"]; strings.HasPrefix(charset, "utf-16") {
+		return "text/plain; charset=" + charset
+	}
+	return "text/plain"
+}
+
+// templateScanner is a writer recording whether "{{" was written, including
+// across writes.
+type templateScanner struct {
+	last  byte
+	found bool
+}
+
+func (s *templateScanner) Write(p []byte) (int, error) {
+	if s.found || len(p) == 0 {
+		return len(p), nil
+	}
+	s.found = s.last == '{' && p[0] == '{' || bytes.Contains(p, []byte("{{"))
+	s.last = p[len(p)-1]
+	return len(p), nil
+}
+
+// writeUserData writes user data, or 304 Not Modified when the request's
+// validators match it.
+func (h *Handler) writeUserData(
+	w http.ResponseWriter,
+	r *http.Request,
+	node *nodes.Node,
+	body *userDataBody,
+) {
+	contentType := body.contentType
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(body.size, 10))
+	w.Header().Set("ETag", body.etag)
+	if notModified(w, r) {
+		return
+	}
+
+	n, err := io.Copy(w, body)
+	if err == nil && n != body.size {
+		err = fmt.Errorf("wrote %d of %d bytes", n, body.size)
+	}
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to write user data response")
+	}
+}
@@ -0,0 +1,135 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestOpenUserData(t *testing.T) {
+	large := "#cloud-config\nwrite_files:\n" + strings.Repeat("- path: /etc/motd\n", 4096)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write([]byte(large)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		userData any
+		limit    int64
+		want     string
+	}{
+		{
+			name:     "gzipped base64",
+			userData: base64.StdEncoding.EncodeToString(gz.Bytes()),
+			want:     large,
+		},
+		{name: "plain", userData: "#!/bin/sh\n", want: "#!/bin/sh\n"},
+		{
+			name:     "template",
+			userData: "## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n",
+			want:     "## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n",
+		},
+		{
+			name:     "too large",
+			userData: base64.StdEncoding.EncodeToString(gz.Bytes()),
+			limit:    1024,
+		},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.MaxUserDataSize = tt.limit
+			node := &nodes.Node{UUID: "test-uuid", InstanceInfo: map[string]any{}}
+			if tt.userData != nil {
+				node.InstanceInfo["user_data"] = tt.userData
+			}
+
+			body, err := h.openUserData(t.Context(), node)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := int64(len(tt.want)); body.size != want {
+				t.Errorf("size: have %d, want %d", body.size, want)
+			}
+
+			rr := httptest.NewRecorder()
+			h.writeUserData(rr, httptest.NewRequest("GET", "/user_data", nil), node, body)
+			if have := rr.Body.String(); have != tt.want {
+				t.Errorf("have %d bytes, want %d bytes", len(have), len(tt.want))
+			}
+			want := strconv.Itoa(len(tt.want))
+			if have := rr.Header().Get("Content-Length"); have != want {
+				t.Errorf("Content-Length: have %s, want %s", have, want)
+			}
+			// Streamed user data has the ETag of its rendered content.
+			if have, want := rr.Header().Get("ETag"), etag([]byte(tt.want)); have != want {
+				t.Errorf("ETag: have %s, want %s", have, want)
+			}
+		})
+	}
+}
+
+func TestUserDataContentType(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{name: "cloud-config", head: []byte("#cloud-config\n"), want: "text/plain"},
+		{name: "powershell", head: []byte("<powershell>\nGet-Date\n"), want: "text/plain"},
+		{
+			name: "utf-16le",
+			head: []byte("\xff\xfe#\x00p\x00s\x001\x00"),
+			want: "text/plain; charset=utf-16le",
+		},
+		{
+			name: "utf-16be",
+			head: []byte("\xfe\xff\x00#\x00p\x00s\x001"),
+			want: "text/plain; charset=utf-16be",
+		},
+		{name: "gzip", head: []byte{0x1f, 0x8b, 0x08, 0x00}, want: "application/x-gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if have := userDataContentType(tt.head); have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateScanner(t *testing.T) {
+	tests := []struct {
+		have []string
+		want bool
+	}{
+		{have: []string{"#cloud-config\n", "hostname: web01\n"}},
+		{have: []string{"password: {{ vault \"kv/node#pw\" }}"}, want: true},
+		{have: []string{"password: {", "{ vault \"kv/node#pw\" }}"}, want: true},
+		{have: []string{"{", "", "x{"}},
+	}
+	for _, tt := range tests {
+		var s templateScanner
+		for _, chunk := range tt.have {
+			if _, err := io.WriteString(&s, chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s.found != tt.want {
+			t.Errorf("%q: have %v, want %v", tt.have, s.found, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gorilla/mux"
+)
+
+// defaultTagsKey is the node.extra key holding instance tags when the
+// handler does not designate one.
+const defaultTagsKey = "tags"
+
+// handleEC2Tags handles requests to /latest/meta-data/tags/.
+func (h *Handler) handleEC2Tags(w http.ResponseWriter, r *http.Request) {
+	h.writeTextResponse(w, "instance/")
+}
+
+// handleEC2InstanceTags handles requests to /latest/meta-data/tags/instance/,
+// listing the tag keys of the node.
+func (h *Handler) handleEC2InstanceTags(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "ec2_tags")
+	if !ok {
+		return
+	}
+
+	tags := h.nodeTags(node)
+	if len(tags) == 0 {
+		http.Error(w, "Tags not found", http.StatusNotFound)
+		return
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h.writeTextResponse(w, strings.Join(keys, "\n"))
+}
+
+// handleEC2InstanceTag handles requests to
+// /latest/meta-data/tags/instance/{key}.
+func (h *Handler) handleEC2InstanceTag(w http.ResponseWriter, r *http.Request) {
+	node, _, ok := h.nodeForRequest(w, r, "ec2_tags")
+	if !ok {
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	value, exists := h.nodeTags(node)[key]
+	if !exists {
+		h.logger().Debug().
+			Str("node_uuid", node.UUID).
+			Str("tag", key).
+			Msg("Tag not found")
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	h.writeTextResponse(w, value)
+}
+
+// nodeTags returns the instance tags stored in the designated node.extra
+// map. Scalar values are formatted as strings; nested values are skipped.
+func (h *Handler) nodeTags(node *nodes.Node) map[string]string {
+	tagsKey := h.TagsKey
+	if tagsKey == "" {
+		tagsKey = defaultTagsKey
+	}
+
+	raw, ok := node.Extra[tagsKey].(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if h.redactedKey(key) {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			tags[key] = v
+		case bool, float64, int, int64:
+			tags[key] = fmt.Sprint(v)
+		default:
+			h.logger().Debug().
+				Str("node_uuid", node.UUID).
+				Str("tag", key).
+				Msg("Skipping non-scalar tag value")
+		}
+	}
+	return tags
+}
@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestNodeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tagsKey string
+		extra   map[string]any
+		want    map[string]string
+	}{
+		{name: "no extra", want: nil},
+		{
+			name: "default key",
+			extra: map[string]any{
+				"tags": map[string]any{
+					"role":     "worker",
+					"rack":     float64(12),
+					"gpu":      true,
+					"topology": map[string]any{"row": "a"},
+				},
+			},
+			want: map[string]string{"role": "worker", "rack": "12", "gpu": "true"},
+		},
+		{
+			name:    "designated key",
+			tagsKey: "instance_tags",
+			extra: map[string]any{
+				"tags":          map[string]any{"role": "ignored"},
+				"instance_tags": map[string]any{"role": "control-plane"},
+			},
+			want: map[string]string{"role": "control-plane"},
+		},
+		{name: "not a map", extra: map[string]any{"tags": "role=worker"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TagsKey: tt.tagsKey}
+			have := h.nodeTags(&nodes.Node{UUID: "test-uuid", Extra: tt.extra})
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// templateSuffix is appended to the name of a document to name the
+// template of its operator keys.
+const templateSuffix = ".tmpl"
+
+// TemplateContext is the data templates are rendered with.
+type TemplateContext struct {
+	// Node is the Ironic node, whose fields such as .Node.Name,
+	// .Node.Properties and .Node.Extra templates read. It has no
+	// driver_info or driver_internal_info, and no keys matching RedactKeys.
+	Node *nodes.Node
+
+	ProjectID string
+	Hostname  string
+	Region    string
+
+	// IPs are the IP addresses the node holds in its node data.
+	IPs []string
+}
+
+// templateFuncs are the functions of templates: those of the sprig library,
+// less env and expandenv, which would serve the environment of the service,
+// credentials included.
+var templateFuncs = func() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+	return funcs
+}()
+
+// templateContext returns the data templates of a node are rendered with.
+func (h *Handler) templateContext(node *nodes.Node) *TemplateContext {
+	tc := &TemplateContext{
+		Node:      h.redactedNode(node),
+		ProjectID: h.projectID(node),
+		Hostname:  getNodeHostname(node),
+		Region:    h.Region,
+	}
+	for _, ip := range h.nodeIPs(node) {
+		if !slices.Contains(tc.IPs, ip.ip) {
+			tc.IPs = append(tc.IPs, ip.ip)
+		}
+	}
+	return tc
+}
+
+// renderTemplates merges the JSON objects rendered by the operator's
+// templates of the document name into document:
+// <TemplateDir>/global/<name>.tmpl, then
+// <TemplateDir>/resource-class/<resource class>/<name>.tmpl. Objects are
+// merged recursively, and other values of later templates replace earlier
+// ones.
+func (h *Handler) renderTemplates(node *nodes.Node, name string, document map[string]any) error {
+	if h.TemplateDir == "" {
+		return nil
+	}
+
+	var tc *TemplateContext
+	for _, dir := range layerDirs(node) {
+		path := filepath.Join(h.TemplateDir, dir, name+templateSuffix)
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+		tmpl, err := template.New(filepath.Base(path)).
+			Funcs(templateFuncs).
+			Option("missingkey=zero").
+			Parse(string(b))
+		if err != nil {
+			return fmt.Errorf("invalid template %s: %w", path, err)
+		}
+
+		if tc == nil {
+			tc = h.templateContext(node)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, tc); err != nil {
+			return fmt.Errorf("failed to render template %s: %w", path, err)
+		}
+		var data map[string]any
+		if err := json.Unmarshal(out.Bytes(), &data); err != nil {
+			return fmt.Errorf("template %s rendered invalid JSON: %w", path, err)
+		}
+		mergeVendorData(document, data)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_renderTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"global/meta_data.json.tmpl": `{"meta": {` +
+			`"asset_tag": {{ .Node.Extra.asset_tag | default "unknown" | toJson }}, ` +
+			`"cluster": {{ .Node.Properties.cluster | upper | quote }}}, ` +
+			`"ips": {{ toJson .IPs }}}`,
+		"resource-class/gpu/meta_data.json.tmpl": `{"meta": {"cluster": "gpu-` +
+			`{{ .Node.Name | trimPrefix "node-" }}"}}`,
+		"global/vendor_data.json.tmpl":              `{"site": {"project": "{{ .ProjectID }}"}}`,
+		"resource-class/bad/meta_data.json.tmpl":    `{"meta": {{ .Node.Name }}}`,
+		"resource-class/ugly/vendor_data.json.tmpl": `{{ .Node.Name | nosuchfunc }}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := createTestHandler()
+	h.TemplateDir = dir
+
+	tests := []struct {
+		name     string
+		class    string
+		extra    map[string]any
+		wantMeta map[string]any
+		wantErr  bool
+	}{
+		{
+			name:  "global",
+			extra: map[string]any{"asset_tag": "A-1234"},
+			wantMeta: map[string]any{
+				"asset_tag": "A-1234",
+				"cluster":   "LAB",
+				"role":      "web",
+			},
+		},
+		{
+			name:  "resource class",
+			class: "gpu",
+			wantMeta: map[string]any{
+				"asset_tag": "unknown",
+				"cluster":   "gpu-01",
+				"role":      "web",
+			},
+		},
+		{name: "invalid JSON", class: "bad", wantErr: true},
+		{name: "invalid template", class: "ugly", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &nodes.Node{
+				UUID:          "node-uuid",
+				Name:          "node-01",
+				Lessee:        "tenant-a",
+				ResourceClass: tt.class,
+				Properties:    map[string]any{"cluster": "lab", "role": "web"},
+				Extra:         tt.extra,
+				InstanceInfo: map[string]any{
+					"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+				},
+			}
+
+			document, err := h.metaDataDocument(node, h.buildMetaData(node))
+			if err == nil {
+				_, err = h.buildVendorData(node)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			metaData, _ := document.(map[string]any)
+			if have := metaData["meta"]; !reflect.DeepEqual(have, tt.wantMeta) {
+				t.Errorf("have meta %v, want %v", have, tt.wantMeta)
+			}
+			if have := metaData["ips"]; !reflect.DeepEqual(have, []any{"10.0.0.5"}) {
+				t.Errorf("have ips %v, want the IP of the node", have)
+			}
+			if metaData["uuid"] != "node-uuid" {
+				t.Errorf("have uuid %v, want the built keys kept", metaData["uuid"])
+			}
+
+			vendorData, err := h.buildVendorData(node)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have := vendorData["site"]; !reflect.DeepEqual(have,
+				map[string]any{"project": "tenant-a"}) {
+				t.Errorf("have site %v, want the project of the node", have)
+			}
+		})
+	}
+}
+
+func TestHandler_renderTemplates_redacted(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     map[string]any
+		wantErr  bool
+	}{
+		{
+			name: "driver info",
+			template: `{"meta": {"bmc": {{ .Node.DriverInfo.ipmi_password | default "none" | ` +
+				`toJson }}}}`,
+			want: map[string]any{"bmc": "none"},
+		},
+		{
+			name: "redacted key",
+			template: `{"meta": {"token": {{ .Node.Properties.api_token | default "none" | ` +
+				`toJson }}, "role": {{ .Node.Properties.role | toJson }}}}`,
+			want: map[string]any{"token": "none", "role": "web"},
+		},
+		{
+			name:     "sprig",
+			template: `{"meta": {"role": {{ .Node.Properties.role | b64enc | toJson }}}}`,
+			want:     map[string]any{"role": "d2Vi"},
+		},
+		{
+			name:     "environment",
+			template: `{"meta": {"path": {{ env "PATH" | toJson }}}}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "global", "meta_data.json.tmpl")
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(tt.template), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			h := NewHandler(WithRedactKeys("*token*"))
+			h.TemplateDir = dir
+
+			node := &nodes.Node{
+				UUID:       "node-uuid",
+				DriverInfo: map[string]any{"ipmi_password": "hunter2"},
+				Properties: map[string]any{"api_token": "s3cret", "role": "web"},
+			}
+			document := map[string]any{}
+			err := h.renderTemplates(node, "meta_data.json", document)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have := document["meta"]; !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have meta %v, want %v", have, tt.want)
+			}
+			if node.DriverInfo["ipmi_password"] != "hunter2" {
+				t.Error("have the node itself redacted, want a copy")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/appkins-org/ironic-metadata/pkg/userdata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// SetUserDataResult describes user data stored on a node.
+type SetUserDataResult struct {
+	NodeUUID string          `json:"node_uuid"`
+	Format   userdata.Format `json:"format"`
+	Size     int             `json:"size"`
+}
+
+// SetUserData validates user data and stores it in a node's
+// instance_info, replacing any earlier user data. Binary payloads such as
+// gzipped user data are stored base64 encoded.
+func (h *Handler) SetUserData(
+	ctx context.Context,
+	node *nodes.Node,
+	data []byte,
+) (*SetUserDataResult, error) {
+	format, err := userdata.Validate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	value := string(data)
+	if !utf8.Valid(data) {
+		value = base64.StdEncoding.EncodeToString(data)
+	}
+
+	err = h.updateNode(ctx, node, nodes.UpdateOperation{
+		Op:    nodes.AddOp,
+		Path:  "/instance_info/user_data",
+		Value: value,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := node.InstanceInfo["configdrive"]; ok {
+		h.logger().Warn().
+			Str("node_uuid", node.UUID).
+			Msg("Node has a configdrive, whose user data takes precedence over instance_info")
+	}
+	h.logger().Info().
+		Str("node_uuid", node.UUID).
+		Str("format", string(format)).
+		Int("size", len(value)).
+		Msg("Set node user data")
+
+	return &SetUserDataResult{NodeUUID: node.UUID, Format: format, Size: len(value)}, nil
+}
+
+// handleSetUserData handles PUT requests to /admin/nodes/{uuid}/user_data,
+// storing the request body as the node's user data.
+func (h *Handler) handleSetUserData(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, userdata.MaxSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "User data too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := userdata.Validate(data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	node, ok := h.adminNode(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.SetUserData(r.Context(), node, data)
+	if errors.Is(err, ErrNotLeader) {
+		writeNotLeader(w)
+		return
+	}
+	if errors.Is(err, ErrNoNodeWriter) {
+		writeNoNodeWriter(w)
+		return
+	}
+	if err != nil {
+		h.logger().Error().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Failed to set user data")
+		http.Error(w, "Failed to set user data", http.StatusBadGateway)
+		return
+	}
+
+	h.writeJSONResponse(w, result)
+}
@@ -0,0 +1,138 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandleSetUserData(t *testing.T) {
+	var patch []map[string]any
+	ironic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nodes/node-01" && r.URL.Path != "/nodes/test-uuid" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"uuid": "test-uuid", "name": "node-01"}`))
+	}))
+	defer ironic.Close()
+
+	h := createTestHandler()
+	h.AdminToken = "secret"
+	h.Clients.SetIronicClient(&gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       ironic.URL + "/",
+	})
+	router := h.Routes()
+
+	tests := []struct {
+		name       string
+		node       string
+		body       string
+		wantStatus int
+		wantFormat string
+	}{
+		{
+			name:       "cloud-config",
+			node:       "node-01",
+			body:       "#cloud-config\nhostname: node-01\n",
+			wantStatus: http.StatusOK,
+			wantFormat: "cloud-config",
+		},
+		{
+			name:       "script",
+			node:       "node-01",
+			body:       "#!/bin/sh\necho hi\n",
+			wantStatus: http.StatusOK,
+			wantFormat: "script",
+		},
+		{
+			name:       "invalid cloud-config",
+			node:       "node-01",
+			body:       "#cloud-config\nhostname: [\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown format",
+			node:       "node-01",
+			body:       "hello\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown node",
+			node:       "missing",
+			body:       "#!/bin/sh\n",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch = nil
+			req := httptest.NewRequest(
+				http.MethodPut,
+				"/admin/nodes/"+tt.node+"/user_data",
+				strings.NewReader(tt.body),
+			)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status: have %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if patch != nil {
+					t.Error("expected no patch for a rejected request")
+				}
+				return
+			}
+
+			var result SetUserDataResult
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if string(result.Format) != tt.wantFormat {
+				t.Errorf("format: have %q, want %q", result.Format, tt.wantFormat)
+			}
+			if len(patch) != 1 || patch[0]["path"] != "/instance_info/user_data" ||
+				patch[0]["value"] != tt.body {
+				t.Errorf("unexpected patch: %v", patch)
+			}
+		})
+	}
+}
+
+func TestHandleSetUserData_nodeSource(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{UUID: "node-1", Name: "web01"})
+	h := NewHandler(WithNodeSource(source), WithAdminToken("secret"))
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/nodes/web01/user_data",
+		strings.NewReader("#!/bin/sh\necho hi\n"))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("have status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	stored, err := source.GetNode(t.Context(), "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := stored.InstanceInfo["user_data"]; have != "#!/bin/sh\necho hi\n" {
+		t.Errorf("have user data %q, want the script", have)
+	}
+}
@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// Modes of validating meta_data.json and network_data.json before they are
+// served.
+const (
+	// ValidationOff serves documents unchecked.
+	ValidationOff = "off"
+
+	// ValidationLog logs the schema violations of a document and serves it
+	// anyway.
+	ValidationLog = "log"
+
+	// ValidationFail logs the schema violations of a document and fails the
+	// request instead of serving it.
+	ValidationFail = "fail"
+)
+
+// ResponseValidations lists the modes of validating documents.
+var ResponseValidations = []string{ValidationOff, ValidationLog, ValidationFail}
+
+// ParseResponseValidation parses a mode of validating documents, where empty
+// means ValidationOff.
+func ParseResponseValidation(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		return ValidationOff, nil
+	}
+	if !slices.Contains(ResponseValidations, mode) {
+		return "", fmt.Errorf("unknown response validation %q, want one of %s",
+			mode, strings.Join(ResponseValidations, ", "))
+	}
+	return mode, nil
+}
+
+// writeValidatedJSON writes document as a JSON response after checking it
+// with validate, according to the ResponseValidation mode.
+func (h *Handler) writeValidatedJSON(
+	w http.ResponseWriter,
+	node *nodes.Node,
+	name string,
+	document any,
+	validate func([]byte) []metadata.Violation,
+) {
+	if h.ResponseValidation == "" || h.ResponseValidation == ValidationOff {
+		h.writeJSONResponse(w, document)
+		return
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		h.writeJSONResponse(w, document)
+		return
+	}
+	violations := validate(body)
+	for _, violation := range violations {
+		h.logger().Warn().
+			Str("document", name).
+			Str("node_uuid", node.UUID).
+			Str("path", violation.Path).
+			Str("violation", violation.Message).
+			Msg("Rendered document violates its schema")
+	}
+	h.Metrics.observeViolations(name, len(violations))
+	if len(violations) > 0 && h.ResponseValidation == ValidationFail {
+		http.Error(w, "Rendered document violates its schema", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(body, '\n'))
+}
@@ -0,0 +1,56 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestHandler_responseValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		path       string
+		wantStatus int
+	}{
+		{name: "off", mode: ValidationOff, path: "network_data.json", wantStatus: http.StatusOK},
+		{name: "log", mode: ValidationLog, path: "network_data.json", wantStatus: http.StatusOK},
+		{
+			name:       "fail",
+			mode:       ValidationFail,
+			path:       "network_data.json",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "fail valid",
+			mode:       ValidationFail,
+			path:       "meta_data.json",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.ResponseValidation = tt.mode
+			// The fallback network data has neither MAC addresses nor services.
+			h.Nodes = mock.NewNodeSource(nodes.Node{UUID: "node-1", Name: "10.0.0.5"})
+
+			req := httptest.NewRequest("GET", "/openstack/latest/"+tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:40000"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("have status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+		})
+	}
+
+	if _, err := ParseResponseValidation("strict"); err == nil {
+		t.Error("expected error for an unknown mode")
+	}
+}
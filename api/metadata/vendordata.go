@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/appkins-org/ironic-metadata/pkg/vendordata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// buildVendorData returns the vendor_data.json document of a node.
+func (h *Handler) buildVendorData(node *nodes.Node) (map[string]any, error) {
+	data, err := h.staticVendorData(node, "vendor_data.json", vendorData())
+	if err != nil {
+		return nil, err
+	}
+	h.addProfileVendorData(node, "vendor_data.json", data)
+	h.addHardware(node, data)
+	if err := h.renderTemplates(node, "vendor_data.json", data); err != nil {
+		return nil, err
+	}
+	h.addHostKeys(node, data)
+	return data, nil
+}
+
+// buildVendorData2 returns the vendor_data2.json document of a node: the
+// static vendor data and hardware summary, plus the response of each dynamic
+// vendor data target under its name.
+func (h *Handler) buildVendorData2(ctx context.Context, node *nodes.Node) (map[string]any, error) {
+	data, err := h.staticVendorData(node, "vendor_data2.json", vendorData2())
+	if err != nil {
+		return nil, err
+	}
+	h.addProfileVendorData(node, "vendor_data2.json", data)
+	h.addHardware(node, data)
+	if err := h.renderTemplates(node, "vendor_data2.json", data); err != nil {
+		return nil, err
+	}
+	if h.VendorData == nil {
+		return data, nil
+	}
+
+	dynamic, err := h.VendorData.Fetch(ctx, h.vendorDataRequest(node))
+	if err != nil {
+		if dynamic == nil {
+			return nil, fmt.Errorf("failed to fetch dynamic vendor data: %w", err)
+		}
+		h.logger().Warn().
+			Err(err).
+			Str("node_uuid", node.UUID).
+			Msg("Leaving out failed dynamic vendor data targets")
+	}
+	for name, value := range dynamic {
+		data[name] = value
+	}
+	return data, nil
+}
+
+// vendorDataRequest returns the node context sent to dynamic vendor data
+// targets.
+func (h *Handler) vendorDataRequest(node *nodes.Node) *vendordata.Request {
+	metaData := h.buildMetaData(node)
+	imageID, _ := node.InstanceInfo["image_source"].(string)
+	return &vendordata.Request{
+		ProjectID:  metaData.ProjectID,
+		InstanceID: node.UUID,
+		ImageID:    imageID,
+		Hostname:   metaData.Hostname,
+		Metadata:   metaData.Meta,
+	}
+}
+
+// staticVendorData merges the operator's copies of the vendor data document
+// name into builtin: <VendorDataDir>/global/<name>, then
+// <VendorDataDir>/resource-class/<resource class>/<name>. Objects are merged
+// recursively, and other values of later files replace earlier ones.
+func (h *Handler) staticVendorData(
+	node *nodes.Node,
+	name string,
+	builtin map[string]any,
+) (map[string]any, error) {
+	if h.VendorDataDir == "" {
+		return builtin, nil
+	}
+
+	for _, dir := range layerDirs(node) {
+		path := filepath.Join(h.VendorDataDir, dir, name)
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vendor data: %w", err)
+		}
+
+		var data map[string]any
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, fmt.Errorf("invalid vendor data in %s: %w", path, err)
+		}
+		mergeVendorData(builtin, data)
+	}
+	return builtin, nil
+}
+
+// mergeVendorData merges src into dst, recursing into objects present in
+// both.
+func mergeVendorData(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, ok := value.(map[string]any)
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		if dstMap, ok := dst[key].(map[string]any); ok {
+			mergeVendorData(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,162 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/vendordata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestBuildVendorData2(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/join" {
+			http.NotFound(w, r)
+			return
+		}
+		var req vendordata.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"instance": req.InstanceID,
+			"image":    req.ImageID,
+		})
+	}))
+	defer srv.Close()
+
+	join := vendordata.Target{Name: "join", URL: srv.URL + "/join"}
+	missing := vendordata.Target{Name: "missing", URL: srv.URL + "/missing"}
+	static := vendorData2()["static"]
+
+	tests := []struct {
+		name    string
+		client  *vendordata.Client
+		want    map[string]any
+		wantErr bool
+	}{
+		{name: "static", want: map[string]any{"static": static}},
+		{
+			name:   "dynamic",
+			client: vendordata.New(vendordata.Config{Targets: []vendordata.Target{join, missing}}),
+			want: map[string]any{
+				"static": static,
+				"join":   map[string]any{"instance": "test-uuid", "image": "glance-image"},
+			},
+		},
+		{
+			name: "failure fatal",
+			client: vendordata.New(vendordata.Config{
+				Targets:      []vendordata.Target{join, missing},
+				FailureFatal: true,
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler()
+			h.VendorData = tt.client
+			node := &nodes.Node{
+				UUID:         "test-uuid",
+				InstanceInfo: map[string]any{"image_source": "glance-image"},
+			}
+
+			have, err := h.buildVendorData2(t.Context(), node)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildVendorData_static(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"global/vendor_data.json": `{"ironic": {"site": "dc1"}, "ntp": ["ntp1"]}`,
+		"resource-class/gpu/vendor_data.json": `{"ironic": {"site": "dc2"}, ` +
+			`"ntp": ["ntp2"], "gpu": true}`,
+		"resource-class/gpu/vendor_data2.json": `{"static": {"gpu": {"driver": "550"}}}`,
+		"resource-class/bad/vendor_data.json":  `[]`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := createTestHandler()
+	h.VendorDataDir = dir
+
+	tests := []struct {
+		name    string
+		class   string
+		want    map[string]any
+		want2   map[string]any
+		wantErr bool
+	}{
+		{
+			name: "global",
+			want: map[string]any{
+				"ironic": map[string]any{"version": "1.0", "site": "dc1"},
+				"ntp":    []any{"ntp1"},
+			},
+			want2: vendorData2(),
+		},
+		{
+			name:  "resource class",
+			class: "gpu",
+			want: map[string]any{
+				"ironic": map[string]any{"version": "1.0", "site": "dc2"},
+				"ntp":    []any{"ntp2"},
+				"gpu":    true,
+			},
+			want2: map[string]any{
+				"static": map[string]any{
+					"ironic-metadata": map[string]any{"version": "1.0"},
+					"gpu":             map[string]any{"driver": "550"},
+				},
+			},
+		},
+		{name: "invalid", class: "bad", wantErr: true, want2: vendorData2()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &nodes.Node{UUID: "test-uuid", ResourceClass: tt.class}
+
+			have, err := h.buildVendorData(node)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("vendor_data.json: have %v, want %v", have, tt.want)
+			}
+
+			have2, err := h.buildVendorData2(t.Context(), node)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have2, tt.want2) {
+				t.Errorf("vendor_data2.json: have %v, want %v", have2, tt.want2)
+			}
+		})
+	}
+}
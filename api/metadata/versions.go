@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// OpenStack metadata versions, named after the Nova release introducing them.
+const (
+	versionFolsom    = "2012-08-10"
+	versionGrizzly   = "2013-04-04"
+	versionHavana    = "2013-10-17"
+	versionLiberty   = "2015-10-15"
+	versionNewtonOne = "2016-06-30"
+	versionNewtonTwo = "2016-10-06"
+	versionOcata     = "2017-02-22"
+	versionRocky     = "2018-08-27"
+	versionLatest    = "latest"
+)
+
+// openStackVersions lists the served OpenStack metadata versions in the order
+// Nova lists them, oldest first.
+var openStackVersions = []string{
+	versionFolsom,
+	versionGrizzly,
+	versionHavana,
+	versionLiberty,
+	versionNewtonOne,
+	versionNewtonTwo,
+	versionOcata,
+	versionRocky,
+	versionLatest,
+}
+
+// openStackFile is a file served below an OpenStack metadata version.
+type openStackFile struct {
+	name    string
+	since   string
+	handler http.HandlerFunc
+
+	// post, when set, handles POST requests to the file.
+	post http.HandlerFunc
+
+	// signed marks files whose responses are signed when JWS signing is
+	// enabled.
+	signed bool
+}
+
+// openStackFiles lists the files served below each OpenStack metadata
+// version, together with the version introducing them.
+func (h *Handler) openStackFiles() []openStackFile {
+	files := []openStackFile{
+		{name: "meta_data.json", since: versionFolsom, handler: h.handleMetaData, signed: true},
+		{name: "network_data.json", since: versionLiberty, handler: h.handleNetworkData},
+		{name: "user_data", since: versionFolsom, handler: h.handleUserData, signed: true},
+		{
+			name:    "password",
+			since:   versionGrizzly,
+			handler: h.handlePassword,
+			post:    h.handlePostPassword,
+		},
+	}
+	if h.routesEnabled(RoutesVendorData) {
+		files = append(files,
+			openStackFile{
+				name:    "vendor_data.json",
+				since:   versionHavana,
+				handler: h.handleVendorData,
+			},
+			openStackFile{
+				name:    "vendor_data2.json",
+				since:   versionNewtonTwo,
+				handler: h.handleVendorData2,
+			},
+		)
+	}
+	return files
+}
+
+// servedOpenStackVersions returns the served OpenStack metadata versions,
+// only latest when dated versions are disabled.
+func (h *Handler) servedOpenStackVersions() []string {
+	if !h.routesEnabled(RoutesDatedVersions) {
+		return []string{versionLatest}
+	}
+	return openStackVersions
+}
+
+// openStackVersionPattern matches the version segment of OpenStack routes.
+func (h *Handler) openStackVersionPattern() string {
+	return "{version:" + strings.Join(h.servedOpenStackVersions(), "|") + "}"
+}
+
+// openStackVersion returns the OpenStack metadata version of a request,
+// defaulting to latest for routes without a version segment.
+func openStackVersion(r *http.Request) string {
+	if version := mux.Vars(r)["version"]; version != "" {
+		return version
+	}
+	return versionLatest
+}
+
+// openStackVersionAtLeast reports whether version includes the data
+// introduced in since. Dated versions sort lexically.
+func openStackVersionAtLeast(version, since string) bool {
+	return version == versionLatest || version >= since
+}
+
+// handleOpenStackVersion serves a file only for versions that include it,
+// mirroring Nova which returns 404 for files requested below older versions.
+func (h *Handler) handleOpenStackVersion(file openStackFile) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version := openStackVersion(r)
+		if !openStackVersionAtLeast(version, file.since) {
+			h.logger().Debug().
+				Str("version", version).
+				Str("file", file.name).
+				Str("since", file.since).
+				Msg("File not available in requested metadata version")
+			http.NotFound(w, r)
+			return
+		}
+		file.handler(w, r)
+	}
+}
@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+)
+
+// StartWarm fetches the node and port inventory and parses the DHCP leases
+// in the background, so the first clients to boot after a restart do not
+// wait for them. /readyz fails until warming finished or timeout passed.
+// Warming failures are logged, and the replica becomes ready regardless, as
+// requests can still be served by fetching from Ironic directly.
+func (h *Handler) StartWarm(ctx context.Context, timeout time.Duration) {
+	h.warming.Store(true)
+	go func() {
+		defer h.warming.Store(false)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		start := time.Now()
+		if err := h.Warm(ctx); err != nil {
+			h.logger().Warn().
+				Err(err).
+				Dur("duration", time.Since(start)).
+				Msg("Failed to warm caches, serving from Ironic until they fill")
+			return
+		}
+		h.logger().Info().
+			Dur("duration", time.Since(start)).
+			Msg("Warmed caches")
+	}()
+}
+
+// Warm fetches the node and port inventory, when the node source caches it,
+// and parses the DHCP leases.
+func (h *Handler) Warm(ctx context.Context) error {
+	var errs []error
+	if warmer, ok := h.nodeSource().(client.Warmer); ok {
+		if err := warmer.Warm(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Deployments resolving clients by IP alone have no lease file.
+	if h.DHCPLeases != nil {
+		if err := h.DHCPLeases.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
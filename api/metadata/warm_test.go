@@ -0,0 +1,92 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// blockingSource is a node source whose node listings wait for release.
+type blockingSource struct {
+	*mock.NodeSource
+	release chan struct{}
+}
+
+func (s blockingSource) ListNodes(ctx context.Context) ([]nodes.Node, error) {
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.NodeSource.ListNodes(ctx)
+}
+
+func TestHandler_StartWarm(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		release bool
+	}{
+		{name: "warmed", timeout: time.Minute, release: true},
+		{name: "timed out", timeout: 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := blockingSource{
+				NodeSource: mock.NewNodeSource(nodes.Node{
+					UUID: "node-1",
+					InstanceInfo: map[string]any{
+						"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+					},
+				}),
+				release: make(chan struct{}),
+			}
+			t.Cleanup(func() {
+				if !tt.release {
+					close(source.release)
+				}
+			})
+			h := createTestHandler()
+			h.Nodes = client.NewCachedSource(source, time.Minute)
+			routes := h.Routes()
+			readyz := func() int {
+				rr := httptest.NewRecorder()
+				routes.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+				return rr.Code
+			}
+
+			h.StartWarm(t.Context(), tt.timeout)
+			if have := readyz(); have != http.StatusServiceUnavailable {
+				t.Fatalf("expected not ready while warming, got %d", have)
+			}
+			if tt.release {
+				close(source.release)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for readyz() != http.StatusOK {
+				if time.Now().After(deadline) {
+					t.Fatal("expected ready after warming")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if !tt.release {
+				return
+			}
+
+			// The first client is resolved from the warmed inventory.
+			if _, err := h.getNodeByIP(t.Context(), "10.0.0.5"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have := source.Calls(mock.MethodListNodes); have != 1 {
+				t.Errorf("have %d ListNodes calls, want 1", have)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// ErrNoNodeWriter is returned by writes to nodes on a handler that cannot
+// update them, such as one built with a node source but no clients.
+var ErrNoNodeWriter = errors.New("no node writer configured")
+
+// nodeWriter returns the writer updating nodes: NodeWriter, the Ironic
+// clients or, when neither is set, Nodes if it can update nodes.
+func (h *Handler) nodeWriter() (client.NodeWriter, error) {
+	switch {
+	case h.NodeWriter != nil:
+		return h.NodeWriter, nil
+	case h.Clients != nil:
+		return h.Clients, nil
+	}
+	if writer, ok := h.Nodes.(client.NodeWriter); ok {
+		return writer, nil
+	}
+	return nil, ErrNoNodeWriter
+}
+
+// currentNode gets a node through the node writer, bypassing any cache, so
+// an update checked against the node does not start from stale data.
+func (h *Handler) currentNode(ctx context.Context, nodeID string) (*nodes.Node, error) {
+	writer, err := h.nodeWriter()
+	if err != nil {
+		return nil, err
+	}
+	node, err := writer.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", nodeID, err)
+	}
+	return node, nil
+}
+
+// updateNode applies update operations to a node through the node writer,
+// failing with ErrNotLeader on a replica that is not leader.
+func (h *Handler) updateNode(
+	ctx context.Context,
+	node *nodes.Node,
+	operations ...nodes.UpdateOperation,
+) error {
+	if err := h.checkLeader(); err != nil {
+		return err
+	}
+	writer, err := h.nodeWriter()
+	if err != nil {
+		return err
+	}
+	opts := make(nodes.UpdateOpts, len(operations))
+	for i, operation := range operations {
+		opts[i] = operation
+	}
+	_, err = writer.UpdateNode(ctx, node.UUID, opts)
+	return err
+}
+
+// writeNoNodeWriter answers a request writing to a node on a handler that
+// cannot update nodes with 501 Not Implemented.
+func writeNoNodeWriter(w http.ResponseWriter) {
+	http.Error(w, "Nodes cannot be updated", http.StatusNotImplemented)
+}
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// yamlMediaTypes are the media types of Accept asking for YAML.
+var yamlMediaTypes = []string{
+	"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml",
+}
+
+// yamlMiddleware serves JSON responses as YAML to clients asking for it with
+// format=yaml, or an Accept header preferring a YAML media type over
+// application/json, for humans debugging with curl. format=json asks for
+// JSON whatever the Accept header. Other responses are passed through, and
+// responses are only buffered when YAML is asked for.
+func (h *Handler) yamlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if !wantsYAML(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		body := bw.body.Bytes()
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		if mediaType == "application/json" {
+			converted, err := jsonToYAML(body)
+			if err == nil {
+				err = h.resign(w, converted)
+			}
+			if err != nil {
+				h.logger().Error().
+					Err(err).
+					Str("path", r.URL.Path).
+					Msg("Failed to convert response to YAML")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			body = converted
+			w.Header().Set("Content-Type", "application/yaml")
+			// The validators of the handler were derived from the JSON.
+			w.Header().Del("ETag")
+			w.Header().Del("Content-Length")
+		}
+		w.WriteHeader(bw.status)
+		if _, err := w.Write(body); err != nil {
+			h.logger().Error().
+				Err(err).
+				Str("path", r.URL.Path).
+				Msg("Failed to write response")
+		}
+	})
+}
+
+// wantsYAML reports whether a request asks for YAML rather than JSON.
+func wantsYAML(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case MappingsYAML:
+		return true
+	case MappingsJSON:
+		return false
+	}
+
+	var yamlQ, jsonQ float64
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case mediaType == "application/json":
+			jsonQ = max(jsonQ, q)
+		case isYAMLMediaType(mediaType):
+			yamlQ = max(yamlQ, q)
+		}
+	}
+	return yamlQ > 0 && yamlQ >= jsonQ
+}
+
+// isYAMLMediaType reports whether a media type is one of yamlMediaTypes.
+func isYAMLMediaType(mediaType string) bool {
+	return slices.Contains(yamlMediaTypes, mediaType)
+}
+
+// jsonToYAML converts a JSON document to YAML, keeping its integers as
+// written rather than as floats.
+func jsonToYAML(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(yamlValue(v))
+}
+
+// yamlValue returns a decoded JSON value with its numbers converted to the
+// integers or floats YAML writes them as.
+func yamlValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = yamlValue(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = yamlValue(value)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,117 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWantsYAML(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		want   bool
+	}{
+		{name: "none"},
+		{name: "any", accept: "*/*"},
+		{name: "yaml", accept: "application/yaml", want: true},
+		{name: "text yaml", accept: "text/yaml; charset=utf-8", want: true},
+		{name: "json preferred", accept: "application/json, application/yaml;q=0.5"},
+		{name: "yaml preferred", accept: "application/json;q=0.5, application/x-yaml", want: true},
+		{name: "yaml refused", accept: "application/yaml;q=0"},
+		{name: "format", query: "format=yaml", want: true},
+		{name: "format over accept", query: "format=json", accept: "application/yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/openstack/latest/meta_data.json?"+tt.query,
+				nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if have := wantsYAML(req); have != tt.want {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_yaml(t *testing.T) {
+	node := nodes.Node{
+		UUID:           "node-1",
+		Name:           "web01",
+		ProvisionState: "active",
+		InstanceInfo: map[string]any{
+			"fixed_ips": []any{map[string]any{"ip_address": "10.0.0.5"}},
+		},
+	}
+	h := NewHandler(WithNodeSource(mock.NewNodeSource(node)))
+
+	tests := []struct {
+		name            string
+		path            string
+		wantContentType string
+	}{
+		{
+			name:            "meta_data.json",
+			path:            "/openstack/latest/meta_data.json?format=yaml",
+			wantContentType: "application/yaml",
+		},
+		{
+			name:            "network_data.json",
+			path:            "/openstack/latest/network_data.json?format=yaml",
+			wantContentType: "application/yaml",
+		},
+		{
+			name:            "not JSON",
+			path:            "/openstack/latest?format=yaml",
+			wantContentType: "text/plain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			rr := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("have status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			if have := rr.Header().Get("Content-Type"); have != tt.wantContentType {
+				t.Errorf("have Content-Type %q, want %q", have, tt.wantContentType)
+			}
+			if have := rr.Header().Get("Vary"); have != "Accept" {
+				t.Errorf("have Vary %q, want Accept", have)
+			}
+			if tt.wantContentType != "application/yaml" {
+				return
+			}
+
+			var document map[string]any
+			if err := yaml.Unmarshal(rr.Body.Bytes(), &document); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.name == "meta_data.json" && document["uuid"] != "node-1" {
+				t.Errorf("have %s, want the meta data of the node", rr.Body)
+			}
+			if tt.name == "network_data.json" {
+				links, _ := document["links"].([]any)
+				link, _ := links[0].(map[any]any)
+				if link["mtu"] != 1500 {
+					t.Errorf("have %s, want an integer MTU", rr.Body)
+				}
+			}
+			if rr.Header().Get("ETag") == "" {
+				t.Error("expected an ETag of the YAML body")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/api/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+)
+
+// backendsFile is the IRONIC_BACKENDS_FILE document listing the
+// provisioning domains served by Ironics other than IRONIC_URL.
+type backendsFile struct {
+	Backends []backendConfig `yaml:"backends"`
+}
+
+// backendConfig is a provisioning domain: the client networks it serves and
+// the Ironic holding its nodes.
+type backendConfig struct {
+	Name           string   `yaml:"name"`
+	CIDRs          []string `yaml:"cidrs"`
+	IronicURL      string   `yaml:"ironic_url"`
+	Region         string   `yaml:"region"`
+	DHCPLeaseFile  string   `yaml:"dhcp_lease_file"`
+	Username       string   `yaml:"username"`
+	Password       string   `yaml:"password"`
+	ProjectName    string   `yaml:"project_name"`
+	UserDomainName string   `yaml:"user_domain_name"`
+	RegionName     string   `yaml:"region_name"`
+}
+
+// loadBackends reads the backends of an IRONIC_BACKENDS_FILE.
+func loadBackends(path string) ([]backendConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file backendsFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	names := make(map[string]bool)
+	for i, backend := range file.Backends {
+		switch {
+		case backend.Name == "":
+			return nil, fmt.Errorf("backend %d has no name", i)
+		case names[backend.Name]:
+			return nil, fmt.Errorf("backend %q is defined twice", backend.Name)
+		case backend.IronicURL == "":
+			return nil, fmt.Errorf("backend %q has no ironic_url", backend.Name)
+		case len(backend.CIDRs) == 0:
+			return nil, fmt.Errorf("backend %q has no cidrs", backend.Name)
+		}
+		names[backend.Name] = true
+	}
+	return file.Backends, nil
+}
+
+// createBackends returns a backend for each config, served by a clone of
+// base reading the nodes of its own Ironic, with the clients configured by
+// clientOpts, cached for nodeCacheTTL up to nodeCacheMaxEntries.
+func createBackends(
+	base *metadata.Handler,
+	configs []backendConfig,
+	transport http.RoundTripper,
+	clientOpts client.Options,
+	nodeCacheTTL time.Duration,
+	nodeCacheMaxEntries int,
+) ([]metadata.Backend, error) {
+	backends := make([]metadata.Backend, 0, len(configs))
+	for _, config := range configs {
+		prefixes := make([]netip.Prefix, 0, len(config.CIDRs))
+		for _, cidr := range config.CIDRs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("backend %q: %w", config.Name, err)
+			}
+			prefixes = append(prefixes, prefix)
+		}
+
+		domainName := config.UserDomainName
+		if domainName == "" {
+			domainName = "default"
+		}
+		ironicClient, err := newIronicClient(config.IronicURL, gophercloud.AuthOptions{
+			IdentityEndpoint: config.IronicURL,
+			Username:         config.Username,
+			Password:         config.Password,
+			TenantName:       config.ProjectName,
+			DomainName:       domainName,
+		}, config.RegionName, transport)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", config.Name, err)
+		}
+		if base.Metrics != nil {
+			ironicClient.HTTPClient.Transport = base.Metrics.InstrumentIronic(
+				ironicClient.Endpoint, ironicClient.HTTPClient.Transport)
+		}
+		clientOpts.Ironic = ironicClient
+		clients, err := client.NewClients(clientOpts)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", config.Name, err)
+		}
+
+		opts := []metadata.Option{metadata.WithClients(clients), metadata.WithNodeSource(nil)}
+		if nodeCacheTTL > 0 {
+			cached := client.NewCachedSource(clients, nodeCacheTTL)
+			cached.SetMaxEntries(nodeCacheMaxEntries)
+			opts = append(opts, metadata.WithNodeSource(cached))
+		}
+		if config.Region != "" {
+			opts = append(opts, metadata.WithRegion(config.Region))
+		}
+		if config.DHCPLeaseFile != "" {
+			opts = append(opts, metadata.WithDHCPLeases(leases.NewFile(config.DHCPLeaseFile)))
+		}
+		backends = append(backends, metadata.Backend{
+			Name:     config.Name,
+			Prefixes: prefixes,
+			Handler:  base.Clone(opts...),
+		})
+
+		log.Info().
+			Str("backend", config.Name).
+			Strs("cidrs", config.CIDRs).
+			Str("ironic_url", config.IronicURL).
+			Msg("Serving provisioning domain from its own Ironic")
+	}
+	return backends, nil
+}
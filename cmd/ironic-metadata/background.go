@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/appkins-org/ironic-metadata/api/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/dhcpsnoop"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// background holds the components of the service running beside its
+// listeners, such as reload loops, event sinks and the metrics server, and
+// stops them on shutdown in the reverse order of their start, as deferred
+// calls are, so a component is stopped before those it was started after.
+type background struct {
+	stops []func(context.Context)
+}
+
+// start runs the loop of a component in a goroutine until the service
+// stops, when the context of the loop is cancelled and it is waited for.
+func (b *background) start(run func(context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+	b.onStop(func(context.Context) {
+		cancel()
+		<-done
+	})
+}
+
+// onStop registers stop to be called on shutdown, with the context bounding
+// the shutdown.
+func (b *background) onStop(stop func(context.Context)) {
+	b.stops = append(b.stops, stop)
+}
+
+// stop stops the components, the last started first.
+func (b *background) stop(ctx context.Context) {
+	for _, stop := range slices.Backward(b.stops) {
+		stop(ctx)
+	}
+}
+
+// startComponents starts the configured components of the service running
+// beside its listeners, adding them to handler. They are started, and so
+// stopped in reverse, in this order:
+//
+//   - the WASM plugins, reloaded as their directory changes
+//   - DHCP ACK capture
+//   - leader election, given up once the event sinks have stopped, so the
+//     audit export writes its last snapshot as leader
+//   - the webhook, NATS and audit export event sinks
+//   - the metrics server
+//   - the gRPC admin API, stopped first as its event streams would hold up
+//     the others
+//
+// Components started before one fails to start are stopped.
+func startComponents(
+	handler *metadata.Handler,
+	ironicClient *gophercloud.ServiceClient,
+	swiftClient *gophercloud.ServiceClient,
+) (*background, error) {
+	components := &background{}
+	if err := components.startAll(handler, ironicClient, swiftClient); err != nil {
+		components.stop(context.Background())
+		return nil, err
+	}
+	return components, nil
+}
+
+// startAll starts the components of startComponents.
+func (b *background) startAll(
+	handler *metadata.Handler,
+	ironicClient *gophercloud.ServiceClient,
+	swiftClient *gophercloud.ServiceClient,
+) error {
+	// Load WASM plugins mutating metadata and resolving nodes, reloaded as
+	// the plugin directory changes, if configured
+	if dir := getEnvOrDefault("PLUGINS_DIR", ""); dir != "" {
+		wasmPlugins, err := createPlugins(dir)
+		if err != nil {
+			return fmt.Errorf("failed to load WASM plugins from %s: %w", dir, err)
+		}
+		handler.Plugins = wasmPlugins
+
+		// Close the plugins once their reload loop, started after, stopped
+		b.onStop(func(context.Context) {
+			if err := wasmPlugins.Close(context.Background()); err != nil {
+				log.Error().
+					Err(err).
+					Msg("Failed to close WASM plugins")
+			}
+		})
+		b.start(wasmPlugins.Run)
+		log.Info().
+			Str("dir", dir).
+			Strs("plugins", wasmPlugins.Names()).
+			Msg("Loaded WASM plugins")
+	}
+
+	// Learn leases from the DHCP ACKs captured on an interface, if
+	// configured, instead of relying on reading the lease file alone
+	if iface := getEnvOrDefault("DHCP_CAPTURE_INTERFACE", ""); iface != "" {
+		capture, err := dhcpsnoop.Open(iface)
+		if err != nil {
+			return fmt.Errorf("failed to capture DHCP ACKs: %w", err)
+		}
+		handler.DHCPACKs = leases.NewLearned()
+
+		b.start(func(ctx context.Context) {
+			if err := capture.Serve(ctx, handler.DHCPACKs); err != nil {
+				log.Error().
+					Err(err).
+					Msg("Stopped capturing DHCP ACKs, resolving from the lease file only")
+			}
+		})
+		log.Info().
+			Str("interface", iface).
+			Msg("Capturing DHCP ACKs")
+	}
+
+	// Elect the replica performing write-back features, if configured
+	if backend := getEnvOrDefault("LEADER_ELECTION", ""); backend != "" {
+		elector, err := createLeaderElector(backend)
+		if err != nil {
+			return fmt.Errorf("failed to configure %s leader election: %w", backend, err)
+		}
+		handler.Leader = elector
+		b.start(elector.Run)
+	}
+
+	// Send webhooks an event when nodes fetch their user data or phone home
+	if spec := getEnvOrDefault("WEBHOOK_URLS", ""); spec != "" {
+		notifier, err := createWebhookNotifier(spec)
+		if err != nil {
+			return fmt.Errorf("failed to configure webhooks: %w", err)
+		}
+		handler.EventSinks = append(handler.EventSinks, notifier)
+		b.start(notifier.Run)
+	}
+
+	// Publish the same events to NATS, if configured
+	if natsURL := getEnvOrDefault("NATS_URL", ""); natsURL != "" {
+		publisher, err := createNATSPublisher(natsURL)
+		if err != nil {
+			return fmt.Errorf("failed to configure NATS: %w", err)
+		}
+		handler.EventSinks = append(handler.EventSinks, publisher)
+		b.start(publisher.Run)
+	}
+
+	// Export the events and snapshots of the mapping table to object
+	// storage for audits, if configured
+	if exportURL := getEnvOrDefault("AUDIT_EXPORT_URL", ""); exportURL != "" {
+		exporter, err := createAuditExporter(exportURL, swiftClient, handler)
+		if err != nil {
+			return fmt.Errorf("failed to configure the audit export: %w", err)
+		}
+		handler.EventSinks = append(handler.EventSinks, exporter)
+		b.start(exporter.Run)
+	}
+
+	// Serve metrics on a separate address, if configured, since every
+	// instance can reach the metadata listeners
+	if metricsAddr := getEnvOrDefault("METRICS_ADDR", ""); metricsAddr != "" {
+		registry := metrics.NewRegistry()
+		handler.EnableMetrics(registry)
+		ironicClient.HTTPClient.Transport = handler.Metrics.InstrumentIronic(
+			ironicClient.Endpoint, ironicClient.HTTPClient.Transport)
+		b.onStop(startMetricsServer(metricsAddr, registry))
+	}
+
+	// Serve the admin operations over gRPC, if configured, streaming the
+	// events of the handler to watchers
+	if grpcAddr := getEnvOrDefault("GRPC_ADDR", ""); grpcAddr != "" {
+		stream := events.NewStream()
+		handler.EventSinks = append(handler.EventSinks, stream)
+		grpcServer, err := metadata.NewGRPCServer(handler, stream)
+		if err != nil {
+			return fmt.Errorf("failed to configure the gRPC admin API: %w", err)
+		}
+		stop := startGRPCServer(grpcAddr, grpcServer)
+		b.onStop(func(ctx context.Context) {
+			// End the event streams, which would hold up a graceful stop
+			stream.Close()
+			stop(ctx)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/api/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/audit"
+	"github.com/appkins-org/ironic-metadata/pkg/blob"
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+	"github.com/appkins-org/ironic-metadata/pkg/hostkeys"
+	"github.com/appkins-org/ironic-metadata/pkg/jws"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/logging"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/identity"
+	"github.com/appkins-org/ironic-metadata/pkg/nats"
+	"github.com/appkins-org/ironic-metadata/pkg/opa"
+	"github.com/appkins-org/ironic-metadata/pkg/profiles"
+	"github.com/appkins-org/ironic-metadata/pkg/vendordata"
+	"github.com/appkins-org/ironic-metadata/pkg/webhook"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/rs/zerolog"
+)
+
+// checkResult is the outcome of one check of the check command.
+type checkResult struct {
+	name    string
+	detail  string
+	skipped bool
+	err     error
+}
+
+// exclusiveOptions are the pairs of variables that cannot be set together.
+var exclusiveOptions = [][2]string{
+	{"IRONIC_RECORD_DIR", "IRONIC_REPLAY_DIR"},
+	{"STATIC_METADATA_DIR", "IRONIC_RECORD_DIR"},
+	{"STATIC_METADATA_DIR", "IRONIC_REPLAY_DIR"},
+	{"MUTATION_HOOK_COMMAND", "MUTATION_HOOK_URL"},
+}
+
+// runCheck implements the check command, which validates the configuration
+// of the service without serving: the variables it is configured with,
+// that of its endpoints resolve, that Ironic accepts its credentials and
+// lists a node, and that the DHCP lease files are readable. A report of
+// the checks is printed, and the command fails when any of them did.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "time allowed for the network checks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	results := []checkResult{checkConfig(), checkExclusiveOptions()}
+	results = append(results, checkDNS(ctx)...)
+	results = append(results, checkIronic(ctx))
+	results = append(results, checkLeaseFiles()...)
+
+	if failed := writeCheckReport(os.Stdout, results); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// writeCheckReport writes a line per check to w, followed by the lines of
+// the error of failed checks, and returns the number of failed checks.
+func writeCheckReport(w io.Writer, results []checkResult) int {
+	failed := 0
+	for _, result := range results {
+		status, detail := "ok", result.detail
+		switch {
+		case result.err != nil:
+			status, detail = "FAIL", result.err.Error()
+			failed++
+		case result.skipped:
+			status = "skip"
+		}
+		lines := strings.Split(detail, "\n")
+		if lines[0] == "" {
+			_, _ = fmt.Fprintf(w, "%-4s  %s\n", status, result.name)
+		} else {
+			_, _ = fmt.Fprintf(w, "%-4s  %s: %s\n", status, result.name, lines[0])
+		}
+		for _, line := range lines[1:] {
+			_, _ = fmt.Fprintf(w, "      %s\n", line)
+		}
+	}
+	return failed
+}
+
+// checkConfig validates the variables the service is configured with,
+// reporting every invalid one.
+func checkConfig() checkResult {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if _, err := zerolog.ParseLevel(getEnvOrDefault("LOG_LEVEL", "info")); err != nil {
+		check(fmt.Errorf("invalid LOG_LEVEL: %w", err))
+	}
+	for _, setting := range []struct {
+		name string
+		def  string
+		min  time.Duration
+	}{
+		{"IRONIC_WAIT_TIMEOUT", "0s", 0},
+		{"IRONIC_POLL_INTERVAL", client.DefaultPollInterval.String(), 1},
+		{"IRONIC_LIST_NODES_TIMEOUT", "2m", 0},
+		{"IRONIC_GET_NODE_TIMEOUT", "10s", 0},
+		{"IRONIC_LIST_PORTS_TIMEOUT", "30s", 0},
+		{"LOG_LEVEL_REVERT_AFTER", logging.DefaultRevertAfter.String(), 1},
+		{"ACTIVE_EXPIRY", "0s", 0},
+		{"FAULT_DELAY", metadata.DefaultFaultDelay.String(), 0},
+		{"NODE_CACHE_TTL", "30s", 0},
+		{"CONFIGDRIVE_CACHE_TTL", "5m", 0},
+		{"USERDATA_CACHE_TTL", "5m", 0},
+		{"CACHE_WARM_TIMEOUT", "1m", 0},
+		{"OPA_TIMEOUT", opa.DefaultTimeout.String(), 0},
+		{"MUTATION_HOOK_TIMEOUT", hook.DefaultTimeout.String(), 1},
+		{"WEBHOOK_TIMEOUT", webhook.DefaultTimeout.String(), 1},
+		{"NATS_TIMEOUT", nats.DefaultTimeout.String(), 1},
+		{"AUDIT_EXPORT_INTERVAL", audit.DefaultInterval.String(), time.Minute},
+		{"AUDIT_EXPORT_RETENTION", "0s", 0},
+		{"REVERSE_DNS_TIMEOUT", metadata.DefaultReverseDNSTimeout.String(), 1},
+		{"VAULT_CACHE_TTL", "1m", 0},
+		{"KUBERNETES_CACHE_TTL", "30s", 0},
+	} {
+		value, err := time.ParseDuration(getEnvOrDefault(setting.name, setting.def))
+		if err == nil && value < setting.min {
+			err = fmt.Errorf("%s is too short", value)
+		}
+		if err != nil {
+			check(fmt.Errorf("invalid %s: %w", setting.name, err))
+		}
+	}
+	for _, setting := range []struct {
+		name string
+		def  string
+		min  int64
+	}{
+		{"NODE_DETAIL_WORKERS", strconv.Itoa(client.DefaultDetailWorkers), 0},
+		{"NODE_CACHE_MAX_ENTRIES", "0", 0},
+		{"USERDATA_MAX_SIZE", strconv.Itoa(blob.DefaultLimit), 1},
+		{"WEBHOOK_RETRIES", strconv.Itoa(webhook.DefaultRetries), 0},
+		{"WEBHOOK_QUEUE_SIZE", strconv.Itoa(webhook.DefaultQueueSize), 1},
+	} {
+		value, err := strconv.ParseInt(getEnvOrDefault(setting.name, setting.def), 10, 64)
+		if err == nil && value < setting.min {
+			err = fmt.Errorf("%d is less than %d", value, setting.min)
+		}
+		if err != nil {
+			check(fmt.Errorf("invalid %s: %w", setting.name, err))
+		}
+	}
+
+	_, err := createIronicTransport()
+	check(err)
+	_, err = createNodeFilter()
+	check(err)
+	_, err = createCacheLimits()
+	check(err)
+	protocols, _, err := parseProtocols()
+	check(err)
+	if err == nil {
+		_, err = parseListeners(getEnvOrDefault("BIND_ADDR", "0.0.0.0"),
+			getEnvOrDefault("BIND_PORT", "80"), protocols)
+		check(err)
+	}
+
+	for _, setting := range []struct {
+		name  string
+		parse func(string) error
+	}{
+		{"DISABLED_ROUTES", func(s string) error {
+			_, err := metadata.ParseRouteFamilies(s)
+			return err
+		}},
+		{"TRUSTED_PROXIES", func(s string) error {
+			_, err := metadata.ParseTrustedProxies(s)
+			return err
+		}},
+		{"NODE_HEADER_TRUST", func(s string) error {
+			_, err := metadata.ParseNodeHeaderTrust(s)
+			return err
+		}},
+		{"REDACT_KEYS", func(s string) error {
+			_, err := metadata.ParseRedactKeys(s)
+			return err
+		}},
+		{"SERVED_PROVISION_STATES", func(s string) error {
+			_, err := metadata.ParseProvisionStates(s)
+			return err
+		}},
+		{"GONE_PROVISION_STATES", func(s string) error {
+			_, err := metadata.ParseProvisionStates(s)
+			return err
+		}},
+		{"SERVED_PROJECTS", func(s string) error {
+			_, err := metadata.ParseProjects(s)
+			return err
+		}},
+		{"ACTIVE_EXPIRY_SCOPE", func(s string) error {
+			_, err := metadata.ParseExpiryScope(s)
+			return err
+		}},
+		{"IP_CONFLICT_POLICY", func(s string) error {
+			_, err := metadata.ParseIPConflictPolicy(s)
+			return err
+		}},
+		{"RESPONSE_VALIDATION", func(s string) error {
+			_, err := metadata.ParseResponseValidation(s)
+			return err
+		}},
+		{"FAULT_INJECTION", func(s string) error {
+			_, err := metadata.ParseFaults(s, metadata.DefaultFaultDelay)
+			return err
+		}},
+		{"DEFAULT_PROJECT_ID", metadata.ValidateProjectID},
+		{"VENDORDATA_DYNAMIC_TARGETS", func(s string) error {
+			_, err := vendordata.ParseTargets(s)
+			return err
+		}},
+		{"IRONIC_BACKENDS_FILE", func(s string) error {
+			_, err := loadBackends(s)
+			return err
+		}},
+		{"IDENTITY_KEY_FILE", func(s string) error {
+			_, err := identity.LoadSigner(s, getEnvOrDefault("IDENTITY_CERT_FILE", ""))
+			return err
+		}},
+		{"PROFILES_FILE", func(s string) error {
+			_, err := profiles.Load(s)
+			return err
+		}},
+		{"SSH_HOST_KEY_ESCROW_KEY_FILE", func(s string) error {
+			_, err := hostkeys.LoadSealer(s)
+			return err
+		}},
+		{"JWS_KEY_FILE", func(s string) error {
+			key, err := identity.LoadKey(s)
+			if err != nil {
+				return err
+			}
+			_, err = jws.NewSigner(key)
+			return err
+		}},
+		{"VAULT_ADDR", func(s string) error {
+			_, err := createVaultClient(s)
+			return err
+		}},
+		{"OPA_URL", func(s string) error {
+			_, err := opa.New(opa.Config{
+				Address: s,
+				Path:    getEnvOrDefault("OPA_POLICY_PATH", opa.DefaultPath),
+			})
+			return err
+		}},
+		{"MUTATION_HOOK_FAILURE_POLICY", func(s string) error {
+			_, err := metadata.ParseHookFailurePolicy(s)
+			return err
+		}},
+		{"MUTATION_HOOK_URL", func(s string) error {
+			_, err := hook.New(hook.Config{URL: s})
+			return err
+		}},
+		{"WEBHOOK_URLS", func(s string) error {
+			_, err := webhook.ParseURLs(s)
+			return err
+		}},
+		{"WEBHOOK_EVENTS", func(s string) error {
+			_, err := events.ParseTypes(s)
+			return err
+		}},
+		{"NATS_URL", func(s string) error {
+			_, err := nats.New(nats.Config{
+				URL:           s,
+				SubjectPrefix: getEnvOrDefault("NATS_SUBJECT_PREFIX", ""),
+			})
+			return err
+		}},
+		{"NATS_EVENTS", func(s string) error {
+			_, err := events.ParseTypes(s)
+			return err
+		}},
+		{"AUDIT_EXPORT_URL", func(s string) error {
+			location, err := audit.ParseURL(s)
+			if err != nil {
+				return err
+			}
+			if location.Scheme == "s3" && getEnvOrDefault("AWS_ACCESS_KEY_ID", "") == "" {
+				return errors.New("s3:// requires AWS_ACCESS_KEY_ID")
+			}
+			if location.Scheme == "swift" && getEnvOrDefault("OS_USERNAME", "") == "" {
+				return errors.New("swift:// requires OS_USERNAME")
+			}
+			return nil
+		}},
+		{"AUDIT_EXPORT_EVENTS", func(s string) error {
+			_, err := events.ParseTypes(s)
+			return err
+		}},
+	} {
+		value := getEnvOrDefault(setting.name, "")
+		if value == "" {
+			continue
+		}
+		if err := setting.parse(value); err != nil {
+			check(fmt.Errorf("invalid %s: %w", setting.name, err))
+		}
+	}
+
+	return checkResult{name: "configuration", err: errors.Join(errs...)}
+}
+
+// checkExclusiveOptions reports the variables set together that cannot be.
+func checkExclusiveOptions() checkResult {
+	var errs []error
+	for _, pair := range exclusiveOptions {
+		if getEnvOrDefault(pair[0], "") != "" && getEnvOrDefault(pair[1], "") != "" {
+			errs = append(errs, fmt.Errorf("%s and %s cannot both be set", pair[0], pair[1]))
+		}
+	}
+	return checkResult{name: "exclusive options", err: errors.Join(errs...)}
+}
+
+// checkDNS resolves the host of every endpoint the service calls.
+func checkDNS(ctx context.Context) []checkResult {
+	type endpoint struct {
+		name string
+		url  string
+	}
+	endpoints := []endpoint{
+		{"IRONIC_URL", getEnvOrDefault("IRONIC_URL", "http://localhost:6385")},
+	}
+	for _, name := range []string{
+		"OPA_URL", "MUTATION_HOOK_URL", "NATS_URL", "VAULT_ADDR", "S3_ENDPOINT",
+	} {
+		if value := getEnvOrDefault(name, ""); value != "" {
+			endpoints = append(endpoints, endpoint{name, value})
+		}
+	}
+	if targets, err := vendordata.ParseTargets(
+		getEnvOrDefault("VENDORDATA_DYNAMIC_TARGETS", "")); err == nil {
+		for _, target := range targets {
+			endpoints = append(endpoints,
+				endpoint{"VENDORDATA_DYNAMIC_TARGETS " + target.Name, target.URL})
+		}
+	}
+	if urls, err := webhook.ParseURLs(getEnvOrDefault("WEBHOOK_URLS", "")); err == nil {
+		for _, u := range urls {
+			endpoints = append(endpoints, endpoint{"WEBHOOK_URLS " + endpointHost(u), u})
+		}
+	}
+	if path := getEnvOrDefault("IRONIC_BACKENDS_FILE", ""); path != "" {
+		if backends, err := loadBackends(path); err == nil {
+			for _, backend := range backends {
+				endpoints = append(endpoints,
+					endpoint{"IRONIC_BACKENDS_FILE " + backend.Name, backend.IronicURL})
+			}
+		}
+	}
+
+	results := make([]checkResult, 0, len(endpoints))
+	for _, e := range endpoints {
+		result := checkResult{name: "dns " + e.name}
+		host := endpointHost(e.url)
+		switch {
+		case host == "":
+			result.err = fmt.Errorf("no host in %q", e.url)
+		case isIPAddress(host):
+			result.detail = host + " is an IP address"
+		default:
+			addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+			if err != nil {
+				result.err = err
+			} else {
+				result.detail = host + " resolves to " + strings.Join(addrs, ", ")
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// endpointHost returns the host of an endpoint URL, which may omit its
+// scheme.
+func endpointHost(endpoint string) string {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "//" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// isIPAddress reports whether host is an IP address rather than a name.
+func isIPAddress(host string) bool {
+	_, err := netip.ParseAddr(host)
+	return err == nil
+}
+
+// checkIronic authenticates against Ironic with the configured credentials
+// and lists a node, unless nodes are served without Ironic.
+func checkIronic(ctx context.Context) checkResult {
+	result := checkResult{name: "ironic"}
+	for _, name := range []string{"STATIC_METADATA_DIR", "IRONIC_REPLAY_DIR"} {
+		if getEnvOrDefault(name, "") != "" {
+			result.skipped = true
+			result.detail = "nodes are served from " + name
+			return result
+		}
+	}
+
+	ironicURL := getEnvOrDefault("IRONIC_URL", "http://localhost:6385")
+	transport, err := createIronicTransport()
+	if err != nil {
+		result.err = err
+		return result
+	}
+	ironicClient, err := createIronicClient(ironicURL, transport)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	filter, err := createNodeFilter()
+	if err != nil {
+		result.err = err
+		return result
+	}
+	clients, err := client.NewClients(client.Options{Ironic: ironicClient, NodeFilter: filter})
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	// Stop the listing at the first node
+	errListed := errors.New("listed")
+	var first *nodes.Node
+	err = clients.EachNode(ctx, func(node nodes.Node) error {
+		first = &node
+		return errListed
+	})
+	switch {
+	case err != nil && !errors.Is(err, errListed):
+		result.err = fmt.Errorf("failed to list nodes from %s: %w", ironicClient.Endpoint, err)
+	case first == nil:
+		result.detail = "listed no nodes from " + ironicClient.Endpoint
+	default:
+		result.detail = fmt.Sprintf("listed node %s from %s", first.UUID, ironicClient.Endpoint)
+	}
+	return result
+}
+
+// checkLeaseFiles reads the DHCP lease file, and those of the backends of
+// IRONIC_BACKENDS_FILE.
+func checkLeaseFiles() []checkResult {
+	paths := []string{getEnvOrDefault("DHCP_LEASE_FILE", metadata.DefaultDHCPLeaseFile)}
+	if path := getEnvOrDefault("IRONIC_BACKENDS_FILE", ""); path != "" {
+		if backends, err := loadBackends(path); err == nil {
+			for _, backend := range backends {
+				if backend.DHCPLeaseFile != "" {
+					paths = append(paths, backend.DHCPLeaseFile)
+				}
+			}
+		}
+	}
+
+	results := make([]checkResult, 0, len(paths))
+	for _, path := range paths {
+		result := checkResult{name: "lease file " + path}
+		active, err := leases.NewFile(path).Leases()
+		if err != nil {
+			result.err = err
+		} else {
+			result.detail = fmt.Sprintf("%d active leases", len(active))
+		}
+		results = append(results, result)
+	}
+	return results
+}
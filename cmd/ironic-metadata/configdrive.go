@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/api/metadata"
+)
+
+// runConfigDrive implements the configdrive command, which builds a node's
+// configdrive from its rendered metadata and either writes the image to a
+// file or attaches it to the node.
+func runConfigDrive(args []string) error {
+	fs := flag.NewFlagSet("configdrive", flag.ContinueOnError)
+	nodeID := fs.String("node", "",
+		"build the configdrive of this Ironic node (UUID, name, IP or MAC address)")
+	output := fs.String("output", "", "write the configdrive image to this file (- for stdout)")
+	attach := fs.Bool("attach", false, "store the configdrive in the node's instance_info")
+	target := fs.String("target", metadata.ConfigDriveTargetInstanceInfo,
+		"where -attach stores the configdrive ("+metadata.ConfigDriveTargetInstanceInfo+", "+
+			metadata.ConfigDriveTargetSwift+")")
+	container := fs.String("container", "", "object storage container for the swift target")
+	ttl := fs.Duration("ttl", time.Hour, "lifetime of the temp URL for the swift target")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *nodeID == "" {
+		return errors.New("-node must be set")
+	}
+	if (*output == "") == !*attach {
+		return errors.New("exactly one of -output or -attach must be set")
+	}
+
+	handler, err := commandHandler()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	node, err := handler.FindNode(ctx, *nodeID)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		image, err := handler.BuildConfigDrive(node)
+		if err != nil {
+			return err
+		}
+		if *output == "-" {
+			_, err = os.Stdout.Write(image)
+			return err
+		}
+		return os.WriteFile(*output, image, 0o600)
+	}
+
+	result, err := handler.AttachConfigDrive(ctx, node, metadata.AttachConfigDriveOpts{
+		Target:     *target,
+		Container:  *container,
+		TempURLTTL: *ttl,
+	})
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
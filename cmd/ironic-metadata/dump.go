@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// runDump implements the dump command, which renders every document of a
+// node into a directory laid out like a configdrive, for golden-file
+// comparisons and for attaching to support requests.
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	nodeID := fs.String("node", "",
+		"dump the metadata of this Ironic node (UUID, name, IP or MAC address)")
+	clientIP := fs.String("ip", "",
+		"render the documents for this client IP (default: the -node IP, or the node's first)")
+	output := fs.String("output", "", "write the documents to this directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *nodeID == "" || *output == "" {
+		return errors.New("-node and -output must be set")
+	}
+	if *clientIP == "" {
+		if addr, err := netip.ParseAddr(*nodeID); err == nil {
+			*clientIP = addr.String()
+		}
+	} else if _, err := netip.ParseAddr(*clientIP); err != nil {
+		return fmt.Errorf("invalid -ip %q", *clientIP)
+	}
+	if entries, err := os.ReadDir(*output); err == nil && len(entries) > 0 {
+		return fmt.Errorf("output directory %s is not empty", *output)
+	}
+
+	handler, err := commandHandler()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	node, err := handler.FindNode(ctx, *nodeID)
+	if err != nil {
+		return err
+	}
+
+	// Write the documents that rendered even when others failed, so a dump
+	// of a node that fails to boot still holds what it can.
+	files, renderErr := handler.DumpFiles(ctx, node, *clientIP)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(*output, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(path, files[name], 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return renderErr
+}
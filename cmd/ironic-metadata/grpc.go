@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// startGRPCServer serves the gRPC admin API on addr in the background,
+// retrying to bind it as startMetricsServer does. The returned function
+// stops the server, cancelling the calls still running when shutdownCtx is
+// done.
+func startGRPCServer(addr string, server *grpc.Server) func(context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var ln net.Listener
+		for warned := false; ; warned = true {
+			var err error
+			if ln, err = net.Listen("tcp", addr); err == nil {
+				break
+			}
+			if !warned {
+				log.Warn().
+					Err(err).
+					Str("address", addr).
+					Msg("Failed to listen for gRPC, retrying")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(metricsRetryInterval):
+			}
+		}
+
+		log.Info().Str("address", addr).Msg("Serving the gRPC admin API")
+		if err := server.Serve(ln); err != nil {
+			log.Error().
+				Err(err).
+				Str("address", addr).
+				Msg("Failed to serve gRPC")
+		}
+	}()
+
+	return func(shutdownCtx context.Context) {
+		cancel()
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			server.Stop()
+		}
+		<-done
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/api/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/blob"
+	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/configdrive"
+	"github.com/appkins-org/ironic-metadata/pkg/hostkeys"
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+	"github.com/appkins-org/ironic-metadata/pkg/profiles"
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/appkins-org/ironic-metadata/pkg/vault"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// handlerFromEnv returns a handler rendering the documents of the nodes of
+// clients as the service does, configured from the environment: the
+// directories of templates, content, fragments and vendor data, metadata
+// profiles, redaction, the sources of user data, vendor data and secrets,
+// and how client IPs resolve to nodes. The serve command adds the options
+// deciding how and to whom documents are served, while the other commands
+// render documents with this handler alone.
+func handlerFromEnv(
+	clients *client.Clients,
+	swiftClient *gophercloud.ServiceClient,
+) (*metadata.Handler, error) {
+	handler := metadata.NewHandler(
+		metadata.WithClients(clients),
+		metadata.WithRegion(
+			getEnvOrDefault("IDENTITY_REGION", getEnvOrDefault("OS_REGION_NAME", ""))),
+		metadata.WithTagsKey(getEnvOrDefault("INSTANCE_TAGS_KEY", "tags")),
+		metadata.WithContentDir(getEnvOrDefault("CONTENT_DIR", "")),
+		metadata.WithSwiftTempURLKey(getEnvOrDefault("SWIFT_TEMP_URL_KEY", "")),
+		metadata.WithUserDataFragmentsDir(getEnvOrDefault("USERDATA_FRAGMENTS_DIR", "")),
+		metadata.WithVendorDataDir(getEnvOrDefault("VENDORDATA_DIR", "")),
+		metadata.WithTemplateDir(getEnvOrDefault("TEMPLATE_DIR", "")),
+		metadata.WithDHCPLeases(leases.NewFile(
+			getEnvOrDefault("DHCP_LEASE_FILE", metadata.DefaultDHCPLeaseFile))),
+		metadata.WithRelayAgentMatching(
+			getEnvOrDefault("RELAY_AGENT_MATCHING", "false") == "true"),
+	)
+
+	// Build network data from inspection instead of a synthetic eth0
+	handler.InspectionNetworkData = getEnvOrDefault("INSPECTION_NETWORK_DATA", "false") == "true"
+	// Summarize the inspected hardware in vendor data
+	handler.HardwareVendorData = getEnvOrDefault("HARDWARE_VENDOR_DATA", "false") == "true"
+
+	// Apply operator metadata profiles to the nodes they match, if
+	// configured
+	if path := getEnvOrDefault("PROFILES_FILE", ""); path != "" {
+		nodeProfiles, err := profiles.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROFILES_FILE: %w", err)
+		}
+		handler.Profiles = nodeProfiles
+		log.Info().
+			Int("profiles", len(nodeProfiles)).
+			Msg("Loaded metadata profiles")
+	}
+
+	// Escrow the SSH host keys nodes phone home with, sealing private keys
+	// with the configured key
+	if getEnvOrDefault("SSH_HOST_KEY_ESCROW", "false") == "true" {
+		var sealer *hostkeys.Sealer
+		if keyFile := getEnvOrDefault("SSH_HOST_KEY_ESCROW_KEY_FILE", ""); keyFile != "" {
+			var err error
+			sealer, err = hostkeys.LoadSealer(keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SSH_HOST_KEY_ESCROW_KEY_FILE: %w", err)
+			}
+		}
+		handler.HostKeyEscrow = true
+		handler.HostKeySealer = sealer
+		log.Info().
+			Bool("private_keys", sealer != nil).
+			Msg("Escrowing SSH host keys")
+	}
+
+	// Never serve the node keys matching the configured patterns
+	redactKeys, err := metadata.ParseRedactKeys(getEnvOrDefault("REDACT_KEYS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDACT_KEYS: %w", err)
+	}
+	handler.RedactKeys = redactKeys
+
+	// Report a fixed project_id for nodes without a lessee or owner
+	if projectID := getEnvOrDefault("DEFAULT_PROJECT_ID", ""); projectID != "" {
+		if err := metadata.ValidateProjectID(projectID); err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT_PROJECT_ID: %w", err)
+		}
+		handler.DefaultProjectID = projectID
+	}
+
+	// Decide the node of a client IP several nodes hold
+	ipConflictPolicy, err := metadata.ParseIPConflictPolicy(
+		getEnvOrDefault("IP_CONFLICT_POLICY", metadata.ConflictRefuse))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP_CONFLICT_POLICY: %w", err)
+	}
+	handler.IPConflictPolicy = ipConflictPolicy
+
+	// Serve nodes from a directory instead of Ironic, if configured
+	if staticDir := getEnvOrDefault("STATIC_METADATA_DIR", ""); staticDir != "" {
+		log.Info().
+			Str("dir", staticDir).
+			Msg("Serving nodes from a static directory instead of Ironic")
+		handler.Nodes = client.NewStaticSource(staticDir)
+	}
+
+	// Bound each of the per-node and download caches, which evict their
+	// least recently used entries
+	cacheLimits, err := createCacheLimits()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache limits: %w", err)
+	}
+	handler.CacheLimits = cacheLimits
+
+	// Configure downloads of configdrives stored in object storage
+	cacheTTL, err := time.ParseDuration(getEnvOrDefault("CONFIGDRIVE_CACHE_TTL", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONFIGDRIVE_CACHE_TTL: %w", err)
+	}
+	downloader := remote.NewDownloader(swiftClient, handler.SwiftTempURLKey, createS3Config())
+	handler.ConfigDrives = configdrive.NewFetcher(downloader, cacheTTL)
+	handler.ConfigDrives.SetLimits(cacheLimits)
+
+	// Configure downloads of user data stored in object storage
+	userDataCacheTTL, err := time.ParseDuration(getEnvOrDefault("USERDATA_CACHE_TTL", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid USERDATA_CACHE_TTL: %w", err)
+	}
+	handler.RemoteUserData = remote.NewFetcher(downloader, userDataCacheTTL)
+	handler.RemoteUserData.SetLimits(cacheLimits)
+
+	// Bound the size of served user data
+	maxUserDataSize, err := strconv.ParseInt(
+		getEnvOrDefault("USERDATA_MAX_SIZE", strconv.Itoa(blob.DefaultLimit)), 10, 64)
+	if err != nil || maxUserDataSize <= 0 {
+		return nil, fmt.Errorf("invalid USERDATA_MAX_SIZE %q",
+			getEnvOrDefault("USERDATA_MAX_SIZE", ""))
+	}
+	handler.MaxUserDataSize = maxUserDataSize
+
+	// Configure the Vault client resolving secret references
+	if vaultAddr := getEnvOrDefault("VAULT_ADDR", ""); vaultAddr != "" {
+		vaultClient, err := createVaultClient(vaultAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Vault client for %s: %w", vaultAddr, err)
+		}
+		handler.Vault = vaultClient
+		log.Info().
+			Str("vault_addr", vaultAddr).
+			Str("auth_method", getEnvOrDefault("VAULT_AUTH_METHOD", vault.AuthToken)).
+			Msg("Resolving secret references from Vault")
+	}
+
+	// Configure user data lookups in Kubernetes Secrets and ConfigMaps
+	if getEnvOrDefault("KUBERNETES_USERDATA", "false") == "true" {
+		source, err := createKubernetesUserDataSource()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure Kubernetes user data: %w", err)
+		}
+		handler.KubernetesUserData = source
+	}
+
+	// Configure dynamic vendor data targets
+	if targets := getEnvOrDefault("VENDORDATA_DYNAMIC_TARGETS", ""); targets != "" {
+		vendorData, err := createVendorDataClient(targets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure dynamic vendor data: %w", err)
+		}
+		handler.VendorData = vendorData
+	}
+
+	// Match the hostnames of clients in reverse DNS to node names, if
+	// configured
+	if getEnvOrDefault("REVERSE_DNS", "false") == "true" {
+		timeout, err := time.ParseDuration(getEnvOrDefault("REVERSE_DNS_TIMEOUT",
+			metadata.DefaultReverseDNSTimeout.String()))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid REVERSE_DNS_TIMEOUT %q",
+				getEnvOrDefault("REVERSE_DNS_TIMEOUT", ""))
+		}
+		reverseDNS := &metadata.ReverseDNS{Timeout: timeout}
+		for _, suffix := range strings.Split(getEnvOrDefault("REVERSE_DNS_SUFFIXES", ""), ",") {
+			if suffix = strings.TrimSpace(suffix); suffix != "" {
+				reverseDNS.Suffixes = append(reverseDNS.Suffixes, suffix)
+			}
+		}
+		handler.ReverseDNS = reverseDNS
+	}
+
+	return handler, nil
+}
+
+// commandHandler returns the handler of the commands rendering documents
+// outside the service, with the Ironic and object storage clients of the
+// service and the configuration of handlerFromEnv.
+func commandHandler() (*metadata.Handler, error) {
+	transport, err := createIronicTransport()
+	if err != nil {
+		return nil, err
+	}
+	ironicClient, err := createIronicClient(
+		getEnvOrDefault("IRONIC_URL", "http://localhost:6385"), transport)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordIronic(ironicClient); err != nil {
+		return nil, err
+	}
+	swiftClient := createSwiftClient(ironicClient)
+
+	clients, err := client.NewClients(client.Options{Ironic: ironicClient, Swift: swiftClient})
+	if err != nil {
+		return nil, err
+	}
+	return handlerFromEnv(clients, swiftClient)
+}
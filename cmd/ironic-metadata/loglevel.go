@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+
+	"github.com/appkins-org/ironic-metadata/pkg/logging"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// watchDebugSignal switches to debug logging for the revert duration of
+// level whenever the debug signal is received, and back to the base level
+// when it is received again before then.
+func watchDebugSignal(level *logging.Level) {
+	if debugSignal == nil {
+		return
+	}
+	debug := make(chan os.Signal, 1)
+	signal.Notify(debug, debugSignal)
+
+	go func() {
+		for range debug {
+			if level.Temporary() {
+				level.Reset()
+				log.Info().
+					Str("log_level", level.Status().Level).
+					Msg("Reverted log level")
+				continue
+			}
+			level.Set(zerolog.DebugLevel, level.RevertAfter())
+			status := level.Status()
+			event := log.Info().
+				Str("log_level", status.Level).
+				Str("base_log_level", status.Base)
+			if status.RevertAt != nil {
+				event = event.Time("revert_at", *status.RevertAt)
+			}
+			event.Msg("Changed log level")
+		}
+	}()
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// debugSignal toggles temporary debug logging.
+var debugSignal os.Signal = syscall.SIGUSR1
@@ -0,0 +1,6 @@
+package main
+
+import "os"
+
+// debugSignal is nil on Windows, which has no user signals.
+var debugSignal os.Signal
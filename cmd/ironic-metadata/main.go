@@ -2,23 +2,93 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
 	"net/http"
-	"net/netip"
 	"os"
-	"os/signal"
-	"syscall"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/appkins-org/ironic-metadata/api/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/audit"
+	"github.com/appkins-org/ironic-metadata/pkg/claim"
 	"github.com/appkins-org/ironic-metadata/pkg/client"
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+	"github.com/appkins-org/ironic-metadata/pkg/jws"
+	"github.com/appkins-org/ironic-metadata/pkg/kube"
+	"github.com/appkins-org/ironic-metadata/pkg/leader"
+	"github.com/appkins-org/ironic-metadata/pkg/listen"
+	"github.com/appkins-org/ironic-metadata/pkg/logging"
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/identity"
+	"github.com/appkins-org/ironic-metadata/pkg/nats"
+	"github.com/appkins-org/ironic-metadata/pkg/opa"
+	"github.com/appkins-org/ironic-metadata/pkg/plugins"
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/appkins-org/ironic-metadata/pkg/vault"
+	"github.com/appkins-org/ironic-metadata/pkg/vendordata"
+	"github.com/appkins-org/ironic-metadata/pkg/webhook"
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
+	// Commands other than serve write their results to stdout, so their logs
+	// go to stderr instead.
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	if command == "serve" {
+		configureLogging(os.Stdout)
+	} else {
+		configureLogging(os.Stderr)
+	}
+
+	switch command {
+	case "serve":
+		runServer()
+	case "render":
+		if err := runRender(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to render network configuration")
+		}
+	case "configdrive":
+		if err := runConfigDrive(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to build configdrive")
+		}
+	case "dump":
+		if err := runDump(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to dump node metadata")
+		}
+	case "verify":
+		if err := runVerify(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to verify document")
+		}
+	case "check":
+		if err := runCheck(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Configuration check failed")
+		}
+	default:
+		fmt.Fprintf(os.Stderr,
+			"unknown command %q\n\nUsage: %s [serve|render|configdrive|dump|verify|check]\n",
+			command, os.Args[0])
+		os.Exit(2)
+	}
+}
+
+// configureLogging sets up the global logger from the environment, writing
+// log output to w.
+func configureLogging(w io.Writer) {
 	// Configure logging
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
@@ -35,29 +105,151 @@ func main() {
 	zerolog.SetGlobalLevel(level)
 
 	// Configure log output based on environment
-	// In Docker/production, use JSON format to stdout
-	// In development, use console format to stderr
+	// In Docker/production, use JSON format
+	// In development, use console format
 	logFormat := getEnvOrDefault("LOG_FORMAT", "auto")
-
-	switch logFormat {
-	case "json":
-		// JSON format for structured logging (good for production)
-		log.Logger = log.Output(os.Stdout)
-	case "console":
-		// Console format for human-readable output (good for development)
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
-	case "auto":
+	jsonOutput := logFormat == "json"
+	if logFormat == "auto" {
 		// Auto-detect: use JSON in Docker, console otherwise
-		if os.Getenv("DOCKER_CONTAINER") == "true" || os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
-			log.Logger = log.Output(os.Stdout)
+		jsonOutput = os.Getenv("DOCKER_CONTAINER") == "true" ||
+			os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+	}
+
+	// Select the logging backend writing to w, which is either zerolog or
+	// the standard library's slog handlers
+	var stream io.Writer
+	backend := getEnvOrDefault("LOG_BACKEND", "zerolog")
+	switch backend {
+	case "slog":
+		opts := &slog.HandlerOptions{Level: logging.SlogLevel(level)}
+		var handler slog.Handler = slog.NewTextHandler(w, opts)
+		if jsonOutput {
+			handler = slog.NewJSONHandler(w, opts)
+		}
+		stream = logging.NewSlogWriter(handler)
+	default:
+		if jsonOutput {
+			// JSON format for structured logging (good for production)
+			stream = w
 		} else {
-			log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
+			// Console format for human-readable output (good for development)
+			stream = zerolog.ConsoleWriter{Out: w}
 		}
+	}
+
+	// Write to the sinks set in LOG_OUTPUTS, and route slog output such as
+	// that of the Ironic client to the same sinks
+	sinks, err := logSinks(stream, jsonOutput)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed to configure log outputs")
+	}
+	log.Logger = log.Output(zerolog.MultiLevelWriter(sinks...))
+	slog.SetDefault(slog.New(logging.NewZerologHandler(log.Logger)))
+
+	if backend != "zerolog" && backend != "slog" {
+		log.Warn().
+			Str("invalid_backend", backend).
+			Str("default_backend", "zerolog").
+			Msg("Invalid log backend, using default")
+	}
+}
+
+// logSinks returns the log sinks set in LOG_OUTPUTS, a comma separated list
+// of stdout for stream, file, syslog and journald. Files are written in the
+// format of stream, and syslog and journald receive structured events.
+func logSinks(stream io.Writer, jsonOutput bool) ([]io.Writer, error) {
+	var sinks []io.Writer
+	for _, output := range strings.Split(getEnvOrDefault("LOG_OUTPUTS", "stdout"), ",") {
+		switch output = strings.TrimSpace(output); output {
+		case "stdout":
+			sinks = append(sinks, stream)
+		case "file":
+			file, err := createLogFile("LOG_FILE")
+			if err != nil {
+				return nil, err
+			}
+			if jsonOutput {
+				sinks = append(sinks, file)
+			} else {
+				sinks = append(sinks, zerolog.ConsoleWriter{Out: file, NoColor: true})
+			}
+		case "syslog":
+			addr := getEnvOrDefault("LOG_SYSLOG_ADDR", "")
+			w, err := logging.NewSyslogWriter(addr, "ironic-metadata")
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, w)
+		case "journald":
+			w, err := logging.NewJournaldWriter("", "ironic-metadata")
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, w)
+		default:
+			return nil, fmt.Errorf("unknown LOG_OUTPUTS entry %q", output)
+		}
+	}
+	return sinks, nil
+}
+
+// createLogFile opens the rotating log file configured by the environment
+// variable name and its _MAX_SIZE, _MAX_AGE and _MAX_BACKUPS variants.
+func createLogFile(name string) (*logging.RotatingFile, error) {
+	maxSize, err := strconv.ParseInt(
+		getEnvOrDefault(name+"_MAX_SIZE", strconv.Itoa(logging.DefaultMaxSize)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s_MAX_SIZE: %w", name, err)
+	}
+	maxAge, err := time.ParseDuration(getEnvOrDefault(name+"_MAX_AGE", "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s_MAX_AGE: %w", name, err)
+	}
+	maxBackups, err := strconv.Atoi(
+		getEnvOrDefault(name+"_MAX_BACKUPS", strconv.Itoa(logging.DefaultMaxBackups)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s_MAX_BACKUPS: %w", name, err)
+	}
+	return logging.NewRotatingFile(logging.FileConfig{
+		Path:       getEnvOrDefault(name, ""),
+		MaxSize:    maxSize,
+		MaxAge:     maxAge,
+		MaxBackups: maxBackups,
+	})
+}
+
+// createAccessLog returns an access log in format, written to the output set
+// in ACCESS_LOG_OUTPUT: stdout, stderr, file or syslog.
+func createAccessLog(format string) (*logging.AccessLog, error) {
+	var w io.Writer
+	switch output := getEnvOrDefault("ACCESS_LOG_OUTPUT", "stdout"); output {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	case "file":
+		file, err := createLogFile("ACCESS_LOG_FILE")
+		if err != nil {
+			return nil, err
+		}
+		w = file
+	case "syslog":
+		syslogWriter, err := logging.NewSyslogWriter(
+			getEnvOrDefault("LOG_SYSLOG_ADDR", ""), "ironic-metadata-access")
+		if err != nil {
+			return nil, err
+		}
+		w = syslogWriter
 	default:
-		// Default to console format to stdout
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
+		return nil, fmt.Errorf("unknown ACCESS_LOG_OUTPUT %q", output)
 	}
+	return logging.NewAccessLog(w, format)
+}
 
+// runServer starts the metadata HTTP server and blocks until it is shut down.
+func runServer() {
 	// Get configuration from environment variables
 	ironicURL := getEnvOrDefault("IRONIC_URL", "http://localhost:6385")
 	bindAddr := getEnvOrDefault("BIND_ADDR", "0.0.0.0")
@@ -67,64 +259,521 @@ func main() {
 		Str("ironic_url", ironicURL).
 		Str("bind_addr", bindAddr).
 		Str("bind_port", bindPort).
-		Str("log_level", logLevel).
+		Str("log_level", zerolog.GlobalLevel().String()).
 		Msg("Starting ironic-metadata service")
 
-	// Initialize Ironic client
-	ironicClient, err := createIronicClient(ironicURL)
+	// Initialize Ironic client, with a connection pool sized for boot storms
+	ironicTransport, err := createIronicTransport()
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid Ironic transport configuration")
+	}
+	ironicClient, err := createIronicClient(ironicURL, ironicTransport)
 	if err != nil {
 		log.Fatal().
 			Err(err).
 			Str("ironic_url", ironicURL).
 			Msg("Failed to create Ironic client")
 	}
+	if err := recordIronic(ironicClient); err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid IRONIC_RECORD_DIR or IRONIC_REPLAY_DIR")
+	}
 
 	log.Info().
 		Str("ironic_endpoint", ironicClient.Endpoint).
 		Msg("Successfully initialized Ironic client")
 
-	clients := &client.Clients{}
-	clients.SetIronicClient(ironicClient)
+	swiftClient := createSwiftClient(ironicClient)
+
+	// Wait for Ironic to come up on first use, if configured
+	waitTimeout, err := time.ParseDuration(getEnvOrDefault("IRONIC_WAIT_TIMEOUT", "0s"))
+	if err != nil || waitTimeout < 0 {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid IRONIC_WAIT_TIMEOUT")
+	}
+	pollInterval, err := time.ParseDuration(
+		getEnvOrDefault("IRONIC_POLL_INTERVAL", client.DefaultPollInterval.String()))
+	if err != nil || pollInterval <= 0 {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid IRONIC_POLL_INTERVAL")
+	}
+
+	// Bound each Ironic operation on its own, so one slow listing does not
+	// use up the time of the request it serves
+	var callTimeouts client.Options
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+		def   string
+	}{
+		{"IRONIC_LIST_NODES_TIMEOUT", &callTimeouts.ListNodesTimeout, "2m"},
+		{"IRONIC_GET_NODE_TIMEOUT", &callTimeouts.GetNodeTimeout, "10s"},
+		{"IRONIC_LIST_PORTS_TIMEOUT", &callTimeouts.ListPortsTimeout, "30s"},
+	} {
+		*setting.value, err = time.ParseDuration(getEnvOrDefault(setting.name, setting.def))
+		if err != nil || *setting.value < 0 {
+			log.Fatal().
+				Err(err).
+				Str("variable", setting.name).
+				Msg("Invalid Ironic call timeout")
+		}
+	}
+
+	// List only the nodes served, page by page, with server-side filters
+	nodeFilter, err := createNodeFilter()
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid Ironic node filter")
+	}
+
+	clients, err := client.NewClients(client.Options{
+		Ironic:           ironicClient,
+		NodeFilter:       nodeFilter,
+		Swift:            swiftClient,
+		WaitTimeout:      waitTimeout,
+		PollInterval:     pollInterval,
+		ListNodesTimeout: callTimeouts.ListNodesTimeout,
+		GetNodeTimeout:   callTimeouts.GetNodeTimeout,
+		ListPortsTimeout: callTimeouts.ListPortsTimeout,
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed to create Ironic clients")
+	}
+
+	// Create metadata handler, configured like the other commands, and add
+	// the options deciding how and to whom its documents are served
+	handler, err := handlerFromEnv(clients, swiftClient)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed to configure the metadata handler")
+	}
+	handler.GCE = getEnvOrDefault("GCE_METADATA", "false") == "true"
+	handler.AdminToken = getEnvOrDefault("ADMIN_TOKEN", "")
+	handler.CacheControl = cacheControlOverrides()
+	handler.BasePath = getEnvOrDefault("BASE_PATH", "")
+
+	// Write an access log, if configured
+	if format := getEnvOrDefault("ACCESS_LOG_FORMAT", ""); format != "" {
+		accessLog, err := createAccessLog(format)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed to configure access log")
+		}
+		handler.AccessLog = accessLog
+	}
+
+	// Allow raising the log level temporarily through the admin API and
+	// the debug signal
+	revertAfter, err := time.ParseDuration(
+		getEnvOrDefault("LOG_LEVEL_REVERT_AFTER", logging.DefaultRevertAfter.String()))
+	if err != nil || revertAfter <= 0 {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid LOG_LEVEL_REVERT_AFTER")
+	}
+	handler.LogLevel = logging.NewLevel(zerolog.GlobalLevel(), revertAfter)
+	watchDebugSignal(handler.LogLevel)
+
+	// Disable route families the deployment does not need
+	disabledRoutes, err := metadata.ParseRouteFamilies(getEnvOrDefault("DISABLED_ROUTES", ""))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid DISABLED_ROUTES")
+	}
+	handler.DisabledRoutes = disabledRoutes
+
+	// Believe the forwarding headers of trusted reverse proxies only
+	trustedProxies, err := metadata.ParseTrustedProxies(getEnvOrDefault("TRUSTED_PROXIES", ""))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid TRUSTED_PROXIES")
+	}
+	handler.TrustedProxies = trustedProxies
+
+	// Let trusted consumers select the node of their requests
+	nodeHeaderTrust, err := metadata.ParseNodeHeaderTrust(getEnvOrDefault("NODE_HEADER_TRUST", ""))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid NODE_HEADER_TRUST")
+	}
+	handler.NodeHeaderTrust = nodeHeaderTrust
+
+	// Let any request select its node with ?node=, for development only
+	if getEnvOrDefault("DEBUG_OVERRIDES", "false") == "true" {
+		handler.DebugOverrides = true
+		log.Warn().
+			Msg("DEBUG_OVERRIDES is enabled, any client can read the metadata of any node")
+	}
+
+	// Serve the metadata of nodes in the configured provision states only
+	if spec := getEnvOrDefault("SERVED_PROVISION_STATES", ""); spec != "" {
+		servedStates, err := metadata.ParseProvisionStates(spec)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Invalid SERVED_PROVISION_STATES")
+		}
+		handler.ServedProvisionStates = servedStates
+	}
+	goneStates, err := metadata.ParseProvisionStates(getEnvOrDefault("GONE_PROVISION_STATES", ""))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid GONE_PROVISION_STATES")
+	}
+	handler.GoneProvisionStates = goneStates
+	// Serve the nodes of the configured projects only
+	if spec := getEnvOrDefault("SERVED_PROJECTS", ""); spec != "" {
+		servedProjects, err := parseServedProjects(spec, ironicClient)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Invalid SERVED_PROJECTS")
+		}
+		handler.ServedProjects = servedProjects
+		log.Info().
+			Strs("projects", slices.Sorted(maps.Keys(servedProjects))).
+			Msg("Serving the nodes of the configured projects only")
+	}
+	handler.RejectMaintenance = getEnvOrDefault("REJECT_MAINTENANCE_NODES", "false") == "true"
+
+	// Stop serving user data, or every document, of nodes active for long
+	activeExpiry, err := time.ParseDuration(getEnvOrDefault("ACTIVE_EXPIRY", "0s"))
+	if err != nil || activeExpiry < 0 {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid ACTIVE_EXPIRY")
+	}
+	activeExpiryScope, err := metadata.ParseExpiryScope(getEnvOrDefault("ACTIVE_EXPIRY_SCOPE", ""))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid ACTIVE_EXPIRY_SCOPE")
+	}
+	handler.ActiveExpiry = activeExpiry
+	handler.ActiveExpiryScope = activeExpiryScope
+
+	// Check rendered documents against their schemas
+	responseValidation, err := metadata.ParseResponseValidation(
+		getEnvOrDefault("RESPONSE_VALIDATION", metadata.ValidationOff))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid RESPONSE_VALIDATION")
+	}
+	handler.ResponseValidation = responseValidation
+
+	// Inject faults into metadata responses, for testing how clients cope
+	faultDelay, err := time.ParseDuration(
+		getEnvOrDefault("FAULT_DELAY", metadata.DefaultFaultDelay.String()))
+	if err != nil || faultDelay < 0 {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid FAULT_DELAY")
+	}
+	faults, err := metadata.ParseFaults(os.Getenv("FAULT_INJECTION"), faultDelay)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid FAULT_INJECTION")
+	}
+	if faults != nil {
+		log.Warn().
+			Str("faults", os.Getenv("FAULT_INJECTION")).
+			Msg("Fault injection is enabled, metadata responses fail on purpose")
+	}
+	handler.Faults = faults
+
+	// Cache the node and port inventory, so resolving a client does not
+	// list every node from Ironic
+	nodeCacheTTL, err := time.ParseDuration(getEnvOrDefault("NODE_CACHE_TTL", "30s"))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid NODE_CACHE_TTL")
+	}
+	// Fetch the details of many nodes concurrently
+	detailWorkers, err := strconv.Atoi(
+		getEnvOrDefault("NODE_DETAIL_WORKERS", strconv.Itoa(client.DefaultDetailWorkers)))
+	if err != nil || detailWorkers < 0 {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid NODE_DETAIL_WORKERS")
+	}
+	clients.SetDetailWorkers(detailWorkers)
+	// Bound the inventory held, for small provisioning hosts
+	nodeCacheMaxEntries, err := strconv.Atoi(getEnvOrDefault("NODE_CACHE_MAX_ENTRIES", "0"))
+	if err != nil || nodeCacheMaxEntries < 0 {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid NODE_CACHE_MAX_ENTRIES")
+	}
+	// Cache the nodes of the static directory, if configured, instead of Ironic
+	var source client.NodeSource = clients
+	if handler.Nodes != nil {
+		source = handler.Nodes
+	}
+	if nodeCacheTTL > 0 {
+		cached := client.NewCachedSource(source, nodeCacheTTL)
+		cached.SetMaxEntries(nodeCacheMaxEntries)
+		handler.Nodes = cached
+	}
+
+	// Load the instance identity signing key, if configured
+	if keyFile := getEnvOrDefault("IDENTITY_KEY_FILE", ""); keyFile != "" {
+		certFile := getEnvOrDefault("IDENTITY_CERT_FILE", "")
+		signer, err := identity.LoadSigner(keyFile, certFile)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Str("key_file", keyFile).
+				Str("cert_file", certFile).
+				Msg("Failed to load instance identity signer")
+		}
+		handler.Identity = signer
+		log.Info().
+			Str("key_file", keyFile).
+			Bool("pkcs7", signer.HasCertificate()).
+			Msg("Loaded instance identity signer")
+	}
+
+	// Load the JWS signing key, if configured
+	if keyFile := getEnvOrDefault("JWS_KEY_FILE", ""); keyFile != "" {
+		key, err := identity.LoadKey(keyFile)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Str("key_file", keyFile).
+				Msg("Failed to load JWS signing key")
+		}
+		signer, err := jws.NewSigner(key)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Str("key_file", keyFile).
+				Msg("Failed to create JWS signer")
+		}
+		handler.JWS = signer
+		log.Info().
+			Str("key_file", keyFile).
+			Str("key_id", signer.KeyID()).
+			Msg("Signing metadata responses")
+	}
+
+	// Ask an OPA policy for a decision on every response served for a node
+	if opaURL := getEnvOrDefault("OPA_URL", ""); opaURL != "" {
+		opaTimeout, err := time.ParseDuration(getEnvOrDefault("OPA_TIMEOUT",
+			opa.DefaultTimeout.String()))
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Invalid OPA_TIMEOUT")
+		}
+		policy, err := opa.New(opa.Config{
+			Address: opaURL,
+			Path:    getEnvOrDefault("OPA_POLICY_PATH", opa.DefaultPath),
+			Token:   getEnvOrDefault("OPA_TOKEN", ""),
+			Timeout: opaTimeout,
+		})
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed to create OPA client")
+		}
+		handler.Policy = policy
+		log.Info().
+			Str("opa_url", opaURL).
+			Str("policy_path", getEnvOrDefault("OPA_POLICY_PATH", opa.DefaultPath)).
+			Msg("Asking OPA for decisions on metadata responses")
+	}
+
+	// Hand rendered metadata to a mutation hook before it is served
+	if getEnvOrDefault("MUTATION_HOOK_COMMAND", "") != "" ||
+		getEnvOrDefault("MUTATION_HOOK_URL", "") != "" {
+		mutationHook, err := createMutationHook()
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed to create mutation hook")
+		}
+		handler.MutationHook = mutationHook
+	}
+
+	// Start the components running beside the listeners, stopped on
+	// shutdown in the reverse order of their start
+	components, err := startComponents(handler, ironicClient, swiftClient)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed to start the background components")
+	}
+
+	if handler.MutationHook != nil || handler.Plugins != nil {
+		failure, err := metadata.ParseHookFailurePolicy(
+			getEnvOrDefault("MUTATION_HOOK_FAILURE_POLICY", ""))
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Invalid MUTATION_HOOK_FAILURE_POLICY")
+		}
+		endpoints := metadata.ParseHookEndpoints(getEnvOrDefault("MUTATION_HOOK_ENDPOINTS", ""))
+		handler.MutationHookEndpoints = endpoints
+		handler.MutationHookFailure = failure
+		log.Info().
+			Str("endpoints", getEnvOrDefault("MUTATION_HOOK_ENDPOINTS", "all")).
+			Str("failure_policy", failure).
+			Bool("hook", handler.MutationHook != nil).
+			Bool("plugins", handler.Plugins != nil).
+			Msg("Handing metadata responses to the mutation hook")
+	}
+
+	// Select the node named by the Host header of requests, if configured
+	for _, suffix := range strings.Split(getEnvOrDefault("VIRTUAL_HOST_SUFFIXES", ""), ",") {
+		if suffix = strings.TrimSpace(suffix); suffix != "" {
+			handler.VirtualHostSuffixes = append(handler.VirtualHostSuffixes, suffix)
+		}
+	}
+
+	// Warm the caches while the listeners start, failing readiness until
+	// they are
+	warmTimeout, err := time.ParseDuration(getEnvOrDefault("CACHE_WARM_TIMEOUT", "1m"))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid CACHE_WARM_TIMEOUT")
+	}
+	if warmTimeout > 0 {
+		handler.StartWarm(context.Background(), warmTimeout)
+	}
+
+	// Route the clients of other provisioning domains to their own Ironic,
+	// if configured
+	var routes http.Handler
+	watched := []*client.Clients{clients}
+	if path := getEnvOrDefault("IRONIC_BACKENDS_FILE", ""); path == "" {
+		routes = handler.Routes()
+	} else {
+		configs, err := loadBackends(path)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Invalid IRONIC_BACKENDS_FILE")
+		}
+		backends, err := createBackends(handler, configs, ironicTransport, client.Options{
+			WaitTimeout:      waitTimeout,
+			PollInterval:     pollInterval,
+			DetailWorkers:    detailWorkers,
+			NodeFilter:       nodeFilter,
+			ListNodesTimeout: callTimeouts.ListNodesTimeout,
+			GetNodeTimeout:   callTimeouts.GetNodeTimeout,
+			ListPortsTimeout: callTimeouts.ListPortsTimeout,
+		}, nodeCacheTTL, nodeCacheMaxEntries)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed to configure Ironic backends")
+		}
+		router, err := metadata.NewBackendRouter(handler, backends...)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Invalid IRONIC_BACKENDS_FILE")
+		}
+		for _, backend := range backends {
+			if warmTimeout > 0 {
+				backend.Handler.StartWarm(context.Background(), warmTimeout)
+			}
+			watched = append(watched, backend.Handler.Clients)
+		}
+		routes = router
+	}
+
+	// Re-probe an Ironic found down in the background, since readiness
+	// fails while it is and no requests would probe it, if waiting for it
+	// is configured
+	if waitTimeout > 0 {
+		for _, clients := range watched {
+			components.start(clients.Watch)
+		}
+	}
 
-	// Create metadata handler
-	handler := &metadata.Handler{
-		Clients: clients,
+	// Parse the HTTP versions to serve
+	protocols, http2Config, err := parseProtocols()
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid HTTP/2 configuration")
 	}
 
-	// Parse bind address
-	addr, err := netip.ParseAddrPort(fmt.Sprintf("%s:%s", bindAddr, bindPort))
+	// Parse the addresses to listen on
+	listeners, err := parseListeners(bindAddr, bindPort, protocols)
 	if err != nil {
 		log.Fatal().
 			Err(err).
-			Str("bind_addr", bindAddr).
-			Str("bind_port", bindPort).
-			Msg("Failed to parse bind address")
+			Msg("Failed to parse listen addresses")
 	}
 
 	// Create HTTP server
 	server := &http.Server{
-		Handler:      handler.Routes(),
+		Handler:      routes,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		Protocols:    protocols,
+		HTTP2:        http2Config,
 	}
 
-	// Start server in a goroutine
-	go func() {
-		log.Info().Str("address", addr.String()).Msg("Starting HTTP server")
-		if err := metadata.ListenAndServe(context.Background(), addr, server); err != nil &&
-			err != http.ErrServerClosed {
+	// Claim the metadata address on this host, if configured
+	var metadataClaim *claim.Claim
+	if getEnvOrDefault("CLAIM_METADATA_ADDRESS", "false") == "true" {
+		metadataClaim, err = claimMetadataAddress(listeners[0].Addr.Port())
+		if err != nil {
 			log.Fatal().
 				Err(err).
-				Str("address", addr.String()).
-				Msg("Failed to start server")
+				Msg("Failed to claim metadata address")
 		}
-	}()
+	}
+
+	// Open the listeners, taking over those of a process being upgraded
+	listenerSet, err := listen.Open(context.Background(), listeners)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed to start server")
+	}
+	for i, ln := range listenerSet.Listeners() {
+		address := listeners[i].String()
+		go func() {
+			log.Info().Str("address", address).Msg("Starting HTTP server")
+			if err := metadata.Serve(context.Background(), ln, server); err != nil &&
+				err != http.ErrServerClosed {
+				log.Fatal().
+					Err(err).
+					Str("address", address).
+					Msg("Failed to serve")
+			}
+		}()
+	}
+	if listenerSet.Inherited() {
+		takeOver()
+	}
+	notifySystemd(fmt.Sprintf("MAINPID=%d\nREADY=1", os.Getpid()))
 
 	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-quit
+	sig, handedOff := waitForShutdown(listenerSet)
 	log.Info().
 		Str("signal", sig.String()).
 		Msg("Received shutdown signal, shutting down server...")
@@ -140,6 +789,20 @@ func main() {
 			Msg("Server forced to shutdown")
 	}
 
+	// Stop the Ironic watches, then the components in the reverse order of
+	// startComponents, letting another replica take over write-back
+	// features once the event sinks have stopped
+	components.stop(ctx)
+
+	// Leave the claim to the process the listeners were handed over to
+	if metadataClaim != nil && !handedOff {
+		if err := metadataClaim.Release(ctx); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Failed to release metadata address")
+		}
+	}
+
 	log.Info().Msg("Server exited gracefully")
 }
 
@@ -150,11 +813,691 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-func createIronicClient(ironicURL string) (*gophercloud.ServiceClient, error) {
+// createSwiftClient returns an object storage client sharing the Ironic
+// client's authentication, or nil when running without authentication or
+// when the cloud has no object storage service.
+func createSwiftClient(ironicClient *gophercloud.ServiceClient) *gophercloud.ServiceClient {
+	if getEnvOrDefault("OS_USERNAME", "") == "" {
+		return nil
+	}
+
+	client, err := openstack.NewObjectStorageV1(ironicClient.ProviderClient, gophercloud.EndpointOpts{
+		Region: getEnvOrDefault("OS_REGION_NAME", ""),
+	})
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Object storage unavailable, swift:// configdrives will not be fetched")
+		return nil
+	}
+
 	log.Debug().
-		Str("ironic_url", ironicURL).
-		Msg("Creating Ironic client")
+		Str("endpoint", client.Endpoint).
+		Msg("Created object storage client")
+
+	return client
+}
+
+// createS3Config returns the credentials for s3:// references, or nil when
+// none are configured.
+func createS3Config() *remote.S3Config {
+	accessKeyID := getEnvOrDefault("AWS_ACCESS_KEY_ID", "")
+	if accessKeyID == "" {
+		return nil
+	}
+	return &remote.S3Config{
+		Endpoint:        getEnvOrDefault("S3_ENDPOINT", ""),
+		Region:          getEnvOrDefault("AWS_REGION", ""),
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: getEnvOrDefault("AWS_SECRET_ACCESS_KEY", ""),
+	}
+}
+
+// createVaultClient returns a Vault client configured from the VAULT_*
+// environment variables.
+func createVaultClient(addr string) (*vault.Client, error) {
+	cacheTTL, err := time.ParseDuration(getEnvOrDefault("VAULT_CACHE_TTL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAULT_CACHE_TTL: %w", err)
+	}
+
+	return vault.New(vault.Config{
+		Address:    addr,
+		Namespace:  getEnvOrDefault("VAULT_NAMESPACE", ""),
+		AuthMethod: getEnvOrDefault("VAULT_AUTH_METHOD", vault.AuthToken),
+		AuthMount:  getEnvOrDefault("VAULT_AUTH_MOUNT", ""),
+		Token:      getEnvOrDefault("VAULT_TOKEN", ""),
+		RoleID:     getEnvOrDefault("VAULT_ROLE_ID", ""),
+		SecretID:   getEnvOrDefault("VAULT_SECRET_ID", ""),
+		Role:       getEnvOrDefault("VAULT_ROLE", ""),
+		TokenPath:  getEnvOrDefault("VAULT_K8S_TOKEN_PATH", ""),
+		CacheTTL:   cacheTTL,
+	})
+}
+
+// createKubernetesUserDataSource returns a user data source reading from
+// the cluster the service runs in, configured from the KUBERNETES_*
+// environment variables.
+func createKubernetesUserDataSource() (*kube.UserDataSource, error) {
+	cacheTTL, err := time.ParseDuration(getEnvOrDefault("KUBERNETES_CACHE_TTL", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KUBERNETES_CACHE_TTL: %w", err)
+	}
+
+	cfg, err := kube.InClusterConfig(getEnvOrDefault("KUBERNETES_NAMESPACE", ""))
+	if err != nil {
+		return nil, err
+	}
+	client, err := kube.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := getEnvOrDefault("KUBERNETES_USERDATA_NAME", kube.NodePlaceholder)
+	defaultName := getEnvOrDefault("KUBERNETES_USERDATA_DEFAULT", "")
+	log.Info().
+		Str("namespace", client.Namespace()).
+		Str("name", pattern).
+		Str("default", defaultName).
+		Msg("Looking up user data in Kubernetes")
+
+	return kube.NewUserDataSource(client, pattern, defaultName, cacheTTL), nil
+}
+
+// createLeaderElector returns an elector using the kubernetes or file
+// backend, configured from the LEADER_ELECTION_* environment variables.
+func createLeaderElector(backend string) (*leader.Elector, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	identity := getEnvOrDefault("LEADER_ELECTION_IDENTITY", hostname)
+	retryPeriod, err := time.ParseDuration(
+		getEnvOrDefault("LEADER_ELECTION_RETRY_PERIOD", leader.DefaultRetryPeriod.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LEADER_ELECTION_RETRY_PERIOD: %w", err)
+	}
+
+	var lock leader.Lock
+	logger := log.Info().Str("backend", backend).Str("identity", identity)
+	switch backend {
+	case "kubernetes":
+		duration, err := time.ParseDuration(getEnvOrDefault(
+			"LEADER_ELECTION_LEASE_DURATION", leader.DefaultLeaseDuration.String()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid LEADER_ELECTION_LEASE_DURATION: %w", err)
+		}
+		if duration < 2*retryPeriod {
+			return nil, errors.New("LEADER_ELECTION_LEASE_DURATION must be at least " +
+				"twice LEADER_ELECTION_RETRY_PERIOD")
+		}
+		cfg, err := kube.InClusterConfig(getEnvOrDefault("LEADER_ELECTION_NAMESPACE", ""))
+		if err != nil {
+			return nil, err
+		}
+		client, err := kube.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		name := getEnvOrDefault("LEADER_ELECTION_LEASE", "ironic-metadata")
+		lock = leader.NewLeaseLock(client, name, identity, duration)
+		logger = logger.Str("namespace", client.Namespace()).Str("lease", name)
+	case "file":
+		path := getEnvOrDefault("LEADER_ELECTION_LOCK_FILE", "")
+		if path == "" {
+			return nil, errors.New("LEADER_ELECTION_LOCK_FILE is required")
+		}
+		lock = leader.NewFileLock(path)
+		logger = logger.Str("lock_file", path)
+	default:
+		return nil, fmt.Errorf("unknown LEADER_ELECTION backend %q", backend)
+	}
+	logger.Msg("Electing the replica performing write-back features")
+
+	return leader.New(leader.Config{
+		Lock:        lock,
+		Identity:    identity,
+		RetryPeriod: retryPeriod,
+		OnChange: func(isLeader bool) {
+			if isLeader {
+				log.Info().Str("identity", identity).Msg("Became leader")
+				return
+			}
+			log.Info().Str("identity", identity).Msg("No longer leader")
+		},
+		OnError: func(err error) {
+			log.Error().
+				Err(err).
+				Str("identity", identity).
+				Msg("Leader election failed")
+		},
+	})
+}
+
+// createWebhookNotifier creates the notifier of the webhooks of spec, a
+// comma separated list of URLs.
+func createWebhookNotifier(spec string) (*webhook.Notifier, error) {
+	urls, err := webhook.ParseURLs(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_URLS: %w", err)
+	}
+	// Lookup failures are left out by default, as every unknown client
+	// sends them.
+	eventSpec := getEnvOrDefault("WEBHOOK_EVENTS", events.UserData+","+events.PhoneHome)
+	eventTypes, err := events.ParseTypes(eventSpec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_EVENTS: %w", err)
+	}
+	timeout, err := time.ParseDuration(getEnvOrDefault("WEBHOOK_TIMEOUT",
+		webhook.DefaultTimeout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT: %w", err)
+	}
+	retries, err := strconv.Atoi(getEnvOrDefault("WEBHOOK_RETRIES",
+		strconv.Itoa(webhook.DefaultRetries)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRIES: %w", err)
+	}
+	queueSize, err := strconv.Atoi(getEnvOrDefault("WEBHOOK_QUEUE_SIZE",
+		strconv.Itoa(webhook.DefaultQueueSize)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_QUEUE_SIZE: %w", err)
+	}
+
+	log.Info().
+		Int("webhooks", len(urls)).
+		Str("events", eventSpec).
+		Bool("signed", getEnvOrDefault("WEBHOOK_SECRET", "") != "").
+		Msg("Sending events to webhooks")
+
+	return webhook.New(webhook.Config{
+		URLs:      urls,
+		Secret:    getEnvOrDefault("WEBHOOK_SECRET", ""),
+		Events:    eventTypes,
+		Timeout:   timeout,
+		Retries:   retries,
+		QueueSize: queueSize,
+		OnError: func(event events.Event, url string, err error) {
+			log.Warn().
+				Err(err).
+				Str("webhook", url).
+				Str("event", event.Type).
+				Str("node_uuid", event.NodeUUID).
+				Msg("Failed to deliver webhook event")
+		},
+	})
+}
+
+// createNATSPublisher creates the publisher of events to the NATS server at
+// natsURL.
+func createNATSPublisher(natsURL string) (*nats.Publisher, error) {
+	eventTypes, err := events.ParseTypes(getEnvOrDefault("NATS_EVENTS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS_EVENTS: %w", err)
+	}
+	timeout, err := time.ParseDuration(getEnvOrDefault("NATS_TIMEOUT",
+		nats.DefaultTimeout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS_TIMEOUT: %w", err)
+	}
+	publisher, err := nats.New(nats.Config{
+		URL:           natsURL,
+		SubjectPrefix: getEnvOrDefault("NATS_SUBJECT_PREFIX", nats.DefaultSubjectPrefix),
+		Events:        eventTypes,
+		Timeout:       timeout,
+		OnError: func(err error) {
+			log.Warn().
+				Err(err).
+				Msg("NATS event publishing interrupted")
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("subject_prefix", getEnvOrDefault("NATS_SUBJECT_PREFIX", nats.DefaultSubjectPrefix)).
+		Str("events", getEnvOrDefault("NATS_EVENTS", "all")).
+		Msg("Publishing events to NATS")
+	return publisher, nil
+}
+
+// createAuditExporter creates the exporter of the audit trail to the
+// s3:// or swift:// location of exportURL, snapshotting the mapping table
+// of handler.
+func createAuditExporter(
+	exportURL string,
+	swiftClient *gophercloud.ServiceClient,
+	handler *metadata.Handler,
+) (*audit.Exporter, error) {
+	location, err := audit.ParseURL(exportURL)
+	if err != nil {
+		return nil, err
+	}
+	var store audit.Store
+	switch location.Scheme {
+	case "s3":
+		s3Config := createS3Config()
+		if s3Config == nil {
+			return nil, errors.New("an s3:// audit export requires AWS_ACCESS_KEY_ID")
+		}
+		store = audit.NewS3Store(s3Config, location.Bucket)
+	case "swift":
+		if swiftClient == nil {
+			return nil, errors.New("a swift:// audit export requires object storage")
+		}
+		store = audit.NewSwiftStore(swiftClient, location.Bucket)
+	}
+
+	eventTypes, err := events.ParseTypes(getEnvOrDefault("AUDIT_EXPORT_EVENTS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUDIT_EXPORT_EVENTS: %w", err)
+	}
+	interval, err := time.ParseDuration(getEnvOrDefault("AUDIT_EXPORT_INTERVAL",
+		audit.DefaultInterval.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUDIT_EXPORT_INTERVAL: %w", err)
+	}
+	retention, err := time.ParseDuration(getEnvOrDefault("AUDIT_EXPORT_RETENTION", "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUDIT_EXPORT_RETENTION: %w", err)
+	}
+
+	var snapshot func(ctx context.Context) ([]any, error)
+	if getEnvOrDefault("AUDIT_EXPORT_MAPPINGS", "true") == "true" {
+		snapshot = func(ctx context.Context) ([]any, error) {
+			mappings, err := handler.MappingTable(ctx)
+			if err != nil {
+				return nil, err
+			}
+			records := make([]any, 0, len(mappings.Mappings))
+			for _, m := range mappings.Mappings {
+				records = append(records, m)
+			}
+			return records, nil
+		}
+	}
+	// Replicas exporting to the same location leave snapshots and pruning
+	// to the leader.
+	var isLeader func() bool
+	if handler.Leader != nil {
+		isLeader = handler.Leader.IsLeader
+	}
+
+	exporter, err := audit.New(audit.Config{
+		Store:     store,
+		Prefix:    location.Prefix,
+		Instance:  getEnvOrDefault("AUDIT_EXPORT_INSTANCE", ""),
+		Events:    eventTypes,
+		Interval:  interval,
+		Retention: retention,
+		Snapshot:  snapshot,
+		Leader:    isLeader,
+		OnError: func(err error) {
+			log.Warn().
+				Err(err).
+				Msg("Failed to export the audit trail")
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("url", exportURL).
+		Str("interval", interval.String()).
+		Str("retention", retention.String()).
+		Str("events", getEnvOrDefault("AUDIT_EXPORT_EVENTS", "all")).
+		Bool("mappings", snapshot != nil).
+		Msg("Exporting the audit trail to object storage")
+	return exporter, nil
+}
+
+// createMutationHook creates the mutation hook configured by
+// MUTATION_HOOK_COMMAND or MUTATION_HOOK_URL.
+func createMutationHook() (*hook.Hook, error) {
+	timeout, err := time.ParseDuration(getEnvOrDefault("MUTATION_HOOK_TIMEOUT",
+		hook.DefaultTimeout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MUTATION_HOOK_TIMEOUT: %w", err)
+	}
+	return hook.New(hook.Config{
+		Command: strings.Fields(getEnvOrDefault("MUTATION_HOOK_COMMAND", "")),
+		URL:     getEnvOrDefault("MUTATION_HOOK_URL", ""),
+		Token:   getEnvOrDefault("MUTATION_HOOK_TOKEN", ""),
+		Timeout: timeout,
+	})
+}
+
+// createPlugins loads the WASM plugins of dir, configured by the PLUGINS_*
+// environment variables.
+func createPlugins(dir string) (*plugins.Plugins, error) {
+	timeout, err := time.ParseDuration(getEnvOrDefault("PLUGINS_TIMEOUT",
+		plugins.DefaultTimeout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PLUGINS_TIMEOUT: %w", err)
+	}
+	interval, err := time.ParseDuration(getEnvOrDefault("PLUGINS_RELOAD_INTERVAL",
+		plugins.DefaultReloadInterval.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PLUGINS_RELOAD_INTERVAL: %w", err)
+	}
+	return plugins.New(context.Background(), plugins.Config{
+		Dir:            dir,
+		Timeout:        timeout,
+		ReloadInterval: interval,
+		OnLoad: func(name string, removed bool) {
+			if removed {
+				log.Info().Str("plugin", name).Msg("Unloaded removed WASM plugin")
+				return
+			}
+			log.Info().Str("plugin", name).Msg("Loaded WASM plugin")
+		},
+		OnError: func(name string, err error) {
+			if name == "" {
+				log.Error().
+					Err(err).
+					Str("dir", dir).
+					Msg("Failed to read WASM plugin directory")
+				return
+			}
+			log.Error().
+				Err(err).
+				Str("plugin", name).
+				Msg("Failed to load WASM plugin, keeping its previous version")
+		},
+	})
+}
+
+// createVendorDataClient returns a client for the dynamic vendor data
+// targets in Nova's <name>@<url> format, configured from the
+// VENDORDATA_DYNAMIC_* environment variables.
+func createVendorDataClient(spec string) (*vendordata.Client, error) {
+	targets, err := vendordata.ParseTargets(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VENDORDATA_DYNAMIC_TARGETS: %w", err)
+	}
+	timeout, err := time.ParseDuration(getEnvOrDefault("VENDORDATA_DYNAMIC_TIMEOUT", "5s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VENDORDATA_DYNAMIC_TIMEOUT: %w", err)
+	}
+	failureFatal := getEnvOrDefault("VENDORDATA_DYNAMIC_FAILURE_FATAL", "false") == "true"
+
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	log.Info().
+		Strs("targets", names).
+		Dur("timeout", timeout).
+		Bool("failure_fatal", failureFatal).
+		Msg("Fetching dynamic vendor data")
+
+	return vendordata.New(vendordata.Config{
+		Targets:      targets,
+		Timeout:      timeout,
+		FailureFatal: failureFatal,
+	}), nil
+}
+
+// cacheControlOverrides returns the Cache-Control headers of the cache
+// classes set through CACHE_CONTROL_<CLASS> environment variables.
+func cacheControlOverrides() map[string]string {
+	overrides := make(map[string]string)
+	for _, class := range metadata.CacheClasses {
+		if value, ok := os.LookupEnv("CACHE_CONTROL_" + strings.ToUpper(class)); ok {
+			overrides[class] = value
+		}
+	}
+	return overrides
+}
+
+// parseProtocols returns the HTTP versions the server serves: HTTP/1.1,
+// HTTP/2 over TLS unless HTTP2 is false, and HTTP/2 over cleartext
+// connections (h2c) with H2C set. HTTP2_MAX_CONCURRENT_STREAMS bounds the
+// requests a client may have in flight on one connection.
+func parseProtocols() (*http.Protocols, *http.HTTP2Config, error) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(getEnvOrDefault("HTTP2", "true") == "true")
+	protocols.SetUnencryptedHTTP2(getEnvOrDefault("H2C", "false") == "true")
+
+	maxStreams, err := strconv.Atoi(getEnvOrDefault("HTTP2_MAX_CONCURRENT_STREAMS", "0"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid HTTP2_MAX_CONCURRENT_STREAMS: %w", err)
+	}
+	if maxStreams < 0 {
+		return nil, nil, fmt.Errorf("invalid HTTP2_MAX_CONCURRENT_STREAMS: %d", maxStreams)
+	}
+	return protocols, &http.HTTP2Config{MaxConcurrentStreams: maxStreams}, nil
+}
+
+// parseListeners returns the listeners set in LISTEN_ADDRS, defaulting to
+// the single address BIND_ADDR and BIND_PORT. HTTPS listeners default to
+// the TLS_* files and to the BIND_INTERFACE interface, and offer HTTP/2 when
+// protocols include it. LISTEN_REUSEPORT sets SO_REUSEPORT on all of them.
+func parseListeners(
+	bindAddr, bindPort string,
+	protocols *http.Protocols,
+) ([]listen.Listener, error) {
+	nextProtos := []string{"http/1.1"}
+	if protocols.HTTP2() {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+	spec := getEnvOrDefault("LISTEN_ADDRS", net.JoinHostPort(bindAddr, bindPort))
+	listeners, err := listen.Parse(spec, listen.TLSConfig{
+		CertFile:     getEnvOrDefault("TLS_CERT_FILE", ""),
+		KeyFile:      getEnvOrDefault("TLS_KEY_FILE", ""),
+		ClientCAFile: getEnvOrDefault("TLS_CLIENT_CA_FILE", ""),
+		NextProtos:   nextProtos,
+	}, getEnvOrDefault("BIND_INTERFACE", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_ADDRS: %w", err)
+	}
+	reusePort := getEnvOrDefault("LISTEN_REUSEPORT", "false") == "true"
+	for i := range listeners {
+		listeners[i].ReusePort = reusePort
+	}
+	return listeners, nil
+}
 
+// claimMetadataAddress claims the metadata address, redirecting its HTTP
+// port to CLAIM_REDIRECT_PORT, which defaults to the port of the first
+// listener.
+func claimMetadataAddress(listenPort uint16) (*claim.Claim, error) {
+	redirectPort, err := strconv.ParseUint(
+		getEnvOrDefault("CLAIM_REDIRECT_PORT", strconv.Itoa(int(listenPort))), 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLAIM_REDIRECT_PORT: %w", err)
+	}
+	cfg := claim.Config{
+		Interface:    getEnvOrDefault("CLAIM_INTERFACE", claim.DefaultInterface),
+		RedirectPort: uint16(redirectPort),
+		Firewall:     getEnvOrDefault("CLAIM_FIREWALL", claim.FirewallAuto),
+	}
+	c, err := claim.New(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().
+		Str("interface", cfg.Interface).
+		Str("address", claim.DefaultAddress.String()).
+		Uint16("redirect_port", cfg.RedirectPort).
+		Str("firewall", c.Firewall()).
+		Msg("Claimed metadata address")
+	return c, nil
+}
+
+// createIronicTransport returns the HTTP transport of the Ironic API calls,
+// configured with the IRONIC_* transport environment variables.
+func createIronicTransport() (*http.Transport, error) {
+	var opts client.TransportOptions
+	ints := []struct {
+		name  string
+		value *int
+		def   int
+	}{
+		{"IRONIC_MAX_IDLE_CONNS", &opts.MaxIdleConns, client.DefaultMaxIdleConns},
+		{"IRONIC_MAX_IDLE_CONNS_PER_HOST", &opts.MaxIdleConnsPerHost,
+			client.DefaultMaxIdleConnsPerHost},
+		{"IRONIC_MAX_CONNS_PER_HOST", &opts.MaxConnsPerHost, 0},
+	}
+	for _, setting := range ints {
+		value, err := strconv.Atoi(getEnvOrDefault(setting.name, strconv.Itoa(setting.def)))
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid %s: %q", setting.name, os.Getenv(setting.name))
+		}
+		*setting.value = value
+	}
+	durations := []struct {
+		name  string
+		value *time.Duration
+		def   time.Duration
+	}{
+		{"IRONIC_IDLE_CONN_TIMEOUT", &opts.IdleConnTimeout, client.DefaultIdleConnTimeout},
+		{"IRONIC_DIAL_TIMEOUT", &opts.DialTimeout, client.DefaultDialTimeout},
+		{"IRONIC_TCP_KEEPALIVE", &opts.KeepAlive, client.DefaultKeepAlive},
+		{"IRONIC_TLS_HANDSHAKE_TIMEOUT", &opts.TLSHandshakeTimeout,
+			client.DefaultTLSHandshakeTimeout},
+		{"IRONIC_RESPONSE_HEADER_TIMEOUT", &opts.ResponseHeaderTimeout, 0},
+	}
+	for _, setting := range durations {
+		value, err := time.ParseDuration(getEnvOrDefault(setting.name, setting.def.String()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", setting.name, err)
+		}
+		*setting.value = value
+	}
+	opts.DisableKeepAlives = getEnvOrDefault("IRONIC_DISABLE_KEEPALIVES", "false") == "true"
+	return client.NewTransport(opts), nil
+}
+
+// createNodeFilter returns the server-side filter of the nodes listed from
+// Ironic, configured with the IRONIC_NODE_* environment variables and
+// IRONIC_PAGE_SIZE.
+func createNodeFilter() (client.NodeFilter, error) {
+	filter := client.NodeFilter{
+		Owner:          getEnvOrDefault("IRONIC_NODE_OWNER", ""),
+		ConductorGroup: getEnvOrDefault("IRONIC_NODE_CONDUCTOR_GROUP", ""),
+	}
+	states, err := metadata.ParseProvisionStates(
+		getEnvOrDefault("IRONIC_NODE_PROVISION_STATES", ""))
+	if err != nil {
+		return client.NodeFilter{}, fmt.Errorf("invalid IRONIC_NODE_PROVISION_STATES: %w", err)
+	}
+	filter.ProvisionStates = slices.Sorted(maps.Keys(states))
+	for _, field := range strings.Split(getEnvOrDefault("IRONIC_NODE_FIELDS", ""), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			filter.Fields = append(filter.Fields, field)
+		}
+	}
+	filter.PageSize, err = strconv.Atoi(getEnvOrDefault("IRONIC_PAGE_SIZE", "0"))
+	if err != nil || filter.PageSize < 0 {
+		return client.NodeFilter{}, fmt.Errorf("invalid IRONIC_PAGE_SIZE: %q",
+			os.Getenv("IRONIC_PAGE_SIZE"))
+	}
+	return filter, nil
+}
+
+// createCacheLimits returns the bound of each per-node and download cache,
+// configured with CACHE_MAX_ENTRIES and CACHE_MAX_BYTES.
+func createCacheLimits() (lru.Limits, error) {
+	maxEntries, err := strconv.Atoi(getEnvOrDefault("CACHE_MAX_ENTRIES", "0"))
+	if err != nil || maxEntries < 0 {
+		return lru.Limits{}, fmt.Errorf("invalid CACHE_MAX_ENTRIES: %q",
+			os.Getenv("CACHE_MAX_ENTRIES"))
+	}
+	maxBytes, err := strconv.ParseInt(getEnvOrDefault("CACHE_MAX_BYTES", "0"), 10, 64)
+	if err != nil || maxBytes < 0 {
+		return lru.Limits{}, fmt.Errorf("invalid CACHE_MAX_BYTES: %q",
+			os.Getenv("CACHE_MAX_BYTES"))
+	}
+	return lru.Limits{MaxEntries: maxEntries, MaxBytes: maxBytes}, nil
+}
+
+// recordIronic records the responses of the Ironic API of ironicClient to
+// IRONIC_RECORD_DIR, or serves them from the recordings in
+// IRONIC_REPLAY_DIR instead of calling Ironic.
+func recordIronic(ironicClient *gophercloud.ServiceClient) error {
+	recordDir := getEnvOrDefault("IRONIC_RECORD_DIR", "")
+	replayDir := getEnvOrDefault("IRONIC_REPLAY_DIR", "")
+	switch {
+	case recordDir != "" && replayDir != "":
+		return errors.New("cannot both record and replay the Ironic API")
+	case recordDir != "":
+		recorder, err := client.NewRecorder(ironicClient.Endpoint, recordDir,
+			ironicClient.HTTPClient.Transport)
+		if err != nil {
+			return err
+		}
+		recorder.OnError = func(err error) {
+			log.Warn().
+				Err(err).
+				Msg("Failed to record Ironic API response")
+		}
+		ironicClient.HTTPClient.Transport = recorder
+		log.Warn().
+			Str("dir", recordDir).
+			Msg("Recording Ironic API responses, which hold node data")
+	case replayDir != "":
+		ironicClient.HTTPClient.Transport = client.NewReplayer(ironicClient.Endpoint, replayDir)
+		log.Warn().
+			Str("dir", replayDir).
+			Msg("Replaying recorded Ironic API responses instead of calling Ironic")
+	}
+	return nil
+}
+
+// parseServedProjects parses SERVED_PROJECTS, a comma separated list of
+// project IDs, where keystone stands for the project of the token Ironic is
+// authenticated with.
+func parseServedProjects(
+	spec string,
+	ironicClient *gophercloud.ServiceClient,
+) (map[string]bool, error) {
+	var ids []string
+	for _, id := range strings.Split(spec, ",") {
+		if strings.TrimSpace(id) != "keystone" {
+			ids = append(ids, id)
+			continue
+		}
+		result, ok := ironicClient.GetAuthResult().(tokens.CreateResult)
+		if !ok {
+			return nil, errors.New("keystone requires authenticating with Keystone v3")
+		}
+		project, err := result.ExtractProject()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the project of the token: %w", err)
+		}
+		if project == nil || project.ID == "" {
+			return nil, errors.New("keystone requires a token scoped to a project")
+		}
+		ids = append(ids, project.ID)
+	}
+	projects, err := metadata.ParseProjects(strings.Join(ids, ","))
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, errors.New("no project is served")
+	}
+	return projects, nil
+}
+
+// createIronicClient returns a bare metal client for the Ironic at
+// ironicURL, calling it through transport and authenticating with the OS_*
+// environment variables, unless IRONIC_REPLAY_DIR replays recordings or
+// STATIC_METADATA_DIR serves nodes without Ironic.
+func createIronicClient(
+	ironicURL string,
+	transport http.RoundTripper,
+) (*gophercloud.ServiceClient, error) {
+	// Replaying and static directories need no credentials, as no request
+	// reaches Keystone
+	if getEnvOrDefault("IRONIC_REPLAY_DIR", "") != "" ||
+		getEnvOrDefault("STATIC_METADATA_DIR", "") != "" {
+		return newIronicClient(ironicURL, gophercloud.AuthOptions{}, "", transport)
+	}
 	// Create authentication options
 	authOpts := gophercloud.AuthOptions{
 		IdentityEndpoint: ironicURL,
@@ -163,6 +1506,21 @@ func createIronicClient(ironicURL string) (*gophercloud.ServiceClient, error) {
 		TenantName:       getEnvOrDefault("OS_PROJECT_NAME", ""),
 		DomainName:       getEnvOrDefault("OS_USER_DOMAIN_NAME", "default"),
 	}
+	return newIronicClient(ironicURL, authOpts, getEnvOrDefault("OS_REGION_NAME", ""), transport)
+}
+
+// newIronicClient returns a bare metal client for the Ironic at ironicURL,
+// authenticating with authOpts against the service catalog of region, or in
+// no-auth mode when authOpts has no username.
+func newIronicClient(
+	ironicURL string,
+	authOpts gophercloud.AuthOptions,
+	region string,
+	transport http.RoundTripper,
+) (*gophercloud.ServiceClient, error) {
+	log.Debug().
+		Str("ironic_url", ironicURL).
+		Msg("Creating Ironic client")
 
 	// If no credentials provided, try to use no-auth mode
 	if authOpts.Username == "" {
@@ -173,6 +1531,7 @@ func createIronicClient(ironicURL string) (*gophercloud.ServiceClient, error) {
 		// For standalone Ironic, we might not need authentication
 		provider := &gophercloud.ProviderClient{
 			IdentityBase: ironicURL,
+			HTTPClient:   http.Client{Transport: transport},
 		}
 
 		client := &gophercloud.ServiceClient{
@@ -193,9 +1552,13 @@ func createIronicClient(ironicURL string) (*gophercloud.ServiceClient, error) {
 		Str("domain_name", authOpts.DomainName).
 		Msg("Using authentication for Ironic client")
 
-	// Use regular authentication
-	provider, err := openstack.AuthenticatedClient(context.Background(), authOpts)
+	// Use regular authentication, through the transport as well
+	provider, err := openstack.NewClient(authOpts.IdentityEndpoint)
 	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenStack client: %w", err)
+	}
+	provider.HTTPClient.Transport = transport
+	if err := openstack.Authenticate(context.Background(), provider, authOpts); err != nil {
 		log.Error().
 			Err(err).
 			Str("identity_endpoint", authOpts.IdentityEndpoint).
@@ -205,12 +1568,12 @@ func createIronicClient(ironicURL string) (*gophercloud.ServiceClient, error) {
 	}
 
 	client, err := openstack.NewBareMetalV1(provider, gophercloud.EndpointOpts{
-		Region: getEnvOrDefault("OS_REGION_NAME", ""),
+		Region: region,
 	})
 	if err != nil {
 		log.Error().
 			Err(err).
-			Str("region", getEnvOrDefault("OS_REGION_NAME", "")).
+			Str("region", region).
 			Msg("Failed to create baremetal service client")
 		return nil, fmt.Errorf("failed to create baremetal client: %w", err)
 	}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// metricsRetryInterval is how often binding the metrics address is retried.
+// During an upgrade the address stays bound by the previous process until
+// it has handed its listeners over.
+const metricsRetryInterval = time.Second
+
+// startMetricsServer serves registry at /metrics on addr in the background.
+// The returned function stops the server.
+func startMetricsServer(addr string, registry *metrics.Registry) func(context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var ln net.Listener
+		for warned := false; ; warned = true {
+			var err error
+			if ln, err = net.Listen("tcp", addr); err == nil {
+				break
+			}
+			if !warned {
+				log.Warn().
+					Err(err).
+					Str("address", addr).
+					Msg("Failed to listen for metrics, retrying")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(metricsRetryInterval):
+			}
+		}
+
+		log.Info().Str("address", addr).Msg("Serving metrics")
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().
+				Err(err).
+				Str("address", addr).
+				Msg("Failed to serve metrics")
+		}
+	}()
+
+	return func(shutdownCtx context.Context) {
+		cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Failed to shut down metrics server")
+		}
+		<-done
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	metadatatypes "github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/netconfig"
+)
+
+// runRender implements the render command, which prints the network
+// configuration of a node in one of the netconfig formats.
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	format := fs.String("format", netconfig.FormatNetplan,
+		"output format ("+strings.Join(netconfig.Formats, ", ")+")")
+	file := fs.String("file", "", "read network_data.json from this file (- for stdin)")
+	nodeID := fs.String("node", "",
+		"render the network data of this Ironic node (UUID, name, IP or MAC address)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if (*file == "") == (*nodeID == "") {
+		return errors.New("exactly one of -file or -node must be set")
+	}
+
+	var (
+		networkData *metadatatypes.NetworkData
+		err         error
+	)
+	if *file != "" {
+		networkData, err = readNetworkData(*file)
+	} else {
+		networkData, err = fetchNetworkData(*nodeID)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := netconfig.Render(*format, networkData)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// readNetworkData decodes a network_data.json document from a file, or from
+// stdin when path is "-".
+func readNetworkData(path string) (*metadatatypes.NetworkData, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open network data: %w", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		r = f
+	}
+
+	networkData := &metadatatypes.NetworkData{}
+	if err := json.NewDecoder(r).Decode(networkData); err != nil {
+		return nil, fmt.Errorf("failed to decode network data: %w", err)
+	}
+	return networkData, nil
+}
+
+// fetchNetworkData loads a node from Ironic and builds the network data the
+// metadata service would serve to it.
+func fetchNetworkData(nodeID string) (*metadatatypes.NetworkData, error) {
+	handler, err := commandHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	node, err := handler.FindNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return handler.NetworkData(node), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/appkins-org/ironic-metadata/pkg/listen"
+	"github.com/rs/zerolog/log"
+)
+
+// waitForShutdown blocks until the server is asked to shut down, handing
+// the listeners over to a new process of the service whenever the upgrade
+// signal is received. The new process stops this one once it is serving.
+// It reports whether a new process that took over is still running.
+func waitForShutdown(listeners *listen.Set) (os.Signal, bool) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	upgrade := make(chan os.Signal, 1)
+	if upgradeSignal != nil {
+		signal.Notify(upgrade, upgradeSignal)
+	}
+
+	var handedOff atomic.Bool
+	for {
+		select {
+		case sig := <-quit:
+			return sig, handedOff.Load()
+		case <-upgrade:
+			process, err := listeners.Handoff()
+			if err != nil {
+				log.Error().
+					Err(err).
+					Msg("Failed to hand listeners over to a new process")
+				continue
+			}
+			log.Info().
+				Int("pid", process.Pid).
+				Msg("Handed listeners over to a new process")
+			handedOff.Store(true)
+
+			go func() {
+				state, err := process.Wait()
+				handedOff.Store(false)
+				if err != nil {
+					log.Error().
+						Err(err).
+						Int("pid", process.Pid).
+						Msg("Failed to wait for new process")
+					return
+				}
+				log.Warn().
+					Int("pid", process.Pid).
+					Str("state", state.String()).
+					Msg("New process exited, continuing to serve")
+			}()
+		}
+	}
+}
+
+// takeOver stops the process that handed its listeners over to this one,
+// once this one is serving them.
+func takeOver() {
+	parent, err := os.FindProcess(os.Getppid())
+	if err == nil {
+		err = parent.Signal(syscall.SIGTERM)
+	}
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("parent_pid", os.Getppid()).
+			Msg("Failed to stop previous process")
+		return
+	}
+	log.Info().
+		Int("parent_pid", os.Getppid()).
+		Msg("Took over listeners from previous process")
+}
+
+// notifySystemd sends a state notification to systemd when the service
+// runs as a notify unit, so it follows the main process across upgrades.
+func notifySystemd(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	conn, err := net.Dial("unixgram", socket)
+	if err == nil {
+		_, err = conn.Write([]byte(state))
+		_ = conn.Close()
+	}
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("notify_socket", socket).
+			Msg("Failed to notify systemd")
+	}
+}
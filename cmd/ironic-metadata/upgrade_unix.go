@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignal asks the server to hand its listeners over to a new process.
+var upgradeSignal os.Signal = syscall.SIGUSR2
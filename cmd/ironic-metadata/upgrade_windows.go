@@ -0,0 +1,6 @@
+package main
+
+import "os"
+
+// upgradeSignal is nil on Windows, which cannot hand listeners over.
+var upgradeSignal os.Signal
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/appkins-org/ironic-metadata/api/metadata"
+	"github.com/appkins-org/ironic-metadata/pkg/jws"
+)
+
+// runVerify implements the verify command, which checks a signed metadata
+// document against the service's public key and prints the verified
+// document.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "PEM public key or certificate of the signing key")
+	file := fs.String("file", "-",
+		"document to verify, or JWS envelope when -signature is unset (- for stdin)")
+	signature := fs.String("signature", "",
+		"detached JWS of the document, as served in the "+metadata.SignatureHeader+" header")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *keyFile == "" {
+		return errors.New("-key must be set")
+	}
+	keyPEM, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+	key, err := jws.ParsePublicKey(keyPEM)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read document: %w", err)
+	}
+
+	var payload []byte
+	if *signature == "" {
+		payload, err = jws.Verify(string(data), nil, key)
+	} else {
+		payload, err = jws.Verify(*signature, data, key)
+	}
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	_, err = os.Stdout.Write(payload)
+	return err
+}
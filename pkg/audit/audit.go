@@ -0,0 +1,376 @@
+// Package audit exports an audit trail of the service to object storage,
+// so compliance teams keep a record of which hosts received which
+// secrets-bearing payloads. The events of the service, such as nodes
+// fetching their user data, and periodic snapshots of the mapping table are
+// written as gzip compressed JSON lines, one object per export, and objects
+// older than the retention are deleted.
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+)
+
+// Defaults of Config.
+const (
+	DefaultInterval  = time.Hour
+	DefaultMaxEvents = 100000
+)
+
+// finalExportTimeout bounds the export of the events buffered when Run
+// returns.
+const finalExportTimeout = 30 * time.Second
+
+// Kinds of exported objects, the first element of their key after the
+// prefix.
+const (
+	KindEvents   = "events"
+	KindMappings = "mappings"
+)
+
+// Kinds lists the kinds of exported objects.
+var Kinds = []string{KindEvents, KindMappings}
+
+// Results of exports.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Object is an object of a store.
+type Object struct {
+	Key          string
+	LastModified time.Time
+}
+
+// Store is the object storage the audit trail is exported to.
+type Store interface {
+	// Put writes an object.
+	Put(ctx context.Context, key string, body []byte) error
+
+	// List lists the objects whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+
+	// Delete deletes an object.
+	Delete(ctx context.Context, key string) error
+}
+
+// Location is where the audit trail is exported, parsed from a
+// s3://<bucket>/<prefix> or swift://<container>/<prefix> URL.
+type Location struct {
+	// Scheme is s3 or swift.
+	Scheme string
+
+	// Bucket is the S3 bucket or the Swift container.
+	Bucket string
+
+	// Prefix prefixes the keys of the objects, ending with a slash unless
+	// empty.
+	Prefix string
+}
+
+// ParseURL parses the URL of the location the audit trail is exported to.
+func ParseURL(rawURL string) (Location, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "s3" && u.Scheme != "swift") || u.Host == "" {
+		return Location{}, fmt.Errorf(
+			"invalid audit export URL %q: expected s3://<bucket>/<prefix> or "+
+				"swift://<container>/<prefix>", rawURL)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return Location{Scheme: u.Scheme, Bucket: u.Host, Prefix: prefix}, nil
+}
+
+// Config configures an Exporter.
+type Config struct {
+	// Store is written the audit trail.
+	Store Store
+
+	// Prefix prefixes the keys of the objects. Events are written to
+	// <prefix>events/YYYY/MM/DD/<time>-<instance>.jsonl.gz and snapshots to
+	// the same key under <prefix>mappings/.
+	Prefix string
+
+	// Instance names the objects of this replica, so replicas exporting to
+	// the same location do not overwrite each other. It defaults to the
+	// host name.
+	Instance string
+
+	// Events are the types of events exported, every type when empty.
+	Events map[string]bool
+
+	// Interval is the time between exports, defaulting to DefaultInterval.
+	Interval time.Duration
+
+	// Retention is the age of the objects deleted at each export. Objects
+	// are kept forever when it is zero.
+	Retention time.Duration
+
+	// Snapshot returns the records of the mapping table, each written as a
+	// line of the snapshot. No snapshot is exported when it is nil.
+	Snapshot func(ctx context.Context) ([]any, error)
+
+	// MaxEvents bounds the events buffered between exports, defaulting to
+	// DefaultMaxEvents.
+	MaxEvents int
+
+	// Leader reports whether the replica snapshots the mapping table and
+	// deletes old objects, which the replicas exporting to the same location
+	// leave to one of them. Every replica does when it is nil, while each
+	// exports its own events regardless.
+	Leader func() bool
+
+	// OnError is called when an export fails.
+	OnError func(err error)
+}
+
+// Exporter buffers events and periodically exports them, with a snapshot
+// of the mapping table, to a store.
+type Exporter struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	buffer  []events.Event
+	exports map[[2]string]uint64
+	pruned  uint64
+}
+
+// New returns an exporter to the store of cfg. Events are exported once
+// Run is called.
+func New(cfg Config) (*Exporter, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("an audit store is required")
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	if cfg.Instance == "" {
+		cfg.Instance, _ = os.Hostname()
+	}
+	if cfg.Instance == "" || strings.ContainsAny(cfg.Instance, "/ ") {
+		return nil, fmt.Errorf("invalid audit instance %q", cfg.Instance)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Retention < 0 {
+		return nil, fmt.Errorf("invalid audit retention %s", cfg.Retention)
+	}
+	if cfg.MaxEvents <= 0 {
+		cfg.MaxEvents = DefaultMaxEvents
+	}
+	return &Exporter{
+		cfg:     cfg,
+		now:     time.Now,
+		exports: make(map[[2]string]uint64),
+	}, nil
+}
+
+// Name returns audit.
+func (e *Exporter) Name() string {
+	return "audit"
+}
+
+// Wants reports whether events of a type are exported.
+func (e *Exporter) Wants(eventType string) bool {
+	return len(e.cfg.Events) == 0 || e.cfg.Events[eventType]
+}
+
+// Notify buffers an event for the next export. It never blocks, and
+// reports false when the buffer is full and the event is dropped.
+func (e *Exporter) Notify(event events.Event) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.buffer) >= e.cfg.MaxEvents {
+		return false
+	}
+	e.buffer = append(e.buffer, event)
+	return true
+}
+
+// Exports returns the number of exports of a kind with a result.
+func (e *Exporter) Exports(kind, result string) uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.exports[[2]string{kind, result}]
+}
+
+// Pruned returns the number of objects deleted for being older than the
+// retention.
+func (e *Exporter) Pruned() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.pruned
+}
+
+// Run exports the audit trail every interval until ctx is done, then
+// exports the events still buffered.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.Background(), finalExportTimeout)
+			defer cancel()
+			if err := e.exportEvents(finalCtx, e.now()); err != nil {
+				e.error(err)
+			}
+			return
+		case <-ticker.C:
+			if err := e.Export(ctx); err != nil && ctx.Err() == nil {
+				e.error(err)
+			}
+		}
+	}
+}
+
+// Export exports the buffered events and, on the leader, a snapshot of the
+// mapping table, then deletes the objects older than the retention. Events
+// that fail to be exported stay buffered for the next export.
+func (e *Exporter) Export(ctx context.Context) error {
+	now := e.now()
+	var errs []error
+	if err := e.exportEvents(ctx, now); err != nil {
+		errs = append(errs, err)
+	}
+	if e.cfg.Leader != nil && !e.cfg.Leader() {
+		return errors.Join(errs...)
+	}
+	if e.cfg.Snapshot != nil {
+		if err := e.exportSnapshot(ctx, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if e.cfg.Retention > 0 {
+		if err := e.prune(ctx, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// exportEvents writes the buffered events, unless there are none.
+func (e *Exporter) exportEvents(ctx context.Context, now time.Time) error {
+	e.mu.Lock()
+	batch := e.buffer
+	e.buffer = nil
+	e.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := put(ctx, e, KindEvents, now, batch)
+	if err != nil {
+		// Put the events back ahead of those buffered since, dropping the
+		// newest beyond the bound.
+		e.mu.Lock()
+		e.buffer = append(batch, e.buffer...)
+		if len(e.buffer) > e.cfg.MaxEvents {
+			e.buffer = e.buffer[:e.cfg.MaxEvents]
+		}
+		e.mu.Unlock()
+	}
+	return err
+}
+
+// exportSnapshot writes a snapshot of the mapping table.
+func (e *Exporter) exportSnapshot(ctx context.Context, now time.Time) error {
+	records, err := e.cfg.Snapshot(ctx)
+	if err != nil {
+		e.count(KindMappings, ResultFailure)
+		return fmt.Errorf("failed to snapshot the mapping table: %w", err)
+	}
+	return put(ctx, e, KindMappings, now, records)
+}
+
+// put writes records as an object of a kind, counting the export.
+func put[T any](ctx context.Context, e *Exporter, kind string, now time.Time, records []T) error {
+	key := e.Key(kind, now)
+	body, err := encode(records)
+	if err == nil {
+		err = e.cfg.Store.Put(ctx, key, body)
+	}
+	if err != nil {
+		e.count(kind, ResultFailure)
+		return fmt.Errorf("failed to export %s to %s: %w", kind, key, err)
+	}
+	e.count(kind, ResultSuccess)
+	return nil
+}
+
+// prune deletes the objects older than the retention.
+func (e *Exporter) prune(ctx context.Context, now time.Time) error {
+	cutoff := now.Add(-e.cfg.Retention)
+	var errs []error
+	for _, kind := range Kinds {
+		objects, err := e.cfg.Store.List(ctx, e.cfg.Prefix+kind+"/")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list %s: %w", kind, err))
+			continue
+		}
+		for _, object := range objects {
+			if !object.LastModified.Before(cutoff) {
+				continue
+			}
+			if err := e.cfg.Store.Delete(ctx, object.Key); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete %s: %w", object.Key, err))
+				continue
+			}
+			e.mu.Lock()
+			e.pruned++
+			e.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Key returns the key of the object of a kind exported at t.
+func (e *Exporter) Key(kind string, t time.Time) string {
+	t = t.UTC()
+	return e.cfg.Prefix + kind + "/" + t.Format("2006/01/02/20060102T150405Z") +
+		"-" + e.cfg.Instance + ".jsonl.gz"
+}
+
+func (e *Exporter) count(kind, result string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exports[[2]string{kind, result}]++
+}
+
+func (e *Exporter) error(err error) {
+	if e.cfg.OnError != nil {
+		e.cfg.OnError(err)
+	}
+}
+
+// encode encodes records as gzip compressed JSON lines.
+func encode[T any](records []T) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to encode record: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress records: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/objectstorage/v1/objects"
+)
+
+// contentType is the content type of exported objects.
+const contentType = "application/gzip"
+
+// S3Store is a bucket of an S3 API, such as AWS, Ceph RGW or MinIO.
+type S3Store struct {
+	cfg        *remote.S3Config
+	bucket     string
+	httpClient *http.Client
+}
+
+// NewS3Store returns the store of a bucket, accessed with the endpoint and
+// credentials of cfg.
+func NewS3Store(cfg *remote.S3Config, bucket string) *S3Store {
+	return &S3Store{
+		cfg:        cfg,
+		bucket:     bucket,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Put writes an object, sending its MD5 digest so buckets with object lock
+// accept it.
+func (s *S3Store) Put(ctx context.Context, key string, body []byte) error {
+	req, err := s.cfg.NewRequest(ctx, http.MethodPut, s.bucket, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	_, err = s.do(req)
+	return err
+}
+
+// listBucketResult is the response of ListObjectsV2.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List lists the objects whose key starts with prefix, following the
+// pages of the listing.
+func (s *S3Store) List(ctx context.Context, prefix string) ([]Object, error) {
+	var result []Object
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		req, err := s.cfg.NewRequest(ctx, http.MethodGet, s.bucket, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		if err := xml.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode object listing: %w", err)
+		}
+		for _, object := range page.Contents {
+			result = append(result, Object{Key: object.Key, LastModified: object.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return result, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// Delete deletes an object.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.cfg.NewRequest(ctx, http.MethodDelete, s.bucket, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(req)
+	return err
+}
+
+// do sends a request, returning the body of a successful response.
+func (s *S3Store) do(req *http.Request) ([]byte, error) {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", req.URL.Host, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", req.URL.Host, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var s3Err struct {
+			Code string `xml:"Code"`
+		}
+		if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("%s answered %s: %s", req.URL.Host, resp.Status, s3Err.Code)
+		}
+		return nil, fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return body, nil
+}
+
+// SwiftStore is a container of Swift.
+type SwiftStore struct {
+	client    *gophercloud.ServiceClient
+	container string
+}
+
+// NewSwiftStore returns the store of a container, accessed with client.
+func NewSwiftStore(client *gophercloud.ServiceClient, container string) *SwiftStore {
+	return &SwiftStore{client: client, container: container}
+}
+
+// Put writes an object, which Swift verifies against the ETag set by
+// gophercloud.
+func (s *SwiftStore) Put(ctx context.Context, key string, body []byte) error {
+	return objects.Create(ctx, s.client, s.container, key, objects.CreateOpts{
+		Content:     bytes.NewReader(body),
+		ContentType: contentType,
+	}).Err
+}
+
+// List lists the objects whose key starts with prefix.
+func (s *SwiftStore) List(ctx context.Context, prefix string) ([]Object, error) {
+	pages, err := objects.List(s.client, s.container, objects.ListOpts{Prefix: prefix}).
+		AllPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := objects.ExtractInfo(pages)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Object, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, Object{Key: info.Name, LastModified: info.LastModified})
+	}
+	return result, nil
+}
+
+// Delete deletes an object.
+func (s *SwiftStore) Delete(ctx context.Context, key string) error {
+	return objects.Delete(ctx, s.client, s.container, key, nil).Err
+}
@@ -0,0 +1,113 @@
+// Package blob decodes the transport encodings clients apply to configdrive
+// and user data blobs stored in Ironic, most commonly base64 encoded gzip.
+package blob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultLimit is the default bound on the decoded size of a blob. It matches
+// the largest configdrive Ironic accepts.
+const DefaultLimit = 64 << 20
+
+// ErrTooLarge is returned when a decoded blob exceeds the size limit.
+var ErrTooLarge = errors.New("decoded blob exceeds size limit")
+
+// Magic bytes of the payloads that are worth unwrapping from base64.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	isoMagic  = []byte("CD001")
+
+	// The byte order marks of UTF-16 text, with which Windows tools such
+	// as PowerShell save scripts.
+	utf16LEMagic = []byte{0xff, 0xfe}
+	utf16BEMagic = []byte{0xfe, 0xff}
+)
+
+// isoMagicOffset is the offset of the ISO 9660 volume descriptor identifier.
+const isoMagicOffset = 16*2048 + 1
+
+// IsGzip reports whether data starts with the gzip magic bytes.
+func IsGzip(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic)
+}
+
+// IsISO reports whether data is an ISO 9660 image.
+func IsISO(data []byte) bool {
+	return len(data) >= isoMagicOffset+len(isoMagic) &&
+		bytes.Equal(data[isoMagicOffset:isoMagicOffset+len(isoMagic)], isoMagic)
+}
+
+// IsUTF16 reports whether data starts with a UTF-16 byte order mark.
+func IsUTF16(data []byte) bool {
+	return bytes.HasPrefix(data, utf16LEMagic) || bytes.HasPrefix(data, utf16BEMagic)
+}
+
+// Decode strips gzip compression and base64 encoding from data. Base64 is
+// only removed when the decoded bytes are gzip, an ISO image or UTF-16 text
+// starting with a byte order mark, since plain text can be valid base64 by
+// accident. Data in neither encoding is returned
+// unchanged. ErrTooLarge is returned when the result exceeds limit bytes.
+func Decode(data []byte, limit int64) ([]byte, error) {
+	if IsGzip(data) {
+		return gunzip(data, limit)
+	}
+
+	if decoded, ok := decodeBase64(data); ok &&
+		(IsGzip(decoded) || IsISO(decoded) || IsUTF16(decoded)) {
+		return Decode(decoded, limit)
+	}
+
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// decodeBase64 decodes standard base64, ignoring line breaks and surrounding
+// whitespace as produced by tools wrapping their output.
+func decodeBase64(data []byte) ([]byte, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	stripped := strings.Map(func(r rune) rune {
+		switch r {
+		case '\n', '\r', ' ', '\t':
+			return -1
+		}
+		return r
+	}, string(data))
+
+	decoded, err := base64.StdEncoding.DecodeString(stripped)
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// gunzip decompresses data, reading at most limit bytes so that compressed
+// payloads cannot expand without bound.
+func gunzip(data []byte, limit int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob: %w", err)
+	}
+
+	decompressed, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob: %w", err)
+	}
+	if int64(len(decompressed)) > limit {
+		return nil, ErrTooLarge
+	}
+	if err := zr.Close(); err != nil {
+		return nil, fmt.Errorf("failed to decompress blob: %w", err)
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,147 @@
+package blob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	script := []byte("#!/bin/sh\necho hello\n")
+	utf16 := []byte("\xff\xfe#\x00p\x00s\x001\x00")
+	encoded := base64.StdEncoding.EncodeToString(gzipped(t, script))
+
+	// Wrap the encoding at 76 columns like base64(1) does.
+	var wrapped strings.Builder
+	for i := 0; i < len(encoded); i += 76 {
+		wrapped.WriteString(encoded[i:min(i+76, len(encoded))])
+		wrapped.WriteString("\n")
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		limit   int64
+		want    []byte
+		wantErr error
+	}{
+		{name: "plain", data: script, limit: DefaultLimit, want: script},
+		{name: "gzip", data: gzipped(t, script), limit: DefaultLimit, want: script},
+		{name: "base64 gzip", data: []byte(encoded), limit: DefaultLimit, want: script},
+		{name: "wrapped base64 gzip", data: []byte(wrapped.String()), limit: DefaultLimit, want: script},
+		{
+			name:  "base64 utf-16",
+			data:  []byte(base64.StdEncoding.EncodeToString(utf16)),
+			limit: DefaultLimit,
+			want:  utf16,
+		},
+		{
+			// Valid base64 that does not wrap a known payload stays as-is.
+			name:  "base64 looking text",
+			data:  []byte("abcd"),
+			limit: DefaultLimit,
+			want:  []byte("abcd"),
+		},
+		{
+			name:    "decompression bomb",
+			data:    gzipped(t, make([]byte, 1<<20)),
+			limit:   1 << 10,
+			wantErr: ErrTooLarge,
+		},
+		{name: "plain too large", data: script, limit: 4, wantErr: ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := Decode(tt.data, tt.limit)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("have error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(have, tt.want) {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	script := []byte("#!/bin/sh\necho hello\n")
+	utf16 := []byte("\xff\xfe#\x00p\x00s\x001\x00")
+	iso := make([]byte, isoMagicOffset+2048)
+	copy(iso[isoMagicOffset:], isoMagic)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "plain", data: script},
+		{name: "gzip", data: gzipped(t, script)},
+		{name: "base64 gzip", data: []byte(base64.StdEncoding.EncodeToString(gzipped(t, script)))},
+		{name: "base64 iso", data: []byte(base64.StdEncoding.EncodeToString(iso))},
+		{name: "base64 looking text", data: []byte("abcd")},
+		{name: "base64 text", data: []byte(base64.StdEncoding.EncodeToString(script))},
+		{name: "base64 utf-16", data: []byte(base64.StdEncoding.EncodeToString(utf16))},
+		{name: "invalid base64 gzip", data: []byte("H4sI AAAA=AAA")},
+		{name: "short", data: []byte("H4s")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := Decode(tt.data, DefaultLimit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(tt.data, DefaultLimit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			have, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(have, want) {
+				t.Errorf("have %q, want %q", have, want)
+			}
+		})
+	}
+}
+
+func TestNewReader_limit(t *testing.T) {
+	for _, data := range [][]byte{
+		gzipped(t, make([]byte, 1<<20)),
+		bytes.Repeat([]byte("x"), 2048),
+	} {
+		r, err := NewReader(data, 1<<10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := io.Copy(io.Discard, r); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("have error %v, want %v", err, ErrTooLarge)
+		}
+	}
+}
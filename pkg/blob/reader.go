@@ -0,0 +1,108 @@
+package blob
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// NewReader returns a reader of data with gzip compression and base64
+// encoding stripped as Decode does, without holding the decoded blob in
+// memory. Reads fail with ErrTooLarge once more than limit bytes have been
+// decoded.
+func NewReader(data []byte, limit int64) (io.Reader, error) {
+	r := io.Reader(bytes.NewReader(data))
+	gzipped := IsGzip(data)
+
+	if !gzipped && isBase64(data) {
+		// Peek far enough into the decoded bytes to tell gzip streams, ISO
+		// images and UTF-16 text apart from text that happens to be valid
+		// base64.
+		decoded := bufio.NewReaderSize(
+			base64.NewDecoder(base64.StdEncoding, &whitespaceFilter{r: bytes.NewReader(data)}),
+			isoMagicOffset+len(isoMagic),
+		)
+		head, _ := decoded.Peek(isoMagicOffset + len(isoMagic))
+		switch {
+		case IsGzip(head):
+			r, gzipped = decoded, true
+		case IsISO(head), IsUTF16(head):
+			r = decoded
+		}
+	}
+
+	if gzipped {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress blob: %w", err)
+		}
+		r = zr
+	}
+	return &limitedReader{r: r, n: limit}, nil
+}
+
+// isBase64 reports whether data is standard, padded base64 once line breaks
+// and surrounding whitespace are ignored, matching what decodeBase64
+// accepts without decoding it.
+func isBase64(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+
+	var n, padding int
+	for _, c := range data {
+		switch {
+		case c == '\n' || c == '\r' || c == ' ' || c == '\t':
+			continue
+		case c == '=':
+			padding++
+		case padding > 0:
+			return false
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '+', c == '/':
+		default:
+			return false
+		}
+		n++
+	}
+	return n > 0 && n%4 == 0 && padding <= 2
+}
+
+// whitespaceFilter drops the whitespace that wrapped base64 may contain.
+type whitespaceFilter struct {
+	r io.Reader
+}
+
+func (f *whitespaceFilter) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		kept := 0
+		for _, c := range p[:n] {
+			if c != '\n' && c != '\r' && c != ' ' && c != '\t' {
+				p[kept] = c
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// limitedReader fails with ErrTooLarge once more than n bytes are read,
+// rather than truncating like io.LimitedReader.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,124 @@
+// Package claim makes the link-local metadata address reachable on the
+// host running the service, by assigning it to a dummy interface and
+// redirecting its HTTP port to the port the service listens on.
+package claim
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Firewalls installing the port redirect.
+const (
+	FirewallAuto     = "auto"
+	FirewallNFTables = "nftables"
+	FirewallIPTables = "iptables"
+	FirewallNone     = "none"
+)
+
+// Defaults of Config.
+const (
+	DefaultInterface = "metadata0"
+	DefaultPort      = 80
+)
+
+// DefaultAddress is the address cloud-init and other agents look for the
+// metadata service at.
+var DefaultAddress = netip.MustParseAddr("169.254.169.254")
+
+// nftTable is the nftables table holding the redirect rules.
+const nftTable = "ironic_metadata"
+
+// Config describes the address to claim.
+type Config struct {
+	// Interface is the dummy interface holding the address, created when
+	// missing and removed on release. It must not be used for anything else.
+	Interface string
+
+	// Address is the IPv4 address to claim.
+	Address netip.Addr
+
+	// Port is the port clients connect to.
+	Port uint16
+
+	// RedirectPort is the port the service listens on. Connections to Port
+	// are redirected to it when they differ.
+	RedirectPort uint16
+
+	// Firewall installs the redirect: nftables, iptables, auto to use
+	// nftables when available, or none.
+	Firewall string
+}
+
+// withDefaults fills in the zero fields of a config.
+func (c Config) withDefaults() Config {
+	if c.Interface == "" {
+		c.Interface = DefaultInterface
+	}
+	if !c.Address.IsValid() {
+		c.Address = DefaultAddress
+	}
+	if c.Port == 0 {
+		c.Port = DefaultPort
+	}
+	if c.RedirectPort == 0 {
+		c.RedirectPort = c.Port
+	}
+	if c.Firewall == "" {
+		c.Firewall = FirewallAuto
+	}
+	return c
+}
+
+// validate checks a config with defaults filled in.
+func (c Config) validate() error {
+	if !c.Address.Is4() {
+		return fmt.Errorf("address %s is not an IPv4 address", c.Address)
+	}
+	if len(c.Interface) > 15 || strings.ContainsAny(c.Interface, "/ \t\n") {
+		return fmt.Errorf("invalid interface name %q", c.Interface)
+	}
+	switch c.Firewall {
+	case FirewallAuto, FirewallNFTables, FirewallIPTables, FirewallNone:
+	default:
+		return fmt.Errorf("unknown firewall %q", c.Firewall)
+	}
+	if c.Firewall == FirewallNone && c.RedirectPort != c.Port {
+		return errors.New("redirecting the port requires a firewall")
+	}
+	return nil
+}
+
+// nftRules returns the nftables script installing the redirect, replacing
+// any rules left behind by an earlier run.
+func (c Config) nftRules() string {
+	rule := fmt.Sprintf("ip daddr %s tcp dport %d redirect to :%d",
+		c.Address, c.Port, c.RedirectPort)
+	return fmt.Sprintf(`table ip %[1]s {}
+delete table ip %[1]s
+table ip %[1]s {
+	chain prerouting {
+		type nat hook prerouting priority dstnat; policy accept;
+		%[2]s
+	}
+	chain output {
+		type nat hook output priority -100; policy accept;
+		%[2]s
+	}
+}
+`, nftTable, rule)
+}
+
+// iptablesRule returns the arguments of an iptables redirect rule in a
+// chain of the nat table, following the command flag such as -A or -D.
+func (c Config) iptablesRule(command, chain string) []string {
+	return []string{
+		"-t", "nat", command, chain,
+		"-d", c.Address.String() + "/32",
+		"-p", "tcp", "--dport", fmt.Sprint(c.Port),
+		"-m", "comment", "--comment", nftTable,
+		"-j", "REDIRECT", "--to-ports", fmt.Sprint(c.RedirectPort),
+	}
+}
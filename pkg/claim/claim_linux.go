@@ -0,0 +1,185 @@
+package claim
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// capNetAdmin is the bit of CAP_NET_ADMIN in capability sets.
+const capNetAdmin = 12
+
+// runFunc runs a command with stdin, returning its combined output.
+type runFunc func(ctx context.Context, stdin string, name string, args ...string) (string, error)
+
+// Claim is a claimed address.
+type Claim struct {
+	cfg      Config
+	firewall string
+	run      runFunc
+}
+
+// New claims the address described by cfg, assigning it to a dummy
+// interface and installing the port redirect. It requires CAP_NET_ADMIN and
+// the ip command, and nft or iptables to redirect the port. Claiming an
+// address claimed earlier, such as by a process being upgraded, succeeds.
+func New(ctx context.Context, cfg Config) (*Claim, error) {
+	ok, err := hasNetAdmin()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("claiming the metadata address requires CAP_NET_ADMIN")
+	}
+	return claim(ctx, cfg, runCommand)
+}
+
+func claim(ctx context.Context, cfg Config, run runFunc) (*Claim, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	c := &Claim{cfg: cfg, firewall: FirewallNone, run: run}
+
+	if err := c.addAddress(ctx); err != nil {
+		return nil, err
+	}
+	if cfg.RedirectPort != cfg.Port {
+		c.firewall = cfg.Firewall
+		if c.firewall == FirewallAuto {
+			c.firewall = FirewallIPTables
+			if _, err := exec.LookPath("nft"); err == nil {
+				c.firewall = FirewallNFTables
+			}
+		}
+		if err := c.addRedirect(ctx); err != nil {
+			return nil, errors.Join(err, c.removeAddress(ctx))
+		}
+	}
+	return c, nil
+}
+
+// Firewall returns the firewall installing the redirect, or none.
+func (c *Claim) Firewall() string {
+	return c.firewall
+}
+
+// Release removes the redirect and the dummy interface.
+func (c *Claim) Release(ctx context.Context) error {
+	return errors.Join(c.removeRedirect(ctx), c.removeAddress(ctx))
+}
+
+// addAddress assigns the address to the dummy interface, creating it when
+// missing.
+func (c *Claim) addAddress(ctx context.Context) error {
+	iface := c.cfg.Interface
+	if _, err := c.run(ctx, "", "ip", "link", "show", "dev", iface); err != nil {
+		if _, err := c.run(ctx, "", "ip", "link", "add", iface, "type", "dummy"); err != nil {
+			return fmt.Errorf("failed to create interface %s: %w", iface, err)
+		}
+	}
+	if _, err := c.run(ctx, "", "ip", "link", "set", "dev", iface, "up"); err != nil {
+		return fmt.Errorf("failed to bring up interface %s: %w", iface, err)
+	}
+	prefix := c.cfg.Address.String() + "/32"
+	if _, err := c.run(ctx, "", "ip", "addr", "replace", prefix, "dev", iface); err != nil {
+		return fmt.Errorf("failed to assign %s to %s: %w", prefix, iface, err)
+	}
+	return nil
+}
+
+// removeAddress deletes the dummy interface and with it the address.
+func (c *Claim) removeAddress(ctx context.Context) error {
+	iface := c.cfg.Interface
+	if _, err := c.run(ctx, "", "ip", "link", "delete", iface, "type", "dummy"); err != nil {
+		return fmt.Errorf("failed to delete interface %s: %w", iface, err)
+	}
+	return nil
+}
+
+// addRedirect installs the port redirect.
+func (c *Claim) addRedirect(ctx context.Context) error {
+	switch c.firewall {
+	case FirewallNFTables:
+		if _, err := c.run(ctx, c.cfg.nftRules(), "nft", "-f", "-"); err != nil {
+			return fmt.Errorf("failed to install nftables redirect: %w", err)
+		}
+	case FirewallIPTables:
+		for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+			// -C fails unless the rule exists.
+			check := c.cfg.iptablesRule("-C", chain)
+			if _, err := c.run(ctx, "", "iptables", check...); err == nil {
+				continue
+			}
+			rule := c.cfg.iptablesRule("-A", chain)
+			if _, err := c.run(ctx, "", "iptables", rule...); err != nil {
+				return fmt.Errorf("failed to install iptables redirect: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// removeRedirect removes the port redirect.
+func (c *Claim) removeRedirect(ctx context.Context) error {
+	switch c.firewall {
+	case FirewallNFTables:
+		if _, err := c.run(ctx, "", "nft", "delete", "table", "ip", nftTable); err != nil {
+			return fmt.Errorf("failed to remove nftables redirect: %w", err)
+		}
+	case FirewallIPTables:
+		var errs []error
+		for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+			rule := c.cfg.iptablesRule("-D", chain)
+			if _, err := c.run(ctx, "", "iptables", rule...); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove iptables redirect: %w", err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// runCommand runs a command, including its output in errors.
+func runCommand(ctx context.Context, stdin string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(out))
+	}
+	return string(out), nil
+}
+
+// hasNetAdmin reports whether the process has CAP_NET_ADMIN in its
+// effective capability set.
+func hasNetAdmin() (bool, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false, fmt.Errorf("failed to read capabilities: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid effective capabilities %q: %w", value, err)
+		}
+		return caps&(1<<capNetAdmin) != 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read capabilities: %w", err)
+	}
+	return false, errors.New("effective capabilities not found")
+}
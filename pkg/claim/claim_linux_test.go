@@ -0,0 +1,117 @@
+package claim
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records commands, failing those whose command line starts
+// with one of fail.
+type fakeRunner struct {
+	commands []string
+	fail     []string
+}
+
+func (f *fakeRunner) run(_ context.Context, _ string, name string, args ...string) (string, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.commands = append(f.commands, command)
+	for _, prefix := range f.fail {
+		if strings.HasPrefix(command, prefix) {
+			return "", errors.New("exit status 1")
+		}
+	}
+	return "", nil
+}
+
+func TestClaim(t *testing.T) {
+	iptablesRule := "-d 169.254.169.254/32 -p tcp --dport 80 " +
+		"-m comment --comment ironic_metadata -j REDIRECT --to-ports 8080"
+
+	tests := []struct {
+		name        string
+		cfg         Config
+		fail        []string
+		want        []string
+		wantRelease []string
+	}{
+		{
+			name: "existing interface without redirect",
+			want: []string{
+				"ip link show dev metadata0",
+				"ip link set dev metadata0 up",
+				"ip addr replace 169.254.169.254/32 dev metadata0",
+			},
+			wantRelease: []string{"ip link delete metadata0 type dummy"},
+		},
+		{
+			name: "nftables redirect",
+			cfg:  Config{Interface: "md0", RedirectPort: 8080, Firewall: FirewallNFTables},
+			fail: []string{"ip link show"},
+			want: []string{
+				"ip link show dev md0",
+				"ip link add md0 type dummy",
+				"ip link set dev md0 up",
+				"ip addr replace 169.254.169.254/32 dev md0",
+				"nft -f -",
+			},
+			wantRelease: []string{
+				"nft delete table ip ironic_metadata",
+				"ip link delete md0 type dummy",
+			},
+		},
+		{
+			name: "iptables redirect",
+			cfg:  Config{RedirectPort: 8080, Firewall: FirewallIPTables},
+			fail: []string{"iptables -t nat -C PREROUTING"},
+			want: []string{
+				"ip link show dev metadata0",
+				"ip link set dev metadata0 up",
+				"ip addr replace 169.254.169.254/32 dev metadata0",
+				"iptables -t nat -C PREROUTING " + iptablesRule,
+				"iptables -t nat -A PREROUTING " + iptablesRule,
+				// The output rule already exists.
+				"iptables -t nat -C OUTPUT " + iptablesRule,
+			},
+			wantRelease: []string{
+				"iptables -t nat -D PREROUTING " + iptablesRule,
+				"iptables -t nat -D OUTPUT " + iptablesRule,
+				"ip link delete metadata0 type dummy",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{fail: tt.fail}
+			c, err := claim(context.Background(), tt.cfg, runner.run)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(runner.commands, tt.want) {
+				t.Errorf("claim:\nhave %q\nwant %q", runner.commands, tt.want)
+			}
+
+			runner.commands = nil
+			if err := c.Release(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(runner.commands, tt.wantRelease) {
+				t.Errorf("release:\nhave %q\nwant %q", runner.commands, tt.wantRelease)
+			}
+		})
+	}
+}
+
+func TestClaim_redirectFailure(t *testing.T) {
+	runner := &fakeRunner{fail: []string{"nft"}}
+	cfg := Config{RedirectPort: 8080, Firewall: FirewallNFTables}
+	if _, err := claim(context.Background(), cfg, runner.run); err == nil {
+		t.Fatal("expected error")
+	}
+	want := "ip link delete metadata0 type dummy"
+	if last := runner.commands[len(runner.commands)-1]; last != want {
+		t.Errorf("expected the interface to be removed after the failure, last command %q", last)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package claim
+
+import (
+	"context"
+	"errors"
+)
+
+// Claim is a claimed address.
+type Claim struct{}
+
+// New fails, as claiming the metadata address is only supported on Linux.
+func New(_ context.Context, _ Config) (*Claim, error) {
+	return nil, errors.New("claiming the metadata address is only supported on Linux")
+}
+
+// Firewall returns the firewall installing the redirect, or none.
+func (c *Claim) Firewall() string {
+	return FirewallNone
+}
+
+// Release removes the redirect and the dummy interface.
+func (c *Claim) Release(_ context.Context) error {
+	return nil
+}
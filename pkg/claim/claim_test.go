@@ -0,0 +1,45 @@
+package claim
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "redirect", cfg: Config{RedirectPort: 8080, Firewall: FirewallIPTables}},
+		{name: "ipv6", cfg: Config{Address: netip.MustParseAddr("fd00:ec2::254")}, wantErr: true},
+		{name: "long interface", cfg: Config{Interface: "metadata-interface"}, wantErr: true},
+		{name: "unknown firewall", cfg: Config{Firewall: "pf"}, wantErr: true},
+		{
+			name:    "redirect without firewall",
+			cfg:     Config{RedirectPort: 8080, Firewall: FirewallNone},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.withDefaults().validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("have error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_nftRules(t *testing.T) {
+	rules := Config{RedirectPort: 8080}.withDefaults().nftRules()
+	want := "ip daddr 169.254.169.254 tcp dport 80 redirect to :8080"
+	if strings.Count(rules, want) != 2 {
+		t.Errorf("expected prerouting and output rules %q, got:\n%s", want, rules)
+	}
+	if !strings.HasPrefix(rules, "table ip ironic_metadata {}\ndelete table ip ironic_metadata\n") {
+		t.Errorf("expected rules to replace an existing table, got:\n%s", rules)
+	}
+}
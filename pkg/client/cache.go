@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/allocations"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+// Inventories refreshed by CachedSource, as reported to OnRefresh.
+const (
+	InventoryNodes = "nodes"
+	InventoryPorts = "ports"
+)
+
+// Warmer is a NodeSource that can fetch its inventory ahead of use.
+type Warmer interface {
+	Warm(ctx context.Context) error
+}
+
+// CachedSource is a NodeSource holding the node and port inventory of
+// another for a TTL, so that resolving a client does not list every node
+// and port from Ironic. Nodes fetched by ID are not cached. It is safe for
+// concurrent use.
+type CachedSource struct {
+	source     NodeSource
+	ttl        time.Duration
+	maxEntries int
+	onRefresh  func(inventory string, took time.Duration, err error)
+
+	// nodesFetch and portsFetch let one caller fetch an expired inventory
+	// while the others wait for it.
+	nodesFetch sync.Mutex
+	portsFetch sync.Mutex
+
+	mu       sync.Mutex
+	nodes    []nodes.Node
+	nodesAt  time.Time
+	ports    map[string][]ports.Port
+	portsAt  time.Time
+	counters metrics.CacheCounters
+}
+
+// NewCachedSource returns a NodeSource caching the inventory of source for
+// ttl.
+func NewCachedSource(source NodeSource, ttl time.Duration) *CachedSource {
+	return &CachedSource{source: source, ttl: ttl}
+}
+
+// OnRefresh sets a function called after each fetch of an inventory, such as
+// InventoryNodes, with its duration and error. It must be called before the
+// source is used.
+func (c *CachedSource) OnRefresh(fn func(inventory string, took time.Duration, err error)) {
+	c.onRefresh = fn
+}
+
+// SetMaxEntries bounds the nodes, and the ports, the source holds. Resolving
+// a client needs the whole inventory, so rather than evicting some nodes, an
+// inventory larger than maxEntries is served without being kept, its entries
+// counted as evicted. Zero leaves the source unbounded. It must be called
+// before the source is used.
+func (c *CachedSource) SetMaxEntries(maxEntries int) {
+	c.maxEntries = maxEntries
+}
+
+// Warm fetches the node and port inventory, replacing any cached one.
+func (c *CachedSource) Warm(ctx context.Context) error {
+	c.nodesFetch.Lock()
+	_, err := c.fetchNodes(ctx)
+	c.nodesFetch.Unlock()
+	if err != nil {
+		return err
+	}
+
+	c.portsFetch.Lock()
+	defer c.portsFetch.Unlock()
+	_, err = c.fetchPorts(ctx)
+	return err
+}
+
+// ListNodes returns the cached nodes, fetching them when they expired. The
+// returned slice is shared and must not be modified.
+func (c *CachedSource) ListNodes(ctx context.Context) ([]nodes.Node, error) {
+	if all, ok := c.cachedNodes(true); ok {
+		return all, nil
+	}
+
+	c.nodesFetch.Lock()
+	defer c.nodesFetch.Unlock()
+	// Another caller may have fetched them while this one waited.
+	if all, ok := c.cachedNodes(false); ok {
+		return all, nil
+	}
+	return c.fetchNodes(ctx)
+}
+
+// GetNode returns the node with a UUID or name from the source.
+func (c *CachedSource) GetNode(ctx context.Context, id string) (*nodes.Node, error) {
+	return c.source.GetNode(ctx, id)
+}
+
+// GetInventory returns the inspection inventory of the node with a UUID or
+// name from the source, failing with errors.ErrUnsupported when the source
+// does not provide inventories.
+func (c *CachedSource) GetInventory(ctx context.Context, id string) (*nodes.InventoryData, error) {
+	source, ok := c.source.(InventorySource)
+	if !ok {
+		return nil, fmt.Errorf("node source does not provide inventories: %w",
+			errors.ErrUnsupported)
+	}
+	return source.GetInventory(ctx, id)
+}
+
+// GetAllocation returns the allocation with a UUID or name from the source,
+// failing with errors.ErrUnsupported when the source does not provide
+// allocations.
+func (c *CachedSource) GetAllocation(
+	ctx context.Context,
+	id string,
+) (*allocations.Allocation, error) {
+	source, ok := c.source.(AllocationSource)
+	if !ok {
+		return nil, fmt.Errorf("node source does not provide allocations: %w",
+			errors.ErrUnsupported)
+	}
+	return source.GetAllocation(ctx, id)
+}
+
+// ListPorts returns the cached ports, fetching them when they expired.
+func (c *CachedSource) ListPorts(ctx context.Context) ([]ports.Port, error) {
+	byMAC, err := c.portsByMAC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var all []ports.Port
+	for _, matched := range byMAC {
+		all = append(all, matched...)
+	}
+	return all, nil
+}
+
+// ListPortsByMAC returns the cached ports with a MAC address. Addresses
+// missing from the cache are looked up in the source, so ports enrolled
+// since the inventory was fetched are found.
+func (c *CachedSource) ListPortsByMAC(ctx context.Context, mac string) ([]ports.Port, error) {
+	byMAC, err := c.portsByMAC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if matched, ok := byMAC[strings.ToLower(mac)]; ok {
+		return matched, nil
+	}
+	return c.source.ListPortsByMAC(ctx, mac)
+}
+
+// CacheStats returns the usage of the cache, whose entries are the cached
+// nodes and ports.
+func (c *CachedSource) CacheStats() metrics.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := len(c.nodes)
+	for _, matched := range c.ports {
+		entries += len(matched)
+	}
+	return c.counters.Stats(entries, func(yield func(time.Time) bool) {
+		for _, at := range []time.Time{c.nodesAt, c.portsAt} {
+			if !at.IsZero() && !yield(at) {
+				return
+			}
+		}
+	})
+}
+
+// cachedNodes returns the nodes when they have not expired, counting the
+// lookup when count is set.
+func (c *CachedSource) cachedNodes(count bool) ([]nodes.Node, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ok := !c.nodesAt.IsZero() && time.Since(c.nodesAt) < c.ttl
+	if count {
+		c.counters.Lookup(ok)
+	}
+	return c.nodes, ok
+}
+
+// fetchNodes lists the nodes from the source and caches them. It must be
+// called with nodesFetch held.
+func (c *CachedSource) fetchNodes(ctx context.Context) ([]nodes.Node, error) {
+	start := time.Now()
+	all, err := c.source.ListNodes(ctx)
+	c.refreshed(InventoryNodes, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tooLarge(len(all)) {
+		c.nodes, c.nodesAt = nil, time.Time{}
+		return all, nil
+	}
+	c.nodes, c.nodesAt = all, time.Now()
+	return all, nil
+}
+
+// portsByMAC returns the cached ports by lower-case MAC address, fetching
+// them when they expired.
+func (c *CachedSource) portsByMAC(ctx context.Context) (map[string][]ports.Port, error) {
+	if byMAC, ok := c.cachedPorts(true); ok {
+		return byMAC, nil
+	}
+
+	c.portsFetch.Lock()
+	defer c.portsFetch.Unlock()
+	if byMAC, ok := c.cachedPorts(false); ok {
+		return byMAC, nil
+	}
+	return c.fetchPorts(ctx)
+}
+
+// cachedPorts returns the ports by MAC address when they have not expired,
+// counting the lookup when count is set.
+func (c *CachedSource) cachedPorts(count bool) (map[string][]ports.Port, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ok := !c.portsAt.IsZero() && time.Since(c.portsAt) < c.ttl
+	if count {
+		c.counters.Lookup(ok)
+	}
+	return c.ports, ok
+}
+
+// fetchPorts lists the ports from the source and caches them by MAC
+// address. It must be called with portsFetch held.
+func (c *CachedSource) fetchPorts(ctx context.Context) (map[string][]ports.Port, error) {
+	start := time.Now()
+	all, err := c.source.ListPorts(ctx)
+	c.refreshed(InventoryPorts, start, err)
+	if err != nil {
+		return nil, err
+	}
+	byMAC := make(map[string][]ports.Port, len(all))
+	for _, port := range all {
+		mac := strings.ToLower(port.Address)
+		byMAC[mac] = append(byMAC[mac], port)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tooLarge(len(all)) {
+		c.ports, c.portsAt = nil, time.Time{}
+		return byMAC, nil
+	}
+	c.ports, c.portsAt = byMAC, time.Now()
+	return byMAC, nil
+}
+
+// tooLarge reports whether an inventory of n entries exceeds the bound of
+// SetMaxEntries, counting its entries as evicted when it does. It must be
+// called with mu held.
+func (c *CachedSource) tooLarge(n int) bool {
+	if c.maxEntries <= 0 || n <= c.maxEntries {
+		return false
+	}
+	c.counters.Evict(n)
+	return true
+}
+
+// refreshed reports a fetch of inventory started at start to OnRefresh.
+func (c *CachedSource) refreshed(inventory string, start time.Time, err error) {
+	if c.onRefresh != nil {
+		c.onRefresh(inventory, time.Since(start), err)
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/client/mock"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestCachedSource(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{UUID: "node-1"})
+	source.AddPort(ports.Port{UUID: "port-1", Address: "52:54:00:00:00:01", NodeUUID: "node-1"})
+	cached := NewCachedSource(source, time.Minute)
+
+	if err := cached.Warm(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range 3 {
+		if all, err := cached.ListNodes(t.Context()); err != nil || len(all) != 1 {
+			t.Fatalf("have %d nodes, error %v, want 1 node", len(all), err)
+		}
+	}
+	matched, err := cached.ListPortsByMAC(t.Context(), "52:54:00:00:00:01")
+	if err != nil || len(matched) != 1 {
+		t.Fatalf("have ports %+v, error %v, want port-1", matched, err)
+	}
+
+	// A port enrolled after the inventory was fetched is looked up.
+	source.AddPort(ports.Port{UUID: "port-2", Address: "52:54:00:00:00:02", NodeUUID: "node-2"})
+	matched, err = cached.ListPortsByMAC(t.Context(), "52:54:00:00:00:02")
+	if err != nil || len(matched) != 1 || matched[0].UUID != "port-2" {
+		t.Fatalf("have ports %+v, error %v, want port-2", matched, err)
+	}
+
+	for method, want := range map[string]int{
+		mock.MethodListNodes:      1,
+		mock.MethodListPorts:      1,
+		mock.MethodListPortsByMAC: 1,
+	} {
+		if have := source.Calls(method); have != want {
+			t.Errorf("have %d %s calls, want %d", have, method, want)
+		}
+	}
+	stats := cached.CacheStats()
+	if stats.Entries != 2 || stats.Hits != 5 || stats.Misses != 0 || stats.Oldest.IsZero() {
+		t.Errorf("have stats %+v, want 2 entries and 5 hits", stats)
+	}
+}
+
+func TestCachedSource_expiry(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{UUID: "node-1"})
+	cached := NewCachedSource(source, time.Nanosecond)
+	var refreshes []string
+	cached.OnRefresh(func(inventory string, _ time.Duration, err error) {
+		refreshes = append(refreshes, fmt.Sprintf("%s %v", inventory, err))
+	})
+
+	for range 2 {
+		if _, err := cached.ListNodes(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if have := source.Calls(mock.MethodListNodes); have != 2 {
+		t.Errorf("have %d ListNodes calls, want 2 as the inventory expired", have)
+	}
+
+	want := errors.New("unavailable")
+	source.SetError(want)
+	if _, err := cached.ListNodes(t.Context()); !errors.Is(err, want) {
+		t.Errorf("have error %v, want %v", err, want)
+	}
+	if err := cached.Warm(t.Context()); !errors.Is(err, want) {
+		t.Errorf("have error %v, want %v", err, want)
+	}
+
+	wantRefreshes := []string{
+		"nodes <nil>", "nodes <nil>", "nodes unavailable", "nodes unavailable",
+	}
+	if !slices.Equal(refreshes, wantRefreshes) {
+		t.Errorf("have refreshes %q, want %q", refreshes, wantRefreshes)
+	}
+}
+
+func TestCachedSource_maxEntries(t *testing.T) {
+	source := mock.NewNodeSource(nodes.Node{UUID: "node-1"}, nodes.Node{UUID: "node-2"})
+	source.AddPort(ports.Port{UUID: "port-1", Address: "52:54:00:00:00:01", NodeUUID: "node-1"})
+	cached := NewCachedSource(source, time.Minute)
+	cached.SetMaxEntries(1)
+
+	for range 2 {
+		if all, err := cached.ListNodes(t.Context()); err != nil || len(all) != 2 {
+			t.Fatalf("have %d nodes, error %v, want 2 nodes", len(all), err)
+		}
+		if all, err := cached.ListPorts(t.Context()); err != nil || len(all) != 1 {
+			t.Fatalf("have %d ports, error %v, want 1 port", len(all), err)
+		}
+	}
+	if have := source.Calls(mock.MethodListNodes); have != 2 {
+		t.Errorf("have %d ListNodes calls, want 2 as the nodes exceed the bound", have)
+	}
+	if have := source.Calls(mock.MethodListPorts); have != 1 {
+		t.Errorf("have %d ListPorts calls, want 1 as the ports fit", have)
+	}
+	stats := cached.CacheStats()
+	if stats.Entries != 1 || stats.Evictions != 4 {
+		t.Errorf("have stats %+v, want 1 entry and 4 evictions", stats)
+	}
+}
@@ -0,0 +1,421 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/drivers"
+	"github.com/gophercloud/gophercloud/v2/pagination"
+)
+
+// DefaultPollInterval is how often Clients polls Ironic while waiting for
+// it to come up, unless Options sets another interval.
+const DefaultPollInterval = 5 * time.Second
+
+// probeTimeout bounds each request polling Ironic.
+const probeTimeout = 5 * time.Second
+
+// Options configures Clients.
+type Options struct {
+	// Ironic is the bare metal API client. It is required.
+	Ironic *gophercloud.ServiceClient
+
+	// Swift is the object storage client, used to store and fetch
+	// configdrives. It is optional.
+	Swift *gophercloud.ServiceClient
+
+	// WaitTimeout is how long the first call needing Ironic waits for its API
+	// to answer and a conductor to register drivers. Zero does not wait.
+	WaitTimeout time.Duration
+
+	// PollInterval is how often Ironic is polled while waiting, and at most
+	// how often it is probed again once waiting timed out, or
+	// DefaultPollInterval when zero.
+	PollInterval time.Duration
+
+	// OnAvailability is called when Ironic is found down, because waiting
+	// for it timed out, or up after waiting for it or after being down.
+	OnAvailability func(up bool)
+
+	// DetailWorkers is how many node details ListNodes fetches at once, as
+	// set by SetDetailWorkers.
+	DetailWorkers int
+
+	// NodeFilter narrows the nodes ListNodes and EachNode list with
+	// server-side filters.
+	NodeFilter NodeFilter
+
+	// ListNodesTimeout bounds listing every node, page by page or with its
+	// details fetched by DetailWorkers. Zero does not bound it.
+	ListNodesTimeout time.Duration
+
+	// GetNodeTimeout bounds getting a node, including each node detail
+	// ListNodes fetches. Zero does not bound it.
+	GetNodeTimeout time.Duration
+
+	// ListPortsTimeout bounds listing ports, page by page. Zero does not
+	// bound it.
+	ListPortsTimeout time.Duration
+
+	// Logger receives the log output of the clients, or the default slog
+	// logger when nil.
+	Logger *slog.Logger
+}
+
+// Clients reads nodes, ports, inventories and allocations from Ironic and
+// holds the object storage client configdrives are kept with. It implements
+// NodeSource, InventorySource and AllocationSource. It is safe for
+// concurrent use once configured; the zero value has no Ironic client, which
+// SetIronicClient provides.
+type Clients struct {
+	ironic *gophercloud.ServiceClient
+	swift  *gophercloud.ServiceClient
+
+	// waitMu lets one caller wait for Ironic to come up while the others
+	// wait for its result.
+	waitMu sync.Mutex
+
+	// ironicUp is set once Ironic was seen up, or when there is no need to
+	// wait for it, and ironicFailed once waiting for it timed out. Ironic is
+	// then probed again at most every poll interval, last at lastProbe, until
+	// it is back.
+	ironicUp     bool
+	ironicFailed bool
+	lastProbe    time.Time
+
+	onAvailability func(up bool)
+
+	waitTimeout  time.Duration
+	pollInterval time.Duration
+
+	// detailWorkers is how many node details ListNodes fetches at once, or
+	// zero to list them with details.
+	detailWorkers int
+
+	// filter narrows the nodes listed.
+	filter NodeFilter
+
+	// The timeouts of Options bounding each Ironic operation, apart from
+	// waiting for Ironic to come up.
+	listNodesTimeout time.Duration
+	getNodeTimeout   time.Duration
+	listPortsTimeout time.Duration
+
+	// versionMu guards the API version nodes are read with, negotiated with
+	// Ironic on first use.
+	versionMu         sync.Mutex
+	versionNegotiated bool
+	nodeVersion       string
+
+	logger *slog.Logger
+}
+
+// Clients reads from and writes to Ironic.
+var (
+	_ NodeSource       = (*Clients)(nil)
+	_ InventorySource  = (*Clients)(nil)
+	_ AllocationSource = (*Clients)(nil)
+	_ NodeWriter       = (*Clients)(nil)
+)
+
+// NewClients returns Clients configured by opts.
+func NewClients(opts Options) (*Clients, error) {
+	if opts.Ironic == nil {
+		return nil, errors.New("no ironic client configured")
+	}
+	if opts.WaitTimeout < 0 || opts.PollInterval < 0 || opts.DetailWorkers < 0 {
+		return nil, errors.New("wait timeout, poll interval and detail workers must not be negative")
+	}
+	if opts.ListNodesTimeout < 0 || opts.GetNodeTimeout < 0 || opts.ListPortsTimeout < 0 {
+		return nil, errors.New("ironic call timeouts must not be negative")
+	}
+	if opts.NodeFilter.PageSize < 0 {
+		return nil, errors.New("node page size must not be negative")
+	}
+	return &Clients{
+		ironic:           opts.Ironic,
+		swift:            opts.Swift,
+		ironicUp:         opts.WaitTimeout == 0,
+		waitTimeout:      opts.WaitTimeout,
+		pollInterval:     opts.PollInterval,
+		detailWorkers:    opts.DetailWorkers,
+		filter:           opts.NodeFilter,
+		listNodesTimeout: opts.ListNodesTimeout,
+		getNodeTimeout:   opts.GetNodeTimeout,
+		listPortsTimeout: opts.ListPortsTimeout,
+		onAvailability:   opts.OnAvailability,
+		logger:           opts.Logger,
+	}, nil
+}
+
+// IronicClient returns the Ironic API client. The first call waits for
+// Ironic to come up, for up to the WaitTimeout of Options or until ctx is
+// done. Once waiting timed out, calls fail without waiting, probing Ironic
+// once at most every poll interval and succeeding again once it is back.
+func (c *Clients) IronicClient(ctx context.Context) (*gophercloud.ServiceClient, error) {
+	// Callers arriving while another waits for Ironic wait for it to finish,
+	// then see its outcome.
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+
+	if c.ironic == nil {
+		return nil, errors.New("no ironic client configured")
+	}
+	if c.ironicUp {
+		return c.ironic, nil
+	}
+	if c.ironicFailed {
+		return c.probeFailed(ctx)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, c.waitTimeout)
+	defer cancel()
+
+	logger := c.log()
+	logger.Info("Waiting for Ironic API")
+	poll(waitCtx, c.interval(), func(ctx context.Context) error {
+		logger.Debug("Waiting for API to become available...")
+		return probeAPI(ctx, c.ironic)
+	})
+	if waitCtx.Err() == nil {
+		logger.Info("Ironic API is up, waiting for a conductor")
+		poll(waitCtx, c.interval(), func(ctx context.Context) error {
+			logger.Debug("Waiting for conductor API to become available...")
+			return probeConductor(ctx, c.ironic)
+		})
+	}
+
+	if err := waitCtx.Err(); err != nil {
+		// A caller giving up is no reason to stop waiting for the others.
+		if ctx.Err() == nil {
+			logger.Error("Ironic API did not come up", "timeout", c.waitTimeout)
+			c.lastProbe = time.Now()
+			c.setAvailable(false)
+		}
+		return nil, fmt.Errorf("could not contact Ironic API: %w", err)
+	}
+	c.setAvailable(true)
+	return c.ironic, nil
+}
+
+// probeFailed probes Ironic after waiting for it timed out, unless it was
+// probed less than a poll interval ago, returning the client once it is
+// back.
+func (c *Clients) probeFailed(ctx context.Context) (*gophercloud.ServiceClient, error) {
+	if time.Since(c.lastProbe) < c.interval() {
+		return nil, fmt.Errorf("could not contact Ironic API: not up within %s", c.waitTimeout)
+	}
+	return c.probe(ctx)
+}
+
+// probe probes Ironic once, returning the client and recording that it is
+// back when it is up.
+func (c *Clients) probe(ctx context.Context) (*gophercloud.ServiceClient, error) {
+	c.lastProbe = time.Now()
+
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	err := probeAPI(probeCtx, c.ironic)
+	if err == nil {
+		err = probeConductor(probeCtx, c.ironic)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not contact Ironic API: %w", err)
+	}
+
+	c.log().Info("Ironic API is back")
+	c.setAvailable(true)
+	return c.ironic, nil
+}
+
+// setAvailable records that Ironic is up or down and reports it to
+// OnAvailability.
+func (c *Clients) setAvailable(up bool) {
+	c.ironicUp = up
+	c.ironicFailed = !up
+	if c.onAvailability != nil {
+		c.onAvailability(up)
+	}
+}
+
+// Down reports whether Ironic is known to be down: waiting for it timed out
+// and it was not found back since.
+func (c *Clients) Down() bool {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	return c.ironicFailed
+}
+
+// Watch probes Ironic every poll interval while it is down, so it is found
+// back without waiting for a request needing it, such as while readiness
+// fails and no requests arrive. It returns when ctx is done.
+func (c *Clients) Watch(ctx context.Context) {
+	ticker := time.NewTicker(c.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.waitMu.Lock()
+			if c.ironicFailed {
+				if _, err := c.probe(ctx); err != nil {
+					c.log().Debug("Ironic API is still down", "error", err)
+				}
+			}
+			c.waitMu.Unlock()
+		}
+	}
+}
+
+// OnAvailability sets the function called when Ironic is found down or up,
+// replacing that of Options.
+func (c *Clients) OnAvailability(fn func(up bool)) {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	c.onAvailability = fn
+}
+
+// Available reports whether Ironic is known to be up: it was seen up, or
+// there is no need to wait for it.
+func (c *Clients) Available() bool {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	return c.ironicUp
+}
+
+// interval returns how often Ironic is polled while waiting for it.
+func (c *Clients) interval() time.Duration {
+	if c.pollInterval > 0 {
+		return c.pollInterval
+	}
+	return DefaultPollInterval
+}
+
+// SetIronicClient sets the Ironic API client, which is used without waiting
+// for it to come up.
+func (c *Clients) SetIronicClient(client *gophercloud.ServiceClient) {
+	c.ironic = client
+	c.ironicUp = true
+}
+
+// SwiftClient returns the object storage client, if one is configured.
+func (c *Clients) SwiftClient() (*gophercloud.ServiceClient, error) {
+	if c.swift == nil {
+		return nil, errors.New("no object storage client configured")
+	}
+	return c.swift, nil
+}
+
+// SetSwiftClient sets the object storage client.
+func (c *Clients) SetSwiftClient(client *gophercloud.ServiceClient) {
+	c.swift = client
+}
+
+// SetLogger sets the logger receiving the clients' log output. The default
+// slog logger is used when none is set.
+func (c *Clients) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+// log returns the logger of the clients.
+func (c *Clients) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return slog.Default()
+}
+
+// poll calls probe every interval, each call bounded by probeTimeout, until
+// it succeeds or ctx is done.
+func poll(ctx context.Context, interval time.Duration, probe func(context.Context) error) {
+	for ctx.Err() == nil {
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := probe(probeCtx)
+		cancel()
+		if err == nil {
+			return
+		}
+		sleep(ctx, interval)
+	}
+}
+
+// probeAPI checks that the API answers its version document with 200 OK.
+func probeAPI(ctx context.Context, client *gophercloud.ServiceClient) error {
+	// NOTE: Some versions of Ironic inspector returns 404 for /v1/ but 200 for /v1,.
+	// which seems to be the default behavior for Flask. Remove the trailing slash
+	// from the client endpoint.
+	endpoint := strings.TrimSuffix(client.Endpoint, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	r, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("ironic API answered %s", r.Status)
+	}
+	return nil
+}
+
+// probeConductor checks that a driver is registered, meaning a conductor is
+// up.
+func probeConductor(ctx context.Context, client *gophercloud.ServiceClient) error {
+	driverCount := 0
+	err := drivers.ListDrivers(client, drivers.ListDriversOpts{
+		Detail: false,
+	}).EachPage(ctx, func(ctx context.Context, page pagination.Page) (bool, error) {
+		actual, err := drivers.ExtractDrivers(page)
+		if err != nil {
+			return false, err
+		}
+		driverCount += len(actual)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	if driverCount == 0 {
+		return errors.New("no conductor registered a driver")
+	}
+	return nil
+}
+
+// callContext returns ctx bounded by the timeout of an Ironic operation,
+// unless it is zero.
+func callContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// callError annotates the error of an Ironic operation that failed because
+// its own timeout passed, as opposed to its caller giving up.
+func callError(ctx, callCtx context.Context, timeout time.Duration, err error) error {
+	if ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: timed out after %s", err, timeout)
+	}
+	return err
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
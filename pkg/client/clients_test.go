@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/fakeironic"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestNewClients(t *testing.T) {
+	srv := fakeironic.New(t)
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "valid", opts: Options{Ironic: srv.ServiceClient(), WaitTimeout: time.Second}},
+		{name: "no ironic client", opts: Options{}, wantErr: true},
+		{
+			name:    "negative timeout",
+			opts:    Options{Ironic: srv.ServiceClient(), WaitTimeout: -time.Second},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClients(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("have error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClients_IronicClient(t *testing.T) {
+	t.Run("waits for a conductor", func(t *testing.T) {
+		srv := fakeironic.New(t)
+		srv.AddNode(nodes.Node{UUID: "node-1"})
+		srv.SetFailure(http.StatusServiceUnavailable)
+		time.AfterFunc(30*time.Millisecond, func() { srv.SetFailure(0) })
+		time.AfterFunc(60*time.Millisecond, func() { srv.SetDrivers("ipmi") })
+
+		c, err := NewClients(Options{
+			Ironic:       srv.ServiceClient(),
+			WaitTimeout:  5 * time.Second,
+			PollInterval: 10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.GetNode(t.Context(), "node-1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		srv := fakeironic.New(t)
+		srv.SetFailure(http.StatusServiceUnavailable)
+
+		c, err := NewClients(Options{
+			Ironic:       srv.ServiceClient(),
+			WaitTimeout:  50 * time.Millisecond,
+			PollInterval: time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.IronicClient(t.Context()); err == nil {
+			t.Fatal("expected error while Ironic is down")
+		}
+
+		srv.SetFailure(0)
+		srv.SetDrivers("ipmi")
+		start := time.Now()
+		if _, err := c.IronicClient(t.Context()); err == nil {
+			t.Error("expected error until the next probe")
+		}
+		if took := time.Since(start); took > 40*time.Millisecond {
+			t.Errorf("waited %s, want no second wait", took)
+		}
+		if c.Available() {
+			t.Error("expected Ironic to be reported down")
+		}
+	})
+
+	t.Run("recovers once Ironic is back", func(t *testing.T) {
+		srv := fakeironic.New(t)
+		srv.SetFailure(http.StatusServiceUnavailable)
+
+		var transitions []bool
+		c, err := NewClients(Options{
+			Ironic:         srv.ServiceClient(),
+			WaitTimeout:    30 * time.Millisecond,
+			PollInterval:   10 * time.Millisecond,
+			OnAvailability: func(up bool) { transitions = append(transitions, up) },
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.IronicClient(t.Context()); err == nil {
+			t.Fatal("expected error while Ironic is down")
+		}
+
+		srv.SetFailure(0)
+		srv.SetDrivers("ipmi")
+		time.Sleep(20 * time.Millisecond)
+		if _, err := c.IronicClient(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !c.Available() {
+			t.Error("expected Ironic to be reported up")
+		}
+		if want := []bool{false, true}; !slices.Equal(transitions, want) {
+			t.Errorf("have transitions %v, want %v", transitions, want)
+		}
+	})
+
+	t.Run("caller giving up", func(t *testing.T) {
+		srv := fakeironic.New(t)
+		srv.SetDrivers("ipmi")
+		srv.SetFailure(http.StatusServiceUnavailable)
+
+		c, err := NewClients(Options{
+			Ironic:       srv.ServiceClient(),
+			WaitTimeout:  5 * time.Second,
+			PollInterval: 10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(t.Context(), 30*time.Millisecond)
+		defer cancel()
+		if _, err := c.IronicClient(ctx); err == nil {
+			t.Fatal("expected error once the caller gave up")
+		}
+
+		srv.SetFailure(0)
+		if _, err := c.IronicClient(t.Context()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestClients_Watch(t *testing.T) {
+	srv := fakeironic.New(t)
+	srv.SetFailure(http.StatusServiceUnavailable)
+
+	c, err := NewClients(Options{
+		Ironic:       srv.ServiceClient(),
+		WaitTimeout:  30 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.IronicClient(t.Context()); err == nil {
+		t.Fatal("expected error while Ironic is down")
+	}
+	if !c.Down() {
+		t.Fatal("expected Ironic to be reported down")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	srv.SetFailure(0)
+	srv.SetDrivers("ipmi")
+	deadline := time.Now().Add(time.Second)
+	for c.Down() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected Ironic to be found back without a request")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !c.Available() {
+		t.Error("expected Ironic to be reported up")
+	}
+}
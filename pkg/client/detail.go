@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+// DefaultDetailWorkers is a number of workers for SetDetailWorkers that
+// keeps a refresh of thousands of nodes short without flooding Ironic.
+const DefaultDetailWorkers = 4
+
+// SetDetailWorkers sets how many node details ListNodes fetches at once. With
+// one or more workers, it lists the UUIDs of the nodes page by page and
+// fetches each node with its own request, pausing the listing while every
+// worker is busy. With zero, the default, it lists the nodes with their
+// details page by page instead, in fewer but larger and sequential requests.
+func (c *Clients) SetDetailWorkers(workers int) {
+	c.detailWorkers = workers
+}
+
+// fetchNodeDetails lists the UUIDs of the nodes matching filter and fetches
+// their details with workers concurrent requests, each bounded by getTimeout unless zero,
+// returning the nodes in listing order. Nodes deleted between being listed
+// and fetched are left out. The first failure cancels the fetches still
+// running.
+func fetchNodeDetails(
+	ctx context.Context, ironicClient *gophercloud.ServiceClient, filter NodeFilter,
+	workers int, getTimeout time.Duration,
+) ([]nodes.Node, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	type job struct {
+		index int
+		uuid  string
+	}
+	var (
+		mu      sync.Mutex
+		fetched []*nodes.Node
+		wg      sync.WaitGroup
+	)
+	// The queue holds no more jobs than there are workers, so listing waits
+	// for the workers instead of queueing every node.
+	jobs := make(chan job, workers)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				node, err := getNode(ctx, ironicClient, j.uuid, getTimeout)
+				if gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+					continue
+				}
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				mu.Lock()
+				fetched[j.index] = node
+				mu.Unlock()
+			}
+		}()
+	}
+
+	filter.Fields = []string{"uuid"}
+	err := eachNode(ctx, ironicClient, filter, func(listed []nodes.Node) error {
+		for _, node := range listed {
+			mu.Lock()
+			index := len(fetched)
+			fetched = append(fetched, nil)
+			mu.Unlock()
+
+			select {
+			case jobs <- job{index: index, uuid: node.UUID}:
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+
+	// A failed fetch also fails the listing, so report its cause first.
+	if cause := context.Cause(ctx); cause != nil {
+		return nil, cause
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]nodes.Node, 0, len(fetched))
+	for _, node := range fetched {
+		if node != nil {
+			all = append(all, *node)
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/fakeironic"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestClients_SetDetailWorkers(t *testing.T) {
+	srv := fakeironic.New(t)
+	srv.SetPageSize(3)
+	var want []string
+	for i := range 10 {
+		uuid := "node-" + string(rune('a'+i))
+		srv.AddNode(nodes.Node{UUID: uuid, Name: "web-" + uuid})
+		want = append(want, uuid)
+	}
+
+	// Count the node details being fetched at once, and fail fetching
+	// node-e with failStatus when set.
+	var inFlight, maxInFlight, failStatus atomic.Int32
+	next := srv.Config.Handler
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/nodes/node-") {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				highest := maxInFlight.Load()
+				if n <= highest || maxInFlight.CompareAndSwap(highest, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if status := failStatus.Load(); status != 0 && r.URL.Path == "/v1/nodes/node-e" {
+				http.Error(w, `{"error_message": "failed"}`, int(status))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+
+	c := &Clients{}
+	c.SetIronicClient(srv.ServiceClient())
+	c.SetDetailWorkers(3)
+
+	all, err := c.ListNodes(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all) != len(want) {
+		t.Fatalf("have %d nodes, want %d", len(all), len(want))
+	}
+	for i, node := range all {
+		if node.UUID != want[i] || node.Name != "web-"+want[i] {
+			t.Errorf("have node %d %s %q, want %s in listing order with details",
+				i, node.UUID, node.Name, want[i])
+		}
+	}
+	if have := maxInFlight.Load(); have > 3 {
+		t.Errorf("have %d details fetched at once, want at most 3", have)
+	}
+
+	// A node deleted after being listed is left out.
+	failStatus.Store(http.StatusNotFound)
+	if all, err = c.ListNodes(t.Context()); err != nil || len(all) != len(want)-1 {
+		t.Errorf("have %d nodes, error %v, want all but node-e", len(all), err)
+	}
+
+	failStatus.Store(http.StatusInternalServerError)
+	_, err = c.ListNodes(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "failed to get node node-e") {
+		t.Errorf("have error %v, want the failure of node-e", err)
+	}
+
+	srv.SetFailure(http.StatusServiceUnavailable)
+	if _, err := c.ListNodes(t.Context()); err == nil {
+		t.Error("expected error while Ironic fails")
+	}
+}
@@ -0,0 +1,24 @@
+// Package client reads the nodes, ports, inspection inventories and
+// allocations of an Ironic deployment, for the metadata service and for
+// other Go tooling built around Ironic.
+//
+// Clients talks to the Ironic API through gophercloud and is built with
+// NewClients:
+//
+//	clients, err := client.NewClients(client.Options{
+//		Ironic:      ironicServiceClient,
+//		WaitTimeout: time.Minute,
+//	})
+//
+// Consumers should depend on the interfaces rather than on Clients:
+// NodeSource for nodes and ports, with the optional InventorySource and
+// AllocationSource, and Resolver for finding the node of a client IP.
+// CachedSource holds the inventory of any NodeSource for a TTL, StaticSource
+// serves nodes from a directory without Ironic, and package mock provides
+// in-memory implementations for tests. Recorder and Replayer record the
+// responses of the Ironic API and serve them again offline.
+//
+// The NodeFilter of Options narrows the nodes listed with the server-side
+// filters of Ironic, and EachNode walks them page by page for tools that
+// need not hold every node at once.
+package client
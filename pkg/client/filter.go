@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/pagination"
+)
+
+// NodeFilter narrows the nodes ListNodes and EachNode list with the
+// server-side filters of the Ironic API, so clouds of tens of thousands of
+// nodes only transfer and hold the nodes served. The zero value lists every
+// node.
+type NodeFilter struct {
+	// ProvisionStates lists only the nodes in one of these provision states,
+	// with a listing per state, since Ironic filters by a single one.
+	ProvisionStates []string
+
+	// Owner lists only the nodes owned by a project.
+	Owner string
+
+	// ConductorGroup lists only the nodes managed by a conductor group.
+	ConductorGroup string
+
+	// Fields lists only these node fields, leaving the others empty, which
+	// bounds the size of each node. The UUID is always listed. It has no
+	// effect on the nodes fetched by detail workers, which come whole.
+	Fields []string
+
+	// PageSize is how many nodes each page lists, bounding the memory a
+	// page takes, or the page size of Ironic when zero.
+	PageSize int
+}
+
+// listOpts returns the list options filtering the nodes in a provision
+// state, or in any when it is empty.
+func (f NodeFilter) listOpts(state string) nodes.ListOpts {
+	opts := nodes.ListOpts{
+		ProvisionState: nodes.ProvisionState(state),
+		Owner:          f.Owner,
+		ConductorGroup: f.ConductorGroup,
+		Limit:          f.PageSize,
+	}
+	if len(f.Fields) > 0 && !slices.Contains(f.Fields, "uuid") {
+		opts.Fields = append([]string{"uuid"}, f.Fields...)
+	} else {
+		opts.Fields = f.Fields
+	}
+	return opts
+}
+
+// states returns the provision states listed one by one, a single empty one
+// listing every state.
+func (f NodeFilter) states() []string {
+	if len(f.ProvisionStates) == 0 {
+		return []string{""}
+	}
+	return f.ProvisionStates
+}
+
+// errStop stops a listing from the function given each node, which is
+// reported as the error of the listing instead.
+type errStop struct {
+	err error
+}
+
+func (e errStop) Error() string {
+	return e.err.Error()
+}
+
+// EachNode calls fn with every node matching the filter of Options, page by
+// page as Ironic serves them, so tools walking large clouds hold a single
+// page of nodes at a time. Nodes come with their details, or with the
+// Fields of the filter only. An error from fn stops the listing and is
+// returned as is.
+func (c *Clients) EachNode(ctx context.Context, fn func(nodes.Node) error) error {
+	ironicClient, err := c.nodeClient(ctx)
+	if err != nil {
+		return err
+	}
+	return eachNode(ctx, ironicClient, c.filter, func(listed []nodes.Node) error {
+		for _, node := range listed {
+			if err := fn(node); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// eachNode lists the nodes matching filter page by page, calling fn with
+// each page. Nodes are listed with details unless the filter lists only
+// some fields.
+func eachNode(
+	ctx context.Context, ironicClient *gophercloud.ServiceClient, filter NodeFilter,
+	fn func([]nodes.Node) error,
+) error {
+	for _, state := range filter.states() {
+		opts := filter.listOpts(state)
+		pager := nodes.ListDetail(ironicClient, opts)
+		if len(opts.Fields) > 0 {
+			pager = nodes.List(ironicClient, opts)
+		}
+		err := pager.EachPage(ctx, func(ctx context.Context, page pagination.Page) (bool, error) {
+			listed, err := nodes.ExtractNodes(page)
+			if err != nil {
+				return false, fmt.Errorf("failed to extract nodes: %w", err)
+			}
+			if err := fn(listed); err != nil {
+				return false, errStop{err: err}
+			}
+			return true, nil
+		})
+		var stop errStop
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list nodes: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/fakeironic"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestClients_NodeFilter(t *testing.T) {
+	srv := fakeironic.New(t)
+	for _, node := range []nodes.Node{
+		{UUID: "node-a", Name: "a", ProvisionState: "active", Owner: "p1", ConductorGroup: "r1"},
+		{UUID: "node-b", Name: "b", ProvisionState: "available", Owner: "p1", ConductorGroup: "r1"},
+		{UUID: "node-c", Name: "c", ProvisionState: "wait call-back", Owner: "p2"},
+		{UUID: "node-d", Name: "d", ProvisionState: "active", Owner: "p2", ConductorGroup: "r1"},
+	} {
+		srv.AddNode(node)
+	}
+
+	tests := []struct {
+		name     string
+		filter   NodeFilter
+		workers  int
+		want     []string
+		wantName bool
+	}{
+		{name: "all", want: []string{"node-a", "node-b", "node-c", "node-d"}, wantName: true},
+		{
+			name:     "provision states",
+			filter:   NodeFilter{ProvisionStates: []string{"active", "wait call-back"}},
+			want:     []string{"node-a", "node-d", "node-c"},
+			wantName: true,
+		},
+		{
+			name:     "owner and conductor group",
+			filter:   NodeFilter{Owner: "p2", ConductorGroup: "r1", PageSize: 1},
+			want:     []string{"node-d"},
+			wantName: true,
+		},
+		{
+			name:   "fields",
+			filter: NodeFilter{Owner: "p1", Fields: []string{"provision_state"}},
+			want:   []string{"node-a", "node-b"},
+		},
+		{
+			name:     "detail workers",
+			filter:   NodeFilter{ProvisionStates: []string{"active"}, PageSize: 1},
+			workers:  2,
+			want:     []string{"node-a", "node-d"},
+			wantName: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClients(Options{
+				Ironic:        srv.ServiceClient(),
+				NodeFilter:    tt.filter,
+				DetailWorkers: tt.workers,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			all, err := c.ListNodes(t.Context())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var have []string
+			for _, node := range all {
+				have = append(have, node.UUID)
+				if (node.Name != "") != tt.wantName {
+					t.Errorf("have node %s named %q, want name %t",
+						node.UUID, node.Name, tt.wantName)
+				}
+				if node.ProvisionState == "" {
+					t.Errorf("have node %s without provision state", node.UUID)
+				}
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("have nodes %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestClients_EachNode(t *testing.T) {
+	srv := fakeironic.New(t)
+	for _, uuid := range []string{"node-a", "node-b", "node-c", "node-d", "node-e"} {
+		srv.AddNode(nodes.Node{UUID: uuid})
+	}
+	c, err := NewClients(Options{
+		Ironic:     srv.ServiceClient(),
+		NodeFilter: NodeFilter{PageSize: 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var have []string
+	if err := c.EachNode(t.Context(), func(node nodes.Node) error {
+		have = append(have, node.UUID)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"node-a", "node-b", "node-c", "node-d", "node-e"}
+	if !slices.Equal(have, want) {
+		t.Errorf("have nodes %v, want %v", have, want)
+	}
+	var pages int
+	for _, request := range srv.Requests() {
+		if strings.HasPrefix(request, "GET /v1/nodes/detail") {
+			pages++
+		}
+	}
+	if pages != 3 {
+		t.Errorf("have %d pages listed, want 3", pages)
+	}
+
+	// An error of the function stops the listing.
+	stop := errors.New("stop")
+	have = nil
+	err = c.EachNode(t.Context(), func(node nodes.Node) error {
+		have = append(have, node.UUID)
+		return stop
+	})
+	if !errors.Is(err, stop) || len(have) != 1 {
+		t.Errorf("have error %v after %v, want the error of the first node", err, have)
+	}
+}
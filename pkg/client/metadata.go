@@ -1,149 +0,0 @@
-package client
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"strings"
-	"sync"
-	"time"
-
-	"github.com/gophercloud/gophercloud/v2"
-	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/drivers"
-	"github.com/gophercloud/gophercloud/v2/pagination"
-)
-
-// Clients stores the client connection information for Ironic.
-type Clients struct {
-	ironic *gophercloud.ServiceClient
-
-	// Boolean that determines if Ironic API was previously determined to be available, we don't need to try every time.
-	ironicUp bool
-
-	// Boolean that determines we've already waited and the API never came up, we don't need to wait again.
-	ironicFailed bool
-
-	// Mutex so that only one resource being created by terraform checks at a time. There's no reason to have multiple
-	// resources calling out to the API.
-	ironicMux sync.Mutex
-
-	timeout int
-}
-
-// GetIronicClient returns the API client for Ironic, optionally retrying to reach the API if timeout is set.
-func (c *Clients) GetIronicClient() (*gophercloud.ServiceClient, error) {
-	// Terraform concurrently creates some resources which means multiple callers can request an Ironic client. We
-	// only need to check if the API is available once, so we use a mux to restrict one caller to polling the API.
-	// When the mux is released, the other callers will fall through to the check for ironicUp.
-	c.ironicMux.Lock()
-	defer c.ironicMux.Unlock()
-
-	// Ironic is UP, or user didn't ask us to check.
-	if c.ironicUp || c.timeout == 0 {
-		return c.ironic, nil
-	}
-
-	// We previously tried and it failed.
-	if c.ironicFailed {
-		return nil, fmt.Errorf("could not contact Ironic API: timeout reached")
-	}
-
-	// Let's poll the API until it's up, or times out.
-	duration := time.Duration(c.timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), duration)
-	defer cancel()
-
-	done := make(chan struct{})
-	go func() {
-		log.Printf("[INFO] Waiting for Ironic API...")
-		waitForAPI(ctx, c.ironic)
-		log.Printf("[INFO] API successfully connected, waiting for conductor...")
-		waitForConductor(ctx, c.ironic)
-		close(done)
-	}()
-
-	// Wait for done or time out.
-	select {
-	case <-ctx.Done():
-		if err := ctx.Err(); err != nil {
-			c.ironicFailed = true
-			return nil, fmt.Errorf("could not contact Ironic API: %w", err)
-		}
-	case <-done:
-	}
-
-	c.ironicUp = true
-	return c.ironic, ctx.Err()
-}
-
-// Retries an API forever until it responds.
-func waitForAPI(ctx context.Context, client *gophercloud.ServiceClient) {
-	httpClient := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	// NOTE: Some versions of Ironic inspector returns 404 for /v1/ but 200 for /v1,.
-	// which seems to be the default behavior for Flask. Remove the trailing slash
-	// from the client endpoint.
-	endpoint := strings.TrimSuffix(client.Endpoint, "/")
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			log.Printf("[DEBUG] Waiting for API to become available...")
-
-			r, err := httpClient.Get(endpoint)
-			if err == nil {
-				statusCode := r.StatusCode
-				if closeErr := r.Body.Close(); closeErr != nil {
-					// Use standard log package as it's already imported.
-					log.Printf("Failed to close response body: %v", closeErr)
-				}
-				if statusCode == http.StatusOK {
-					return
-				}
-			}
-
-			time.Sleep(5 * time.Second)
-		}
-	}
-}
-
-// Ironic conductor can be considered up when the driver count returns non-zero.
-func waitForConductor(ctx context.Context, client *gophercloud.ServiceClient) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			log.Printf("[DEBUG] Waiting for conductor API to become available...")
-			driverCount := 0
-
-			err := drivers.ListDrivers(client, drivers.ListDriversOpts{
-				Detail: false,
-			}).EachPage(ctx, func(ctx context.Context, page pagination.Page) (bool, error) {
-				actual, err := drivers.ExtractDrivers(page)
-				if err != nil {
-					return false, err
-				}
-				driverCount += len(actual)
-				return true, nil
-			})
-			// If we have any drivers, conductor is up.
-			if err == nil && driverCount > 0 {
-				return
-			}
-
-			time.Sleep(5 * time.Second)
-		}
-	}
-}
-
-// SetIronicClient sets the Ironic client.
-func (c *Clients) SetIronicClient(client *gophercloud.ServiceClient) {
-	c.ironic = client
-	c.ironicUp = true
-}
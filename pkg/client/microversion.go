@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+// Ironic API versions adding node fields the metadata is built from.
+const (
+	// lesseeMicroversion is the first version reporting the lessee of nodes.
+	lesseeMicroversion = "1.65"
+
+	// networkDataMicroversion is the first version reporting the
+	// network_data of nodes.
+	networkDataMicroversion = "1.66"
+)
+
+// nodeMicroversion is the Ironic API version nodes are read with, the first
+// reporting every node field the metadata is built from.
+const nodeMicroversion = networkDataMicroversion
+
+// maxVersionHeader announces the newest API version Ironic supports, which
+// the version document of /v1/ repeats.
+const maxVersionHeader = "X-OpenStack-Ironic-API-Maximum-Version"
+
+// nodeClient returns the Ironic client for reading nodes. It asks for
+// nodeMicroversion, or the newest version of an older Ironic, so nodes come
+// with the owner, lessee and network_data fields Ironic's default version
+// leaves out.
+func (c *Clients) nodeClient(ctx context.Context) (*gophercloud.ServiceClient, error) {
+	ironicClient, err := c.IronicClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ironic client: %w", err)
+	}
+
+	version := c.negotiateNodeMicroversion(ctx, ironicClient)
+	if version == "" {
+		return ironicClient, nil
+	}
+	// Clients built by hand, such as for standalone Ironic, have no type, and
+	// gophercloud sends microversions only for typed clients.
+	nodeClient := *ironicClient
+	nodeClient.Type = "baremetal"
+	nodeClient.Microversion = version
+	return &nodeClient, nil
+}
+
+// negotiateNodeMicroversion returns the version to read nodes with, asking
+// Ironic for the versions it supports the first time. It returns empty when
+// Ironic does not announce them, and retries on the next call when it cannot
+// be reached.
+func (c *Clients) negotiateNodeMicroversion(
+	ctx context.Context,
+	ironicClient *gophercloud.ServiceClient,
+) string {
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+
+	if c.versionNegotiated {
+		return c.nodeVersion
+	}
+
+	var root struct {
+		Version struct {
+			Version string `json:"version"`
+		} `json:"version"`
+	}
+	resp, err := ironicClient.Get(ctx, ironicClient.ServiceURL(), &root, nil)
+	if err != nil {
+		c.log().Warn("Failed to negotiate the Ironic API version", "error", err)
+		return ""
+	}
+
+	maxVersion := resp.Header.Get(maxVersionHeader)
+	if maxVersion == "" {
+		maxVersion = root.Version.Version
+	}
+	switch {
+	case maxVersion == "":
+		c.nodeVersion = ""
+	case compareMicroversions(maxVersion, lesseeMicroversion) < 0:
+		c.log().Info("Ironic does not report node lessees",
+			"max_version", maxVersion, "want_version", lesseeMicroversion)
+		c.nodeVersion = maxVersion
+	case compareMicroversions(maxVersion, networkDataMicroversion) < 0:
+		c.log().Info("Ironic does not report node network data",
+			"max_version", maxVersion, "want_version", networkDataMicroversion)
+		c.nodeVersion = maxVersion
+	default:
+		c.nodeVersion = nodeMicroversion
+	}
+	c.versionNegotiated = true
+	return c.nodeVersion
+}
+
+// compareMicroversions compares two versions such as 1.65, returning a
+// negative number when a is older than b, zero when they are equal and a
+// positive one otherwise. Unparsable parts count as zero.
+func compareMicroversions(a, b string) int {
+	parse := func(version string) (int, int) {
+		major, minor, _ := strings.Cut(version, ".")
+		majorNumber, _ := strconv.Atoi(major)
+		minorNumber, _ := strconv.Atoi(minor)
+		return majorNumber, minorNumber
+	}
+	aMajor, aMinor := parse(a)
+	bMajor, bMinor := parse(b)
+	if aMajor != bMajor {
+		return aMajor - bMajor
+	}
+	return aMinor - bMinor
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+func TestClients_nodeMicroversion(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxVersion string
+		body       string
+		want       string
+	}{
+		{name: "newer ironic", maxVersion: "1.89", want: nodeMicroversion},
+		{name: "older ironic", maxVersion: "1.58", want: "1.58"},
+		{name: "ironic without node network data", maxVersion: "1.65", want: "1.65"},
+		{name: "version document", body: `{"version": {"version": "1.70"}}`,
+			want: nodeMicroversion},
+		{name: "no versions", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var roots int
+			var have string
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					switch r.URL.Path {
+					case "/v1/":
+						roots++
+						if tt.maxVersion != "" {
+							w.Header().Set(maxVersionHeader, tt.maxVersion)
+						}
+						body := tt.body
+						if body == "" {
+							body = `{"id": "v1"}`
+						}
+						_, _ = w.Write([]byte(body))
+					case "/v1/nodes/node-1":
+						have = r.Header.Get("X-OpenStack-Ironic-API-Version")
+						_, _ = w.Write([]byte(`{"uuid": "node-1", "lessee": "project-1"}`))
+					default:
+						http.Error(w, `{"error_message": "not found"}`, http.StatusNotFound)
+					}
+				}))
+			defer srv.Close()
+
+			c := &Clients{}
+			c.SetIronicClient(&gophercloud.ServiceClient{
+				ProviderClient: &gophercloud.ProviderClient{},
+				Endpoint:       srv.URL + "/v1/",
+			})
+			for range 2 {
+				node, err := c.GetNode(t.Context(), "node-1")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if node.Lessee != "project-1" {
+					t.Errorf("have lessee %q, want project-1", node.Lessee)
+				}
+				if have != tt.want {
+					t.Errorf("have version %q, want %q", have, tt.want)
+				}
+			}
+			if roots != 1 {
+				t.Errorf("have %d version requests, want 1", roots)
+			}
+		})
+	}
+}
+
+func TestCompareMicroversions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.65", b: "1.65", want: 0},
+		{a: "1.9", b: "1.65", want: -1},
+		{a: "1.81", b: "1.65", want: 1},
+		{a: "2.1", b: "1.99", want: 1},
+	}
+
+	for _, tt := range tests {
+		have := compareMicroversions(tt.a, tt.b)
+		if (have > 0) != (tt.want > 0) || (have < 0) != (tt.want < 0) {
+			t.Errorf("compareMicroversions(%q, %q) = %d, want sign of %d", tt.a, tt.b, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package mock provides in-memory implementations of the client interfaces,
+// so handlers can be tested without an Ironic API.
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/allocations"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+// Methods of NodeSource, as counted by Calls.
+const (
+	MethodListNodes      = "ListNodes"
+	MethodGetNode        = "GetNode"
+	MethodListPorts      = "ListPorts"
+	MethodListPortsByMAC = "ListPortsByMAC"
+	MethodGetInventory   = "GetInventory"
+	MethodGetAllocation  = "GetAllocation"
+	MethodUpdateNode     = "UpdateNode"
+)
+
+// NodeSource is a client.NodeSource, client.InventorySource,
+// client.AllocationSource and client.NodeWriter serving the nodes, ports,
+// inventories and allocations added to it. It is safe for concurrent use.
+type NodeSource struct {
+	mu          sync.Mutex
+	nodes       []nodes.Node
+	ports       []ports.Port
+	inventories map[string]*nodes.InventoryData
+	allocations []allocations.Allocation
+	err         error
+	calls       map[string]int
+}
+
+// NewNodeSource returns a NodeSource serving nodes.
+func NewNodeSource(nodes ...nodes.Node) *NodeSource {
+	s := &NodeSource{}
+	for _, node := range nodes {
+		s.AddNode(node)
+	}
+	return s
+}
+
+// AddNode adds a node, replacing any node with the same UUID.
+func (s *NodeSource) AddNode(node nodes.Node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.nodes {
+		if s.nodes[i].UUID == node.UUID {
+			s.nodes[i] = node
+			return
+		}
+	}
+	s.nodes = append(s.nodes, node)
+}
+
+// AddPort adds a port.
+func (s *NodeSource) AddPort(port ports.Port) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ports = append(s.ports, port)
+}
+
+// AddInventory adds the inspection inventory of the node with a UUID,
+// replacing any earlier inventory.
+func (s *NodeSource) AddInventory(nodeUUID string, data *nodes.InventoryData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inventories == nil {
+		s.inventories = make(map[string]*nodes.InventoryData)
+	}
+	s.inventories[nodeUUID] = data
+}
+
+// AddAllocation adds an allocation, replacing any allocation with the same
+// UUID.
+func (s *NodeSource) AddAllocation(allocation allocations.Allocation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.allocations {
+		if s.allocations[i].UUID == allocation.UUID {
+			s.allocations[i] = allocation
+			return
+		}
+	}
+	s.allocations = append(s.allocations, allocation)
+}
+
+// SetError makes every call fail with err until it is set to nil.
+func (s *NodeSource) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// Calls returns how many times a method, such as MethodGetNode, was called.
+func (s *NodeSource) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// call records a call to method and returns the error set by SetError.
+func (s *NodeSource) call(method string) error {
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[method]++
+	return s.err
+}
+
+// ListNodes returns the nodes.
+func (s *NodeSource) ListNodes(context.Context) ([]nodes.Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodListNodes); err != nil {
+		return nil, err
+	}
+	return append([]nodes.Node(nil), s.nodes...), nil
+}
+
+// GetNode returns the node with a UUID or name, failing like the Ironic API
+// with status 404 when there is none.
+func (s *NodeSource) GetNode(_ context.Context, id string) (*nodes.Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGetNode); err != nil {
+		return nil, err
+	}
+	for _, node := range s.nodes {
+		if node.UUID == id || (node.Name != "" && node.Name == id) {
+			return &node, nil
+		}
+	}
+	return nil, gophercloud.ErrUnexpectedResponseCode{
+		URL:      "nodes/" + id,
+		Method:   http.MethodGet,
+		Expected: []int{http.StatusOK},
+		Actual:   http.StatusNotFound,
+	}
+}
+
+// GetInventory returns the inspection inventory of the node with a UUID or
+// name, failing like the Ironic API with status 404 when the node does not
+// exist or has none.
+func (s *NodeSource) GetInventory(_ context.Context, id string) (*nodes.InventoryData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGetInventory); err != nil {
+		return nil, err
+	}
+	for _, node := range s.nodes {
+		if node.UUID != id && (node.Name == "" || node.Name != id) {
+			continue
+		}
+		if data, ok := s.inventories[node.UUID]; ok {
+			return data, nil
+		}
+	}
+	return nil, gophercloud.ErrUnexpectedResponseCode{
+		URL:      "nodes/" + id + "/inventory",
+		Method:   http.MethodGet,
+		Expected: []int{http.StatusOK},
+		Actual:   http.StatusNotFound,
+	}
+}
+
+// GetAllocation returns the allocation with a UUID or name, failing like the
+// Ironic API with status 404 when there is none.
+func (s *NodeSource) GetAllocation(
+	_ context.Context,
+	id string,
+) (*allocations.Allocation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGetAllocation); err != nil {
+		return nil, err
+	}
+	for _, allocation := range s.allocations {
+		if allocation.UUID == id || (allocation.Name != "" && allocation.Name == id) {
+			return &allocation, nil
+		}
+	}
+	return nil, gophercloud.ErrUnexpectedResponseCode{
+		URL:      "allocations/" + id,
+		Method:   http.MethodGet,
+		Expected: []int{http.StatusOK},
+		Actual:   http.StatusNotFound,
+	}
+}
+
+// UpdateNode applies the operations of opts to the node with a UUID or
+// name, failing like the Ironic API with status 404 when there is none.
+// Operations may add, replace or remove the keys of its instance_info,
+// extra, properties and driver_info, given by paths such as
+// /instance_info/user_data.
+func (s *NodeSource) UpdateNode(
+	_ context.Context,
+	id string,
+	opts nodes.UpdateOpts,
+) (*nodes.Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodUpdateNode); err != nil {
+		return nil, err
+	}
+	for i := range s.nodes {
+		node := &s.nodes[i]
+		if node.UUID != id && (node.Name == "" || node.Name != id) {
+			continue
+		}
+		// Nodes returned earlier share the maps of the stored node.
+		updated := *node
+		updated.InstanceInfo = maps.Clone(node.InstanceInfo)
+		updated.Extra = maps.Clone(node.Extra)
+		updated.Properties = maps.Clone(node.Properties)
+		updated.DriverInfo = maps.Clone(node.DriverInfo)
+		for _, patch := range opts {
+			if err := update(&updated, patch); err != nil {
+				return nil, err
+			}
+		}
+		*node = updated
+		return &updated, nil
+	}
+	return nil, gophercloud.ErrUnexpectedResponseCode{
+		URL:      "nodes/" + id,
+		Method:   http.MethodPatch,
+		Expected: []int{http.StatusOK},
+		Actual:   http.StatusNotFound,
+	}
+}
+
+// update applies an update operation to a node.
+func update(node *nodes.Node, patch nodes.Patch) error {
+	operation, ok := patch.(nodes.UpdateOperation)
+	if !ok {
+		return fmt.Errorf("unsupported update %T", patch)
+	}
+	field, key, _ := strings.Cut(strings.TrimPrefix(operation.Path, "/"), "/")
+	fields := map[string]*map[string]any{
+		"instance_info": &node.InstanceInfo,
+		"extra":         &node.Extra,
+		"properties":    &node.Properties,
+		"driver_info":   &node.DriverInfo,
+	}
+	values, ok := fields[field]
+	if !ok || key == "" || strings.Contains(key, "/") {
+		return fmt.Errorf("unsupported update path %s", operation.Path)
+	}
+
+	switch operation.Op {
+	case nodes.AddOp, nodes.ReplaceOp:
+		// Values are stored as the Ironic API would return them.
+		b, err := json.Marshal(operation.Value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", operation.Path, err)
+		}
+		var value any
+		if err := json.Unmarshal(b, &value); err != nil {
+			return fmt.Errorf("failed to unmarshal %s: %w", operation.Path, err)
+		}
+		if *values == nil {
+			*values = make(map[string]any)
+		}
+		(*values)[key] = value
+	case nodes.RemoveOp:
+		if _, ok := (*values)[key]; !ok {
+			return fmt.Errorf("cannot remove missing %s", operation.Path)
+		}
+		delete(*values, key)
+	default:
+		return fmt.Errorf("unsupported update operation %s", operation.Op)
+	}
+	return nil
+}
+
+// ListPorts returns the ports.
+func (s *NodeSource) ListPorts(context.Context) ([]ports.Port, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodListPorts); err != nil {
+		return nil, err
+	}
+	return append([]ports.Port(nil), s.ports...), nil
+}
+
+// ListPortsByMAC returns the ports with a MAC address, compared
+// case-insensitively.
+func (s *NodeSource) ListPortsByMAC(_ context.Context, mac string) ([]ports.Port, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodListPortsByMAC); err != nil {
+		return nil, err
+	}
+	var matched []ports.Port
+	for _, port := range s.ports {
+		if strings.EqualFold(port.Address, mac) {
+			matched = append(matched, port)
+		}
+	}
+	return matched, nil
+}
+
+// Resolver is a client.Resolver mapping client IP addresses to nodes.
+type Resolver struct {
+	// Nodes maps client IP addresses to their node.
+	Nodes map[string]*nodes.Node
+
+	// Err, when set, is returned by every call to Resolve.
+	Err error
+}
+
+// Name returns "mock".
+func (r *Resolver) Name() string {
+	return "mock"
+}
+
+// Resolve returns the node of clientIP, or nil when there is none.
+func (r *Resolver) Resolve(_ context.Context, clientIP string) (*nodes.Node, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return r.Nodes[clientIP], nil
+}
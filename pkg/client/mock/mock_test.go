@@ -0,0 +1,141 @@
+package mock
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestNodeSource(t *testing.T) {
+	s := NewNodeSource(nodes.Node{UUID: "node-1", Name: "web01"})
+	s.AddNode(nodes.Node{UUID: "node-1", Name: "web02"})
+	s.AddPort(ports.Port{UUID: "port-1", Address: "52:54:00:AB:CD:EF", NodeUUID: "node-1"})
+
+	all, err := s.ListNodes(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all) != 1 || all[0].Name != "web02" {
+		t.Errorf("have nodes %+v, want node-1 replaced", all)
+	}
+
+	tests := []struct {
+		id       string
+		wantNode string
+		wantCode int
+	}{
+		{id: "node-1", wantNode: "node-1"},
+		{id: "web02", wantNode: "node-1"},
+		{id: "web01", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		node, err := s.GetNode(t.Context(), tt.id)
+		if tt.wantCode != 0 {
+			if !gophercloud.ResponseCodeIs(err, tt.wantCode) {
+				t.Errorf("GetNode(%q): have error %v, want status %d", tt.id, err, tt.wantCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("GetNode(%q): unexpected error: %v", tt.id, err)
+		}
+		if node.UUID != tt.wantNode {
+			t.Errorf("GetNode(%q): have %q, want %q", tt.id, node.UUID, tt.wantNode)
+		}
+	}
+
+	matched, err := s.ListPortsByMAC(t.Context(), "52:54:00:ab:cd:ef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(matched) != 1 || matched[0].UUID != "port-1" {
+		t.Errorf("have ports %+v, want port-1", matched)
+	}
+
+	if have := s.Calls(MethodGetNode); have != len(tests) {
+		t.Errorf("have %d GetNode calls, want %d", have, len(tests))
+	}
+
+	want := errors.New("unavailable")
+	s.SetError(want)
+	if _, err := s.ListNodes(t.Context()); !errors.Is(err, want) {
+		t.Errorf("have error %v, want %v", err, want)
+	}
+}
+
+func TestNodeSource_UpdateNode(t *testing.T) {
+	s := NewNodeSource(nodes.Node{
+		UUID:         "node-1",
+		Name:         "web01",
+		InstanceInfo: map[string]any{"password": "old"},
+	})
+	before, err := s.GetNode(t.Context(), "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := s.UpdateNode(t.Context(), "web01", nodes.UpdateOpts{
+		nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/instance_info/password"},
+		nodes.UpdateOperation{
+			Op:    nodes.AddOp,
+			Path:  "/extra/kv",
+			Value: map[string]string{"role": "web"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := updated.InstanceInfo["password"]; ok {
+		t.Errorf("have instance_info %v, want the password removed", updated.InstanceInfo)
+	}
+	kv, _ := updated.Extra["kv"].(map[string]any)
+	if kv["role"] != "web" {
+		t.Errorf("have extra %v, want kv stored as JSON", updated.Extra)
+	}
+	if before.InstanceInfo["password"] != "old" {
+		t.Errorf("have instance_info %v before the update, want it unchanged",
+			before.InstanceInfo)
+	}
+	if stored, _ := s.GetNode(t.Context(), "node-1"); stored.Extra["kv"] == nil {
+		t.Errorf("have extra %v, want the update stored", stored.Extra)
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		patch    nodes.UpdateOperation
+		wantCode int
+	}{
+		{
+			name:     "missing node",
+			id:       "node-2",
+			patch:    nodes.UpdateOperation{Op: nodes.AddOp, Path: "/extra/a", Value: "a"},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:  "unsupported path",
+			id:    "node-1",
+			patch: nodes.UpdateOperation{Op: nodes.AddOp, Path: "/name", Value: "a"},
+		},
+		{
+			name:  "remove missing key",
+			id:    "node-1",
+			patch: nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/extra/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.UpdateNode(t.Context(), tt.id, nodes.UpdateOpts{tt.patch})
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if tt.wantCode != 0 && !gophercloud.ResponseCodeIs(err, tt.wantCode) {
+				t.Errorf("have error %v, want status %d", err, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recording is a response of the Ironic API recorded by a Recorder, stored
+// as a JSON file of its own.
+type Recording struct {
+	Method string `json:"method"`
+
+	// Path is the path of the request below the endpoint, with its query
+	// parameters sorted, such as nodes/detail?limit=100.
+	Path string `json:"path"`
+
+	Status int `json:"status"`
+
+	// Header holds the Content-Type and OpenStack headers of the response,
+	// such as the API versions Ironic supports.
+	Header http.Header `json:"header,omitempty"`
+
+	// Body is the body of the response when it is JSON, and Text when it is
+	// not.
+	Body json.RawMessage `json:"body,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// Recorder is an http.RoundTripper recording the responses of the Ironic
+// API at an endpoint to a directory, for a Replayer to serve them without
+// Ironic, such as to reproduce a bug reported from the field. Requests
+// outside the endpoint, such as those to Keystone or object storage, pass
+// through unrecorded. A response recorded again replaces the earlier one.
+type Recorder struct {
+	endpoint string
+	dir      string
+	next     http.RoundTripper
+
+	// OnError is called when a response cannot be recorded. The response
+	// is still returned.
+	OnError func(err error)
+}
+
+// NewRecorder returns a Recorder of the responses to requests made through
+// next, or http.DefaultTransport when nil, to the endpoint, recording them
+// in dir, which is created if missing.
+func NewRecorder(endpoint, dir string, next http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{endpoint: withSlash(endpoint), dir: dir, next: next}, nil
+}
+
+// RoundTrip makes a request and records its response.
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	path, ok := recordingPath(rec.endpoint, r)
+	if !ok {
+		return rec.next.RoundTrip(r)
+	}
+	resp, err := rec.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	recording := Recording{
+		Method: r.Method,
+		Path:   path,
+		Status: resp.StatusCode,
+		Header: make(http.Header),
+	}
+	for name, values := range resp.Header {
+		if name == "Content-Type" || strings.Contains(name, "Openstack") {
+			recording.Header[name] = values
+		}
+	}
+	if json.Valid(body) {
+		recording.Body = body
+	} else {
+		recording.Text = string(body)
+	}
+	if err := rec.write(&recording); err != nil && rec.OnError != nil {
+		rec.OnError(fmt.Errorf("failed to record %s %s: %w", r.Method, path, err))
+	}
+	return resp, nil
+}
+
+// write stores a recording, replacing the file in one step so a Replayer
+// never reads half of it.
+func (rec *Recorder) write(recording *Recording) error {
+	b, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(rec.dir, ".recording-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(rec.dir, recordingFile(recording.Method,
+		recording.Path)))
+}
+
+// Replayer is an http.RoundTripper serving the responses of the Ironic API
+// at an endpoint from the recordings of a Recorder, without Ironic.
+// Requests that were not recorded are answered 404 Not Found, as for nodes
+// that do not exist, and requests outside the endpoint fail.
+type Replayer struct {
+	endpoint string
+	dir      string
+}
+
+// NewReplayer returns a Replayer of the recordings in dir of the responses
+// of the Ironic API at endpoint.
+func NewReplayer(endpoint, dir string) *Replayer {
+	return &Replayer{endpoint: withSlash(endpoint), dir: dir}
+}
+
+// RoundTrip answers a request with its recorded response.
+func (rep *Replayer) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		_ = r.Body.Close()
+	}
+	path, ok := recordingPath(rep.endpoint, r)
+	if !ok {
+		return nil, fmt.Errorf("cannot replay %s %s outside the Ironic endpoint %s",
+			r.Method, r.URL, rep.endpoint)
+	}
+
+	recording := Recording{
+		Status: http.StatusNotFound,
+		Header: http.Header{"Content-Type": {"application/json"}},
+	}
+	b, err := os.ReadFile(filepath.Join(rep.dir, recordingFile(r.Method, path)))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		recording.Body, _ = json.Marshal(map[string]string{
+			"error_message": fmt.Sprintf("%s %s was not recorded", r.Method, path),
+		})
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, &recording); err != nil {
+			return nil, fmt.Errorf("invalid recording of %s %s: %w", r.Method, path, err)
+		}
+	}
+
+	body := []byte(recording.Body)
+	if recording.Body == nil {
+		body = []byte(recording.Text)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recording.Status, http.StatusText(recording.Status)),
+		StatusCode:    recording.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recording.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
+
+// recordingPath returns the path of a request below the endpoint, with its
+// query parameters sorted so they match however they were ordered.
+func recordingPath(endpoint string, r *http.Request) (string, bool) {
+	u := *r.URL
+	u.RawQuery, u.Fragment = "", ""
+	s := u.String()
+	var path string
+	switch {
+	case s+"/" == endpoint:
+	case strings.HasPrefix(s, endpoint):
+		path = strings.TrimPrefix(s, endpoint)
+	default:
+		return "", false
+	}
+	if query := r.URL.Query().Encode(); query != "" {
+		path += "?" + query
+	}
+	return path, true
+}
+
+// recordingFile returns the name of the file of the recording of a request,
+// readable but made unique by a digest of the request.
+func recordingFile(method, path string) string {
+	digest := sha256.Sum256([]byte(method + " " + path))
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, method+"_"+path)
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name + "-" + hex.EncodeToString(digest[:4]) + ".json"
+}
+
+// withSlash returns an endpoint ending in a slash.
+func withSlash(endpoint string) string {
+	if !strings.HasSuffix(endpoint, "/") {
+		return endpoint + "/"
+	}
+	return endpoint
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/fakeironic"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestRecorder_replay(t *testing.T) {
+	srv := fakeironic.New(t)
+	srv.AddNode(nodes.Node{UUID: "node-1", Name: "web01", ProvisionState: "active"})
+	srv.AddNode(nodes.Node{UUID: "node-2", Name: "web02", ProvisionState: "active"})
+	srv.AddPort(ports.Port{UUID: "port-1", NodeUUID: "node-1", Address: "52:54:00:00:00:01"})
+	srv.SetPageSize(1)
+
+	dir := filepath.Join(t.TempDir(), "recording")
+	recorded := srv.ServiceClient()
+	recorder, err := NewRecorder(recorded.Endpoint, dir, recorded.HTTPClient.Transport)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorder.OnError = func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	recorded.HTTPClient.Transport = recorder
+
+	// Make the calls of a request, then serve them again from the
+	// recording once Ironic is gone.
+	serve := func(ironic *gophercloud.ServiceClient) ([]string, []string) {
+		t.Helper()
+		c, err := NewClients(Options{Ironic: ironic, WaitTimeout: time.Second})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		all, err := c.ListNodes(t.Context())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var names []string
+		for _, node := range all {
+			names = append(names, node.Name)
+		}
+		node, err := c.GetNode(t.Context(), "web01")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		macs, err := c.ListPortsByMAC(t.Context(), "52:54:00:00:00:01")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var owners []string
+		for _, port := range macs {
+			owners = append(owners, port.NodeUUID)
+		}
+		return append(names, node.UUID), owners
+	}
+	wantNodes, wantOwners := serve(recorded)
+	srv.Close()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("expected recordings")
+	}
+
+	endpoint := srv.Endpoint()
+	replayed := &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{
+			HTTPClient: http.Client{Transport: NewReplayer(endpoint, dir)},
+		},
+		Endpoint: endpoint,
+	}
+	haveNodes, haveOwners := serve(replayed)
+	if !slices.Equal(haveNodes, wantNodes) || !slices.Equal(haveOwners, wantOwners) {
+		t.Errorf("have nodes %v and ports of %v, want %v and %v", haveNodes, haveOwners,
+			wantNodes, wantOwners)
+	}
+
+	c, err := NewClients(Options{Ironic: replayed})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetNode(t.Context(), "node-3"); !gophercloud.ResponseCodeIs(err,
+		http.StatusNotFound) {
+		t.Errorf("have error %v, want 404 Not Found for a call never recorded", err)
+	}
+}
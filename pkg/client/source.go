@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/allocations"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+// NodeSource reads the nodes and ports the metadata service is built from.
+// Clients implements it with the Ironic API; package mock provides an
+// in-memory implementation for tests.
+type NodeSource interface {
+	// ListNodes returns every node with its details.
+	ListNodes(ctx context.Context) ([]nodes.Node, error)
+
+	// GetNode returns the node with a UUID or name. A node that does not
+	// exist is reported with a gophercloud.ErrUnexpectedResponseCode
+	// carrying status 404.
+	GetNode(ctx context.Context, id string) (*nodes.Node, error)
+
+	// ListPorts returns every port with its details.
+	ListPorts(ctx context.Context) ([]ports.Port, error)
+
+	// ListPortsByMAC returns the ports with a MAC address.
+	ListPortsByMAC(ctx context.Context, mac string) ([]ports.Port, error)
+}
+
+// InventorySource is implemented by node sources that can read the hardware
+// inventory inspection recorded for a node.
+type InventorySource interface {
+	// GetInventory returns the inventory and plugin data of the node with a
+	// UUID or name. A node never inspected is reported with a
+	// gophercloud.ErrUnexpectedResponseCode carrying status 404.
+	GetInventory(ctx context.Context, id string) (*nodes.InventoryData, error)
+}
+
+// AllocationSource is implemented by node sources that can read the
+// allocations nodes are reserved through.
+type AllocationSource interface {
+	// GetAllocation returns the allocation with a UUID or name. An
+	// allocation that does not exist is reported with a
+	// gophercloud.ErrUnexpectedResponseCode carrying status 404.
+	GetAllocation(ctx context.Context, id string) (*allocations.Allocation, error)
+}
+
+// NodeWriter is implemented by node sources that can update nodes. Its
+// GetNode reads nodes uncached, so updates are based on the stored node
+// rather than one cached before an earlier update.
+type NodeWriter interface {
+	// GetNode returns the node with a UUID or name. A node that does not
+	// exist is reported with a gophercloud.ErrUnexpectedResponseCode
+	// carrying status 404.
+	GetNode(ctx context.Context, id string) (*nodes.Node, error)
+
+	// UpdateNode applies the JSON patch operations of opts to the node
+	// with a UUID or name, returning the updated node.
+	UpdateNode(ctx context.Context, id string, opts nodes.UpdateOpts) (*nodes.Node, error)
+}
+
+// Resolver finds the node a metadata client runs on.
+type Resolver interface {
+	// Name identifies the resolver in logs and metrics.
+	Name() string
+
+	// Resolve returns the node of a client IP address, or nil when the
+	// resolver knows no node for it.
+	Resolve(ctx context.Context, clientIP string) (*nodes.Node, error)
+}
+
+// ListNodes returns every node known to Ironic matching the filter of
+// Options with its details, fetched as set by SetDetailWorkers. Pages are
+// decoded as they arrive rather than all held at once.
+func (c *Clients) ListNodes(ctx context.Context) ([]nodes.Node, error) {
+	ironicClient, err := c.nodeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := callContext(ctx, c.listNodesTimeout)
+	defer cancel()
+	if c.detailWorkers > 0 {
+		allNodes, err := fetchNodeDetails(callCtx, ironicClient, c.filter, c.detailWorkers,
+			c.getNodeTimeout)
+		if err != nil {
+			return nil, callError(ctx, callCtx, c.listNodesTimeout, err)
+		}
+		return allNodes, nil
+	}
+
+	allNodes := make([]nodes.Node, 0)
+	err = eachNode(callCtx, ironicClient, c.filter, func(listed []nodes.Node) error {
+		allNodes = append(allNodes, listed...)
+		return nil
+	})
+	if err != nil {
+		return nil, callError(ctx, callCtx, c.listNodesTimeout, err)
+	}
+	return allNodes, nil
+}
+
+// GetNode returns the Ironic node with a UUID or name.
+func (c *Clients) GetNode(ctx context.Context, id string) (*nodes.Node, error) {
+	ironicClient, err := c.nodeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return getNode(ctx, ironicClient, id, c.getNodeTimeout)
+}
+
+// getNode returns the Ironic node with a UUID or name, bounded by timeout
+// unless zero.
+func getNode(
+	ctx context.Context, ironicClient *gophercloud.ServiceClient, id string,
+	timeout time.Duration,
+) (*nodes.Node, error) {
+	callCtx, cancel := callContext(ctx, timeout)
+	defer cancel()
+	node, err := nodes.Get(callCtx, ironicClient, id).Extract()
+	if err != nil {
+		return nil, callError(ctx, callCtx, timeout,
+			fmt.Errorf("failed to get node %s: %w", id, err))
+	}
+	return node, nil
+}
+
+// UpdateNode applies the JSON patch operations of opts to the Ironic node
+// with a UUID or name.
+func (c *Clients) UpdateNode(
+	ctx context.Context,
+	id string,
+	opts nodes.UpdateOpts,
+) (*nodes.Node, error) {
+	ironicClient, err := c.IronicClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ironic client: %w", err)
+	}
+
+	node, err := nodes.Update(ctx, ironicClient, id, opts).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update node %s: %w", id, err)
+	}
+	return node, nil
+}
+
+// inventoryMicroversion is the first Ironic API version serving inventories.
+const inventoryMicroversion = "1.81"
+
+// GetInventory returns the inventory and plugin data Ironic recorded when
+// inspecting the node with a UUID or name.
+func (c *Clients) GetInventory(ctx context.Context, id string) (*nodes.InventoryData, error) {
+	ironicClient, err := c.IronicClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ironic client: %w", err)
+	}
+
+	// Clients built by hand, such as for standalone Ironic, have no type, and
+	// gophercloud sends microversions only for typed clients.
+	inventoryClient := *ironicClient
+	inventoryClient.Type = "baremetal"
+	inventoryClient.Microversion = inventoryMicroversion
+	data, err := nodes.GetInventory(ctx, &inventoryClient, id).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory of node %s: %w", id, err)
+	}
+	return data, nil
+}
+
+// allocationMicroversion is the first Ironic API version serving allocations.
+const allocationMicroversion = "1.52"
+
+// GetAllocation returns the Ironic allocation with a UUID or name.
+func (c *Clients) GetAllocation(ctx context.Context, id string) (*allocations.Allocation, error) {
+	ironicClient, err := c.IronicClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ironic client: %w", err)
+	}
+
+	allocationClient := *ironicClient
+	allocationClient.Type = "baremetal"
+	allocationClient.Microversion = allocationMicroversion
+	allocation, err := allocations.Get(ctx, &allocationClient, id).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allocation %s: %w", id, err)
+	}
+	return allocation, nil
+}
+
+// ListPorts returns every port known to Ironic with its details.
+func (c *Clients) ListPorts(ctx context.Context) ([]ports.Port, error) {
+	return c.listPorts(ctx, ports.ListOpts{})
+}
+
+// ListPortsByMAC returns the Ironic ports with a MAC address. Ironic stores
+// addresses in lower case. Ports are listed with details, as listings without
+// them leave out the node.
+func (c *Clients) ListPortsByMAC(ctx context.Context, mac string) ([]ports.Port, error) {
+	return c.listPorts(ctx, ports.ListOpts{Address: strings.ToLower(mac)})
+}
+
+// listPorts returns the Ironic ports matching opts with their details.
+func (c *Clients) listPorts(ctx context.Context, opts ports.ListOpts) ([]ports.Port, error) {
+	ironicClient, err := c.IronicClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ironic client: %w", err)
+	}
+
+	callCtx, cancel := callContext(ctx, c.listPortsTimeout)
+	defer cancel()
+	allPages, err := ports.ListDetail(ironicClient, opts).AllPages(callCtx)
+	if err != nil {
+		return nil, callError(ctx, callCtx, c.listPortsTimeout,
+			fmt.Errorf("failed to list ports: %w", err))
+	}
+	allPorts, err := ports.ExtractPorts(allPages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract ports: %w", err)
+	}
+	return allPorts, nil
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestClients_NodeSource(t *testing.T) {
+	var query, patch string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/ports/detail":
+			query = r.URL.RawQuery
+			_, _ = w.Write([]byte(`{"ports": [{"uuid": "port-1", "address": "52:54:00:ab:cd:ef", ` +
+				`"node_uuid": "node-1"}]}`))
+		case "/v1/nodes/node-1":
+			if r.Method == http.MethodPatch {
+				b, _ := io.ReadAll(r.Body)
+				patch = string(b)
+			}
+			_, _ = w.Write([]byte(`{"uuid": "node-1", "name": "web01"}`))
+		case "/v1/nodes/node-1/inventory":
+			if r.Header.Get("X-OpenStack-Ironic-API-Version") != inventoryMicroversion {
+				http.Error(w, `{"error_message": "not found"}`, http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`{"inventory": {"interfaces": [{"name": "eno1"}]}, ` +
+				`"plugin_data": {}}`))
+		case "/v1/allocations/alloc-1":
+			if r.Header.Get("X-OpenStack-Ironic-API-Version") != allocationMicroversion {
+				http.Error(w, `{"error_message": "not found"}`, http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`{"uuid": "alloc-1", "node_uuid": "node-1", ` +
+				`"extra": {"public_keys": "ssh-ed25519 AAAA"}}`))
+		default:
+			http.Error(w, `{"error_message": "not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Clients{}
+	c.SetIronicClient(&gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       srv.URL + "/v1/",
+	})
+
+	matched, err := c.ListPortsByMAC(t.Context(), "52:54:00:AB:CD:EF")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query != "address=52%3A54%3A00%3Aab%3Acd%3Aef" {
+		t.Errorf("have query %q, want the lower-case address", query)
+	}
+	if len(matched) != 1 || matched[0].NodeUUID != "node-1" {
+		t.Errorf("have ports %+v, want port-1", matched)
+	}
+
+	node, err := c.GetNode(t.Context(), "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.Name != "web01" {
+		t.Errorf("have node %+v, want web01", node)
+	}
+	_, err = c.GetNode(t.Context(), "node-2")
+	if !gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+		t.Errorf("have error %v, want status 404", err)
+	}
+
+	_, err = c.UpdateNode(t.Context(), "node-1", nodes.UpdateOpts{
+		nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/instance_info/password"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `[{"op":"remove","path":"/instance_info/password"}]`; patch != want {
+		t.Errorf("have patch %s, want %s", patch, want)
+	}
+
+	inventory, err := c.GetInventory(t.Context(), "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(inventory.Inventory.Interfaces) != 1 {
+		t.Errorf("have inventory %+v, want eno1", inventory.Inventory)
+	}
+
+	allocation, err := c.GetAllocation(t.Context(), "alloc-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allocation.Extra["public_keys"] != "ssh-ed25519 AAAA" {
+		t.Errorf("have allocation %+v, want its public keys", allocation)
+	}
+
+	if _, err := (&Clients{}).ListNodes(t.Context()); err == nil {
+		t.Error("expected error without an Ironic client")
+	}
+}
+
+func TestClients_callTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/ports/detail":
+			_, _ = w.Write([]byte(`{"ports": []}`))
+		case "/v1/":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			// Node calls answer once the test is done.
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	tests := []struct {
+		name string
+		opts Options
+		call func(c *Clients) error
+	}{
+		{
+			name: "get node",
+			opts: Options{GetNodeTimeout: 20 * time.Millisecond},
+			call: func(c *Clients) error {
+				_, err := c.GetNode(t.Context(), "node-1")
+				return err
+			},
+		},
+		{
+			name: "list nodes",
+			opts: Options{ListNodesTimeout: 20 * time.Millisecond},
+			call: func(c *Clients) error {
+				_, err := c.ListNodes(t.Context())
+				return err
+			},
+		},
+		{
+			name: "list node details",
+			opts: Options{
+				ListNodesTimeout: 20 * time.Millisecond,
+				DetailWorkers:    2,
+			},
+			call: func(c *Clients) error {
+				_, err := c.ListNodes(t.Context())
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Ironic = &gophercloud.ServiceClient{
+				ProviderClient: &gophercloud.ProviderClient{},
+				Endpoint:       srv.URL + "/v1/",
+			}
+			c, err := NewClients(tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = tt.call(c)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("have error %v, want a deadline exceeded", err)
+			}
+			if !strings.Contains(err.Error(), "timed out after 20ms") {
+				t.Errorf("have error %q, want the timeout named", err)
+			}
+			// Calls without a slow response are not bounded by other
+			// timeouts.
+			if _, err := c.ListPorts(t.Context()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
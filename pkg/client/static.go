@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+// StaticMappingsFile is the file of a static directory mapping IP and MAC
+// addresses to its nodes, in the CSV format of the mapping table of the
+// metadata service.
+const StaticMappingsFile = "mappings.csv"
+
+// staticFiles maps the files of the openstack/latest directory of a node in
+// a static directory to the configdrive keys serving them.
+var staticFiles = []struct {
+	name string
+	key  string
+	json bool
+}{
+	{"meta_data.json", "meta_data", true},
+	{"network_data.json", "network_data", true},
+	{"vendor_data.json", "vendor_data", true},
+	{"user_data", "user_data", false},
+}
+
+// StaticSource is a NodeSource serving nodes from a directory rather than
+// from Ironic, for labs and demos without Ironic. Each subdirectory holding
+// openstack/latest/meta_data.json is a node, in the layout of a configdrive:
+//
+//	<root>/web01/openstack/latest/meta_data.json
+//	<root>/web01/openstack/latest/network_data.json
+//	<root>/web01/openstack/latest/user_data
+//	<root>/web01/openstack/latest/vendor_data.json
+//
+// Only meta_data.json is required. The node is active, with the uuid and
+// name of meta_data.json, defaulting to the name of its directory, and its
+// files served as a configdrive. It is found by the addresses of its
+// network_data.json, and by those mapped to it in the StaticMappingsFile of
+// the root, whose ip and mac_address columns map to the directory, UUID or
+// name in its node_uuid or node_name column. The directory is read on every
+// call, so changes are served right away.
+type StaticSource struct {
+	root string
+}
+
+// NewStaticSource returns a StaticSource serving the nodes in root.
+func NewStaticSource(root string) *StaticSource {
+	return &StaticSource{root: root}
+}
+
+// ListNodes returns the nodes of the directory.
+func (s *StaticSource) ListNodes(context.Context) ([]nodes.Node, error) {
+	all, _, err := s.load()
+	return all, err
+}
+
+// GetNode returns the node with a UUID, name or directory, failing like the
+// Ironic API with status 404 when there is none.
+func (s *StaticSource) GetNode(_ context.Context, id string) (*nodes.Node, error) {
+	all, _, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range all {
+		if node.UUID == id || node.Name == id || node.Extra["static_dir"] == id {
+			return &node, nil
+		}
+	}
+	return nil, gophercloud.ErrUnexpectedResponseCode{
+		URL:      "nodes/" + id,
+		Method:   http.MethodGet,
+		Expected: []int{http.StatusOK},
+		Actual:   http.StatusNotFound,
+	}
+}
+
+// ListPorts returns a port for each MAC address of the nodes.
+func (s *StaticSource) ListPorts(context.Context) ([]ports.Port, error) {
+	_, all, err := s.load()
+	return all, err
+}
+
+// ListPortsByMAC returns the ports with a MAC address.
+func (s *StaticSource) ListPortsByMAC(ctx context.Context, mac string) ([]ports.Port, error) {
+	all, err := s.ListPorts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matched []ports.Port
+	for _, port := range all {
+		if strings.EqualFold(port.Address, mac) {
+			matched = append(matched, port)
+		}
+	}
+	return matched, nil
+}
+
+// load reads the nodes of the directory and their ports.
+func (s *StaticSource) load() ([]nodes.Node, []ports.Port, error) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read static directory: %w", err)
+	}
+	mappings, err := s.loadMappings()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		allNodes []nodes.Node
+		allPorts []ports.Port
+	)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		node, macs, err := s.loadNode(entry.Name())
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var fixedIPs []any
+		for _, m := range mappings {
+			if m.node != entry.Name() && m.node != node.UUID && m.node != node.Name {
+				continue
+			}
+			if m.ip != "" {
+				fixedIPs = append(fixedIPs, map[string]any{"ip_address": m.ip})
+			}
+			if m.mac != "" && !slices.Contains(macs, m.mac) {
+				macs = append(macs, m.mac)
+			}
+		}
+		if fixedIPs != nil {
+			node.InstanceInfo["fixed_ips"] = fixedIPs
+		}
+		for i, mac := range macs {
+			allPorts = append(allPorts, ports.Port{
+				UUID:      fmt.Sprintf("%s-port-%d", node.UUID, i),
+				NodeUUID:  node.UUID,
+				Address:   mac,
+				CreatedAt: node.CreatedAt,
+			})
+		}
+		allNodes = append(allNodes, *node)
+	}
+	return allNodes, allPorts, nil
+}
+
+// loadNode reads the node in a directory of the root, with the MAC
+// addresses of the links of its network data.
+func (s *StaticSource) loadNode(dir string) (*nodes.Node, []string, error) {
+	latest := filepath.Join(s.root, dir, "openstack", "latest")
+	configDrive := make(map[string]any)
+	var updated time.Time
+	for _, file := range staticFiles {
+		path := filepath.Join(latest, file.name)
+		b, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) && file.name != "meta_data.json" {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(updated) {
+			updated = info.ModTime()
+		}
+		if !file.json {
+			configDrive[file.key] = string(b)
+			continue
+		}
+		var v map[string]any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+		configDrive[file.key] = v
+	}
+
+	metaData, _ := configDrive["meta_data"].(map[string]any)
+	node := &nodes.Node{
+		UUID:           stringField(metaData, "uuid", dir),
+		Name:           stringField(metaData, "name", stringField(metaData, "hostname", dir)),
+		ProvisionState: "active",
+		PowerState:     "power on",
+		InstanceInfo:   map[string]any{"configdrive": configDrive},
+		Extra:          map[string]any{"static_dir": dir},
+		CreatedAt:      updated,
+		UpdatedAt:      updated,
+	}
+
+	var macs []string
+	networkData, _ := configDrive["network_data"].(map[string]any)
+	links, _ := networkData["links"].([]any)
+	for _, link := range links {
+		link, _ := link.(map[string]any)
+		if mac := strings.ToLower(stringField(link, "ethernet_mac_address", "")); mac != "" &&
+			!slices.Contains(macs, mac) {
+			macs = append(macs, mac)
+		}
+	}
+	return node, macs, nil
+}
+
+// staticMapping is an address mapped to a node by the StaticMappingsFile.
+type staticMapping struct {
+	ip   string
+	mac  string
+	node string
+}
+
+// loadMappings reads the StaticMappingsFile of the root, if any.
+func (s *StaticSource) loadMappings() ([]staticMapping, error) {
+	f, err := os.Open(filepath.Join(s.root, StaticMappingsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", StaticMappingsFile, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var mappings []staticMapping
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return mappings, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", StaticMappingsFile, err)
+		}
+		m := staticMapping{
+			ip:   field(record, "ip"),
+			mac:  strings.ToLower(field(record, "mac_address")),
+			node: field(record, "node_uuid"),
+		}
+		if m.node == "" {
+			m.node = field(record, "node_name")
+		}
+		if m.node != "" {
+			mappings = append(mappings, m)
+		}
+	}
+}
+
+// stringField returns the string of a key of a JSON object, or def.
+func stringField(v map[string]any, key, def string) string {
+	if s, ok := v[key].(string); ok && s != "" {
+		return s
+	}
+	return def
+}
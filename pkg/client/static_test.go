@@ -0,0 +1,107 @@
+package client
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+// writeStaticTree writes files, keyed by their path below the root, to a
+// temporary directory and returns it.
+func writeStaticTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return root
+}
+
+func TestStaticSource(t *testing.T) {
+	root := writeStaticTree(t, map[string]string{
+		"web01/openstack/latest/meta_data.json": `{"uuid": "node-1", "name": "web01"}`,
+		"web01/openstack/latest/network_data.json": `{"links": [` +
+			`{"id": "eth0", "ethernet_mac_address": "52:54:00:00:00:01"}]}`,
+		"web01/openstack/latest/user_data":     "#cloud-config\n",
+		"db01/openstack/latest/meta_data.json": `{"hostname": "db01.lab"}`,
+		"notes/README":                         "not a node",
+		"mappings.csv": "ip,mac_address,node_uuid,node_name\n" +
+			"10.0.0.5,,node-1,\n" +
+			"10.0.0.6,52:54:00:00:00:02,,db01\n" +
+			"10.0.0.9,52:54:00:00:00:09,,\n",
+	})
+	s := NewStaticSource(root)
+
+	all, err := s.ListNodes(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var haveNodes []string
+	for _, node := range all {
+		haveNodes = append(haveNodes, node.UUID+"/"+node.Name+"/"+node.ProvisionState)
+	}
+	wantNodes := []string{"db01/db01.lab/active", "node-1/web01/active"}
+	if !slices.Equal(haveNodes, wantNodes) {
+		t.Errorf("have nodes %v, want %v", haveNodes, wantNodes)
+	}
+
+	node, err := s.GetNode(t.Context(), "web01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	configDrive, _ := node.InstanceInfo["configdrive"].(map[string]any)
+	if configDrive["user_data"] != "#cloud-config\n" {
+		t.Errorf("have configdrive %v, want the user data of the node", configDrive)
+	}
+	fixedIPs, _ := node.InstanceInfo["fixed_ips"].([]any)
+	if len(fixedIPs) != 1 {
+		t.Errorf("have fixed IPs %v, want the mapped 10.0.0.5", fixedIPs)
+	}
+	if _, err := s.GetNode(t.Context(), "missing"); !gophercloud.ResponseCodeIs(err,
+		http.StatusNotFound) {
+		t.Errorf("have error %v, want 404 Not Found", err)
+	}
+
+	tests := []struct {
+		mac  string
+		want []string
+	}{
+		{mac: "52:54:00:00:00:01", want: []string{"node-1"}},
+		{mac: "52:54:00:00:00:02", want: []string{"db01"}},
+		{mac: "52:54:00:00:00:09"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mac, func(t *testing.T) {
+			matched, err := s.ListPortsByMAC(t.Context(), tt.mac)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var have []string
+			for _, port := range matched {
+				have = append(have, port.NodeUUID)
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("have ports of %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticSource_invalid(t *testing.T) {
+	root := writeStaticTree(t, map[string]string{
+		"web01/openstack/latest/meta_data.json": `{"uuid": `,
+	})
+	if _, err := NewStaticSource(root).ListNodes(t.Context()); err == nil {
+		t.Error("expected error but got none")
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Defaults of TransportOptions, sized for a boot storm of hundreds of nodes
+// asking for their metadata at once rather than for the default pool of two
+// idle connections per host, which makes most requests to Ironic open a new
+// connection and leave it in TIME_WAIT.
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 100
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultDialTimeout         = 30 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// TransportOptions configures the HTTP transport of the Ironic API calls.
+// Zero values take the defaults above, except for the unlimited
+// MaxConnsPerHost and ResponseHeaderTimeout.
+type TransportOptions struct {
+	// MaxIdleConns caps the idle connections kept across every host.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost caps the idle connections kept to each host, and
+	// so the concurrent calls reusing a connection.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost caps the connections to each host, making calls
+	// beyond it wait for one, to spare the ephemeral ports of the host and
+	// Ironic itself.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout bounds establishing a TCP connection.
+	DialTimeout time.Duration
+
+	// KeepAlive is the interval of TCP keep-alive probes; negative disables
+	// them.
+	KeepAlive time.Duration
+
+	// TLSHandshakeTimeout bounds the TLS handshake of a connection.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout bounds waiting for the response headers of a
+	// call once its request is written.
+	ResponseHeaderTimeout time.Duration
+
+	// DisableKeepAlives opens a connection per call.
+	DisableKeepAlives bool
+}
+
+// NewTransport returns an HTTP transport for the Ironic API configured with
+// opts, otherwise like http.DefaultTransport, honoring the proxy
+// environment variables.
+func NewTransport(opts TransportOptions) *http.Transport {
+	orDefault := func(value, def time.Duration) time.Duration {
+		if value == 0 {
+			return def
+		}
+		return value
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   orDefault(opts.DialTimeout, DefaultDialTimeout),
+		KeepAlive: orDefault(opts.KeepAlive, DefaultKeepAlive),
+	}).DialContext
+	transport.MaxIdleConns = DefaultMaxIdleConns
+	if opts.MaxIdleConns > 0 {
+		transport.MaxIdleConns = opts.MaxIdleConns
+	}
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	if opts.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	transport.MaxConnsPerHost = opts.MaxConnsPerHost
+	transport.IdleConnTimeout = orDefault(opts.IdleConnTimeout, DefaultIdleConnTimeout)
+	transport.TLSHandshakeTimeout = orDefault(opts.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout)
+	transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	transport.DisableKeepAlives = opts.DisableKeepAlives
+	return transport
+}
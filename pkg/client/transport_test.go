@@ -0,0 +1,66 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		name string
+		have TransportOptions
+		// want holds MaxIdleConns, MaxIdleConnsPerHost and MaxConnsPerHost.
+		want        [3]int
+		wantIdle    time.Duration
+		wantTLS     time.Duration
+		wantHeaders time.Duration
+	}{
+		{
+			name:     "defaults",
+			want:     [3]int{DefaultMaxIdleConns, DefaultMaxIdleConnsPerHost, 0},
+			wantIdle: DefaultIdleConnTimeout,
+			wantTLS:  DefaultTLSHandshakeTimeout,
+		},
+		{
+			name: "tuned",
+			have: TransportOptions{
+				MaxIdleConns:          500,
+				MaxIdleConnsPerHost:   250,
+				MaxConnsPerHost:       300,
+				IdleConnTimeout:       time.Minute,
+				TLSHandshakeTimeout:   3 * time.Second,
+				ResponseHeaderTimeout: 20 * time.Second,
+			},
+			want:        [3]int{500, 250, 300},
+			wantIdle:    time.Minute,
+			wantTLS:     3 * time.Second,
+			wantHeaders: 20 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewTransport(tt.have)
+			have := [3]int{
+				transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost,
+			}
+			if have != tt.want {
+				t.Errorf("have connection limits %v, want %v", have, tt.want)
+			}
+			if transport.IdleConnTimeout != tt.wantIdle {
+				t.Errorf("have idle timeout %s, want %s", transport.IdleConnTimeout, tt.wantIdle)
+			}
+			if transport.TLSHandshakeTimeout != tt.wantTLS {
+				t.Errorf("have TLS handshake timeout %s, want %s",
+					transport.TLSHandshakeTimeout, tt.wantTLS)
+			}
+			if transport.ResponseHeaderTimeout != tt.wantHeaders {
+				t.Errorf("have response header timeout %s, want %s",
+					transport.ResponseHeaderTimeout, tt.wantHeaders)
+			}
+			if transport.Proxy == nil || transport.DialContext == nil {
+				t.Error("expected the proxy and dialer of the default transport")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package configdrive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/appkins-org/ironic-metadata/pkg/iso"
+)
+
+// Build writes a configdrive image labelled config-2 holding the files of cd
+// under openstack/latest.
+func Build(cd *ConfigDrive) ([]byte, error) {
+	files := make(map[string][]byte)
+	for name, data := range map[string][]byte{
+		"meta_data.json":    cd.MetaData,
+		"network_data.json": cd.NetworkData,
+		"user_data":         cd.UserData,
+		"vendor_data.json":  cd.VendorData,
+		"vendor_data2.json": cd.VendorData2,
+	} {
+		if data != nil {
+			files["openstack/latest/"+name] = data
+		}
+	}
+	if files["openstack/latest/meta_data.json"] == nil {
+		return nil, fmt.Errorf("configdrive has no meta_data.json")
+	}
+	for id, data := range cd.Content {
+		files["openstack/content/"+id] = data
+	}
+
+	var buf bytes.Buffer
+	if err := iso.Write(&buf, Label, files); err != nil {
+		return nil, fmt.Errorf("failed to write configdrive image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Encode gzips and base64 encodes a configdrive image, the form Ironic
+// expects in instance_info.
+func Encode(image []byte) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(image); err != nil {
+		return "", fmt.Errorf("failed to compress configdrive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress configdrive: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
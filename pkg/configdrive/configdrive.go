@@ -0,0 +1,129 @@
+// Package configdrive reads OpenStack configdrive images, as attached to
+// nodes by Ironic, so their contents can be served over the network.
+package configdrive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/blob"
+	"github.com/kdomanski/iso9660"
+)
+
+// Label is the volume label of an OpenStack configdrive.
+const Label = "config-2"
+
+// ConfigDrive holds the files of the latest version in a configdrive.
+type ConfigDrive struct {
+	MetaData    []byte
+	NetworkData []byte
+	UserData    []byte
+	VendorData  []byte
+	VendorData2 []byte
+
+	// Content holds injected file bodies keyed by their content id.
+	Content map[string][]byte
+}
+
+// IsISO reports whether data is an ISO 9660 image.
+func IsISO(data []byte) bool {
+	return blob.IsISO(data)
+}
+
+// Decode returns the ISO image of a configdrive in any of the encodings
+// Ironic accepts: a raw image, or a base64 encoded, optionally gzipped one.
+func Decode(data []byte) ([]byte, error) {
+	decoded, err := blob.Decode(data, blob.DefaultLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode configdrive: %w", err)
+	}
+	if !IsISO(decoded) {
+		return nil, errors.New("configdrive is not an ISO image")
+	}
+	return decoded, nil
+}
+
+// Parse reads the openstack/latest files of a configdrive image in any of
+// the encodings accepted by Decode.
+func Parse(data []byte) (*ConfigDrive, error) {
+	image, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	iso, err := iso9660.OpenImage(bytes.NewReader(image))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open configdrive image: %w", err)
+	}
+	root, err := iso.RootDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configdrive root: %w", err)
+	}
+
+	openstack, err := child(root, "openstack")
+	if err != nil {
+		return nil, err
+	}
+	latest, err := child(openstack, "latest")
+	if err != nil {
+		return nil, err
+	}
+
+	cd := &ConfigDrive{Content: make(map[string][]byte)}
+	for name, dst := range map[string]*[]byte{
+		"meta_data.json":    &cd.MetaData,
+		"network_data.json": &cd.NetworkData,
+		"user_data":         &cd.UserData,
+		"vendor_data.json":  &cd.VendorData,
+		"vendor_data2.json": &cd.VendorData2,
+	} {
+		f, err := child(latest, name)
+		if err != nil {
+			continue
+		}
+		if *dst, err = io.ReadAll(f.Reader()); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+	if cd.MetaData == nil {
+		return nil, errors.New("configdrive has no openstack/latest/meta_data.json")
+	}
+
+	if content, err := child(openstack, "content"); err == nil {
+		files, err := content.GetChildren()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list configdrive content: %w", err)
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			body, err := io.ReadAll(f.Reader())
+			if err != nil {
+				return nil, fmt.Errorf("failed to read content %s: %w", f.Name(), err)
+			}
+			cd.Content[strings.ToLower(f.Name())] = body
+		}
+	}
+
+	return cd, nil
+}
+
+// child looks up an entry of a directory. Names are compared case
+// insensitively since images without Rock Ridge extensions store them in
+// upper case.
+func child(dir *iso9660.File, name string) (*iso9660.File, error) {
+	children, err := dir.GetChildren()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir.Name(), err)
+	}
+	for _, c := range children {
+		if strings.EqualFold(c.Name(), name) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("configdrive has no %s entry in %s", name, dir.Name())
+}
@@ -0,0 +1,231 @@
+package configdrive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+	"github.com/kdomanski/iso9660"
+)
+
+// testImage builds a configdrive image holding files.
+func testImage(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	w, err := iso9660.NewWriter()
+	if err != nil {
+		t.Fatalf("failed to create image writer: %v", err)
+	}
+	defer func() {
+		if err := w.Cleanup(); err != nil {
+			t.Errorf("failed to clean up image writer: %v", err)
+		}
+	}()
+
+	for name, body := range files {
+		if err := w.AddFile(strings.NewReader(body), name); err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := w.WriteTo(&buf, Label); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// gzipBase64 encodes data the way Ironic expects configdrives.
+func gzipBase64(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+func testConfigDrive(t *testing.T) []byte {
+	t.Helper()
+
+	return testImage(t, map[string]string{
+		"openstack/latest/meta_data.json":    `{"uuid": "test-uuid"}`,
+		"openstack/latest/network_data.json": `{"links": [], "networks": []}`,
+		"openstack/latest/user_data":         "#cloud-config\n",
+		"openstack/content/0000":             "hello\n",
+	})
+}
+
+func TestParse(t *testing.T) {
+	image := testConfigDrive(t)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "raw", data: image},
+		{name: "base64", data: []byte(base64.StdEncoding.EncodeToString(image))},
+		{name: "gzip base64", data: gzipBase64(t, image)},
+		{name: "garbage", data: []byte("not a configdrive"), wantErr: true},
+		{
+			name:    "missing meta data",
+			data:    testImage(t, map[string]string{"openstack/latest/user_data": "x"}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd, err := Parse(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if have, want := string(cd.MetaData), `{"uuid": "test-uuid"}`; have != want {
+				t.Errorf("meta_data.json: have %q, want %q", have, want)
+			}
+			if have, want := string(cd.UserData), "#cloud-config\n"; have != want {
+				t.Errorf("user_data: have %q, want %q", have, want)
+			}
+			if cd.NetworkData == nil {
+				t.Error("expected network_data.json to be read")
+			}
+			if cd.VendorData != nil {
+				t.Errorf("expected no vendor_data.json, got %q", cd.VendorData)
+			}
+			if have, want := string(cd.Content["0000"]), "hello\n"; have != want {
+				t.Errorf("content 0000: have %q, want %q", have, want)
+			}
+		})
+	}
+}
+
+func TestIsRemote(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{ref: "https://swift.example.com/v1/AUTH_x/ironic/node", want: true},
+		{ref: "http://10.0.0.1/configdrive", want: true},
+		{ref: "swift://ironic_configdrive_container/node", want: true},
+		{ref: "s3://configdrives/node", want: true},
+		{ref: "H4sICAAAAAAA/2NvbmZpZw==", want: false},
+		{ref: `{"meta_data": {}}`, want: false},
+	}
+
+	for _, tt := range tests {
+		if have := IsRemote(tt.ref); have != tt.want {
+			t.Errorf("IsRemote(%q): have %v, want %v", tt.ref, have, tt.want)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	body := gzipBase64(t, testConfigDrive(t))
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/configdrive" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(remote.NewDownloader(nil, "", nil), time.Minute)
+
+	ref := srv.URL + "/configdrive"
+	for range 2 {
+		cd, err := f.Fetch(context.Background(), ref)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if have, want := string(cd.MetaData), `{"uuid": "test-uuid"}`; have != want {
+			t.Errorf("meta_data.json: have %q, want %q", have, want)
+		}
+	}
+	if have := requests.Load(); have != 1 {
+		t.Errorf("expected cached configdrive to be reused, got %d requests", have)
+	}
+
+	// An expired temp URL bypasses the cache.
+	expired := ref + "?temp_url_sig=abc&temp_url_expires=" +
+		strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	for range 2 {
+		if _, err := f.Fetch(context.Background(), expired); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if have := requests.Load(); have != 3 {
+		t.Errorf("expected expired temp URL to be fetched again, got %d requests", have)
+	}
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("expected error for missing configdrive")
+	}
+	if _, err := f.Fetch(context.Background(), "swift://container/object"); err == nil {
+		t.Error("expected error for swift reference without object storage client")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	want := &ConfigDrive{
+		MetaData:    []byte(`{"uuid": "test-uuid"}`),
+		NetworkData: []byte(`{"links": [], "networks": []}`),
+		UserData:    []byte("#cloud-config\n"),
+		Content:     map[string][]byte{"0000": []byte("hello\n")},
+	}
+
+	image, err := Build(want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	encoded, err := Encode(image)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	have, err := Parse([]byte(encoded))
+	if err != nil {
+		t.Fatalf("failed to parse built configdrive: %v", err)
+	}
+	if !bytes.Equal(have.MetaData, want.MetaData) {
+		t.Errorf("meta_data.json: have %q, want %q", have.MetaData, want.MetaData)
+	}
+	if !bytes.Equal(have.UserData, want.UserData) {
+		t.Errorf("user_data: have %q, want %q", have.UserData, want.UserData)
+	}
+	if have.VendorData != nil {
+		t.Errorf("expected no vendor_data.json, got %q", have.VendorData)
+	}
+	if !bytes.Equal(have.Content["0000"], want.Content["0000"]) {
+		t.Errorf("content 0000: have %q, want %q", have.Content["0000"], want.Content["0000"])
+	}
+
+	if _, err := Build(&ConfigDrive{}); err == nil {
+		t.Error("expected error without meta_data.json")
+	}
+}
@@ -0,0 +1,107 @@
+package configdrive
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+	"github.com/appkins-org/ironic-metadata/pkg/remote"
+)
+
+// MaxDownloadSize bounds the size of a downloaded configdrive. Ironic itself
+// refuses configdrives larger than 64 MiB once encoded.
+const MaxDownloadSize = 64 << 20
+
+// IsRemote reports whether a configdrive reference points to a download
+// location rather than holding the configdrive itself.
+func IsRemote(ref string) bool {
+	return remote.IsRemote(ref)
+}
+
+// Fetcher downloads configdrives referenced by URL, as Ironic stores them
+// with configdrive_use_object_store, and caches the parsed result, sized by
+// the downloaded configdrive.
+type Fetcher struct {
+	downloader *remote.Downloader
+	ttl        time.Duration
+
+	mu       sync.Mutex
+	cache    *lru.Cache[string, cacheEntry]
+	counters metrics.CacheCounters
+}
+
+// cacheEntry is a parsed configdrive, the time it was stored and the time
+// it stops being valid.
+type cacheEntry struct {
+	configDrive *ConfigDrive
+	stored      time.Time
+	expires     time.Time
+}
+
+// NewFetcher returns a fetcher downloading configdrives through downloader
+// and caching the parsed result for ttl.
+func NewFetcher(downloader *remote.Downloader, ttl time.Duration) *Fetcher {
+	return &Fetcher{
+		downloader: downloader,
+		ttl:        ttl,
+		cache:      lru.New[string, cacheEntry](lru.Limits{}),
+	}
+}
+
+// SetLimits bounds the cache, which evicts the least recently used
+// configdrives beyond limits.
+func (f *Fetcher) SetLimits(limits lru.Limits) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counters.Evict(f.cache.SetLimits(limits))
+}
+
+// Fetch downloads and parses the configdrive at ref.
+func (f *Fetcher) Fetch(ctx context.Context, ref string) (*ConfigDrive, error) {
+	now := time.Now()
+
+	f.mu.Lock()
+	if entry, ok := f.cache.Get(ref); ok && now.Before(entry.expires) {
+		f.counters.Lookup(true)
+		f.mu.Unlock()
+		return entry.configDrive, nil
+	}
+	f.counters.Lookup(false)
+	f.mu.Unlock()
+
+	data, err := f.downloader.Download(ctx, ref, MaxDownloadSize)
+	if err != nil {
+		return nil, err
+	}
+	cd, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.counters.Evict(f.cache.Put(ref, cacheEntry{
+		configDrive: cd,
+		stored:      now,
+		expires:     remote.CacheExpiry(ref, now, f.ttl),
+	}, int64(len(data))))
+	f.mu.Unlock()
+
+	return cd, nil
+}
+
+// CacheStats returns the usage of the cache.
+func (f *Fetcher) CacheStats() metrics.CacheStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := f.counters.Stats(f.cache.Len(), func(yield func(time.Time) bool) {
+		for _, entry := range f.cache.All() {
+			if !yield(entry.stored) {
+				return
+			}
+		}
+	})
+	stats.Bytes = f.cache.Bytes()
+	return stats
+}
@@ -0,0 +1,97 @@
+// Package dhcpsnoop learns the IP addresses DHCP servers lease to MAC
+// addresses by capturing their ACKs on a network interface, so clients can
+// be resolved without read access to the lease database of the server.
+package dhcpsnoop
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+)
+
+// UDP ports of DHCPv4 servers and clients. Servers send ACKs to clients, or
+// to the server port of the relay agents that forwarded the request.
+const (
+	serverPort = 67
+	clientPort = 68
+)
+
+// protocolUDP is the IPv4 protocol number of UDP.
+const protocolUDP = 17
+
+// Listener captures the DHCP ACKs sent and received on an interface.
+type Listener struct {
+	iface string
+	conn  io.ReadCloser
+}
+
+// Open starts capturing on an interface, such as the provisioning network
+// interface of the DHCP server. It needs CAP_NET_RAW and is only supported
+// on Linux.
+func Open(iface string) (*Listener, error) {
+	conn, err := openPacketConn(iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture DHCP on %s: %w", iface, err)
+	}
+	return &Listener{iface: iface, conn: conn}, nil
+}
+
+// Serve adds the leases of the captured ACKs to learned until ctx is done,
+// then closes the listener.
+func (l *Listener) Serve(ctx context.Context, learned *leases.Learned) error {
+	stop := context.AfterFunc(ctx, func() { _ = l.conn.Close() })
+	defer stop()
+
+	buf := make([]byte, 1<<16)
+	for {
+		n, err := l.conn.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to capture DHCP on %s: %w", l.iface, err)
+		}
+		msg, ok := dhcpPayload(buf[:n])
+		if !ok {
+			continue
+		}
+		if lease, ok := leases.ParseACK(msg, time.Now()); ok {
+			learned.Add(lease)
+		}
+	}
+}
+
+// Close stops capturing.
+func (l *Listener) Close() error {
+	return l.conn.Close()
+}
+
+// dhcpPayload returns the payload of an IPv4 packet when it is an unfragmented
+// UDP datagram from a DHCP server.
+func dhcpPayload(packet []byte) ([]byte, bool) {
+	if len(packet) < 20 || packet[0]>>4 != 4 {
+		return nil, false
+	}
+	headerLen := int(packet[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(packet[2:4]))
+	// Reject fragments: the more fragments flag or a fragment offset.
+	fragmented := binary.BigEndian.Uint16(packet[6:8])&0x3fff != 0
+	if headerLen < 20 || totalLen < headerLen+8 || totalLen > len(packet) ||
+		fragmented || packet[9] != protocolUDP {
+		return nil, false
+	}
+
+	udp := packet[headerLen:totalLen]
+	srcPort := binary.BigEndian.Uint16(udp[0:2])
+	dstPort := binary.BigEndian.Uint16(udp[2:4])
+	udpLen := int(binary.BigEndian.Uint16(udp[4:6]))
+	if srcPort != serverPort || (dstPort != clientPort && dstPort != serverPort) ||
+		udpLen < 8 || udpLen > len(udp) {
+		return nil, false
+	}
+	return udp[8:udpLen], true
+}
@@ -0,0 +1,124 @@
+package dhcpsnoop
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+)
+
+// ack returns a DHCPACK assigning ip to mac.
+func ack(ip, mac string) []byte {
+	msg := make([]byte, 240)
+	msg[0], msg[1], msg[2] = 2, 1, 6
+	copy(msg[16:20], net.ParseIP(ip).To4())
+	hw, _ := net.ParseMAC(mac)
+	copy(msg[28:], hw)
+	binary.BigEndian.PutUint32(msg[236:240], 0x63825363)
+	return append(msg, 53, 1, 5, 255)
+}
+
+// udpPacket returns an IPv4 packet holding a UDP datagram between ports.
+func udpPacket(srcPort, dstPort uint16, payload []byte) []byte {
+	packet := make([]byte, 28, 28+len(payload))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(28+len(payload)))
+	packet[9] = protocolUDP
+	binary.BigEndian.PutUint16(packet[20:22], srcPort)
+	binary.BigEndian.PutUint16(packet[22:24], dstPort)
+	binary.BigEndian.PutUint16(packet[24:26], uint16(8+len(payload)))
+	return append(packet, payload...)
+}
+
+func TestDHCPPayload(t *testing.T) {
+	payload := []byte("payload")
+	fragment := udpPacket(serverPort, clientPort, payload)
+	fragment[6] = 0x20
+	tcp := udpPacket(serverPort, clientPort, payload)
+	tcp[9] = 6
+	truncated := udpPacket(serverPort, clientPort, payload)
+	binary.BigEndian.PutUint16(truncated[2:4], 100)
+
+	tests := []struct {
+		name   string
+		packet []byte
+		wantOK bool
+	}{
+		{name: "to client", packet: udpPacket(serverPort, clientPort, payload), wantOK: true},
+		{name: "to relay agent", packet: udpPacket(serverPort, serverPort, payload), wantOK: true},
+		{name: "from client", packet: udpPacket(clientPort, serverPort, payload)},
+		{name: "other ports", packet: udpPacket(53, 53, payload)},
+		{name: "fragment", packet: fragment},
+		{name: "tcp", packet: tcp},
+		{name: "truncated", packet: truncated},
+		{name: "short", packet: []byte{0x45}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, ok := dhcpPayload(tt.packet)
+			if ok != tt.wantOK {
+				t.Fatalf("have ok %v, want %v", ok, tt.wantOK)
+			}
+			if ok && string(have) != string(payload) {
+				t.Errorf("have payload %q, want %q", have, payload)
+			}
+		})
+	}
+}
+
+// packetConn returns the packets sent on a channel, one per read.
+type packetConn struct {
+	packets chan []byte
+	closed  chan struct{}
+}
+
+func (c *packetConn) Read(b []byte) (int, error) {
+	select {
+	case packet := <-c.packets:
+		return copy(b, packet), nil
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+func (c *packetConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestListener_Serve(t *testing.T) {
+	conn := &packetConn{packets: make(chan []byte), closed: make(chan struct{})}
+	l := &Listener{iface: "test0", conn: conn}
+	learned := leases.NewLearned()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() { done <- l.Serve(ctx, learned) }()
+
+	conn.packets <- udpPacket(clientPort, serverPort, ack("10.0.0.6", "52:54:00:00:00:02"))
+	conn.packets <- udpPacket(serverPort, clientPort, ack("10.0.0.5", "52:54:00:00:00:01"))
+	// Wait for the ACK to be handled.
+	conn.packets <- []byte{}
+
+	if lease, ok, _ := learned.Lookup("10.0.0.5"); !ok || lease.MAC != "52:54:00:00:00:01" {
+		t.Errorf("have lease %+v, want the ACK of 10.0.0.5", lease)
+	}
+	if learned.Added() != 1 {
+		t.Errorf("have %d leases added, want 1", learned.Added())
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+}
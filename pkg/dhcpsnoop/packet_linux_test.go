@@ -0,0 +1,44 @@
+package dhcpsnoop
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/leases"
+)
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("missing0"); err == nil {
+		t.Error("expected error for a missing interface")
+	}
+
+	l, err := Open("lo")
+	if err != nil {
+		t.Skipf("cannot capture on lo: %v", err)
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: serverPort})
+	if err != nil {
+		l.Close()
+		t.Skipf("cannot listen on the DHCP server port: %v", err)
+	}
+	defer conn.Close()
+
+	learned := leases.NewLearned()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go func() { _ = l.Serve(ctx, learned) }()
+
+	client := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: clientPort}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if _, err := conn.WriteToUDP(ack("10.0.0.5", "52:54:00:00:00:01"), client); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok, _ := learned.Lookup("10.0.0.5"); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected the ACK sent on lo to be learned")
+}
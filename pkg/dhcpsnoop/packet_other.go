@@ -0,0 +1,13 @@
+//go:build !linux
+
+package dhcpsnoop
+
+import (
+	"errors"
+	"io"
+)
+
+// openPacketConn fails on platforms without packet sockets.
+func openPacketConn(_ string) (io.ReadCloser, error) {
+	return nil, errors.New("capturing DHCP is only supported on Linux")
+}
@@ -0,0 +1,88 @@
+// Package events describes the events of the service, such as a node
+// fetching its user data, and the sinks they are sent to, such as webhooks
+// and message buses.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Types of events.
+const (
+	// UserData is sent when a node fetches its user data.
+	UserData = "user_data"
+
+	// PhoneHome is sent when a node phones home.
+	PhoneHome = "phone_home"
+
+	// LookupFailure is sent when no node is found for a client.
+	LookupFailure = "lookup_failure"
+)
+
+// Types lists the types of events.
+var Types = []string{UserData, PhoneHome, LookupFailure}
+
+// Event is an event of the service, sent to sinks as JSON.
+type Event struct {
+	// ID identifies the event, the same in every sink and across retries.
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// NodeUUID is empty for lookup failures.
+	NodeUUID string `json:"node_uuid"`
+	NodeName string `json:"node_name,omitempty"`
+	Endpoint string `json:"endpoint"`
+	ClientIP string `json:"client_ip"`
+
+	// Status is the HTTP status the request was answered with.
+	Status int `json:"status"`
+}
+
+// New returns an event of a type with a new ID, at the current time.
+func New(eventType string) Event {
+	return Event{ID: NewID(), Type: eventType, Timestamp: time.Now().UTC()}
+}
+
+// NewID returns a random event ID.
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ParseTypes parses a comma separated list of event types. An empty list
+// selects every type.
+func ParseTypes(spec string) (map[string]bool, error) {
+	types := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if !slices.Contains(Types, item) {
+			return nil, fmt.Errorf("unknown event %q, want one of %s",
+				item, strings.Join(Types, ", "))
+		}
+		types[item] = true
+	}
+	return types, nil
+}
+
+// Sink is sent events.
+type Sink interface {
+	// Name names the sink in logs and metrics, such as webhook.
+	Name() string
+
+	// Wants reports whether events of a type are sent to the sink.
+	Wants(eventType string) bool
+
+	// Notify queues an event for the sink without blocking, reporting
+	// false when it is dropped.
+	Notify(event Event) bool
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{name: "empty", have: "", want: map[string]bool{}},
+		{name: "user data", have: "User_Data", want: map[string]bool{UserData: true}},
+		{
+			name: "several",
+			have: "phone_home, lookup_failure",
+			want: map[string]bool{PhoneHome: true, LookupFailure: true},
+		},
+		{name: "unknown", have: "user_data,boot", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := ParseTypes(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	stream := NewStream()
+	if stream.Wants(UserData) {
+		t.Error("have a stream without subscribers wanting events")
+	}
+
+	all := stream.Subscribe(nil, 0)
+	phoneHome := stream.Subscribe(map[string]bool{PhoneHome: true}, 1)
+	if !stream.Wants(UserData) || !stream.Wants(PhoneHome) {
+		t.Error("have a stream not wanting the events of its subscribers")
+	}
+
+	if !stream.Notify(New(UserData)) || !stream.Notify(New(PhoneHome)) {
+		t.Fatal("have events dropped, want them queued")
+	}
+	// The queue of phoneHome is full.
+	if stream.Notify(New(PhoneHome)) {
+		t.Error("have an event queued for a full subscriber, want it dropped")
+	}
+
+	var types []string
+	for range 3 {
+		types = append(types, (<-all.C).Type)
+	}
+	if !reflect.DeepEqual(types, []string{UserData, PhoneHome, PhoneHome}) {
+		t.Errorf("have events %v, want user_data and two phone_home", types)
+	}
+	if event := <-phoneHome.C; event.Type != PhoneHome {
+		t.Errorf("have event %q, want phone_home", event.Type)
+	}
+
+	all.Close()
+	all.Close()
+	if _, ok := <-all.C; ok {
+		t.Error("have an event after Close, want C closed")
+	}
+	if stream.Wants(UserData) {
+		t.Error("have a closed subscription wanting events")
+	}
+	phoneHome.Close()
+}
+
+func TestStream_Close(t *testing.T) {
+	stream := NewStream()
+	sub := stream.Subscribe(nil, 0)
+	stream.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("have an event after Close, want C closed")
+	}
+	sub.Close()
+
+	if _, ok := <-stream.Subscribe(nil, 0).C; ok {
+		t.Error("have an event on a closed stream, want C closed")
+	}
+	if stream.Notify(New(UserData)) != true {
+		t.Error("have an event dropped by a closed stream without subscribers")
+	}
+}
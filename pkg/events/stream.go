@@ -0,0 +1,129 @@
+package events
+
+import "sync"
+
+// DefaultStreamBuffer is the number of events queued for a subscriber of a
+// Stream by default.
+const DefaultStreamBuffer = 256
+
+// Stream is a Sink handing events to its subscribers as they happen, such
+// as the clients of the gRPC event stream. A subscriber that falls behind
+// loses events rather than holding up the others. It is safe for concurrent
+// use.
+type Stream struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// Subscription is a subscriber of a Stream.
+type Subscription struct {
+	// C receives the events of the types the subscriber wants. It is
+	// closed by Close.
+	C <-chan Event
+
+	stream *Stream
+	events chan Event
+	types  map[string]bool
+	closed bool
+}
+
+// NewStream returns a Stream without subscribers.
+func NewStream() *Stream {
+	return &Stream{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Name implements Sink.
+func (s *Stream) Name() string {
+	return "stream"
+}
+
+// Wants implements Sink, reporting whether a subscriber wants events of a
+// type.
+func (s *Stream) Wants(eventType string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subscribers {
+		if sub.wants(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify implements Sink, queueing an event for the subscribers that want
+// it and reporting false when it is dropped for one of them.
+func (s *Stream) Notify(event Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queued := true
+	for sub := range s.subscribers {
+		if !sub.wants(event.Type) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			queued = false
+		}
+	}
+	return queued
+}
+
+// Subscribe returns a subscription to the events of types, or of every
+// type when types is empty, queueing up to buffer events, or
+// DefaultStreamBuffer when it is not positive.
+func (s *Stream) Subscribe(types map[string]bool, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultStreamBuffer
+	}
+	events := make(chan Event, buffer)
+	sub := &Subscription{C: events, stream: s, events: events, types: types}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		sub.close()
+		return sub
+	}
+	s.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close ends every subscription, and those made later, closing their C,
+// so subscribers can stop as the service shuts down.
+func (s *Stream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for sub := range s.subscribers {
+		sub.close()
+	}
+}
+
+// Close ends the subscription, closing C.
+func (sub *Subscription) Close() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+
+	sub.close()
+}
+
+// close ends the subscription. It must be called with the lock of its
+// stream held.
+func (sub *Subscription) close() {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(sub.stream.subscribers, sub)
+	close(sub.events)
+}
+
+// wants reports whether the subscriber wants events of a type.
+func (sub *Subscription) wants(eventType string) bool {
+	return len(sub.types) == 0 || sub.types[eventType]
+}
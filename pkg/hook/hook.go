@@ -0,0 +1,255 @@
+// Package hook calls a mutation hook, an external command or webhook that is
+// handed each rendered metadata document with the context of its node and
+// may return it changed, so site-specific logic needs no fork of the
+// service.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a call of the hook.
+const DefaultTimeout = 2 * time.Second
+
+// waitDelay bounds the wait for the output of a command killed on timeout.
+const waitDelay = 100 * time.Millisecond
+
+// maxOutputSize bounds the output of the hook.
+const maxOutputSize = 16 << 20
+
+// Config configures a Hook. Exactly one of Command and URL is set.
+type Config struct {
+	// Command is the program and arguments of a command reading the Input
+	// as JSON on its standard input and writing the Output as JSON on its
+	// standard output.
+	Command []string
+
+	// URL is a webhook posted the Input as JSON, answering the Output as
+	// JSON.
+	URL string
+
+	// Token is sent to the webhook as a bearer token, when set.
+	Token string
+
+	// Timeout bounds a call, defaulting to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Input is the input of the hook, describing a response about to be served.
+type Input struct {
+	ClientIP string `json:"client_ip"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+
+	// Endpoint is the document served, such as meta_data.json or
+	// ec2/meta-data.
+	Endpoint string `json:"endpoint"`
+
+	Node *Node `json:"node"`
+
+	ContentType string `json:"content_type"`
+
+	// Document is the rendered response, decoded when it is JSON and a
+	// string otherwise.
+	Document any `json:"document"`
+}
+
+// Node describes the node a response is served for.
+type Node struct {
+	UUID           string         `json:"uuid"`
+	Name           string         `json:"name,omitempty"`
+	ProvisionState string         `json:"provision_state,omitempty"`
+	ProjectID      string         `json:"project_id,omitempty"`
+	ResourceClass  string         `json:"resource_class,omitempty"`
+	Traits         []string       `json:"traits,omitempty"`
+	Properties     map[string]any `json:"properties,omitempty"`
+	Extra          map[string]any `json:"extra,omitempty"`
+}
+
+// Output is the output of the hook. An empty output, or one without a
+// document, serves the document unchanged.
+type Output struct {
+	// Document replaces the document served: any JSON value for JSON
+	// documents, and a string for others.
+	Document json.RawMessage `json:"document,omitempty"`
+}
+
+// Mutator mutates the documents handed to it, as a Hook does: Mutate
+// returns the document to serve, or nil when it is served unchanged.
+type Mutator interface {
+	Mutate(ctx context.Context, input *Input) (json.RawMessage, error)
+}
+
+// Chain is a Mutator handing a document to each of its mutators in turn,
+// each given the document as the previous one returned it.
+type Chain []Mutator
+
+// Mutate implements Mutator.
+func (c Chain) Mutate(ctx context.Context, input *Input) (json.RawMessage, error) {
+	next := *input
+	var mutated json.RawMessage
+	for _, mutator := range c {
+		document, err := mutator.Mutate(ctx, &next)
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(document))
+		decoder.UseNumber()
+		if err := decoder.Decode(&next.Document); err != nil {
+			return nil, fmt.Errorf("failed to decode mutated document: %w", err)
+		}
+		mutated = document
+	}
+	return mutated, nil
+}
+
+// Hook calls a mutation hook.
+type Hook struct {
+	cfg        Config
+	httpClient *http.Client
+}
+
+// New returns a Hook calling the command or webhook of cfg.
+func New(cfg Config) (*Hook, error) {
+	switch {
+	case len(cfg.Command) == 0 && cfg.URL == "":
+		return nil, errors.New("a hook command or URL is required")
+	case len(cfg.Command) > 0 && cfg.URL != "":
+		return nil, errors.New("a hook cannot have both a command and a URL")
+	}
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid hook URL %q: expected an http(s) URL", cfg.URL)
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Hook{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Mutate hands input to the hook and returns the document it returned, or
+// nil when the document is served unchanged.
+func (h *Hook) Mutate(ctx context.Context, input *Input) (json.RawMessage, error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal hook input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+	var out []byte
+	if h.cfg.URL != "" {
+		out, err = h.post(ctx, b)
+	} else {
+		out, err = h.run(ctx, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	var output Output
+	if err := json.Unmarshal(out, &output); err != nil {
+		return nil, fmt.Errorf("failed to decode hook output: %w", err)
+	}
+	return output.Document, nil
+}
+
+// post posts b to the webhook, returning its response.
+func (h *Hook) post(ctx context.Context, b []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.cfg.Token)
+	}
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call hook: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("hook failed: %s", resp.Status)
+	}
+	out, err := io.ReadAll(io.LimitReader(resp.Body, maxOutputSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hook response: %w", err)
+	}
+	if len(out) > maxOutputSize {
+		return nil, fmt.Errorf("hook response exceeds %d bytes", maxOutputSize)
+	}
+	return out, nil
+}
+
+// run runs the command with b as its standard input, returning its
+// standard output.
+func (h *Hook) run(ctx context.Context, b []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, h.cfg.Command[0], h.cfg.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(b)
+	// Children of the command may hold its output open once it is killed.
+	cmd.WaitDelay = waitDelay
+	var stdout, stderr limitedBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("hook command timed out after %s", h.cfg.Timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("hook command failed: %w: %s", err, firstLine(msg))
+		}
+		return nil, fmt.Errorf("hook command failed: %w", err)
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("hook output exceeds %d bytes", maxOutputSize)
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer is a buffer keeping the first maxOutputSize bytes written
+// to it, so a runaway command cannot exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := maxOutputSize - b.Len(); len(p) > room {
+		p = p[:max(room, 0)]
+		b.truncated = true
+	}
+	b.Buffer.Write(p)
+	return n, nil
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,149 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    Config
+		wantErr bool
+	}{
+		{name: "command", have: Config{Command: []string{"mutate"}}},
+		{name: "url", have: Config{URL: "https://hook.example.com/mutate"}},
+		{name: "neither", have: Config{}, wantErr: true},
+		{
+			name:    "both",
+			have:    Config{Command: []string{"mutate"}, URL: "https://hook.example.com"},
+			wantErr: true,
+		},
+		{name: "invalid url", have: Config{URL: "hook.example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHook_Mutate_webhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "mutated",
+			status: http.StatusOK,
+			body:   `{"document":{"role":"web"}}`,
+			want:   `{"role":"web"}`,
+		},
+		{name: "unchanged", status: http.StatusOK, body: `{}`},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "error status", status: http.StatusBadGateway, wantErr: true},
+		{name: "invalid output", status: http.StatusOK, body: "role: web", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input Input
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					if have := r.Header.Get("Authorization"); have != "Bearer secret" {
+						t.Errorf("have authorization %q, want the bearer token", have)
+					}
+					if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+						t.Errorf("unexpected error: %v", err)
+					}
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+				}))
+			defer server.Close()
+
+			h, err := New(Config{URL: server.URL, Token: "secret"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			have, err := h.Mutate(context.Background(), &Input{
+				Endpoint: "meta_data.json",
+				Node:     &Node{UUID: "node-1"},
+				Document: map[string]any{"role": "db"},
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(have) != tt.want {
+				t.Errorf("have document %q, want %q", have, tt.want)
+			}
+			if input.Endpoint != "meta_data.json" || input.Node == nil ||
+				input.Node.UUID != "node-1" {
+				t.Errorf("have input %+v, want the endpoint and node", input)
+			}
+		})
+	}
+}
+
+func TestHook_Mutate_command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		wantErr bool
+	}{
+		{name: "mutated", script: `echo '{"document":"#!/bin/sh"}'`, want: `"#!/bin/sh"`},
+		{name: "unchanged", script: "cat >/dev/null"},
+		{name: "failed", script: "echo denied >&2; exit 1", wantErr: true},
+		{name: "timed out", script: "sleep 5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := New(Config{
+				Command: []string{"sh", "-c", tt.script},
+				Timeout: 500 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			have, err := h.Mutate(context.Background(), &Input{Document: "#cloud-config\n"})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(have) != tt.want {
+				t.Errorf("have document %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package hostkeys validates, seals and renders the SSH host keys of nodes
+// escrowed by the metadata service, so re-imaged nodes can be given back
+// the host keys they had.
+package hostkeys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"gopkg.in/yaml.v2"
+)
+
+// Types lists the host key types escrowed, named as cloud-init names them.
+var Types = []string{"rsa", "ecdsa", "ed25519"}
+
+// KeySize is the size of the keys sealing private host keys.
+const KeySize = 32
+
+// Key is an escrowed host key.
+type Key struct {
+	// Public is the public key, as in ssh_host_<type>_key.pub.
+	Public string
+
+	// Private is the PEM encoded private key, empty when only the public
+	// key is escrowed.
+	Private string
+}
+
+// ParsePublic parses the public host key of a key type, returning it
+// without its comment, which names the host that generated it.
+func ParsePublic(keyType, line string) (string, error) {
+	key, err := metadata.ParsePublicKey(line)
+	if err != nil {
+		return "", err
+	}
+	if key.Algorithm != keyType {
+		return "", fmt.Errorf("expected a %s key, got %s", keyType, key.Algorithm)
+	}
+	if !strings.HasPrefix(key.Line, key.Type+" ") {
+		return "", errors.New("host keys cannot have options")
+	}
+	return key.Type + " " + key.Blob, nil
+}
+
+// ValidatePrivate checks that a private host key is a PEM encoded private
+// key, as written by ssh-keygen.
+func ValidatePrivate(private string) error {
+	block, rest := pem.Decode([]byte(private))
+	if block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return errors.New("expected a PEM private key")
+	}
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return errors.New("unexpected data after the private key")
+	}
+	return nil
+}
+
+// CloudConfig returns a #cloud-config document installing the host keys of
+// keys with a private key through the ssh module of cloud-init, or nil when
+// none has one.
+func CloudConfig(keys map[string]Key) ([]byte, error) {
+	sshKeys := yaml.MapSlice{}
+	for _, keyType := range Types {
+		key, ok := keys[keyType]
+		if !ok || key.Private == "" {
+			continue
+		}
+		sshKeys = append(sshKeys,
+			yaml.MapItem{Key: keyType + "_private", Value: strings.TrimSpace(key.Private) + "\n"},
+			yaml.MapItem{Key: keyType + "_public", Value: key.Public},
+		)
+	}
+	if len(sshKeys) == 0 {
+		return nil, nil
+	}
+
+	b, err := yaml.Marshal(yaml.MapSlice{{Key: "ssh_keys", Value: sshKeys}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cloud-config: %w", err)
+	}
+	return append([]byte("#cloud-config\n"), b...), nil
+}
+
+// Sealer encrypts private host keys with AES-256-GCM, so they can be stored
+// where others read them, such as in the extra of Ironic nodes.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer returns a Sealer encrypting with a key of KeySize bytes.
+func NewSealer(key []byte) (*Sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("expected a key of %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Sealer{aead: aead}, nil
+}
+
+// LoadSealer returns a Sealer encrypting with the base64 encoded key held
+// in a file, such as one written by openssl rand -base64 32.
+func LoadSealer(path string) (*Sealer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	return NewSealer(key)
+}
+
+// Seal encrypts a private key of a node, returning the nonce and ciphertext
+// base64 encoded. The sealed key only opens for the same node.
+func (s *Sealer) Seal(node string, private []byte) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, private, []byte(node))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a private key of a node sealed by Seal.
+func (s *Sealer) Open(node, sealed string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed key: %w", err)
+	}
+	if len(b) < s.aead.NonceSize() {
+		return nil, errors.New("sealed key is too short")
+	}
+	nonce, ciphertext := b[:s.aead.NonceSize()], b[s.aead.NonceSize():]
+	private, err := s.aead.Open(nil, nonce, ciphertext, []byte(node))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed key: %w", err)
+	}
+	return private, nil
+}
@@ -0,0 +1,466 @@
+// Package iso writes small ISO 9660 images with Joliet extensions, as used
+// for NoCloud seeds and OpenStack configdrives. Joliet preserves the mixed
+// case, dashed file names cloud-init looks for, which plain ISO 9660 cannot
+// represent.
+package iso
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// SectorSize is the logical block size of the written images.
+const SectorSize = 2048
+
+// Volume descriptor types.
+const (
+	descriptorPrimary       = 1
+	descriptorSupplementary = 2
+	descriptorTerminator    = 255
+)
+
+// firstDescriptorSector is the sector of the first volume descriptor,
+// following the 16 sectors of system area.
+const firstDescriptorSector = 16
+
+// jolietEscape is the escape sequence marking a supplementary volume
+// descriptor as Joliet UCS-2 level 3.
+var jolietEscape = []byte("%/E")
+
+// entry is a file or directory of the image.
+type entry struct {
+	name     string
+	data     []byte
+	dir      bool
+	parent   *entry
+	children []*entry
+
+	// number is the position of a directory in the path tables.
+	number int
+
+	// Location and size of the directory records of each tree, and of the
+	// data of a file, which both trees share.
+	extent        [2]uint32
+	size          [2]uint32
+	dataExtent    uint32
+	dataSectorLen uint32
+}
+
+// Trees of the image: the primary ISO 9660 names and the Joliet names.
+const (
+	treePrimary = 0
+	treeJoliet  = 1
+)
+
+// Write writes an image labelled label holding files, keyed by their slash
+// separated path. Intermediate directories are created as needed.
+func Write(w io.Writer, label string, files map[string][]byte) error {
+	return WriteAt(w, label, files, time.Now())
+}
+
+// WriteAt is like Write with an explicit recording time, for reproducible
+// images.
+func WriteAt(w io.Writer, label string, files map[string][]byte, recorded time.Time) error {
+	root, err := buildTree(files)
+	if err != nil {
+		return err
+	}
+
+	dirs := directories(root)
+	for i, dir := range dirs {
+		dir.number = i + 1
+	}
+
+	// Path tables: little and big endian for each tree.
+	var pathTables [2][]byte
+	for tree := range pathTables {
+		pathTables[tree] = pathTable(dirs, tree, binary.LittleEndian)
+	}
+
+	// Allocate the sectors following the descriptors and terminator.
+	next := uint32(firstDescriptorSector + 3)
+	var pathTableLocations [2][2]uint32
+	for tree := range pathTables {
+		n := sectors(uint32(len(pathTables[tree])))
+		pathTableLocations[tree][0] = next
+		pathTableLocations[tree][1] = next + n
+		next += 2 * n
+	}
+
+	for tree := range 2 {
+		for _, dir := range dirs {
+			dir.size[tree] = directorySize(dir, tree)
+			dir.extent[tree] = next
+			next += dir.size[tree] / SectorSize
+		}
+	}
+
+	for _, f := range fileEntries(root) {
+		f.dataExtent = next
+		f.dataSectorLen = sectors(uint32(len(f.data)))
+		next += f.dataSectorLen
+	}
+	totalSectors := next
+
+	buf := &bytes.Buffer{}
+	buf.Grow(int(totalSectors) * SectorSize)
+
+	buf.Write(make([]byte, firstDescriptorSector*SectorSize))
+	for tree, descriptorType := range []byte{descriptorPrimary, descriptorSupplementary} {
+		buf.Write(volumeDescriptor(descriptorType, label, root, totalSectors,
+			uint32(len(pathTables[tree])), pathTableLocations[tree], tree, recorded))
+	}
+	terminator := make([]byte, SectorSize)
+	terminator[0] = descriptorTerminator
+	copy(terminator[1:], "CD001")
+	terminator[6] = 1
+	buf.Write(terminator)
+
+	for tree := range pathTables {
+		writePadded(buf, pathTable(dirs, tree, binary.LittleEndian))
+		writePadded(buf, pathTable(dirs, tree, binary.BigEndian))
+	}
+
+	for tree := range 2 {
+		for _, dir := range dirs {
+			buf.Write(directoryRecords(dir, tree, recorded))
+		}
+	}
+
+	for _, f := range fileEntries(root) {
+		writePadded(buf, f.data)
+	}
+
+	if uint32(buf.Len()) != totalSectors*SectorSize {
+		return fmt.Errorf("iso layout mismatch: wrote %d bytes, expected %d",
+			buf.Len(), totalSectors*SectorSize)
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// buildTree arranges files into a directory tree with sorted children.
+func buildTree(files map[string][]byte) (*entry, error) {
+	root := &entry{dir: true}
+	root.parent = root
+
+	for name, data := range files {
+		clean := strings.Trim(path.Clean("/"+name), "/")
+		if clean == "" {
+			return nil, fmt.Errorf("invalid file path %q", name)
+		}
+
+		dir := root
+		parts := strings.Split(clean, "/")
+		for i, part := range parts {
+			child := dir.child(part)
+			last := i == len(parts)-1
+			switch {
+			case child == nil && last:
+				dir.children = append(dir.children, &entry{name: part, data: data, parent: dir})
+			case child == nil:
+				child = &entry{name: part, dir: true, parent: dir}
+				dir.children = append(dir.children, child)
+				dir = child
+			case last || !child.dir:
+				return nil, fmt.Errorf("conflicting file path %q", name)
+			default:
+				dir = child
+			}
+		}
+	}
+
+	var sortChildren func(*entry)
+	sortChildren = func(e *entry) {
+		sort.Slice(e.children, func(i, j int) bool {
+			return e.children[i].name < e.children[j].name
+		})
+		for _, c := range e.children {
+			sortChildren(c)
+		}
+	}
+	sortChildren(root)
+
+	if len(directories(root)) > 0xffff {
+		return nil, errors.New("too many directories")
+	}
+	return root, nil
+}
+
+// child returns the child of a directory named name.
+func (e *entry) child(name string) *entry {
+	for _, c := range e.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// directories lists the directories of a tree breadth first, the order
+// required for path tables.
+func directories(root *entry) []*entry {
+	dirs := []*entry{root}
+	for i := 0; i < len(dirs); i++ {
+		for _, c := range dirs[i].children {
+			if c.dir {
+				dirs = append(dirs, c)
+			}
+		}
+	}
+	return dirs
+}
+
+// fileEntries lists the files of a tree in directory order.
+func fileEntries(root *entry) []*entry {
+	var files []*entry
+	for _, dir := range directories(root) {
+		for _, c := range dir.children {
+			if !c.dir {
+				files = append(files, c)
+			}
+		}
+	}
+	return files
+}
+
+// identifier returns the name of an entry as recorded in a tree. Primary
+// names are upper cased d-characters with a version suffix on files, Joliet
+// names are UCS-2 big endian.
+func (e *entry) identifier(tree int) []byte {
+	if tree == treeJoliet {
+		return ucs2(e.name)
+	}
+
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, e.name)
+	if len(name) > 30 {
+		name = name[:30]
+	}
+	if e.dir {
+		return []byte(strings.ReplaceAll(name, ".", "_"))
+	}
+	return []byte(name + ";1")
+}
+
+// ucs2 encodes s as UCS-2 big endian, truncated to the 64 characters Joliet
+// allows.
+func ucs2(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	if len(units) > 64 {
+		units = units[:64]
+	}
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.BigEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// recordLength returns the length of a directory record with an identifier
+// of n bytes, padded to an even length.
+func recordLength(n int) int {
+	l := 33 + n
+	if l%2 == 1 {
+		l++
+	}
+	return l
+}
+
+// directorySize returns the size of the directory records of dir, rounded
+// to whole sectors. Records never span a sector boundary.
+func directorySize(dir *entry, tree int) uint32 {
+	size := 0
+	add := func(l int) {
+		if size%SectorSize+l > SectorSize {
+			size += SectorSize - size%SectorSize
+		}
+		size += l
+	}
+	add(recordLength(1))
+	add(recordLength(1))
+	for _, c := range dir.children {
+		add(recordLength(len(c.identifier(tree))))
+	}
+	return sectors(uint32(size)) * SectorSize
+}
+
+// directoryRecords renders the records of dir, starting with the "." and
+// ".." entries.
+func directoryRecords(dir *entry, tree int, recorded time.Time) []byte {
+	out := make([]byte, 0, dir.size[tree])
+	add := func(record []byte) {
+		if len(out)%SectorSize+len(record) > SectorSize {
+			out = append(out, make([]byte, SectorSize-len(out)%SectorSize)...)
+		}
+		out = append(out, record...)
+	}
+
+	add(dirRecord([]byte{0}, dir.extent[tree], dir.size[tree], true, recorded))
+	add(dirRecord([]byte{1}, dir.parent.extent[tree], dir.parent.size[tree], true, recorded))
+	for _, c := range dir.children {
+		if c.dir {
+			add(dirRecord(c.identifier(tree), c.extent[tree], c.size[tree], true, recorded))
+		} else {
+			add(dirRecord(c.identifier(tree), c.dataExtent, uint32(len(c.data)), false, recorded))
+		}
+	}
+	return append(out, make([]byte, int(dir.size[tree])-len(out))...)
+}
+
+// dirRecord renders a single directory record.
+func dirRecord(id []byte, extent, size uint32, dir bool, recorded time.Time) []byte {
+	r := make([]byte, recordLength(len(id)))
+	r[0] = byte(len(r))
+	putBothUint32(r[2:], extent)
+	putBothUint32(r[10:], size)
+	copy(r[18:25], recordingTime(recorded))
+	if dir {
+		r[25] = 0x02
+	}
+	putBothUint16(r[28:], 1)
+	r[32] = byte(len(id))
+	copy(r[33:], id)
+	return r
+}
+
+// pathTable renders the path table of a tree in the given byte order.
+func pathTable(dirs []*entry, tree int, order binary.ByteOrder) []byte {
+	var out []byte
+	for _, dir := range dirs {
+		id := []byte{0}
+		if dir.parent != dir {
+			id = dir.identifier(tree)
+		}
+		record := make([]byte, 8+len(id)+len(id)%2)
+		record[0] = byte(len(id))
+		order.PutUint32(record[2:], dir.extent[tree])
+		order.PutUint16(record[6:], uint16(dir.parent.number))
+		copy(record[8:], id)
+		out = append(out, record...)
+	}
+	return out
+}
+
+// volumeDescriptor renders a primary or Joliet supplementary volume
+// descriptor.
+func volumeDescriptor(
+	descriptorType byte,
+	label string,
+	root *entry,
+	totalSectors, pathTableSize uint32,
+	pathTableLocations [2]uint32,
+	tree int,
+	recorded time.Time,
+) []byte {
+	d := make([]byte, SectorSize)
+	d[0] = descriptorType
+	copy(d[1:], "CD001")
+	d[6] = 1
+
+	text := func(field []byte, s string) {
+		if tree == treeJoliet {
+			for i := 0; i+1 < len(field); i += 2 {
+				field[i], field[i+1] = 0, ' '
+			}
+			copy(field, ucs2(s))
+			return
+		}
+		for i := range field {
+			field[i] = ' '
+		}
+		copy(field, s)
+	}
+
+	text(d[8:40], "LINUX")
+	text(d[40:72], label)
+	putBothUint32(d[80:], totalSectors)
+	if tree == treeJoliet {
+		copy(d[88:], jolietEscape)
+	}
+	putBothUint16(d[120:], 1)
+	putBothUint16(d[124:], 1)
+	putBothUint16(d[128:], SectorSize)
+	putBothUint32(d[132:], pathTableSize)
+	binary.LittleEndian.PutUint32(d[140:], pathTableLocations[0])
+	binary.BigEndian.PutUint32(d[148:], pathTableLocations[1])
+	copy(d[156:190], dirRecord([]byte{0}, root.extent[tree], root.size[tree], true, recorded))
+	text(d[190:318], "")
+	text(d[318:446], "")
+	text(d[446:574], "")
+	text(d[574:702], "IRONIC-METADATA")
+	text(d[702:739], "")
+	text(d[739:776], "")
+	text(d[776:813], "")
+	copy(d[813:830], volumeTime(recorded))
+	copy(d[830:847], volumeTime(recorded))
+	copy(d[847:864], volumeTime(time.Time{}))
+	copy(d[864:881], volumeTime(recorded))
+	d[881] = 1
+	return d
+}
+
+// recordingTime renders the 7 byte timestamp of directory records.
+func recordingTime(t time.Time) []byte {
+	t = t.UTC()
+	return []byte{
+		byte(t.Year() - 1900), byte(t.Month()), byte(t.Day()),
+		byte(t.Hour()), byte(t.Minute()), byte(t.Second()), 0,
+	}
+}
+
+// volumeTime renders the 17 byte timestamp of volume descriptors. The zero
+// time renders as "not specified".
+func volumeTime(t time.Time) []byte {
+	if t.IsZero() {
+		b := []byte("0000000000000000 ")
+		b[16] = 0
+		return b
+	}
+	t = t.UTC()
+	b := []byte(fmt.Sprintf("%04d%02d%02d%02d%02d%02d%02d ",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/1e7))
+	b[16] = 0
+	return b
+}
+
+// putBothUint32 writes v in both byte orders, as ISO 9660 requires.
+func putBothUint32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
+
+// putBothUint16 writes v in both byte orders, as ISO 9660 requires.
+func putBothUint16(b []byte, v uint16) {
+	binary.LittleEndian.PutUint16(b, v)
+	binary.BigEndian.PutUint16(b[2:], v)
+}
+
+// sectors returns the number of sectors needed for n bytes.
+func sectors(n uint32) uint32 {
+	return (n + SectorSize - 1) / SectorSize
+}
+
+// writePadded writes data padded with zeroes to a whole sector.
+func writePadded(buf *bytes.Buffer, data []byte) {
+	buf.Write(data)
+	if rem := len(data) % SectorSize; rem != 0 {
+		buf.Write(make([]byte, SectorSize-rem))
+	}
+}
@@ -0,0 +1,145 @@
+package iso
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/kdomanski/iso9660"
+)
+
+var testFiles = map[string][]byte{
+	"meta-data":                       []byte("instance-id: test-uuid\n"),
+	"user-data":                       []byte("#cloud-config\n"),
+	"openstack/latest/meta_data.json": []byte(`{"uuid": "test-uuid"}`),
+	"openstack/content/0000":          bytes.Repeat([]byte("x"), 3*SectorSize+1),
+}
+
+func writeTestImage(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := WriteAt(&buf, "cidata", testFiles, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// jolietFiles walks the Joliet tree of an image, returning file contents by
+// path.
+func jolietFiles(t *testing.T, image []byte) map[string][]byte {
+	t.Helper()
+
+	svd := image[(firstDescriptorSector+1)*SectorSize:]
+	if svd[0] != descriptorSupplementary || !bytes.Equal(svd[88:91], jolietEscape) {
+		t.Fatal("second volume descriptor is not a Joliet descriptor")
+	}
+
+	files := make(map[string][]byte)
+	var walk func(extent, size uint32, prefix string)
+	walk = func(extent, size uint32, prefix string) {
+		records := image[extent*SectorSize : extent*SectorSize+size]
+		for offset := 0; offset < len(records); {
+			length := int(records[offset])
+			if length == 0 {
+				// Skip the padding up to the next sector.
+				offset += SectorSize - offset%SectorSize
+				continue
+			}
+			record := records[offset : offset+length]
+			offset += length
+
+			id := record[33 : 33+int(record[32])]
+			if len(id) == 1 && (id[0] == 0 || id[0] == 1) {
+				continue
+			}
+			units := make([]uint16, len(id)/2)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(id[2*i:])
+			}
+			name := prefix + string(utf16.Decode(units))
+
+			childExtent := binary.LittleEndian.Uint32(record[2:])
+			childSize := binary.LittleEndian.Uint32(record[10:])
+			if record[25]&0x02 != 0 {
+				walk(childExtent, childSize, name+"/")
+				continue
+			}
+			files[name] = image[childExtent*SectorSize : childExtent*SectorSize+childSize]
+		}
+	}
+
+	root := svd[156:190]
+	walk(binary.LittleEndian.Uint32(root[2:]), binary.LittleEndian.Uint32(root[10:]), "")
+	return files
+}
+
+func TestWriteJoliet(t *testing.T) {
+	files := jolietFiles(t, writeTestImage(t))
+
+	if len(files) != len(testFiles) {
+		t.Errorf("expected %d files, got %d", len(testFiles), len(files))
+	}
+	for name, want := range testFiles {
+		if have, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		} else if !bytes.Equal(have, want) {
+			t.Errorf("%s: have %d bytes, want %d bytes", name, len(have), len(want))
+		}
+	}
+}
+
+func TestWritePrimary(t *testing.T) {
+	image, err := iso9660.OpenImage(bytes.NewReader(writeTestImage(t)))
+	if err != nil {
+		t.Fatalf("failed to open image: %v", err)
+	}
+
+	label, err := image.Label()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(label) != "cidata" {
+		t.Errorf("expected label cidata, got %q", label)
+	}
+
+	root, err := image.RootDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	children, err := root.GetChildren()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, c := range children {
+		names = append(names, c.Name())
+		if c.Name() == "META_DATA" {
+			data, err := io.ReadAll(c.Reader())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, testFiles["meta-data"]) {
+				t.Errorf("META_DATA: have %q, want %q", data, testFiles["meta-data"])
+			}
+		}
+	}
+	if have, want := strings.Join(names, ","), "META_DATA,OPENSTACK,USER_DATA"; have != want {
+		t.Errorf("root entries: have %s, want %s", have, want)
+	}
+}
+
+func TestWriteConflictingPaths(t *testing.T) {
+	err := Write(io.Discard, "cidata", map[string][]byte{
+		"openstack":        []byte("file"),
+		"openstack/latest": []byte("file below a file"),
+	})
+	if err == nil {
+		t.Error("expected error for a file used as a directory")
+	}
+}
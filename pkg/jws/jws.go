@@ -0,0 +1,250 @@
+// Package jws signs metadata documents as JSON Web Signatures (RFC 7515) in
+// compact serialization, so instances can verify that documents served over
+// the provisioning network come from the metadata service.
+package jws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when a signature does not verify.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// encoding is the unpadded base64url encoding of JWS segments.
+var encoding = base64.RawURLEncoding
+
+// header is the JWS protected header.
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// algorithm describes a JWS signature algorithm.
+type algorithm struct {
+	name string
+	hash crypto.Hash
+	// size is the byte length of each of r and s in ECDSA signatures.
+	size int
+}
+
+// Signer signs payloads with the service key.
+type Signer struct {
+	key   crypto.Signer
+	alg   algorithm
+	keyID string
+}
+
+// NewSigner returns a signer using an RSA key, signing with RS256, or an
+// ECDSA key on P-256, P-384 or P-521, signing with ES256, ES384 or ES512.
+func NewSigner(key crypto.Signer) (*Signer, error) {
+	alg, err := algorithmFor(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := Thumbprint(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, alg: alg, keyID: keyID}, nil
+}
+
+// algorithmFor returns the signature algorithm used with a public key.
+func algorithmFor(pub crypto.PublicKey) (algorithm, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return algorithm{name: "RS256", hash: crypto.SHA256}, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return algorithm{name: "ES256", hash: crypto.SHA256, size: 32}, nil
+		case elliptic.P384():
+			return algorithm{name: "ES384", hash: crypto.SHA384, size: 48}, nil
+		case elliptic.P521():
+			return algorithm{name: "ES512", hash: crypto.SHA512, size: 66}, nil
+		}
+		return algorithm{}, fmt.Errorf("unsupported curve %s", pub.Curve.Params().Name)
+	}
+	return algorithm{}, fmt.Errorf("unsupported signing key type %T", pub)
+}
+
+// KeyID returns the key ID set in signature headers, the RFC 7638
+// thumbprint of the public key.
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Sign returns a JWS with payload embedded.
+func (s *Signer) Sign(payload []byte) (string, error) {
+	protected, signature, err := s.sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return protected + "." + encoding.EncodeToString(payload) + "." + signature, nil
+}
+
+// SignDetached returns a JWS over payload with the payload segment left
+// empty, as in RFC 7515 appendix F, for sending alongside the payload.
+func (s *Signer) SignDetached(payload []byte) (string, error) {
+	protected, signature, err := s.sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return protected + ".." + signature, nil
+}
+
+// sign returns the encoded protected header and signature over payload.
+func (s *Signer) sign(payload []byte) (string, string, error) {
+	b, err := json.Marshal(header{Algorithm: s.alg.name, KeyID: s.keyID})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal header: %w", err)
+	}
+	protected := encoding.EncodeToString(b)
+
+	h := s.alg.hash.New()
+	h.Write([]byte(protected + "." + encoding.EncodeToString(payload)))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *ecdsa.PrivateKey:
+		// JWS uses the fixed size r || s form rather than ASN.1.
+		r, sig, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to sign: %w", err)
+		}
+		signature = make([]byte, 2*s.alg.size)
+		r.FillBytes(signature[:s.alg.size])
+		sig.FillBytes(signature[s.alg.size:])
+	default:
+		if signature, err = s.key.Sign(rand.Reader, digest, s.alg.hash); err != nil {
+			return "", "", fmt.Errorf("failed to sign: %w", err)
+		}
+	}
+	return protected, encoding.EncodeToString(signature), nil
+}
+
+// Verify checks a JWS against key and returns its payload. For detached
+// signatures the payload is passed as detached; it is ignored otherwise.
+func Verify(token string, detached []byte, key crypto.PublicKey) ([]byte, error) {
+	segments := strings.Split(strings.TrimSpace(token), ".")
+	if len(segments) != 3 {
+		return nil, errors.New("malformed JWS: expected three segments")
+	}
+
+	b, err := encoding.DecodeString(segments[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+	var hdr header
+	if err := json.Unmarshal(b, &hdr); err != nil {
+		return nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+	alg, err := algorithmFor(key)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Algorithm != alg.name {
+		return nil, fmt.Errorf("unexpected algorithm %q for key, want %q", hdr.Algorithm, alg.name)
+	}
+
+	payload := detached
+	if segments[1] != "" {
+		if payload, err = encoding.DecodeString(segments[1]); err != nil {
+			return nil, fmt.Errorf("malformed JWS payload: %w", err)
+		}
+	}
+	signature, err := encoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWS signature: %w", err)
+	}
+
+	h := alg.hash.New()
+	h.Write([]byte(segments[0] + "." + encoding.EncodeToString(payload)))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, alg.hash, digest, signature); err != nil {
+			return nil, ErrInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		if len(signature) != 2*alg.size {
+			return nil, ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:alg.size])
+		s := new(big.Int).SetBytes(signature[alg.size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return nil, ErrInvalidSignature
+		}
+	}
+	return payload, nil
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of a public key.
+func Thumbprint(pub crypto.PublicKey) (string, error) {
+	// The members of the JWK are serialized in lexical order without
+	// whitespace, which encoding/json does for maps.
+	var members map[string]string
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		members = map[string]string{
+			"e":   encoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			"kty": "RSA",
+			"n":   encoding.EncodeToString(pub.N.Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		x := make([]byte, size)
+		y := make([]byte, size)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		members = map[string]string{
+			"crv": pub.Curve.Params().Name,
+			"kty": "EC",
+			"x":   encoding.EncodeToString(x),
+			"y":   encoding.EncodeToString(y),
+		}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JWK: %w", err)
+	}
+	digest := sha256.Sum256(b)
+	return encoding.EncodeToString(digest[:]), nil
+}
+
+// ParsePublicKey decodes a PEM encoded PKIX public key or certificate.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		return cert.PublicKey, nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return pub, nil
+}
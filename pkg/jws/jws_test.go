@@ -0,0 +1,130 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"uuid": "test-uuid"}`)
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		alg  string
+	}{
+		{name: "rsa", key: rsaKey, alg: "RS256"},
+		{name: "p256", key: p256Key, alg: "ES256"},
+		{name: "p384", key: p384Key, alg: "ES384"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSigner(tt.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			token, err := s.Sign(payload)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			have, err := Verify(token, nil, tt.key.Public())
+			if err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			if string(have) != string(payload) {
+				t.Errorf("payload: have %q, want %q", have, payload)
+			}
+
+			detached, err := s.SignDetached(payload)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(detached, "..") {
+				t.Errorf("expected detached payload, got %q", detached)
+			}
+			if _, err := Verify(detached, payload, tt.key.Public()); err != nil {
+				t.Errorf("failed to verify detached signature: %v", err)
+			}
+			_, err = Verify(detached, []byte(`{"uuid": "other"}`), tt.key.Public())
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("expected tampered payload to fail, got %v", err)
+			}
+			if _, err := Verify(detached, payload, otherKey.Public()); err == nil {
+				t.Error("expected other key to fail")
+			}
+		})
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// The example of RFC 7638 section 3.1.
+	n, err := encoding.DecodeString(
+		"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86z" +
+			"wu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5Js" +
+			"GY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMic" +
+			"AtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-" +
+			"bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csF" +
+			"Cur-kEgU8awapJzKnqDKgw",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	have, err := Thumbprint(pub)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !key.PublicKey.Equal(pub) {
+		t.Error("parsed public key does not match")
+	}
+
+	if _, err := ParsePublicKey([]byte("not pem")); err == nil {
+		t.Error("expected error for data that is not PEM encoded")
+	}
+}
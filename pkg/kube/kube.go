@@ -0,0 +1,211 @@
+// Package kube reads Secrets and ConfigMaps from the Kubernetes API, for
+// deployments running in-cluster next to Metal3 where user data is managed
+// as Kubernetes resources, and manages the Leases used for leader election.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Paths of the service account credentials Kubernetes mounts into pods.
+const (
+	DefaultTokenPath     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultCAPath        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	DefaultNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// ErrNotFound is returned for resources that do not exist.
+var ErrNotFound = errors.New("kubernetes resource not found")
+
+// ErrConflict is returned when a resource was modified concurrently.
+var ErrConflict = errors.New("kubernetes resource modified concurrently")
+
+// Config configures a Client.
+type Config struct {
+	// Host is the API server URL.
+	Host string
+
+	// Namespace holds the resources read and written by the client.
+	Namespace string
+
+	// TokenPath is the bearer token file, which is read on every request
+	// since projected service account tokens are rotated. No token is sent
+	// when it is empty.
+	TokenPath string
+
+	// CAPath is the CA bundle verifying the API server. The system roots
+	// are used when it is empty.
+	CAPath string
+}
+
+// Client accesses resources in one namespace.
+type Client struct {
+	host       string
+	namespace  string
+	tokenPath  string
+	httpClient *http.Client
+}
+
+// InClusterConfig returns the configuration of a client running in a pod,
+// reading from namespace or, when it is empty, from the pod's namespace.
+func InClusterConfig(namespace string) (Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return Config{}, errors.New("not running in a Kubernetes cluster")
+	}
+
+	if namespace == "" {
+		b, err := os.ReadFile(DefaultNamespacePath)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+
+	return Config{
+		Host:      "https://" + net.JoinHostPort(host, port),
+		Namespace: namespace,
+		TokenPath: DefaultTokenPath,
+		CAPath:    DefaultCAPath,
+	}, nil
+}
+
+// New returns a client for the API server described by cfg.
+func New(cfg Config) (*Client, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("kubernetes API host is required")
+	}
+	if cfg.Namespace == "" {
+		return nil, errors.New("kubernetes namespace is required")
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if cfg.CAPath != "" {
+		pem, err := os.ReadFile(cfg.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAPath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &Client{
+		host:       strings.TrimRight(cfg.Host, "/"),
+		namespace:  cfg.Namespace,
+		tokenPath:  cfg.TokenPath,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+	}, nil
+}
+
+// Namespace returns the namespace the client reads from.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// SecretData returns the decoded data of a Secret.
+func (c *Client) SecretData(ctx context.Context, name string) (map[string][]byte, error) {
+	var secret struct {
+		Data map[string][]byte `json:"data"`
+	}
+	if err := c.get(ctx, "secrets", name, &secret); err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+// ConfigMapData returns the data of a ConfigMap, including binary data.
+func (c *Client) ConfigMapData(ctx context.Context, name string) (map[string][]byte, error) {
+	var configMap struct {
+		Data       map[string]string `json:"data"`
+		BinaryData map[string][]byte `json:"binaryData"`
+	}
+	if err := c.get(ctx, "configmaps", name, &configMap); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for key, value := range configMap.BinaryData {
+		data[key] = value
+	}
+	for key, value := range configMap.Data {
+		data[key] = []byte(value)
+	}
+	return data, nil
+}
+
+// get fetches a namespaced core/v1 resource into out.
+func (c *Client) get(ctx context.Context, resource, name string, out any) error {
+	path := "/api/v1/namespaces/" + url.PathEscape(c.namespace) + "/" + resource + "/" +
+		url.PathEscape(name)
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// do sends a request with in as JSON body, unless it is nil, and decodes
+// the response into out.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.tokenPath != "" {
+		token, err := os.ReadFile(c.tokenPath)
+		if err != nil {
+			return fmt.Errorf("failed to read service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", method, path, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		var status struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&status)
+		return fmt.Errorf("failed to %s %s: %s: %s", method, path, resp.Status, status.Message)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package kube
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for an API server holding:
+//   - Secret node-web01 with userData "#cloud-config\nhostname: web01\n"
+//   - ConfigMap node-web02 with userData "#!/bin/sh\n"
+//   - ConfigMap node-db01 without user data
+//   - Secret userdata-default with value "#cloud-config\n"
+func newTestClient(t *testing.T, requests *atomic.Int32) *Client {
+	t.Helper()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("sa-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	const prefix = "/api/v1/namespaces/metal3/"
+	objects := map[string]string{
+		prefix + "secrets/node-web01": `{"data": {"userData": ` +
+			`"I2Nsb3VkLWNvbmZpZwpob3N0bmFtZTogd2ViMDEK"}}`,
+		prefix + "configmaps/node-web02":    `{"data": {"userData": "#!/bin/sh\n"}}`,
+		prefix + "configmaps/node-db01":     `{"data": {"other": "x"}}`,
+		prefix + "secrets/userdata-default": `{"data": {"value": "I2Nsb3VkLWNvbmZpZwo="}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "Unauthorized"}`))
+			return
+		}
+		body, ok := objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := New(Config{Host: srv.URL, Namespace: "metal3", TokenPath: tokenPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	var requests atomic.Int32
+	c := newTestClient(t, &requests)
+
+	secret, err := c.SecretData(t.Context(), "node-web01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, want := string(secret["userData"]), "#cloud-config\nhostname: web01\n"; have != want {
+		t.Errorf("secret userData: have %q, want %q", have, want)
+	}
+
+	configMap, err := c.ConfigMapData(t.Context(), "node-web02")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, want := string(configMap["userData"]), "#!/bin/sh\n"; have != want {
+		t.Errorf("configmap userData: have %q, want %q", have, want)
+	}
+
+	if _, err := c.SecretData(t.Context(), "node-web02"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestUserDataSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultName string
+		have        []string
+		want        string
+	}{
+		{name: "secret", have: []string{"web01", "uuid-1"}, want: "#cloud-config\nhostname: web01\n"},
+		{name: "configmap", have: []string{"web02"}, want: "#!/bin/sh\n"},
+		{name: "by uuid", have: []string{"", "web01"}, want: "#cloud-config\nhostname: web01\n"},
+		{name: "no user data key", have: []string{"db01"}},
+		{name: "missing", have: []string{"web03"}},
+		{
+			name:        "default",
+			defaultName: "userdata-default",
+			have:        []string{"web03"},
+			want:        "#cloud-config\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			s := NewUserDataSource(newTestClient(t, &requests), "node-{node}", tt.defaultName, 0)
+
+			have, err := s.UserData(t.Context(), tt.have...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(have) != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserDataSource_cache(t *testing.T) {
+	var requests atomic.Int32
+	s := NewUserDataSource(newTestClient(t, &requests), "node-{node}", "", time.Minute)
+
+	for range 3 {
+		if _, err := s.UserData(t.Context(), "web01"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.UserData(t.Context(), "web03"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// One request for the web01 secret, two for the missing web03 secret
+	// and configmap.
+	if have := requests.Load(); have != 3 {
+		t.Errorf("expected 3 requests, got %d", have)
+	}
+}
+
+func TestUserDataSource_error(t *testing.T) {
+	var requests atomic.Int32
+	c := newTestClient(t, &requests)
+	c.tokenPath = ""
+
+	s := NewUserDataSource(c, "node-{node}", "", time.Minute)
+	if _, err := s.UserData(t.Context(), "web01"); err == nil {
+		t.Fatal("expected error for unauthorized request")
+	}
+}
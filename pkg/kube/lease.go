@@ -0,0 +1,121 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// leaseTimeFormat is the MicroTime format of Lease timestamps.
+const leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Lease is a coordination.k8s.io/v1 Lease.
+type Lease struct {
+	Name            string
+	ResourceVersion string
+
+	HolderIdentity       string
+	LeaseDurationSeconds int
+	AcquireTime          time.Time
+	RenewTime            time.Time
+	LeaseTransitions     int
+}
+
+// leaseObject is the API representation of a Lease.
+type leaseObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       *string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds *int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          *string `json:"acquireTime,omitempty"`
+		RenewTime            *string `json:"renewTime,omitempty"`
+		LeaseTransitions     *int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// Lease returns a Lease.
+func (c *Client) Lease(ctx context.Context, name string) (*Lease, error) {
+	var obj leaseObject
+	if err := c.do(ctx, http.MethodGet, c.leasePath(name), nil, &obj); err != nil {
+		return nil, err
+	}
+	return obj.lease(), nil
+}
+
+// CreateLease creates a Lease, returning ErrConflict when it exists.
+func (c *Client) CreateLease(ctx context.Context, lease *Lease) (*Lease, error) {
+	var obj leaseObject
+	if err := c.do(ctx, http.MethodPost, c.leasePath(""), newLeaseObject(lease), &obj); err != nil {
+		return nil, err
+	}
+	return obj.lease(), nil
+}
+
+// UpdateLease replaces a Lease, returning ErrConflict when it was modified
+// since lease.ResourceVersion was read.
+func (c *Client) UpdateLease(ctx context.Context, lease *Lease) (*Lease, error) {
+	var obj leaseObject
+	path := c.leasePath(lease.Name)
+	if err := c.do(ctx, http.MethodPut, path, newLeaseObject(lease), &obj); err != nil {
+		return nil, err
+	}
+	return obj.lease(), nil
+}
+
+// leasePath returns the API path of a Lease, or of the Lease collection
+// when name is empty.
+func (c *Client) leasePath(name string) string {
+	path := "/apis/coordination.k8s.io/v1/namespaces/" + url.PathEscape(c.namespace) + "/leases"
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+func newLeaseObject(lease *Lease) *leaseObject {
+	obj := &leaseObject{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+	obj.Metadata.Name = lease.Name
+	obj.Metadata.ResourceVersion = lease.ResourceVersion
+	obj.Spec.HolderIdentity = &lease.HolderIdentity
+	obj.Spec.LeaseDurationSeconds = &lease.LeaseDurationSeconds
+	obj.Spec.LeaseTransitions = &lease.LeaseTransitions
+	if !lease.AcquireTime.IsZero() {
+		acquireTime := lease.AcquireTime.UTC().Format(leaseTimeFormat)
+		obj.Spec.AcquireTime = &acquireTime
+	}
+	if !lease.RenewTime.IsZero() {
+		renewTime := lease.RenewTime.UTC().Format(leaseTimeFormat)
+		obj.Spec.RenewTime = &renewTime
+	}
+	return obj
+}
+
+func (obj *leaseObject) lease() *Lease {
+	lease := &Lease{
+		Name:            obj.Metadata.Name,
+		ResourceVersion: obj.Metadata.ResourceVersion,
+	}
+	if obj.Spec.HolderIdentity != nil {
+		lease.HolderIdentity = *obj.Spec.HolderIdentity
+	}
+	if obj.Spec.LeaseDurationSeconds != nil {
+		lease.LeaseDurationSeconds = *obj.Spec.LeaseDurationSeconds
+	}
+	if obj.Spec.LeaseTransitions != nil {
+		lease.LeaseTransitions = *obj.Spec.LeaseTransitions
+	}
+	// Unparsable times are left zero, which treats the lease as expired.
+	if obj.Spec.AcquireTime != nil {
+		lease.AcquireTime, _ = time.Parse(time.RFC3339Nano, *obj.Spec.AcquireTime)
+	}
+	if obj.Spec.RenewTime != nil {
+		lease.RenewTime, _ = time.Parse(time.RFC3339Nano, *obj.Spec.RenewTime)
+	}
+	return lease
+}
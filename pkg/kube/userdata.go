@@ -0,0 +1,143 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+)
+
+// UserDataKeys are the keys holding user data in a Secret or ConfigMap, in
+// order of preference. Metal3 uses userData, Cluster API bootstrap secrets
+// use value.
+var UserDataKeys = []string{"userData", "value"}
+
+// NodePlaceholder is replaced by the node's name or UUID in resource name
+// patterns.
+const NodePlaceholder = "{node}"
+
+// UserDataSource looks up node user data in Secrets and ConfigMaps named
+// after the node, falling back to a shared default.
+type UserDataSource struct {
+	client      *Client
+	pattern     string
+	defaultName string
+	ttl         time.Duration
+
+	mu       sync.Mutex
+	cache    map[string]userDataEntry
+	counters metrics.CacheCounters
+}
+
+// userDataEntry is looked up user data, the time it was stored and the time
+// it stops being valid. A nil data records that no resource exists.
+type userDataEntry struct {
+	data    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// NewUserDataSource returns a source reading the resources named by
+// pattern, in which NodePlaceholder stands for the node, and the resource
+// named defaultName for nodes without one. Lookups, including missing
+// resources, are cached for ttl.
+func NewUserDataSource(
+	client *Client,
+	pattern, defaultName string,
+	ttl time.Duration,
+) *UserDataSource {
+	if pattern == "" {
+		pattern = NodePlaceholder
+	}
+	return &UserDataSource{
+		client:      client,
+		pattern:     pattern,
+		defaultName: defaultName,
+		ttl:         ttl,
+		cache:       make(map[string]userDataEntry),
+	}
+}
+
+// UserData returns the user data of a node known by names, typically its
+// name and UUID. For each name the Secret is preferred over the ConfigMap.
+// It returns nil when no resource holds user data for the node.
+func (s *UserDataSource) UserData(ctx context.Context, names ...string) ([]byte, error) {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		data, err := s.lookup(ctx, strings.ReplaceAll(s.pattern, NodePlaceholder, name))
+		if err != nil || data != nil {
+			return data, err
+		}
+	}
+	if s.defaultName == "" {
+		return nil, nil
+	}
+	return s.lookup(ctx, s.defaultName)
+}
+
+// lookup returns the user data held by the Secret or ConfigMap called name.
+func (s *UserDataSource) lookup(ctx context.Context, name string) ([]byte, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if entry, ok := s.cache[name]; ok && now.Before(entry.expires) {
+		s.counters.Lookup(true)
+		s.mu.Unlock()
+		return entry.data, nil
+	}
+	s.counters.Lookup(false)
+	s.mu.Unlock()
+
+	var data []byte
+	for _, get := range []func(context.Context, string) (map[string][]byte, error){
+		s.client.SecretData,
+		s.client.ConfigMapData,
+	} {
+		values, err := get(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			// Errors are not cached, as they may be transient.
+			return nil, err
+		}
+		if data = userDataValue(values); data != nil {
+			break
+		}
+	}
+
+	if s.ttl > 0 {
+		s.mu.Lock()
+		s.cache[name] = userDataEntry{data: data, stored: now, expires: now.Add(s.ttl)}
+		s.mu.Unlock()
+	}
+	return data, nil
+}
+
+// CacheStats returns the usage of the cache.
+func (s *UserDataSource) CacheStats() metrics.CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters.Stats(len(s.cache), func(yield func(time.Time) bool) {
+		for _, entry := range s.cache {
+			if !yield(entry.stored) {
+				return
+			}
+		}
+	})
+}
+
+// userDataValue returns the first user data key found in values.
+func userDataValue(values map[string][]byte) []byte {
+	for _, key := range UserDataKeys {
+		if value, ok := values[key]; ok {
+			return value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileLock is a lock backed by an advisory lock on a file, for replicas
+// sharing a host or a file system supporting locks. The lock is released by
+// the kernel when its holder exits.
+type FileLock struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileLock returns a lock on the file at path, which is created when
+// missing.
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// TryAcquire locks the file unless it is locked by another process.
+func (l *FileLock) TryAcquire(_ context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		return true, nil
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return false, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	ok, err := tryLock(f)
+	if err != nil || !ok {
+		_ = f.Close()
+		if err != nil {
+			return false, fmt.Errorf("failed to lock %s: %w", l.path, err)
+		}
+		return false, nil
+	}
+	l.file = f
+	return true, nil
+}
+
+// Release unlocks the file if it is locked.
+func (l *FileLock) Release(_ context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	// Closing the file releases the lock.
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", l.path, err)
+	}
+	return nil
+}
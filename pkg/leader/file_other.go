@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package leader
+
+import (
+	"errors"
+	"os"
+)
+
+func tryLock(_ *os.File) (bool, error) {
+	return false, errors.New("file locks are not supported on this platform")
+}
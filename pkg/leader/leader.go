@@ -0,0 +1,156 @@
+// Package leader elects one replica of the service to perform writes and
+// background syncs, so replicas of a highly available deployment do not
+// duplicate them.
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryPeriod is how often the lock is acquired or renewed.
+const DefaultRetryPeriod = 2 * time.Second
+
+// Lock is a lock held by at most one replica.
+type Lock interface {
+	// TryAcquire acquires or renews the lock without waiting, reporting
+	// whether it is held.
+	TryAcquire(ctx context.Context) (bool, error)
+
+	// Release releases the lock if it is held.
+	Release(ctx context.Context) error
+}
+
+// Config configures an Elector.
+type Config struct {
+	// Lock is the lock held by the leader.
+	Lock Lock
+
+	// Identity identifies the replica in logs and the lock.
+	Identity string
+
+	// RetryPeriod is how often the lock is acquired or renewed, and must be
+	// well below its expiry. It defaults to DefaultRetryPeriod.
+	RetryPeriod time.Duration
+
+	// OnChange is called when the replica becomes or stops being leader.
+	OnChange func(leader bool)
+
+	// OnError is called when acquiring or releasing the lock fails.
+	OnError func(err error)
+}
+
+// Elector tracks whether the replica is leader.
+type Elector struct {
+	cfg    Config
+	leader atomic.Bool
+	paused atomic.Bool
+	wake   chan struct{}
+}
+
+// New returns an elector for cfg.
+func New(cfg Config) (*Elector, error) {
+	if cfg.Lock == nil {
+		return nil, errors.New("leader election lock is required")
+	}
+	if cfg.Identity == "" {
+		return nil, errors.New("leader election identity is required")
+	}
+	if cfg.RetryPeriod <= 0 {
+		cfg.RetryPeriod = DefaultRetryPeriod
+	}
+	return &Elector{cfg: cfg, wake: make(chan struct{}, 1)}, nil
+}
+
+// Run acquires and renews the lock until ctx is done, then releases it.
+// The replica stops being leader as soon as renewing the lock fails, and
+// releases the lock while it is paused.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.RetryPeriod)
+	defer ticker.Stop()
+
+	released := true
+	for {
+		if e.paused.Load() {
+			e.set(false)
+			if !released {
+				if err := e.cfg.Lock.Release(ctx); err != nil && ctx.Err() == nil {
+					e.error(err)
+				}
+				released = true
+			}
+		} else {
+			held, err := e.cfg.Lock.TryAcquire(ctx)
+			if err != nil && ctx.Err() == nil {
+				e.error(err)
+			}
+			e.set(held && err == nil)
+			released = false
+		}
+
+		select {
+		case <-ctx.Done():
+			e.set(false)
+			// Release with a fresh context, as ctx is already done.
+			releaseCtx, cancel := context.WithTimeout(context.Background(), e.cfg.RetryPeriod)
+			defer cancel()
+			if err := e.cfg.Lock.Release(releaseCtx); err != nil {
+				e.error(err)
+			}
+			return
+		case <-ticker.C:
+		case <-e.wake:
+		}
+	}
+}
+
+// Pause makes the replica stop being leader and release the lock, such as
+// before maintenance, until Resume is called.
+func (e *Elector) Pause() {
+	e.paused.Store(true)
+	e.notify()
+}
+
+// Resume makes a paused replica compete for the lock again.
+func (e *Elector) Resume() {
+	e.paused.Store(false)
+	e.notify()
+}
+
+// Paused reports whether the replica is paused.
+func (e *Elector) Paused() bool {
+	return e.paused.Load()
+}
+
+// notify wakes Run up to apply a pause or resume without waiting for the
+// next retry.
+func (e *Elector) notify() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// IsLeader reports whether the replica is leader.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Identity returns the identity of the replica.
+func (e *Elector) Identity() string {
+	return e.cfg.Identity
+}
+
+func (e *Elector) set(leader bool) {
+	if e.leader.Swap(leader) != leader && e.cfg.OnChange != nil {
+		e.cfg.OnChange(leader)
+	}
+}
+
+func (e *Elector) error(err error) {
+	if e.cfg.OnError != nil {
+		e.cfg.OnError(err)
+	}
+}
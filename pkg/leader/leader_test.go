@@ -0,0 +1,267 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/kube"
+)
+
+// newLeaseServer returns a client for an API server storing Leases in
+// memory, rejecting updates with a stale resourceVersion.
+func newLeaseServer(t *testing.T) *kube.Client {
+	t.Helper()
+
+	const prefix = "/apis/coordination.k8s.io/v1/namespaces/metal3/leases"
+	var (
+		mu      sync.Mutex
+		leases  = make(map[string]map[string]any)
+		version int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var obj map[string]any
+		if r.Method != http.MethodGet {
+			if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		name := r.URL.Path[len(prefix):]
+		switch r.Method {
+		case http.MethodGet:
+			stored, ok := leases[name[1:]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			obj = stored
+		case http.MethodPost:
+			metadata, _ := obj["metadata"].(map[string]any)
+			name, _ := metadata["name"].(string)
+			if _, ok := leases[name]; ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			version++
+			metadata["resourceVersion"] = strconv.Itoa(version)
+			leases[name] = obj
+		case http.MethodPut:
+			metadata, _ := obj["metadata"].(map[string]any)
+			stored, ok := leases[name[1:]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			storedMetadata, _ := stored["metadata"].(map[string]any)
+			if metadata["resourceVersion"] != storedMetadata["resourceVersion"] {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			version++
+			metadata["resourceVersion"] = strconv.Itoa(version)
+			leases[name[1:]] = obj
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(obj)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := kube.New(kube.Config{Host: srv.URL, Namespace: "metal3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLeaseLock(t *testing.T) {
+	ctx := context.Background()
+	client := newLeaseServer(t)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	a := NewLeaseLock(client, "ironic-metadata", "a", 15*time.Second)
+	b := NewLeaseLock(client, "ironic-metadata", "b", 15*time.Second)
+	a.now = func() time.Time { return now }
+	b.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		lock    *LeaseLock
+		advance time.Duration
+		want    bool
+	}{
+		{name: "a creates", lock: a, want: true},
+		{name: "b waits", lock: b, advance: 10 * time.Second, want: false},
+		{name: "a renews", lock: a, want: true},
+		{name: "b waits for renewal", lock: b, advance: 10 * time.Second, want: false},
+		{name: "b takes over expired", lock: b, advance: 6 * time.Second, want: true},
+		{name: "a lost", lock: a, want: false},
+	}
+	for _, tt := range steps {
+		now = now.Add(tt.advance)
+		have, err := tt.lock.TryAcquire(ctx)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if have != tt.want {
+			t.Fatalf("%s: have %v, want %v", tt.name, have, tt.want)
+		}
+	}
+
+	lease, err := client.Lease(ctx, "ironic-metadata")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lease.HolderIdentity != "b" || lease.LeaseTransitions != 1 {
+		t.Errorf("have holder %q and %d transitions, want b and 1",
+			lease.HolderIdentity, lease.LeaseTransitions)
+	}
+	if !lease.RenewTime.Equal(now) {
+		t.Errorf("have renew time %s, want %s", lease.RenewTime, now)
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, _ := a.TryAcquire(ctx); have {
+		t.Error("expected release by a non-holder to keep the lease")
+	}
+	if err := b.Release(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, _ := a.TryAcquire(ctx); !have {
+		t.Error("expected a released lease to be acquired immediately")
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	a, b := NewFileLock(path), NewFileLock(path)
+
+	if have, err := a.TryAcquire(ctx); err != nil || !have {
+		t.Fatalf("have %v (%v), want true", have, err)
+	}
+	if have, err := a.TryAcquire(ctx); err != nil || !have {
+		t.Fatalf("renew: have %v (%v), want true", have, err)
+	}
+	if have, err := b.TryAcquire(ctx); err != nil || have {
+		t.Fatalf("have %v (%v), want false", have, err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, err := b.TryAcquire(ctx); err != nil || !have {
+		t.Fatalf("have %v (%v), want true after release", have, err)
+	}
+	_ = b.Release(ctx)
+}
+
+// fakeLock fails or succeeds as told.
+type fakeLock struct {
+	mu       sync.Mutex
+	held     bool
+	err      error
+	released bool
+}
+
+func (l *fakeLock) TryAcquire(_ context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held, l.err
+}
+
+func (l *fakeLock) Release(_ context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = true
+	return nil
+}
+
+func TestElector_Run(t *testing.T) {
+	lock := &fakeLock{held: true}
+	changes := make(chan bool, 10)
+	errs := make(chan error, 10)
+	e, err := New(Config{
+		Lock:        lock,
+		Identity:    "a",
+		RetryPeriod: 10 * time.Millisecond,
+		OnChange:    func(leader bool) { changes <- leader },
+		OnError:     func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	if have := <-changes; !have || !e.IsLeader() {
+		t.Fatal("expected to become leader")
+	}
+
+	lock.mu.Lock()
+	lock.err = errors.New("api unavailable")
+	lock.mu.Unlock()
+	if have := <-changes; have || e.IsLeader() {
+		t.Fatal("expected to stop being leader when renewal fails")
+	}
+	if err := <-errs; err == nil {
+		t.Fatal("expected renewal error to be reported")
+	}
+
+	lock.mu.Lock()
+	lock.err = nil
+	lock.mu.Unlock()
+	if have := <-changes; !have {
+		t.Fatal("expected to become leader again")
+	}
+
+	e.Pause()
+	if have := <-changes; have || !e.Paused() {
+		t.Fatal("expected to stop being leader when paused")
+	}
+	lock.mu.Lock()
+	released := lock.released
+	lock.released = false
+	lock.mu.Unlock()
+	if !released {
+		t.Error("expected lock to be released when paused")
+	}
+	e.Resume()
+	if have := <-changes; !have {
+		t.Fatal("expected to become leader when resumed")
+	}
+
+	cancel()
+	<-done
+	if e.IsLeader() {
+		t.Error("expected to stop being leader when stopped")
+	}
+	if !lock.released {
+		t.Error("expected lock to be released")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{Identity: "a"}); err == nil {
+		t.Error("expected error without lock")
+	}
+	if _, err := New(Config{Lock: &fakeLock{}}); err == nil {
+		t.Error("expected error without identity")
+	}
+}
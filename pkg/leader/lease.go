@@ -0,0 +1,105 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/kube"
+)
+
+// DefaultLeaseDuration is how long a Lease is held without being renewed.
+const DefaultLeaseDuration = 15 * time.Second
+
+// LeaseLock is a lock backed by a Kubernetes Lease, which other replicas
+// take over once its holder stops renewing it.
+type LeaseLock struct {
+	client   *kube.Client
+	name     string
+	identity string
+	duration time.Duration
+	now      func() time.Time
+}
+
+// NewLeaseLock returns a lock on the Lease name, held by identity for
+// duration after each renewal. The duration defaults to
+// DefaultLeaseDuration.
+func NewLeaseLock(client *kube.Client, name, identity string, duration time.Duration) *LeaseLock {
+	if duration <= 0 {
+		duration = DefaultLeaseDuration
+	}
+	return &LeaseLock{
+		client:   client,
+		name:     name,
+		identity: identity,
+		duration: duration.Truncate(time.Second),
+		now:      time.Now,
+	}
+}
+
+// TryAcquire creates the Lease, renews it, or takes it over once expired.
+// Losing a race with another replica is not an error.
+func (l *LeaseLock) TryAcquire(ctx context.Context) (bool, error) {
+	now := l.now()
+	lease, err := l.client.Lease(ctx, l.name)
+	if errors.Is(err, kube.ErrNotFound) {
+		_, err = l.client.CreateLease(ctx, &kube.Lease{
+			Name:                 l.name,
+			HolderIdentity:       l.identity,
+			LeaseDurationSeconds: int(l.duration / time.Second),
+			AcquireTime:          now,
+			RenewTime:            now,
+		})
+		return l.result(err)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get lease %s: %w", l.name, err)
+	}
+
+	if lease.HolderIdentity != l.identity {
+		expiry := lease.RenewTime.Add(time.Duration(lease.LeaseDurationSeconds) * time.Second)
+		if lease.HolderIdentity != "" && now.Before(expiry) {
+			return false, nil
+		}
+		lease.HolderIdentity = l.identity
+		lease.AcquireTime = now
+		lease.LeaseTransitions++
+	}
+	lease.LeaseDurationSeconds = int(l.duration / time.Second)
+	lease.RenewTime = now
+	_, err = l.client.UpdateLease(ctx, lease)
+	return l.result(err)
+}
+
+// Release clears the holder of the Lease if it is held, so another replica
+// takes over without waiting for it to expire.
+func (l *LeaseLock) Release(ctx context.Context) error {
+	lease, err := l.client.Lease(ctx, l.name)
+	if errors.Is(err, kube.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lease %s: %w", l.name, err)
+	}
+	if lease.HolderIdentity != l.identity {
+		return nil
+	}
+	lease.HolderIdentity = ""
+	if _, err := l.client.UpdateLease(ctx, lease); err != nil &&
+		!errors.Is(err, kube.ErrConflict) {
+		return fmt.Errorf("failed to release lease %s: %w", l.name, err)
+	}
+	return nil
+}
+
+func (l *LeaseLock) result(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, kube.ErrConflict):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to acquire lease %s: %w", l.name, err)
+	}
+}
@@ -0,0 +1,81 @@
+package leases
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// Fields and options of DHCPv4 messages, from RFC 2131 and RFC 2132.
+const (
+	dhcpOptionsOffset = 240
+	dhcpMagicCookie   = 0x63825363
+	dhcpBootReply     = 2
+	dhcpACK           = 5
+
+	optionPad          = 0
+	optionHostname     = 12
+	optionLeaseTime    = 51
+	optionMessageType  = 53
+	optionRelayAgent   = 82
+	optionEnd          = 255
+	infiniteLeaseTime  = 0xffffffff
+	ethernetHWType     = 1
+	ethernetAddressLen = 6
+)
+
+// ParseACK returns the lease a DHCPv4 message, the payload of a UDP datagram,
+// grants when it is a DHCPACK assigning an address to an Ethernet client. The
+// lease starts at now. ACKs answering DHCPINFORM assign no address and are
+// not leases.
+func ParseACK(msg []byte, now time.Time) (Lease, bool) {
+	if len(msg) < dhcpOptionsOffset ||
+		msg[0] != dhcpBootReply ||
+		msg[1] != ethernetHWType ||
+		msg[2] != ethernetAddressLen ||
+		binary.BigEndian.Uint32(msg[236:240]) != dhcpMagicCookie {
+		return Lease{}, false
+	}
+	ip := netip.AddrFrom4([4]byte(msg[16:20]))
+	if ip.IsUnspecified() {
+		return Lease{}, false
+	}
+
+	lease := Lease{
+		IP:     ip,
+		MAC:    net.HardwareAddr(msg[28 : 28+ethernetAddressLen]).String(),
+		Starts: now,
+		Active: true,
+	}
+	var isACK bool
+	for options := msg[dhcpOptionsOffset:]; len(options) > 0; {
+		code := options[0]
+		if code == optionPad {
+			options = options[1:]
+			continue
+		}
+		if code == optionEnd || len(options) < 2 || len(options) < 2+int(options[1]) {
+			break
+		}
+		value := options[2 : 2+int(options[1])]
+		options = options[2+int(options[1]):]
+
+		switch code {
+		case optionMessageType:
+			isACK = len(value) == 1 && value[0] == dhcpACK
+		case optionLeaseTime:
+			if len(value) == 4 {
+				if seconds := binary.BigEndian.Uint32(value); seconds != infiniteLeaseTime {
+					lease.Expires = now.Add(time.Duration(seconds) * time.Second)
+				}
+			}
+		case optionHostname:
+			lease.Hostname = string(value)
+		case optionRelayAgent:
+			// Servers echo the relay agent information of relayed requests.
+			parseAgentSubOptions(&lease, value)
+		}
+	}
+	return lease, isACK
+}
@@ -0,0 +1,55 @@
+package leases
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDnsmasq parses a dnsmasq lease file, whose IPv4 lines read
+//
+//	<expiry> <mac> <ip> <hostname> <client id>
+//
+// with an expiry of 0 for infinite leases. IPv6 leases follow a "duid" line
+// and identify clients by DUID rather than MAC address, so they are skipped.
+func parseDnsmasq(t *Table, lines []string) {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "duid" {
+			return
+		}
+		if len(fields) < 3 {
+			t.Malformed++
+			continue
+		}
+
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || expiry < 0 {
+			t.Malformed++
+			continue
+		}
+		mac, ok := parseMAC(fields[1])
+		if !ok {
+			t.Malformed++
+			continue
+		}
+		ip, err := netip.ParseAddr(fields[2])
+		if err != nil {
+			t.Malformed++
+			continue
+		}
+
+		lease := Lease{IP: ip, MAC: mac, Active: true}
+		if expiry > 0 {
+			lease.Expires = time.Unix(expiry, 0)
+		}
+		if len(fields) > 3 && fields[3] != "*" {
+			lease.Hostname = fields[3]
+		}
+		t.add(lease)
+	}
+}
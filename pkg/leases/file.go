@@ -0,0 +1,148 @@
+package leases
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// File is a lease database on disk. It is parsed again when its size or
+// modification time changes, and is safe for concurrent use.
+type File struct {
+	path string
+
+	mu      sync.Mutex
+	table   *Table
+	size    int64
+	modTime time.Time
+
+	// parseErrors counts malformed entries by format across parses.
+	parseErrors map[string]*atomic.Uint64
+	duplicates  atomic.Uint64
+}
+
+// Stats describes a lease database.
+type Stats struct {
+	// Format is the detected format, empty before the file was read.
+	Format string
+
+	// Leases is the number of IP addresses with a lease.
+	Leases int
+
+	// Malformed and Duplicates count the skipped and superseded entries of
+	// the file as last parsed.
+	Malformed  int
+	Duplicates int
+}
+
+// NewFile returns the lease database at path.
+func NewFile(path string) *File {
+	f := &File{
+		path:        path,
+		parseErrors: make(map[string]*atomic.Uint64, len(Formats)),
+	}
+	for _, format := range Formats {
+		f.parseErrors[format] = new(atomic.Uint64)
+	}
+	return f
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Lookup returns the active lease of an IP address.
+func (f *File) Lookup(ip string) (Lease, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("invalid IP address %q: %w", ip, err)
+	}
+
+	t, err := f.load()
+	if err != nil {
+		return Lease{}, false, err
+	}
+	lease, ok := t.Lookup(addr)
+	return lease, ok, nil
+}
+
+// Leases returns the active leases, ordered by IP address.
+func (f *File) Leases() ([]Lease, error) {
+	t, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return t.Active(), nil
+}
+
+// Load parses the file unless it is unchanged since last parsed.
+func (f *File) Load() error {
+	_, err := f.load()
+	return err
+}
+
+// Stats returns the statistics of the file as last parsed.
+func (f *File) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.table == nil {
+		return Stats{}
+	}
+	return Stats{
+		Format:     f.table.Format,
+		Leases:     f.table.Len(),
+		Malformed:  f.table.Malformed,
+		Duplicates: f.table.Duplicates,
+	}
+}
+
+// ParseErrors returns the malformed entries found in a format across every
+// parse of the file.
+func (f *File) ParseErrors(format string) uint64 {
+	if n, ok := f.parseErrors[format]; ok {
+		return n.Load()
+	}
+	return 0
+}
+
+// Duplicates returns the entries superseded by newer leases across every
+// parse of the file.
+func (f *File) Duplicates() uint64 {
+	return f.duplicates.Load()
+}
+
+// load returns the parsed file, parsing it again when it changed.
+func (f *File) load() (*Table, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat lease file %s: %w", f.path, err)
+	}
+	if f.table != nil && info.Size() == f.size && info.ModTime().Equal(f.modTime) {
+		return f.table, nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lease file %s: %w", f.path, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	t, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lease file %s: %w", f.path, err)
+	}
+
+	f.table, f.size, f.modTime = t, info.Size(), info.ModTime()
+	f.parseErrors[t.Format].Add(uint64(t.Malformed))
+	f.duplicates.Add(uint64(t.Duplicates))
+	return t, nil
+}
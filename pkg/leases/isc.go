@@ -0,0 +1,200 @@
+package leases
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iscTimeLayout is the layout of ISC dhcpd times after the weekday, in UTC.
+const iscTimeLayout = "2006/01/02 15:04:05"
+
+// parseISC parses an ISC dhcpd lease file, made of declarations such as
+//
+//	lease 10.0.0.5 {
+//	  starts 4 2025/06/01 12:00:00;
+//	  ends 4 2025/06/01 13:00:00;
+//	  binding state active;
+//	  hardware ethernet 52:54:00:00:00:02;
+//	  client-hostname "web02";
+//	  option agent.circuit-id "Ethernet1/5";
+//	}
+//
+// dhcpd appends a declaration whenever a lease changes, so later ones
+// supersede earlier ones. Declarations other than leases are skipped.
+func parseISC(t *Table, lines []string) {
+	var (
+		lease  *Lease
+		broken bool
+		depth  int
+	)
+	finish := func() {
+		switch {
+		case lease == nil:
+		case broken || !lease.IP.IsValid() || lease.MAC == "":
+			t.Malformed++
+		default:
+			t.add(*lease)
+		}
+		lease, broken = nil, false
+	}
+
+	for _, line := range lines {
+		if i := strings.IndexByte(line, '#'); i >= 0 && !strings.Contains(line[:i], `"`) {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if iscLease.MatchString(line) {
+			// A lease declared inside another block, or before the last
+			// one was closed, means the file was truncated or corrupted.
+			if lease != nil || depth > 0 {
+				broken = true
+				finish()
+				depth = 0
+			}
+			fields := strings.Fields(line)
+			ip, err := netip.ParseAddr(strings.TrimSuffix(fields[1], "{"))
+			lease = &Lease{IP: ip, Active: true}
+			broken = err != nil
+			depth = 1
+			continue
+		}
+
+		if lease == nil {
+			// Skip other declarations, such as server-duid or failover
+			// peer state, tracking their braces.
+			depth += strings.Count(line, "{") - strings.Count(line, "}")
+			if depth < 0 {
+				t.Malformed++
+				depth = 0
+			}
+			continue
+		}
+
+		if line == "}" {
+			finish()
+			depth = 0
+			continue
+		}
+		if !strings.HasSuffix(line, ";") {
+			broken = true
+			continue
+		}
+		if !parseISCStatement(lease, strings.TrimSuffix(line, ";")) {
+			broken = true
+		}
+	}
+	if lease != nil {
+		// The last declaration was not closed.
+		broken = true
+		finish()
+	}
+}
+
+// parseISCStatement applies a statement of a lease declaration to lease. It
+// returns false when a statement the parser uses is malformed; statements it
+// does not use are ignored.
+func parseISCStatement(lease *Lease, statement string) bool {
+	fields := strings.Fields(statement)
+	if len(fields) == 0 {
+		return true
+	}
+
+	switch fields[0] {
+	case "starts", "ends":
+		at, ok := parseISCTime(fields[1:])
+		if !ok {
+			return false
+		}
+		if fields[0] == "starts" {
+			lease.Starts = at
+		} else {
+			lease.Expires = at
+		}
+	case "binding":
+		if len(fields) != 3 || fields[1] != "state" {
+			return false
+		}
+		lease.Active = fields[2] == "active"
+	case "hardware":
+		if len(fields) != 3 {
+			return false
+		}
+		mac, ok := parseMAC(fields[2])
+		if !ok {
+			return false
+		}
+		lease.MAC = mac
+	case "client-hostname":
+		quoted := strings.TrimSpace(strings.TrimPrefix(statement, "client-hostname"))
+		// A hostname that cannot be decoded does not invalidate the lease.
+		if hostname, err := strconv.Unquote(quoted); err == nil {
+			lease.Hostname = hostname
+		}
+	case "option":
+		// Relay agent options are recorded when dhcpd is configured with
+		// stash-agent-options. Undecodable ones are ignored like hostnames.
+		if len(fields) < 3 {
+			return true
+		}
+		value, ok := parseISCData(strings.Join(fields[2:], " "))
+		if !ok {
+			return true
+		}
+		switch fields[1] {
+		case "agent.circuit-id":
+			lease.CircuitID = formatAgentOption(value)
+		case "agent.remote-id":
+			lease.RemoteID = formatAgentOption(value)
+		}
+	}
+	return true
+}
+
+// parseISCData decodes an ISC dhcpd option value, either a quoted string
+// with octal escapes or colon-separated hex bytes such as 0:1b:21:3c.
+func parseISCData(s string) ([]byte, bool) {
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, false
+		}
+		return []byte(unquoted), true
+	}
+	var b []byte
+	for _, part := range strings.Split(s, ":") {
+		c, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return nil, false
+		}
+		b = append(b, byte(c))
+	}
+	return b, true
+}
+
+// parseISCTime parses the fields of an ISC dhcpd time: "never", "epoch
+// <seconds>" or "<weekday> <date> <time>" in UTC.
+func parseISCTime(fields []string) (time.Time, bool) {
+	switch {
+	case len(fields) == 1 && fields[0] == "never":
+		return time.Time{}, true
+	case len(fields) == 2 && fields[0] == "epoch":
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(seconds, 0), true
+	case len(fields) == 3:
+		at, err := time.Parse(iscTimeLayout, fields[1]+" "+fields[2])
+		if err != nil {
+			return time.Time{}, false
+		}
+		return at, true
+	}
+	return time.Time{}, false
+}
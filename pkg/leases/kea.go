@@ -0,0 +1,127 @@
+package leases
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseKea parses a Kea memfile lease file: CSV with a header naming the
+// columns, of which address, hwaddr, expire, hostname, state and
+// user_context are used. Leases in a state other than 0, default, are
+// declined or reclaimed.
+func parseKea(t *Table, lines []string) {
+	var header []string
+	columns := make(map[string]int)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := csv.NewReader(strings.NewReader(line))
+		r.LazyQuotes = true
+		r.FieldsPerRecord = -1
+		record, err := r.Read()
+		if err != nil {
+			t.Malformed++
+			continue
+		}
+
+		// The header comes first and is repeated when files are joined.
+		if len(record) > 0 && record[0] == "address" {
+			header = record
+			clear(columns)
+			for i, name := range header {
+				columns[name] = i
+			}
+			continue
+		}
+		if header == nil || len(record) != len(header) {
+			t.Malformed++
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		ip, err := netip.ParseAddr(field("address"))
+		if err != nil {
+			t.Malformed++
+			continue
+		}
+		mac, ok := parseMAC(field("hwaddr"))
+		if !ok {
+			// DHCPv6 leases and clients identified only by client ID have
+			// no hardware address.
+			if field("hwaddr") != "" {
+				t.Malformed++
+			}
+			continue
+		}
+
+		lease := Lease{
+			IP:       ip,
+			MAC:      mac,
+			Hostname: field("hostname"),
+			Active:   field("state") == "" || field("state") == "0",
+		}
+		if v := field("expire"); v != "" {
+			expire, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				t.Malformed++
+				continue
+			}
+			lease.Expires = time.Unix(expire, 0)
+		}
+		// Relay agent information that cannot be decoded does not
+		// invalidate the lease.
+		parseKeaUserContext(&lease, field("user_context"))
+		t.add(lease)
+	}
+}
+
+// parseKeaUserContext sets the circuit and remote IDs of lease from the user
+// context of a Kea lease, where Kea stores the relay agent information of
+// relayed requests when store-extended-info is enabled:
+//
+//	{"ISC": {"relay-agent-info": {"sub-options": "0x0105...", "remote-id": "..."}}}
+//
+// Kea releases before 2.1 store the sub-options as the value of
+// relay-agent-info itself. Commas are escaped in memfile columns.
+func parseKeaUserContext(lease *Lease, userContext string) {
+	if userContext == "" {
+		return
+	}
+	var parsed struct {
+		ISC struct {
+			RelayAgentInfo json.RawMessage `json:"relay-agent-info"`
+		} `json:"ISC"`
+	}
+	err := json.Unmarshal([]byte(strings.ReplaceAll(userContext, "&#x2c", ",")), &parsed)
+	if err != nil || parsed.ISC.RelayAgentInfo == nil {
+		return
+	}
+
+	var subOptions string
+	if err := json.Unmarshal(parsed.ISC.RelayAgentInfo, &subOptions); err != nil {
+		var info struct {
+			SubOptions string `json:"sub-options"`
+		}
+		if json.Unmarshal(parsed.ISC.RelayAgentInfo, &info) != nil {
+			return
+		}
+		subOptions = info.SubOptions
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(subOptions), "0x"))
+	if err != nil {
+		return
+	}
+	parseAgentSubOptions(lease, b)
+}
@@ -0,0 +1,103 @@
+package leases
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often Learned drops expired leases.
+const pruneInterval = time.Minute
+
+// Learned is a lease table filled with the leases DHCP servers are seen
+// granting, such as from ACKs captured on the network, instead of read from
+// their lease database. It is safe for concurrent use.
+type Learned struct {
+	mu     sync.Mutex
+	leases map[netip.Addr]Lease
+	added  uint64
+	pruned time.Time
+}
+
+// NewLearned returns an empty table.
+func NewLearned() *Learned {
+	return &Learned{leases: make(map[netip.Addr]Lease)}
+}
+
+// Add records a lease, replacing the lease of the same IP address, as a
+// server grants an address to one client at a time.
+func (l *Learned) Add(lease Lease) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lease.IP = lease.IP.Unmap()
+	l.leases[lease.IP] = lease
+	l.added++
+
+	now := time.Now()
+	if now.Sub(l.pruned) < pruneInterval {
+		return
+	}
+	l.pruned = now
+	for ip, lease := range l.leases {
+		if expired(lease, now) {
+			delete(l.leases, ip)
+		}
+	}
+}
+
+// Lookup returns the unexpired lease of an IP address.
+func (l *Learned) Lookup(ip string) (Lease, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("invalid IP address %q: %w", ip, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lease, ok := l.leases[addr.Unmap()]
+	if !ok || !lease.Active || expired(lease, time.Now()) {
+		return Lease{}, false, nil
+	}
+	return lease, true, nil
+}
+
+// Leases returns the active, unexpired leases, ordered by IP address.
+func (l *Learned) Leases() []Lease {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	active := make([]Lease, 0, len(l.leases))
+	for _, lease := range l.leases {
+		if lease.Active && !expired(lease, now) {
+			active = append(active, lease)
+		}
+	}
+	sortByIP(active)
+	return active
+}
+
+// Len returns the number of IP addresses with a lease, including expired
+// leases not yet dropped.
+func (l *Learned) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.leases)
+}
+
+// Added returns the number of leases added so far.
+func (l *Learned) Added() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.added
+}
+
+// expired reports whether lease expired at now.
+func expired(lease Lease, now time.Time) bool {
+	return !lease.Expires.IsZero() && !now.Before(lease.Expires)
+}
@@ -0,0 +1,188 @@
+// Package leases reads the lease databases of DHCP servers to find the MAC
+// address leased an IP address. It recognizes dnsmasq lease files, ISC dhcpd
+// lease files and Kea memfile CSV files, and keeps the leases learned from
+// DHCP ACKs seen on the network.
+package leases
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Lease database formats.
+const (
+	FormatDnsmasq = "dnsmasq"
+	FormatISC     = "isc"
+	FormatKea     = "kea"
+)
+
+// Formats lists the recognized lease database formats.
+var Formats = []string{FormatDnsmasq, FormatISC, FormatKea}
+
+// iscLease matches the first line of an ISC dhcpd lease declaration.
+var iscLease = regexp.MustCompile(`^lease\s+\S+\s*\{`)
+
+// Lease is an address lease.
+type Lease struct {
+	IP  netip.Addr
+	MAC string
+
+	// Hostname is the hostname the client sent, if any.
+	Hostname string
+
+	// Starts is when the lease was granted, when the format records it.
+	Starts time.Time
+
+	// Expires is when the lease expires, zero when it does not.
+	Expires time.Time
+
+	// Active is false for leases the server has freed, released or
+	// reclaimed.
+	Active bool
+
+	// CircuitID and RemoteID are the relay agent information (DHCP option
+	// 82) of the request, when it was relayed and the server records it.
+	// Printable values are kept as text, others are formatted as
+	// colon-separated hex bytes.
+	CircuitID string
+	RemoteID  string
+}
+
+// newerThan reports whether l was granted after other, comparing start
+// times when both are known and expiry times otherwise.
+func (l Lease) newerThan(other Lease) bool {
+	if !l.Starts.IsZero() && !other.Starts.IsZero() {
+		return !l.Starts.Before(other.Starts)
+	}
+	switch {
+	case l.Expires.IsZero():
+		return true
+	case other.Expires.IsZero():
+		return false
+	}
+	return !l.Expires.Before(other.Expires)
+}
+
+// Table holds the leases of a lease database, by IP address.
+type Table struct {
+	// Format is the detected format of the database.
+	Format string
+
+	// Malformed counts the entries that could not be parsed and were
+	// skipped.
+	Malformed int
+
+	// Duplicates counts the entries superseded by a newer lease of the
+	// same IP address.
+	Duplicates int
+
+	leases map[netip.Addr]Lease
+}
+
+// Lookup returns the active lease of an IP address.
+func (t *Table) Lookup(ip netip.Addr) (Lease, bool) {
+	lease, ok := t.leases[ip.Unmap()]
+	if !ok || !lease.Active {
+		return Lease{}, false
+	}
+	return lease, true
+}
+
+// Active returns the active leases, ordered by IP address.
+func (t *Table) Active() []Lease {
+	active := make([]Lease, 0, len(t.leases))
+	for _, lease := range t.leases {
+		if lease.Active {
+			active = append(active, lease)
+		}
+	}
+	sortByIP(active)
+	return active
+}
+
+// sortByIP orders leases by IP address.
+func sortByIP(leases []Lease) {
+	slices.SortFunc(leases, func(a, b Lease) int {
+		return a.IP.Compare(b.IP)
+	})
+}
+
+// Len returns the number of IP addresses with a lease, active or not.
+func (t *Table) Len() int {
+	return len(t.leases)
+}
+
+// add records a lease, keeping the newest lease of each IP address. Later
+// entries win ties, as servers append renewed leases.
+func (t *Table) add(lease Lease) {
+	lease.IP = lease.IP.Unmap()
+	if current, ok := t.leases[lease.IP]; ok {
+		t.Duplicates++
+		if !lease.newerThan(current) {
+			return
+		}
+	}
+	t.leases[lease.IP] = lease
+}
+
+// Parse reads a lease database, detecting its format. Entries that cannot be
+// parsed are counted in Malformed and skipped, so only failing to read r is
+// an error.
+func Parse(r io.Reader) (*Table, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leases: %w", err)
+	}
+
+	lines := strings.Split(string(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))), "\n")
+	t := &Table{
+		Format: Detect(lines),
+		leases: make(map[netip.Addr]Lease),
+	}
+	switch t.Format {
+	case FormatISC:
+		parseISC(t, lines)
+	case FormatKea:
+		parseKea(t, lines)
+	default:
+		parseDnsmasq(t, lines)
+	}
+	return t, nil
+}
+
+// Detect returns the format of a lease database from its lines: Kea when it
+// starts with a CSV header, ISC when any line declares a lease block, and
+// dnsmasq otherwise.
+func Detect(lines []string) string {
+	first := true
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if first && strings.HasPrefix(line, "address,") {
+			return FormatKea
+		}
+		first = false
+		if iscLease.MatchString(line) {
+			return FormatISC
+		}
+	}
+	return FormatDnsmasq
+}
+
+// parseMAC returns a MAC address in lower-case colon notation.
+func parseMAC(s string) (string, bool) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return "", false
+	}
+	return mac.String(), true
+}
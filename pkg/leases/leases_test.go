@@ -0,0 +1,489 @@
+package leases
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keaHeader is the header of Kea DHCPv4 memfile lease files.
+const keaHeader = "address,hwaddr,client_id,valid_lifetime,expire,subnet_id," +
+	"fqdn_fwd,fqdn_rev,hostname,state,user_context,pool_id"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           string
+		wantFormat     string
+		wantLeases     map[string]string
+		wantMalformed  int
+		wantDuplicates int
+	}{
+		{
+			name: "dnsmasq",
+			data: `1750802648 9c:6b:00:70:59:8b 10.1.105.195 * *
+1750802648 9c:6b:00:70:59:8a 10.1.105.194 web02 01:9c:6b:00:70:59:8a
+`,
+			wantFormat: FormatDnsmasq,
+			wantLeases: map[string]string{
+				"10.1.105.195": "9c:6b:00:70:59:8b",
+				"10.1.105.194": "9c:6b:00:70:59:8a",
+			},
+		},
+		{
+			name: "dnsmasq with garbage",
+			data: `invalid line
+1750802648 9C:6B:00:70:59:8B 10.1.105.195 * *
+1750802648 not-a-mac 10.1.105.194 * *
+soon 9c:6b:00:70:59:8a 10.1.105.194 * *
+1750802648 9c:6b:00:70:59:8a 10.1.105.999 * *
+another invalid line
+`,
+			wantFormat:    FormatDnsmasq,
+			wantLeases:    map[string]string{"10.1.105.195": "9c:6b:00:70:59:8b"},
+			wantMalformed: 5,
+		},
+		{
+			name: "dnsmasq duplicate prefers newest",
+			data: `1750802700 52:54:00:00:00:02 10.0.0.5 * *
+1750802600 52:54:00:00:00:01 10.0.0.5 * *
+0 52:54:00:00:00:03 10.0.0.6 * *
+1750802600 52:54:00:00:00:04 10.0.0.6 * *
+`,
+			wantFormat: FormatDnsmasq,
+			wantLeases: map[string]string{
+				"10.0.0.5": "52:54:00:00:00:02",
+				"10.0.0.6": "52:54:00:00:00:03",
+			},
+			wantDuplicates: 2,
+		},
+		{
+			name: "dnsmasq skips DHCPv6",
+			data: `1750802648 52:54:00:00:00:01 10.0.0.5 * *
+duid 00:01:00:01:2c:5d:0b:7a:52:54:00:00:00:ff
+1750802648 1234567 fd00::5 web01 00:01:00:01:2c:5d:0b:7a:52:54:00:00:00:01
+`,
+			wantFormat: FormatDnsmasq,
+			wantLeases: map[string]string{"10.0.0.5": "52:54:00:00:00:01"},
+		},
+		{
+			name: "isc",
+			data: `# The format of this file is documented in the dhcpd.leases(5) manual page.
+authoring-byte-order little-endian;
+
+server-duid "\000\001\000\001";
+
+failover peer "dhcp" state {
+  my state normal at 4 2025/06/01 11:00:00;
+}
+
+lease 10.0.0.5 {
+  starts 4 2025/06/01 12:00:00;
+  ends 4 2025/06/01 13:00:00;
+  binding state active;
+  hardware ethernet 52:54:00:00:00:01;
+  client-hostname "web 01";
+}
+lease 10.0.0.6 {
+  starts epoch 1748779200; # Sun Jun 01 12:00:00 2025
+  ends never;
+  binding state active;
+  hardware ethernet 52:54:00:00:00:02;
+}
+`,
+			wantFormat: FormatISC,
+			wantLeases: map[string]string{
+				"10.0.0.5": "52:54:00:00:00:01",
+				"10.0.0.6": "52:54:00:00:00:02",
+			},
+		},
+		{
+			name: "isc later declaration supersedes",
+			data: `lease 10.0.0.5 {
+  starts 4 2025/06/01 12:00:00;
+  binding state active;
+  hardware ethernet 52:54:00:00:00:01;
+}
+lease 10.0.0.5 {
+  starts 4 2025/06/01 14:00:00;
+  binding state free;
+  hardware ethernet 52:54:00:00:00:01;
+}
+lease 10.0.0.6 {
+  starts 4 2025/06/01 14:00:00;
+  binding state active;
+  hardware ethernet 52:54:00:00:00:03;
+}
+lease 10.0.0.6 {
+  starts 4 2025/06/01 12:00:00;
+  binding state active;
+  hardware ethernet 52:54:00:00:00:02;
+}
+`,
+			wantFormat:     FormatISC,
+			wantLeases:     map[string]string{"10.0.0.6": "52:54:00:00:00:03"},
+			wantDuplicates: 2,
+		},
+		{
+			name: "isc truncated and corrupted",
+			data: `lease 10.0.0.5 {
+  starts 4 2025/06/01 12:00:00;
+  hardware ethernet 52:54:00:00:00:01;
+lease 10.0.0.6 {
+  starts 4 2025/06/01 12:00:00;
+  hardware ethernet 52:54:00:00:00:02;
+}
+lease 10.0.0.7 {
+  starts yesterday;
+  hardware ethernet 52:54:00:00:00:03;
+}
+lease 10.0.0.8 {
+  hardware ethernet 52:54:00:00:00:04;
+  garbage
+}
+lease 10.0.0.9 {
+  hardware ethernet 52:54:00:00:00:05;
+`,
+			wantFormat:    FormatISC,
+			wantLeases:    map[string]string{"10.0.0.6": "52:54:00:00:00:02"},
+			wantMalformed: 4,
+		},
+		{
+			name: "kea",
+			data: keaHeader + `
+10.0.0.5,52:54:00:00:00:01,01:52:54:00:00:00:01,3600,1748782800,1,0,0,web01,0,,0
+10.0.0.6,52:54:00:00:00:02,,3600,1748782800,1,0,0,web02,2,,0
+10.0.0.7,52:54:00:00:00:03,,3600,1748779200,1,0,0,,0,,0
+10.0.0.7,52:54:00:00:00:04,,3600,1748782800,1,0,0,,0,,0
+10.0.0.8,,01:02:03,3600,1748782800,1,0,0,,0,,0
+garbage
+10.0.0.9,52:54:00:00:00:05,,3600,later,1,0,0,,0,,0
+` + keaHeader + `
+10.0.0.10,52:54:00:00:00:06,,3600,1748782800,1,0,0,"web,10",0,,0
+`,
+			wantFormat: FormatKea,
+			wantLeases: map[string]string{
+				"10.0.0.5":  "52:54:00:00:00:01",
+				"10.0.0.7":  "52:54:00:00:00:04",
+				"10.0.0.10": "52:54:00:00:00:06",
+			},
+			wantMalformed:  2,
+			wantDuplicates: 1,
+		},
+		{
+			name:       "empty",
+			wantFormat: FormatDnsmasq,
+			wantLeases: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := Parse(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if table.Format != tt.wantFormat {
+				t.Errorf("have format %q, want %q", table.Format, tt.wantFormat)
+			}
+			if table.Malformed != tt.wantMalformed {
+				t.Errorf("have %d malformed, want %d", table.Malformed, tt.wantMalformed)
+			}
+			if table.Duplicates != tt.wantDuplicates {
+				t.Errorf("have %d duplicates, want %d", table.Duplicates, tt.wantDuplicates)
+			}
+
+			have := make(map[string]string)
+			for ip := range table.leases {
+				if lease, ok := table.Lookup(ip); ok {
+					have[ip.String()] = lease.MAC
+				}
+			}
+			if len(have) != len(tt.wantLeases) {
+				t.Errorf("have leases %v, want %v", have, tt.wantLeases)
+			}
+			for ip, want := range tt.wantLeases {
+				if have[ip] != want {
+					t.Errorf("%s: have MAC %q, want %q", ip, have[ip], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_fields(t *testing.T) {
+	table, err := Parse(strings.NewReader(`lease 10.0.0.5 {
+  starts 4 2025/06/01 12:00:00;
+  ends 4 2025/06/01 13:00:00;
+  binding state active;
+  hardware ethernet 52:54:00:00:00:01;
+  client-hostname "web 01";
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have, ok := table.Lookup(netip.MustParseAddr("::ffff:10.0.0.5"))
+	if !ok {
+		t.Fatal("expected a lease for the IPv4-mapped address")
+	}
+	want := Lease{
+		IP:       netip.MustParseAddr("10.0.0.5"),
+		MAC:      "52:54:00:00:00:01",
+		Hostname: "web 01",
+		Starts:   time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		Expires:  time.Date(2025, 6, 1, 13, 0, 0, 0, time.UTC),
+		Active:   true,
+	}
+	if have != want {
+		t.Errorf("have %+v, want %+v", have, want)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	write := func(data string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	lookup := func(f *File, ip string) string {
+		t.Helper()
+		lease, ok, err := f.Lookup(ip)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ok {
+			return ""
+		}
+		return lease.MAC
+	}
+
+	f := NewFile(path)
+	if _, _, err := f.Lookup("10.0.0.5"); err == nil {
+		t.Error("expected error for a missing file")
+	}
+	if _, _, err := f.Lookup("not-an-ip"); err == nil {
+		t.Error("expected error for an invalid IP")
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("1750802648 52:54:00:00:00:01 10.0.0.5 * *\ngarbage\n", start)
+	if have := lookup(f, "10.0.0.5"); have != "52:54:00:00:00:01" {
+		t.Errorf("have MAC %q, want 52:54:00:00:00:01", have)
+	}
+	if have := lookup(f, "10.0.0.6"); have != "" {
+		t.Errorf("have MAC %q for an IP without lease", have)
+	}
+	if have := f.ParseErrors(FormatDnsmasq); have != 1 {
+		t.Errorf("have %d parse errors, want 1 as the unchanged file is parsed once", have)
+	}
+
+	write("1750802648 52:54:00:00:00:02 10.0.0.5 * *\ngarbage\n", start.Add(time.Minute))
+	if have := lookup(f, "10.0.0.5"); have != "52:54:00:00:00:02" {
+		t.Errorf("have MAC %q, want the lease of the changed file", have)
+	}
+	if have := f.ParseErrors(FormatDnsmasq); have != 2 {
+		t.Errorf("have %d parse errors, want 2", have)
+	}
+	want := Stats{Format: FormatDnsmasq, Leases: 1, Malformed: 1}
+	if have := f.Stats(); have != want {
+		t.Errorf("have stats %+v, want %+v", have, want)
+	}
+	all, err := f.Leases()
+	if err != nil || len(all) != 1 || all[0].MAC != "52:54:00:00:00:02" {
+		t.Errorf("have leases %+v, error %v, want the lease of 10.0.0.5", all, err)
+	}
+}
+
+func TestParse_relayAgent(t *testing.T) {
+	const subOptions = "0x010B45746865726E6574312F350206001B213C4D5E"
+	tests := []struct {
+		name          string
+		data          string
+		wantCircuitID string
+		wantRemoteID  string
+	}{
+		{
+			name: "isc quoted and hex",
+			data: `lease 10.0.0.5 {
+  starts 4 2025/06/01 12:00:00;
+  hardware ethernet 52:54:00:00:00:01;
+  option agent.circuit-id "Ethernet1/5";
+  option agent.remote-id 0:1b:21:3c:4d:5e;
+}
+`,
+			wantCircuitID: "Ethernet1/5",
+			wantRemoteID:  "00:1b:21:3c:4d:5e",
+		},
+		{
+			name: "isc undecodable",
+			data: `lease 10.0.0.5 {
+  starts 4 2025/06/01 12:00:00;
+  hardware ethernet 52:54:00:00:00:01;
+  option agent.circuit-id zz:1;
+}
+`,
+		},
+		{
+			name: "kea",
+			data: keaHeader + `
+10.0.0.5,52:54:00:00:00:01,,3600,1748782800,1,0,0,,0,{ "ISC": { "relay-agent-info": ` +
+				`{ "sub-options": "` + subOptions + `"&#x2c "remote-id": "001B213C4D5E" } } },0
+`,
+			wantCircuitID: "Ethernet1/5",
+			wantRemoteID:  "00:1b:21:3c:4d:5e",
+		},
+		{
+			name: "kea before 2.1",
+			data: keaHeader + `
+10.0.0.5,52:54:00:00:00:01,,3600,1748782800,1,0,0,,0,` +
+				`{ "ISC": { "relay-agent-info": "` + subOptions + `" } },0
+`,
+			wantCircuitID: "Ethernet1/5",
+			wantRemoteID:  "00:1b:21:3c:4d:5e",
+		},
+		{
+			name: "dnsmasq",
+			data: "1750802648 52:54:00:00:00:01 10.0.0.5 * *\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := Parse(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			lease, ok := table.Lookup(netip.MustParseAddr("10.0.0.5"))
+			if !ok {
+				t.Fatal("expected a lease for 10.0.0.5")
+			}
+			if lease.CircuitID != tt.wantCircuitID || lease.RemoteID != tt.wantRemoteID {
+				t.Errorf("have circuit ID %q, remote ID %q, want %q, %q",
+					lease.CircuitID, lease.RemoteID, tt.wantCircuitID, tt.wantRemoteID)
+			}
+		})
+	}
+}
+
+// dhcpMessage returns a DHCPv4 reply assigning yiaddr to chaddr with options,
+// each given as its code followed by its value.
+func dhcpMessage(yiaddr, chaddr string, options ...[]byte) []byte {
+	msg := make([]byte, dhcpOptionsOffset)
+	msg[0], msg[1], msg[2] = dhcpBootReply, ethernetHWType, ethernetAddressLen
+	ip := netip.MustParseAddr(yiaddr).As4()
+	copy(msg[16:20], ip[:])
+	mac, _ := net.ParseMAC(chaddr)
+	copy(msg[28:], mac)
+	binary.BigEndian.PutUint32(msg[236:240], dhcpMagicCookie)
+	for _, option := range options {
+		msg = append(msg, option[0], byte(len(option)-1))
+		msg = append(msg, option[1:]...)
+	}
+	return append(msg, optionPad, optionEnd)
+}
+
+func TestParseACK(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	ack := []byte{optionMessageType, dhcpACK}
+	relayAgent := append([]byte{optionRelayAgent, agentCircuitID, 3}, "xe1"...)
+
+	tests := []struct {
+		name   string
+		msg    []byte
+		want   Lease
+		wantOK bool
+	}{
+		{
+			name: "ack",
+			msg: dhcpMessage("10.0.0.5", "52:54:00:00:00:01", ack,
+				[]byte{optionLeaseTime, 0, 0, 0x0e, 0x10},
+				append([]byte{optionHostname}, "web01"...), relayAgent),
+			want: Lease{
+				IP:        netip.MustParseAddr("10.0.0.5"),
+				MAC:       "52:54:00:00:00:01",
+				Hostname:  "web01",
+				Starts:    now,
+				Expires:   now.Add(time.Hour),
+				Active:    true,
+				CircuitID: "xe1",
+			},
+			wantOK: true,
+		},
+		{
+			name: "infinite lease",
+			msg: dhcpMessage("10.0.0.5", "52:54:00:00:00:01", ack,
+				[]byte{optionLeaseTime, 0xff, 0xff, 0xff, 0xff}),
+			want: Lease{
+				IP:     netip.MustParseAddr("10.0.0.5"),
+				MAC:    "52:54:00:00:00:01",
+				Starts: now,
+				Active: true,
+			},
+			wantOK: true,
+		},
+		{
+			name: "offer",
+			msg:  dhcpMessage("10.0.0.5", "52:54:00:00:00:01", []byte{optionMessageType, 2}),
+		},
+		{
+			name: "ack to inform",
+			msg:  dhcpMessage("0.0.0.0", "52:54:00:00:00:01", ack),
+		},
+		{
+			name: "truncated",
+			msg:  dhcpMessage("10.0.0.5", "52:54:00:00:00:01", ack)[:100],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, ok := ParseACK(tt.msg, now)
+			if ok != tt.wantOK {
+				t.Fatalf("have ok %v, want %v", ok, tt.wantOK)
+			}
+			if ok && have != tt.want {
+				t.Errorf("have %+v, want %+v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestLearned(t *testing.T) {
+	l := NewLearned()
+	l.Add(Lease{IP: netip.MustParseAddr("10.0.0.5"), MAC: "52:54:00:00:00:01", Active: true})
+	l.Add(Lease{IP: netip.MustParseAddr("10.0.0.5"), MAC: "52:54:00:00:00:02", Active: true})
+	l.Add(Lease{
+		IP:      netip.MustParseAddr("10.0.0.6"),
+		MAC:     "52:54:00:00:00:03",
+		Expires: time.Now().Add(-time.Second),
+		Active:  true,
+	})
+
+	if lease, ok, err := l.Lookup("::ffff:10.0.0.5"); err != nil || !ok ||
+		lease.MAC != "52:54:00:00:00:02" {
+		t.Errorf("have %+v, %v, %v, want the latest lease", lease, ok, err)
+	}
+	if _, ok, err := l.Lookup("10.0.0.6"); err != nil || ok {
+		t.Errorf("have ok %v, error %v, want no expired lease", ok, err)
+	}
+	if _, _, err := l.Lookup("not-an-ip"); err == nil {
+		t.Error("expected error for an invalid IP")
+	}
+	if l.Len() != 2 || l.Added() != 3 {
+		t.Errorf("have %d leases, %d added, want 2 and 3", l.Len(), l.Added())
+	}
+	if all := l.Leases(); len(all) != 1 || all[0].MAC != "52:54:00:00:00:02" {
+		t.Errorf("have leases %+v, want the unexpired lease", all)
+	}
+}
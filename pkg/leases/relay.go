@@ -0,0 +1,51 @@
+package leases
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// Sub-options of the relay agent information option, from RFC 3046.
+const (
+	agentCircuitID = 1
+	agentRemoteID  = 2
+)
+
+// formatAgentOption formats a relay agent sub-option value as text when it
+// is printable and as colon-separated hex bytes otherwise, as switches send
+// interface names in the circuit ID and often their MAC address in the
+// remote ID.
+func formatAgentOption(b []byte) string {
+	printable := len(b) > 0
+	for _, c := range b {
+		if c > unicode.MaxASCII || !unicode.IsPrint(rune(c)) {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return string(b)
+	}
+	hexBytes := make([]string, len(b))
+	for i, c := range b {
+		hexBytes[i] = hex.EncodeToString([]byte{c})
+	}
+	return strings.Join(hexBytes, ":")
+}
+
+// parseAgentSubOptions sets the circuit and remote IDs of lease from the
+// encoded sub-options of a relay agent information option, up to the first
+// truncated one.
+func parseAgentSubOptions(lease *Lease, b []byte) {
+	for len(b) >= 2 && len(b) >= 2+int(b[1]) {
+		value := b[2 : 2+int(b[1])]
+		switch b[0] {
+		case agentCircuitID:
+			lease.CircuitID = formatAgentOption(value)
+		case agentRemoteID:
+			lease.RemoteID = formatAgentOption(value)
+		}
+		b = b[2+int(b[1]):]
+	}
+}
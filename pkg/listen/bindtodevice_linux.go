@@ -0,0 +1,19 @@
+package listen
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToDevice sets SO_BINDTODEVICE on a socket before it is bound.
+func bindToDevice(c syscall.RawConn, iface string) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptString(int(fd), unix.SOL_SOCKET, unix.SO_BINDTODEVICE, iface)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
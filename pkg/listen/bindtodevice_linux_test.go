@@ -0,0 +1,22 @@
+package listen
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+func TestListener_bindToDevice(t *testing.T) {
+	l := Listener{Addr: netip.MustParseAddrPort("127.0.0.1:0"), Interface: "lo"}
+	ln, err := l.Listen(context.Background())
+	if err != nil {
+		t.Skipf("cannot bind to lo: %v", err)
+	}
+	ln.Close()
+
+	l.Interface = "missing0"
+	if ln, err := l.Listen(context.Background()); err == nil {
+		ln.Close()
+		t.Error("expected error for a missing interface")
+	}
+}
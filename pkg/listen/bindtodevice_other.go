@@ -0,0 +1,13 @@
+//go:build !linux
+
+package listen
+
+import (
+	"errors"
+	"syscall"
+)
+
+// bindToDevice fails on platforms without SO_BINDTODEVICE.
+func bindToDevice(_ syscall.RawConn, _ string) error {
+	return errors.New("binding to an interface is only supported on Linux")
+}
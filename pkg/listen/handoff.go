@@ -0,0 +1,157 @@
+package listen
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// inheritEnv lists the addresses of the listeners handed over to a new
+// process, whose file descriptors follow stdin, stdout and stderr in order.
+const inheritEnv = "IRONIC_METADATA_INHERITED_LISTENERS"
+
+// firstInheritedFD is the file descriptor of the first inherited listener,
+// following the standard streams.
+const firstInheritedFD = 3
+
+// Set is a group of open listeners that can be handed over to a new
+// process of the service, so it can be upgraded without closing them.
+type Set struct {
+	listeners []Listener
+	tcp       []*net.TCPListener
+	served    []net.Listener
+	inherited bool
+}
+
+// Open opens listeners, taking over those the process that started this one
+// handed over, and closing inherited listeners that are no longer listed.
+func Open(ctx context.Context, listeners []Listener) (*Set, error) {
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, ln := range inherited {
+			_ = ln.Close()
+		}
+	}()
+
+	s := &Set{listeners: listeners, inherited: len(inherited) > 0}
+	for _, l := range listeners {
+		ln, ok := inherited[l.Addr.String()]
+		if ok {
+			delete(inherited, l.Addr.String())
+		} else if ln, err = l.listenTCP(ctx); err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("failed to listen on %s: %w", l, err)
+		}
+		s.tcp = append(s.tcp, ln)
+
+		served := net.Listener(ln)
+		if l.TLS != nil {
+			tlsConfig, err := l.TLS.load()
+			if err != nil {
+				_ = s.Close()
+				return nil, fmt.Errorf("failed to listen on %s: %w", l, err)
+			}
+			served = tls.NewListener(ln, tlsConfig)
+		}
+		s.served = append(s.served, served)
+	}
+	return s, nil
+}
+
+// inheritedListeners returns the listeners handed over to this process by
+// address, and stops them from being handed down to processes it starts.
+func inheritedListeners() (map[string]*net.TCPListener, error) {
+	spec, ok := os.LookupEnv(inheritEnv)
+	if !ok {
+		return nil, nil
+	}
+	if err := os.Unsetenv(inheritEnv); err != nil {
+		return nil, err
+	}
+
+	inherited := make(map[string]*net.TCPListener)
+	for i, addr := range strings.Split(spec, ",") {
+		name := "listener " + addr
+		f := os.NewFile(uintptr(firstInheritedFD+i), name)
+		if f == nil {
+			return nil, fmt.Errorf("inherited %s is not open", name)
+		}
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid inherited %s: %w", name, err)
+		}
+		tcp, ok := ln.(*net.TCPListener)
+		if !ok {
+			_ = ln.Close()
+			return nil, fmt.Errorf("inherited %s is not a TCP listener", name)
+		}
+		inherited[addr] = tcp
+	}
+	return inherited, nil
+}
+
+// Listeners returns the listeners to serve, in the order they were given.
+func (s *Set) Listeners() []net.Listener {
+	return s.served
+}
+
+// Inherited reports whether the listeners were handed over by the process
+// that started this one.
+func (s *Set) Inherited() bool {
+	return s.inherited
+}
+
+// Handoff starts the current executable again with the same arguments and
+// environment, handing it the listeners. Both processes then accept
+// connections until this one is stopped.
+func (s *Set) Handoff() (*os.Process, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	addrs := make([]string, 0, len(s.tcp))
+	files := make([]*os.File, 0, len(s.tcp))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for i, ln := range s.tcp {
+		f, err := ln.File()
+		if err != nil {
+			return nil, fmt.Errorf("failed to hand over %s: %w", s.listeners[i], err)
+		}
+		files = append(files, f)
+		addrs = append(addrs, s.listeners[i].Addr.String())
+	}
+
+	attr := &os.ProcAttr{
+		Env:   append(os.Environ(), inheritEnv+"="+strings.Join(addrs, ",")),
+		Files: append([]*os.File{os.Stdin, os.Stdout, os.Stderr}, files...),
+	}
+	process, err := os.StartProcess(executable, os.Args, attr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", executable, err)
+	}
+	return process, nil
+}
+
+// Close closes the listeners.
+func (s *Set) Close() error {
+	var errs []error
+	for _, ln := range s.tcp {
+		if err := ln.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
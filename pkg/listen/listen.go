@@ -0,0 +1,248 @@
+// Package listen parses the addresses the metadata service listens on and
+// opens listeners for them, optionally serving TLS.
+package listen
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// TLSConfig locates the PEM files of a TLS listener.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile, when set, requires clients to present a certificate
+	// signed by one of its CAs.
+	ClientCAFile string
+
+	// NextProtos are the application protocols offered to clients during
+	// the handshake, such as h2 to serve HTTP/2.
+	NextProtos []string
+}
+
+// Listener is an address to listen on.
+type Listener struct {
+	Addr netip.AddrPort
+
+	// TLS serves HTTPS on the listener when it is not nil.
+	TLS *TLSConfig
+
+	// ReusePort sets SO_REUSEPORT, so other processes of the service can
+	// listen on the same address, such as during upgrades.
+	ReusePort bool
+
+	// Interface binds the listener to a network interface with
+	// SO_BINDTODEVICE, so it only accepts connections arriving on it.
+	Interface string
+
+	// network is tcp, or tcp4 or tcp6 for wildcard addresses listened on
+	// side by side with the wildcard address of the other family.
+	network string
+}
+
+// Parse parses a comma separated list of listeners, each an address and
+// port such as 169.254.169.254:80 or [::]:80, optionally prefixed with
+// http:// or https://. Listeners are bound to the interface in the
+// interface query parameter, e.g. [::]:80?interface=eth1, or otherwise to
+// iface when it is set. HTTPS listeners take their certificate, key and
+// client CA from the cert, key and client_ca query parameters, e.g.
+// https://10.0.60.5:8443?cert=/etc/tls/tls.crt&key=/etc/tls/tls.key, and
+// otherwise from defaults.
+func Parse(spec string, defaults TLSConfig, iface string) ([]Listener, error) {
+	var listeners []Listener
+	seen := make(map[netip.AddrPort]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		l, err := parseListener(entry, defaults, iface)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listener %q: %w", entry, err)
+		}
+		if seen[l.Addr] {
+			return nil, fmt.Errorf("duplicate listener %s", l)
+		}
+		seen[l.Addr] = true
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 0 {
+		return nil, errors.New("no listeners")
+	}
+
+	// Go listens on both families on a wildcard address, so a wildcard
+	// address listed alongside the other family's is limited to its own.
+	for i, l := range listeners {
+		if !l.Addr.Addr().IsUnspecified() {
+			continue
+		}
+		other := netip.IPv6Unspecified()
+		if l.Addr.Addr().Is6() {
+			other = netip.IPv4Unspecified()
+		}
+		if seen[netip.AddrPortFrom(other, l.Addr.Port())] {
+			listeners[i].network = "tcp4"
+			if l.Addr.Addr().Is6() {
+				listeners[i].network = "tcp6"
+			}
+		}
+	}
+	return listeners, nil
+}
+
+// parseListener parses a single listener.
+func parseListener(entry string, defaults TLSConfig, iface string) (Listener, error) {
+	if !strings.Contains(entry, "://") {
+		entry = "http://" + entry
+	}
+	u, err := url.Parse(entry)
+	if err != nil {
+		return Listener{}, err
+	}
+	if u.Path != "" || u.User != nil || u.Fragment != "" {
+		return Listener{}, errors.New("expected only a scheme, address and options")
+	}
+	addr, err := netip.ParseAddrPort(u.Host)
+	if err != nil {
+		return Listener{}, err
+	}
+	l := Listener{
+		Addr:      netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port()),
+		Interface: iface,
+	}
+
+	cfg := defaults
+	for key := range u.Query() {
+		value := u.Query().Get(key)
+		switch {
+		case key == "interface":
+			l.Interface = value
+		case u.Scheme == "https" && key == "cert":
+			cfg.CertFile = value
+		case u.Scheme == "https" && key == "key":
+			cfg.KeyFile = value
+		case u.Scheme == "https" && key == "client_ca":
+			cfg.ClientCAFile = value
+		default:
+			return Listener{}, fmt.Errorf("unknown option %q for %s listeners", key, u.Scheme)
+		}
+	}
+
+	switch u.Scheme {
+	case "http":
+		return l, nil
+	case "https":
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return Listener{}, errors.New("https listeners require a certificate and key")
+		}
+		l.TLS = &cfg
+		return l, nil
+	}
+	return Listener{}, fmt.Errorf("unsupported scheme %q", u.Scheme)
+}
+
+// Network returns the network of the listener.
+func (l Listener) Network() string {
+	if l.network != "" {
+		return l.network
+	}
+	return "tcp"
+}
+
+// String returns the listener's URL, followed by its interface.
+func (l Listener) String() string {
+	scheme := "http://"
+	if l.TLS != nil {
+		scheme = "https://"
+	}
+	if l.Interface != "" {
+		return scheme + l.Addr.String() + "%" + l.Interface
+	}
+	return scheme + l.Addr.String()
+}
+
+// Listen opens the listener.
+func (l Listener) Listen(ctx context.Context) (net.Listener, error) {
+	var tlsConfig *tls.Config
+	if l.TLS != nil {
+		var err error
+		if tlsConfig, err = l.TLS.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := l.listenTCP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		return tls.NewListener(ln, tlsConfig), nil
+	}
+	return ln, nil
+}
+
+// listenTCP opens the TCP listener underlying the listener.
+func (l Listener) listenTCP(ctx context.Context) (*net.TCPListener, error) {
+	lc := net.ListenConfig{Control: l.control}
+	ln, err := lc.Listen(ctx, l.Network(), l.Addr.String())
+	if err != nil {
+		return nil, err
+	}
+	tcp, ok := ln.(*net.TCPListener)
+	if !ok {
+		_ = ln.Close()
+		return nil, fmt.Errorf("unexpected listener type %T", ln)
+	}
+	return tcp, nil
+}
+
+// control sets the socket options of the listener before it is bound.
+func (l Listener) control(network, address string, c syscall.RawConn) error {
+	if l.ReusePort {
+		if err := reusePort(network, address, c); err != nil {
+			return err
+		}
+	}
+	if l.Interface != "" {
+		if err := bindToDevice(c, l.Interface); err != nil {
+			return fmt.Errorf("failed to bind to interface %s: %w", l.Interface, err)
+		}
+	}
+	return nil
+}
+
+// load reads the certificate, key and client CA of a TLS listener.
+func (c *TLSConfig) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   c.NextProtos,
+	}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
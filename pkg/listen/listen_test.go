@@ -0,0 +1,230 @@
+package listen
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	defaults := TLSConfig{CertFile: "default.crt", KeyFile: "default.key"}
+
+	tests := []struct {
+		name        string
+		spec        string
+		want        []string
+		wantNetwork []string
+		wantErr     bool
+	}{
+		{
+			name:        "single",
+			spec:        "169.254.169.254:80",
+			want:        []string{"http://169.254.169.254:80"},
+			wantNetwork: []string{"tcp"},
+		},
+		{
+			name: "multiple",
+			spec: "[::]:80, 169.254.169.254:80,https://10.0.60.5:8443",
+			want: []string{
+				"http://[::]:80",
+				"http://169.254.169.254:80",
+				"https://10.0.60.5:8443",
+			},
+			wantNetwork: []string{"tcp", "tcp", "tcp"},
+		},
+		{
+			name:        "both wildcards",
+			spec:        "0.0.0.0:80,[::]:80,[::]:8080",
+			want:        []string{"http://0.0.0.0:80", "http://[::]:80", "http://[::]:8080"},
+			wantNetwork: []string{"tcp4", "tcp6", "tcp"},
+		},
+		{name: "empty", spec: " , ", wantErr: true},
+		{name: "missing port", spec: "10.0.0.1", wantErr: true},
+		{name: "hostname", spec: "localhost:80", wantErr: true},
+		{name: "duplicate", spec: "10.0.0.1:80,http://10.0.0.1:80", wantErr: true},
+		{name: "unsupported scheme", spec: "udp://10.0.0.1:80", wantErr: true},
+		{name: "http tls options", spec: "http://10.0.0.1:80?cert=a.crt", wantErr: true},
+		{name: "unknown option", spec: "https://10.0.0.1:443?ciphers=all", wantErr: true},
+		{name: "path", spec: "http://10.0.0.1:80/metadata", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := Parse(tt.spec, defaults, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(have) != len(tt.want) {
+				t.Fatalf("have %d listeners, want %d", len(have), len(tt.want))
+			}
+			for i, l := range have {
+				if l.String() != tt.want[i] {
+					t.Errorf("listener %d: have %s, want %s", i, l, tt.want[i])
+				}
+				if network := l.Network(); network != tt.wantNetwork[i] {
+					t.Errorf("listener %d network: have %s, want %s", i, network, tt.wantNetwork[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParse_tlsOptions(t *testing.T) {
+	defaults := TLSConfig{CertFile: "default.crt", KeyFile: "default.key"}
+	spec := "https://10.0.60.5:8443?cert=a.crt&key=a.key&client_ca=ca.crt,https://[::1]:443"
+
+	have, err := Parse(spec, defaults, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := TLSConfig{CertFile: "a.crt", KeyFile: "a.key", ClientCAFile: "ca.crt"}
+	if !reflect.DeepEqual(*have[0].TLS, want) {
+		t.Errorf("have %+v, want %+v", *have[0].TLS, want)
+	}
+	if !reflect.DeepEqual(*have[1].TLS, defaults) {
+		t.Errorf("have %+v, want %+v", *have[1].TLS, defaults)
+	}
+
+	if _, err := Parse("https://10.0.60.5:8443", TLSConfig{}, ""); err == nil {
+		t.Error("expected error for https listener without certificate")
+	}
+}
+
+func TestParse_interface(t *testing.T) {
+	have, err := Parse("10.0.60.5:80,[::]:80?interface=eth1", TLSConfig{}, "eth0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"http://10.0.60.5:80%eth0", "http://[::]:80%eth1"}
+	for i, l := range have {
+		if l.String() != want[i] {
+			t.Errorf("listener %d: have %s, want %s", i, l, want[i])
+		}
+	}
+}
+
+func TestListener_Listen(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+	listeners, err := Parse(
+		"https://127.0.0.1:0?cert="+certFile+"&key="+keyFile,
+		TLSConfig{NextProtos: []string{"h2", "http/1.1"}},
+		"",
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ln, err := listeners[0].Listen(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("ok"))
+	}()
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2"},
+	})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+	defer conn.Close()
+	if have := conn.ConnectionState().NegotiatedProtocol; have != "h2" {
+		t.Errorf("have protocol %q, want h2", have)
+	}
+	buf := make([]byte, 2)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "ok" {
+		t.Errorf("have %q (%v), want ok", buf, err)
+	}
+}
+
+// writeCertificate writes a self-signed certificate and its key, returning
+// their paths.
+func writeCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestOpen(t *testing.T) {
+	listeners, err := Parse("127.0.0.1:0,[::1]:0", TLSConfig{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s, err := Open(context.Background(), listeners)
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer s.Close()
+
+	if s.Inherited() {
+		t.Error("expected listeners not to be inherited")
+	}
+	if have := len(s.Listeners()); have != 2 {
+		t.Fatalf("have %d listeners, want 2", have)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package listen
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePort fails on platforms without SO_REUSEPORT.
+func reusePort(_, _ string, _ syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
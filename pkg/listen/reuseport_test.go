@@ -0,0 +1,31 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package listen
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+func TestListener_reusePort(t *testing.T) {
+	l := Listener{Addr: netip.MustParseAddrPort("127.0.0.1:0"), ReusePort: true}
+	first, err := l.Listen(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer first.Close()
+
+	l.Addr = netip.MustParseAddrPort(first.Addr().String())
+	second, err := l.Listen(context.Background())
+	if err != nil {
+		t.Fatalf("failed to listen on %s again: %v", l.Addr, err)
+	}
+	defer second.Close()
+
+	l.ReusePort = false
+	if ln, err := l.Listen(context.Background()); err == nil {
+		ln.Close()
+		t.Errorf("expected listening on %s without SO_REUSEPORT to fail", l.Addr)
+	}
+}
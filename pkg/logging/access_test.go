@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	entry := AccessEntry{
+		Time:      time.Date(2025, 6, 1, 12, 0, 0, 0, time.FixedZone("", 2*60*60)),
+		ClientIP:  "10.1.105.195",
+		Method:    "GET",
+		URI:       "/openstack/latest/meta_data.json?x=1",
+		Proto:     "HTTP/1.1",
+		Status:    200,
+		Size:      512,
+		UserAgent: "Cloud-Init/23.4",
+		Duration:  1500 * time.Microsecond,
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		entry  func(e AccessEntry) AccessEntry
+		want   string
+	}{
+		{
+			name:   "combined",
+			format: AccessFormatCombined,
+			want: `10.1.105.195 - - [01/Jun/2025:12:00:00 +0200] ` +
+				`"GET /openstack/latest/meta_data.json?x=1 HTTP/1.1" 200 512 "-" "Cloud-Init/23.4"` +
+				"\n",
+		},
+		{
+			name:   "combined escaping",
+			format: AccessFormatCombined,
+			entry: func(e AccessEntry) AccessEntry {
+				e.Size = 0
+				e.Referer = "http://example.com/"
+				e.UserAgent = "curl\" \\\n"
+				return e
+			},
+			want: `10.1.105.195 - - [01/Jun/2025:12:00:00 +0200] ` +
+				`"GET /openstack/latest/meta_data.json?x=1 HTTP/1.1" 200 - ` +
+				`"http://example.com/" "curl\" \\\x0a"` + "\n",
+		},
+		{
+			name:   "json",
+			format: AccessFormatJSON,
+			want: `{"time":"2025-06-01T12:00:00+02:00","client_ip":"10.1.105.195",` +
+				`"method":"GET","uri":"/openstack/latest/meta_data.json?x=1",` +
+				`"protocol":"HTTP/1.1","status":200,"size":512,` +
+				`"user_agent":"Cloud-Init/23.4","duration_ms":1.5}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := NewAccessLog(&buf, tt.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			e := entry
+			if tt.entry != nil {
+				e = tt.entry(e)
+			}
+			if err := l.Log(e); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have := buf.String(); have != tt.want {
+				t.Errorf("have %s\nwant %s", have, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewAccessLog(&bytes.Buffer{}, "common"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
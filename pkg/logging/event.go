@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// event is a zerolog event decoded from its JSON encoding.
+type event struct {
+	level   zerolog.Level
+	message string
+
+	// fields holds the fields other than the time, level and message, in
+	// the order they were added.
+	fields []field
+}
+
+// field is a field of an event.
+type field struct {
+	key   string
+	value any
+}
+
+// decodeEvent decodes an event at level, or at the level in its level
+// field for zerolog.NoLevel. Numbers are decoded as int64 or float64.
+func decodeEvent(level zerolog.Level, p []byte) (*event, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("invalid log event %q", bytes.TrimSpace(p))
+	}
+
+	e := &event{level: level}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid log event: %w", err)
+		}
+		key, _ := tok.(string)
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid log event: %w", err)
+		}
+
+		switch key {
+		case zerolog.MessageFieldName:
+			e.message = fmt.Sprint(value)
+		case zerolog.LevelFieldName:
+			if e.level == zerolog.NoLevel {
+				if parsed, err := zerolog.ParseLevel(fmt.Sprint(value)); err == nil {
+					e.level = parsed
+				}
+			}
+		case zerolog.TimestampFieldName:
+			// Sinks timestamp events when they receive them.
+		default:
+			e.fields = append(e.fields, field{key: key, value: jsonValue(value)})
+		}
+	}
+	return e, nil
+}
+
+// jsonValue converts the numbers of a decoded JSON value to int64 or
+// float64.
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, elem := range v {
+			v[key] = jsonValue(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = jsonValue(elem)
+		}
+	}
+	return value
+}
@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp suffix of rotated log files, which sorts
+// in rotation order.
+const backupTimeFormat = "20060102T150405.000"
+
+// Defaults of FileConfig.
+const (
+	DefaultMaxSize    = 100 << 20
+	DefaultMaxBackups = 5
+)
+
+// FileConfig configures a RotatingFile.
+type FileConfig struct {
+	// Path is the log file, created with its directory when missing.
+	Path string
+
+	// MaxSize is the size in bytes the file is rotated at. It defaults to
+	// DefaultMaxSize, and a negative size disables rotation by size.
+	MaxSize int64
+
+	// MaxAge is how long the file is written to before it is rotated.
+	// Zero disables rotation by age.
+	MaxAge time.Duration
+
+	// MaxBackups is how many rotated files are kept. It defaults to
+	// DefaultMaxBackups, and a negative count keeps all of them.
+	MaxBackups int
+}
+
+// RotatingFile is a log file that is renamed with a timestamp suffix and
+// replaced once it grows too large or too old.
+type RotatingFile struct {
+	cfg FileConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	created time.Time
+}
+
+// NewRotatingFile opens the log file described by cfg, appending to it
+// when it exists.
+func NewRotatingFile(cfg FileConfig) (*RotatingFile, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("log file path is required")
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if cfg.MaxBackups == 0 {
+		cfg.MaxBackups = DefaultMaxBackups
+	}
+	f := &RotatingFile{cfg: cfg, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first when p would take it
+// past its size or when it is too old. Writes are never split across files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// due reports whether the file is rotated before writing n bytes.
+func (f *RotatingFile) due(n int64) bool {
+	if f.cfg.MaxSize > 0 && f.size+n > f.cfg.MaxSize {
+		return true
+	}
+	return f.cfg.MaxAge > 0 && f.now().Sub(f.created) >= f.cfg.MaxAge
+}
+
+// open opens the log file. The age of an existing file is taken from its
+// modification time, as its creation time is not portably available.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.created = f.now()
+	if f.size > 0 {
+		f.created = info.ModTime()
+	}
+	return nil
+}
+
+// rotate renames the file with a timestamp suffix, opens a new one and
+// removes rotated files beyond MaxBackups.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+	backup := f.cfg.Path + "." + f.now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(f.cfg.Path, backup); err != nil {
+		// Keep writing to the file rather than losing logs.
+		return errors.Join(fmt.Errorf("failed to rotate log file: %w", err), f.open())
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+// prune removes the oldest rotated files beyond MaxBackups.
+func (f *RotatingFile) prune() error {
+	if f.cfg.MaxBackups < 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(f.cfg.Path + ".[0-9]*T*")
+	if err != nil {
+		return fmt.Errorf("failed to list rotated log files: %w", err)
+	}
+	sort.Strings(backups)
+	for len(backups) > f.cfg.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove rotated log file: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultJournaldSocket is the socket journald receives native protocol
+// messages on.
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter is a zerolog writer sending events to journald, with
+// their fields as journal fields.
+type JournaldWriter struct {
+	conn       net.Conn
+	identifier string
+}
+
+// NewJournaldWriter returns a writer sending events to the journald socket,
+// or DefaultJournaldSocket when it is empty, tagged with identifier as
+// SYSLOG_IDENTIFIER.
+func NewJournaldWriter(socket, identifier string) (*JournaldWriter, error) {
+	if socket == "" {
+		socket = DefaultJournaldSocket
+	}
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+	return &JournaldWriter{conn: conn, identifier: identifier}, nil
+}
+
+// Write sends an event at the level in its level field.
+func (w *JournaldWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel sends an event at level. Fields are named by upper casing
+// their keys, such as NODE_UUID for node_uuid, and values other than
+// strings are JSON encoded.
+func (w *JournaldWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	e, err := decodeEvent(level, p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.conn.Write(journalMessage(e, w.identifier)); err != nil {
+		return 0, fmt.Errorf("failed to write to journald: %w", err)
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to journald.
+func (w *JournaldWriter) Close() error {
+	return w.conn.Close()
+}
+
+// journalMessage encodes an event in the journald native protocol.
+func journalMessage(e *event, identifier string) []byte {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", e.message)
+	appendJournalField(&buf, "PRIORITY", fmt.Sprint(journalPriority(e.level)))
+	if identifier != "" {
+		appendJournalField(&buf, "SYSLOG_IDENTIFIER", identifier)
+	}
+	for _, f := range e.fields {
+		value, ok := f.value.(string)
+		if !ok {
+			b, _ := json.Marshal(f.value)
+			value = string(b)
+		}
+		appendJournalField(&buf, journalFieldName(f.key), value)
+	}
+	return buf.Bytes()
+}
+
+// appendJournalField appends a field, in the binary format when the value
+// spans lines.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName returns a valid journal field name for key: upper case
+// letters, digits and underscores, not starting with an underscore, which
+// is reserved for trusted fields.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "FIELD_" + name
+	}
+	return name
+}
+
+// journalPriority returns the syslog priority of a level.
+func journalPriority(level zerolog.Level) int {
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return 7
+	case zerolog.WarnLevel:
+		return 4
+	case zerolog.ErrorLevel:
+		return 3
+	case zerolog.FatalLevel:
+		return 2
+	case zerolog.PanicLevel:
+		return 0
+	default:
+		return 6
+	}
+}
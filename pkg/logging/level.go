@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultRevertAfter is how long a temporary log level lasts by default.
+const DefaultRevertAfter = 15 * time.Minute
+
+// Level controls the global zerolog level, which can be raised temporarily
+// to diagnose a live service and reverts to the base level on its own.
+type Level struct {
+	revertAfter time.Duration
+
+	mu       sync.Mutex
+	base     zerolog.Level
+	level    zerolog.Level
+	revertAt time.Time
+	timer    *time.Timer
+}
+
+// LevelStatus describes the log level.
+type LevelStatus struct {
+	Level    string     `json:"level"`
+	Base     string     `json:"base"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// NewLevel returns a controller of the global level, starting at base.
+// Temporary levels last revertAfter by default, or DefaultRevertAfter when
+// it is zero.
+func NewLevel(base zerolog.Level, revertAfter time.Duration) *Level {
+	if revertAfter <= 0 {
+		revertAfter = DefaultRevertAfter
+	}
+	zerolog.SetGlobalLevel(base)
+	return &Level{revertAfter: revertAfter, base: base, level: base}
+}
+
+// RevertAfter returns how long temporary levels last by default.
+func (l *Level) RevertAfter() time.Duration {
+	return l.revertAfter
+}
+
+// Set sets the level, reverting to the base level after d. When d is zero
+// or level is the base level, level becomes the base level.
+func (l *Level) Set(level zerolog.Level, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stopTimer()
+	if d <= 0 || level == l.base {
+		l.base = level
+		l.apply(level)
+		return
+	}
+
+	l.apply(level)
+	l.revertAt = time.Now().Add(d)
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// A later change replaced the timer.
+		if l.timer == timer {
+			l.timer = nil
+			l.revertAt = time.Time{}
+			l.apply(l.base)
+		}
+	})
+	l.timer = timer
+}
+
+// Reset reverts to the base level.
+func (l *Level) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stopTimer()
+	l.apply(l.base)
+}
+
+// Temporary reports whether a temporary level is set.
+func (l *Level) Temporary() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timer != nil
+}
+
+// Status returns the level, the base level and when the level reverts.
+func (l *Level) Status() LevelStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status := LevelStatus{Level: l.level.String(), Base: l.base.String()}
+	if l.timer != nil {
+		revertAt := l.revertAt.UTC()
+		status.RevertAt = &revertAt
+	}
+	return status
+}
+
+func (l *Level) apply(level zerolog.Level) {
+	l.level = level
+	zerolog.SetGlobalLevel(level)
+}
+
+func (l *Level) stopTimer() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+		l.revertAt = time.Time{}
+	}
+}
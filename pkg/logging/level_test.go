@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLevel(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+
+	l := NewLevel(zerolog.InfoLevel, time.Minute)
+	if have := zerolog.GlobalLevel(); have != zerolog.InfoLevel {
+		t.Fatalf("have %s, want info", have)
+	}
+
+	l.Set(zerolog.DebugLevel, 50*time.Millisecond)
+	status := l.Status()
+	if zerolog.GlobalLevel() != zerolog.DebugLevel || !l.Temporary() || status.RevertAt == nil {
+		t.Fatalf("expected temporary debug level, have %+v", status)
+	}
+
+	// Replacing the temporary level cancels the earlier revert.
+	l.Set(zerolog.TraceLevel, time.Hour)
+	time.Sleep(100 * time.Millisecond)
+	if have := zerolog.GlobalLevel(); have != zerolog.TraceLevel {
+		t.Fatalf("have %s, want trace", have)
+	}
+
+	l.Reset()
+	if have := l.Status(); have.Level != "info" || have.RevertAt != nil || l.Temporary() {
+		t.Fatalf("expected reset to info, have %+v", have)
+	}
+
+	l.Set(zerolog.DebugLevel, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for zerolog.GlobalLevel() != zerolog.InfoLevel {
+		if time.Now().After(deadline) {
+			t.Fatal("expected level to revert to info")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if l.Temporary() {
+		t.Error("expected no temporary level after revert")
+	}
+
+	l.Set(zerolog.WarnLevel, 0)
+	if have := l.Status(); have.Level != "warn" || have.Base != "warn" {
+		t.Errorf("expected permanent warn level, have %+v", have)
+	}
+}
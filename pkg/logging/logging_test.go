@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSlogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := zerolog.New(NewSlogWriter(handler)).With().Timestamp().Logger()
+
+	logger.Debug().Msg("dropped")
+	logger.Warn().
+		Err(errors.New("timeout")).
+		Str("node", "web01").
+		Int("attempt", 3).
+		Float64("ratio", 0.5).
+		Strs("macs", []string{"52:54:00:12:34:56"}).
+		Msg("Failed to reach Ironic")
+
+	var have map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &have); err != nil {
+		t.Fatalf("expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"level":   "WARN",
+		"msg":     "Failed to reach Ironic",
+		"error":   "timeout",
+		"node":    "web01",
+		"attempt": float64(3),
+		"ratio":   0.5,
+		"macs":    []any{"52:54:00:12:34:56"},
+	}
+	for key, value := range want {
+		if have, _ := json.Marshal(have[key]); string(have) != mustMarshal(t, value) {
+			t.Errorf("%s: have %s, want %s", key, have, mustMarshal(t, value))
+		}
+	}
+	if _, ok := have["time"]; !ok {
+		t.Error("expected record time")
+	}
+}
+
+func TestSlogWriter_invalid(t *testing.T) {
+	w := NewSlogWriter(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	if _, err := w.Write([]byte("not json\n")); err == nil {
+		t.Error("expected error for non-JSON event")
+	}
+}
+
+func TestZerologHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewZerologHandler(zerolog.New(&buf).Level(zerolog.InfoLevel)))
+
+	logger.Debug("dropped")
+	logger.With("component", "client").WithGroup("ironic").Warn("Waiting for API",
+		"attempt", 2, slog.Group("endpoint", "url", "http://ironic:6385"))
+
+	var have map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &have); err != nil {
+		t.Fatalf("expected one JSON event, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"level":               "warn",
+		"message":             "Waiting for API",
+		"component":           "client",
+		"ironic.attempt":      float64(2),
+		"ironic.endpoint.url": "http://ironic:6385",
+	}
+	for key, value := range want {
+		if have[key] != value {
+			t.Errorf("%s: have %v, want %v", key, have[key], value)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		have zerolog.Level
+		want slog.Level
+	}{
+		{have: zerolog.TraceLevel, want: slog.LevelDebug - 4},
+		{have: zerolog.DebugLevel, want: slog.LevelDebug},
+		{have: zerolog.InfoLevel, want: slog.LevelInfo},
+		{have: zerolog.WarnLevel, want: slog.LevelWarn},
+		{have: zerolog.ErrorLevel, want: slog.LevelError},
+	}
+	for _, tt := range tests {
+		if have := SlogLevel(tt.have); have != tt.want {
+			t.Errorf("SlogLevel(%s): have %s, want %s", tt.have, have, tt.want)
+		}
+		if have := ZerologLevel(tt.want); have != tt.have {
+			t.Errorf("ZerologLevel(%s): have %s, want %s", tt.want, have, tt.have)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, value any) string {
+	t.Helper()
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
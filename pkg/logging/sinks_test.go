@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         FileConfig
+		advance     time.Duration
+		writes      []string
+		wantCurrent string
+		wantBackups int
+	}{
+		{
+			name:        "below size",
+			cfg:         FileConfig{MaxSize: 16},
+			writes:      []string{"0123456789\n", "abcd\n"},
+			wantCurrent: "0123456789\nabcd\n",
+		},
+		{
+			name:        "size",
+			cfg:         FileConfig{MaxSize: 16},
+			writes:      []string{"0123456789\n", "0123456789\n", "abcd\n"},
+			wantCurrent: "0123456789\nabcd\n",
+			wantBackups: 1,
+		},
+		{
+			name:        "backups pruned",
+			cfg:         FileConfig{MaxSize: 4, MaxBackups: 2},
+			writes:      []string{"one\n", "two\n", "three\n", "four\n"},
+			wantCurrent: "four\n",
+			wantBackups: 2,
+		},
+		{
+			name:        "age",
+			cfg:         FileConfig{MaxSize: -1, MaxAge: time.Hour},
+			advance:     time.Hour,
+			writes:      []string{"old\n", "new\n"},
+			wantCurrent: "new\n",
+			wantBackups: 1,
+		},
+		{
+			name:        "oversized write",
+			cfg:         FileConfig{MaxSize: 4},
+			writes:      []string{"0123456789\n"},
+			wantCurrent: "0123456789\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.cfg.Path = filepath.Join(dir, "log", "ironic-metadata.log")
+			now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+			f, err := NewRotatingFile(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer f.Close()
+			f.now = func() time.Time { return now }
+			f.created = now
+
+			for _, line := range tt.writes {
+				if _, err := f.Write([]byte(line)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// Distinct backup names need distinct rotation times.
+				now = now.Add(tt.advance + time.Second)
+			}
+
+			have, err := os.ReadFile(tt.cfg.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(have) != tt.wantCurrent {
+				t.Errorf("have %q, want %q", have, tt.wantCurrent)
+			}
+			backups, _ := filepath.Glob(tt.cfg.Path + ".*")
+			if len(backups) != tt.wantBackups {
+				t.Errorf("have %d backups %v, want %d", len(backups), backups, tt.wantBackups)
+			}
+		})
+	}
+}
+
+func TestJournaldWriter(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("cannot listen on unix datagram socket: %v", err)
+	}
+	defer conn.Close()
+
+	w, err := NewJournaldWriter(socket, "ironic-metadata")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	logger := zerolog.New(w).With().Timestamp().Logger()
+	logger.Warn().
+		Str("node_uuid", "uuid-1").
+		Int("attempt", 2).
+		Str("user_data", "#cloud-config\nhostname: web01\n").
+		Msg("Failed to render")
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have := buf[:n]
+
+	for _, want := range []string{
+		"MESSAGE=Failed to render\n",
+		"PRIORITY=4\n",
+		"SYSLOG_IDENTIFIER=ironic-metadata\n",
+		"NODE_UUID=uuid-1\n",
+		"ATTEMPT=2\n",
+	} {
+		if !bytes.Contains(have, []byte(want)) {
+			t.Errorf("expected %q in %q", want, have)
+		}
+	}
+	value := "#cloud-config\nhostname: web01\n"
+	size := binary.LittleEndian.AppendUint64(nil, uint64(len(value)))
+	binaryField := "USER_DATA\n" + string(size) + value + "\n"
+	if !bytes.Contains(have, []byte(binaryField)) {
+		t.Errorf("expected multi-line field in binary format in %q", have)
+	}
+	if bytes.Contains(have, []byte("TIME=")) {
+		t.Errorf("expected time field to be left out of %q", have)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := []struct {
+		have string
+		want string
+	}{
+		{have: "node_uuid", want: "NODE_UUID"},
+		{have: "remote-addr", want: "REMOTE_ADDR"},
+		{have: "_hidden", want: "HIDDEN"},
+		{have: "2fa", want: "FIELD_2FA"},
+		{have: "", want: "FIELD_"},
+	}
+	for _, tt := range tests {
+		if have := journalFieldName(tt.have); have != tt.want {
+			t.Errorf("journalFieldName(%q): have %q, want %q", tt.have, have, tt.want)
+		}
+	}
+}
+
+func TestNewSyslogWriter_invalid(t *testing.T) {
+	if _, err := NewSyslogWriter("http://loghost:514", "ironic-metadata"); err == nil ||
+		!strings.Contains(err.Error(), "syslog") {
+		t.Errorf("expected error for unsupported address, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Package logging provides the log sinks of the service, and adapts its
+// zerolog loggers to the standard library's log/slog, so its packages can
+// be embedded in binaries with their own logging stack, and vice versa.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// SlogWriter is a zerolog writer handing events to a slog handler.
+type SlogWriter struct {
+	handler slog.Handler
+}
+
+// NewSlogWriter returns a writer handing the events of a zerolog logger to
+// handler, such as for zerolog.New(logging.NewSlogWriter(handler)). Events
+// are expected in zerolog's JSON encoding; their time, level and message
+// fields become those of the slog record.
+func NewSlogWriter(handler slog.Handler) *SlogWriter {
+	return &SlogWriter{handler: handler}
+}
+
+// Write hands an event to the handler at the level in its level field.
+func (w *SlogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel hands an event at level to the handler.
+func (w *SlogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	ctx := context.Background()
+	e, err := decodeEvent(level, p)
+	if err != nil {
+		return 0, err
+	}
+	record := slog.NewRecord(time.Now(), SlogLevel(e.level), e.message, 0)
+	for _, f := range e.fields {
+		record.AddAttrs(slog.Any(f.key, f.value))
+	}
+	if !w.handler.Enabled(ctx, record.Level) {
+		return len(p), nil
+	}
+	if err := w.handler.Handle(ctx, record); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// SlogLevel returns the slog level of a zerolog level. Levels without a
+// slog equivalent are spaced like slog's own levels.
+func SlogLevel(level zerolog.Level) slog.Level {
+	switch level {
+	case zerolog.TraceLevel:
+		return slog.LevelDebug - 4
+	case zerolog.DebugLevel:
+		return slog.LevelDebug
+	case zerolog.WarnLevel:
+		return slog.LevelWarn
+	case zerolog.ErrorLevel:
+		return slog.LevelError
+	case zerolog.FatalLevel:
+		return slog.LevelError + 4
+	case zerolog.PanicLevel:
+		return slog.LevelError + 8
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ZerologLevel returns the zerolog level of a slog level, rounding down to
+// the nearest level.
+func ZerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
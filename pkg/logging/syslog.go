@@ -0,0 +1,37 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+
+	"github.com/rs/zerolog"
+)
+
+// NewSyslogWriter returns a writer sending events to syslog at their level,
+// tagged with tag. addr is empty for the local syslog daemon, or a URL such
+// as udp://loghost:514, tcp://loghost:514 or unix:///dev/log.
+func NewSyslogWriter(addr, tag string) (zerolog.LevelWriter, error) {
+	var network, raddr string
+	if addr != "" {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog address %q: %w", addr, err)
+		}
+		switch u.Scheme {
+		case "udp", "tcp":
+			network, raddr = u.Scheme, u.Host
+		case "unix", "unixgram":
+			network, raddr = u.Scheme, u.Path
+		default:
+			return nil, fmt.Errorf("unsupported syslog address %q", addr)
+		}
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return zerolog.SyslogLevelWriter(w), nil
+}
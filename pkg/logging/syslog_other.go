@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog"
+)
+
+// NewSyslogWriter is not supported on this platform.
+func NewSyslogWriter(_, _ string) (zerolog.LevelWriter, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
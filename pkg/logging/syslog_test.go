@@ -0,0 +1,44 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSyslogWriter(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("cannot listen on unix datagram socket: %v", err)
+	}
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("unixgram://"+socket, "ironic-metadata")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger := zerolog.New(w)
+	logger.Error().Str("node_uuid", "uuid-1").Msg("Failed to render")
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have := string(buf[:n])
+
+	// LOG_DAEMON|LOG_ERR
+	for _, want := range []string{"<27>", "ironic-metadata[", `"node_uuid":"uuid-1"`} {
+		if !strings.Contains(have, want) {
+			t.Errorf("expected %q in %q", want, have)
+		}
+	}
+}
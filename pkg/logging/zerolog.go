@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// ZerologHandler is a slog handler writing records to a zerolog logger.
+type ZerologHandler struct {
+	logger zerolog.Logger
+	prefix string
+}
+
+// NewZerologHandler returns a slog handler writing to logger, such as for
+// slog.New(logging.NewZerologHandler(log.Logger)). Attributes in groups are
+// written with the group names as dot separated key prefixes.
+func NewZerologHandler(logger zerolog.Logger) *ZerologHandler {
+	return &ZerologHandler{logger: logger}
+}
+
+// Enabled reports whether the logger writes records at level.
+func (h *ZerologHandler) Enabled(_ context.Context, level slog.Level) bool {
+	zlevel := ZerologLevel(level)
+	return zlevel >= h.logger.GetLevel() && zlevel >= zerolog.GlobalLevel()
+}
+
+// Handle writes a record.
+func (h *ZerologHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := make([]any, 0, 2*record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, attr)
+		return true
+	})
+	h.logger.WithLevel(ZerologLevel(record.Level)).Fields(fields).Msg(record.Message)
+	return nil
+}
+
+// WithAttrs returns a handler writing attrs with every record.
+func (h *ZerologHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]any, 0, 2*len(attrs))
+	for _, attr := range attrs {
+		fields = appendAttr(fields, h.prefix, attr)
+	}
+	return &ZerologHandler{logger: h.logger.With().Fields(fields).Logger(), prefix: h.prefix}
+}
+
+// WithGroup returns a handler writing attributes in the group name.
+func (h *ZerologHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &ZerologHandler{logger: h.logger, prefix: h.prefix + name + "."}
+}
+
+// appendAttr appends the key and value of an attribute to fields,
+// flattening groups.
+func appendAttr(fields []any, prefix string, attr slog.Attr) []any {
+	value := attr.Value.Resolve()
+	if value.Kind() != slog.KindGroup {
+		if attr.Key == "" {
+			return fields
+		}
+		return append(fields, prefix+attr.Key, value.Any())
+	}
+	if attr.Key != "" {
+		prefix += attr.Key + "."
+	}
+	for _, member := range value.Group() {
+		fields = appendAttr(fields, prefix, member)
+	}
+	return fields
+}
@@ -0,0 +1,137 @@
+// Package lru provides a cache evicting its least recently used entries to
+// stay within a number of entries and a memory budget, for the caches of the
+// metadata service to fit on small provisioning hosts.
+package lru
+
+import (
+	"container/list"
+	"iter"
+)
+
+// Limits bounds a cache. Zero values leave the cache unbounded.
+type Limits struct {
+	// MaxEntries caps the number of entries.
+	MaxEntries int
+
+	// MaxBytes caps the sum of the sizes given to Put, an estimate of the
+	// memory the entries take.
+	MaxBytes int64
+}
+
+// Cache maps keys to values, evicting the least recently used entries beyond
+// its limits. The zero value is an empty, unbounded cache. It is not safe for
+// concurrent use, and is meant to be guarded by the mutex of its owner.
+type Cache[K comparable, V any] struct {
+	limits Limits
+	order  *list.List
+	items  map[K]*list.Element
+	bytes  int64
+}
+
+// entry is a cached value with its key and size.
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+// New returns an empty cache bounded by limits.
+func New[K comparable, V any](limits Limits) *Cache[K, V] {
+	return &Cache[K, V]{limits: limits}
+}
+
+// SetLimits bounds the cache by limits, evicting the entries beyond them. It
+// returns the number of entries evicted.
+func (c *Cache[K, V]) SetLimits(limits Limits) int {
+	c.limits = limits
+	return c.evict()
+}
+
+// Get returns the value of a key, marking it as the most recently used.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*entry[K, V]).value, true
+}
+
+// Put stores the value of a key, of an estimated size in bytes, as the most
+// recently used, replacing any earlier value. It returns the number of
+// entries evicted to stay within the limits, which includes the new entry
+// when it alone exceeds MaxBytes.
+func (c *Cache[K, V]) Put(key K, value V, size int64) int {
+	if c.items == nil {
+		c.items = make(map[K]*list.Element)
+		c.order = list.New()
+	}
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		c.bytes += size - e.size
+		e.value, e.size = value, size
+		c.order.MoveToFront(elem)
+	} else {
+		c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, size: size})
+		c.bytes += size
+	}
+	return c.evict()
+}
+
+// Delete drops the value of a key.
+func (c *Cache[K, V]) Delete(key K) {
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// Len returns the number of entries.
+func (c *Cache[K, V]) Len() int {
+	return len(c.items)
+}
+
+// Bytes returns the sum of the sizes of the entries.
+func (c *Cache[K, V]) Bytes() int64 {
+	return c.bytes
+}
+
+// All yields every entry, from the most to the least recently used, without
+// marking them as used.
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if c.order == nil {
+			return
+		}
+		for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+			e := elem.Value.(*entry[K, V])
+			if !yield(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// evict drops the least recently used entries until the cache is within its
+// limits, returning how many it dropped.
+func (c *Cache[K, V]) evict() int {
+	evicted := 0
+	for c.order != nil && c.order.Len() > 0 && c.over() {
+		c.remove(c.order.Back())
+		evicted++
+	}
+	return evicted
+}
+
+// over reports whether the cache exceeds its limits.
+func (c *Cache[K, V]) over() bool {
+	return (c.limits.MaxEntries > 0 && len(c.items) > c.limits.MaxEntries) ||
+		(c.limits.MaxBytes > 0 && c.bytes > c.limits.MaxBytes)
+}
+
+// remove drops an entry.
+func (c *Cache[K, V]) remove(elem *list.Element) {
+	e := c.order.Remove(elem).(*entry[K, V])
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
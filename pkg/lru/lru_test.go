@@ -0,0 +1,124 @@
+package lru
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	type put struct {
+		key  string
+		size int64
+	}
+	tests := []struct {
+		name   string
+		limits Limits
+		puts   []put
+		// get is looked up after the first two puts, marking it as used.
+		get         string
+		want        []string
+		wantEvicted int
+		wantBytes   int64
+	}{
+		{
+			name:      "unbounded",
+			puts:      []put{{"a", 10}, {"b", 10}, {"c", 10}},
+			want:      []string{"c", "b", "a"},
+			wantBytes: 30,
+		},
+		{
+			name:        "max entries",
+			limits:      Limits{MaxEntries: 2},
+			puts:        []put{{"a", 10}, {"b", 10}, {"c", 10}},
+			want:        []string{"c", "b"},
+			wantEvicted: 1,
+			wantBytes:   20,
+		},
+		{
+			name:        "get keeps an entry",
+			limits:      Limits{MaxEntries: 2},
+			puts:        []put{{"a", 10}, {"b", 10}, {"c", 10}},
+			get:         "a",
+			want:        []string{"c", "a"},
+			wantEvicted: 1,
+			wantBytes:   20,
+		},
+		{
+			name:        "max bytes",
+			limits:      Limits{MaxBytes: 25},
+			puts:        []put{{"a", 10}, {"b", 10}, {"c", 15}},
+			want:        []string{"c", "b"},
+			wantEvicted: 1,
+			wantBytes:   25,
+		},
+		{
+			name:        "entry larger than the budget",
+			limits:      Limits{MaxBytes: 25},
+			puts:        []put{{"a", 10}, {"b", 30}},
+			want:        []string{},
+			wantEvicted: 2,
+		},
+		{
+			name:      "replace",
+			limits:    Limits{MaxBytes: 25},
+			puts:      []put{{"a", 10}, {"b", 10}, {"a", 5}},
+			want:      []string{"a", "b"},
+			wantBytes: 15,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[string, int](tt.limits)
+			evicted := 0
+			for i, p := range tt.puts {
+				evicted += c.Put(p.key, i, p.size)
+				if i == 1 && tt.get != "" {
+					if _, ok := c.Get(tt.get); !ok {
+						t.Fatalf("expected %q to be cached", tt.get)
+					}
+				}
+			}
+
+			have := []string{}
+			for key := range c.All() {
+				have = append(have, key)
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("have keys %v, want %v", have, tt.want)
+			}
+			if evicted != tt.wantEvicted {
+				t.Errorf("have %d evicted, want %d", evicted, tt.wantEvicted)
+			}
+			if c.Bytes() != tt.wantBytes {
+				t.Errorf("have %d bytes, want %d", c.Bytes(), tt.wantBytes)
+			}
+			if c.Len() != len(tt.want) {
+				t.Errorf("have %d entries, want %d", c.Len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestCache_zeroValue(t *testing.T) {
+	var c Cache[string, int]
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected an empty cache")
+	}
+	c.Delete("a")
+	c.Put("a", 1, 1)
+	if have, ok := c.Get("a"); !ok || have != 1 {
+		t.Errorf("have %d, %v, want 1, true", have, ok)
+	}
+	if evicted := c.SetLimits(Limits{MaxEntries: 1}); evicted != 0 {
+		t.Errorf("have %d evicted, want 0", evicted)
+	}
+	c.Put("b", 2, 1)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to be evicted")
+	}
+	c.Delete("b")
+	if c.Len() != 0 || c.Bytes() != 0 {
+		t.Errorf("have %d entries of %d bytes, want none", c.Len(), c.Bytes())
+	}
+}
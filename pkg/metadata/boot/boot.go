@@ -0,0 +1,81 @@
+// Package boot renders the network boot scripts chainloaded by nodes, from
+// the kernel, ramdisk or ISO image they boot.
+package boot
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Config describes what a node boots. A node boots the Kernel and Ramdisk
+// when both are set, or else the ISO when it is set, or else from its local
+// disk.
+type Config struct {
+	// Node names the node in comments and messages of the script.
+	Node string
+
+	// Kernel and Ramdisk are the URLs of the kernel and initramfs.
+	Kernel  string
+	Ramdisk string
+
+	// ISO is the URL of an ISO image booted instead of a kernel.
+	ISO string
+
+	// Params are the kernel command line parameters.
+	Params string
+}
+
+// LocalBoot reports whether c boots from the local disk.
+func (c Config) LocalBoot() bool {
+	return c.Kernel == "" && c.ISO == ""
+}
+
+// Validate checks that c can be rendered into a script: a kernel comes with
+// a ramdisk, images are HTTP, HTTPS or TFTP URLs, and no value spans several
+// lines.
+func (c Config) Validate() error {
+	if (c.Kernel == "") != (c.Ramdisk == "") {
+		return errors.New("kernel and ramdisk must be set together")
+	}
+	for _, image := range []struct{ name, value string }{
+		{"kernel", c.Kernel},
+		{"ramdisk", c.Ramdisk},
+		{"iso", c.ISO},
+	} {
+		if image.value == "" {
+			continue
+		}
+		if err := validateURL(image.value); err != nil {
+			return fmt.Errorf("invalid %s: %w", image.name, err)
+		}
+	}
+	if strings.ContainsAny(c.Params, "\r\n") {
+		return errors.New("kernel parameters span several lines")
+	}
+	if strings.ContainsAny(c.Node, "\r\n") {
+		return errors.New("node name spans several lines")
+	}
+	return nil
+}
+
+// validateURL checks that an image is a URL a network boot loader fetches.
+func validateURL(image string) error {
+	if strings.ContainsFunc(image, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return fmt.Errorf("%q contains whitespace or control characters", image)
+	}
+	u, err := url.Parse(image)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "tftp":
+	default:
+		return fmt.Errorf("%q is not an HTTP, HTTPS or TFTP URL", image)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", image)
+	}
+	return nil
+}
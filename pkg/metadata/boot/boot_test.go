@@ -0,0 +1,178 @@
+package boot
+
+import (
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    Config
+		wantErr bool
+	}{
+		{name: "local", have: Config{Node: "web01"}},
+		{
+			name: "kernel",
+			have: Config{
+				Kernel:  "http://10.0.0.1/vmlinuz",
+				Ramdisk: "tftp://10.0.0.1/initrd",
+				Params:  "console=ttyS0",
+			},
+		},
+		{name: "iso", have: Config{ISO: "https://images.example.com/boot.iso"}},
+		{
+			name:    "kernel without ramdisk",
+			have:    Config{Kernel: "http://10.0.0.1/vmlinuz"},
+			wantErr: true,
+		},
+		{
+			name:    "glance image",
+			have:    Config{ISO: "8a81b3a9-0b1f-4e4c-9a4f-0d3c7b5e6f70"},
+			wantErr: true,
+		},
+		{name: "file url", have: Config{ISO: "file:///images/boot.iso"}, wantErr: true},
+		{name: "whitespace", have: Config{ISO: "http://10.0.0.1/boot .iso"}, wantErr: true},
+		{
+			name: "multiline params",
+			have: Config{
+				Kernel:  "http://10.0.0.1/vmlinuz",
+				Ramdisk: "http://10.0.0.1/initrd",
+				Params:  "console=ttyS0\nshell",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.have.Validate()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestIPXE(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "local",
+			have: Config{Node: "web01"},
+			want: "#!ipxe\n# web01\nexit\n",
+		},
+		{
+			name: "kernel",
+			have: Config{
+				Node:    "web01",
+				Kernel:  "http://10.0.0.1/vmlinuz",
+				Ramdisk: "http://10.0.0.1/initrd",
+				Params:  "console=ttyS0",
+			},
+			want: "#!ipxe\n# web01\n" +
+				"kernel --name kernel http://10.0.0.1/vmlinuz initrd=ramdisk console=ttyS0" +
+				" || goto fail\n" +
+				"initrd --name ramdisk http://10.0.0.1/initrd || goto fail\n" +
+				"boot || goto fail\n" +
+				"\n:fail\necho Failed to boot web01\nsleep 10\nexit 1\n",
+		},
+		{
+			name: "iso",
+			have: Config{ISO: "http://10.0.0.1/boot.iso"},
+			want: "#!ipxe\n" +
+				"sanboot --no-describe http://10.0.0.1/boot.iso || goto fail\n" +
+				"\n:fail\necho Failed to boot\nsleep 10\nexit 1\n",
+		},
+		{name: "invalid", have: Config{ISO: "boot.iso"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := IPXE(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRUB(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "local",
+			have: Config{Node: "web01"},
+			want: "set default=0\nset timeout=0\n\nmenuentry web01 {\n\texit\n}\n",
+		},
+		{
+			name: "kernel",
+			have: Config{
+				Node:    "web 01",
+				Kernel:  "http://10.0.0.1:8080/images/vmlinuz",
+				Ramdisk: "tftp://10.0.0.1/initrd?arch=x86_64",
+				Params:  "console=ttyS0 ipa-api-url=http://10.0.0.1:6385 root=$root",
+			},
+			want: "set default=0\nset timeout=0\n\nmenuentry 'web 01' {\n" +
+				"\tlinux (http,10.0.0.1:8080)/images/vmlinuz console=ttyS0" +
+				" ipa-api-url=http://10.0.0.1:6385 'root=$root'\n" +
+				"\tinitrd (tftp,10.0.0.1)'/initrd?arch=x86_64'\n" +
+				"}\n",
+		},
+		{name: "iso", have: Config{ISO: "http://10.0.0.1/boot.iso"}, wantErr: true},
+		{
+			name: "https",
+			have: Config{
+				Kernel:  "https://10.0.0.1/vmlinuz",
+				Ramdisk: "https://10.0.0.1/initrd",
+			},
+			wantErr: true,
+		},
+		{
+			name: "single quote",
+			have: Config{
+				Kernel:  "http://10.0.0.1/vmlinuz",
+				Ramdisk: "http://10.0.0.1/initrd",
+				Params:  "motd='hi'",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := GRUB(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package boot
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GRUB renders c as a GRUB configuration with a single menu entry. Images
+// are fetched with the http and tftp modules of GRUB, which cannot fetch
+// HTTPS URLs nor boot ISO images. Nodes booting from their local disk exit
+// GRUB, so the firmware tries its next boot device.
+func GRUB(c Config) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	if c.Kernel == "" && c.ISO != "" {
+		return "", errors.New("grub cannot boot iso images")
+	}
+
+	title := c.Node
+	if title == "" {
+		title = "node"
+	}
+	title, err := grubQuote(title)
+	if err != nil {
+		return "", fmt.Errorf("invalid node name: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("set default=0\nset timeout=0\n\n")
+	if c.LocalBoot() {
+		fmt.Fprintf(&b, "menuentry %s {\n\texit\n}\n", title)
+		return b.String(), nil
+	}
+
+	kernel, err := grubPath(c.Kernel)
+	if err != nil {
+		return "", fmt.Errorf("invalid kernel: %w", err)
+	}
+	ramdisk, err := grubPath(c.Ramdisk)
+	if err != nil {
+		return "", fmt.Errorf("invalid ramdisk: %w", err)
+	}
+	linux := []string{kernel}
+	for _, param := range strings.Fields(c.Params) {
+		quoted, err := grubQuote(param)
+		if err != nil {
+			return "", fmt.Errorf("invalid kernel parameter: %w", err)
+		}
+		linux = append(linux, quoted)
+	}
+
+	fmt.Fprintf(&b, "menuentry %s {\n", title)
+	fmt.Fprintf(&b, "\tlinux %s\n", strings.Join(linux, " "))
+	fmt.Fprintf(&b, "\tinitrd %s\n", ramdisk)
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// grubPath converts an image URL to a GRUB path on a network device, such
+// as (http,10.0.0.1:8080)/images/vmlinuz for http://10.0.0.1:8080/images/vmlinuz.
+func grubPath(image string) (string, error) {
+	u, err := url.Parse(image)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "tftp" {
+		return "", fmt.Errorf("%q is not an HTTP or TFTP URL", image)
+	}
+	path, err := grubQuote(u.RequestURI())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s,%s)%s", u.Scheme, u.Host, path), nil
+}
+
+// grubQuote quotes a word of a GRUB command in single quotes when it holds
+// characters GRUB interprets, such as $ or ;. Words holding single quotes,
+// which cannot be escaped within them, are rejected.
+func grubQuote(word string) (string, error) {
+	if !strings.ContainsFunc(word, grubSpecial) {
+		return word, nil
+	}
+	if strings.Contains(word, "'") {
+		return "", fmt.Errorf("%q contains a single quote", word)
+	}
+	return "'" + word + "'", nil
+}
+
+// grubSpecial reports whether GRUB interprets a character of a word.
+func grubSpecial(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("-_.,:=+/@%", r)
+}
@@ -0,0 +1,47 @@
+package boot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IPXE renders c as an iPXE script. Scripts booting a kernel name the
+// initramfs on the command line, as EFI stub kernels require. Nodes booting
+// from their local disk exit iPXE, so the firmware tries its next boot
+// device.
+func IPXE(c Config) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("#!ipxe\n")
+	if c.Node != "" {
+		fmt.Fprintf(&b, "# %s\n", c.Node)
+	}
+
+	switch {
+	case c.Kernel != "":
+		params := "initrd=ramdisk"
+		if c.Params != "" {
+			params += " " + c.Params
+		}
+		fmt.Fprintf(&b, "kernel --name kernel %s %s || goto fail\n", c.Kernel, params)
+		fmt.Fprintf(&b, "initrd --name ramdisk %s || goto fail\n", c.Ramdisk)
+		b.WriteString("boot || goto fail\n")
+	case c.ISO != "":
+		fmt.Fprintf(&b, "sanboot --no-describe %s || goto fail\n", c.ISO)
+	default:
+		b.WriteString("exit\n")
+		return b.String(), nil
+	}
+
+	b.WriteString("\n:fail\n")
+	if c.Node != "" {
+		fmt.Fprintf(&b, "echo Failed to boot %s\n", c.Node)
+	} else {
+		b.WriteString("echo Failed to boot\n")
+	}
+	b.WriteString("sleep 10\nexit 1\n")
+	return b.String(), nil
+}
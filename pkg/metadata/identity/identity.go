@@ -0,0 +1,128 @@
+// Package identity builds and signs EC2-style instance identity documents,
+// so workloads that verify instance identity can run on Ironic nodes.
+package identity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DocumentVersion is the identity document schema version reported to
+// clients, matching the one served by EC2.
+const DocumentVersion = "2017-09-30"
+
+// Document is an EC2-compatible instance identity document.
+type Document struct {
+	AccountID               string    `json:"accountId"`
+	Architecture            string    `json:"architecture"`
+	AvailabilityZone        string    `json:"availabilityZone"`
+	BillingProducts         []string  `json:"billingProducts"`
+	DevpayProductCodes      []string  `json:"devpayProductCodes"`
+	MarketplaceProductCodes []string  `json:"marketplaceProductCodes"`
+	ImageID                 string    `json:"imageId"`
+	InstanceID              string    `json:"instanceId"`
+	InstanceType            string    `json:"instanceType"`
+	KernelID                *string   `json:"kernelId"`
+	PendingTime             time.Time `json:"pendingTime"`
+	PrivateIP               string    `json:"privateIp"`
+	RamdiskID               *string   `json:"ramdiskId"`
+	Region                  string    `json:"region"`
+	Version                 string    `json:"version"`
+}
+
+// Signer signs identity documents with the service key.
+type Signer struct {
+	key  crypto.Signer
+	cert *x509.Certificate
+}
+
+// NewSigner returns a signer using key. cert is optional and only required
+// to produce PKCS#7 signatures; it must hold the public half of key.
+func NewSigner(key crypto.Signer, cert *x509.Certificate) (*Signer, error) {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported identity signing key type %T", key)
+	}
+	return &Signer{key: key, cert: cert}, nil
+}
+
+// LoadSigner reads a PEM encoded private key and, if certFile is not empty,
+// the matching PEM encoded certificate.
+func LoadSigner(keyFile, certFile string) (*Signer, error) {
+	key, err := LoadKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var cert *x509.Certificate
+	if certFile != "" {
+		certPEM, err := os.ReadFile(certFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identity certificate: %w", err)
+		}
+		block, _ := pem.Decode(certPEM)
+		if block == nil {
+			return nil, errors.New("identity certificate is not PEM encoded")
+		}
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse identity certificate: %w", err)
+		}
+	}
+
+	return NewSigner(key, cert)
+}
+
+// LoadKey reads a PEM encoded PKCS#1, PKCS#8 or SEC 1 private key.
+func LoadKey(keyFile string) (crypto.Signer, error) {
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity key: %w", err)
+	}
+	return parsePrivateKey(keyPEM)
+}
+
+// parsePrivateKey decodes a PKCS#1, PKCS#8 or SEC 1 private key.
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("identity key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported identity signing key type %T", key)
+	}
+	return signer, nil
+}
+
+// Sign returns the SHA-256 signature of a document.
+func (s *Signer) Sign(document []byte) ([]byte, error) {
+	digest := sha256.Sum256(document)
+	return s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// HasCertificate reports whether the signer can produce PKCS#7 signatures.
+func (s *Signer) HasCertificate() bool {
+	return s.cert != nil
+}
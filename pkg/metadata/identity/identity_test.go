@@ -0,0 +1,172 @@
+package identity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// selfSigned creates a certificate for key signed by itself.
+func selfSigned(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "ironic-metadata identity"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestPKCS7(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm x509.SignatureAlgorithm
+	}{
+		{name: "rsa", key: rsaKey, algorithm: x509.SHA256WithRSA},
+		{name: "ecdsa", key: ecKey, algorithm: x509.ECDSAWithSHA256},
+	}
+
+	document := []byte(`{"instanceId": "test-uuid"}`)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := selfSigned(t, tt.key)
+			signer, err := NewSigner(tt.key, cert)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			der, err := signer.PKCS7(document)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var outer contentInfo
+			if _, err := asn1.Unmarshal(der, &outer); err != nil {
+				t.Fatalf("failed to decode content info: %v", err)
+			}
+			if !outer.ContentType.Equal(oidSignedData) {
+				t.Fatalf("expected signedData content type, got %v", outer.ContentType)
+			}
+
+			var sd signedData
+			if _, err := asn1.Unmarshal(outer.Content.Bytes, &sd); err != nil {
+				t.Fatalf("failed to decode signed data: %v", err)
+			}
+
+			var content []byte
+			if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+				t.Fatalf("failed to decode content: %v", err)
+			}
+			if string(content) != string(document) {
+				t.Errorf("content\nhave: %q\nwant: %q", content, document)
+			}
+
+			embedded, err := x509.ParseCertificate(sd.Certificates.Bytes)
+			if err != nil {
+				t.Fatalf("failed to parse embedded certificate: %v", err)
+			}
+			si := sd.SignerInfos[0]
+			if si.IssuerAndSerialNumber.SerialNumber.Cmp(embedded.SerialNumber) != 0 {
+				t.Errorf("expected serial %v, got %v", embedded.SerialNumber, si.IssuerAndSerialNumber.SerialNumber)
+			}
+			if err := embedded.CheckSignature(tt.algorithm, content, si.EncryptedDigest); err != nil {
+				t.Errorf("signature does not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignWithoutCertificate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := NewSigner(key, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	document := []byte(`{"instanceId": "test-uuid"}`)
+	signature, err := signer.Sign(document)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	digest := sha256.Sum256(document)
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+
+	if signer.HasCertificate() {
+		t.Error("expected signer without certificate")
+	}
+	if _, err := signer.PKCS7(document); err == nil {
+		t.Error("expected PKCS7 to fail without a certificate")
+	}
+}
+
+func TestLoadSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	cert := selfSigned(t, key)
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "identity.key")
+	certFile := filepath.Join(dir, "identity.crt")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := LoadSigner(keyFile, certFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !signer.HasCertificate() {
+		t.Error("expected certificate to be loaded")
+	}
+
+	if _, err := LoadSigner(filepath.Join(dir, "missing.key"), ""); err == nil {
+		t.Error("expected error for missing key file")
+	}
+}
@@ -0,0 +1,105 @@
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Object identifiers used in PKCS#7 SignedData structures.
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// contentInfo is the PKCS#7 ContentInfo structure.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// signedData is the PKCS#7 SignedData structure.
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []signerInfo `asn1:"set"`
+}
+
+// issuerAndSerial identifies the certificate of a signer.
+type issuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// signerInfo is the PKCS#7 SignerInfo structure, without authenticated
+// attributes so the signature covers the content directly.
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+// explicit wraps DER encoded bytes in a context-specific [0] tag.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// PKCS7 returns a DER encoded PKCS#7 SignedData structure embedding the
+// document, in the form served by EC2 at instance-identity/pkcs7.
+func (s *Signer) PKCS7(document []byte) ([]byte, error) {
+	if s.cert == nil {
+		return nil, errors.New("no identity certificate configured")
+	}
+
+	signature, err := s.Sign(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign identity document: %w", err)
+	}
+
+	encryptionAlgorithm := pkix.AlgorithmIdentifier{
+		Algorithm:  oidRSAEncryption,
+		Parameters: asn1.NullRawValue,
+	}
+	if _, ok := s.key.(*ecdsa.PrivateKey); ok {
+		encryptionAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA}
+	}
+	digestAlgorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+
+	content, err := asn1.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgorithm},
+		ContentInfo:      contentInfo{ContentType: oidData, Content: explicit(content)},
+		Certificates:     explicit(s.cert.Raw),
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: issuerAndSerial{
+				IssuerName:   asn1.RawValue{FullBytes: s.cert.RawIssuer},
+				SerialNumber: s.cert.SerialNumber,
+			},
+			DigestAlgorithm:           digestAlgorithm,
+			DigestEncryptionAlgorithm: encryptionAlgorithm,
+			EncryptedDigest:           signature,
+		}},
+	}
+
+	inner, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signed data: %w", err)
+	}
+	return asn1.Marshal(contentInfo{ContentType: oidSignedData, Content: explicit(inner)})
+}
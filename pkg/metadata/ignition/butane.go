@@ -0,0 +1,51 @@
+package ignition
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/butane/config"
+	"github.com/coreos/butane/config/common"
+)
+
+// IsButane reports whether data is a Butane config, which is recognized by
+// its top-level variant key.
+func IsButane(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("variant:")) {
+			return true
+		}
+	}
+	return false
+}
+
+// TranslateButane transpiles a Butane config into Ignition JSON with the
+// Butane translator, which supports the variants and versions of the
+// Butane release this module requires. As with butane --strict, a config
+// raising warnings, such as for a field Butane does not define, is
+// rejected, and so are sugar reading local files, which the metadata
+// service has none of, and experimental versions, whose Ignition spec
+// versions no Ignition release accepts.
+func TranslateButane(data []byte) ([]byte, Version, error) {
+	b, r, err := config.TranslateBytes(data, common.TranslateBytesOptions{Raw: true})
+	if err != nil {
+		if len(r.Entries) > 0 {
+			return nil, Version{}, fmt.Errorf("invalid butane config: %w: %s", err,
+				strings.TrimSpace(r.String()))
+		}
+		return nil, Version{}, fmt.Errorf("invalid butane config: %w", err)
+	}
+	if len(r.Entries) > 0 {
+		return nil, Version{}, fmt.Errorf("invalid butane config: %s",
+			strings.TrimSpace(r.String()))
+	}
+
+	v, ok := StoredVersion(b)
+	if !ok {
+		return nil, Version{}, errors.New(
+			"invalid butane config: experimental versions are not supported")
+	}
+	return b, v, nil
+}
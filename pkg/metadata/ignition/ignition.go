@@ -0,0 +1,222 @@
+// Package ignition serves Ignition configs for Fedora CoreOS and RHCOS
+// nodes, either verbatim from stored configs or translated from simple user
+// data.
+package ignition
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MediaType is the content type of Ignition configs.
+const MediaType = "application/vnd.coreos.ignition+json"
+
+// defaultUser is the account that receives SSH keys on CoreOS images.
+const defaultUser = "core"
+
+// userDataScript is where a translated shell script is written and the unit
+// that runs it on first boot.
+const (
+	userDataScript = "/usr/local/bin/ironic-user-data"
+	userDataUnit   = "ironic-user-data.service"
+)
+
+// ErrIncompatible is returned when a config cannot be served in the spec
+// version the client understands.
+var ErrIncompatible = errors.New("incompatible ignition version")
+
+// Config is the subset of the Ignition v3 config schema produced when
+// translating user data.
+type Config struct {
+	Ignition Ignition `json:"ignition"`
+	Passwd   *Passwd  `json:"passwd,omitempty"`
+	Storage  *Storage `json:"storage,omitempty"`
+	Systemd  *Systemd `json:"systemd,omitempty"`
+}
+
+// Ignition holds the config metadata.
+type Ignition struct {
+	Version string `json:"version"`
+}
+
+// Passwd configures users.
+type Passwd struct {
+	Users []User `json:"users,omitempty"`
+}
+
+// User is a local account.
+type User struct {
+	Name              string   `json:"name"`
+	SSHAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty"`
+}
+
+// Storage configures files.
+type Storage struct {
+	Files []File `json:"files,omitempty"`
+}
+
+// File is a file written to the root filesystem.
+type File struct {
+	Path      string       `json:"path"`
+	Mode      *int         `json:"mode,omitempty"`
+	Overwrite *bool        `json:"overwrite,omitempty"`
+	Contents  FileContents `json:"contents"`
+}
+
+// FileContents references the contents of a file.
+type FileContents struct {
+	Source string `json:"source"`
+}
+
+// Systemd configures units.
+type Systemd struct {
+	Units []Unit `json:"units,omitempty"`
+}
+
+// Unit is a systemd unit.
+type Unit struct {
+	Name     string `json:"name"`
+	Enabled  *bool  `json:"enabled,omitempty"`
+	Contents string `json:"contents,omitempty"`
+}
+
+// Params is the node data used to translate user data into a config.
+type Params struct {
+	Hostname string
+	SSHKeys  []string
+	UserData []byte
+}
+
+// StoredVersion returns the spec version declared by a stored config. ok is
+// false when data is not an Ignition config.
+func StoredVersion(data []byte) (v Version, ok bool) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return Version{}, false
+	}
+
+	var doc struct {
+		Ignition *Ignition `json:"ignition"`
+	}
+	if err := json.Unmarshal(trimmed, &doc); err != nil || doc.Ignition == nil {
+		return Version{}, false
+	}
+
+	v, err := ParseVersion(doc.Ignition.Version)
+	if err != nil {
+		return Version{}, false
+	}
+	return v, true
+}
+
+// CheckCompatible verifies a stored config with version stored can be served
+// to a client that accepts version want.
+func CheckCompatible(stored, want Version) error {
+	if stored.Major != want.Major || want.Less(stored) {
+		return fmt.Errorf("%w: stored config version %s is not readable as %s",
+			ErrIncompatible, stored, want)
+	}
+	return nil
+}
+
+// Translate builds a config declaring version v from node data. Shell
+// scripts are installed as a oneshot unit, and the hostname, SSH keys and
+// write_files of a #cloud-config document are carried over.
+func Translate(v Version, params Params) (*Config, error) {
+	cfg := &Config{Ignition: Ignition{Version: v.String()}}
+	hostname := params.Hostname
+	keys := append([]string(nil), params.SSHKeys...)
+	var files []File
+
+	userData := bytes.TrimSpace(params.UserData)
+	switch {
+	case len(userData) == 0:
+	case bytes.HasPrefix(userData, []byte("#!")):
+		files = append(files, newFile(userDataScript, 0o755, params.UserData))
+		enabled := true
+		cfg.Systemd = &Systemd{Units: []Unit{{
+			Name:    userDataUnit,
+			Enabled: &enabled,
+			Contents: "[Unit]\nDescription=Run Ironic user data\n" +
+				"Wants=network-online.target\nAfter=network-online.target\n" +
+				"ConditionFirstBoot=yes\n\n" +
+				"[Service]\nType=oneshot\nExecStart=" + userDataScript + "\n\n" +
+				"[Install]\nWantedBy=multi-user.target\n",
+		}}}
+	case bytes.HasPrefix(userData, []byte("#cloud-config")):
+		var cc cloudConfig
+		if err := yaml.Unmarshal(userData, &cc); err != nil {
+			return nil, fmt.Errorf("failed to parse cloud-config: %w", err)
+		}
+		if cc.Hostname != "" {
+			hostname = cc.Hostname
+		}
+		keys = append(keys, cc.SSHAuthorizedKeys...)
+		for _, wf := range cc.WriteFiles {
+			mode, err := wf.mode()
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, newFile(wf.Path, mode, []byte(wf.Content)))
+		}
+	default:
+		return nil, errors.New("user data cannot be translated to ignition")
+	}
+
+	if hostname != "" {
+		files = append([]File{newFile("/etc/hostname", 0o644, []byte(hostname+"\n"))}, files...)
+	}
+	if len(files) > 0 {
+		cfg.Storage = &Storage{Files: files}
+	}
+	if len(keys) > 0 {
+		cfg.Passwd = &Passwd{Users: []User{{Name: defaultUser, SSHAuthorizedKeys: keys}}}
+	}
+
+	return cfg, nil
+}
+
+// cloudConfig is the subset of #cloud-config that can be translated.
+type cloudConfig struct {
+	Hostname          string      `yaml:"hostname"`
+	SSHAuthorizedKeys []string    `yaml:"ssh_authorized_keys"`
+	WriteFiles        []writeFile `yaml:"write_files"`
+}
+
+// writeFile is a cloud-config write_files entry.
+type writeFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Permissions string `yaml:"permissions"`
+}
+
+// mode parses the octal permissions of a write_files entry.
+func (wf writeFile) mode() (int, error) {
+	if wf.Permissions == "" {
+		return 0o644, nil
+	}
+	var mode int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(wf.Permissions, "0o"), "%o", &mode); err != nil {
+		return 0, fmt.Errorf("invalid permissions %q for %s: %w", wf.Permissions, wf.Path, err)
+	}
+	return mode, nil
+}
+
+// newFile returns a file whose contents are embedded as a data URL.
+func newFile(path string, mode int, contents []byte) File {
+	overwrite := true
+	return File{
+		Path:      path,
+		Mode:      &mode,
+		Overwrite: &overwrite,
+		Contents: FileContents{
+			Source: "data:;base64," + base64.StdEncoding.EncodeToString(contents),
+		},
+	}
+}
@@ -0,0 +1,445 @@
+package ignition
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "3.4.0", want: Version{3, 4, 0}},
+		{input: "v3.2.0", want: Version{3, 2, 0}},
+		{input: "latest", want: Latest},
+		{input: "3.4", wantErr: true},
+		{input: "three", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			have, err := ParseVersion(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error for %q, got %v", tt.input, have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested Version
+		accept    string
+		want      Version
+		wantErr   bool
+	}{
+		{name: "no accept header", requested: Version{3, 4, 0}, want: Version{3, 4, 0}},
+		{
+			name:      "capped by accept header",
+			requested: Version{3, 4, 0},
+			accept:    MediaType + ";version=3.2.0, */*;q=0.1",
+			want:      Version{3, 2, 0},
+		},
+		{
+			name:      "accept header newer than requested",
+			requested: Version{3, 1, 0},
+			accept:    MediaType + ";version=3.4.0",
+			want:      Version{3, 1, 0},
+		},
+		{
+			name:      "unknown patch falls back",
+			requested: Version{3, 6, 0},
+			want:      Version{3, 5, 0},
+		},
+		{name: "spec 2 unsupported", requested: Version{2, 2, 0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := Negotiate(tt.requested, tt.accept)
+			if tt.wantErr {
+				if !errors.Is(err, ErrIncompatible) {
+					t.Errorf("expected ErrIncompatible, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoredVersion(t *testing.T) {
+	v, ok := StoredVersion([]byte(`{"ignition": {"version": "3.3.0"}}`))
+	if !ok || v != (Version{3, 3, 0}) {
+		t.Errorf("expected stored version 3.3.0, got %v (ok=%v)", v, ok)
+	}
+
+	if _, ok := StoredVersion([]byte("#cloud-config\n")); ok {
+		t.Error("expected cloud-config not to be detected as ignition")
+	}
+
+	if err := CheckCompatible(Version{3, 3, 0}, Version{3, 2, 0}); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("expected newer stored config to be incompatible, got %v", err)
+	}
+	if err := CheckCompatible(Version{3, 1, 0}, Version{3, 4, 0}); err != nil {
+		t.Errorf("expected older stored config to be compatible, got %v", err)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name      string
+		userData  string
+		wantFiles []string
+		wantUnit  bool
+		wantKeys  int
+		wantErr   bool
+	}{
+		{
+			name:      "empty user data",
+			wantFiles: []string{"/etc/hostname"},
+			wantKeys:  1,
+		},
+		{
+			name:      "shell script",
+			userData:  "#!/bin/sh\necho hello\n",
+			wantFiles: []string{"/etc/hostname", userDataScript},
+			wantUnit:  true,
+			wantKeys:  1,
+		},
+		{
+			name: "cloud-config",
+			userData: "#cloud-config\nssh_authorized_keys:\n  - ssh-ed25519 BBBB\n" +
+				"write_files:\n  - path: /etc/motd\n    content: hi\n    permissions: '0600'\n",
+			wantFiles: []string{"/etc/hostname", "/etc/motd"},
+			wantKeys:  2,
+		},
+		{name: "unsupported", userData: "Content-Type: multipart/mixed", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Translate(Latest, Params{
+				Hostname: "node-01",
+				SSHKeys:  []string{"ssh-ed25519 AAAA"},
+				UserData: []byte(tt.userData),
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cfg.Ignition.Version != Latest.String() {
+				t.Errorf("expected version %s, got %s", Latest, cfg.Ignition.Version)
+			}
+
+			var files []string
+			for _, f := range cfg.Storage.Files {
+				files = append(files, f.Path)
+			}
+			if strings.Join(files, ",") != strings.Join(tt.wantFiles, ",") {
+				t.Errorf("files\nhave: %v\nwant: %v", files, tt.wantFiles)
+			}
+
+			if (cfg.Systemd != nil) != tt.wantUnit {
+				t.Errorf("expected unit: %v, got %#v", tt.wantUnit, cfg.Systemd)
+			}
+
+			if have := len(cfg.Passwd.Users[0].SSHAuthorizedKeys); have != tt.wantKeys {
+				t.Errorf("expected %d keys, got %d", tt.wantKeys, have)
+			}
+		})
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	f := newFile("/etc/hostname", 0o644, []byte("node-01\n"))
+	want := "data:;base64," + base64.StdEncoding.EncodeToString([]byte("node-01\n"))
+	if f.Contents.Source != want {
+		t.Errorf("source\nhave: %q\nwant: %q", f.Contents.Source, want)
+	}
+	if *f.Mode != 0o644 {
+		t.Errorf("expected mode 0644, got %o", *f.Mode)
+	}
+}
+
+func TestTranslateButane(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{
+			name: "fcos with inline file",
+			input: "variant: fcos\nversion: 1.4.0\n" +
+				"passwd:\n  users:\n    - name: core\n      ssh_authorized_keys:\n        - ssh-ed25519 AAAA\n" +
+				"storage:\n  files:\n    - path: /etc/motd\n      mode: 0644\n      contents:\n        inline: hello\n",
+			want: Version{3, 3, 0},
+		},
+		{name: "flatcar", input: "variant: flatcar\nversion: 1.0.0\n", want: Version{3, 3, 0}},
+		{name: "unknown variant", input: "variant: openshift\nversion: 4.14.0\n", wantErr: true},
+		{name: "unknown version", input: "variant: fcos\nversion: 9.9.9\n", wantErr: true},
+		{
+			name:    "local contents",
+			input:   "variant: fcos\nversion: 1.4.0\nstorage:\n  files:\n    - path: /a\n      contents:\n        local: a\n",
+			wantErr: true,
+		},
+		{name: "newer than the translator", input: "variant: fcos\nversion: 1.6.0\n", wantErr: true},
+		{
+			name:    "experimental",
+			input:   "variant: fcos\nversion: 1.5.0-experimental\n",
+			wantErr: true,
+		},
+		{
+			name: "boot device mirror",
+			input: "variant: fcos\nversion: 1.4.0\n" +
+				"boot_device:\n  mirror:\n    devices:\n      - /dev/sda\n      - /dev/sdb\n",
+			want: Version{3, 3, 0},
+		},
+		{
+			name: "local ssh keys",
+			input: "variant: fcos\nversion: 1.4.0\n" +
+				"passwd:\n  users:\n    - name: core\n" +
+				"      ssh_authorized_keys_local:\n        - id.pub\n",
+			wantErr: true,
+		},
+		{
+			name: "local unit contents",
+			input: "variant: fcos\nversion: 1.4.0\n" +
+				"systemd:\n  units:\n    - name: a.service\n      contents_local: a.service\n",
+			wantErr: true,
+		},
+		{
+			name:    "trees",
+			input:   "variant: fcos\nversion: 1.4.0\nstorage:\n  trees:\n    - local: etc\n",
+			wantErr: true,
+		},
+		{
+			name: "unknown field",
+			input: "variant: fcos\nversion: 1.4.0\n" +
+				"storage:\n  files:\n    - path: /a\n      contnets:\n        inline: a\n",
+			wantErr: true,
+		},
+		{
+			name: "field newer than the version",
+			input: "variant: fcos\nversion: 1.2.0\n" +
+				"kernel_arguments:\n  should_exist:\n    - quiet\n",
+			wantErr: true,
+		},
+		{
+			name:    "object instead of a list",
+			input:   "variant: fcos\nversion: 1.4.0\nstorage:\n  files:\n    path: /a\n",
+			wantErr: true,
+		},
+		{
+			name: "source and inline",
+			input: "variant: fcos\nversion: 1.4.0\nstorage:\n  files:\n    - path: /a\n" +
+				"      contents:\n        source: https://example.com/a\n        inline: a\n",
+			wantErr: true,
+		},
+		{
+			name: "mount unit without path",
+			input: "variant: fcos\nversion: 1.4.0\nstorage:\n  filesystems:\n" +
+				"    - device: /dev/vdb\n      format: xfs\n      with_mount_unit: true\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsButane([]byte(tt.input)) {
+				t.Fatal("expected input to be detected as butane")
+			}
+
+			b, v, err := TranslateButane([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", b)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if v != tt.want {
+				t.Errorf("have version %v, want %v", v, tt.want)
+			}
+
+			stored, ok := StoredVersion(b)
+			if !ok || stored != tt.want {
+				t.Errorf("expected transpiled config to declare %v, got %v (ok=%v)", tt.want, stored, ok)
+			}
+			if strings.Contains(string(b), "variant") || strings.Contains(string(b), "inline") ||
+				strings.Contains(string(b), "ssh_authorized_keys") {
+				t.Errorf("expected butane sugar to be removed, got %s", b)
+			}
+		})
+	}
+}
+
+func TestTranslateButane_fields(t *testing.T) {
+	input := "variant: fcos\nversion: 1.4.0\n" +
+		"storage:\n  disks:\n    - device: /dev/vdb\n      wipe_table: true\n" +
+		"      partitions:\n        - label: data\n" +
+		"          size_mib: 1024\n          start_mib: 0\n" +
+		"  files:\n    - path: /etc/motd\n      mode: 0644\n" +
+		"      contents:\n        inline: hello\n"
+
+	b, _, err := TranslateButane([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg struct {
+		Storage struct {
+			Disks []struct {
+				WipeTable  bool `json:"wipeTable"`
+				Partitions []struct {
+					Label    string `json:"label"`
+					SizeMiB  *int   `json:"sizeMiB"`
+					StartMiB *int   `json:"startMiB"`
+				} `json:"partitions"`
+			} `json:"disks"`
+			Files []struct {
+				Mode     int `json:"mode"`
+				Contents struct {
+					Source string `json:"source"`
+				} `json:"contents"`
+			} `json:"files"`
+		} `json:"storage"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Storage.Disks) != 1 || !cfg.Storage.Disks[0].WipeTable ||
+		len(cfg.Storage.Disks[0].Partitions) != 1 {
+		t.Fatalf("have disks %+v, want the disk of the config", cfg.Storage.Disks)
+	}
+	partition := cfg.Storage.Disks[0].Partitions[0]
+	if partition.SizeMiB == nil || *partition.SizeMiB != 1024 ||
+		partition.StartMiB == nil || *partition.StartMiB != 0 {
+		t.Errorf("have partition %+v, want sizeMiB 1024 and startMiB 0", partition)
+	}
+	if len(cfg.Storage.Files) != 1 || cfg.Storage.Files[0].Mode != 0o644 {
+		t.Fatalf("have files %+v, want /etc/motd with mode 0644", cfg.Storage.Files)
+	}
+	want := "data:,hello"
+	if have := cfg.Storage.Files[0].Contents.Source; have != want {
+		t.Errorf("have source %q, want %q", have, want)
+	}
+}
+
+func TestTranslateButane_mountUnits(t *testing.T) {
+	input := "variant: fcos\nversion: 1.4.0\n" +
+		"storage:\n" +
+		"  luks:\n    - name: secret\n      device: /dev/vdd\n" +
+		"      clevis:\n        tang:\n          - url: https://tang.example.com\n" +
+		"            thumbprint: abc\n" +
+		"  filesystems:\n" +
+		"    - device: /dev/disk/by-label/var\n      format: xfs\n" +
+		"      path: /var/lib/containers\n" +
+		"      mount_options:\n        - noatime\n        - nodev\n      with_mount_unit: true\n" +
+		"    - device: /dev/vdc\n      format: swap\n      with_mount_unit: true\n" +
+		"    - device: /dev/mapper/secret\n      format: ext4\n      path: /srv\n" +
+		"      with_mount_unit: true\n" +
+		"    - device: /dev/vde\n      format: ext4\n      path: /data\n" +
+		"      with_mount_unit: false\n" +
+		"systemd:\n  units:\n    - name: srv.mount\n      enabled: false\n"
+
+	b, _, err := TranslateButane([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(b), "with_mount_unit") {
+		t.Errorf("expected with_mount_unit to be removed, got %s", b)
+	}
+	var cfg struct {
+		Systemd struct {
+			Units []struct {
+				Name     string `json:"name"`
+				Enabled  bool   `json:"enabled"`
+				Contents string `json:"contents"`
+			} `json:"units"`
+		} `json:"systemd"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		contents []string
+	}{
+		{
+			name:    "var-lib-containers.mount",
+			enabled: true,
+			contents: []string{
+				"# Generated by Butane\n[Unit]\nBefore=local-fs.target\n",
+				"Requires=systemd-fsck@dev-disk-by\\x2dlabel-var.service\n",
+				"[Mount]\nWhere=/var/lib/containers\nWhat=/dev/disk/by-label/var\nType=xfs\n" +
+					"Options=noatime,nodev\n",
+				"[Install]\nRequiredBy=local-fs.target",
+			},
+		},
+		{
+			name:    "dev-vdc.swap",
+			enabled: true,
+			contents: []string{
+				"[Swap]\nWhat=/dev/vdc\n\n[Install]\nRequiredBy=swap.target",
+			},
+		},
+		{
+			name:    "srv.mount",
+			enabled: false,
+			contents: []string{
+				"Before=remote-fs.target\nDefaultDependencies=no\n",
+				"RequiredBy=remote-fs.target",
+			},
+		},
+	}
+	if len(cfg.Systemd.Units) != len(tests) {
+		t.Fatalf("have units %+v, want %d", cfg.Systemd.Units, len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit := cfg.Systemd.Units[i]
+			if unit.Name != tt.name {
+				t.Fatalf("have unit %s, want %s", unit.Name, tt.name)
+			}
+			if unit.Enabled != tt.enabled {
+				t.Errorf("have enabled %v, want %v", unit.Enabled, tt.enabled)
+			}
+			for _, want := range tt.contents {
+				if !strings.Contains(unit.Contents, want) {
+					t.Errorf("expected contents to contain %q, got %q", want, unit.Contents)
+				}
+			}
+		})
+	}
+}
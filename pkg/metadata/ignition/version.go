@@ -0,0 +1,123 @@
+package ignition
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Version is an Ignition config specification version.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// SupportedVersions lists the spec versions that generated configs can be
+// declared as, in ascending order.
+var SupportedVersions = []Version{
+	{Major: 3, Minor: 0, Patch: 0},
+	{Major: 3, Minor: 1, Patch: 0},
+	{Major: 3, Minor: 2, Patch: 0},
+	{Major: 3, Minor: 3, Patch: 0},
+	{Major: 3, Minor: 4, Patch: 0},
+	{Major: 3, Minor: 5, Patch: 0},
+}
+
+// Latest is the newest supported spec version.
+var Latest = SupportedVersions[len(SupportedVersions)-1]
+
+// ParseVersion parses a spec version such as "3.4.0" or "v3.4.0". The string
+// "latest" resolves to the newest supported version.
+func ParseVersion(s string) (Version, error) {
+	if s == "latest" {
+		return Latest, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid ignition version %q", s)
+	}
+
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid ignition version %q", s)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// String returns the version in dotted form.
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v sorts before o.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// Supported reports whether v is one of the supported spec versions.
+func (v Version) Supported() bool {
+	for _, s := range SupportedVersions {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate picks the spec version to serve for a request asking for the
+// given version. Ignition advertises the newest spec it understands in the
+// Accept header; when present, the result is capped to that version.
+func Negotiate(requested Version, accept string) (Version, error) {
+	if advertised, ok := acceptedVersion(accept); ok && advertised.Less(requested) {
+		requested = advertised
+	}
+
+	if requested.Supported() {
+		return requested, nil
+	}
+
+	// Fall back to the newest supported version the client can still read.
+	for i := len(SupportedVersions) - 1; i >= 0; i-- {
+		candidate := SupportedVersions[i]
+		if candidate.Major == requested.Major && candidate.Less(requested) {
+			return candidate, nil
+		}
+	}
+	return Version{}, fmt.Errorf("%w: version %s is not supported", ErrIncompatible, requested)
+}
+
+// acceptedVersion extracts the highest Ignition spec version listed in an
+// Accept header.
+func acceptedVersion(accept string) (Version, bool) {
+	var (
+		best  Version
+		found bool
+	)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != MediaType {
+			continue
+		}
+		v, err := ParseVersion(params["version"])
+		if err != nil {
+			continue
+		}
+		if !found || best.Less(v) {
+			best, found = v, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PublicKey is an OpenSSH public key, as written in authorized_keys.
+type PublicKey struct {
+	// Algorithm is the kind of key, such as rsa, ed25519, ecdsa or
+	// ed25519-sk.
+	Algorithm string
+
+	// Type is the key type named in the key, such as ssh-ed25519.
+	Type string
+
+	// Blob is the base64 encoded key.
+	Blob string
+
+	// Comment is the text following the key, usually naming its owner.
+	Comment string
+
+	// Line is the key as given, including any options preceding it.
+	Line string
+}
+
+// keyAlgorithms maps OpenSSH key types to the algorithm of their keys.
+var keyAlgorithms = map[string]string{
+	"ssh-rsa":                            "rsa",
+	"ssh-dss":                            "dsa",
+	"ssh-ed25519":                        "ed25519",
+	"ecdsa-sha2-nistp256":                "ecdsa",
+	"ecdsa-sha2-nistp384":                "ecdsa",
+	"ecdsa-sha2-nistp521":                "ecdsa",
+	"sk-ssh-ed25519@openssh.com":         "ed25519-sk",
+	"sk-ecdsa-sha2-nistp256@openssh.com": "ecdsa-sk",
+}
+
+// ParsePublicKey parses a line of authorized_keys. Options may precede the
+// key type, which must be one OpenSSH knows, and the key must be base64
+// encoded. Certificates are parsed as the keys they certify.
+func ParsePublicKey(line string) (PublicKey, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return PublicKey{}, errors.New("empty key")
+	}
+
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		keyType := strings.Replace(field, "-cert-v01@openssh.com", "", 1)
+		algorithm, ok := keyAlgorithms[keyType]
+		if !ok {
+			continue
+		}
+		if i+1 >= len(fields) {
+			return PublicKey{}, fmt.Errorf("%s key has no key data", field)
+		}
+		blob := fields[i+1]
+		if _, err := base64.StdEncoding.DecodeString(blob); err != nil {
+			return PublicKey{}, fmt.Errorf("%s key data is not base64 encoded: %w", field, err)
+		}
+		return PublicKey{
+			Algorithm: algorithm,
+			Type:      field,
+			Blob:      blob,
+			Comment:   strings.Join(fields[i+2:], " "),
+			Line:      line,
+		}, nil
+	}
+	return PublicKey{}, fmt.Errorf("unknown key type in %q", truncateKey(line))
+}
+
+// truncateKey shortens a key for error messages.
+func truncateKey(line string) string {
+	const keep = 32
+	if len(line) <= keep {
+		return line
+	}
+	return line[:keep] + "..."
+}
@@ -0,0 +1,77 @@
+package metadata
+
+import "testing"
+
+func TestParsePublicKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    string
+		want    PublicKey
+		wantErr bool
+	}{
+		{
+			name: "ed25519",
+			have: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 alice@example.com\n",
+			want: PublicKey{
+				Algorithm: "ed25519",
+				Type:      "ssh-ed25519",
+				Blob:      "AAAAC3NzaC1lZDI1NTE5",
+				Comment:   "alice@example.com",
+				Line:      "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 alice@example.com",
+			},
+		},
+		{
+			name: "options",
+			have: `no-pty,from="10.0.0.0/8" ssh-rsa AAAAB3NzaC1yc2E=`,
+			want: PublicKey{
+				Algorithm: "rsa",
+				Type:      "ssh-rsa",
+				Blob:      "AAAAB3NzaC1yc2E=",
+				Line:      `no-pty,from="10.0.0.0/8" ssh-rsa AAAAB3NzaC1yc2E=`,
+			},
+		},
+		{
+			name: "certificate",
+			have: "ecdsa-sha2-nistp256-cert-v01@openssh.com AAAA host",
+			want: PublicKey{
+				Algorithm: "ecdsa",
+				Type:      "ecdsa-sha2-nistp256-cert-v01@openssh.com",
+				Blob:      "AAAA",
+				Comment:   "host",
+				Line:      "ecdsa-sha2-nistp256-cert-v01@openssh.com AAAA host",
+			},
+		},
+		{
+			name: "security key",
+			have: "sk-ssh-ed25519@openssh.com AAAA",
+			want: PublicKey{
+				Algorithm: "ed25519-sk",
+				Type:      "sk-ssh-ed25519@openssh.com",
+				Blob:      "AAAA",
+				Line:      "sk-ssh-ed25519@openssh.com AAAA",
+			},
+		},
+		{name: "empty", have: " ", wantErr: true},
+		{name: "unknown type", have: "ssh-foo AAAA", wantErr: true},
+		{name: "missing data", have: "ssh-ed25519", wantErr: true},
+		{name: "invalid data", have: "ssh-rsa AAAAB3NzaC1yc2E...", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := ParsePublicKey(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, have %+v", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %+v, want %+v", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package netconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+)
+
+// ENI converts network_data into /etc/network/interfaces syntax as
+// described in interfaces(5), for images that still use ifupdown.
+func ENI(nd *metadata.NetworkData) string {
+	var b strings.Builder
+	grouped := networksByLink(nd)
+	servers := nameservers(nd)
+
+	// Bond members are brought up by their bond rather than configured
+	// directly.
+	bondMaster := make(map[string]string)
+	for _, link := range nd.Links {
+		if link.Type == linkTypeBond {
+			for _, member := range link.BondLinks {
+				bondMaster[member] = link.ID
+			}
+		}
+	}
+
+	b.WriteString("auto lo\niface lo inet loopback\n")
+
+	for _, link := range nd.Links {
+		var options []string
+		if link.MTU > 0 {
+			options = append(options, fmt.Sprintf("mtu %d", link.MTU))
+		}
+
+		switch link.Type {
+		case linkTypeBond:
+			options = append(options, "bond-slaves none")
+			if link.BondMode != "" {
+				options = append(options, "bond-mode "+link.BondMode)
+			}
+			if link.BondMIIMon != nil {
+				options = append(options, fmt.Sprintf("bond-miimon %d", *link.BondMIIMon))
+			}
+			if link.BondHashPolicy != "" {
+				options = append(options, "bond-xmit-hash-policy "+link.BondHashPolicy)
+			}
+			if link.EthernetMacAddress != "" {
+				options = append(options, "hwaddress ether "+link.EthernetMacAddress)
+			}
+		case linkTypeVlan:
+			options = append(options, "vlan-raw-device "+link.VlanLink)
+			if link.VlanMacAddress != "" {
+				options = append(options, "hwaddress ether "+link.VlanMacAddress)
+			}
+		}
+
+		if master, ok := bondMaster[link.ID]; ok {
+			options = append(options, "bond-master "+master)
+		}
+
+		fmt.Fprintf(&b, "\nauto %s\n", link.ID)
+
+		networks := grouped[link.ID]
+		if len(networks) == 0 {
+			writeENIStanza(&b, link.ID, "inet", "manual", options)
+			continue
+		}
+
+		for i, network := range networks {
+			family, method, netOptions := eniNetwork(network, servers)
+			// Link level options only need to be set once.
+			if i == 0 {
+				netOptions = append(options, netOptions...)
+			}
+			writeENIStanza(&b, link.ID, family, method, netOptions)
+		}
+	}
+
+	return b.String()
+}
+
+// eniNetwork returns the address family, method and options of the stanza
+// configuring a single network.
+func eniNetwork(network metadata.Network, servers []string) (family, method string, options []string) {
+	family = "inet"
+	if isIPv6(network) {
+		family = "inet6"
+	}
+
+	switch {
+	case isSLAAC(network):
+		return family, "auto", nil
+	case isDHCP(network):
+		return family, "dhcp", nil
+	}
+
+	options = append(options, "address "+cidr(network.Address, network.Netmask))
+	if network.Gateway != "" {
+		options = append(options, "gateway "+network.Gateway)
+	}
+	if len(servers) > 0 {
+		options = append(options, "dns-nameservers "+strings.Join(servers, " "))
+	}
+	for _, route := range network.Routes {
+		dest := cidr(route.Network, route.Netmask)
+		cmd := fmt.Sprintf("route add %s via %s", dest, route.Gateway)
+		if route.Metric > 0 {
+			cmd += fmt.Sprintf(" metric %d", route.Metric)
+		}
+		flag := "-4"
+		if family == "inet6" {
+			flag = "-6"
+		}
+		options = append(options,
+			fmt.Sprintf("post-up ip %s %s || true", flag, cmd),
+			fmt.Sprintf("pre-down ip %s route del %s via %s || true", flag, dest, route.Gateway),
+		)
+	}
+
+	return family, "static", options
+}
+
+// writeENIStanza writes a single iface stanza.
+func writeENIStanza(b *strings.Builder, name, family, method string, options []string) {
+	fmt.Fprintf(b, "iface %s %s %s\n", name, family, method)
+	for _, option := range options {
+		fmt.Fprintf(b, "    %s\n", option)
+	}
+}
+
+// MarshalENI renders network_data as an /etc/network/interfaces file.
+func MarshalENI(nd *metadata.NetworkData) ([]byte, error) {
+	return []byte(ENI(nd)), nil
+}
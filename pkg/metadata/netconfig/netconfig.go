@@ -0,0 +1,149 @@
+// Package netconfig renders OpenStack network_data into the network
+// configuration formats consumed by cloud-init and the operating systems it
+// supports.
+package netconfig
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+)
+
+// Link types used by network_data that need special handling. Every other
+// link type is treated as a physical interface.
+const (
+	linkTypeBond = "bond"
+	linkTypeVlan = "vlan"
+)
+
+// serviceTypeDNS is the network_data service type carrying a nameserver.
+const serviceTypeDNS = "dns"
+
+// Output formats accepted by Render.
+const (
+	FormatV1      = "v1"
+	FormatV2      = "v2"
+	FormatNetplan = "netplan"
+	FormatENI     = "eni"
+)
+
+// Formats lists the output formats accepted by Render.
+var Formats = []string{FormatV1, FormatV2, FormatNetplan, FormatENI}
+
+// Render renders network_data in the named output format.
+func Render(format string, nd *metadata.NetworkData) ([]byte, error) {
+	switch format {
+	case FormatV1:
+		return MarshalV1(nd)
+	case FormatV2:
+		return MarshalV2(nd)
+	case FormatNetplan:
+		return MarshalNetplan(nd)
+	case FormatENI:
+		return MarshalENI(nd)
+	}
+	return nil, fmt.Errorf("unknown network config format %q", format)
+}
+
+// isDHCP reports whether a network_data network type is configured via DHCP.
+// A static network without an address is treated as DHCP as well, since
+// that is what the synthetic fallback network data produces.
+func isDHCP(network metadata.Network) bool {
+	switch network.Type {
+	case "ipv4_dhcp", "ipv6_dhcp", "ipv6_dhcpv6-stateful", "ipv6_dhcpv6-stateless":
+		return true
+	case "ipv4", "ipv6":
+		return network.Address == ""
+	}
+	return false
+}
+
+// isIPv6 reports whether a network_data network type is an IPv6 network.
+func isIPv6(network metadata.Network) bool {
+	return strings.HasPrefix(network.Type, "ipv6")
+}
+
+// isSLAAC reports whether a network_data network relies on router
+// advertisements for its address.
+func isSLAAC(network metadata.Network) bool {
+	return network.Type == "ipv6_slaac" || network.Type == "ipv6_dhcpv6-stateless"
+}
+
+// prefixLength converts a dotted or colon-separated netmask into a prefix
+// length. Netmasks that are already prefix lengths are returned as-is.
+func prefixLength(netmask string) (int, bool) {
+	if netmask == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(netmask); err == nil {
+		return n, true
+	}
+	ip := net.ParseIP(netmask)
+	if ip == nil {
+		return 0, false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	ones, bits := net.IPMask(ip).Size()
+	if bits == 0 {
+		return 0, false
+	}
+	return ones, true
+}
+
+// cidr joins an address and netmask into CIDR notation. Addresses already in
+// CIDR notation, or without a usable netmask, are returned unchanged.
+func cidr(address, netmask string) string {
+	if strings.Contains(address, "/") {
+		return address
+	}
+	if prefix, ok := prefixLength(netmask); ok {
+		return address + "/" + strconv.Itoa(prefix)
+	}
+	return address
+}
+
+// defaultRoute returns the default destination for the address family of a
+// network.
+func defaultRoute(network metadata.Network) string {
+	if isIPv6(network) {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// nameservers collects the DNS services of a network_data document, starting
+// with the global services and followed by those scoped to a network.
+func nameservers(nd *metadata.NetworkData) []string {
+	var servers []string
+	seen := make(map[string]bool)
+	add := func(services []metadata.Service) {
+		for _, svc := range services {
+			if svc.Type != serviceTypeDNS || svc.Address == "" || seen[svc.Address] {
+				continue
+			}
+			seen[svc.Address] = true
+			servers = append(servers, svc.Address)
+		}
+	}
+
+	add(nd.Services)
+	for _, network := range nd.Networks {
+		add(network.Services)
+	}
+	return servers
+}
+
+// networksByLink groups the networks of a network_data document by the link
+// they are attached to.
+func networksByLink(nd *metadata.NetworkData) map[string][]metadata.Network {
+	grouped := make(map[string][]metadata.Network)
+	for _, network := range nd.Networks {
+		grouped[network.Link] = append(grouped[network.Link], network)
+	}
+	return grouped
+}
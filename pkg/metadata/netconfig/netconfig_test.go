@@ -0,0 +1,211 @@
+package netconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+)
+
+// testNetworkData returns network data with a bond, a VLAN and a mix of
+// static and DHCP networks.
+func testNetworkData() *metadata.NetworkData {
+	miimon := uint32(100)
+	return &metadata.NetworkData{
+		Links: []metadata.Link{
+			{ID: "eth0", Type: "phy", EthernetMacAddress: "52:54:00:00:00:01", MTU: 9000},
+			{ID: "eth1", Type: "phy", EthernetMacAddress: "52:54:00:00:00:02", MTU: 9000},
+			{
+				ID:                 "bond0",
+				Type:               "bond",
+				EthernetMacAddress: "52:54:00:00:00:01",
+				BondLinks:          []string{"eth0", "eth1"},
+				BondMode:           "802.3ad",
+				BondMIIMon:         &miimon,
+			},
+			{ID: "vlan100", Type: "vlan", VlanLink: "bond0", VlanID: 100},
+		},
+		Networks: []metadata.Network{
+			{
+				ID:      "network0",
+				Link:    "bond0",
+				Type:    "ipv4",
+				Address: "10.0.0.5",
+				Netmask: "255.255.255.0",
+				Gateway: "10.0.0.1",
+				Routes: []metadata.Route{
+					{Network: "192.168.0.0", Netmask: "255.255.0.0", Gateway: "10.0.0.254"},
+				},
+			},
+			{ID: "network1", Link: "vlan100", Type: "ipv4_dhcp"},
+		},
+		Services: []metadata.Service{
+			{Type: "dns", Address: "10.0.0.2"},
+		},
+	}
+}
+
+func TestPrefixLength(t *testing.T) {
+	tests := []struct {
+		netmask string
+		want    int
+		wantOK  bool
+	}{
+		{netmask: "255.255.255.0", want: 24, wantOK: true},
+		{netmask: "255.255.0.0", want: 16, wantOK: true},
+		{netmask: "ffff:ffff:ffff:ffff::", want: 64, wantOK: true},
+		{netmask: "64", want: 64, wantOK: true},
+		{netmask: "", wantOK: false},
+		{netmask: "garbage", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.netmask, func(t *testing.T) {
+			have, ok := prefixLength(tt.netmask)
+			if ok != tt.wantOK || have != tt.want {
+				t.Errorf("prefixLength(%q)\nhave: %d, %v\nwant: %d, %v",
+					tt.netmask, have, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestV1(t *testing.T) {
+	cfg := V1(testNetworkData())
+
+	if cfg.Version != 1 {
+		t.Fatalf("expected version 1, got %d", cfg.Version)
+	}
+	if len(cfg.Config) != 5 {
+		t.Fatalf("expected 5 config entries, got %d", len(cfg.Config))
+	}
+
+	bond := cfg.Config[2]
+	if bond.Type != "bond" || !reflect.DeepEqual(bond.BondInterfaces, []string{"eth0", "eth1"}) {
+		t.Errorf("unexpected bond entry: %#v", bond)
+	}
+	if bond.Params["bond-mode"] != "802.3ad" {
+		t.Errorf("expected bond-mode 802.3ad, got %v", bond.Params["bond-mode"])
+	}
+	if len(bond.Subnets) != 1 || bond.Subnets[0].Type != "static" {
+		t.Fatalf("expected one static subnet on bond, got %#v", bond.Subnets)
+	}
+	if have := bond.Subnets[0].Routes[0].Network; have != "192.168.0.0" {
+		t.Errorf("expected route network 192.168.0.0, got %s", have)
+	}
+
+	vlan := cfg.Config[3]
+	if vlan.Type != "vlan" || vlan.VlanID != 100 || vlan.VlanLink != "bond0" {
+		t.Errorf("unexpected vlan entry: %#v", vlan)
+	}
+	if len(vlan.Subnets) != 1 || vlan.Subnets[0].Type != "dhcp4" {
+		t.Errorf("expected dhcp4 subnet on vlan, got %#v", vlan.Subnets)
+	}
+
+	ns := cfg.Config[4]
+	if ns.Type != "nameserver" || !reflect.DeepEqual(ns.Address, []string{"10.0.0.2"}) {
+		t.Errorf("unexpected nameserver entry: %#v", ns)
+	}
+}
+
+func TestV2(t *testing.T) {
+	cfg := V2(testNetworkData())
+
+	if cfg.Version != 2 {
+		t.Fatalf("expected version 2, got %d", cfg.Version)
+	}
+
+	eth0, ok := cfg.Ethernets["eth0"]
+	if !ok {
+		t.Fatal("expected eth0 in ethernets")
+	}
+	if eth0.Match == nil || eth0.Match.MACAddress != "52:54:00:00:00:01" || eth0.SetName != "eth0" {
+		t.Errorf("unexpected eth0 match: %#v", eth0)
+	}
+
+	bond, ok := cfg.Bonds["bond0"]
+	if !ok {
+		t.Fatal("expected bond0 in bonds")
+	}
+	if !reflect.DeepEqual(bond.Addresses, []string{"10.0.0.5/24"}) {
+		t.Errorf("unexpected bond addresses: %v", bond.Addresses)
+	}
+	wantRoutes := []V2Route{
+		{To: "0.0.0.0/0", Via: "10.0.0.1"},
+		{To: "192.168.0.0/16", Via: "10.0.0.254"},
+	}
+	if !reflect.DeepEqual(bond.Routes, wantRoutes) {
+		t.Errorf("unexpected bond routes\nhave: %#v\nwant: %#v", bond.Routes, wantRoutes)
+	}
+	if bond.Nameservers == nil || bond.Nameservers.Addresses[0] != "10.0.0.2" {
+		t.Errorf("expected nameservers on bond, got %#v", bond.Nameservers)
+	}
+	if bond.Parameters == nil || bond.Parameters.Mode != "802.3ad" {
+		t.Errorf("unexpected bond parameters: %#v", bond.Parameters)
+	}
+
+	vlan, ok := cfg.Vlans["vlan100"]
+	if !ok {
+		t.Fatal("expected vlan100 in vlans")
+	}
+	if vlan.ID != 100 || vlan.Link != "bond0" || !vlan.DHCP4 {
+		t.Errorf("unexpected vlan: %#v", vlan)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		marshal func(*metadata.NetworkData) ([]byte, error)
+		want    string
+	}{
+		{name: "v1", marshal: MarshalV1, want: "network:\n  version: 1\n"},
+		{name: "v2", marshal: MarshalV2, want: "network:\n  version: 2\n"},
+		{name: "netplan", marshal: MarshalNetplan, want: "network:\n  version: 2\n  renderer: networkd\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := tt.marshal(testNetworkData())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(string(have), tt.want) {
+				t.Errorf("unexpected document header\nhave: %q\nwant prefix: %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			if _, err := Render(format, testNetworkData()); err != nil {
+				t.Errorf("unexpected error rendering %s: %v", format, err)
+			}
+		})
+	}
+
+	if _, err := Render("ifcfg", testNetworkData()); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestENI(t *testing.T) {
+	have := ENI(testNetworkData())
+
+	wants := []string{
+		"auto lo\niface lo inet loopback\n",
+		"auto eth0\niface eth0 inet manual\n    mtu 9000\n    bond-master bond0\n",
+		"auto bond0\niface bond0 inet static\n    bond-slaves none\n    bond-mode 802.3ad\n    bond-miimon 100\n",
+		"    address 10.0.0.5/24\n    gateway 10.0.0.1\n    dns-nameservers 10.0.0.2\n",
+		"    post-up ip -4 route add 192.168.0.0/16 via 10.0.0.254 || true\n",
+		"auto vlan100\niface vlan100 inet dhcp\n    vlan-raw-device bond0\n",
+	}
+	for _, want := range wants {
+		if !strings.Contains(have, want) {
+			t.Errorf("expected output to contain %q\nhave:\n%s", want, have)
+		}
+	}
+}
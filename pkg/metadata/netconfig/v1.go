@@ -0,0 +1,146 @@
+package netconfig
+
+import (
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"gopkg.in/yaml.v2"
+)
+
+// V1Config is a cloud-init network configuration version 1 document.
+type V1Config struct {
+	Version int       `yaml:"version"`
+	Config  []V1Entry `yaml:"config"`
+}
+
+// V1Entry is a single entry of a version 1 configuration. Only the fields
+// relevant to the entry type are populated.
+type V1Entry struct {
+	Type           string         `yaml:"type"`
+	Name           string         `yaml:"name,omitempty"`
+	MacAddress     string         `yaml:"mac_address,omitempty"`
+	MTU            int            `yaml:"mtu,omitempty"`
+	BondInterfaces []string       `yaml:"bond_interfaces,omitempty"`
+	Params         map[string]any `yaml:"params,omitempty"`
+	VlanLink       string         `yaml:"vlan_link,omitempty"`
+	VlanID         int            `yaml:"vlan_id,omitempty"`
+	Address        []string       `yaml:"address,omitempty"`
+	Subnets        []V1Subnet     `yaml:"subnets,omitempty"`
+}
+
+// V1Subnet is a subnet attached to a version 1 interface entry.
+type V1Subnet struct {
+	Type           string    `yaml:"type"`
+	Address        string    `yaml:"address,omitempty"`
+	Netmask        string    `yaml:"netmask,omitempty"`
+	Gateway        string    `yaml:"gateway,omitempty"`
+	DNSNameservers []string  `yaml:"dns_nameservers,omitempty"`
+	Routes         []V1Route `yaml:"routes,omitempty"`
+}
+
+// V1Route is a static route of a version 1 subnet.
+type V1Route struct {
+	Network string `yaml:"network"`
+	Netmask string `yaml:"netmask,omitempty"`
+	Gateway string `yaml:"gateway"`
+	Metric  int    `yaml:"metric,omitempty"`
+}
+
+// V1 converts network_data into a cloud-init version 1 configuration.
+func V1(nd *metadata.NetworkData) *V1Config {
+	cfg := &V1Config{Version: 1, Config: []V1Entry{}}
+	grouped := networksByLink(nd)
+
+	for _, link := range nd.Links {
+		entry := V1Entry{
+			Type:       "physical",
+			Name:       link.ID,
+			MacAddress: link.EthernetMacAddress,
+			MTU:        link.MTU,
+		}
+
+		switch link.Type {
+		case linkTypeBond:
+			entry.Type = "bond"
+			entry.BondInterfaces = link.BondLinks
+			entry.Params = map[string]any{}
+			if link.BondMode != "" {
+				entry.Params["bond-mode"] = link.BondMode
+			}
+			if link.BondMIIMon != nil {
+				entry.Params["bond-miimon"] = *link.BondMIIMon
+			}
+			if link.BondHashPolicy != "" {
+				entry.Params["bond-xmit-hash-policy"] = link.BondHashPolicy
+			}
+		case linkTypeVlan:
+			entry.Type = "vlan"
+			entry.VlanLink = link.VlanLink
+			entry.VlanID = link.VlanID
+			if link.VlanMacAddress != "" {
+				entry.MacAddress = link.VlanMacAddress
+			}
+		}
+
+		for _, network := range grouped[link.ID] {
+			entry.Subnets = append(entry.Subnets, v1Subnet(network))
+		}
+
+		cfg.Config = append(cfg.Config, entry)
+	}
+
+	if servers := nameservers(nd); len(servers) > 0 {
+		cfg.Config = append(cfg.Config, V1Entry{
+			Type:    "nameserver",
+			Address: servers,
+		})
+	}
+
+	return cfg
+}
+
+// v1Subnet converts a single network_data network into a version 1 subnet.
+func v1Subnet(network metadata.Network) V1Subnet {
+	switch {
+	case isSLAAC(network):
+		return V1Subnet{Type: "ipv6_slaac"}
+	case isDHCP(network) && isIPv6(network):
+		return V1Subnet{Type: "dhcp6"}
+	case isDHCP(network):
+		return V1Subnet{Type: "dhcp4"}
+	}
+
+	subnet := V1Subnet{
+		Type:    "static",
+		Address: network.Address,
+		Netmask: network.Netmask,
+		Gateway: network.Gateway,
+	}
+	if isIPv6(network) {
+		subnet.Type = "static6"
+		subnet.Address = cidr(network.Address, network.Netmask)
+		subnet.Netmask = ""
+	}
+
+	for _, svc := range network.Services {
+		if svc.Type == serviceTypeDNS {
+			subnet.DNSNameservers = append(subnet.DNSNameservers, svc.Address)
+		}
+	}
+
+	for _, route := range network.Routes {
+		subnet.Routes = append(subnet.Routes, V1Route{
+			Network: route.Network,
+			Netmask: route.Netmask,
+			Gateway: route.Gateway,
+			Metric:  route.Metric,
+		})
+	}
+
+	return subnet
+}
+
+// MarshalV1 renders network_data as a version 1 network-config YAML document.
+func MarshalV1(nd *metadata.NetworkData) ([]byte, error) {
+	return yaml.Marshal(struct {
+		Network *V1Config `yaml:"network"`
+	}{Network: V1(nd)})
+}
@@ -0,0 +1,186 @@
+package netconfig
+
+import (
+	"github.com/appkins-org/ironic-metadata/pkg/metadata"
+	"gopkg.in/yaml.v2"
+)
+
+// V2Config is a cloud-init network configuration version 2 document, which
+// follows the netplan schema.
+type V2Config struct {
+	Version   int                   `yaml:"version"`
+	Renderer  string                `yaml:"renderer,omitempty"`
+	Ethernets map[string]V2Ethernet `yaml:"ethernets,omitempty"`
+	Bonds     map[string]V2Bond     `yaml:"bonds,omitempty"`
+	Vlans     map[string]V2Vlan     `yaml:"vlans,omitempty"`
+}
+
+// V2Interface holds the settings shared by every version 2 device type.
+type V2Interface struct {
+	MACAddress  string         `yaml:"macaddress,omitempty"`
+	MTU         int            `yaml:"mtu,omitempty"`
+	DHCP4       bool           `yaml:"dhcp4,omitempty"`
+	DHCP6       bool           `yaml:"dhcp6,omitempty"`
+	AcceptRA    *bool          `yaml:"accept-ra,omitempty"`
+	Addresses   []string       `yaml:"addresses,omitempty"`
+	Routes      []V2Route      `yaml:"routes,omitempty"`
+	Nameservers *V2Nameservers `yaml:"nameservers,omitempty"`
+}
+
+// V2Ethernet is a physical interface of a version 2 configuration.
+type V2Ethernet struct {
+	Match       *V2Match `yaml:"match,omitempty"`
+	SetName     string   `yaml:"set-name,omitempty"`
+	V2Interface `yaml:",inline"`
+}
+
+// V2Bond is a bonded interface of a version 2 configuration.
+type V2Bond struct {
+	Interfaces  []string          `yaml:"interfaces"`
+	Parameters  *V2BondParameters `yaml:"parameters,omitempty"`
+	V2Interface `yaml:",inline"`
+}
+
+// V2Vlan is a VLAN interface of a version 2 configuration.
+type V2Vlan struct {
+	ID          int    `yaml:"id"`
+	Link        string `yaml:"link"`
+	V2Interface `yaml:",inline"`
+}
+
+// V2Match selects a physical interface by its hardware address.
+type V2Match struct {
+	MACAddress string `yaml:"macaddress"`
+}
+
+// V2BondParameters configures the bonding driver.
+type V2BondParameters struct {
+	Mode               string  `yaml:"mode,omitempty"`
+	MIIMonitorInterval *uint32 `yaml:"mii-monitor-interval,omitempty"`
+	TransmitHashPolicy string  `yaml:"transmit-hash-policy,omitempty"`
+}
+
+// V2Route is a static route of a version 2 interface.
+type V2Route struct {
+	To     string `yaml:"to"`
+	Via    string `yaml:"via"`
+	Metric int    `yaml:"metric,omitempty"`
+}
+
+// V2Nameservers configures DNS resolution for a version 2 interface.
+type V2Nameservers struct {
+	Addresses []string `yaml:"addresses"`
+}
+
+// V2 converts network_data into a cloud-init version 2 configuration.
+func V2(nd *metadata.NetworkData) *V2Config {
+	cfg := &V2Config{Version: 2}
+	grouped := networksByLink(nd)
+	servers := nameservers(nd)
+
+	for _, link := range nd.Links {
+		iface := v2Interface(grouped[link.ID], servers)
+		iface.MTU = link.MTU
+
+		switch link.Type {
+		case linkTypeBond:
+			if cfg.Bonds == nil {
+				cfg.Bonds = make(map[string]V2Bond)
+			}
+			bond := V2Bond{Interfaces: link.BondLinks, V2Interface: iface}
+			if link.BondMode != "" || link.BondMIIMon != nil || link.BondHashPolicy != "" {
+				bond.Parameters = &V2BondParameters{
+					Mode:               link.BondMode,
+					MIIMonitorInterval: link.BondMIIMon,
+					TransmitHashPolicy: link.BondHashPolicy,
+				}
+			}
+			bond.MACAddress = link.EthernetMacAddress
+			cfg.Bonds[link.ID] = bond
+		case linkTypeVlan:
+			if cfg.Vlans == nil {
+				cfg.Vlans = make(map[string]V2Vlan)
+			}
+			iface.MACAddress = link.VlanMacAddress
+			cfg.Vlans[link.ID] = V2Vlan{ID: link.VlanID, Link: link.VlanLink, V2Interface: iface}
+		default:
+			if cfg.Ethernets == nil {
+				cfg.Ethernets = make(map[string]V2Ethernet)
+			}
+			ethernet := V2Ethernet{V2Interface: iface}
+			if link.EthernetMacAddress != "" {
+				ethernet.Match = &V2Match{MACAddress: link.EthernetMacAddress}
+				ethernet.SetName = link.ID
+			}
+			cfg.Ethernets[link.ID] = ethernet
+		}
+	}
+
+	return cfg
+}
+
+// v2Interface builds the address configuration of a version 2 interface
+// from the networks attached to its link.
+func v2Interface(networks []metadata.Network, servers []string) V2Interface {
+	var iface V2Interface
+	static := false
+
+	for _, network := range networks {
+		switch {
+		case isSLAAC(network):
+			acceptRA := true
+			iface.AcceptRA = &acceptRA
+			continue
+		case isDHCP(network) && isIPv6(network):
+			iface.DHCP6 = true
+			continue
+		case isDHCP(network):
+			iface.DHCP4 = true
+			continue
+		}
+
+		static = true
+		iface.Addresses = append(iface.Addresses, cidr(network.Address, network.Netmask))
+		if network.Gateway != "" {
+			iface.Routes = append(iface.Routes, V2Route{
+				To:  defaultRoute(network),
+				Via: network.Gateway,
+			})
+		}
+		for _, route := range network.Routes {
+			iface.Routes = append(iface.Routes, V2Route{
+				To:     cidr(route.Network, route.Netmask),
+				Via:    route.Gateway,
+				Metric: route.Metric,
+			})
+		}
+	}
+
+	if static && len(servers) > 0 {
+		iface.Nameservers = &V2Nameservers{Addresses: servers}
+	}
+
+	return iface
+}
+
+// MarshalV2 renders network_data as a version 2 network-config YAML document.
+func MarshalV2(nd *metadata.NetworkData) ([]byte, error) {
+	return yaml.Marshal(struct {
+		Network *V2Config `yaml:"network"`
+	}{Network: V2(nd)})
+}
+
+// Netplan converts network_data into a netplan configuration rendered by
+// systemd-networkd, as written to /etc/netplan.
+func Netplan(nd *metadata.NetworkData) *V2Config {
+	cfg := V2(nd)
+	cfg.Renderer = "networkd"
+	return cfg
+}
+
+// MarshalNetplan renders network_data as a netplan YAML file.
+func MarshalNetplan(nd *metadata.NetworkData) ([]byte, error) {
+	return yaml.Marshal(struct {
+		Network *V2Config `yaml:"network"`
+	}{Network: Netplan(nd)})
+}
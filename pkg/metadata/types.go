@@ -13,11 +13,24 @@ type MetaData struct {
 	LaunchIndex      int               `json:"launch_index,omitempty"`
 	PublicKeys       map[string]string `json:"public_keys,omitempty"`
 	Meta             map[string]string `json:"meta,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
 	Keys             []Key             `json:"keys,omitempty"`
 	AdminPass        string            `json:"admin_pass,omitempty"`
 	ProjectID        string            `json:"project_id,omitempty"`
 	CreationTime     *time.Time        `json:"creation_time,omitempty"`
 	InstanceType     string            `json:"instance_type"`
+	Files            []File            `json:"files,omitempty"`
+}
+
+// File represents a file injected into the instance. Its contents are
+// served at content_path, relative to the /openstack root.
+type File struct {
+	Path        string `json:"path"`
+	ContentPath string `json:"content_path"`
+	// Owner and Mode, such as root:root and 0644, are hints for writing the
+	// file. Nova does not serve them.
+	Owner string `json:"owner,omitempty"`
+	Mode  string `json:"mode,omitempty"`
 }
 
 // Key represents an SSH key.
@@ -44,17 +57,29 @@ type Link struct {
 	BondLinks          []string `json:"bond_links,omitempty"`
 	BondMIIMon         *uint32  `json:"bond_miimon,omitempty"`
 	BondHashPolicy     string   `json:"bond_xmit_hash_policy,omitempty"`
+	VlanID             int      `json:"vlan_id,omitempty"`
+	VlanLink           string   `json:"vlan_link,omitempty"`
+	VlanMacAddress     string   `json:"vlan_mac_address,omitempty"`
+
+	// Hints found by inspection, which are not part of the Nova schema: the
+	// negotiated speed, and the switch and switch port seen over LLDP.
+	SpeedMbps    int    `json:"speed_mbps,omitempty"`
+	SwitchID     string `json:"switch_id,omitempty"`
+	SwitchInfo   string `json:"switch_info,omitempty"`
+	SwitchPortID string `json:"switch_port_id,omitempty"`
 }
 
 // Network represents a network configuration.
 type Network struct {
-	ID      string  `json:"id,omitempty"`
-	Link    string  `json:"link"`
-	Type    string  `json:"type"`
-	Address string  `json:"ip_address,omitempty"`
-	Netmask string  `json:"netmask,omitempty"`
-	Gateway string  `json:"gateway,omitempty"`
-	Routes  []Route `json:"routes,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Link      string    `json:"link"`
+	Type      string    `json:"type"`
+	Address   string    `json:"ip_address,omitempty"`
+	Netmask   string    `json:"netmask,omitempty"`
+	Gateway   string    `json:"gateway,omitempty"`
+	NetworkID string    `json:"network_id,omitempty"`
+	Routes    []Route   `json:"routes,omitempty"`
+	Services  []Service `json:"services,omitempty"`
 }
 
 // Route represents a network route.
@@ -71,6 +96,50 @@ type Service struct {
 	Address string `json:"address"`
 }
 
+// Hardware summarizes the hardware inspection found on a node.
+type Hardware struct {
+	InspectedAt time.Time      `json:"inspected_at"`
+	System      HardwareSystem `json:"system"`
+	CPU         HardwareCPU    `json:"cpu"`
+	MemoryMB    int            `json:"memory_mb"`
+	Disks       []HardwareDisk `json:"disks"`
+	NICs        []HardwareNIC  `json:"nics"`
+}
+
+// HardwareSystem identifies the model of a node.
+type HardwareSystem struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	ProductName  string `json:"product_name,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+}
+
+// HardwareCPU describes the processors of a node.
+type HardwareCPU struct {
+	Architecture string `json:"architecture,omitempty"`
+	ModelName    string `json:"model_name,omitempty"`
+	Count        int    `json:"count"`
+	Frequency    string `json:"frequency,omitempty"`
+}
+
+// HardwareDisk describes a disk of a node.
+type HardwareDisk struct {
+	Name       string `json:"name"`
+	Model      string `json:"model,omitempty"`
+	Size       int64  `json:"size"`
+	Rotational bool   `json:"rotational"`
+	Serial     string `json:"serial,omitempty"`
+	WWN        string `json:"wwn,omitempty"`
+}
+
+// HardwareNIC describes a network interface of a node.
+type HardwareNIC struct {
+	Name       string `json:"name"`
+	MACAddress string `json:"mac_address"`
+	SpeedMbps  int    `json:"speed_mbps,omitempty"`
+	Vendor     string `json:"vendor,omitempty"`
+	Product    string `json:"product,omitempty"`
+}
+
 // UserData represents user data (typically cloud-init).
 type UserData string
 
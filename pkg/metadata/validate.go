@@ -0,0 +1,224 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/models"
+)
+
+// Violation is a way in which a rendered document breaks its schema.
+type Violation struct {
+	// Path locates the offending value, such as networks[0].link, or is
+	// empty for the document as a whole.
+	Path    string
+	Message string
+}
+
+func (v Violation) Error() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// keyTypes are the key types of the keys of meta_data.json.
+var keyTypes = []string{"ssh", "x509"}
+
+// ValidateNetworkData checks a rendered network_data.json against the Nova
+// network_data schema, from which the models package is generated. Links are
+// checked against the schema of their type, and the links that networks,
+// VLANs and bonds refer to must exist.
+func ValidateNetworkData(data []byte) []Violation {
+	var doc models.NetworkDataJson
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return []Violation{{Message: err.Error()}}
+	}
+	var raw struct {
+		Links    []json.RawMessage `json:"links"`
+		Networks []json.RawMessage `json:"networks"`
+		Services []json.RawMessage `json:"services"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return []Violation{{Message: err.Error()}}
+	}
+
+	var violations []Violation
+	check := func(path string, value json.RawMessage, model any) {
+		if err := json.Unmarshal(value, model); err != nil {
+			violations = append(violations, Violation{Path: path, Message: err.Error()})
+		}
+	}
+
+	type reference struct {
+		path, link string
+	}
+	var references []reference
+	linkIDs := make(map[string]bool)
+	for i, value := range raw.Links {
+		path := fmt.Sprintf("links[%d]", i)
+		var link struct {
+			ID        string   `json:"id"`
+			Type      string   `json:"type"`
+			VlanLink  string   `json:"vlan_link"`
+			BondLinks []string `json:"bond_links"`
+		}
+		_ = json.Unmarshal(value, &link)
+		linkIDs[link.ID] = true
+
+		switch link.Type {
+		case "bond":
+			check(path, value, &models.L2Bond{})
+			for j, bondLink := range link.BondLinks {
+				references = append(references,
+					reference{fmt.Sprintf("%s.bond_links[%d]", path, j), bondLink})
+			}
+		case "vlan":
+			check(path, value, &models.L2Vlan{})
+			references = append(references, reference{path + ".vlan_link", link.VlanLink})
+		default:
+			check(path, value, &models.L2Link{})
+		}
+	}
+
+	for i, value := range raw.Networks {
+		path := fmt.Sprintf("networks[%d]", i)
+		var network struct {
+			Type string `json:"type"`
+			Link string `json:"link"`
+		}
+		_ = json.Unmarshal(value, &network)
+
+		if strings.HasPrefix(network.Type, "ipv6") {
+			check(path, value, &models.L3Ipv6Network{})
+		} else {
+			check(path, value, &models.L3Ipv4Network{})
+		}
+		references = append(references, reference{path + ".link", network.Link})
+	}
+
+	for i, value := range raw.Services {
+		path := fmt.Sprintf("services[%d]", i)
+		var service struct {
+			Address string `json:"address"`
+		}
+		_ = json.Unmarshal(value, &service)
+
+		if strings.Contains(service.Address, ":") {
+			check(path, value, &models.Ipv6Service{})
+		} else {
+			check(path, value, &models.Ipv4Service{})
+		}
+	}
+
+	for _, ref := range references {
+		if ref.link != "" && !linkIDs[ref.link] {
+			violations = append(violations, Violation{
+				Path:    ref.path,
+				Message: fmt.Sprintf("refers to unknown link %q", ref.link),
+			})
+		}
+	}
+	return violations
+}
+
+// ValidateMetaData checks a rendered meta_data.json against the documents
+// Nova serves. It requires the uuid and hostname, and checks the types of
+// the other fields cloud-init reads.
+func ValidateMetaData(data []byte) []Violation {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return []Violation{{Message: err.Error()}}
+	}
+
+	var violations []Violation
+	violate := func(path, format string, args ...any) {
+		violations = append(violations,
+			Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	isString := func(value any) bool {
+		_, ok := value.(string)
+		return ok
+	}
+
+	for _, field := range []string{"uuid", "hostname"} {
+		if value, _ := doc[field].(string); value == "" {
+			violate(field, "required")
+		}
+	}
+	for _, field := range []string{
+		"name", "availability_zone", "project_id", "instance_type", "admin_pass",
+	} {
+		if value, ok := doc[field]; ok && !isString(value) {
+			violate(field, "must be a string")
+		}
+	}
+	if value, ok := doc["launch_index"]; ok {
+		if index, isNumber := value.(float64); !isNumber || index != float64(int64(index)) {
+			violate("launch_index", "must be an integer")
+		}
+	}
+
+	for _, field := range []string{"public_keys", "meta"} {
+		value, ok := doc[field]
+		if !ok {
+			continue
+		}
+		object, isObject := value.(map[string]any)
+		if !isObject {
+			violate(field, "must be an object")
+			continue
+		}
+		for key, value := range object {
+			if !isString(value) {
+				violate(field+"."+key, "must be a string")
+			}
+		}
+	}
+
+	objects := func(field string, check func(path string, object map[string]any)) {
+		value, ok := doc[field]
+		if !ok {
+			return
+		}
+		list, isList := value.([]any)
+		if !isList {
+			violate(field, "must be an array")
+			return
+		}
+		for i, item := range list {
+			path := fmt.Sprintf("%s[%d]", field, i)
+			object, isObject := item.(map[string]any)
+			if !isObject {
+				violate(path, "must be an object")
+				continue
+			}
+			check(path, object)
+		}
+	}
+	required := func(path string, object map[string]any, fields ...string) {
+		for _, field := range fields {
+			if value, _ := object[field].(string); value == "" {
+				violate(path+"."+field, "required")
+			}
+		}
+	}
+	objects("keys", func(path string, key map[string]any) {
+		required(path, key, "name", "type", "data")
+		if keyType, _ := key["type"].(string); keyType != "" &&
+			!slices.Contains(keyTypes, keyType) {
+			violate(path+".type", "must be one of %s", strings.Join(keyTypes, ", "))
+		}
+	})
+	objects("files", func(path string, file map[string]any) {
+		required(path, file, "path", "content_path")
+		for _, field := range []string{"owner", "mode"} {
+			if value, ok := file[field]; ok && !isString(value) {
+				violate(path+"."+field, "must be a string")
+			}
+		}
+	})
+	return violations
+}
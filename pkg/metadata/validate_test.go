@@ -0,0 +1,141 @@
+package metadata
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidateNetworkData(t *testing.T) {
+	tests := []struct {
+		name string
+		have string
+		want []string
+	}{
+		{
+			name: "valid",
+			have: `{
+				"links": [
+					{"id": "eth0", "type": "phy", "ethernet_mac_address": "52:54:00:00:00:01"},
+					{"id": "eth1", "type": "phy", "ethernet_mac_address": "52:54:00:00:00:02"},
+					{"id": "bond0", "type": "bond", "bond_mode": "802.3ad",
+						"bond_links": ["eth0", "eth1"],
+						"ethernet_mac_address": "52:54:00:00:00:01"},
+					{"id": "vlan10", "type": "vlan", "vlan_id": 10, "vlan_link": "bond0",
+						"vlan_mac_address": "52:54:00:00:00:01"}
+				],
+				"networks": [
+					{"id": "net0", "type": "ipv4", "link": "vlan10", "network_id": "n0",
+						"ip_address": "10.0.0.5", "netmask": "255.255.255.0"},
+					{"id": "net1", "type": "ipv6_slaac", "link": "eth0", "network_id": "n1"}
+				],
+				"services": [{"type": "dns", "address": "10.0.0.1"}]
+			}`,
+		},
+		{
+			name: "missing services",
+			have: `{"links": [], "networks": []}`,
+			want: []string{"field services in NetworkDataJson: required"},
+		},
+		{
+			name: "invalid values",
+			have: `{
+				"links": [
+					{"id": "eth0", "type": "physical", "ethernet_mac_address": "52:54:00:00:00:01"}
+				],
+				"networks": [
+					{"id": "net0", "type": "ipv4", "link": "eth1", "network_id": "n0"},
+					{"id": "net1", "type": "ipv4", "link": "eth0"}
+				],
+				"services": [{"type": "dns", "address": "10.0.0.256"}]
+			}`,
+			want: []string{"links[0]", "networks[1]", "services[0]", "networks[0].link"},
+		},
+		{
+			name: "unknown vlan link",
+			have: `{
+				"links": [{"id": "vlan10", "type": "vlan", "vlan_id": 10, "vlan_link": "eth0",
+					"vlan_mac_address": "52:54:00:00:00:01"}],
+				"networks": [],
+				"services": []
+			}`,
+			want: []string{"links[0].vlan_link"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var have []string
+			for _, violation := range ValidateNetworkData([]byte(tt.have)) {
+				if violation.Path == "" {
+					have = append(have, violation.Message)
+				} else {
+					have = append(have, violation.Path)
+				}
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("have violations %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMetaData(t *testing.T) {
+	tests := []struct {
+		name string
+		have string
+		want []string
+	}{
+		{
+			name: "valid",
+			have: `{"uuid": "node-1", "hostname": "web01", "launch_index": 0,
+				"public_keys": {"default": "ssh-ed25519 AAAA"},
+				"keys": [{"name": "default", "type": "ssh", "data": "ssh-ed25519 AAAA"}],
+				"files": [{"path": "/etc/motd", "content_path": "/content/0000",
+					"owner": "root:root", "mode": "0644"}]}`,
+		},
+		{
+			name: "missing required fields",
+			have: `{"name": "web01"}`,
+			want: []string{"uuid: required", "hostname: required"},
+		},
+		{
+			name: "wrong types",
+			have: `{"uuid": "node-1", "hostname": "web01", "launch_index": 0.5,
+				"public_keys": {"default": 1}, "meta": [], "project_id": 1}`,
+			want: []string{
+				"project_id: must be a string",
+				"launch_index: must be an integer",
+				"public_keys.default: must be a string",
+				"meta: must be an object",
+			},
+		},
+		{
+			name: "invalid keys and files",
+			have: `{"uuid": "node-1", "hostname": "web01",
+				"keys": [{"name": "default", "type": "rsa", "data": "AAAA"}, "AAAA"],
+				"files": [{"path": "/etc/motd"},
+					{"path": "/etc/issue", "content_path": "/content/0001", "mode": 420}]}`,
+			want: []string{
+				"keys[0].type: must be one of ssh, x509",
+				"keys[1]: must be an object",
+				"files[0].content_path: required",
+				"files[1].mode: must be a string",
+			},
+		},
+		{name: "not an object", have: `[]`, want: []string{
+			"json: cannot unmarshal array into Go value of type map[string]interface {}",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var have []string
+			for _, violation := range ValidateMetaData([]byte(tt.have)) {
+				have = append(have, violation.Error())
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("have violations %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"iter"
+	"time"
+)
+
+// CacheStats is a snapshot of the usage of a cache.
+type CacheStats struct {
+	// Entries is the number of cached entries, including expired entries
+	// that were not evicted yet.
+	Entries int
+
+	// Hits and Misses count the lookups answered from the cache and those
+	// that were not.
+	Hits, Misses uint64
+
+	// Evictions counts the entries dropped to keep the cache within its
+	// limits.
+	Evictions uint64
+
+	// Bytes estimates the memory the entries take, or is zero for caches
+	// that do not size their entries.
+	Bytes int64
+
+	// Oldest is when the oldest entry was stored. It is zero when the cache
+	// is empty.
+	Oldest time.Time
+}
+
+// CacheCounters counts the hits and misses of a cache. It is not safe for
+// concurrent use, and is meant to be guarded by the mutex of the cache.
+type CacheCounters struct {
+	Hits, Misses, Evictions uint64
+}
+
+// Lookup counts a lookup, a hit when hit is true.
+func (c *CacheCounters) Lookup(hit bool) {
+	if hit {
+		c.Hits++
+	} else {
+		c.Misses++
+	}
+}
+
+// Evict counts n entries evicted.
+func (c *CacheCounters) Evict(n int) {
+	c.Evictions += uint64(n)
+}
+
+// Stats returns the stats of a cache holding entries stored at the times
+// yielded by stored.
+func (c *CacheCounters) Stats(entries int, stored iter.Seq[time.Time]) CacheStats {
+	stats := CacheStats{
+		Entries:   entries,
+		Hits:      c.Hits,
+		Misses:    c.Misses,
+		Evictions: c.Evictions,
+	}
+	for t := range stored {
+		if stats.Oldest.IsZero() || t.Before(stats.Oldest) {
+			stats.Oldest = t
+		}
+	}
+	return stats
+}
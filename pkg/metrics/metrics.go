@@ -0,0 +1,473 @@
+// Package metrics implements counters, gauges and histograms exposed in the
+// Prometheus text exposition format, without depending on the Prometheus
+// client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are histogram buckets in seconds suited to HTTP latencies.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Labels are the label names and values of a series.
+type Labels map[string]string
+
+// Registry holds metric families and writes them in the text exposition
+// format. It implements http.Handler to serve them.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// family is a named metric with its series.
+type family interface {
+	kind() string
+	help() string
+	write(w *bufio.Writer, name string)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	v := &CounterVec{vec: newVec(help, labelNames, func() *Counter { return &Counter{} })}
+	r.register(name, v)
+	return v
+}
+
+// NewGaugeVec registers a gauge with the given label names.
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	v := &GaugeVec{vec: newVec(help, labelNames, func() *Gauge { return &Gauge{} })}
+	r.register(name, v)
+	return v
+}
+
+// NewHistogramVec registers a histogram with the given buckets, which
+// default to DefaultBuckets when empty, and label names.
+func (r *Registry) NewHistogramVec(
+	name, help string,
+	buckets []float64,
+	labelNames ...string,
+) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	v := &HistogramVec{vec: newVec(help, labelNames, func() *Histogram {
+		return &Histogram{upperBounds: buckets, counts: make([]uint64, len(buckets))}
+	})}
+	r.register(name, v)
+	return v
+}
+
+// NewGaugeFunc registers a gauge series whose value is read from fn when
+// the metrics are written. Several series of one gauge are registered under
+// the same name with distinct labels.
+func (r *Registry) NewGaugeFunc(name, help string, labels Labels, fn func() float64) {
+	r.registerFunc(name, "gauge", help, labels, fn)
+}
+
+// NewCounterFunc registers a counter series whose value is read from fn
+// when the metrics are written, like NewGaugeFunc.
+func (r *Registry) NewCounterFunc(name, help string, labels Labels, fn func() float64) {
+	r.registerFunc(name, "counter", help, labels, fn)
+}
+
+// register adds a family. Registering a name twice is a programming error
+// and panics.
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.families[name] = f
+}
+
+func (r *Registry) registerFunc(name, kind, help string, labels Labels, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		f = &funcFamily{k: kind, h: help}
+		r.families[name] = f
+	}
+	ff, ok := f.(*funcFamily)
+	if !ok || ff.k != kind {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	ff.series = append(ff.series, funcSeries{labels: formatLabels(labels), fn: fn})
+}
+
+// WriteTo writes the metrics to w in the text exposition format, sorted by
+// name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make(map[string]family, len(r.families))
+	for name, f := range r.families {
+		families[name] = f
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, name := range names {
+		f := families[name]
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(f.help()))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.kind())
+		f.write(bw, name)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP writes the metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = r.WriteTo(w)
+}
+
+// Since returns the seconds elapsed since start, as observed by latency
+// histograms.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// vec holds the series of a family by label values.
+type vec[T any] struct {
+	h          string
+	labelNames []string
+	newSeries  func() *T
+
+	mu     sync.Mutex
+	series map[string]*T
+	labels map[string]string
+}
+
+func newVec[T any](help string, labelNames []string, newSeries func() *T) vec[T] {
+	return vec[T]{
+		h:          help,
+		labelNames: labelNames,
+		newSeries:  newSeries,
+		series:     make(map[string]*T),
+		labels:     make(map[string]string),
+	}
+}
+
+// with returns the series with the given label values, creating it when
+// missing. Passing a different number of values than label names is a
+// programming error and panics.
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %d label values for %d labels", len(values), len(v.labelNames)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newSeries()
+		v.series[key] = s
+		labels := make(Labels, len(values))
+		for i, name := range v.labelNames {
+			labels[name] = values[i]
+		}
+		v.labels[key] = formatLabels(labels)
+	}
+	return s
+}
+
+// each calls fn with the formatted labels of each series, sorted by them.
+func (v *vec[T]) each(fn func(labels string, s *T)) {
+	v.mu.Lock()
+	type entry struct {
+		labels string
+		series *T
+	}
+	entries := make([]entry, 0, len(v.series))
+	for key, s := range v.series {
+		entries = append(entries, entry{labels: v.labels[key], series: s})
+	}
+	v.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].labels < entries[j].labels })
+	for _, e := range entries {
+		fn(e.labels, e.series)
+	}
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	vec[Counter]
+}
+
+// With returns the counter with the given label values.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.with(values)
+}
+
+func (v *CounterVec) kind() string { return "counter" }
+func (v *CounterVec) help() string { return v.h }
+
+func (v *CounterVec) write(w *bufio.Writer, name string) {
+	v.each(func(labels string, c *Counter) {
+		writeSample(w, name, labels, c.Value())
+	})
+}
+
+// Counter is a value that only increases.
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds delta, which must not be negative, to the counter.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+// Value returns the value of the counter.
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct {
+	vec[Gauge]
+}
+
+// With returns the gauge with the given label values.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.with(values)
+}
+
+func (v *GaugeVec) kind() string { return "gauge" }
+func (v *GaugeVec) help() string { return v.h }
+
+func (v *GaugeVec) write(w *bufio.Writer, name string) {
+	v.each(func(labels string, g *Gauge) {
+		writeSample(w, name, labels, g.Value())
+	})
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// Set sets the gauge.
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+// Add adds delta to the gauge.
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	vec[Histogram]
+}
+
+// With returns the histogram with the given label values.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.with(values)
+}
+
+func (v *HistogramVec) kind() string { return "histogram" }
+func (v *HistogramVec) help() string { return v.h }
+
+func (v *HistogramVec) write(w *bufio.Writer, name string) {
+	v.each(func(labels string, h *Histogram) {
+		counts, count, sum := h.snapshot()
+		var cumulative uint64
+		for i, upperBound := range h.upperBounds {
+			cumulative += counts[i]
+			writeSample(w, name+"_bucket", withLabel(labels, "le", formatFloat(upperBound)),
+				float64(cumulative))
+		}
+		writeSample(w, name+"_bucket", withLabel(labels, "le", "+Inf"), float64(count))
+		writeSample(w, name+"_sum", labels, sum)
+		writeSample(w, name+"_count", labels, float64(count))
+	})
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	upperBounds []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe adds an observation to the histogram.
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.upperBounds, value)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) snapshot() (counts []uint64, count uint64, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]uint64(nil), h.counts...), h.count, h.sum
+}
+
+// funcFamily is a metric whose series are read from functions.
+type funcFamily struct {
+	k, h   string
+	series []funcSeries
+}
+
+type funcSeries struct {
+	labels string
+	fn     func() float64
+}
+
+func (f *funcFamily) kind() string { return f.k }
+func (f *funcFamily) help() string { return f.h }
+
+func (f *funcFamily) write(w *bufio.Writer, name string) {
+	for _, s := range f.series {
+		writeSample(w, name, s.labels, s.fn())
+	}
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	_, _ = w.WriteString(name)
+	if labels != "" {
+		_ = w.WriteByte('{')
+		_, _ = w.WriteString(labels)
+		_ = w.WriteByte('}')
+	}
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(value))
+	_ = w.WriteByte('\n')
+}
+
+// formatLabels formats labels as name="value" pairs sorted by name.
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(labels[name]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return pair
+	}
+	return labels + "," + pair
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelValueEscaper.Replace(s) }
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
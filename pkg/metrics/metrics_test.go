@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests served.", "route", "code")
+	requests.With("meta_data", "200").Add(3)
+	requests.With("meta_data", "404").Inc()
+	requests.With("user_data", "200").Add(-1)
+	r.NewGaugeVec("temperature", "Temperature with \"quotes\".", "room").
+		With("a\"b\\c\nd").Set(21.5)
+	latency := r.NewHistogramVec("latency_seconds", "Request latency.", []float64{1, .1}, "route")
+	for _, v := range []float64{.05, .1, .5, 2} {
+		latency.With("meta_data").Observe(v)
+	}
+	r.NewGaugeFunc("entries", "Cache entries.", Labels{"cache": "b"}, func() float64 { return 2 })
+	r.NewGaugeFunc("entries", "Cache entries.", Labels{"cache": "a"}, func() float64 { return 1 })
+
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have := b.String()
+	if n != int64(len(have)) {
+		t.Errorf("have %d bytes written, want %d", n, len(have))
+	}
+
+	want := `# HELP entries Cache entries.
+# TYPE entries gauge
+entries{cache="b"} 2
+entries{cache="a"} 1
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="meta_data",le="0.1"} 2
+latency_seconds_bucket{route="meta_data",le="1"} 3
+latency_seconds_bucket{route="meta_data",le="+Inf"} 4
+latency_seconds_sum{route="meta_data"} 2.65
+latency_seconds_count{route="meta_data"} 4
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{code="200",route="meta_data"} 3
+requests_total{code="200",route="user_data"} 0
+requests_total{code="404",route="meta_data"} 1
+# HELP temperature Temperature with "quotes".
+# TYPE temperature gauge
+temperature{room="a\"b\\c\nd"} 21.5
+`
+	if have != want {
+		t.Errorf("have\n%s\nwant\n%s", have, want)
+	}
+}
+
+func TestRegistry_register(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(r *Registry)
+	}{
+		{
+			name: "duplicate vector",
+			register: func(r *Registry) {
+				r.NewCounterVec("total", "")
+				r.NewGaugeVec("total", "")
+			},
+		},
+		{
+			name: "function of another type",
+			register: func(r *Registry) {
+				r.NewGaugeFunc("total", "", nil, func() float64 { return 0 })
+				r.NewCounterFunc("total", "", nil, func() float64 { return 0 })
+			},
+		},
+		{
+			name: "wrong label count",
+			register: func(r *Registry) {
+				r.NewCounterVec("total", "", "route").With()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			tt.register(NewRegistry())
+		})
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("total", "Total.").With().Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if have := rec.Header().Get("Content-Type"); have != ContentType {
+		t.Errorf("have content type %q, want %q", have, ContentType)
+	}
+	if have := rec.Body.String(); !strings.Contains(have, "\ntotal 1\n") {
+		t.Errorf("expected counter in %q", have)
+	}
+}
+
+func TestCacheCounters(t *testing.T) {
+	var c CacheCounters
+	c.Lookup(true)
+	c.Lookup(false)
+	c.Lookup(false)
+
+	now := time.Now()
+	stored := []time.Time{now, now.Add(-time.Minute), now.Add(-time.Second)}
+	have := c.Stats(len(stored), func(yield func(time.Time) bool) {
+		for _, t := range stored {
+			if !yield(t) {
+				return
+			}
+		}
+	})
+	want := CacheStats{Entries: 3, Hits: 1, Misses: 2, Oldest: now.Add(-time.Minute)}
+	if have != want {
+		t.Errorf("have %+v, want %+v", have, want)
+	}
+
+	if have := c.Stats(0, func(func(time.Time) bool) {}); !have.Oldest.IsZero() {
+		t.Errorf("expected no oldest entry, have %v", have.Oldest)
+	}
+}
@@ -0,0 +1,393 @@
+// Package nats publishes events of the service to a NATS server, speaking
+// the core NATS client protocol, so sites aggregating provisioning
+// telemetry on a message bus receive them. Core NATS does not acknowledge
+// messages, so events are published at most once.
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+)
+
+// Defaults of Config.
+const (
+	DefaultSubjectPrefix = "ironic_metadata"
+	DefaultTimeout       = 5 * time.Second
+	DefaultQueueSize     = 1024
+)
+
+// defaultPort is the port of NATS servers.
+const defaultPort = "4222"
+
+// Bounds of the delay between attempts to connect.
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// maxLineSize bounds the protocol lines read from the server, such as INFO.
+const maxLineSize = 64 << 10
+
+// Config configures a Publisher.
+type Config struct {
+	// URL is the server, nats://host:port, or tls://host:port to require
+	// TLS. The port defaults to 4222. Credentials are given as user and
+	// password, or as a token in place of the user.
+	URL string
+
+	// SubjectPrefix prefixes the subject of each event, published to
+	// <prefix>.<type>. It defaults to DefaultSubjectPrefix.
+	SubjectPrefix string
+
+	// Events are the types of events published, every type when empty.
+	Events map[string]bool
+
+	// Timeout bounds connecting and each write, defaulting to
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	// QueueSize bounds the events waiting to be published, defaulting to
+	// DefaultQueueSize.
+	QueueSize int
+
+	// OnError is called when the connection to the server fails.
+	OnError func(err error)
+}
+
+// Publisher publishes events to a NATS server.
+type Publisher struct {
+	cfg   Config
+	addr  string
+	tls   bool
+	host  string
+	user  string
+	pass  string
+	token string
+	queue chan events.Event
+}
+
+// New returns a publisher to the server of cfg. Events are published once
+// Run is called.
+func New(cfg Config) (*Publisher, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q: expected nats://host:port or tls://host:port",
+			cfg.URL)
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = DefaultSubjectPrefix
+	}
+	if strings.ContainsAny(cfg.SubjectPrefix, " \t\r\n*>") {
+		return nil, fmt.Errorf("invalid NATS subject prefix %q", cfg.SubjectPrefix)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	p := &Publisher{
+		cfg:   cfg,
+		addr:  net.JoinHostPort(u.Hostname(), port),
+		tls:   u.Scheme == "tls",
+		host:  u.Hostname(),
+		queue: make(chan events.Event, cfg.QueueSize),
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			p.user, p.pass = u.User.Username(), pass
+		} else {
+			p.token = u.User.Username()
+		}
+	}
+	return p, nil
+}
+
+// Name returns nats.
+func (p *Publisher) Name() string {
+	return "nats"
+}
+
+// Wants reports whether events of a type are published.
+func (p *Publisher) Wants(eventType string) bool {
+	return len(p.cfg.Events) == 0 || p.cfg.Events[eventType]
+}
+
+// Subject returns the subject events of a type are published to.
+func (p *Publisher) Subject(eventType string) string {
+	return p.cfg.SubjectPrefix + "." + eventType
+}
+
+// Notify queues an event for publishing. It never blocks, and reports
+// false when the queue is full and the event is dropped.
+func (p *Publisher) Notify(event events.Event) bool {
+	select {
+	case p.queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run connects to the server and publishes queued events until ctx is
+// done, reconnecting with exponential backoff when the connection fails.
+// Events still queued then are dropped.
+func (p *Publisher) Run(ctx context.Context) {
+	delay := minReconnectDelay
+	var pending *events.Event
+	for {
+		c, err := p.connect(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.error(fmt.Errorf("failed to connect to NATS: %w", err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxReconnectDelay)
+			continue
+		}
+		delay = minReconnectDelay
+
+		pending, err = p.serve(ctx, c, pending)
+		c.close()
+		if ctx.Err() != nil {
+			return
+		}
+		p.error(fmt.Errorf("NATS connection failed: %w", err))
+	}
+}
+
+// serve publishes queued events on a connection until ctx is done or the
+// connection fails, returning the event that could not be published.
+func (p *Publisher) serve(
+	ctx context.Context,
+	c *conn,
+	pending *events.Event,
+) (*events.Event, error) {
+	if pending != nil {
+		if err := c.publish(p.Subject(pending.Type), *pending); err != nil {
+			return pending, err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-c.done:
+			return nil, c.err
+		case event := <-p.queue:
+			if err := c.publish(p.Subject(event.Type), event); err != nil {
+				return &event, err
+			}
+		}
+	}
+}
+
+func (p *Publisher) error(err error) {
+	if p.cfg.OnError != nil {
+		p.cfg.OnError(err)
+	}
+}
+
+// serverInfo is the INFO the server greets clients with.
+type serverInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// connectOptions are the options of the CONNECT of the client.
+type connectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// connect dials the server and completes the handshake: the server's INFO,
+// a TLS upgrade when required, then the CONNECT of the client, confirmed
+// by a PING answered with PONG.
+func (p *Publisher) connect(ctx context.Context) (*conn, error) {
+	dialer := net.Dialer{Timeout: p.cfg.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := nc.SetDeadline(time.Now().Add(p.cfg.Timeout)); err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReaderSize(nc, 4096)
+	line, err := readLine(r)
+	if err != nil {
+		_ = nc.Close()
+		return nil, fmt.Errorf("failed to read server info: %w", err)
+	}
+	infoJSON, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		_ = nc.Close()
+		return nil, fmt.Errorf("unexpected greeting %q", line)
+	}
+	var info serverInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		_ = nc.Close()
+		return nil, fmt.Errorf("failed to decode server info: %w", err)
+	}
+
+	if p.tls || info.TLSRequired {
+		tc := tls.Client(nc, &tls.Config{ServerName: p.host, MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		nc = tc
+		r = bufio.NewReaderSize(nc, 4096)
+	}
+
+	options, err := json.Marshal(connectOptions{
+		Name:      "ironic-metadata",
+		Lang:      "go",
+		Protocol:  0,
+		User:      p.user,
+		Pass:      p.pass,
+		AuthToken: p.token,
+	})
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	if _, err := nc.Write([]byte("CONNECT " + string(options) + "\r\nPING\r\n")); err != nil {
+		_ = nc.Close()
+		return nil, fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("failed to read CONNECT response: %w", err)
+		}
+		if line == "PONG" {
+			break
+		}
+		if msg, ok := strings.CutPrefix(line, "-ERR "); ok {
+			_ = nc.Close()
+			return nil, fmt.Errorf("server refused connection: %s", msg)
+		}
+	}
+	if err := nc.SetDeadline(time.Time{}); err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+
+	c := &conn{nc: nc, timeout: p.cfg.Timeout, done: make(chan struct{})}
+	go c.read(r)
+	return c, nil
+}
+
+// conn is a connection to the server after the handshake.
+type conn struct {
+	nc      net.Conn
+	timeout time.Duration
+
+	// mu serializes writes, made by publish and by read answering PINGs.
+	mu sync.Mutex
+
+	// done is closed once the connection fails, after err is set.
+	done chan struct{}
+	err  error
+}
+
+// read reads the protocol lines of the server until the connection fails,
+// answering PINGs so the server keeps the connection open.
+func (c *conn) read(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			c.err = err
+			return
+		}
+		switch {
+		case line == "PING":
+			if err := c.write([]byte("PONG\r\n")); err != nil {
+				c.err = err
+				return
+			}
+		case strings.HasPrefix(line, "-ERR "):
+			c.err = fmt.Errorf("server error: %s", strings.TrimPrefix(line, "-ERR "))
+			_ = c.nc.Close()
+			return
+		}
+	}
+}
+
+// publish publishes an event to a subject.
+func (c *conn) publish(subject string, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	msg := make([]byte, 0, len(subject)+len(payload)+32)
+	msg = fmt.Appendf(msg, "PUB %s %d\r\n", subject, len(payload))
+	msg = append(msg, payload...)
+	msg = append(msg, "\r\n"...)
+	return c.write(msg)
+}
+
+func (c *conn) write(b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nc.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err := c.nc.Write(b)
+	return err
+}
+
+// close closes the connection and waits for read to return.
+func (c *conn) close() {
+	_ = c.nc.Close()
+	<-c.done
+}
+
+// readLine reads a protocol line without its CRLF.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineSize {
+			return "", errors.New("protocol line too long")
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package opa asks an Open Policy Agent server for decisions on metadata
+// responses through its Data API, so a Rego policy can deny responses or
+// strip fields from them.
+package opa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultPath is the path of the decision document in the data of OPA.
+const DefaultPath = "ironic_metadata/decision"
+
+// DefaultTimeout bounds a policy query.
+const DefaultTimeout = 2 * time.Second
+
+// Config configures a Client.
+type Config struct {
+	// Address is the OPA server URL, such as http://localhost:8181.
+	Address string
+
+	// Path is the path of the decision document below /v1/data, defaulting
+	// to DefaultPath.
+	Path string
+
+	// Token is sent as a bearer token, when OPA requires authentication.
+	Token string
+
+	// Timeout bounds a query, defaulting to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Input is the input of a policy query, describing a response about to be
+// served.
+type Input struct {
+	ClientIP string `json:"client_ip"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+
+	// Endpoint is the document served, such as meta_data.json or
+	// ec2/meta-data.
+	Endpoint string `json:"endpoint"`
+
+	Node *Node `json:"node"`
+
+	ContentType string `json:"content_type"`
+
+	// Document is the rendered response, decoded when it is JSON and a
+	// string otherwise.
+	Document any `json:"document"`
+}
+
+// Node describes the node a response is served for.
+type Node struct {
+	UUID           string         `json:"uuid"`
+	Name           string         `json:"name,omitempty"`
+	ProvisionState string         `json:"provision_state,omitempty"`
+	Maintenance    bool           `json:"maintenance"`
+	ProjectID      string         `json:"project_id,omitempty"`
+	Owner          string         `json:"owner,omitempty"`
+	Lessee         string         `json:"lessee,omitempty"`
+	ResourceClass  string         `json:"resource_class,omitempty"`
+	Traits         []string       `json:"traits,omitempty"`
+	Extra          map[string]any `json:"extra,omitempty"`
+}
+
+// Decision is the decision of a policy on a response.
+type Decision struct {
+	// Allow is whether the response is served.
+	Allow bool `json:"allow"`
+
+	// Strip holds the fields removed from a JSON document before it is
+	// served, as slash separated paths of object keys, such as
+	// meta/admin_pass. Paths crossing an array apply to each of its
+	// elements.
+	Strip []string `json:"strip,omitempty"`
+
+	// Reason explains a denial in the logs.
+	Reason string `json:"reason,omitempty"`
+}
+
+// UnmarshalJSON decodes a decision document, or a bare boolean standing for
+// a decision allowing or denying the response as is.
+func (d *Decision) UnmarshalJSON(b []byte) error {
+	var allow bool
+	if err := json.Unmarshal(b, &allow); err == nil {
+		*d = Decision{Allow: allow}
+		return nil
+	}
+	type decision Decision
+	var v decision
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*d = Decision(v)
+	return nil
+}
+
+// Client asks OPA for decisions.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+}
+
+// New returns a client of the OPA server described by cfg.
+func New(cfg Config) (*Client, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("OPA address is required")
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	cfg.Path = strings.Trim(strings.ReplaceAll(cfg.Path, ".", "/"), "/")
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Decide returns the decision of the policy on input. A policy whose
+// decision is undefined for input fails, so a mistyped path or a policy
+// that was not loaded denies every response rather than allowing them.
+func (c *Client) Decide(ctx context.Context, input *Input) (*Decision, error) {
+	b, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal policy input: %w", err)
+	}
+	url := strings.TrimRight(c.cfg.Address, "/") + "/v1/data/" + c.cfg.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OPA: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 300 {
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.Message != "" {
+			return nil, fmt.Errorf("OPA query failed: %s: %s", resp.Status, errResp.Message)
+		}
+		return nil, fmt.Errorf("OPA query failed: %s", resp.Status)
+	}
+
+	var result struct {
+		Result *Decision `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode OPA response: %w", err)
+	}
+	if result.Result == nil {
+		return nil, fmt.Errorf("policy decision %s is undefined", c.cfg.Path)
+	}
+	return result.Result, nil
+}
+
+// Strip removes the fields at paths from a decoded JSON document, reporting
+// whether any was removed.
+func Strip(document any, paths []string) bool {
+	stripped := false
+	for _, path := range paths {
+		keys := strings.Split(strings.Trim(path, "/"), "/")
+		if strip(document, keys) {
+			stripped = true
+		}
+	}
+	return stripped
+}
+
+// strip removes the field at the path of keys below v.
+func strip(v any, keys []string) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		child, ok := v[keys[0]]
+		if !ok {
+			return false
+		}
+		if len(keys) == 1 {
+			delete(v, keys[0])
+			return true
+		}
+		return strip(child, keys[1:])
+	case []any:
+		stripped := false
+		for _, elem := range v {
+			if strip(elem, keys) {
+				stripped = true
+			}
+		}
+		return stripped
+	}
+	return false
+}
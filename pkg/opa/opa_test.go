@@ -0,0 +1,150 @@
+package opa
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// newTestServer returns an OPA server answering queries of
+// ironic_metadata/decision with the result for the endpoint of the input,
+// or none for an undefined decision.
+func newTestServer(t *testing.T, results map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/data/ironic_metadata/decision" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code": "not_found", "message": "no such path"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"code": "unauthorized", "message": "invalid token"}`))
+			return
+		}
+		var body struct {
+			Input Input `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result, ok := results[body.Input.Endpoint]
+		if !ok {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result": ` + result + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_Decide(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"meta_data.json":    `{"allow": true, "strip": ["meta/admin_pass"]}`,
+		"user_data":         `{"allow": false, "reason": "user data is off limits"}`,
+		"network_data.json": `true`,
+	})
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		endpoint string
+		want     *Decision
+		wantErr  bool
+	}{
+		{
+			name:     "strip",
+			endpoint: "meta_data.json",
+			want:     &Decision{Allow: true, Strip: []string{"meta/admin_pass"}},
+		},
+		{
+			name:     "deny",
+			endpoint: "user_data",
+			want:     &Decision{Reason: "user data is off limits"},
+		},
+		{name: "boolean", endpoint: "network_data.json", want: &Decision{Allow: true}},
+		{name: "undefined", endpoint: "vendor_data.json", wantErr: true},
+		{
+			name:     "dotted path",
+			cfg:      Config{Path: "ironic_metadata.decision"},
+			endpoint: "network_data.json",
+			want:     &Decision{Allow: true},
+		},
+		{
+			name:     "unknown path",
+			cfg:      Config{Path: "missing"},
+			endpoint: "network_data.json",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid token",
+			cfg:      Config{Token: "wrong"},
+			endpoint: "network_data.json",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Address = srv.URL
+			if cfg.Token == "" {
+				cfg.Token = "secret"
+			}
+			c, err := New(cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			have, err := c.Decide(t.Context(), &Input{Endpoint: tt.endpoint})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %+v, want %+v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	document := map[string]any{
+		"uuid": "node-1",
+		"meta": map[string]any{"admin_pass": "hunter2", "role": "web"},
+		"links": []any{
+			map[string]any{"id": "eth0", "ethernet_mac_address": "52:54:00:00:00:01"},
+			map[string]any{"id": "eth1", "ethernet_mac_address": "52:54:00:00:00:02"},
+		},
+	}
+	if Strip(document, []string{"missing", "uuid/nested"}) {
+		t.Error("have fields stripped, want none")
+	}
+	if !Strip(document, []string{"meta/admin_pass", "/links/ethernet_mac_address/"}) {
+		t.Error("have no field stripped, want some")
+	}
+
+	var keys []string
+	for key := range document["meta"].(map[string]any) {
+		keys = append(keys, key)
+	}
+	for _, link := range document["links"].([]any) {
+		for key := range link.(map[string]any) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	if want := []string{"id", "id", "role"}; !slices.Equal(keys, want) {
+		t.Errorf("have keys %v left, want %v", keys, want)
+	}
+}
@@ -0,0 +1,508 @@
+// Package plugins runs WASM plugins, loaded from a directory and run in
+// wazero, that mutate rendered metadata documents and resolve client IPs to
+// nodes. Plugins run in process but sandboxed, with no access to files,
+// the network or the environment, and are reloaded when the directory
+// changes.
+//
+// A plugin is a module exporting its memory and:
+//
+//   - alloc(size i32) i32, returning size bytes of its memory that the host
+//     writes the input of a call to;
+//   - mutate(ptr i32, len i32) i64, optionally, handed the hook.Input of a
+//     document as JSON and returning the hook.Output;
+//   - resolve(ptr i32, len i32) i64, optionally, handed a ResolveInput as
+//     JSON and returning a ResolveOutput.
+//
+// Outputs are JSON in the memory of the plugin, returned as their address
+// in the upper 32 bits of the result and their length in the lower 32 bits;
+// a zero result is an empty output. Plugins may import WASI preview 1 and
+// are instantiated afresh, calling _initialize when exported, for every
+// call, so they keep no state between calls.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Defaults of Config.
+const (
+	DefaultTimeout        = time.Second
+	DefaultReloadInterval = 10 * time.Second
+)
+
+// Extension is the extension of the plugin files of a directory.
+const Extension = ".wasm"
+
+// maxMemoryPages bounds the memory of a plugin, in 64 KiB pages.
+const maxMemoryPages = 512
+
+// Functions exported by plugins.
+const (
+	exportAlloc   = "alloc"
+	exportMutate  = "mutate"
+	exportResolve = "resolve"
+	exportMemory  = "memory"
+)
+
+// Config configures Plugins.
+type Config struct {
+	// Dir holds the plugins, one module per file with the Extension, run
+	// in the order of their names.
+	Dir string
+
+	// Timeout bounds a call of a plugin, defaulting to DefaultTimeout.
+	Timeout time.Duration
+
+	// ReloadInterval is how often Run looks for changed plugins,
+	// defaulting to DefaultReloadInterval.
+	ReloadInterval time.Duration
+
+	// OnLoad is called when Run loads a new or changed plugin, or removes
+	// a deleted one.
+	OnLoad func(name string, removed bool)
+
+	// OnError is called when Run fails to load a plugin, which keeps its
+	// previous version, if any, or with an empty name to read Dir.
+	OnError func(name string, err error)
+}
+
+// ResolveInput is the input of the resolve function of a plugin.
+type ResolveInput struct {
+	ClientIP string `json:"client_ip"`
+}
+
+// ResolveOutput is the output of the resolve function of a plugin. An
+// empty output, or one without a node, knows no node for the client IP.
+type ResolveOutput struct {
+	// Node is the UUID or name of the node of the client IP.
+	Node string `json:"node,omitempty"`
+}
+
+// Plugins runs the plugins of a directory.
+type Plugins struct {
+	cfg     Config
+	runtime wazero.Runtime
+
+	mu      sync.RWMutex
+	plugins []*plugin
+}
+
+// plugin is a compiled plugin.
+type plugin struct {
+	name     string
+	size     int64
+	modTime  time.Time
+	compiled wazero.CompiledModule
+	mutate   bool
+	resolve  bool
+}
+
+// New returns Plugins running the plugins of cfg.Dir, failing when one of
+// them cannot be loaded.
+func New(ctx context.Context, cfg Config) (*Plugins, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("a plugins directory is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = DefaultReloadInterval
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(maxMemoryPages).
+		WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+	p := &Plugins{cfg: cfg, runtime: runtime}
+
+	files, err := p.files()
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	for _, file := range files {
+		loaded, err := p.load(ctx, file)
+		if err != nil {
+			_ = runtime.Close(ctx)
+			return nil, fmt.Errorf("failed to load plugin %s: %w", file.name, err)
+		}
+		p.plugins = append(p.plugins, loaded)
+	}
+	return p, nil
+}
+
+// Names returns the names of the loaded plugins, in the order they run.
+func (p *Plugins) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := make([]string, 0, len(p.plugins))
+	for _, loaded := range p.plugins {
+		names = append(names, loaded.name)
+	}
+	return names
+}
+
+// Run reloads the plugins of the directory when they change, until ctx is
+// done.
+func (p *Plugins) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Reload(ctx); err != nil && ctx.Err() == nil {
+				p.error("", err)
+			}
+		}
+	}
+}
+
+// Reload loads the plugins of the directory added or changed since they
+// were last loaded, and drops the deleted ones. A plugin failing to load
+// keeps its previous version.
+func (p *Plugins) Reload(ctx context.Context) error {
+	files, err := p.files()
+	if err != nil {
+		return err
+	}
+
+	p.mu.RLock()
+	current := make(map[string]*plugin, len(p.plugins))
+	for _, loaded := range p.plugins {
+		current[loaded.name] = loaded
+	}
+	p.mu.RUnlock()
+
+	next := make([]*plugin, 0, len(files))
+	changed := len(files) != len(current)
+	for _, file := range files {
+		previous := current[file.name]
+		if previous != nil && previous.size == file.size && previous.modTime.Equal(file.modTime) {
+			next = append(next, previous)
+			continue
+		}
+		loaded, err := p.load(ctx, file)
+		if err != nil {
+			p.error(file.name, err)
+			if previous != nil {
+				next = append(next, previous)
+			}
+			continue
+		}
+		next = append(next, loaded)
+		changed = true
+		p.loaded(file.name, false)
+	}
+	if !changed {
+		return nil
+	}
+
+	kept := make(map[*plugin]bool, len(next))
+	for _, loaded := range next {
+		kept[loaded] = true
+	}
+	p.mu.Lock()
+	p.plugins = next
+	p.mu.Unlock()
+
+	// Calls hold the read lock, so none runs the replaced plugins anymore.
+	for name, previous := range current {
+		if kept[previous] {
+			continue
+		}
+		_ = previous.compiled.Close(ctx)
+		if !pluginsContain(next, name) {
+			p.loaded(name, true)
+		}
+	}
+	return nil
+}
+
+// Close closes the plugins.
+func (p *Plugins) Close(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.plugins = nil
+	return p.runtime.Close(ctx)
+}
+
+// Mutate implements hook.Mutator, handing input to the mutate function of
+// every plugin exporting one, each given the document as the previous one
+// returned it.
+func (p *Plugins) Mutate(ctx context.Context, input *hook.Input) (json.RawMessage, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var chain hook.Chain
+	for _, loaded := range p.plugins {
+		if loaded.mutate {
+			chain = append(chain, mutator{p: p, plugin: loaded})
+		}
+	}
+	return chain.Mutate(ctx, input)
+}
+
+// mutator is a hook.Mutator calling the mutate function of a plugin.
+type mutator struct {
+	p      *Plugins
+	plugin *plugin
+}
+
+func (m mutator) Mutate(ctx context.Context, input *hook.Input) (json.RawMessage, error) {
+	out, err := m.p.call(ctx, m.plugin, exportMutate, input)
+	if err != nil || len(bytes.TrimSpace(out)) == 0 {
+		return nil, err
+	}
+	var output hook.Output
+	if err := json.Unmarshal(out, &output); err != nil {
+		return nil, fmt.Errorf("failed to decode output of plugin %s: %w", m.plugin.name, err)
+	}
+	return output.Document, nil
+}
+
+// Resolve asks the resolve function of every plugin exporting one, in
+// turn, for the node of a client IP. It returns the UUID or name of the
+// node and the plugin that resolved it, or empty strings when none did.
+func (p *Plugins) Resolve(ctx context.Context, clientIP string) (string, string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, loaded := range p.plugins {
+		if !loaded.resolve {
+			continue
+		}
+		out, err := p.call(ctx, loaded, exportResolve, &ResolveInput{ClientIP: clientIP})
+		if err != nil {
+			return "", loaded.name, err
+		}
+		if len(bytes.TrimSpace(out)) == 0 {
+			continue
+		}
+		var output ResolveOutput
+		if err := json.Unmarshal(out, &output); err != nil {
+			return "", loaded.name, fmt.Errorf("failed to decode output of plugin %s: %w",
+				loaded.name, err)
+		}
+		if output.Node != "" {
+			return output.Node, loaded.name, nil
+		}
+	}
+	return "", "", nil
+}
+
+// call calls a function of a fresh instance of a plugin with input as
+// JSON, returning its output.
+func (p *Plugins) call(
+	ctx context.Context,
+	loaded *plugin,
+	function string,
+	input any,
+) ([]byte, error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plugin input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+	out, err := p.invoke(ctx, loaded, function, b)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("plugin %s timed out after %s", loaded.name, p.cfg.Timeout)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w", loaded.name, err)
+	}
+	return out, nil
+}
+
+// invoke instantiates a plugin and calls one of its functions with input.
+func (p *Plugins) invoke(
+	ctx context.Context,
+	loaded *plugin,
+	function string,
+	input []byte,
+) ([]byte, error) {
+	module, err := p.runtime.InstantiateModule(ctx, loaded.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate: %w", err)
+	}
+	defer func() {
+		_ = module.Close(context.WithoutCancel(ctx))
+	}()
+
+	results, err := module.ExportedFunction(exportAlloc).Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate input: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !module.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("input of %d bytes at %#x is out of memory", len(input), ptr)
+	}
+
+	results, err = module.ExportedFunction(function).Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	if results[0] == 0 {
+		return nil, nil
+	}
+	ptr, size := uint32(results[0]>>32), uint32(results[0])
+	out, ok := module.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("output of %d bytes at %#x is out of memory", size, ptr)
+	}
+	// The memory is released with the instance.
+	return bytes.Clone(out), nil
+}
+
+// pluginFile is a plugin file of the directory.
+type pluginFile struct {
+	name    string
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files returns the plugin files of the directory, sorted by name.
+func (p *Plugins) files() ([]pluginFile, error) {
+	entries, err := os.ReadDir(p.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+	var files []pluginFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != Extension {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat plugin: %w", err)
+		}
+		files = append(files, pluginFile{
+			name:    strings.TrimSuffix(entry.Name(), Extension),
+			path:    filepath.Join(p.cfg.Dir, entry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	return files, nil
+}
+
+// load compiles a plugin file and checks the functions it exports.
+func (p *Plugins) load(ctx context.Context, file pluginFile) (*plugin, error) {
+	b, err := os.ReadFile(file.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin: %w", err)
+	}
+	compiled, err := p.runtime.CompileModule(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile plugin: %w", err)
+	}
+
+	loaded := &plugin{
+		name:     file.name,
+		size:     file.size,
+		modTime:  file.modTime,
+		compiled: compiled,
+	}
+	if err := loaded.check(); err != nil {
+		_ = compiled.Close(ctx)
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// check checks the exports of a plugin against the ABI, recording the
+// functions it implements.
+func (l *plugin) check() error {
+	if _, ok := l.compiled.ExportedMemories()[exportMemory]; !ok {
+		return errors.New("plugin does not export its memory")
+	}
+	functions := l.compiled.ExportedFunctions()
+	if !hasSignature(functions[exportAlloc], []api.ValueType{api.ValueTypeI32},
+		[]api.ValueType{api.ValueTypeI32}) {
+		return errors.New("plugin does not export alloc(i32) i32")
+	}
+	call := []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}
+	result := []api.ValueType{api.ValueTypeI64}
+	for name, implemented := range map[string]*bool{
+		exportMutate:  &l.mutate,
+		exportResolve: &l.resolve,
+	} {
+		definition, ok := functions[name]
+		if !ok {
+			continue
+		}
+		if !hasSignature(definition, call, result) {
+			return fmt.Errorf("plugin exports %s, but not as %s(i32, i32) i64", name, name)
+		}
+		*implemented = true
+	}
+	if !l.mutate && !l.resolve {
+		return errors.New("plugin exports neither mutate nor resolve")
+	}
+	return nil
+}
+
+// hasSignature reports whether a function has the params and results.
+func hasSignature(definition api.FunctionDefinition, params, results []api.ValueType) bool {
+	return definition != nil &&
+		bytes.Equal(definition.ParamTypes(), params) &&
+		bytes.Equal(definition.ResultTypes(), results)
+}
+
+// pluginsContain reports whether plugins has one named name.
+func pluginsContain(plugins []*plugin, name string) bool {
+	for _, loaded := range plugins {
+		if loaded.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loaded reports a loaded or removed plugin to OnLoad.
+func (p *Plugins) loaded(name string, removed bool) {
+	if p.cfg.OnLoad != nil {
+		p.cfg.OnLoad(name, removed)
+	}
+}
+
+// error reports a failure to OnError.
+func (p *Plugins) error(name string, err error) {
+	if p.cfg.OnError != nil {
+		p.cfg.OnError(name, err)
+	}
+}
@@ -0,0 +1,215 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/hook"
+	"github.com/appkins-org/ironic-metadata/pkg/testutil/wasmplugin"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		module  []byte
+		wantErr bool
+	}{
+		{name: "resolver", module: wasmplugin.Resolver(`{"node":"node-1"}`)},
+		{name: "invalid module", module: []byte("not wasm"), wantErr: true},
+		{name: "no functions", module: wasmplugin.Module(""), wantErr: true},
+		{
+			name: "wrong signature",
+			module: wasmplugin.Module("", wasmplugin.Function{
+				Name: exportMutate,
+				Body: []byte{0x20, 0x00}, // local.get 0
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			wasmplugin.Write(t, dir, "plugin", tt.module, time.Now())
+			p, err := New(context.Background(), Config{Dir: dir})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer func() {
+				_ = p.Close(context.Background())
+			}()
+			if have := p.Names(); !slices.Equal(have, []string{"plugin"}) {
+				t.Errorf("have plugins %v, want [plugin]", have)
+			}
+		})
+	}
+}
+
+func TestPlugins_Mutate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	// Plugins run in the order of their names, each given the document
+	// the previous one returned.
+	wasmplugin.Write(t, dir, "10-role", wasmplugin.Mutator(`{"document":{"role":"web"}}`), now)
+	echo := wasmplugin.Module("", wasmplugin.Mutate(wasmplugin.Echo))
+	wasmplugin.Write(t, dir, "20-echo", echo, now)
+	wasmplugin.Write(t, dir, "30-unchanged", wasmplugin.Mutator(""), now)
+
+	p, err := New(context.Background(), Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = p.Close(context.Background())
+	}()
+
+	mutated, err := p.Mutate(context.Background(), &hook.Input{
+		ClientIP: "10.0.0.5",
+		Endpoint: "meta_data.json",
+		Document: map[string]any{"uuid": "node-1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var document map[string]any
+	if err := json.Unmarshal(mutated, &document); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if document["role"] != "web" || len(document) != 1 {
+		t.Errorf("have document %s, want {\"role\":\"web\"}", mutated)
+	}
+}
+
+func TestPlugins_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	wasmplugin.Write(t, dir, "10-none", wasmplugin.Resolver(""), now)
+	wasmplugin.Write(t, dir, "20-rack", wasmplugin.Resolver(`{"node":"node-1"}`), now)
+
+	p, err := New(context.Background(), Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = p.Close(context.Background())
+	}()
+
+	node, plugin, err := p.Resolve(context.Background(), "10.0.0.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node != "node-1" || plugin != "20-rack" {
+		t.Errorf("have node %q from %q, want node-1 from 20-rack", node, plugin)
+	}
+}
+
+func TestPlugins_timeout(t *testing.T) {
+	dir := t.TempDir()
+	spin := wasmplugin.Module("", wasmplugin.Resolve(wasmplugin.Spin))
+	wasmplugin.Write(t, dir, "spin", spin, time.Now())
+
+	p, err := New(context.Background(), Config{Dir: dir, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = p.Close(context.Background())
+	}()
+
+	_, _, err = p.Resolve(context.Background(), "10.0.0.5")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("have error %v, want a timeout", err)
+	}
+}
+
+func TestPlugins_Reload(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	resolver := func(node string) []byte {
+		return wasmplugin.Resolver(`{"node":"` + node + `"}`)
+	}
+	wasmplugin.Write(t, dir, "rack", resolver("node-1"), now)
+
+	var loaded, removed, failed []string
+	p, err := New(context.Background(), Config{
+		Dir: dir,
+		OnLoad: func(name string, isRemoved bool) {
+			if isRemoved {
+				removed = append(removed, name)
+			} else {
+				loaded = append(loaded, name)
+			}
+		},
+		OnError: func(name string, err error) {
+			failed = append(failed, name)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = p.Close(context.Background())
+	}()
+	resolve := func() string {
+		t.Helper()
+		node, _, err := p.Resolve(context.Background(), "10.0.0.5")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return node
+	}
+
+	// An unchanged directory loads nothing.
+	if err := p.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(loaded) != 0 {
+		t.Errorf("have loaded %v, want none", loaded)
+	}
+
+	// A changed plugin replaces its previous version.
+	wasmplugin.Write(t, dir, "rack", resolver("node-2"), now.Add(time.Second))
+	if err := p.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := resolve(); have != "node-2" {
+		t.Errorf("have node %q, want node-2", have)
+	}
+
+	// A plugin failing to load keeps its previous version.
+	wasmplugin.Write(t, dir, "rack", []byte("not wasm"), now.Add(2*time.Second))
+	if err := p.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := resolve(); have != "node-2" {
+		t.Errorf("have node %q, want node-2", have)
+	}
+	if !slices.Equal(failed, []string{"rack"}) {
+		t.Errorf("have failed %v, want [rack]", failed)
+	}
+
+	// A deleted plugin is dropped.
+	if err := os.Remove(filepath.Join(dir, "rack"+Extension)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := resolve(); have != "" {
+		t.Errorf("have node %q, want none", have)
+	}
+	if !slices.Equal(loaded, []string{"rack"}) || !slices.Equal(removed, []string{"rack"}) {
+		t.Errorf("have loaded %v and removed %v, want [rack] and [rack]", loaded, removed)
+	}
+}
@@ -0,0 +1,200 @@
+// Package profiles selects the operator defined metadata profiles of nodes,
+// so fleets of identical hardware get consistent metadata without per-node
+// configuration.
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+
+	"github.com/appkins-org/ironic-metadata/pkg/userdata"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"gopkg.in/yaml.v2"
+)
+
+// file is the YAML document of a profiles file.
+type file struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// Profile is metadata shared by the nodes it matches.
+type Profile struct {
+	Name  string `yaml:"name"`
+	Match Match  `yaml:"match"`
+
+	// Meta holds defaults of the meta of meta_data.json, which node
+	// properties override.
+	Meta map[string]string `yaml:"meta"`
+
+	// UserData is layered before the user data of the node, like the user
+	// data fragments of the node.
+	UserData string `yaml:"user_data"`
+
+	// VendorData and VendorData2 are merged into vendor_data.json and
+	// vendor_data2.json.
+	VendorData  map[string]any `yaml:"vendor_data"`
+	VendorData2 map[string]any `yaml:"vendor_data2"`
+
+	Network Network `yaml:"network"`
+}
+
+// Match selects the nodes of a profile. A node matches when it matches
+// every selector set; a profile without selectors matches every node.
+type Match struct {
+	// ResourceClass is the resource class of the node.
+	ResourceClass string `yaml:"resource_class"`
+
+	// Traits must all be traits of the node.
+	Traits []string `yaml:"traits"`
+
+	// DeployTemplate is the name of a deploy template applied to the
+	// instance. Ironic applies the deploy templates named after the traits
+	// the instance requests in its instance_info.
+	DeployTemplate string `yaml:"deploy_template"`
+}
+
+// Network holds defaults of the network data of nodes.
+type Network struct {
+	// MTU is set on links without an MTU.
+	MTU int `yaml:"mtu"`
+
+	// DNSNameservers are served as dns services when the network data has
+	// none.
+	DNSNameservers []string `yaml:"dns_nameservers"`
+}
+
+// Load reads and validates a profiles file.
+func Load(path string) ([]Profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// Parse parses and validates the YAML document of a profiles file.
+func Parse(b []byte) ([]Profile, error) {
+	var f file
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for i := range f.Profiles {
+		profile := &f.Profiles[i]
+		switch {
+		case profile.Name == "":
+			return nil, fmt.Errorf("profile %d has no name", i)
+		case names[profile.Name]:
+			return nil, fmt.Errorf("profile %q is defined twice", profile.Name)
+		}
+		names[profile.Name] = true
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", profile.Name, err)
+		}
+		profile.VendorData = normalize(profile.VendorData)
+		profile.VendorData2 = normalize(profile.VendorData2)
+	}
+	return f.Profiles, nil
+}
+
+// validate checks the fields of a profile.
+func (p *Profile) validate() error {
+	if slices.Contains(p.Match.Traits, "") {
+		return errors.New("empty trait")
+	}
+	for key := range p.Meta {
+		if key == "" {
+			return errors.New("empty meta key")
+		}
+	}
+	if p.UserData != "" {
+		if _, err := userdata.Validate([]byte(p.UserData)); err != nil {
+			return fmt.Errorf("invalid user_data: %w", err)
+		}
+	}
+	if p.Network.MTU != 0 && (p.Network.MTU < 68 || p.Network.MTU > 65535) {
+		return fmt.Errorf("invalid mtu %d", p.Network.MTU)
+	}
+	for _, nameserver := range p.Network.DNSNameservers {
+		if _, err := netip.ParseAddr(nameserver); err != nil {
+			return fmt.Errorf("invalid dns nameserver %q", nameserver)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a node matches the profile.
+func (p *Profile) Matches(node *nodes.Node) bool {
+	m := p.Match
+	if m.ResourceClass != "" && m.ResourceClass != node.ResourceClass {
+		return false
+	}
+	for _, trait := range m.Traits {
+		if !slices.Contains(node.Traits, trait) {
+			return false
+		}
+	}
+	if m.DeployTemplate != "" && !slices.Contains(instanceTraits(node), m.DeployTemplate) {
+		return false
+	}
+	return true
+}
+
+// Select returns the profiles matching a node, in the order of profiles.
+func Select(profiles []Profile, node *nodes.Node) []*Profile {
+	var selected []*Profile
+	for i := range profiles {
+		if profiles[i].Matches(node) {
+			selected = append(selected, &profiles[i])
+		}
+	}
+	return selected
+}
+
+// instanceTraits returns the traits requested by the instance of a node,
+// which name the deploy templates Ironic applies to it.
+func instanceTraits(node *nodes.Node) []string {
+	values, _ := node.InstanceInfo["traits"].([]any)
+	traits := make([]string, 0, len(values))
+	for _, value := range values {
+		if trait, ok := value.(string); ok {
+			traits = append(traits, trait)
+		}
+	}
+	return traits
+}
+
+// normalize converts the map[any]any values produced by yaml.v2 into
+// map[string]any so vendor data can be encoded as JSON.
+func normalize(m map[string]any) map[string]any {
+	for key, value := range m {
+		m[key] = normalizeValue(value)
+	}
+	return m
+}
+
+// normalizeValue converts a value of a YAML document for normalize.
+func normalizeValue(v any) any {
+	switch val := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = normalizeValue(item)
+		}
+		return m
+	case []any:
+		for i, item := range val {
+			val[i] = normalizeValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
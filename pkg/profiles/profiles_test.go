@@ -0,0 +1,140 @@
+package profiles
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    string
+		want    []Profile
+		wantErr bool
+	}{
+		{
+			name: "profile",
+			have: `
+profiles:
+  - name: gpu
+    match:
+      resource_class: baremetal-gpu
+      traits: [CUSTOM_GPU_A100]
+    meta:
+      role: gpu
+    vendor_data:
+      gpu:
+        driver: "550"
+    network:
+      mtu: 9000
+      dns_nameservers: [10.0.0.2]
+`,
+			want: []Profile{{
+				Name: "gpu",
+				Match: Match{
+					ResourceClass: "baremetal-gpu",
+					Traits:        []string{"CUSTOM_GPU_A100"},
+				},
+				Meta:       map[string]string{"role": "gpu"},
+				VendorData: map[string]any{"gpu": map[string]any{"driver": "550"}},
+				Network:    Network{MTU: 9000, DNSNameservers: []string{"10.0.0.2"}},
+			}},
+		},
+		{name: "no name", have: "profiles:\n  - meta: {role: gpu}\n", wantErr: true},
+		{
+			name:    "duplicate",
+			have:    "profiles:\n  - name: gpu\n  - name: gpu\n",
+			wantErr: true,
+		},
+		{name: "unknown field", have: "profiles:\n  - name: gpu\n    metas: {}\n", wantErr: true},
+		{
+			name:    "invalid user data",
+			have:    "profiles:\n  - name: gpu\n    user_data: hello\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid mtu",
+			have:    "profiles:\n  - name: gpu\n    network: {mtu: 12}\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid nameserver",
+			have:    "profiles:\n  - name: gpu\n    network: {dns_nameservers: [ns1]}\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := Parse([]byte(tt.have))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %+v, want %+v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	profiles := []Profile{
+		{Name: "all"},
+		{Name: "class", Match: Match{ResourceClass: "baremetal-gpu"}},
+		{Name: "traits", Match: Match{Traits: []string{"CUSTOM_GPU", "CUSTOM_NVME"}}},
+		{Name: "template", Match: Match{DeployTemplate: "CUSTOM_RAID1"}},
+		{
+			Name:  "class and template",
+			Match: Match{ResourceClass: "baremetal-cpu", DeployTemplate: "CUSTOM_RAID1"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		node nodes.Node
+		want []string
+	}{
+		{name: "no selectors", node: nodes.Node{}, want: []string{"all"}},
+		{
+			name: "resource class",
+			node: nodes.Node{ResourceClass: "baremetal-gpu"},
+			want: []string{"all", "class"},
+		},
+		{
+			name: "every trait",
+			node: nodes.Node{Traits: []string{"CUSTOM_NVME", "CUSTOM_GPU"}},
+			want: []string{"all", "traits"},
+		},
+		{
+			name: "some traits",
+			node: nodes.Node{Traits: []string{"CUSTOM_GPU"}},
+			want: []string{"all"},
+		},
+		{
+			name: "deploy template",
+			node: nodes.Node{
+				ResourceClass: "baremetal-gpu",
+				InstanceInfo:  map[string]any{"traits": []any{"CUSTOM_RAID1"}},
+			},
+			want: []string{"all", "class", "template"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var have []string
+			for _, profile := range Select(profiles, &tt.node) {
+				have = append(have, profile.Name)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package remote
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Verify checks data against a checksum in the forms Ironic accepts for
+// image_checksum: <algorithm>:<hex digest>, or a bare hex digest whose
+// algorithm (md5, sha256 or sha512) is inferred from its length.
+func Verify(data []byte, checksum string) error {
+	algorithm, digest, ok := strings.Cut(strings.TrimSpace(checksum), ":")
+	if !ok {
+		digest = algorithm
+		switch len(digest) {
+		case md5.Size * 2:
+			algorithm = "md5"
+		case sha256.Size * 2:
+			algorithm = "sha256"
+		case sha512.Size * 2:
+			algorithm = "sha512"
+		default:
+			return fmt.Errorf("cannot infer the algorithm of checksum %q", checksum)
+		}
+	}
+
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		h = md5.New()
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+
+	h.Write(data)
+	if have := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(have, digest) {
+		return fmt.Errorf("checksum mismatch: have %s:%s, want %s:%s", algorithm, have, algorithm, digest)
+	}
+	return nil
+}
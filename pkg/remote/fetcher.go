@@ -0,0 +1,100 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/lru"
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+)
+
+// Fetcher caches downloaded blobs by reference.
+type Fetcher struct {
+	downloader *Downloader
+	ttl        time.Duration
+
+	mu       sync.Mutex
+	cache    *lru.Cache[string, cacheEntry]
+	counters metrics.CacheCounters
+}
+
+// cacheEntry is a downloaded blob, the time it was stored and the time it
+// stops being valid.
+type cacheEntry struct {
+	data    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// NewFetcher returns a fetcher caching the blobs downloaded through
+// downloader for ttl.
+func NewFetcher(downloader *Downloader, ttl time.Duration) *Fetcher {
+	return &Fetcher{
+		downloader: downloader,
+		ttl:        ttl,
+		cache:      lru.New[string, cacheEntry](lru.Limits{}),
+	}
+}
+
+// SetLimits bounds the cache, which evicts the least recently used blobs
+// beyond limits.
+func (f *Fetcher) SetLimits(limits lru.Limits) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counters.Evict(f.cache.SetLimits(limits))
+}
+
+// Fetch returns the blob at ref, downloading it when it is not cached.
+// Blobs larger than limit bytes are rejected.
+func (f *Fetcher) Fetch(ctx context.Context, ref string, limit int64) ([]byte, error) {
+	now := time.Now()
+
+	f.mu.Lock()
+	if entry, ok := f.cache.Get(ref); ok && now.Before(entry.expires) && int64(len(entry.data)) <= limit {
+		f.counters.Lookup(true)
+		f.mu.Unlock()
+		return entry.data, nil
+	}
+	f.counters.Lookup(false)
+	f.mu.Unlock()
+
+	data, err := f.downloader.Download(ctx, ref, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.counters.Evict(f.cache.Put(ref,
+		cacheEntry{data: data, stored: now, expires: CacheExpiry(ref, now, f.ttl)},
+		int64(len(data))))
+	f.mu.Unlock()
+
+	return data, nil
+}
+
+// CacheStats returns the usage of the cache.
+func (f *Fetcher) CacheStats() metrics.CacheStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := f.counters.Stats(f.cache.Len(), func(yield func(time.Time) bool) {
+		for _, entry := range f.cache.All() {
+			if !yield(entry.stored) {
+				return
+			}
+		}
+	})
+	stats.Bytes = f.cache.Bytes()
+	return stats
+}
+
+// CacheExpiry returns when a blob downloaded from ref at now stops being
+// valid. Temp URLs stop working once they expire, so blobs are not cached
+// for longer than Ironic intended them to be reachable.
+func CacheExpiry(ref string, now time.Time, ttl time.Duration) time.Time {
+	expires := now.Add(ttl)
+	if tempURLExpires, ok := Expiry(ref); ok && tempURLExpires.Before(expires) {
+		expires = tempURLExpires
+	}
+	return expires
+}
@@ -0,0 +1,148 @@
+// Package remote downloads blobs that Ironic references by URL rather than
+// storing them inline: configdrives and user data kept in object storage.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/objectstorage/v1/objects"
+)
+
+// tempURLTTL is the lifetime of temp URLs generated for swift:// references.
+const tempURLTTL = 5 * time.Minute
+
+// IsRemote reports whether ref points to a download location rather than
+// holding a blob itself.
+func IsRemote(ref string) bool {
+	return strings.HasPrefix(ref, "http://") ||
+		strings.HasPrefix(ref, "https://") ||
+		strings.HasPrefix(ref, "swift://") ||
+		strings.HasPrefix(ref, "s3://")
+}
+
+// Downloader fetches http(s) URLs, swift://<container>/<object> references
+// through a Swift temp URL and s3://<bucket>/<key> references with signed
+// requests.
+type Downloader struct {
+	httpClient *http.Client
+	swift      *gophercloud.ServiceClient
+	tempURLKey string
+	s3         *S3Config
+}
+
+// NewDownloader returns a downloader. swift is only needed for swift://
+// references, which are downloaded through a temp URL signed with
+// tempURLKey, or with the account's key when tempURLKey is empty. s3 is
+// only needed for s3:// references.
+func NewDownloader(swift *gophercloud.ServiceClient, tempURLKey string, s3 *S3Config) *Downloader {
+	return &Downloader{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		swift:      swift,
+		tempURLKey: tempURLKey,
+		s3:         s3,
+	}
+}
+
+// Download fetches the blob at ref, failing when it exceeds limit bytes.
+func (d *Downloader) Download(ctx context.Context, ref string, limit int64) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(ref, "swift://"):
+		tempURL, err := d.tempURL(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tempURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		return d.do(req, limit)
+	case strings.HasPrefix(ref, "s3://"):
+		req, err := d.s3Request(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		return d.do(req, limit)
+	}
+
+	if _, err := url.Parse(ref); err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return d.do(req, limit)
+}
+
+// tempURL signs a temp URL for a swift://<container>/<object> reference.
+func (d *Downloader) tempURL(ctx context.Context, ref string) (string, error) {
+	if d.swift == nil {
+		return "", fmt.Errorf("no object storage client configured for %s", ref)
+	}
+
+	container, object, ok := strings.Cut(strings.TrimPrefix(ref, "swift://"), "/")
+	if !ok || container == "" || object == "" {
+		return "", fmt.Errorf("invalid swift reference %q", ref)
+	}
+
+	tempURL, err := objects.CreateTempURL(ctx, d.swift, container, object, objects.CreateTempURLOpts{
+		Method:     http.MethodGet,
+		TTL:        int(tempURLTTL.Seconds()),
+		TempURLKey: d.tempURLKey,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp URL for %s: %w", ref, err)
+	}
+	return tempURL, nil
+}
+
+// do sends a download request. Errors only name the host, since temp URLs
+// carry their signature in the query string.
+func (d *Downloader) do(req *http.Request, limit int64) ([]byte, error) {
+	host := req.URL.Host
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from %s: %w", host, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download from %s: %s", host, resp.Status)
+	}
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("download from %s exceeds %d bytes", host, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download from %s: %w", host, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("download from %s exceeds %d bytes", host, limit)
+	}
+	return data, nil
+}
+
+// Expiry returns the expiry of a Swift temp URL.
+func Expiry(ref string) (time.Time, bool) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return time.Time{}, false
+	}
+	expires, err := strconv.ParseInt(u.Query().Get("temp_url_expires"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(expires, 0), true
+}
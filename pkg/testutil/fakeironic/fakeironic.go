@@ -0,0 +1,496 @@
+// Package fakeironic runs an in-process fake of the Ironic bare metal API,
+// serving enough of nodes, ports and drivers to exercise the whole request
+// path of the metadata service in tests.
+package fakeironic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+// maxPatchSize bounds the body of node PATCH requests.
+const maxPatchSize = 1 << 20
+
+// nodeListFields are the fields of nodes in listings without details.
+var nodeListFields = []string{
+	"uuid", "name", "instance_uuid", "power_state", "provision_state", "maintenance", "links",
+}
+
+// portListFields are the fields of ports in listings without details.
+var portListFields = []string{"uuid", "address", "links"}
+
+// Server is a fake Ironic API. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	nodes    []nodes.Node
+	ports    []ports.Port
+	drivers  []string
+	pageSize int
+	failure  int
+	requests []string
+}
+
+// New starts a fake Ironic API with the ipmi driver and no nodes, closed
+// when the test finishes.
+func New(t testing.TB) *Server {
+	s := &Server{drivers: []string{"ipmi"}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Endpoint returns the endpoint of the bare metal API, ending in /v1/.
+func (s *Server) Endpoint() string {
+	return s.URL + "/v1/"
+}
+
+// ServiceClient returns a gophercloud client of the API.
+func (s *Server) ServiceClient() *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{HTTPClient: *s.Client()},
+		Endpoint:       s.Endpoint(),
+	}
+}
+
+// AddNode adds a node, replacing any node with the same UUID.
+func (s *Server) AddNode(node nodes.Node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.nodes {
+		if s.nodes[i].UUID == node.UUID {
+			s.nodes[i] = node
+			return
+		}
+	}
+	s.nodes = append(s.nodes, node)
+}
+
+// RemoveNode removes the node with a UUID and its ports.
+func (s *Server) RemoveNode(uuid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []nodes.Node
+	for _, node := range s.nodes {
+		if node.UUID != uuid {
+			kept = append(kept, node)
+		}
+	}
+	s.nodes = kept
+
+	var keptPorts []ports.Port
+	for _, port := range s.ports {
+		if port.NodeUUID != uuid {
+			keptPorts = append(keptPorts, port)
+		}
+	}
+	s.ports = keptPorts
+}
+
+// Node returns the node with a UUID or name as it is now, including updates
+// made through the API.
+func (s *Server) Node(id string) (nodes.Node, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.findNode(id); i >= 0 {
+		return s.nodes[i], true
+	}
+	return nodes.Node{}, false
+}
+
+// AddPort adds a port.
+func (s *Server) AddPort(port ports.Port) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ports = append(s.ports, port)
+}
+
+// SetDrivers sets the names of the enabled drivers. Clients waiting for a
+// conductor wait while there are none.
+func (s *Server) SetDrivers(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.drivers = names
+}
+
+// SetPageSize limits how many resources a listing returns before linking to
+// the next page, like Ironic's max_limit. Zero, the default, returns every
+// resource unless the request sets a limit.
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pageSize = n
+}
+
+// SetFailure makes every request fail with an HTTP status, such as 503,
+// until it is set to zero.
+func (s *Server) SetFailure(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failure = status
+}
+
+// Requests returns the requests served so far, such as
+// "GET /v1/nodes/detail".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// serveHTTP routes a request to the fake API.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if s.failure != 0 {
+		writeError(w, s.failure, http.StatusText(s.failure))
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	segments := strings.Split(path, "/")
+	switch {
+	case path == "" || path == "v1":
+		s.serveRoot(w, r)
+	case segments[0] != "v1":
+		writeError(w, http.StatusNotFound, "The resource could not be found.")
+	case len(segments) == 2 && segments[1] == "drivers":
+		s.serveDrivers(w, r)
+	case len(segments) == 2 && segments[1] == "nodes":
+		s.serveNodes(w, r, false)
+	case len(segments) == 3 && segments[1] == "nodes" && segments[2] == "detail":
+		s.serveNodes(w, r, true)
+	case len(segments) == 3 && segments[1] == "nodes":
+		s.serveNode(w, r, segments[2])
+	case len(segments) == 2 && segments[1] == "ports":
+		s.servePorts(w, r, false)
+	case len(segments) == 3 && segments[1] == "ports" && segments[2] == "detail":
+		s.servePorts(w, r, true)
+	default:
+		writeError(w, http.StatusNotFound, "The resource could not be found.")
+	}
+}
+
+// serveRoot serves the version discovery documents.
+func (s *Server) serveRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	version := map[string]any{
+		"id":          "v1",
+		"status":      "CURRENT",
+		"min_version": "1.1",
+		"version":     "1.99",
+		"links":       []any{map[string]any{"href": s.Endpoint(), "rel": "self"}},
+	}
+	if strings.Trim(r.URL.Path, "/") == "" {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"name":            "OpenStack Ironic API",
+			"versions":        []any{version},
+			"default_version": version,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": "v1", "version": version})
+}
+
+// serveDrivers serves GET /v1/drivers.
+func (s *Server) serveDrivers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	drivers := make([]any, 0, len(s.drivers))
+	for _, name := range s.drivers {
+		drivers = append(drivers, map[string]any{
+			"name":  name,
+			"hosts": []string{"fake-conductor"},
+			"type":  "dynamic",
+			"links": []any{},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"drivers": drivers})
+}
+
+// serveNodes serves GET /v1/nodes and /v1/nodes/detail, filtered by the
+// provision_state, owner and conductor_group query parameters, and listing
+// the fields of the fields parameter without details.
+func (s *Server) serveNodes(w http.ResponseWriter, r *http.Request, detail bool) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	query := r.URL.Query()
+	fields := nodeListFields
+	if v := query.Get("fields"); v != "" {
+		if detail {
+			writeError(w, http.StatusBadRequest,
+				"Can not specify ?detail=True and fields in the same request.")
+			return
+		}
+		fields = strings.Split(v, ",")
+	}
+
+	items := make([]map[string]any, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		if v := query.Get("provision_state"); v != "" && node.ProvisionState != v {
+			continue
+		}
+		if v := query.Get("owner"); v != "" && node.Owner != v {
+			continue
+		}
+		if v := query.Get("conductor_group"); v != "" && node.ConductorGroup != v {
+			continue
+		}
+		item, err := toMap(node)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !detail {
+			item = pick(item, fields)
+		}
+		items = append(items, item)
+	}
+	s.writePage(w, r, "nodes", items)
+}
+
+// serveNode serves GET and PATCH requests to /v1/nodes/{id}.
+func (s *Server) serveNode(w http.ResponseWriter, r *http.Request, id string) {
+	i := s.findNode(id)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Node %s could not be found.", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.nodes[i])
+	case http.MethodPatch:
+		var ops []nodes.UpdateOperation
+		body := http.MaxBytesReader(w, r.Body, maxPatchSize)
+		if err := json.NewDecoder(body).Decode(&ops); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid patch: %v", err))
+			return
+		}
+		node, err := applyPatch(s.nodes[i], ops)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.nodes[i] = node
+		writeJSON(w, http.StatusOK, node)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	}
+}
+
+// servePorts serves GET /v1/ports and /v1/ports/detail, filtered by the
+// address and node_uuid query parameters.
+func (s *Server) servePorts(w http.ResponseWriter, r *http.Request, detail bool) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	query := r.URL.Query()
+	address := query.Get("address")
+	nodeUUID := query.Get("node_uuid")
+
+	items := make([]map[string]any, 0, len(s.ports))
+	for _, port := range s.ports {
+		if address != "" && port.Address != address {
+			continue
+		}
+		if nodeUUID != "" && port.NodeUUID != nodeUUID {
+			continue
+		}
+		item, err := toMap(port)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !detail {
+			item = pick(item, portListFields)
+		}
+		items = append(items, item)
+	}
+	s.writePage(w, r, "ports", items)
+}
+
+// writePage writes the page of a listing selected by the limit and marker
+// query parameters, linking to the next page when there is one.
+func (s *Server) writePage(
+	w http.ResponseWriter,
+	r *http.Request,
+	collection string,
+	items []map[string]any,
+) {
+	query := r.URL.Query()
+	if marker := query.Get("marker"); marker != "" {
+		start := -1
+		for i, item := range items {
+			if item["uuid"] == marker {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			writeError(w, http.StatusBadRequest,
+				fmt.Sprintf("Marker %s could not be found.", marker))
+			return
+		}
+		items = items[start:]
+	}
+
+	limit := s.pageSize
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit %q.", v))
+			return
+		}
+		if n > 0 && (limit == 0 || n < limit) {
+			limit = n
+		}
+	}
+
+	body := map[string]any{collection: items}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+		next := url.Values{}
+		for key, values := range query {
+			next[key] = values
+		}
+		next.Set("limit", strconv.Itoa(limit))
+		next.Set("marker", fmt.Sprint(items[limit-1]["uuid"]))
+		body[collection] = items
+		body[collection+"_links"] = []any{map[string]any{
+			"href": s.URL + r.URL.Path + "?" + next.Encode(),
+			"rel":  "next",
+		}}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// findNode returns the index of the node with a UUID or name, or -1.
+func (s *Server) findNode(id string) int {
+	for i, node := range s.nodes {
+		if node.UUID == id || (node.Name != "" && node.Name == id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyPatch applies JSON patch operations to a node.
+func applyPatch(node nodes.Node, ops []nodes.UpdateOperation) (nodes.Node, error) {
+	doc, err := toMap(node)
+	if err != nil {
+		return node, err
+	}
+	for _, op := range ops {
+		names := strings.Split(strings.TrimPrefix(op.Path, "/"), "/")
+		for i, name := range names {
+			names[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+		}
+		parent := doc
+		for _, name := range names[:len(names)-1] {
+			child, ok := parent[name].(map[string]any)
+			if !ok {
+				if op.Op == nodes.RemoveOp {
+					return node, fmt.Errorf("path %s does not exist", op.Path)
+				}
+				child = make(map[string]any)
+				parent[name] = child
+			}
+			parent = child
+		}
+		last := names[len(names)-1]
+		switch op.Op {
+		case nodes.AddOp, nodes.ReplaceOp:
+			parent[last] = op.Value
+		case nodes.RemoveOp:
+			if _, ok := parent[last]; !ok {
+				return node, fmt.Errorf("path %s does not exist", op.Path)
+			}
+			delete(parent, last)
+		default:
+			return node, fmt.Errorf("unsupported patch operation %q", op.Op)
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return node, err
+	}
+	var patched nodes.Node
+	if err := json.Unmarshal(b, &patched); err != nil {
+		return node, fmt.Errorf("invalid patched node: %w", err)
+	}
+	return patched, nil
+}
+
+// toMap returns the JSON object representation of v.
+func toMap(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// pick returns the fields of m named in keys.
+func pick(m map[string]any, keys []string) map[string]any {
+	picked := make(map[string]any, len(keys))
+	for _, key := range keys {
+		if v, ok := m[key]; ok {
+			picked[key] = v
+		}
+	}
+	return picked
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format of the Ironic API, whose
+// error_message is itself JSON encoded.
+func writeError(w http.ResponseWriter, status int, message string) {
+	fault, _ := json.Marshal(map[string]any{
+		"faultstring": message,
+		"faultcode":   "Client",
+		"debuginfo":   nil,
+	})
+	writeJSON(w, status, map[string]string{"error_message": string(fault)})
+}
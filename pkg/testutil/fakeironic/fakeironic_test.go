@@ -0,0 +1,185 @@
+package fakeironic
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/drivers"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/v2/openstack/baremetal/v1/ports"
+)
+
+func TestServer_Nodes(t *testing.T) {
+	s := New(t)
+	s.SetPageSize(2)
+	for _, name := range []string{"web01", "web02", "web03"} {
+		s.AddNode(nodes.Node{
+			UUID:       "uuid-" + name,
+			Name:       name,
+			DriverInfo: map[string]any{"ipmi_address": "10.0.0.1"},
+		})
+	}
+	client := s.ServiceClient()
+
+	tests := []struct {
+		name       string
+		pager      func() ([]nodes.Node, error)
+		wantDriver bool
+	}{
+		{
+			name: "list",
+			pager: func() ([]nodes.Node, error) {
+				pages, err := nodes.List(client, nodes.ListOpts{}).AllPages(t.Context())
+				if err != nil {
+					return nil, err
+				}
+				return nodes.ExtractNodes(pages)
+			},
+		},
+		{
+			name: "detail",
+			pager: func() ([]nodes.Node, error) {
+				pages, err := nodes.ListDetail(client, nodes.ListOpts{}).AllPages(t.Context())
+				if err != nil {
+					return nil, err
+				}
+				return nodes.ExtractNodes(pages)
+			},
+			wantDriver: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := tt.pager()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(have) != 3 || have[2].Name != "web03" {
+				t.Fatalf("have %d nodes, want all 3 across pages", len(have))
+			}
+			if hasDriver := have[0].DriverInfo != nil; hasDriver != tt.wantDriver {
+				t.Errorf("have driver_info %v, want %v", hasDriver, tt.wantDriver)
+			}
+		})
+	}
+
+	node, err := nodes.Get(t.Context(), client, "web02").Extract()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.UUID != "uuid-web02" {
+		t.Errorf("have node %q, want uuid-web02", node.UUID)
+	}
+	_, err = nodes.Get(t.Context(), client, "web04").Extract()
+	if !gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+		t.Errorf("have error %v, want status 404", err)
+	}
+}
+
+func TestServer_Patch(t *testing.T) {
+	s := New(t)
+	s.AddNode(nodes.Node{UUID: "uuid-web01", InstanceInfo: map[string]any{"image": "x"}})
+	client := s.ServiceClient()
+
+	_, err := nodes.Update(t.Context(), client, "uuid-web01", nodes.UpdateOpts{
+		nodes.UpdateOperation{
+			Op:    nodes.AddOp,
+			Path:  "/instance_info/user_data",
+			Value: "#!/bin/sh",
+		},
+		nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/instance_info/image"},
+		nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/extra/rack~1row", Value: "r1"},
+	}).Extract()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	node, ok := s.Node("uuid-web01")
+	if !ok {
+		t.Fatal("expected node to exist")
+	}
+	if have := node.InstanceInfo["user_data"]; have != "#!/bin/sh" {
+		t.Errorf("have user_data %v, want #!/bin/sh", have)
+	}
+	if _, ok := node.InstanceInfo["image"]; ok {
+		t.Error("expected image to be removed")
+	}
+	if have := node.Extra["rack/row"]; have != "r1" {
+		t.Errorf("have extra %v, want rack/row set", node.Extra)
+	}
+
+	_, err = nodes.Update(t.Context(), client, "uuid-web01", nodes.UpdateOpts{
+		nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/instance_info/missing"},
+	}).Extract()
+	if !gophercloud.ResponseCodeIs(err, http.StatusBadRequest) {
+		t.Errorf("have error %v, want status 400", err)
+	}
+}
+
+func TestServer_Ports(t *testing.T) {
+	s := New(t)
+	s.AddNode(nodes.Node{UUID: "uuid-web01"})
+	s.AddPort(ports.Port{UUID: "port-1", Address: "52:54:00:00:00:01", NodeUUID: "uuid-web01"})
+	s.AddPort(ports.Port{UUID: "port-2", Address: "52:54:00:00:00:02", NodeUUID: "uuid-web02"})
+	client := s.ServiceClient()
+
+	pages, err := ports.ListDetail(client, ports.ListOpts{Address: "52:54:00:00:00:01"}).
+		AllPages(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have, err := ports.ExtractPorts(pages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(have) != 1 || have[0].NodeUUID != "uuid-web01" {
+		t.Errorf("have ports %+v, want port-1 with its node", have)
+	}
+
+	pages, err = ports.List(client, ports.ListOpts{}).AllPages(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	have, err = ports.ExtractPorts(pages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(have) != 2 || have[0].NodeUUID != "" {
+		t.Errorf("have ports %+v, want both without their node", have)
+	}
+
+	s.RemoveNode("uuid-web01")
+	pages, err = ports.List(client, ports.ListOpts{}).AllPages(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, _ := ports.ExtractPorts(pages); len(have) != 1 {
+		t.Errorf("have %d ports, want the port of the removed node gone", len(have))
+	}
+}
+
+func TestServer_Failure(t *testing.T) {
+	s := New(t)
+	client := s.ServiceClient()
+
+	pages, err := drivers.ListDrivers(client, drivers.ListDriversOpts{}).AllPages(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, _ := drivers.ExtractDrivers(pages); len(have) != 1 || have[0].Name != "ipmi" {
+		t.Errorf("have drivers %+v, want ipmi", have)
+	}
+
+	s.SetFailure(http.StatusServiceUnavailable)
+	_, err = nodes.Get(t.Context(), client, "uuid-web01").Extract()
+	if !gophercloud.ResponseCodeIs(err, http.StatusServiceUnavailable) {
+		t.Errorf("have error %v, want status 503", err)
+	}
+
+	want := []string{"GET /v1/drivers", "GET /v1/nodes/uuid-web01"}
+	if have := s.Requests(); !slices.Equal(have, want) {
+		t.Errorf("have requests %v, want %v", have, want)
+	}
+}
@@ -0,0 +1,167 @@
+// Package wasmplugin assembles WASM plugins implementing the ABI of package
+// plugins from a few instructions, so plugins can be tested without a WASM
+// toolchain.
+package wasmplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Bodies of the mutate and resolve functions of plugins.
+var (
+	// Echo returns its input.
+	Echo = []byte{
+		0x20, 0x00, // local.get 0
+		0xad,       // i64.extend_i32_u
+		0x42, 0x20, // i64.const 32
+		0x86,       // i64.shl
+		0x20, 0x01, // local.get 1
+		0xad, // i64.extend_i32_u
+		0x84, // i64.or
+	}
+
+	// Spin never returns.
+	Spin = []byte{
+		0x03, 0x40, // loop
+		0x0c, 0x00, // br 0
+		0x0b,       // end
+		0x42, 0x00, // i64.const 0
+	}
+)
+
+// Constant returns the body of a function returning the data of its
+// module, stored at the start of its memory.
+func Constant(data string) []byte {
+	return append([]byte{0x42}, sleb128(int64(len(data)))...) // i64.const len
+}
+
+// Function is a function exported by a plugin, of the type of alloc or,
+// when Call is set, of mutate and resolve.
+type Function struct {
+	Name string
+	Call bool
+	Body []byte
+}
+
+// Mutate returns the mutate function of a plugin.
+func Mutate(body []byte) Function {
+	return Function{Name: "mutate", Call: true, Body: body}
+}
+
+// Resolve returns the resolve function of a plugin.
+func Resolve(body []byte) Function {
+	return Function{Name: "resolve", Call: true, Body: body}
+}
+
+// Module assembles a plugin exporting its memory, a bump allocator and
+// functions, with data at the start of its memory.
+func Module(data string, functions ...Function) []byte {
+	section := func(id byte, contents ...[]byte) []byte {
+		b := vector(contents...)
+		return append(append([]byte{id}, uleb128(uint64(len(b)))...), b...)
+	}
+	allocType := []byte{0x60, 0x01, 0x7f, 0x01, 0x7f}
+	callType := []byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e}
+
+	types := [][]byte{{0x00}}
+	exports := [][]byte{
+		append(name("memory"), 0x02, 0x00),
+		append(name("alloc"), 0x00, 0x00),
+	}
+	code := [][]byte{body([]byte{
+		0x23, 0x00, // global.get 0
+		0x23, 0x00, // global.get 0
+		0x20, 0x00, // local.get 0
+		0x6a,       // i32.add
+		0x24, 0x00, // global.set 0
+	})}
+	for i, fn := range functions {
+		if fn.Call {
+			types = append(types, []byte{0x01})
+		} else {
+			types = append(types, []byte{0x00})
+		}
+		exports = append(exports, append(name(fn.Name), 0x00, byte(i+1)))
+		code = append(code, body(fn.Body))
+	}
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(0x01, allocType, callType)...)
+	module = append(module, section(0x03, types...)...)
+	module = append(module, section(0x05, []byte{0x00, 0x01})...)
+	// The allocator hands out memory past the data.
+	module = append(module, section(0x06, []byte{0x7f, 0x01, 0x41, 0x80, 0x08, 0x0b})...)
+	module = append(module, section(0x07, exports...)...)
+	module = append(module, section(0x0a, code...)...)
+	segment := append([]byte{0x00, 0x41, 0x00, 0x0b}, uleb128(uint64(len(data)))...)
+	module = append(module, section(0x0b, append(segment, data...))...)
+	return module
+}
+
+// Mutator returns a plugin whose mutate function returns output.
+func Mutator(output string) []byte {
+	return Module(output, Mutate(Constant(output)))
+}
+
+// Resolver returns a plugin whose resolve function returns output.
+func Resolver(output string) []byte {
+	return Module(output, Resolve(Constant(output)))
+}
+
+// Write writes a plugin to dir, with a modification time set apart from
+// the previous version of the file.
+func Write(t *testing.T, dir, name string, module []byte, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name+".wasm")
+	if err := os.WriteFile(path, module, 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func body(instructions []byte) []byte {
+	b := append([]byte{0x00}, instructions...) // no locals
+	b = append(b, 0x0b)
+	return append(uleb128(uint64(len(b))), b...)
+}
+
+func name(s string) []byte {
+	return append(uleb128(uint64(len(s))), s...)
+}
+
+func vector(items ...[]byte) []byte {
+	b := uleb128(uint64(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func uleb128(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func sleb128(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
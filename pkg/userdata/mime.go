@@ -0,0 +1,104 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+)
+
+// MergeType is the cloud-init merge strategy set on cloud-config parts, so
+// lists are appended and later parts override the keys of earlier ones.
+const MergeType = "list(append)+dict(recurse_array)+str()"
+
+// contentTypes maps user data formats to the MIME types cloud-init
+// dispatches on.
+var contentTypes = map[Format]string{
+	FormatCloudConfig: "text/cloud-config",
+	FormatScript:      "text/x-shellscript",
+	FormatBoothook:    "text/cloud-boothook",
+	FormatInclude:     "text/x-include-url",
+	FormatJinja:       "text/jinja2",
+	FormatMIME:        "multipart/mixed",
+}
+
+// Part is a user data fragment of a multipart document.
+type Part struct {
+	// Filename names the part in its Content-Disposition header.
+	Filename string
+
+	// Content is the decoded fragment.
+	Content []byte
+}
+
+// Combine assembles parts, in order, into a multipart/mixed document as
+// cloud-init expects layered configs. Content types are detected from each
+// part; Ignition configs cannot be combined and are rejected.
+func Combine(parts []Part) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range parts {
+		format := Detect(part.Content)
+		if format == FormatIgnition || format == FormatButane {
+			return nil, fmt.Errorf("%s user data in %s cannot be combined with other parts",
+				format, part.Filename)
+		}
+		contentType, ok := contentTypes[format]
+		if !ok {
+			return nil, fmt.Errorf("unknown user data format in %s", part.Filename)
+		}
+
+		header := textproto.MIMEHeader{}
+		if format == FormatMIME {
+			// Nested documents carry their own headers, including the
+			// boundary, which cloud-init walks recursively.
+			contentType, body, err := splitMIME(part.Content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", part.Filename, err)
+			}
+			header.Set("Content-Type", contentType)
+			part.Content = body
+		} else {
+			header.Set("Content-Type", contentType+`; charset="utf-8"`)
+		}
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", part.Filename))
+		if format == FormatCloudConfig {
+			header.Set("Merge-Type", MergeType)
+		}
+
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create part %s: %w", part.Filename, err)
+		}
+		if _, err := w.Write(part.Content); err != nil {
+			return nil, fmt.Errorf("failed to write part %s: %w", part.Filename, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart document: %w", err)
+	}
+
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, "Content-Type: multipart/mixed; boundary=%q\r\n", mw.Boundary())
+	doc.WriteString("MIME-Version: 1.0\r\n\r\n")
+	doc.Write(body.Bytes())
+	return doc.Bytes(), nil
+}
+
+// splitMIME returns the Content-Type header and the body of a MIME
+// document.
+func splitMIME(data []byte) (string, []byte, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid MIME document: %w", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read MIME document: %w", err)
+	}
+	return msg.Header.Get("Content-Type"), body, nil
+}
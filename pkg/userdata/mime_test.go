@@ -0,0 +1,86 @@
+package userdata
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	doc, err := Combine([]Part{
+		{Filename: "00-bootcmd.yaml", Content: []byte("#cloud-config\nbootcmd: [echo global]\n")},
+		{Filename: "resource-class.sh", Content: []byte("#!/bin/sh\necho class\n")},
+		{Filename: "user-data", Content: []byte(mimeUserData)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if have, err := Validate(doc); err != nil || have != FormatMIME {
+		t.Fatalf("combined document does not validate as MIME: %q, %v", have, err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		contentType string
+		mergeType   string
+		filename    string
+	}{
+		{`text/cloud-config; charset="utf-8"`, MergeType, "00-bootcmd.yaml"},
+		{`text/x-shellscript; charset="utf-8"`, "", "resource-class.sh"},
+		{`multipart/mixed; boundary="===boundary=="`, "", "user-data"},
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			if i != len(want) {
+				t.Fatalf("have %d parts, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(want) {
+			t.Fatalf("unexpected part %d", i)
+		}
+		if have := part.Header.Get("Content-Type"); have != want[i].contentType {
+			t.Errorf("part %d content type: have %q, want %q", i, have, want[i].contentType)
+		}
+		if have := part.Header.Get("Merge-Type"); have != want[i].mergeType {
+			t.Errorf("part %d merge type: have %q, want %q", i, have, want[i].mergeType)
+		}
+		if have := part.FileName(); have != want[i].filename {
+			t.Errorf("part %d filename: have %q, want %q", i, have, want[i].filename)
+		}
+	}
+}
+
+func TestCombine_errors(t *testing.T) {
+	for _, content := range []string{
+		`{"ignition": {"version": "3.4.0"}}`,
+		"variant: fcos\nversion: 1.4.0\n",
+		"hello world\n",
+	} {
+		_, err := Combine([]Part{
+			{Filename: "global", Content: []byte("#cloud-config\n")},
+			{Filename: "user-data", Content: []byte(content)},
+		})
+		if err == nil {
+			t.Errorf("expected error combining %q", content)
+		}
+	}
+}
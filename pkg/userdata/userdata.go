@@ -0,0 +1,206 @@
+// Package userdata recognizes and validates the user data formats understood
+// by cloud-init and Ignition, so malformed payloads are rejected before they
+// are stored on a node.
+package userdata
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/appkins-org/ironic-metadata/pkg/blob"
+	"github.com/appkins-org/ironic-metadata/pkg/metadata/ignition"
+	"gopkg.in/yaml.v2"
+)
+
+// Format names a user data format.
+type Format string
+
+// User data formats, named after the cloud-init handlers consuming them,
+// or for PowerShell and batch scripts, the cloudbase-init plugins.
+const (
+	FormatCloudConfig Format = "cloud-config"
+	FormatScript      Format = "script"
+	FormatBoothook    Format = "cloud-boothook"
+	FormatInclude     Format = "include"
+	FormatJinja       Format = "jinja"
+	FormatMIME        Format = "mime"
+	FormatIgnition    Format = "ignition"
+	FormatButane      Format = "butane"
+	FormatPowerShell  Format = "powershell"
+	FormatBatch       Format = "batch"
+)
+
+// MaxSize bounds the decoded size of validated user data.
+const MaxSize = 1 << 20
+
+// ErrUnknownFormat is returned for user data in none of the known formats.
+var ErrUnknownFormat = errors.New(
+	"user data must be #cloud-config YAML, a #! script, a MIME multipart document, " +
+		"an Ignition config or a PowerShell or batch script",
+)
+
+// Validate checks that data, optionally gzipped or base64 encoded gzip, is
+// well-formed user data and returns its format. Windows scripts may be UTF-16
+// text starting with a byte order mark.
+func Validate(data []byte) (Format, error) {
+	decoded, err := blob.Decode(data, MaxSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode user data: %w", err)
+	}
+	if len(bytes.TrimSpace(decoded)) == 0 {
+		return "", errors.New("user data is empty")
+	}
+	if blob.IsUTF16(decoded) {
+		if decoded, err = decodeUTF16(decoded); err != nil {
+			return "", err
+		}
+	}
+	if !utf8.Valid(decoded) {
+		return "", errors.New("user data is not valid UTF-8")
+	}
+
+	format := Detect(decoded)
+	switch format {
+	case FormatCloudConfig:
+		return format, validateCloudConfig(decoded)
+	case FormatMIME:
+		return format, validateMIME(decoded)
+	case FormatButane:
+		if _, _, err := ignition.TranslateButane(decoded); err != nil {
+			return "", fmt.Errorf("invalid butane config: %w", err)
+		}
+		return format, nil
+	case "":
+		return "", ErrUnknownFormat
+	}
+	return format, nil
+}
+
+// Detect returns the format of decoded user data, or an empty format when
+// it is not recognized.
+func Detect(data []byte) Format {
+	firstLine, _, _ := strings.Cut(string(data), "\n")
+	firstLine = strings.TrimSpace(firstLine)
+
+	switch {
+	case strings.HasPrefix(firstLine, "#cloud-config"):
+		return FormatCloudConfig
+	case strings.HasPrefix(firstLine, "#!"):
+		return FormatScript
+	case strings.HasPrefix(firstLine, "#cloud-boothook"):
+		return FormatBoothook
+	case strings.HasPrefix(firstLine, "#include"):
+		return FormatInclude
+	case strings.HasPrefix(firstLine, "## template: jinja"):
+		return FormatJinja
+	case strings.HasPrefix(firstLine, "#ps1"),
+		strings.HasPrefix(strings.ToLower(firstLine), "<powershell>"):
+		return FormatPowerShell
+	case strings.HasPrefix(strings.ToLower(firstLine), "rem cmd"),
+		strings.HasPrefix(strings.ToLower(firstLine), "<script>"):
+		return FormatBatch
+	case isMIME(data):
+		return FormatMIME
+	}
+	if _, ok := ignition.StoredVersion(data); ok {
+		return FormatIgnition
+	}
+	if ignition.IsButane(data) {
+		return FormatButane
+	}
+	return ""
+}
+
+// decodeUTF16 converts UTF-16 text starting with a byte order mark to UTF-8,
+// without the byte order mark.
+func decodeUTF16(data []byte) ([]byte, error) {
+	if len(data)%2 != 0 {
+		return nil, errors.New("user data is not valid UTF-16")
+	}
+	order := binary.ByteOrder(binary.LittleEndian)
+	if bytes.HasPrefix(data, []byte{0xfe, 0xff}) {
+		order = binary.BigEndian
+	}
+	units := make([]uint16, 0, len(data)/2-1)
+	for i := 2; i < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return []byte(string(utf16.Decode(units))), nil
+}
+
+// isMIME reports whether data starts with MIME headers.
+func isMIME(data []byte) bool {
+	header, _, _ := bytes.Cut(data, []byte("\n\n"))
+	for _, line := range strings.Split(string(header), "\n") {
+		name, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "content-type", "mime-version":
+			return true
+		}
+	}
+	return false
+}
+
+// validateCloudConfig checks that cloud-config is a YAML mapping.
+func validateCloudConfig(data []byte) error {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid cloud-config: %w", err)
+	}
+	return nil
+}
+
+// validateMIME checks that a MIME document parses and that its parts are
+// themselves valid user data.
+func validateMIME(data []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid MIME document: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid MIME content type: %w", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid MIME part: %w", err)
+		}
+		var body io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		contents, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to read MIME part: %w", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType == "text/cloud-config" {
+			if err := validateCloudConfig(contents); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package userdata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+)
+
+const mimeUserData = `Content-Type: multipart/mixed; boundary="===boundary=="
+MIME-Version: 1.0
+
+--===boundary==
+Content-Type: text/cloud-config; charset="us-ascii"
+
+#cloud-config
+hostname: node-01
+
+--===boundary==
+Content-Type: text/x-shellscript
+Content-Transfer-Encoding: base64
+
+IyEvYmluL3NoCmVjaG8gaGkK
+
+--===boundary==--
+`
+
+const invalidMIMEUserData = `Content-Type: multipart/mixed; boundary=b
+
+--b
+Content-Type: text/cloud-config
+
+hostname: [
+--b--
+`
+
+func gzipBase64(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    string
+		want    Format
+		wantErr bool
+	}{
+		{name: "cloud-config", have: "#cloud-config\nhostname: node-01\n", want: FormatCloudConfig},
+		{name: "script", have: "#!/bin/sh\necho hi\n", want: FormatScript},
+		{name: "boothook", have: "#cloud-boothook\necho hi\n", want: FormatBoothook},
+		{name: "include", have: "#include\nhttps://example.com/ud\n", want: FormatInclude},
+		{name: "jinja", have: "## template: jinja\n#cloud-config\n", want: FormatJinja},
+		{name: "mime", have: mimeUserData, want: FormatMIME},
+		{name: "ignition", have: `{"ignition": {"version": "3.4.0"}}`, want: FormatIgnition},
+		{name: "butane", have: "variant: fcos\nversion: 1.4.0\n", want: FormatButane},
+		{name: "powershell", have: "#ps1_sysnative\nGet-Date\n", want: FormatPowerShell},
+		{name: "powershell tags", have: "<powershell>\nGet-Date\n", want: FormatPowerShell},
+		{name: "batch", have: "rem cmd\necho hi\n", want: FormatBatch},
+		{name: "utf-16le", have: "\xff\xfe#\x00p\x00s\x001\x00\n\x00", want: FormatPowerShell},
+		{name: "utf-16be", have: "\xfe\xff\x00#\x00p\x00s\x001\x00\n", want: FormatPowerShell},
+		{name: "odd utf-16", have: "\xff\xfe#\x00p", wantErr: true},
+		{
+			name: "gzipped cloud-config",
+			have: gzipBase64(t, "#cloud-config\nhostname: node-01\n"),
+			want: FormatCloudConfig,
+		},
+		{name: "invalid cloud-config", have: "#cloud-config\nhostname: [\n", wantErr: true},
+		{name: "cloud-config list", have: "#cloud-config\n- a\n- b\n", wantErr: true},
+		{name: "invalid mime part", have: invalidMIMEUserData, wantErr: true},
+		{name: "unknown", have: "hello world\n", wantErr: true},
+		{name: "empty", have: "  \n", wantErr: true},
+		{name: "binary", have: "\xff\xfe\x00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := Validate([]byte(tt.have))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got format %q", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
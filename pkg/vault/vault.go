@@ -0,0 +1,325 @@
+// Package vault reads secrets from HashiCorp Vault, authenticating with a
+// token, AppRole or Kubernetes service account, so user data can reference
+// secrets instead of carrying them.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/metrics"
+)
+
+// Authentication methods.
+const (
+	AuthToken      = "token"
+	AuthAppRole    = "approle"
+	AuthKubernetes = "kubernetes"
+)
+
+// DefaultKubernetesTokenPath is where Kubernetes mounts the service account
+// token of a pod.
+const DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// ErrPermissionDenied is returned when Vault rejects the client's token.
+var ErrPermissionDenied = errors.New("vault permission denied")
+
+// Config configures a Client.
+type Config struct {
+	// Address is the Vault server URL.
+	Address string
+
+	// Namespace is the Vault Enterprise namespace, if any.
+	Namespace string
+
+	// AuthMethod is AuthToken, AuthAppRole or AuthKubernetes. It defaults
+	// to AuthToken.
+	AuthMethod string
+
+	// AuthMount is the mount path of the auth method, defaulting to the
+	// method's name.
+	AuthMount string
+
+	// Token authenticates the AuthToken method.
+	Token string
+
+	// RoleID and SecretID authenticate the AuthAppRole method.
+	RoleID   string
+	SecretID string
+
+	// Role and TokenPath authenticate the AuthKubernetes method. TokenPath
+	// defaults to DefaultKubernetesTokenPath.
+	Role      string
+	TokenPath string
+
+	// CacheTTL is how long read secrets are cached. Secrets are read on
+	// every lookup when it is zero.
+	CacheTTL time.Duration
+}
+
+// Client reads secrets from Vault.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	cache       map[string]cacheEntry
+	counters    metrics.CacheCounters
+}
+
+// cacheEntry is a read secret, the time it was stored and the time it stops
+// being valid.
+type cacheEntry struct {
+	data    map[string]any
+	stored  time.Time
+	expires time.Time
+}
+
+// New returns a client for the Vault server described by cfg.
+func New(cfg Config) (*Client, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("vault address is required")
+	}
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = AuthToken
+	}
+	if cfg.AuthMount == "" {
+		cfg.AuthMount = cfg.AuthMethod
+	}
+
+	switch cfg.AuthMethod {
+	case AuthToken:
+		if cfg.Token == "" {
+			return nil, errors.New("vault token is required")
+		}
+	case AuthAppRole:
+		if cfg.RoleID == "" {
+			return nil, errors.New("vault AppRole role ID is required")
+		}
+	case AuthKubernetes:
+		if cfg.Role == "" {
+			return nil, errors.New("vault Kubernetes role is required")
+		}
+		if cfg.TokenPath == "" {
+			cfg.TokenPath = DefaultKubernetesTokenPath
+		}
+	default:
+		return nil, fmt.Errorf("unknown vault auth method %q", cfg.AuthMethod)
+	}
+
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		token:      cfg.Token,
+		cache:      make(map[string]cacheEntry),
+	}, nil
+}
+
+// Read returns a field of a secret. ref has the form <path>#<field>, where
+// path is the full API path below /v1, such as secret/data/nodes/web01 for
+// a KV version 2 engine mounted at secret. Fields that are not strings are
+// returned as JSON.
+func (c *Client) Read(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid vault reference %q, want <path>#<field>", ref)
+	}
+
+	data, err := c.readSecret(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %q", path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal field %q of %s: %w", field, path, err)
+	}
+	return string(b), nil
+}
+
+// readSecret returns the data of a secret, from the cache when possible.
+func (c *Client) readSecret(ctx context.Context, path string) (map[string]any, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if entry, ok := c.cache[path]; ok && now.Before(entry.expires) {
+		c.counters.Lookup(true)
+		c.mu.Unlock()
+		return entry.data, nil
+	}
+	c.counters.Lookup(false)
+	c.mu.Unlock()
+
+	var resp struct {
+		Data map[string]any `json:"data"`
+	}
+	err := c.request(ctx, http.MethodGet, path, &resp)
+	if errors.Is(err, ErrPermissionDenied) && c.cfg.AuthMethod != AuthToken {
+		// The login token may have been revoked or expired early.
+		c.mu.Lock()
+		c.token = ""
+		c.mu.Unlock()
+		err = c.request(ctx, http.MethodGet, path, &resp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret %s: %w", path, err)
+	}
+
+	// KV version 2 nests the secret below data, next to its metadata.
+	data := resp.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+
+	if c.cfg.CacheTTL > 0 {
+		c.mu.Lock()
+		c.cache[path] = cacheEntry{data: data, stored: now, expires: now.Add(c.cfg.CacheTTL)}
+		c.mu.Unlock()
+	}
+	return data, nil
+}
+
+// CacheStats returns the usage of the cache.
+func (c *Client) CacheStats() metrics.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counters.Stats(len(c.cache), func(yield func(time.Time) bool) {
+		for _, entry := range c.cache {
+			if !yield(entry.stored) {
+				return
+			}
+		}
+	})
+}
+
+// authToken returns a valid client token, logging in when needed.
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cfg.AuthMethod == AuthToken {
+		return c.cfg.Token, nil
+	}
+	if c.token != "" && (c.tokenExpiry.IsZero() || time.Now().Before(c.tokenExpiry)) {
+		return c.token, nil
+	}
+
+	var body map[string]string
+	switch c.cfg.AuthMethod {
+	case AuthAppRole:
+		body = map[string]string{"role_id": c.cfg.RoleID, "secret_id": c.cfg.SecretID}
+	case AuthKubernetes:
+		jwt, err := os.ReadFile(c.cfg.TokenPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read service account token: %w", err)
+		}
+		body = map[string]string{"role": c.cfg.Role, "jwt": strings.TrimSpace(string(jwt))}
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	err := c.do(ctx, http.MethodPost, "auth/"+c.cfg.AuthMount+"/login", "", body, &resp)
+	if err != nil {
+		return "", fmt.Errorf("vault %s login failed: %w", c.cfg.AuthMethod, err)
+	}
+	if resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault %s login returned no token", c.cfg.AuthMethod)
+	}
+
+	c.token = resp.Auth.ClientToken
+	c.tokenExpiry = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		// Log in again shortly before the token expires.
+		lease := time.Duration(resp.Auth.LeaseDuration) * time.Second
+		c.tokenExpiry = time.Now().Add(lease * 9 / 10)
+	}
+	return c.token, nil
+}
+
+// request sends an authenticated request to the Vault API.
+func (c *Client) request(ctx context.Context, method, path string, out any) error {
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, token, nil, out)
+}
+
+// do sends a request to the Vault API and decodes the response into out.
+func (c *Client) do(ctx context.Context, method, path, token string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	url := strings.TrimRight(c.cfg.Address, "/") + "/v1/" + path
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.cfg.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrPermissionDenied
+	case resp.StatusCode >= 300:
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		if len(errResp.Errors) > 0 {
+			return fmt.Errorf("%s: %s", resp.Status, strings.Join(errResp.Errors, "; "))
+		}
+		return errors.New(resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
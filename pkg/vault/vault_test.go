@@ -0,0 +1,174 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a Vault server holding a KV version 2 secret at
+// secret/data/nodes/web01 and a version 1 secret at kv/nodes/web01, readable
+// with the token "root" or any token issued by its login endpoints.
+func newTestServer(t *testing.T, reads *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	issued := map[string]bool{"root": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/auth/approle/login", "/v1/auth/kubernetes/login":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["role_id"] != "web" && body["jwt"] != "sa-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors": ["invalid credentials"]}`))
+				return
+			}
+			issued["issued"] = true
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "issued", "lease_duration": 3600}}`))
+			return
+		}
+
+		if !issued[r.Header.Get("X-Vault-Token")] {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		reads.Add(1)
+		switch r.URL.Path {
+		case "/v1/secret/data/nodes/web01":
+			_, _ = w.Write([]byte(`{"data": {"data": {"root_password": "hunter2", "port": 22},
+				"metadata": {"version": 1}}}`))
+		case "/v1/kv/nodes/web01":
+			_, _ = w.Write([]byte(`{"data": {"root_password": "swordfish"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_Read(t *testing.T) {
+	var reads atomic.Int32
+	srv := newTestServer(t, &reads)
+
+	c, err := New(Config{Address: srv.URL, Token: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		have    string
+		want    string
+		wantErr bool
+	}{
+		{name: "kv v2", have: "secret/data/nodes/web01#root_password", want: "hunter2"},
+		{name: "kv v1", have: "kv/nodes/web01#root_password", want: "swordfish"},
+		{name: "non-string field", have: "secret/data/nodes/web01#port", want: "22"},
+		{name: "missing field", have: "secret/data/nodes/web01#missing", wantErr: true},
+		{name: "missing secret", have: "secret/data/nodes/web02#root_password", wantErr: true},
+		{name: "no field", have: "secret/data/nodes/web01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := c.Read(t.Context(), tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != tt.want {
+				t.Errorf("have %q, want %q", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_ReadCached(t *testing.T) {
+	var reads atomic.Int32
+	srv := newTestServer(t, &reads)
+
+	c, err := New(Config{Address: srv.URL, Token: "root", CacheTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := c.Read(t.Context(), "secret/data/nodes/web01#root_password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := reads.Load(); have != 1 {
+		t.Errorf("expected 1 read, got %d", have)
+	}
+}
+
+func TestClient_Login(t *testing.T) {
+	var reads atomic.Int32
+	srv := newTestServer(t, &reads)
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("sa-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		have    Config
+		wantErr bool
+	}{
+		{name: "approle", have: Config{AuthMethod: AuthAppRole, RoleID: "web", SecretID: "s"}},
+		{name: "kubernetes", have: Config{AuthMethod: AuthKubernetes, Role: "web", TokenPath: tokenPath}},
+		{name: "bad approle", have: Config{AuthMethod: AuthAppRole, RoleID: "db"}, wantErr: true},
+		{name: "bad token", have: Config{Token: "guess"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.have
+			cfg.Address = srv.URL
+			c, err := New(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			have, err := c.Read(t.Context(), "kv/nodes/web01#root_password")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if have != "swordfish" {
+				t.Errorf("have %q, want %q", have, "swordfish")
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{Address: "http://vault:8200"},
+		{Address: "http://vault:8200", AuthMethod: AuthAppRole},
+		{Address: "http://vault:8200", AuthMethod: AuthKubernetes},
+		{Address: "http://vault:8200", AuthMethod: "ldap"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
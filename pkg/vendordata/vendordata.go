@@ -0,0 +1,186 @@
+// Package vendordata calls dynamic vendor data services, external REST
+// services in the style of Nova's DynamicJSON provider whose JSON responses
+// are served under their name in vendor_data2.json.
+package vendordata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds each call to a target when Config.Timeout is zero.
+const DefaultTimeout = 5 * time.Second
+
+// maxResponseSize bounds the response of a target.
+const maxResponseSize = 1 << 20
+
+// StaticKey is the vendor_data2.json key of the built-in static vendor
+// data, which targets cannot be named after.
+const StaticKey = "static"
+
+// Target is a named vendor data service.
+type Target struct {
+	Name string
+	URL  string
+}
+
+// ParseTargets parses a comma separated list of targets in Nova's
+// vendordata_dynamic_targets format, <name>@<url>.
+func ParseTargets(spec string) ([]Target, error) {
+	var targets []Target
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, rawURL, ok := strings.Cut(item, "@")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid target %q: expected <name>@<url>", item)
+		}
+		if name == StaticKey {
+			return nil, fmt.Errorf("invalid target %q: %q is reserved", item, StaticKey)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate target %q", name)
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid target %q: expected an http(s) URL", item)
+		}
+
+		seen[name] = true
+		targets = append(targets, Target{Name: name, URL: rawURL})
+	}
+	return targets, nil
+}
+
+// Request is the node context posted to each target, using the field names
+// Nova sends.
+type Request struct {
+	ProjectID  string            `json:"project-id"`
+	InstanceID string            `json:"instance-id"`
+	ImageID    string            `json:"image-id"`
+	Hostname   string            `json:"hostname"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// Config configures a Client.
+type Config struct {
+	Targets []Target
+
+	// Timeout bounds each call to a target, defaulting to DefaultTimeout.
+	Timeout time.Duration
+
+	// FailureFatal fails a fetch when any target fails, rather than
+	// leaving the failed targets out.
+	FailureFatal bool
+}
+
+// Client fetches vendor data from the configured targets.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+}
+
+// New returns a client for the targets of cfg.
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Fetch calls every target concurrently and returns their responses keyed
+// by target name. Failed targets are left out of the result and reported in
+// the error; with FailureFatal set, any failure instead fails the fetch and
+// the result is nil.
+func (c *Client) Fetch(ctx context.Context, req *Request) (map[string]any, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]any, len(c.cfg.Targets))
+		errs    []error
+	)
+	for _, target := range c.cfg.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.call(ctx, target, body)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("target %s: %w", target.Name, err))
+				return
+			}
+			results[target.Name] = data
+		}()
+	}
+	wg.Wait()
+
+	err = errors.Join(errs...)
+	if err != nil && c.cfg.FailureFatal {
+		return nil, err
+	}
+	return results, err
+}
+
+// call posts the request body to a target and decodes its JSON response.
+func (c *Client) call(ctx context.Context, target Target, body []byte) (any, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		target.URL,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(raw) > maxResponseSize {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxResponseSize)
+	}
+
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package vendordata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    string
+		want    []Target
+		wantErr bool
+	}{
+		{name: "empty"},
+		{
+			name: "targets",
+			have: "join@https://join.example.com/v1, keys@http://10.0.0.5:8080/",
+			want: []Target{
+				{Name: "join", URL: "https://join.example.com/v1"},
+				{Name: "keys", URL: "http://10.0.0.5:8080/"},
+			},
+		},
+		{name: "missing name", have: "https://join.example.com", wantErr: true},
+		{name: "reserved name", have: "static@https://join.example.com", wantErr: true},
+		{name: "duplicate", have: "a@http://a.example.com,a@http://b.example.com", wantErr: true},
+		{name: "not http", have: "a@ftp://a.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := ParseTargets(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Fetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/join":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"host": req.Hostname,
+				"node": req.InstanceID,
+			})
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte(`{}`))
+		case "/invalid":
+			_, _ = w.Write([]byte(`not json`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	req := &Request{InstanceID: "uuid-1", Hostname: "web01"}
+	join := Target{Name: "join", URL: srv.URL + "/join"}
+	want := map[string]any{"join": map[string]any{"host": "web01", "node": "uuid-1"}}
+
+	tests := []struct {
+		name         string
+		targets      []Target
+		failureFatal bool
+		want         map[string]any
+		wantErr      bool
+	}{
+		{name: "success", targets: []Target{join}, want: want},
+		{
+			name: "failures left out",
+			targets: []Target{
+				join,
+				{Name: "slow", URL: srv.URL + "/slow"},
+				{Name: "invalid", URL: srv.URL + "/invalid"},
+				{Name: "missing", URL: srv.URL + "/missing"},
+			},
+			want:    want,
+			wantErr: true,
+		},
+		{
+			name:         "failure fatal",
+			targets:      []Target{join, {Name: "missing", URL: srv.URL + "/missing"}},
+			failureFatal: true,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Config{
+				Targets:      tt.targets,
+				Timeout:      50 * time.Millisecond,
+				FailureFatal: tt.failureFatal,
+			})
+
+			have, err := c.Fetch(t.Context(), req)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
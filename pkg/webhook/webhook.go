@@ -0,0 +1,262 @@
+// Package webhook delivers events of the service, such as a node fetching
+// its user data, to webhooks, so external orchestration can react to boots
+// as they happen. Events are queued and posted in the background, signed
+// with HMAC-SHA256 and retried with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+)
+
+// Defaults of Config.
+const (
+	DefaultTimeout   = 5 * time.Second
+	DefaultRetries   = 3
+	DefaultBackoff   = time.Second
+	DefaultQueueSize = 1024
+)
+
+// workers is the number of events delivered concurrently.
+const workers = 4
+
+// Headers of deliveries.
+const (
+	// EventHeader is the type of the event.
+	EventHeader = "X-Ironic-Metadata-Event"
+
+	// DeliveryHeader is the ID of the event, the same across retries so
+	// receivers can drop duplicates.
+	DeliveryHeader = "X-Ironic-Metadata-Delivery"
+
+	// SignatureHeader is the signature of the body, as returned by Sign,
+	// when a secret is configured.
+	SignatureHeader = "X-Ironic-Metadata-Signature"
+)
+
+// ParseURLs parses a comma separated list of webhook URLs.
+func ParseURLs(spec string) ([]string, error) {
+	var urls []string
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		u, err := url.Parse(item)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid webhook URL %q: expected an http(s) URL", item)
+		}
+		urls = append(urls, item)
+	}
+	return urls, nil
+}
+
+// Config configures a Notifier.
+type Config struct {
+	// URLs are the webhooks every event is posted to.
+	URLs []string
+
+	// Secret signs the events when set.
+	Secret string
+
+	// Events are the types of events sent, every type when empty.
+	Events map[string]bool
+
+	// Timeout bounds each attempt to post an event, defaulting to
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	// Retries is the number of times a failed delivery is retried.
+	Retries int
+
+	// Backoff is the delay before the first retry, doubling with each
+	// retry. It defaults to DefaultBackoff.
+	Backoff time.Duration
+
+	// QueueSize bounds the events waiting to be delivered, defaulting to
+	// DefaultQueueSize.
+	QueueSize int
+
+	// OnError is called when delivering an event to a webhook fails after
+	// its retries.
+	OnError func(event events.Event, url string, err error)
+}
+
+// Notifier delivers events to webhooks.
+type Notifier struct {
+	cfg        Config
+	httpClient *http.Client
+	queue      chan events.Event
+}
+
+// New returns a notifier for the webhooks of cfg. Events are delivered
+// once Run is called.
+func New(cfg Config) (*Notifier, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, errors.New("a webhook URL is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Retries < 0 {
+		return nil, fmt.Errorf("invalid webhook retries %d", cfg.Retries)
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	return &Notifier{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		queue:      make(chan events.Event, cfg.QueueSize),
+	}, nil
+}
+
+// Name returns webhook.
+func (n *Notifier) Name() string {
+	return "webhook"
+}
+
+// Wants reports whether events of a type are sent.
+func (n *Notifier) Wants(eventType string) bool {
+	return len(n.cfg.Events) == 0 || n.cfg.Events[eventType]
+}
+
+// Notify queues an event for delivery, setting its ID and timestamp when
+// unset. It never blocks, and reports false when the queue is full and the
+// event is dropped.
+func (n *Notifier) Notify(event events.Event) bool {
+	if event.ID == "" {
+		event.ID = events.NewID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	select {
+	case n.queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run delivers queued events until ctx is done. Deliveries in flight are
+// abandoned then, and events still queued are dropped.
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case event := <-n.queue:
+					n.deliver(ctx, event)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// deliver posts an event to every webhook.
+func (n *Notifier) deliver(ctx context.Context, event events.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.error(event, "", fmt.Errorf("failed to marshal event: %w", err))
+		return
+	}
+	for _, u := range n.cfg.URLs {
+		if err := n.post(ctx, u, event, body); err != nil && ctx.Err() == nil {
+			n.error(event, u, err)
+		}
+	}
+}
+
+// post posts an event to a webhook, retrying failed attempts unless the
+// webhook rejected the event.
+func (n *Notifier) post(ctx context.Context, u string, event events.Event, body []byte) error {
+	backoff := n.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.send(ctx, u, event, body)
+		if err == nil || !retry || attempt == n.cfg.Retries {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one attempt to post an event to a webhook, reporting whether
+// a failed attempt is worth retrying.
+func (n *Notifier) send(
+	ctx context.Context,
+	u string,
+	event events.Event,
+	body []byte,
+) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	if n.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.cfg.Secret, body))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post event: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode == http.StatusRequestTimeout
+		return retry, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return false, nil
+}
+
+func (n *Notifier) error(event events.Event, u string, err error) {
+	if n.cfg.OnError != nil {
+		n.cfg.OnError(event, u, err)
+	}
+}
+
+// Sign returns the signature of a body with secret, sha256= followed by
+// the hex encoded HMAC-SHA256 of the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appkins-org/ironic-metadata/pkg/events"
+)
+
+func TestParseURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		have    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", have: ""},
+		{
+			name: "urls",
+			have: "https://cmdb.example.com/boots, http://ci.example.com/hook",
+			want: []string{"https://cmdb.example.com/boots", "http://ci.example.com/hook"},
+		},
+		{name: "no scheme", have: "cmdb.example.com/boots", wantErr: true},
+		{name: "unsupported scheme", have: "ftp://cmdb.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := ParseURLs(tt.have)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifier_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "delivered", statuses: []int{http.StatusNoContent}, wantAttempts: 1},
+		{
+			name:         "retried",
+			statuses:     []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK},
+			wantAttempts: 3,
+		},
+		{
+			name:         "retries exhausted",
+			statuses:     []int{http.StatusBadGateway, http.StatusServiceUnavailable, 599},
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "rejected",
+			statuses:     []int{http.StatusBadRequest},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			done := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					body, err := io.ReadAll(r.Body)
+					if err != nil {
+						t.Errorf("unexpected error: %v", err)
+					}
+					if have := r.Header.Get(SignatureHeader); have != Sign("secret", body) {
+						t.Errorf("have signature %q, want the HMAC of the body", have)
+					}
+					var event events.Event
+					if err := json.Unmarshal(body, &event); err != nil {
+						t.Errorf("unexpected error: %v", err)
+					}
+					if event.NodeUUID != "node-1" || event.ID == "" || event.Timestamp.IsZero() ||
+						r.Header.Get(DeliveryHeader) != event.ID ||
+						r.Header.Get(EventHeader) != events.UserData {
+						t.Errorf("have event %+v, want the node, ID and timestamp", event)
+					}
+
+					attempt := int(attempts.Add(1))
+					w.WriteHeader(tt.statuses[attempt-1])
+					if attempt == len(tt.statuses) {
+						close(done)
+					}
+				}))
+			defer srv.Close()
+
+			var mu sync.Mutex
+			var errs []error
+			errored := make(chan struct{}, 1)
+			n, err := New(Config{
+				URLs:    []string{srv.URL},
+				Secret:  "secret",
+				Retries: 2,
+				Backoff: time.Millisecond,
+				OnError: func(_ events.Event, _ string, err error) {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					errored <- struct{}{}
+				},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+			go func() {
+				n.Run(ctx)
+				close(stopped)
+			}()
+
+			if !n.Notify(events.Event{Type: events.UserData, NodeUUID: "node-1", Status: 200}) {
+				t.Fatal("expected the event to be queued")
+			}
+			<-done
+			if tt.wantErr {
+				<-errored
+			}
+			cancel()
+			<-stopped
+
+			if have := int(attempts.Load()); have != tt.wantAttempts {
+				t.Errorf("have %d attempts, want %d", have, tt.wantAttempts)
+			}
+			if have := len(errs) > 0; have != tt.wantErr {
+				t.Errorf("have errors %v, want errors %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	n, err := New(Config{
+		URLs:      []string{"https://cmdb.example.com/boots"},
+		Events:    map[string]bool{events.PhoneHome: true},
+		QueueSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.Wants(events.UserData) || !n.Wants(events.PhoneHome) {
+		t.Error("expected only phone home events to be wanted")
+	}
+	if !n.Notify(events.Event{Type: events.PhoneHome}) {
+		t.Fatal("expected the event to be queued")
+	}
+	if n.Notify(events.Event{Type: events.PhoneHome}) {
+		t.Error("expected the event to be dropped once the queue is full")
+	}
+}